@@ -0,0 +1,154 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/apis"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/bundle"
+)
+
+const usage = `Usage:
+  appsub-bundle export [--namespace <namespace>] [--file <bundle.yaml>]
+  appsub-bundle import --file <bundle.yaml> [--overwrite] [--dry-run]
+`
+
+// main exports the channels and subscriptions of the hub of the kubeconfig as a bundle, or imports a bundle into it,
+// e.g.
+// appsub-bundle export > bundle.yaml
+// KUBECONFIG=new-hub.kubeconfig appsub-bundle import --file bundle.yaml
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+
+	switch os.Args[1] {
+	case "export":
+		err = runExport(os.Args[2:])
+	case "import":
+		err = runImport(os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %v\n%v", os.Args[1], usage)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func runExport(args []string) error {
+	flags := pflag.NewFlagSet("export", pflag.ExitOnError)
+	namespace := flags.String("namespace", "", "The namespace of the exported channels and subscriptions, all the namespaces if empty.")
+	file := flags.String("file", "", "The file of the bundle, the standard output if empty.")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	clt, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	b, err := bundle.Export(context.TODO(), clt, *namespace)
+	if err != nil {
+		return err
+	}
+
+	out, err := b.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if *file == "" {
+		_, err = os.Stdout.Write(out)
+
+		return err
+	}
+
+	return ioutil.WriteFile(*file, out, 0600)
+}
+
+func runImport(args []string) error {
+	flags := pflag.NewFlagSet("import", pflag.ExitOnError)
+	file := flags.String("file", "", "The file of the bundle to import.")
+	options := bundle.ImportOptions{}
+	flags.BoolVar(&options.Overwrite, "overwrite", false, "Update the existing channels, subscriptions and config maps with the ones of the bundle.")
+	flags.BoolVar(&options.DryRun, "dry-run", false, "Report what the import would do without doing it.")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *file == "" {
+		return fmt.Errorf("the --file of the bundle is required")
+	}
+
+	data, err := ioutil.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+
+	b, err := bundle.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	clt, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	report, err := bundle.Import(context.TODO(), clt, b, options)
+
+	for _, line := range report {
+		fmt.Println(line)
+	}
+
+	return err
+}
+
+func newClient() (client.Client, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := runtime.NewScheme()
+
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	if err := apis.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
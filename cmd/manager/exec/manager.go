@@ -36,13 +36,21 @@ import (
 	spokeClusterV1 "open-cluster-management.io/api/cluster/v1"
 	manifestWorkV1 "open-cluster-management.io/api/work/v1"
 	agentaddon "open-cluster-management.io/multicloud-operators-subscription/addon"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/adminapi"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/admission"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/apis"
 	ansiblejob "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/ansible/v1alpha1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/channelcache"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/controller"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/controller/mcmhub"
 	leasectrl "open-cluster-management.io/multicloud-operators-subscription/pkg/controller/subscription"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/eventstream"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/subscriber"
+	gitsubscriber "open-cluster-management.io/multicloud-operators-subscription/pkg/subscriber/git"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/synchronizer"
+	kubesynchronizer "open-cluster-management.io/multicloud-operators-subscription/pkg/synchronizer/kubernetes"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+	awsutils "open-cluster-management.io/multicloud-operators-subscription/pkg/utils/aws"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/webhook"
 )
 
@@ -84,6 +92,60 @@ func RunManager() {
 
 	klog.Info("kubeconfig:" + Options.KubeConfig)
 
+	utils.SetFetchDNSResolver(Options.FetchDNSResolver)
+	utils.SetGitProxy(Options.GitHTTPProxy, Options.GitHTTPSProxy, Options.GitNoProxy)
+	utils.SetRenderHelmCharts(Options.RenderHelmCharts)
+	utils.SetHelmV2Charts(Options.HelmV2Charts)
+	utils.SetHelmProvenanceKeyring(Options.HelmProvenanceKeyring)
+	utils.SetStrictSync(Options.StrictSync)
+	awsutils.SetAmbientCredentials(Options.AmbientCredentials, Options.AmbientCredentialsNS)
+	utils.SetRepoLimits(utils.RepoLimits{
+		MaxRepoSize:  int64(Options.GitMaxRepoSizeMB) << 20,
+		MaxFileSize:  int64(Options.GitMaxFileSizeMB) << 20,
+		MaxManifests: Options.GitMaxManifests,
+	})
+
+	if Options.HelmLocalRenderSandbox {
+		utils.SetHelmRenderSandbox(&utils.HelmRenderSandbox{
+			Timeout:    Options.HelmRenderTimeout,
+			MemoryMB:   Options.HelmRenderMemoryMB,
+			CPUSeconds: Options.HelmRenderCPUSeconds,
+		})
+	}
+
+	if err := utils.SetChannelSourceAllowList(Options.ChannelSourceAllowList); err != nil {
+		klog.Error(err)
+		os.Exit(1)
+	}
+
+	if err := utils.SetNamespaceContainment(Options.NamespaceContainment); err != nil {
+		klog.Error(err)
+		os.Exit(1)
+	}
+
+	if err := admission.SetNamespaceQuota(Options.MaxSubscriptionsPerNS, Options.MaxChannelsPerNS,
+		Options.MaxReconcileRate); err != nil {
+		klog.Error(err)
+		os.Exit(1)
+	}
+
+	mcmhub.SetSyncWorkers(Options.HubSyncWorkers)
+	kubesynchronizer.SetKubectlLastApplied(Options.KubectlLastApplied)
+	kubesynchronizer.SetSyncAuditRetention(Options.SyncAuditRetention)
+
+	if err := kubesynchronizer.SetChangeFreezeConfigMap(Options.ChangeFreezeConfigMap); err != nil {
+		klog.Error(err)
+		os.Exit(1)
+	}
+
+	if err := utils.SetupSubscriberStateStore(Options.SubscriberStateStore, Options.SubscriberStateURL,
+		Options.SubscriberStateToken, Options.ClusterName); err != nil {
+		klog.Error(err)
+		os.Exit(1)
+	}
+
+	channelcache.SetClient(Options.ChannelCacheURL, Options.ChannelCacheTokenFile, Options.ChannelCacheCAFile)
+
 	// increase the dafault QPS(5) to 100, only sends 5 requests to API server
 	// seems to be unrealistic. Reading some other projects, it seems QPS 100 is
 	// a pretty common practice
@@ -181,6 +243,24 @@ func RunManager() {
 				os.Exit(1)
 			}
 		}
+
+		if Options.ChannelCacheAddress != "" {
+			// Setup the channel cache the managed clusters fetch the git channels from
+			if err := channelcache.Add(mgr, Options.ChannelCacheAddress, Options.TLSKeyFilePathName, Options.TLSCrtFilePathName,
+				Options.DisableTLS); err != nil {
+				klog.Error("Failed to initialize channel cache server with error:", err)
+				os.Exit(1)
+			}
+		}
+
+		if Options.AdmissionAddress != "" {
+			// Setup the validating admission webhook of the subscriptions
+			if err := admission.Add(mgr, Options.AdmissionAddress, Options.TLSKeyFilePathName, Options.TLSCrtFilePathName,
+				Options.DisableTLS); err != nil {
+				klog.Error("Failed to initialize admission webhook server with error:", err)
+				os.Exit(1)
+			}
+		}
 	} else if !strings.EqualFold(Options.ClusterName, "") {
 		// Setup ocinfrav1 Scheme for manager
 		if err := ocinfrav1.AddToScheme(mgr.GetScheme()); err != nil {
@@ -222,6 +302,30 @@ func RunManager() {
 		os.Exit(1)
 	}
 
+	if Options.EventStreamAddress != "" {
+		// Setup the event stream of the subscription activity of the synchronizers
+		if err := eventstream.Add(mgr, Options.EventStreamAddress, Options.TLSKeyFilePathName, Options.TLSCrtFilePathName,
+			Options.DisableTLS); err != nil {
+			klog.Error("Failed to initialize event stream server with error:", err)
+			os.Exit(1)
+		}
+	}
+
+	if Options.AdminAPIAddress != "" {
+		// the appsubs are previewed for the managed clusters on the hub
+		var previewer adminapi.Previewer
+		if !Options.Standalone && Options.ClusterName == "" {
+			previewer = mcmhub.NewClusterPreviewer(mgr.GetClient(), mgr.GetRESTMapper())
+		}
+
+		// Setup the admin API the platform portals drive the subscriptions applied by the controller with
+		if err := adminapi.Add(mgr, Options.AdminAPIAddress, Options.TLSKeyFilePathName, Options.TLSCrtFilePathName,
+			Options.DisableTLS, previewer); err != nil {
+			klog.Error("Failed to initialize admin API server with error:", err)
+			os.Exit(1)
+		}
+	}
+
 	sig := signals.SetupSignalHandler()
 
 	// Only detect if the placementDecsion API is ready on the hub cluster
@@ -285,6 +389,46 @@ func setupStandalone(mgr manager.Manager, hubconfig *rest.Config, id *types.Name
 		return err
 	}
 
+	if Options.MutationWebhooksConfig != "" {
+		// Setup the external webhooks mutating the rendered resources of the appsubs before they are applied
+		webhooks, err := kubesynchronizer.LoadMutationWebhooks(Options.MutationWebhooksConfig)
+		if err != nil {
+			klog.Error("Failed to load mutation webhooks with error:", err)
+
+			return err
+		}
+
+		for _, mutationWebhook := range webhooks {
+			kubesynchronizer.GetDefaultSynchronizer().AddManifestMutator(mutationWebhook)
+		}
+	}
+
+	if Options.SecretScanConfig != "" {
+		// Setup the scanner of the inline credentials of the resources of the appsubs before they are applied
+		scanner, err := kubesynchronizer.LoadSecretScanner(Options.SecretScanConfig)
+		if err != nil {
+			klog.Error("Failed to load secret scanner with error:", err)
+
+			return err
+		}
+
+		kubesynchronizer.GetDefaultSynchronizer().SetSecretScanner(scanner)
+	}
+
+	if Options.PackageFilterPlugins != "" {
+		// Setup the external plugins filtering the packages of the subscriptions after their package filter
+		plugins, err := utils.LoadPackageFilterPlugins(Options.PackageFilterPlugins)
+		if err != nil {
+			klog.Error("Failed to load package filter plugins with error:", err)
+
+			return err
+		}
+
+		for _, plugin := range plugins {
+			utils.AddPackageFilterPlugin(plugin)
+		}
+	}
+
 	// Setup Subscribers
 	if err := subscriber.AddToManager(mgr, hubconfig, id, Options.SyncInterval, isHub, standalone); err != nil {
 		klog.Error("Failed to initialize subscriber with error:", err)
@@ -292,6 +436,16 @@ func setupStandalone(mgr manager.Manager, hubconfig *rest.Config, id *types.Name
 		return err
 	}
 
+	if Options.GitWebhookAddress != "" {
+		// Setup the push webhook server syncing the git subscriptions without waiting for their reconcile interval
+		if err := gitsubscriber.AddWebhookServer(mgr, Options.GitWebhookAddress, Options.TLSKeyFilePathName,
+			Options.TLSCrtFilePathName, Options.DisableTLS); err != nil {
+			klog.Error("Failed to initialize Git webhook server with error:", err)
+
+			return err
+		}
+	}
+
 	// Setup all Controllers
 	if err := controller.AddToManager(mgr, hubconfig, id, isHub, standalone); err != nil {
 		klog.Error("Failed to initialize controller with error:", err)
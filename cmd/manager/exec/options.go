@@ -15,24 +15,66 @@
 package exec
 
 import (
+	"time"
+
 	pflag "github.com/spf13/pflag"
 )
 
 // SubscriptionCMDOptions for command line flag parsing
 type SubscriptionCMDOptions struct {
-	MetricsAddr           string
-	KubeConfig            string
-	ClusterName           string
-	HubConfigFilePathName string
-	TLSKeyFilePathName    string
-	TLSCrtFilePathName    string
-	SyncInterval          int
-	DisableTLS            bool
-	Standalone            bool
-	AgentImage            string
-	LeaseDurationSeconds  int
-	Debug                 bool
-	AgentInstallAll       bool
+	MetricsAddr            string
+	KubeConfig             string
+	ClusterName            string
+	HubConfigFilePathName  string
+	TLSKeyFilePathName     string
+	TLSCrtFilePathName     string
+	SyncInterval           int
+	DisableTLS             bool
+	Standalone             bool
+	AgentImage             string
+	LeaseDurationSeconds   int
+	Debug                  bool
+	AgentInstallAll        bool
+	FetchDNSResolver       string
+	ChannelCacheAddress    string
+	ChannelCacheURL        string
+	ChannelCacheTokenFile  string
+	ChannelCacheCAFile     string
+	EventStreamAddress     string
+	AdminAPIAddress        string
+	GitWebhookAddress      string
+	AdmissionAddress       string
+	MutationWebhooksConfig string
+	SecretScanConfig       string
+	PackageFilterPlugins   string
+	GitHTTPProxy           string
+	GitHTTPSProxy          string
+	GitNoProxy             string
+	ChannelSourceAllowList string
+	AmbientCredentials     bool
+	AmbientCredentialsNS   string
+	HubSyncWorkers         int
+	RenderHelmCharts       bool
+	HelmLocalRenderSandbox bool
+	HelmRenderTimeout      time.Duration
+	HelmRenderMemoryMB     int
+	HelmRenderCPUSeconds   int
+	HelmV2Charts           bool
+	HelmProvenanceKeyring  string
+	KubectlLastApplied     bool
+	ChangeFreezeConfigMap  string
+	SubscriberStateStore   string
+	SubscriberStateURL     string
+	SubscriberStateToken   string
+	GitMaxRepoSizeMB       int
+	GitMaxFileSizeMB       int
+	GitMaxManifests        int
+	NamespaceContainment   string
+	SyncAuditRetention     int
+	MaxSubscriptionsPerNS  int
+	MaxChannelsPerNS       int
+	MaxReconcileRate       string
+	StrictSync             bool
 }
 
 var Options = SubscriptionCMDOptions{
@@ -43,6 +85,11 @@ var Options = SubscriptionCMDOptions{
 	Standalone:           false,
 	AgentImage:           "quay.io/open-cluster-management/multicloud-operators-subscription:latest",
 	Debug:                false,
+	HubSyncWorkers:       1,
+	HelmRenderTimeout:    time.Minute,
+	HelmRenderMemoryMB:   1024,
+	HelmRenderCPUSeconds: 30,
+	HelmV2Charts:         true,
 }
 
 // ProcessFlags parses command line parameters into Options
@@ -133,6 +180,315 @@ func ProcessFlags() {
 		"Disable TLS on WebHook event listener.",
 	)
 
+	flag.StringVar(
+		&Options.FetchDNSResolver,
+		"fetch-dns-resolver",
+		Options.FetchDNSResolver,
+		"DNS server address the git, helm repo and object store fetchers resolve names with, e.g. 10.0.0.10 or [fd00::10]:53. "+
+			"Defaults to the system resolver.",
+	)
+
+	flag.StringVar(
+		&Options.GitHTTPProxy,
+		"git-http-proxy",
+		Options.GitHTTPProxy,
+		"Proxy URL of the http Git servers, unless set in the channel. The git proxy flags default to the HTTP_PROXY, "+
+			"HTTPS_PROXY and NO_PROXY environment variables.",
+	)
+
+	flag.StringVar(
+		&Options.GitHTTPSProxy,
+		"git-https-proxy",
+		Options.GitHTTPSProxy,
+		"Proxy URL of the https Git servers, unless set in the channel.",
+	)
+
+	flag.StringVar(
+		&Options.GitNoProxy,
+		"git-no-proxy",
+		Options.GitNoProxy,
+		"Comma separated hosts, domains and CIDRs of the Git servers reached without the proxy, unless set in the channel.",
+	)
+
+	flag.StringVar(
+		&Options.ChannelSourceAllowList,
+		"channel-source-allowlist",
+		Options.ChannelSourceAllowList,
+		"Comma separated host names, wildcard domains like *.example.com, IP addresses and CIDRs the channels may "+
+			"reference. The subscriptions of channels referencing other hosts are not synced. All hosts are allowed if empty.",
+	)
+
+	flag.BoolVar(
+		&Options.AmbientCredentials,
+		"object-store-ambient-credentials",
+		Options.AmbientCredentials,
+		"Allow the object bucket channels with the object-store-credentials: ambient annotation to read their bucket "+
+			"with the AWS credentials of the pod, like its IRSA role or the instance profile of its node.",
+	)
+
+	flag.StringVar(
+		&Options.AmbientCredentialsNS,
+		"object-store-ambient-credentials-namespaces",
+		Options.AmbientCredentialsNS,
+		"Comma separated namespaces of the channels allowed to use the ambient credentials of the pod. All the "+
+			"namespaces if empty.",
+	)
+
+	flag.StringVar(
+		&Options.NamespaceContainment,
+		"namespace-containment",
+		Options.NamespaceContainment,
+		"Policy of the namespaced resources subscribed in another namespace than the one of their subscription: force "+
+			"moves them to the subscription namespace, reject rejects them. The namespace-containment annotation of a "+
+			"namespace overrides it. If empty, only the resources of the non cluster-admin subscriptions are moved.",
+	)
+
+	flag.StringVar(
+		&Options.ChannelCacheAddress,
+		"channel-cache-address",
+		Options.ChannelCacheAddress,
+		"Address the hub channel cache server listens on, e.g. :8443. The channel cache is disabled if empty.",
+	)
+
+	flag.StringVar(
+		&Options.ChannelCacheURL,
+		"channel-cache-url",
+		Options.ChannelCacheURL,
+		"URL of the hub channel cache to fetch the git channels from. Git repos are cloned directly if empty.",
+	)
+
+	flag.StringVar(
+		&Options.ChannelCacheTokenFile,
+		"channel-cache-token-file",
+		Options.ChannelCacheTokenFile,
+		"File of the bearer token presented to the hub channel cache.",
+	)
+
+	flag.StringVar(
+		&Options.ChannelCacheCAFile,
+		"channel-cache-ca-file",
+		Options.ChannelCacheCAFile,
+		"CA certificate file to verify the hub channel cache server certificate.",
+	)
+
+	flag.StringVar(
+		&Options.EventStreamAddress,
+		"event-stream-address",
+		Options.EventStreamAddress,
+		"Address the subscription event stream server listens on, e.g. :8444. The event stream is disabled if empty.",
+	)
+
+	flag.StringVar(
+		&Options.AdminAPIAddress,
+		"admin-api-address",
+		Options.AdminAPIAddress,
+		"Address the subscription admin API server listens on, e.g. :8445. The admin API is disabled if empty.",
+	)
+
+	flag.StringVar(
+		&Options.GitWebhookAddress,
+		"git-webhook-address",
+		Options.GitWebhookAddress,
+		"Address the GitHub and GitLab push webhook server of the git subscriptions listens on, e.g. :8445. "+
+			"The git subscriptions are synced on their reconcile interval only if empty.",
+	)
+
+	flag.StringVar(
+		&Options.AdmissionAddress,
+		"admission-webhook-address",
+		Options.AdmissionAddress,
+		"Address the hub validating admission webhook server of the subscriptions listens on, e.g. :8446. "+
+			"The webhook is disabled if empty.",
+	)
+
+	flag.IntVar(
+		&Options.MaxSubscriptionsPerNS,
+		"max-subscriptions-per-namespace",
+		Options.MaxSubscriptionsPerNS,
+		"Maximum number of subscriptions of a namespace, enforced by the admission webhook. The max-subscriptions "+
+			"annotation of a namespace overrides it. Unlimited if 0.",
+	)
+
+	flag.IntVar(
+		&Options.MaxChannelsPerNS,
+		"max-channels-per-namespace",
+		Options.MaxChannelsPerNS,
+		"Maximum number of channels referenced by the subscriptions of a namespace, enforced by the admission webhook. "+
+			"The max-channels annotation of a namespace overrides it. Unlimited if 0.",
+	)
+
+	flag.StringVar(
+		&Options.MaxReconcileRate,
+		"max-reconcile-rate",
+		Options.MaxReconcileRate,
+		"Highest reconcile rate, off, low, medium or high, of the channels of a namespace and of the channels subscribed "+
+			"in a namespace, enforced by the admission webhook. The max-reconcile-rate annotation of a namespace "+
+			"overrides it. Unlimited if empty.",
+	)
+
+	flag.StringVar(
+		&Options.MutationWebhooksConfig,
+		"mutation-webhooks-config",
+		Options.MutationWebhooksConfig,
+		"Config file of the HTTP webhooks mutating the rendered resources of the subscriptions before they are applied. "+
+			"The resources are applied as rendered if empty.",
+	)
+
+	flag.StringVar(
+		&Options.SecretScanConfig,
+		"secret-scan-config",
+		Options.SecretScanConfig,
+		"Config file of the secret scanner flagging the resources of the subscriptions with inline credentials or private keys "+
+			"before they are applied, warning or blocking per its policy. The resources are not scanned if empty.",
+	)
+
+	flag.StringVar(
+		&Options.PackageFilterPlugins,
+		"package-filter-plugins-config",
+		Options.PackageFilterPlugins,
+		"Config file of the HTTP webhooks and commands deciding if the packages passing the package filter of the "+
+			"subscriptions are deployed. The packages are not filtered by plugins if empty.",
+	)
+
+	flag.IntVar(
+		&Options.HubSyncWorkers,
+		"hub-sync-workers",
+		Options.HubSyncWorkers,
+		"Number of subscriptions the hub reconciles concurrently. "+
+			"The pending subscriptions get a worker in turn across the namespaces.",
+	)
+
+	flag.BoolVar(
+		&Options.KubectlLastApplied,
+		"kubectl-last-applied",
+		Options.KubectlLastApplied,
+		"Maintain the kubectl.kubernetes.io/last-applied-configuration annotation on the resources applied by the subscriptions, "+
+			"for kubectl apply and diff to work on them.",
+	)
+
+	flag.StringVar(
+		&Options.ChangeFreezeConfigMap,
+		"change-freeze-configmap",
+		Options.ChangeFreezeConfigMap,
+		"The <namespace>/<name> config map freezing the changes of all the subscriptions while its frozen key is true. "+
+			"The changes held are reported in the subscription status. There is no change freeze if empty.",
+	)
+
+	flag.StringVar(
+		&Options.SubscriberStateStore,
+		"subscriber-state-store",
+		Options.SubscriberStateStore,
+		"Where the subscribers save the runtime state of the subscriptions, resumed when the controller restarts: "+
+			"memory, status (the subscription status), configmap (a config map next to each subscription) or kv "+
+			"(an external key value store).",
+	)
+
+	flag.StringVar(
+		&Options.SubscriberStateURL,
+		"subscriber-state-store-url",
+		Options.SubscriberStateURL,
+		"URL of the HTTP API of the external key value store of the kv subscriber state store.",
+	)
+
+	flag.StringVar(
+		&Options.SubscriberStateToken,
+		"subscriber-state-store-token-file",
+		Options.SubscriberStateToken,
+		"File of the bearer token presented to the external key value store of the kv subscriber state store.",
+	)
+
+	flag.IntVar(
+		&Options.SyncAuditRetention,
+		"sync-audit-retention",
+		Options.SyncAuditRetention,
+		"Number of syncs recorded in the <subscription>-sync-audit config map of each subscription, with the revision "+
+			"and the resources they applied. The sync audit is disabled if 0.",
+	)
+
+	flag.BoolVar(
+		&Options.StrictSync,
+		"strict-sync",
+		Options.StrictSync,
+		"Fail the sync of the Git subscriptions on any package error. Nothing is applied, the deployed commit "+
+			"doesn't advance and the sync is retried until all the packages of the commit are applied.",
+	)
+
+	flag.IntVar(
+		&Options.GitMaxRepoSizeMB,
+		"git-max-repo-size",
+		Options.GitMaxRepoSizeMB,
+		"Size limit of the clone of a Git repository, its history included, in MiB. The clone is aborted when it "+
+			"exceeds the limit. 0 is no limit.",
+	)
+
+	flag.IntVar(
+		&Options.GitMaxFileSizeMB,
+		"git-max-file-size",
+		Options.GitMaxFileSizeMB,
+		"Size limit of a file of the subscribed paths of a Git repository, in MiB. 0 is no limit.",
+	)
+
+	flag.IntVar(
+		&Options.GitMaxManifests,
+		"git-max-manifests",
+		Options.GitMaxManifests,
+		"Limit of the number of resource files of the subscribed paths of a Git repository. 0 is no limit.",
+	)
+
+	flag.BoolVar(
+		&Options.RenderHelmCharts,
+		"render-helm-charts",
+		Options.RenderHelmCharts,
+		"Template the Helm charts of the git subscriptions and apply the rendered resources instead of creating HelmRelease CRs, "+
+			"unless the subscription has the apps.open-cluster-management.io/helm-render annotation.",
+	)
+
+	flag.BoolVar(
+		&Options.HelmLocalRenderSandbox,
+		"helm-local-render-sandbox",
+		Options.HelmLocalRenderSandbox,
+		"Render the Helm charts templated locally, with --render-helm-charts or the helm-render: local annotation, "+
+			"in a subprocess limited by --helm-render-timeout, --helm-render-memory-limit and --helm-render-cpu-limit, "+
+			"instead of the operator process. The charts deployed with HelmRelease CRs are not sandboxed.",
+	)
+
+	flag.DurationVar(
+		&Options.HelmRenderTimeout,
+		"helm-render-timeout",
+		Options.HelmRenderTimeout,
+		"Time after which the Helm chart render subprocess is killed.",
+	)
+
+	flag.IntVar(
+		&Options.HelmRenderMemoryMB,
+		"helm-render-memory-limit",
+		Options.HelmRenderMemoryMB,
+		"Address space limit of the Helm chart render subprocess, in MiB.",
+	)
+
+	flag.IntVar(
+		&Options.HelmRenderCPUSeconds,
+		"helm-render-cpu-limit",
+		Options.HelmRenderCPUSeconds,
+		"CPU time limit of the Helm chart render subprocess, in seconds.",
+	)
+
+	flag.BoolVar(
+		&Options.HelmV2Charts,
+		"helm-v2-charts",
+		Options.HelmV2Charts,
+		"Deploy the Helm charts of apiVersion v1, written for Helm 2, with the Helm 3 libraries. "+
+			"Set to false to deploy only the apiVersion v2 charts of Helm 3.",
+	)
+
+	flag.StringVar(
+		&Options.HelmProvenanceKeyring,
+		"helm-provenance-keyring",
+		Options.HelmProvenanceKeyring,
+		"Public keyring verifying the provenance files of the charts of the Helm repos. "+
+			"If set, the charts without a valid provenance file signed by one of its keys are not deployed.",
+	)
+
 	flag.BoolVar(
 		&Options.AgentInstallAll,
 		"agent-install-all",
@@ -22,9 +22,15 @@ import (
 	"k8s.io/klog/v2"
 
 	"open-cluster-management.io/multicloud-operators-subscription/cmd/manager/exec"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
 )
 
 func main() {
+	// the operator binary started to render a Helm chart in a sandbox
+	if utils.IsHelmRenderSandbox() {
+		utils.RunHelmRenderSandbox()
+	}
+
 	exec.ProcessFlags()
 
 	klog.InitFlags(nil)
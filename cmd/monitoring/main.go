@@ -0,0 +1,58 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/metrics"
+)
+
+// main prints the monitoring resources of the subscription metrics, e.g.
+// appsub-monitoring --output rules | kubectl apply -f -
+func main() {
+	output := pflag.String("output", "rules",
+		"The resource to print: rules for the PrometheusRule, dashboard for the grafana dashboard JSON, "+
+			"dashboard-configmap for the dashboard in a ConfigMap loaded by the grafana sidecar.")
+	namespace := pflag.String("namespace", "open-cluster-management", "The namespace of the PrometheusRule and the dashboard ConfigMap.")
+
+	pflag.Parse()
+
+	var (
+		out []byte
+		err error
+	)
+
+	switch *output {
+	case "rules":
+		out, err = metrics.PrometheusRule(*namespace)
+	case "dashboard":
+		out, err = metrics.Dashboard()
+	case "dashboard-configmap":
+		out, err = metrics.DashboardConfigMap(*namespace)
+	default:
+		err = fmt.Errorf("unknown output %v, expected rules, dashboard or dashboard-configmap", *output)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(out))
+}
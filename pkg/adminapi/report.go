@@ -0,0 +1,231 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adminapi
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	kubesynchronizer "open-cluster-management.io/multicloud-operators-subscription/pkg/synchronizer/kubernetes"
+)
+
+// The report formats of the diff of a subscription, for the CI checks
+const (
+	reportJUnit = "junit"
+	reportSARIF = "sarif"
+)
+
+// The report failures of the resources of the render missing from the cluster or changed since, the others are the
+// phases of the resources the sync didn't deploy
+const (
+	failureMissing = "Missing"
+	failureDrifted = "Drifted"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	reportTool   = "multicloud-operators-subscription"
+)
+
+// reportCase is a resource of the last sync, failed if the sync didn't deploy it or if it differs from the render
+type reportCase struct {
+	resource string
+	failure  string
+	message  string
+}
+
+// getReportCases returns the resources of the render and the failures of the sync, in the order of the render
+func getReportCases(render *kubesynchronizer.Render, diffs []kubesynchronizer.ResourceDiff) []*reportCase {
+	cases := []*reportCase{}
+	byResource := map[string]*reportCase{}
+
+	for _, diff := range diffs {
+		c := &reportCase{resource: resourceKey(diff.APIVersion, diff.Kind, diff.Namespace, diff.Name)}
+
+		switch {
+		case diff.Missing:
+			c.failure = failureMissing
+			c.message = "the resource is not on the cluster"
+		case len(diff.Patch) > 0:
+			c.failure = failureDrifted
+			c.message = "the resource differs from the last sync: " + string(diff.Patch)
+		}
+
+		cases = append(cases, c)
+		byResource[c.resource] = c
+	}
+
+	for _, failure := range render.Failures {
+		key := resourceKey(failure.APIVersion, failure.Kind, failure.Namespace, failure.Name)
+
+		c, ok := byResource[key]
+		if !ok {
+			c = &reportCase{resource: key}
+			cases = append(cases, c)
+			byResource[key] = c
+		}
+
+		// the failure of the sync explains the difference
+		c.failure = failure.Phase
+		c.message = failure.Message
+	}
+
+	return cases
+}
+
+func resourceKey(apiVersion, kind, namespace, name string) string {
+	return strings.Join([]string{apiVersion, kind, namespace, name}, "/")
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnitReport writes the report as a JUnit test suite of the subscription, with a test case per resource
+func writeJUnitReport(w io.Writer, appsub types.NamespacedName, render *kubesynchronizer.Render,
+	diffs []kubesynchronizer.ResourceDiff) error {
+	suite := junitTestSuite{Name: appsub.String(), Timestamp: render.Time.UTC().Format(time.RFC3339)}
+
+	for _, c := range getReportCases(render, diffs) {
+		tc := junitTestCase{ClassName: appsub.String(), Name: c.resource}
+
+		if c.failure != "" {
+			tc.Failure = &junitFailure{Type: c.failure, Message: c.message}
+			suite.Failures++
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	suite.Tests = len(suite.Cases)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	return encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}})
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// writeSARIFReport writes the report as a SARIF log with a result per failed resource. The resources the sync didn't
+// deploy are errors, the resources that changed since the sync are warnings.
+func writeSARIFReport(w io.Writer, appsub types.NamespacedName, render *kubesynchronizer.Render,
+	diffs []kubesynchronizer.ResourceDiff) error {
+	run := sarifRun{Tool: sarifTool{Driver: sarifDriver{Name: reportTool, Rules: []sarifRule{}}}, Results: []sarifResult{}}
+	rules := map[string]bool{}
+
+	for _, c := range getReportCases(render, diffs) {
+		if c.failure == "" {
+			continue
+		}
+
+		if !rules[c.failure] {
+			rules[c.failure] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: c.failure})
+		}
+
+		level := "error"
+		if c.failure == failureDrifted {
+			level = "warning"
+		}
+
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  c.failure,
+			Level:   level,
+			Message: sarifMessage{Text: appsub.String() + ": " + c.message},
+			Locations: []sarifLocation{{LogicalLocations: []sarifLogicalLocation{{
+				FullyQualifiedName: c.resource,
+				Kind:               "resource",
+			}}}},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{run}})
+}
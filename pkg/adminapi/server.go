@@ -0,0 +1,449 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adminapi
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	authzv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/controller/mcmhub"
+	kubesynchronizer "open-cluster-management.io/multicloud-operators-subscription/pkg/synchronizer/kubernetes"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+const (
+	subscriptionsPath = "/subscriptions/"
+
+	actionPause   = "pause"
+	actionResume  = "resume"
+	actionSync    = "sync"
+	actionRender  = "render"
+	actionDiff    = "diff"
+	actionPreview = "preview"
+
+	// redactedValue replaces the values of the secrets in the renders, diffs and previews
+	redactedValue = "REDACTED"
+)
+
+// Synchronizer gives the last render of the appsubs and its difference with the cluster
+type Synchronizer interface {
+	GetLastRender(hostSub types.NamespacedName) (*kubesynchronizer.Render, bool)
+	DiffLastRender(hostSub types.NamespacedName) ([]kubesynchronizer.ResourceDiff, error)
+}
+
+// Previewer renders the appsubs of the hub for a managed cluster
+type Previewer interface {
+	PreviewCluster(ctx context.Context, appsub types.NamespacedName, cluster string) (*mcmhub.ClusterPreview, error)
+}
+
+// Server serves the admin API the platform portals drive the subscriptions with:
+//
+//	POST /subscriptions/<namespace>/<name>/pause
+//	POST /subscriptions/<namespace>/<name>/resume
+//	POST /subscriptions/<namespace>/<name>/sync
+//	GET  /subscriptions/<namespace>/<name>/render
+//	GET  /subscriptions/<namespace>/<name>/diff[?format=junit|sarif]
+//	GET  /subscriptions/<namespace>/<name>/preview?cluster=<cluster>
+type Server struct {
+	client       client.Client
+	authClient   kubernetes.Interface
+	synchronizer func() Synchronizer
+	previewer    Previewer
+	address      string
+	tlsKeyFile   string
+	tlsCrtFile   string
+	disableTLS   bool
+}
+
+// Add creates the admin API server and adds it to the manager, the server listens on address. The previewer is set on
+// the hub only, the managed clusters have no appsub to preview.
+func Add(mgr manager.Manager, address, tlsKeyFile, tlsCrtFile string, disableTLS bool, previewer Previewer) error {
+	authClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+
+	return mgr.Add(&Server{
+		client:       mgr.GetClient(),
+		authClient:   authClient,
+		synchronizer: defaultSynchronizer,
+		previewer:    previewer,
+		address:      address,
+		tlsKeyFile:   tlsKeyFile,
+		tlsCrtFile:   tlsCrtFile,
+		disableTLS:   disableTLS,
+	})
+}
+
+// defaultSynchronizer returns the synchronizer applying the subscriptions of the controller, nil until it is created
+func defaultSynchronizer() Synchronizer {
+	if sync := kubesynchronizer.GetDefaultSynchronizer(); sync != nil {
+		return sync
+	}
+
+	return nil
+}
+
+// Start serves the admin API until the context is done, this will be triggered by the manager.
+func (s *Server) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.address,
+		Handler:           s,
+		ReadHeaderTimeout: 30 * time.Second,
+		BaseContext:       func(_ net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		if err := srv.Shutdown(context.TODO()); err != nil {
+			klog.Error("failed to shut down the admin API server, err: ", err)
+		}
+	}()
+
+	klog.Info("starting the admin API server on ", s.address)
+
+	var err error
+
+	if s.disableTLS {
+		err = srv.ListenAndServe()
+	} else {
+		err = utils.ListenAndServeTLS(srv, s.tlsCrtFile, s.tlsKeyFile)
+	}
+
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// NeedLeaderElection serves the renders of every replica
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// ServeHTTP runs the action of the request on the subscription. The bearer token user needs to patch the
+// subscription to pause, resume or sync it, and to get it to fetch its render, diff or preview. The values of the
+// secrets are redacted from the renders, diffs and previews, the user might not be allowed to read them.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, subscriptionsPath), "/")
+	if !strings.HasPrefix(r.URL.Path, subscriptionsPath) || len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "unknown path "+r.URL.Path, http.StatusNotFound)
+
+		return
+	}
+
+	appsub := types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	action := parts[2]
+
+	verb := "get"
+	method := http.MethodGet
+
+	switch action {
+	case actionPause, actionResume, actionSync:
+		verb = "patch"
+		method = http.MethodPost
+	case actionRender, actionDiff, actionPreview:
+	default:
+		http.Error(w, "unknown action "+action, http.StatusNotFound)
+
+		return
+	}
+
+	if r.Method != method {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	if code, err := utils.AuthorizeBearerToken(r, s.authClient, authzv1.ResourceAttributes{
+		Namespace: appsub.Namespace,
+		Name:      appsub.Name,
+		Verb:      verb,
+		Group:     appv1.SchemeGroupVersion.Group,
+		Resource:  "subscriptions",
+	}); err != nil {
+		klog.Infof("admin API %v request of appsub %v denied: %v", action, appsub, err)
+		http.Error(w, err.Error(), code)
+
+		return
+	}
+
+	klog.Infof("admin API %v request of appsub %v", action, appsub)
+
+	switch action {
+	case actionPause, actionResume, actionSync:
+		s.updateSubscription(w, r, appsub, action)
+	case actionRender, actionDiff:
+		s.getRender(w, r, appsub, action)
+	case actionPreview:
+		s.getPreview(w, r, appsub)
+	}
+}
+
+// updateSubscription pauses, resumes or triggers a sync of the subscription through its pause label or its manual
+// refresh time annotation, the way the console does
+func (s *Server) updateSubscription(w http.ResponseWriter, r *http.Request, appsub types.NamespacedName, action string) {
+	sub := &appv1.Subscription{}
+	if err := s.client.Get(r.Context(), appsub, sub); err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	patched := sub.DeepCopy()
+
+	switch action {
+	case actionPause:
+		labels := patched.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+
+		labels[appv1.LabelSubscriptionPause] = "true"
+		patched.SetLabels(labels)
+	case actionResume:
+		labels := patched.GetLabels()
+		delete(labels, appv1.LabelSubscriptionPause)
+		patched.SetLabels(labels)
+	case actionSync:
+		annotations := patched.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+
+		annotations[appv1.AnnotationManualReconcileTime] = time.Now().UTC().Format(time.RFC3339Nano)
+		patched.SetAnnotations(annotations)
+	}
+
+	if err := s.client.Patch(r.Context(), patched, client.MergeFrom(sub)); err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getRender writes the last render of the subscription, or its difference with the cluster, as JSON. The format
+// query parameter of the diff selects a JUnit or SARIF report instead.
+func (s *Server) getRender(w http.ResponseWriter, r *http.Request, appsub types.NamespacedName, action string) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+
+	switch format {
+	case "", "json":
+	case reportJUnit, reportSARIF:
+		if action != actionDiff {
+			http.Error(w, "the "+format+" format is only supported by the diff", http.StatusBadRequest)
+
+			return
+		}
+	default:
+		http.Error(w, "unknown format "+format, http.StatusBadRequest)
+
+		return
+	}
+
+	sync := s.synchronizer()
+	if sync == nil {
+		http.Error(w, "the subscriptions are not applied by this controller", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	render, ok := sync.GetLastRender(appsub)
+	if !ok {
+		http.Error(w, "appsub "+appsub.String()+" has not synced since the controller started", http.StatusNotFound)
+
+		return
+	}
+
+	redacted := *render
+	redacted.Resources = redactSecrets(render.Resources)
+
+	var body interface{} = &redacted
+
+	if action == actionDiff {
+		diffs, err := sync.DiffLastRender(appsub)
+		if err != nil {
+			writeError(w, err)
+
+			return
+		}
+
+		if diffs, err = redactSecretPatches(diffs); err != nil {
+			writeError(w, err)
+
+			return
+		}
+
+		if format == reportJUnit || format == reportSARIF {
+			writeReport(w, appsub, format, &redacted, diffs)
+
+			return
+		}
+
+		body = diffs
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		klog.Errorf("failed to write the %v of appsub %v, err: %v", action, appsub, err)
+	}
+}
+
+// getPreview writes what the cluster of the cluster query parameter gets from the subscription as JSON, its resources
+// rendered from the channel after the package conditions, the cluster variables and the cluster overrides
+func (s *Server) getPreview(w http.ResponseWriter, r *http.Request, appsub types.NamespacedName) {
+	cluster := r.URL.Query().Get("cluster")
+	if cluster == "" {
+		http.Error(w, "the cluster query parameter is required", http.StatusBadRequest)
+
+		return
+	}
+
+	if s.previewer == nil {
+		http.Error(w, "the subscriptions are previewed on the hub only", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	preview, err := s.previewer.PreviewCluster(r.Context(), appsub, cluster)
+	if err != nil {
+		writeError(w, err)
+
+		return
+	}
+
+	preview.Resources = redactSecrets(preview.Resources)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(preview); err != nil {
+		klog.Errorf("failed to write the preview of appsub %v on cluster %v, err: %v", appsub, cluster, err)
+	}
+}
+
+// writeReport writes the JUnit or SARIF report of the last render and diff of the subscription
+func writeReport(w http.ResponseWriter, appsub types.NamespacedName, format string, render *kubesynchronizer.Render,
+	diffs []kubesynchronizer.ResourceDiff) {
+	var err error
+
+	if format == reportJUnit {
+		w.Header().Set("Content-Type", "application/xml")
+
+		err = writeJUnitReport(w, appsub, render, diffs)
+	} else {
+		w.Header().Set("Content-Type", "application/sarif+json")
+
+		err = writeSARIFReport(w, appsub, render, diffs)
+	}
+
+	if err != nil {
+		klog.Errorf("failed to write the %v report of appsub %v, err: %v", format, appsub, err)
+	}
+}
+
+// writeError fails the request with the status code of the API error
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+
+	if status, ok := err.(errors.APIStatus); ok {
+		code = int(status.Status().Code)
+	}
+
+	http.Error(w, err.Error(), code)
+}
+
+// isSecret returns true if the API version and kind are the ones of a secret
+func isSecret(apiVersion, kind string) bool {
+	return apiVersion == "v1" && kind == "Secret"
+}
+
+// redactSecretValues replaces the values of the data and stringData of the secret, its keys are kept
+func redactSecretValues(secret map[string]interface{}) {
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := secret[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for key, value := range values {
+			// the patch removes the key
+			if value != nil {
+				values[key] = redactedValue
+			}
+		}
+	}
+}
+
+// redactSecrets returns the resources with copies of the secrets without their values, the resources are not changed
+func redactSecrets(resources []*unstructured.Unstructured) []*unstructured.Unstructured {
+	redacted := make([]*unstructured.Unstructured, 0, len(resources))
+
+	for _, resource := range resources {
+		if resource != nil && isSecret(resource.GetAPIVersion(), resource.GetKind()) {
+			resource = resource.DeepCopy()
+			redactSecretValues(resource.Object)
+		}
+
+		redacted = append(redacted, resource)
+	}
+
+	return redacted
+}
+
+// redactSecretPatches returns the diffs with the values of the secrets redacted from their patches
+func redactSecretPatches(diffs []kubesynchronizer.ResourceDiff) ([]kubesynchronizer.ResourceDiff, error) {
+	redacted := make([]kubesynchronizer.ResourceDiff, 0, len(diffs))
+
+	for _, diff := range diffs {
+		if len(diff.Patch) > 0 && isSecret(diff.APIVersion, diff.Kind) {
+			patch := map[string]interface{}{}
+			if err := json.Unmarshal(diff.Patch, &patch); err != nil {
+				return nil, err
+			}
+
+			redactSecretValues(patch)
+
+			patchb, err := json.Marshal(patch)
+			if err != nil {
+				return nil, err
+			}
+
+			diff.Patch = patchb
+		}
+
+		redacted = append(redacted, diff)
+	}
+
+	return redacted, nil
+}
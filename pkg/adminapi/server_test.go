@@ -0,0 +1,266 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adminapi
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/onsi/gomega"
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/controller/mcmhub"
+	kubesynchronizer "open-cluster-management.io/multicloud-operators-subscription/pkg/synchronizer/kubernetes"
+)
+
+// fakeSynchronizer has a render of ns1/sub only
+type fakeSynchronizer struct{}
+
+func (fakeSynchronizer) GetLastRender(hostSub types.NamespacedName) (*kubesynchronizer.Render, bool) {
+	if hostSub.String() != "ns1/sub" {
+		return nil, false
+	}
+
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetNamespace("ns1")
+	cm.SetName("settings")
+
+	return &kubesynchronizer.Render{Time: metav1.Now(), Resources: []*unstructured.Unstructured{cm, newSecret()},
+		Failures: []kubesynchronizer.ResourceFailure{{APIVersion: "v1", Kind: "Secret", Namespace: "ns1", Name: "tls",
+			Phase: "Failed", Message: "Secret is not in the allow list"}}}, true
+}
+
+func (fakeSynchronizer) DiffLastRender(hostSub types.NamespacedName) ([]kubesynchronizer.ResourceDiff, error) {
+	if hostSub.String() != "ns1/sub" {
+		return nil, fmt.Errorf("appsub %v has not synced yet", hostSub)
+	}
+
+	return []kubesynchronizer.ResourceDiff{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns1", Name: "settings",
+		Patch: json.RawMessage(`{"data":{"mode":"fast"}}`)}, {APIVersion: "v1", Kind: "Secret", Namespace: "ns1",
+		Name: "db", Patch: json.RawMessage(`{"data":{"old":null,"password":"czNjcjN0"}}`)}}, nil
+}
+
+// newSecret returns a secret decrypted by the sync, whose values must not be served
+func newSecret() *unstructured.Unstructured {
+	secret := &unstructured.Unstructured{Object: map[string]interface{}{
+		"data":       map[string]interface{}{"password": "czNjcjN0"},
+		"stringData": map[string]interface{}{"user": "admin"},
+	}}
+	secret.SetAPIVersion("v1")
+	secret.SetKind("Secret")
+	secret.SetNamespace("ns1")
+	secret.SetName("db")
+
+	return secret
+}
+
+// fakePreviewer previews ns1/sub for cluster1 only
+type fakePreviewer struct{}
+
+func (fakePreviewer) PreviewCluster(ctx context.Context, appsub types.NamespacedName,
+	cluster string) (*mcmhub.ClusterPreview, error) {
+	if appsub.String() != "ns1/sub" || cluster != "cluster1" {
+		return nil, errors.NewNotFound(schema.GroupResource{Group: "cluster.open-cluster-management.io",
+			Resource: "managedclusters"}, cluster)
+	}
+
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetNamespace("ns1")
+	cm.SetName("settings")
+
+	return &mcmhub.ClusterPreview{Cluster: cluster, Placed: true,
+		Resources: []*unstructured.Unstructured{cm, newSecret()}}, nil
+}
+
+// newFakeAuthClient authenticates the token "valid" as a user allowed to get and patch the subscriptions of ns1,
+// and the token "viewer" as a user allowed to get them only
+func newFakeAuthClient() *fake.Clientset {
+	authClient := fake.NewSimpleClientset()
+
+	authClient.PrependReactor("create", "tokenreviews",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			review := action.(clienttesting.CreateAction).GetObject().(*authnv1.TokenReview)
+			review.Status.Authenticated = review.Spec.Token == "valid" || review.Spec.Token == "viewer"
+			review.Status.User.Username = review.Spec.Token
+
+			return true, review, nil
+		})
+
+	authClient.PrependReactor("create", "subjectaccessreviews",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			sar := action.(clienttesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+			attrs := sar.Spec.ResourceAttributes
+			sar.Status.Allowed = attrs.Namespace == "ns1" && attrs.Resource == "subscriptions" &&
+				(attrs.Verb == "get" || sar.Spec.User == "valid")
+
+			return true, sar, nil
+		})
+
+	return authClient
+}
+
+func TestAdminAPI(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(appv1.SchemeBuilder.AddToScheme(scheme)).To(gomega.Succeed())
+
+	sub := &appv1.Subscription{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "sub"}}
+	clt := crfake.NewClientBuilder().WithScheme(scheme).WithObjects(sub).Build()
+
+	s := &Server{
+		client:       clt,
+		authClient:   newFakeAuthClient(),
+		synchronizer: func() Synchronizer { return fakeSynchronizer{} },
+		previewer:    fakePreviewer{},
+	}
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	do := func(method, path, token string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		return resp
+	}
+
+	expectStatus := func(method, path, token string, code int) {
+		resp := do(method, path, token)
+		resp.Body.Close()
+		g.Expect(resp.StatusCode).To(gomega.Equal(code), method+" "+path)
+	}
+
+	getSub := func() *appv1.Subscription {
+		current := &appv1.Subscription{}
+		g.Expect(clt.Get(context.TODO(), types.NamespacedName{Namespace: "ns1", Name: "sub"}, current)).To(gomega.Succeed())
+
+		return current
+	}
+
+	expectStatus(http.MethodPost, "/subscriptions/ns1/sub/pause", "", http.StatusUnauthorized)
+	expectStatus(http.MethodPost, "/subscriptions/ns1/sub/pause", "viewer", http.StatusForbidden)
+	expectStatus(http.MethodPost, "/subscriptions/ns2/sub/pause", "valid", http.StatusForbidden)
+	expectStatus(http.MethodGet, "/subscriptions/ns1/sub/pause", "valid", http.StatusMethodNotAllowed)
+	expectStatus(http.MethodPost, "/subscriptions/ns1/sub/rollback", "valid", http.StatusNotFound)
+	expectStatus(http.MethodPost, "/subscriptions/ns1/missing/pause", "valid", http.StatusNotFound)
+
+	expectStatus(http.MethodPost, "/subscriptions/ns1/sub/pause", "valid", http.StatusNoContent)
+	g.Expect(getSub().GetLabels()).To(gomega.HaveKeyWithValue(appv1.LabelSubscriptionPause, "true"))
+
+	expectStatus(http.MethodPost, "/subscriptions/ns1/sub/resume", "valid", http.StatusNoContent)
+	g.Expect(getSub().GetLabels()).NotTo(gomega.HaveKey(appv1.LabelSubscriptionPause))
+
+	expectStatus(http.MethodPost, "/subscriptions/ns1/sub/sync", "valid", http.StatusNoContent)
+	g.Expect(getSub().GetAnnotations()).To(gomega.HaveKey(appv1.AnnotationManualReconcileTime))
+
+	resp := do(http.MethodGet, "/subscriptions/ns1/sub/render", "viewer")
+	g.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK))
+
+	render := &kubesynchronizer.Render{}
+	g.Expect(json.NewDecoder(resp.Body).Decode(render)).To(gomega.Succeed())
+	resp.Body.Close()
+	g.Expect(render.Resources).To(gomega.HaveLen(2))
+	g.Expect(render.Resources[0].GetName()).To(gomega.Equal("settings"))
+	g.Expect(render.Resources[1].Object["data"]).To(gomega.Equal(map[string]interface{}{"password": redactedValue}))
+	g.Expect(render.Resources[1].Object["stringData"]).To(gomega.Equal(map[string]interface{}{"user": redactedValue}))
+
+	resp = do(http.MethodGet, "/subscriptions/ns1/sub/diff", "viewer")
+	g.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK))
+
+	diffs := []kubesynchronizer.ResourceDiff{}
+	g.Expect(json.NewDecoder(resp.Body).Decode(&diffs)).To(gomega.Succeed())
+	resp.Body.Close()
+	g.Expect(diffs).To(gomega.HaveLen(2))
+	g.Expect(string(diffs[0].Patch)).To(gomega.Equal(`{"data":{"mode":"fast"}}`))
+	g.Expect(string(diffs[1].Patch)).To(gomega.Equal(`{"data":{"old":null,"password":"REDACTED"}}`))
+
+	expectStatus(http.MethodGet, "/subscriptions/ns1/other/render", "viewer", http.StatusNotFound)
+	expectStatus(http.MethodGet, "/subscriptions/ns1/sub/diff?format=html", "viewer", http.StatusBadRequest)
+	expectStatus(http.MethodGet, "/subscriptions/ns1/sub/render?format=junit", "viewer", http.StatusBadRequest)
+
+	resp = do(http.MethodGet, "/subscriptions/ns1/sub/preview?cluster=cluster1", "viewer")
+	g.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK))
+
+	preview := &mcmhub.ClusterPreview{}
+	g.Expect(json.NewDecoder(resp.Body).Decode(preview)).To(gomega.Succeed())
+	resp.Body.Close()
+	g.Expect(preview.Placed).To(gomega.BeTrue())
+	g.Expect(preview.Resources).To(gomega.HaveLen(2))
+	g.Expect(preview.Resources[0].GetName()).To(gomega.Equal("settings"))
+	g.Expect(preview.Resources[1].Object["data"]).To(gomega.Equal(map[string]interface{}{"password": redactedValue}))
+
+	expectStatus(http.MethodGet, "/subscriptions/ns1/sub/preview", "viewer", http.StatusBadRequest)
+	expectStatus(http.MethodGet, "/subscriptions/ns1/sub/preview?cluster=cluster2", "viewer", http.StatusNotFound)
+	expectStatus(http.MethodGet, "/subscriptions/ns2/sub/preview?cluster=cluster1", "viewer", http.StatusForbidden)
+
+	s.previewer = nil
+	expectStatus(http.MethodGet, "/subscriptions/ns1/sub/preview?cluster=cluster1", "viewer", http.StatusServiceUnavailable)
+
+	resp = do(http.MethodGet, "/subscriptions/ns1/sub/diff?format=junit", "viewer")
+	g.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK))
+
+	suites := &junitTestSuites{}
+	g.Expect(xml.NewDecoder(resp.Body).Decode(suites)).To(gomega.Succeed())
+	resp.Body.Close()
+	g.Expect(suites.Suites).To(gomega.HaveLen(1))
+	g.Expect(suites.Suites[0].Tests).To(gomega.Equal(3))
+	g.Expect(suites.Suites[0].Failures).To(gomega.Equal(3))
+	g.Expect(suites.Suites[0].Cases[0].Name).To(gomega.Equal("v1/ConfigMap/ns1/settings"))
+	g.Expect(suites.Suites[0].Cases[0].Failure.Type).To(gomega.Equal(failureDrifted))
+	g.Expect(suites.Suites[0].Cases[1].Failure.Message).NotTo(gomega.ContainSubstring("czNjcjN0"))
+	g.Expect(suites.Suites[0].Cases[2].Failure.Type).To(gomega.Equal("Failed"))
+
+	resp = do(http.MethodGet, "/subscriptions/ns1/sub/diff?format=sarif", "viewer")
+	g.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK))
+	g.Expect(resp.Header.Get("Content-Type")).To(gomega.Equal("application/sarif+json"))
+
+	log := &sarifLog{}
+	g.Expect(json.NewDecoder(resp.Body).Decode(log)).To(gomega.Succeed())
+	resp.Body.Close()
+	g.Expect(log.Version).To(gomega.Equal(sarifVersion))
+	g.Expect(log.Runs[0].Results).To(gomega.HaveLen(3))
+	g.Expect(log.Runs[0].Results[0].Level).To(gomega.Equal("warning"))
+	g.Expect(log.Runs[0].Results[2].Level).To(gomega.Equal("error"))
+	g.Expect(log.Runs[0].Results[2].Locations[0].LogicalLocations[0].FullyQualifiedName).To(
+		gomega.Equal("v1/Secret/ns1/tls"))
+}
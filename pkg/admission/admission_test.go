@@ -0,0 +1,347 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admission
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	plrv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/placementrule/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+func newChannel(name string, tp chnv1.ChannelType) *chnv1.Channel {
+	return &chnv1.Channel{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "chn-ns"},
+		Spec:       chnv1.ChannelSpec{Type: tp, Pathname: "https://charts.example.com/" + name},
+	}
+}
+
+func newSubscription(retarget *appv1.ChannelRetarget) *appv1.Subscription {
+	return &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{Name: "sub", Namespace: "default"},
+		Spec: appv1.SubscriptionSpec{
+			Channel:   "chn-ns/stage",
+			Placement: &plrv1alpha1.Placement{PlacementRef: &corev1.ObjectReference{Kind: "PlacementRule", Name: "prod-clusters"}},
+			Retarget:  retarget,
+		},
+	}
+}
+
+func newServer(g *gomega.WithT, objs ...client.Object) *Server {
+	s := runtime.NewScheme()
+	g.Expect(chnv1.AddToScheme(s)).To(gomega.Succeed())
+	g.Expect(appv1.SchemeBuilder.AddToScheme(s)).To(gomega.Succeed())
+	g.Expect(corev1.AddToScheme(s)).To(gomega.Succeed())
+
+	clt := fake.NewClientBuilder().WithScheme(s).WithObjects(
+		newChannel("stage", chnv1.ChannelTypeHelmRepo),
+		newChannel("prod", chnv1.ChannelTypeHelmRepo),
+		newChannel("git", chnv1.ChannelTypeGit),
+	).WithObjects(objs...).Build()
+
+	return &Server{client: clt}
+}
+
+func postReview(g *gomega.WithT, srv *Server, op admissionv1.Operation, sub, old *appv1.Subscription) *admissionv1.AdmissionResponse {
+	req := &admissionv1.AdmissionRequest{UID: types.UID("uid"), Operation: op}
+
+	raw, err := json.Marshal(sub)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	req.Object.Raw = raw
+
+	if old != nil {
+		raw, err = json.Marshal(old)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		req.OldObject.Raw = raw
+	}
+
+	body, err := json.Marshal(&admissionv1.AdmissionReview{Request: req})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, validateSubscriptionPath, bytes.NewReader(body)))
+	g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+
+	review := &admissionv1.AdmissionReview{}
+	g.Expect(json.Unmarshal(w.Body.Bytes(), review)).To(gomega.Succeed())
+	g.Expect(review.Response.UID).To(gomega.Equal(types.UID("uid")))
+
+	return review.Response
+}
+
+func TestValidateRetarget(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	srv := newServer(g)
+
+	resp := postReview(g, srv, admissionv1.Create, newSubscription(nil), nil)
+	g.Expect(resp.Allowed).To(gomega.BeTrue())
+
+	resp = postReview(g, srv, admissionv1.Update, newSubscription(&appv1.ChannelRetarget{Channel: "chn-ns/prod"}), newSubscription(nil))
+	g.Expect(resp.Allowed).To(gomega.BeTrue())
+	g.Expect(resp.Warnings).To(gomega.BeEmpty())
+
+	resp = postReview(g, srv, admissionv1.Update,
+		newSubscription(&appv1.ChannelRetarget{Channel: "chn-ns/prod", AllowPackageDeletion: true}), newSubscription(nil))
+	g.Expect(resp.Allowed).To(gomega.BeTrue())
+	g.Expect(resp.Warnings).To(gomega.HaveLen(1))
+
+	for retarget, msg := range map[string]string{
+		"prod":          "is not a namespace/name channel",
+		"chn-ns/qa":     "channel chn-ns/qa not found",
+		"chn-ns/git":    "is of type git, not helmrepo",
+		"chn-ns/prod/x": "is not a namespace/name channel",
+	} {
+		resp = postReview(g, srv, admissionv1.Create, newSubscription(&appv1.ChannelRetarget{Channel: retarget}), nil)
+		g.Expect(resp.Allowed).To(gomega.BeFalse())
+		g.Expect(resp.Result.Message).To(gomega.ContainSubstring(msg))
+	}
+
+	// the subscriptions placed locally are not retargeted by the hub
+	local := true
+	sub := newSubscription(&appv1.ChannelRetarget{Channel: "chn-ns/prod"})
+	sub.Spec.Placement = &plrv1alpha1.Placement{Local: &local}
+
+	resp = postReview(g, srv, admissionv1.Create, sub, nil)
+	g.Expect(resp.Allowed).To(gomega.BeFalse())
+
+	// the channel can't be changed directly while a retarget is pending
+	sub = newSubscription(&appv1.ChannelRetarget{Channel: "chn-ns/prod"})
+	sub.Spec.Channel = "chn-ns/git"
+
+	resp = postReview(g, srv, admissionv1.Update, sub, newSubscription(&appv1.ChannelRetarget{Channel: "chn-ns/prod"}))
+	g.Expect(resp.Allowed).To(gomega.BeFalse())
+	g.Expect(resp.Result.Message).To(gomega.ContainSubstring("retarget to channel chn-ns/prod is pending"))
+
+	// the hub switches the channel and clears the retarget
+	sub.Spec.Channel = "chn-ns/prod"
+	sub.Spec.Retarget = nil
+
+	resp = postReview(g, srv, admissionv1.Update, sub, newSubscription(&appv1.ChannelRetarget{Channel: "chn-ns/prod"}))
+	g.Expect(resp.Allowed).To(gomega.BeTrue())
+}
+
+func TestValidateChannelSource(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(utils.SetChannelSourceAllowList("charts.example.com")).To(gomega.Succeed())
+
+	defer func() {
+		g.Expect(utils.SetChannelSourceAllowList("")).To(gomega.Succeed())
+	}()
+
+	srv := newServer(g)
+
+	for name, allowed := range map[string]bool{"stable": false, "charts": true} {
+		chn := newChannel(name, chnv1.ChannelTypeHelmRepo)
+		chn.Spec.Pathname = "https://" + name + ".example.com"
+
+		raw, err := json.Marshal(chn)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		req := &admissionv1.AdmissionRequest{UID: types.UID("uid"), Operation: admissionv1.Create}
+		req.Object.Raw = raw
+
+		body, err := json.Marshal(&admissionv1.AdmissionReview{Request: req})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, validateChannelPath, bytes.NewReader(body)))
+		g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+
+		review := &admissionv1.AdmissionReview{}
+		g.Expect(json.Unmarshal(w.Body.Bytes(), review)).To(gomega.Succeed())
+		g.Expect(review.Response.Allowed).To(gomega.Equal(allowed))
+	}
+}
+
+func postChannelReview(g *gomega.WithT, srv *Server, op admissionv1.Operation, chn, old *chnv1.Channel) *admissionv1.AdmissionResponse {
+	req := &admissionv1.AdmissionRequest{UID: types.UID("uid"), Operation: op}
+
+	raw, err := json.Marshal(chn)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	req.Object.Raw = raw
+
+	if old != nil {
+		raw, err = json.Marshal(old)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		req.OldObject.Raw = raw
+	}
+
+	body, err := json.Marshal(&admissionv1.AdmissionReview{Request: req})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, validateChannelPath, bytes.NewReader(body)))
+	g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+
+	review := &admissionv1.AdmissionReview{}
+	g.Expect(json.Unmarshal(w.Body.Bytes(), review)).To(gomega.Succeed())
+
+	return review.Response
+}
+
+func TestNamespaceQuota(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(SetNamespaceQuota(2, 0, "high")).To(gomega.Succeed())
+	g.Expect(SetNamespaceQuota(0, 0, "hourly")).NotTo(gomega.Succeed())
+
+	defer func() {
+		g.Expect(SetNamespaceQuota(0, 0, "")).To(gomega.Succeed())
+	}()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Annotations: map[string]string{
+		appv1.AnnotationMaxChannels:      "1",
+		appv1.AnnotationMaxReconcileRate: "Medium",
+	}}}
+
+	existing := newSubscription(nil)
+	existing.Name = "existing"
+
+	// created by the hub for its local cluster, not counted
+	hosted := newSubscription(nil)
+	hosted.Name = "existing-local"
+	hosted.Spec.Channel = "chn-ns/git"
+	hosted.SetAnnotations(map[string]string{appv1.AnnotationHosting: "default/existing"})
+
+	srv := newServer(g, ns, existing, hosted)
+
+	sub := newSubscription(nil)
+
+	resp := postReview(g, srv, admissionv1.Create, sub, nil)
+	g.Expect(resp.Allowed).To(gomega.BeTrue())
+	g.Expect(srv.client.Create(context.TODO(), sub)).To(gomega.Succeed())
+
+	sub = newSubscription(nil)
+	sub.Name = "third"
+
+	resp = postReview(g, srv, admissionv1.Create, sub, nil)
+	g.Expect(resp.Allowed).To(gomega.BeFalse())
+	g.Expect(resp.Result.Message).To(gomega.ContainSubstring("limited to 2 subscriptions"))
+
+	// a second channel
+	sub = newSubscription(nil)
+	sub.Spec.Channel = "chn-ns/prod"
+
+	resp = postReview(g, srv, admissionv1.Update, sub, newSubscription(nil))
+	g.Expect(resp.Allowed).To(gomega.BeFalse())
+	g.Expect(resp.Result.Message).To(gomega.ContainSubstring("limited to 1 channels"))
+
+	// the updates keeping the channels are allowed over a lowered quota
+	sub = newSubscription(nil)
+	sub.SetLabels(map[string]string{"app": "nginx"})
+
+	resp = postReview(g, srv, admissionv1.Update, sub, newSubscription(nil))
+	g.Expect(resp.Allowed).To(gomega.BeTrue())
+
+	// the reconcile rate of the channels of the namespace
+	chn := newChannel("fast", chnv1.ChannelTypeHelmRepo)
+	chn.Namespace = "default"
+	chn.SetAnnotations(map[string]string{appv1.AnnotationResourceReconcileLevel: "high"})
+
+	resp = postChannelReview(g, srv, admissionv1.Create, chn, nil)
+	g.Expect(resp.Allowed).To(gomega.BeFalse())
+	g.Expect(resp.Result.Message).To(gomega.ContainSubstring("the highest rate allowed is medium"))
+
+	chn.SetAnnotations(map[string]string{appv1.AnnotationResourceReconcileLevel: "low"})
+
+	resp = postChannelReview(g, srv, admissionv1.Create, chn, nil)
+	g.Expect(resp.Allowed).To(gomega.BeTrue())
+
+	// the channels of another namespace subscribed in the namespace
+	fast := newChannel("stage", chnv1.ChannelTypeHelmRepo)
+	fast.Namespace = "chn-ns"
+	g.Expect(srv.client.Get(context.TODO(), client.ObjectKeyFromObject(fast), fast)).To(gomega.Succeed())
+	fast.SetAnnotations(map[string]string{appv1.AnnotationResourceReconcileLevel: "high"})
+	g.Expect(srv.client.Update(context.TODO(), fast)).To(gomega.Succeed())
+
+	g.Expect(srv.client.Delete(context.TODO(), sub)).To(gomega.Succeed())
+
+	resp = postReview(g, srv, admissionv1.Create, newSubscription(nil), nil)
+	g.Expect(resp.Allowed).To(gomega.BeFalse())
+	g.Expect(resp.Result.Message).To(gomega.ContainSubstring("channel chn-ns/stage is reconciled at the high rate"))
+
+	// an invalid quota annotation denies the subscriptions
+	ns.Annotations[appv1.AnnotationMaxSubscriptions] = "ten"
+	g.Expect(srv.client.Update(context.TODO(), ns)).To(gomega.Succeed())
+
+	resp = postReview(g, srv, admissionv1.Create, newSubscription(nil), nil)
+	g.Expect(resp.Allowed).To(gomega.BeFalse())
+	g.Expect(resp.Result.Message).To(gomega.ContainSubstring("invalid " + appv1.AnnotationMaxSubscriptions))
+}
+
+func TestMutateSubscriptionSet(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	srv := newServer(g)
+
+	// the identity set by the author is replaced with the one of the request
+	set := &appv1.SubscriptionSet{ObjectMeta: metav1.ObjectMeta{Name: "guestbook", Namespace: "apps", Annotations: map[string]string{
+		appv1.AnnotationUserIdentity: base64.StdEncoding.EncodeToString([]byte("kube:admin")),
+	}}}
+
+	raw, err := json.Marshal(set)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	req := &admissionv1.AdmissionRequest{UID: types.UID("uid"), Operation: admissionv1.Create}
+	req.Object.Raw = raw
+	req.UserInfo.Username = "dev"
+	req.UserInfo.Groups = []string{"devs", "system:authenticated"}
+
+	body, err := json.Marshal(&admissionv1.AdmissionReview{Request: req})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, mutateSubscriptionSetPath, bytes.NewReader(body)))
+	g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+
+	review := &admissionv1.AdmissionReview{}
+	g.Expect(json.Unmarshal(w.Body.Bytes(), review)).To(gomega.Succeed())
+	g.Expect(review.Response.Allowed).To(gomega.BeTrue())
+
+	patch := []struct {
+		Op    string            `json:"op"`
+		Path  string            `json:"path"`
+		Value map[string]string `json:"value"`
+	}{}
+	g.Expect(json.Unmarshal(review.Response.Patch, &patch)).To(gomega.Succeed())
+	g.Expect(patch).To(gomega.HaveLen(1))
+	g.Expect(patch[0].Path).To(gomega.Equal("/metadata/annotations"))
+	g.Expect(patch[0].Value[appv1.AnnotationUserIdentity]).To(gomega.Equal(base64.StdEncoding.EncodeToString([]byte("dev"))))
+	g.Expect(patch[0].Value[appv1.AnnotationUserGroup]).To(gomega.Equal(
+		base64.StdEncoding.EncodeToString([]byte("devs,system:authenticated"))))
+}
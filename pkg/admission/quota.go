@@ -0,0 +1,304 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// namespaceQuota limits the subscriptions of a namespace, the zero values are unlimited
+type namespaceQuota struct {
+	maxSubscriptions int
+	maxChannels      int
+	maxReconcileRate string
+}
+
+// reconcileRates orders the reconcile rates of the channels
+var reconcileRates = map[string]int{"off": 0, "low": 1, "medium": 2, "high": 3}
+
+// defaultReconcileRate is the reconcile rate of the channels without reconcile-rate annotation
+const defaultReconcileRate = "medium"
+
+// defaultQuota is the quota of the namespaces without quota annotations
+var defaultQuota namespaceQuota
+
+// SetNamespaceQuota sets the quota of the namespaces without their own: the maximum number of subscriptions, of
+// channels referenced by the subscriptions, and the highest reconcile rate of their channels. 0 and an empty rate are
+// unlimited.
+func SetNamespaceQuota(maxSubscriptions, maxChannels int, maxReconcileRate string) error {
+	quota := namespaceQuota{
+		maxSubscriptions: maxSubscriptions,
+		maxChannels:      maxChannels,
+		maxReconcileRate: strings.ToLower(strings.TrimSpace(maxReconcileRate)),
+	}
+
+	if err := quota.validate(); err != nil {
+		return err
+	}
+
+	if quota != (namespaceQuota{}) {
+		klog.Infof("Namespace quota: %d subscriptions, %d channels, %q reconcile rate", quota.maxSubscriptions,
+			quota.maxChannels, quota.maxReconcileRate)
+	}
+
+	defaultQuota = quota
+
+	return nil
+}
+
+func (q namespaceQuota) validate() error {
+	if q.maxSubscriptions < 0 || q.maxChannels < 0 {
+		return fmt.Errorf("invalid namespace quota, the maximum numbers of subscriptions and channels can't be negative")
+	}
+
+	if _, ok := reconcileRates[q.maxReconcileRate]; q.maxReconcileRate != "" && !ok {
+		return fmt.Errorf("invalid maximum reconcile rate %q, expecting off, low, medium or high", q.maxReconcileRate)
+	}
+
+	return nil
+}
+
+// getNamespaceQuota returns the quota of the namespace, its quota annotations override the default quota. The
+// namespace annotations are set by the hub administrators, unlike the subscriptions and channels of the tenants.
+func getNamespaceQuota(ctx context.Context, clt client.Client, namespace string) (namespaceQuota, error) {
+	quota := defaultQuota
+
+	ns := &corev1.Namespace{}
+	if err := clt.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		if errors.IsNotFound(err) {
+			return quota, nil
+		}
+
+		return quota, fmt.Errorf("failed to get namespace %v, err: %w", namespace, err)
+	}
+
+	annotations := ns.GetAnnotations()
+
+	var err error
+
+	if quota.maxSubscriptions, err = parseQuotaAnnotation(annotations, appv1.AnnotationMaxSubscriptions,
+		quota.maxSubscriptions); err != nil {
+		return quota, fmt.Errorf("namespace %v: %w", namespace, err)
+	}
+
+	if quota.maxChannels, err = parseQuotaAnnotation(annotations, appv1.AnnotationMaxChannels, quota.maxChannels); err != nil {
+		return quota, fmt.Errorf("namespace %v: %w", namespace, err)
+	}
+
+	if rate, ok := annotations[appv1.AnnotationMaxReconcileRate]; ok {
+		quota.maxReconcileRate = strings.ToLower(strings.TrimSpace(rate))
+	}
+
+	if err := quota.validate(); err != nil {
+		return quota, fmt.Errorf("namespace %v: %w", namespace, err)
+	}
+
+	return quota, nil
+}
+
+func parseQuotaAnnotation(annotations map[string]string, key string, value int) (int, error) {
+	annotation, ok := annotations[key]
+	if !ok {
+		return value, nil
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(annotation))
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid %v annotation %q, expecting a number", key, annotation)
+	}
+
+	return value, nil
+}
+
+// validateSubscriptionQuota checks the subscription against the quota of its namespace. The number of subscriptions
+// is checked on creation, the channels on creation and on their change, so the subscriptions of a namespace over a
+// lowered quota can still be updated. The subscriptions created by the hub for its local cluster are not counted.
+func validateSubscriptionQuota(ctx context.Context, clt client.Client, sub, old *appv1.Subscription) error {
+	if isHubCreated(sub) {
+		return nil
+	}
+
+	quota, err := getNamespaceQuota(ctx, clt, sub.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if quota == (namespaceQuota{}) {
+		return nil
+	}
+
+	channelsChanged := old == nil || old.Spec.Channel != sub.Spec.Channel || old.Spec.SecondaryChannel != sub.Spec.SecondaryChannel
+
+	if (quota.maxSubscriptions > 0 && old == nil) || (quota.maxChannels > 0 && channelsChanged) {
+		if err := validateSubscriptionCount(ctx, clt, sub, old, quota); err != nil {
+			return err
+		}
+	}
+
+	if quota.maxReconcileRate == "" || !channelsChanged {
+		return nil
+	}
+
+	for _, channel := range subscriptionChannels(sub) {
+		key, err := parseChannelKey(channel)
+		if err != nil {
+			continue
+		}
+
+		// the subscriptions created with a missing channel are reported by the hub reconcile
+		chn := &chnv1.Channel{}
+		if err := clt.Get(ctx, key, chn); err != nil {
+			continue
+		}
+
+		if err := validateReconcileRate(chn, quota.maxReconcileRate); err != nil {
+			return fmt.Errorf("namespace %v: %w", sub.Namespace, err)
+		}
+	}
+
+	return nil
+}
+
+// validateSubscriptionCount checks the number of subscriptions of the namespace on creation, and the number of
+// channels they reference if the subscription adds one
+func validateSubscriptionCount(ctx context.Context, clt client.Client, sub, old *appv1.Subscription, quota namespaceQuota) error {
+	subs := &appv1.SubscriptionList{}
+	if err := clt.List(ctx, subs, client.InNamespace(sub.Namespace)); err != nil {
+		return fmt.Errorf("failed to list the subscriptions of namespace %v, err: %w", sub.Namespace, err)
+	}
+
+	count := 1
+	channels := map[string]bool{}
+
+	for i := range subs.Items {
+		other := &subs.Items[i]
+
+		if other.Name == sub.Name || isHubCreated(other) {
+			continue
+		}
+
+		count++
+
+		for _, channel := range subscriptionChannels(other) {
+			channels[channel] = true
+		}
+	}
+
+	if old == nil && quota.maxSubscriptions > 0 && count > quota.maxSubscriptions {
+		return fmt.Errorf("namespace %v is limited to %d subscriptions", sub.Namespace, quota.maxSubscriptions)
+	}
+
+	if quota.maxChannels == 0 {
+		return nil
+	}
+
+	oldChannels := map[string]bool{}
+
+	if old != nil {
+		for _, channel := range subscriptionChannels(old) {
+			oldChannels[channel] = true
+		}
+	}
+
+	added := false
+
+	for _, channel := range subscriptionChannels(sub) {
+		if !channels[channel] && !oldChannels[channel] {
+			added = true
+		}
+
+		channels[channel] = true
+	}
+
+	if added && len(channels) > quota.maxChannels {
+		return fmt.Errorf("the subscriptions of namespace %v are limited to %d channels", sub.Namespace, quota.maxChannels)
+	}
+
+	return nil
+}
+
+// validateChannelQuota checks the reconcile rate of the channel against the quota of its namespace, on creation and
+// on the change of its rate
+func validateChannelQuota(ctx context.Context, clt client.Client, chn, old *chnv1.Channel) error {
+	if old != nil && getReconcileRate(old) == getReconcileRate(chn) {
+		return nil
+	}
+
+	quota, err := getNamespaceQuota(ctx, clt, chn.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if quota.maxReconcileRate == "" {
+		return nil
+	}
+
+	if err := validateReconcileRate(chn, quota.maxReconcileRate); err != nil {
+		return fmt.Errorf("namespace %v: %w", chn.Namespace, err)
+	}
+
+	return nil
+}
+
+func validateReconcileRate(chn *chnv1.Channel, maxRate string) error {
+	rate := getReconcileRate(chn)
+
+	if reconcileRates[rate] > reconcileRates[maxRate] {
+		return fmt.Errorf("channel %v/%v is reconciled at the %v rate, the highest rate allowed is %v", chn.Namespace,
+			chn.Name, rate, maxRate)
+	}
+
+	return nil
+}
+
+// getReconcileRate returns the reconcile rate of the channel, the unknown rates are the default one
+func getReconcileRate(chn *chnv1.Channel) string {
+	rate := strings.ToLower(strings.TrimSpace(chn.GetAnnotations()[appv1.AnnotationResourceReconcileLevel]))
+
+	if _, ok := reconcileRates[rate]; !ok {
+		return defaultReconcileRate
+	}
+
+	return rate
+}
+
+func subscriptionChannels(sub *appv1.Subscription) []string {
+	channels := []string{}
+
+	for _, channel := range []string{sub.Spec.Channel, sub.Spec.SecondaryChannel} {
+		if channel = strings.TrimSpace(channel); channel != "" {
+			channels = append(channels, channel)
+		}
+	}
+
+	return channels
+}
+
+// isHubCreated returns true for the subscriptions the hub creates from another subscription
+func isHubCreated(sub *appv1.Subscription) bool {
+	return sub.GetAnnotations()[appv1.AnnotationHosting] != ""
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+// validateRetarget checks the retarget of the subscription spec can be processed by the hub: the target channel
+// exists and is of the type of the current one, the subscription is propagated to remote clusters, and the channel
+// isn't changed directly while a retarget is pending. It returns a warning if the deletion of packages is allowed.
+func validateRetarget(ctx context.Context, clt client.Client, sub, old *appv1.Subscription) ([]string, error) {
+	retarget := sub.Spec.Retarget
+
+	if old != nil && old.Spec.Retarget != nil && retarget != nil && old.Spec.Channel != sub.Spec.Channel {
+		return nil, fmt.Errorf("spec.channel can't be changed while the retarget to channel %v is pending", old.Spec.Retarget.Channel)
+	}
+
+	if retarget == nil {
+		return nil, nil
+	}
+
+	pl := sub.Spec.Placement
+	if pl == nil || (pl.Local != nil && *pl.Local) {
+		return nil, fmt.Errorf("spec.retarget is only supported by the subscriptions placed on remote clusters")
+	}
+
+	targetKey, err := parseChannelKey(retarget.Channel)
+	if err != nil {
+		return nil, fmt.Errorf("spec.retarget.channel: %w", err)
+	}
+
+	target := &chnv1.Channel{}
+	if err := clt.Get(ctx, targetKey, target); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("spec.retarget.channel: channel %v not found", retarget.Channel)
+		}
+
+		return nil, fmt.Errorf("failed to get channel %v, err: %w", retarget.Channel, err)
+	}
+
+	// the subscriptions created with a missing channel are reported by the hub reconcile
+	currentKey, err := parseChannelKey(sub.Spec.Channel)
+	if err != nil {
+		return nil, fmt.Errorf("spec.channel: %w", err)
+	}
+
+	current := &chnv1.Channel{}
+	if err := clt.Get(ctx, currentKey, current); err == nil && !sameChannelType(current, target) {
+		return nil, fmt.Errorf("spec.retarget.channel: channel %v is of type %v, not %v like channel %v",
+			retarget.Channel, target.Spec.Type, current.Spec.Type, sub.Spec.Channel)
+	}
+
+	if retarget.AllowPackageDeletion && retarget.Channel != sub.Spec.Channel {
+		return []string{fmt.Sprintf("the packages missing from channel %v will be deleted from the clusters", retarget.Channel)}, nil
+	}
+
+	return nil, nil
+}
+
+func parseChannelKey(channel string) (types.NamespacedName, error) {
+	strs := strings.Split(channel, "/")
+	if len(strs) != 2 || strs[0] == "" || strs[1] == "" {
+		return types.NamespacedName{}, fmt.Errorf("%q is not a namespace/name channel", channel)
+	}
+
+	return types.NamespacedName{Namespace: strs[0], Name: strs[1]}, nil
+}
+
+func sameChannelType(a, b *chnv1.Channel) bool {
+	if utils.IsGitChannel(string(a.Spec.Type)) && utils.IsGitChannel(string(b.Spec.Type)) {
+		return true
+	}
+
+	return strings.EqualFold(string(a.Spec.Type), string(b.Spec.Type))
+}
@@ -0,0 +1,229 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+const (
+	validateSubscriptionPath  = "/validate-subscription"
+	validateChannelPath       = "/validate-channel"
+	mutateSubscriptionSetPath = "/mutate-subscriptionset"
+	// maxReviewSize limits the admission review requests read by the server
+	maxReviewSize = 3 * 1024 * 1024
+)
+
+// Server is the validating admission webhook of the subscriptions and the channels, and the mutating admission
+// webhook of the subscription sets
+type Server struct {
+	client     client.Client
+	address    string
+	tlsKeyFile string
+	tlsCrtFile string
+	disableTLS bool
+}
+
+// Add creates the admission webhook server and adds it to the manager, the server listens on address.
+func Add(mgr manager.Manager, address, tlsKeyFile, tlsCrtFile string, disableTLS bool) error {
+	return mgr.Add(&Server{
+		client:     mgr.GetClient(),
+		address:    address,
+		tlsKeyFile: tlsKeyFile,
+		tlsCrtFile: tlsCrtFile,
+		disableTLS: disableTLS,
+	})
+}
+
+// Start serves the admission reviews until the context is done, this will be triggered by the manager.
+func (s *Server) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.address,
+		Handler:           s,
+		ReadHeaderTimeout: 30 * time.Second,
+		BaseContext:       func(_ net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		if err := srv.Shutdown(context.TODO()); err != nil {
+			klog.Error("failed to shut down the admission webhook server, err: ", err)
+		}
+	}()
+
+	klog.Info("starting the admission webhook server on ", s.address)
+
+	var err error
+
+	if s.disableTLS {
+		err = srv.ListenAndServe()
+	} else {
+		err = utils.ListenAndServeTLS(srv, s.tlsCrtFile, s.tlsKeyFile)
+	}
+
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// NeedLeaderElection validates the subscriptions on every replica
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// ServeHTTP reviews POST /validate-subscription, /validate-channel and /mutate-subscriptionset admission reviews
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	var review func(context.Context, *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+
+	switch r.URL.Path {
+	case validateSubscriptionPath:
+		review = s.reviewSubscription
+	case validateChannelPath:
+		review = s.reviewChannel
+	case mutateSubscriptionSetPath:
+		review = s.mutateSubscriptionSet
+	default:
+		http.Error(w, "unknown path "+r.URL.Path, http.StatusNotFound)
+
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxReviewSize))
+	if err != nil {
+		http.Error(w, "failed to read the request, err: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	admissionReview := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(body, admissionReview); err != nil || admissionReview.Request == nil {
+		http.Error(w, "the request is not an admission review", http.StatusBadRequest)
+
+		return
+	}
+
+	admissionReview.Response = review(r.Context(), admissionReview.Request)
+	admissionReview.Response.UID = admissionReview.Request.UID
+	admissionReview.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(admissionReview); err != nil {
+		klog.Error("failed to write the admission review response, err: ", err)
+	}
+}
+
+func (s *Server) reviewSubscription(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	sub := &appv1.Subscription{}
+	if err := json.Unmarshal(req.Object.Raw, sub); err != nil {
+		return denied(fmt.Sprintf("failed to decode the subscription, err: %v", err))
+	}
+
+	var old *appv1.Subscription
+
+	if req.Operation == admissionv1.Update {
+		old = &appv1.Subscription{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return denied(fmt.Sprintf("failed to decode the subscription, err: %v", err))
+		}
+	}
+
+	warnings, err := validateRetarget(ctx, s.client, sub, old)
+	if err != nil {
+		klog.Infof("denied %v of subscription %v/%v: %v", req.Operation, req.Namespace, req.Name, err)
+
+		return denied(err.Error())
+	}
+
+	if err := validateSubscriptionQuota(ctx, s.client, sub, old); err != nil {
+		klog.Infof("denied %v of subscription %v/%v: %v", req.Operation, req.Namespace, req.Name, err)
+
+		return denied(err.Error())
+	}
+
+	return &admissionv1.AdmissionResponse{Allowed: true, Warnings: warnings}
+}
+
+// reviewChannel denies the channels referencing a host out of the channel source allow-list, or reconciled at a
+// higher rate than the quota of their namespace
+func (s *Server) reviewChannel(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	chn := &chnv1.Channel{}
+	if err := json.Unmarshal(req.Object.Raw, chn); err != nil {
+		return denied(fmt.Sprintf("failed to decode the channel, err: %v", err))
+	}
+
+	if err := utils.CheckChannelSourceAllowed(chn); err != nil {
+		klog.Infof("denied %v of channel %v/%v: %v", req.Operation, req.Namespace, req.Name, err)
+
+		return denied(err.Error())
+	}
+
+	var old *chnv1.Channel
+
+	if req.Operation == admissionv1.Update {
+		old = &chnv1.Channel{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return denied(fmt.Sprintf("failed to decode the channel, err: %v", err))
+		}
+	}
+
+	if err := validateChannelQuota(ctx, s.client, chn, old); err != nil {
+		klog.Infof("denied %v of channel %v/%v: %v", req.Operation, req.Namespace, req.Name, err)
+
+		return denied(err.Error())
+	}
+
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+func denied(msg string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result:  &metav1.Status{Status: metav1.StatusFailure, Message: msg, Reason: metav1.StatusReasonInvalid, Code: http.StatusUnprocessableEntity},
+	}
+}
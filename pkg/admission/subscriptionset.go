@@ -0,0 +1,61 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admission
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// mutateSubscriptionSet stamps the user identity and groups of the request on the subscription set, replacing the
+// ones its author may have set. The subscription set controller gives them to the subscriptions it generates.
+func (s *Server) mutateSubscriptionSet(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	set := &appv1.SubscriptionSet{}
+	if err := json.Unmarshal(req.Object.Raw, set); err != nil {
+		return denied(fmt.Sprintf("failed to decode the subscription set, err: %v", err))
+	}
+
+	annotations := set.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[appv1.AnnotationUserIdentity] = base64.StdEncoding.EncodeToString([]byte(req.UserInfo.Username))
+	annotations[appv1.AnnotationUserGroup] = base64.StdEncoding.EncodeToString(
+		[]byte(strings.Join(req.UserInfo.Groups, ",")))
+
+	// adding an existing member replaces it
+	patch, err := json.Marshal([]map[string]interface{}{
+		{"op": "add", "path": "/metadata/annotations", "value": annotations},
+	})
+	if err != nil {
+		return denied(fmt.Sprintf("failed to patch the subscription set, err: %v", err))
+	}
+
+	patchType := admissionv1.PatchTypeJSONPatch
+
+	return &admissionv1.AdmissionResponse{Allowed: true, Patch: patch, PatchType: &patchType}
+}
@@ -224,6 +224,7 @@ const (
 	ConditionDeployed       HelmAppConditionType = "Deployed"
 	ConditionReleaseFailed  HelmAppConditionType = "ReleaseFailed"
 	ConditionIrreconcilable HelmAppConditionType = "Irreconcilable"
+	ConditionWaitingForCRDs HelmAppConditionType = "WaitingForCRDs"
 
 	StatusTrue    ConditionStatus = "True"
 	StatusFalse   ConditionStatus = "False"
@@ -236,6 +237,7 @@ const (
 	ReasonUpgradeError        HelmAppConditionReason = "UpgradeError"
 	ReasonReconcileError      HelmAppConditionReason = "ReconcileError"
 	ReasonUninstallError      HelmAppConditionReason = "UninstallError"
+	ReasonCRDsNotEstablished  HelmAppConditionReason = "CRDsNotEstablished"
 )
 
 type HelmAppStatus struct {
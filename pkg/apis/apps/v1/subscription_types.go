@@ -45,6 +45,8 @@ var (
 	AnnotationWebhookEventCount = SchemeGroupVersion.Group + "/webhook-event-count"
 	// AnnotationWebhookSecret defines webhook secret
 	AnnotationWebhookSecret = SchemeGroupVersion.Group + "/webhook-secret"
+	// AnnotationWebhookAllowUnsigned sits in a channel, lets the unsigned push events sync its subscriptions
+	AnnotationWebhookAllowUnsigned = SchemeGroupVersion.Group + "/webhook-allow-unsigned"
 	// AnnotationGithubPath defines webhook secret
 	AnnotationGithubPath = SchemeGroupVersion.Group + "/github-path"
 	// AnnotationGithubBranch defines webhook secret
@@ -55,6 +57,16 @@ var (
 	AnnotationGitPath = SchemeGroupVersion.Group + "/git-path"
 	// AnnotationGitBranch defines webhook secret
 	AnnotationGitBranch = SchemeGroupVersion.Group + "/git-branch"
+	// AnnotationGitIncludePaths defines the comma separated glob patterns of the Git repo paths to subscribe
+	AnnotationGitIncludePaths = SchemeGroupVersion.Group + "/git-include-paths"
+	// AnnotationGitExcludePaths defines the comma separated glob patterns of the Git repo paths not to subscribe
+	AnnotationGitExcludePaths = SchemeGroupVersion.Group + "/git-exclude-paths"
+	// AnnotationGitPathExpect defines the comma separated structure expected from the subscribed Git repo paths,
+	// chart, kustomization or non-empty
+	AnnotationGitPathExpect = SchemeGroupVersion.Group + "/git-path-expect"
+	// AnnotationGitPaths defines the comma separated Git repo paths of the resources to subscribe, when there are
+	// several of them
+	AnnotationGitPaths = SchemeGroupVersion.Group + "/git-paths"
 	// AnnotationGitCommit defines currently deployed Git repo commit ID
 	AnnotationGitCommit = SchemeGroupVersion.Group + "/git-current-commit"
 	// AnnotationGitCloneDepth defines Git repo clone depth to be able to check out previous commits
@@ -63,6 +75,44 @@ var (
 	AnnotationGitTargetCommit = SchemeGroupVersion.Group + "/git-desired-commit"
 	// AnnotationGitTag defines Git repo revision tag
 	AnnotationGitTag = SchemeGroupVersion.Group + "/git-tag"
+	// AnnotationGitProvider defines the Git provider of a channel, github, gitlab or bitbucket-server. It is detected from
+	// the channel URL host if not set.
+	AnnotationGitProvider = SchemeGroupVersion.Group + "/git-provider"
+	// AnnotationTemplateParameters names the ConfigMap of the parameter values of the OpenShift templates in the repo
+	AnnotationTemplateParameters = SchemeGroupVersion.Group + "/template-parameters"
+	// AnnotationGitAllowedAuthors lists the commit authors, by name, email or @email-domain, allowed to be deployed
+	AnnotationGitAllowedAuthors = SchemeGroupVersion.Group + "/git-allowed-authors"
+	// AnnotationGitSigningKeys is the secret of the PGP public keys the deployed commits or tags must be signed with
+	AnnotationGitSigningKeys = SchemeGroupVersion.Group + "/git-signing-keys"
+	// AnnotationSopsDecryptionSecret is the secret of the age identities or PGP private keys the SOPS encrypted files
+	// of the repo are decrypted with
+	AnnotationSopsDecryptionSecret = SchemeGroupVersion.Group + "/sops-decryption-secret"
+	// AnnotationClusterVariables enables the substitution of the ${CLUSTER_...} variables of the cluster claims in
+	// the subscribed resources and Helm values
+	AnnotationClusterVariables = SchemeGroupVersion.Group + "/cluster-variables"
+	// AnnotationChannelVariables enables the substitution of the ${CHANNEL_...} variables of the channel name,
+	// namespace, labels and annotations in the values of the HelmReleases of the subscription, "true" or "false"
+	AnnotationChannelVariables = SchemeGroupVersion.Group + "/channel-variables"
+	// AnnotationHelmReleaseNamespace is the namespace of the HelmReleases of the subscription, the charts are installed
+	// in, rather than the subscription namespace. It may reference the ${CHANNEL_...} variables.
+	AnnotationHelmReleaseNamespace = SchemeGroupVersion.Group + "/helm-release-namespace"
+	// AnnotationNamespaceContainment is the namespace annotation of the policy of the resources subscribed in another
+	// namespace by the subscriptions of the namespace, force or reject
+	AnnotationNamespaceContainment = SchemeGroupVersion.Group + "/namespace-containment"
+	// AnnotationMaxSubscriptions is the namespace annotation of the maximum number of subscriptions of the namespace
+	AnnotationMaxSubscriptions = SchemeGroupVersion.Group + "/max-subscriptions"
+	// AnnotationMaxChannels is the namespace annotation of the maximum number of channels referenced by the
+	// subscriptions of the namespace
+	AnnotationMaxChannels = SchemeGroupVersion.Group + "/max-channels"
+	// AnnotationMaxReconcileRate is the namespace annotation of the highest reconcile rate of the channels of the
+	// namespace and of the channels subscribed in the namespace, off, low, medium or high
+	AnnotationMaxReconcileRate = SchemeGroupVersion.Group + "/max-reconcile-rate"
+	// AnnotationConflictStrategy is the strategy of the applies hitting a resource owned by others or an immutable
+	// field, fail, overwrite, merge or skip
+	AnnotationConflictStrategy = SchemeGroupVersion.Group + "/conflict-strategy"
+	// AnnotationTargetKubeconfigSecrets lists the secrets, in the subscription namespace, holding the kubeconfig of the
+	// external clusters a standalone subscription also deploys its resources to
+	AnnotationTargetKubeconfigSecrets = SchemeGroupVersion.Group + "/target-kubeconfig-secrets"
 	// AnnotationClusterAdmin indicates the subscription has cluster admin access
 	AnnotationClusterAdmin = SchemeGroupVersion.Group + "/cluster-admin"
 	// AnnotationChannelType indicates the channel type for subscription
@@ -78,6 +128,12 @@ var (
 	AnnotationResourceReconcileLevel = SchemeGroupVersion.Group + "/reconcile-rate"
 	// AnnotationManualReconcileTime is the time user triggers a manual resource reconcile
 	AnnotationManualReconcileTime = SchemeGroupVersion.Group + "/manual-refresh-time"
+	// AnnotationResyncPackage requests a forced re-apply of a single package, <package name>[@<request id>]
+	AnnotationResyncPackage = SchemeGroupVersion.Group + "/resync-package"
+	// AnnotationClusterSelector limits a subscribed resource to the clusters whose cluster claims match the label selector
+	AnnotationClusterSelector = SchemeGroupVersion.Group + "/cluster-selector"
+	// AnnotationKubeVersion limits a subscribed resource to the clusters whose Kubernetes version meets the semver constraint
+	AnnotationKubeVersion = SchemeGroupVersion.Group + "/kube-version"
 	//LabelSubscriptionPause sits in subscription label to identify if the subscription is paused or not
 	LabelSubscriptionPause = "subscription-pause"
 	//LabelSubscriptionName is the subscription name
@@ -86,12 +142,116 @@ var (
 	AnnotationHookType = SchemeGroupVersion.Group + "/hook-type"
 	// AnnotationBucketPath defines s3 object bucket subfolder path
 	AnnotationBucketPath = SchemeGroupVersion.Group + "/bucket-path"
+	// AnnotationHelmRepoProject scopes the Helm repo of the channel to a project path, like a Harbor project of the
+	// chartrepo URL, so one channel serves the subscriptions of every project
+	AnnotationHelmRepoProject = SchemeGroupVersion.Group + "/helm-repo-project"
+	// AnnotationHelmRepoURLs sits in a Helm repo channel, lists the URLs of the other Helm repos of the channel, comma
+	// separated, whose indexes are merged with the index of the channel pathname
+	AnnotationHelmRepoURLs = SchemeGroupVersion.Group + "/helm-repo-urls"
+	// AnnotationHelmRepoPrecedence sits in a Helm repo channel, tells how the indexes of its Helm repos are merged,
+	// "version" or "order"
+	AnnotationHelmRepoPrecedence = SchemeGroupVersion.Group + "/helm-repo-precedence"
+	// AnnotationObjectStoreEndpoint sits in an object bucket channel, sets the URL of its S3 compatible object store,
+	// like a MinIO or Ceph RGW one, rather than the channel pathname without the bucket
+	AnnotationObjectStoreEndpoint = SchemeGroupVersion.Group + "/object-store-endpoint"
+	// AnnotationObjectStoreRegion sits in an object bucket channel, sets the region of its object store
+	AnnotationObjectStoreRegion = SchemeGroupVersion.Group + "/object-store-region"
+	// AnnotationObjectStorePathStyle sits in an object bucket channel, "true" addresses its bucket as
+	// <endpoint>/<bucket> and "false" as <bucket>.<endpoint>
+	AnnotationObjectStorePathStyle = SchemeGroupVersion.Group + "/object-store-path-style"
+	// AnnotationObjectStoreCredentials sits in an object bucket channel, "secret" for the access keys of its secret, or
+	// "ambient" for the AWS credentials of the pod, like its IRSA role or the instance profile of its node
+	AnnotationObjectStoreCredentials = SchemeGroupVersion.Group + "/object-store-credentials"
+	// AnnotationObjectStorePrefix sits in an object bucket channel, gives the folder of the channel in its bucket
+	AnnotationObjectStorePrefix = SchemeGroupVersion.Group + "/object-store-prefix"
+	// AnnotationObjectStoreKeyFilter sits in an object bucket channel, gives the comma separated globs of the object
+	// keys of the channel, relative to its folder
+	AnnotationObjectStoreKeyFilter = SchemeGroupVersion.Group + "/object-store-key-filter"
 	// AnnotationManagedCluster identifies this is a deployable for managed cluster
 	AnnotationManagedCluster = SchemeGroupVersion.Group + "/managed-cluster"
 	// AnnotationHostingDeployable sits in templated resource, gives name of hosting deployable, legacy annotation
 	AnnotationHostingDeployable = SchemeGroupVersion.Group + "/hosting-deployable"
 	// AnnotationCurrentNamespaceScoped specifies to deloy resources into subscription namespace
 	AnnotationCurrentNamespaceScoped = SchemeGroupVersion.Group + "/current-namespace-scoped"
+	// AnnotationPreserveNamespace sits in a subscription or in a package, "true" keeps the namespace declared in the
+	// package rather than deploying it in the subscription namespace, if the user-identity and user-group of the
+	// subscription can deploy it there. The package annotation overrides the subscription one.
+	AnnotationPreserveNamespace = SchemeGroupVersion.Group + "/preserve-namespace"
+	// AnnotationRedacted sits in a secret of an exported bundle, "true" if its values are emptied by the export
+	AnnotationRedacted = SchemeGroupVersion.Group + "/redacted"
+	// AnnotationSkipCapabilityCheck skips probing the managed cluster for the application addon before propagation
+	AnnotationSkipCapabilityCheck = SchemeGroupVersion.Group + "/skip-capability-check"
+	// AnnotationHealthCheck sits in a package, gives a JSONPath readiness gate evaluated against the deployed resource
+	AnnotationHealthCheck = SchemeGroupVersion.Group + "/health-check"
+	// AnnotationRecreateOnImmutable sits in a package, "true" deletes and creates the resource again when its update
+	// changes an immutable field
+	AnnotationRecreateOnImmutable = SchemeGroupVersion.Group + "/recreate-on-immutable"
+	// AnnotationSyncWave sits in a package, the packages of the lower integer waves are applied first, 0 by default
+	AnnotationSyncWave = SchemeGroupVersion.Group + "/sync-wave"
+	// AnnotationApplyBatchSize is the number of resources applied between two updates of the apply progress status
+	AnnotationApplyBatchSize = SchemeGroupVersion.Group + "/apply-batch-size"
+	// AnnotationHelmRender tells how the Helm charts of a Git subscription are deployed, "local" to template them
+	// and apply the rendered manifests, "helmrelease" to create HelmRelease CRs
+	AnnotationHelmRender = SchemeGroupVersion.Group + "/helm-render"
+	// AnnotationHelmValuesFile is the values file of the Helm charts of a Git subscription, relative to the chart
+	// directory, values-override.yaml by default
+	AnnotationHelmValuesFile = SchemeGroupVersion.Group + "/helm-values-file"
+	// LabelDesiredStateHash sits in the deployed resources, gives the desired state hash of the subscription sync
+	// that applied them
+	LabelDesiredStateHash = SchemeGroupVersion.Group + "/desired-state-hash"
+	// AnnotationGenerateName tells how the resources with a metadata.generateName and no name are deployed, "reject"
+	// to fail them, "hash" to name them with a hash stable across the syncs
+	AnnotationGenerateName = SchemeGroupVersion.Group + "/generate-name"
+	// AnnotationAtLeastOnce is "true" to keep retrying the commits of a Git subscription until all their packages are
+	// applied, the deployed commit only advances once no package failed
+	AnnotationAtLeastOnce = SchemeGroupVersion.Group + "/at-least-once"
+	// AnnotationGeneratorVersion sits in the deployed resources and the generated HelmReleases, gives the version of the
+	// naming and annotation conventions of the operator that generated them
+	AnnotationGeneratorVersion = SchemeGroupVersion.Group + "/generator-version"
+)
+
+const (
+	// SubscriptionDegraded is the condition of the subscription whose channel failed to be fetched several syncs in
+	// a row, or whose repo paths don't have the expected structure
+	SubscriptionDegraded = "Degraded"
+)
+
+const (
+	// ObjectStoreCredentialsSecret signs the object store requests with the access keys of the channel secret
+	ObjectStoreCredentialsSecret = "secret"
+	// ObjectStoreCredentialsAmbient signs the object store requests with the AWS credentials of the pod environment,
+	// refreshed as they expire. Only in the namespaces the operator allows them.
+	ObjectStoreCredentialsAmbient = "ambient"
+)
+
+const (
+	// HelmRepoPrecedenceVersion merges the versions of a chart across the Helm repos of the channel, a version in
+	// several repos is taken from the first one. The default.
+	HelmRepoPrecedenceVersion = "version"
+	// HelmRepoPrecedenceOrder takes a chart from the first Helm repo of the channel that has it, the versions of the
+	// chart in the next repos are ignored
+	HelmRepoPrecedenceOrder = "order"
+)
+
+const (
+	// HelmRenderLocal templates the Helm charts of a Git subscription in the subscription controller
+	HelmRenderLocal = "local"
+	// HelmRenderHelmRelease deploys the Helm charts of a Git subscription with HelmRelease CRs
+	HelmRenderHelmRelease = "helmrelease"
+	// DefaultHelmValuesFile is the values file read from the chart directory of a Git subscription
+	DefaultHelmValuesFile = "values-override.yaml"
+	// GenerateNameReject fails the resources with a metadata.generateName and no name, the default
+	GenerateNameReject = "reject"
+	// GenerateNameHash names the resources with a metadata.generateName and no name from a hash of their identity
+	GenerateNameHash = "hash"
+	// ConflictStrategyFail fails the resources in conflict
+	ConflictStrategyFail = "fail"
+	// ConflictStrategyOverwrite replaces the resources in conflict, the ones with an immutable field are recreated
+	ConflictStrategyOverwrite = "overwrite"
+	// ConflictStrategyMerge patches the resources owned by others, without taking them over
+	ConflictStrategyMerge = "merge"
+	// ConflictStrategySkip leaves the resources in conflict as they are and reports them
+	ConflictStrategySkip = "skip"
 )
 
 const (
@@ -111,6 +271,8 @@ const (
 	SubscriptionNameSuffix = ""
 	// ChannelCertificateData is the configmap data spec field containing trust certificates
 	ChannelCertificateData = "caCerts"
+	// DefaultApplyBatchSize is the default number of resources applied between two apply progress updates
+	DefaultApplyBatchSize = 100
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -123,6 +285,11 @@ type PackageFilter struct {
 	// +kubebuilder:validation:Pattern=([0-9]+)((\.[0-9]+)(\.[0-9]+)|(\.[0-9]+)?(\.[xX]))$
 	Version   string                       `json:"version,omitempty"`
 	FilterRef *corev1.LocalObjectReference `json:"filterRef,omitempty"`
+
+	// GitTag is a semver constraint of the Git tags, e.g. ">=v1.2.0 <2.0.0". The Git subscription deploys the highest
+	// tag matching it, unless the git-desired-commit or git-tag annotation is set.
+	// +optional
+	GitTag string `json:"gitTag,omitempty"`
 }
 
 // PackageOverride describes rules for override
@@ -135,6 +302,21 @@ type Overrides struct {
 	PackageAlias     string            `json:"packageAlias,omitempty"`
 	PackageName      string            `json:"packageName"`
 	PackageOverrides []PackageOverride `json:"packageOverrides,omitempty"` // To be added
+
+	// Condition limits the package to the clusters meeting it
+	// +optional
+	Condition *PackageCondition `json:"condition,omitempty"`
+}
+
+// PackageCondition is met by the clusters matching all of its fields
+type PackageCondition struct {
+	// ClusterSelector selects the clusters by their cluster claims
+	// +optional
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+
+	// KubeVersion is a semver constraint on the Kubernetes version of the cluster, e.g. ">= 1.21"
+	// +optional
+	KubeVersion string `json:"kubeVersion,omitempty"`
 }
 
 // AllowDenyItem is a group resources allowed or denied for deployment
@@ -180,7 +362,7 @@ type SubscriptionSpec struct {
 	Channel string `json:"channel"`
 	// When fails to connect to the channel, connect to the secondary channel
 	SecondaryChannel string `json:"secondaryChannel,omitempty"`
-	// To specify 1 package in channel
+	// To specify the packages in channel, a comma separated list of names, all the packages if empty
 	Package string `json:"name,omitempty"`
 	// To specify more than 1 package in channel
 	PackageFilter *PackageFilter `json:"packageFilter,omitempty"`
@@ -198,6 +380,42 @@ type SubscriptionSpec struct {
 	Deny          []*AllowDenyItem        `json:"deny,omitempty"`
 	// WatchHelmNamespaceScopedResources is used to enable watching namespace scope Helm chart resources
 	WatchHelmNamespaceScopedResources bool `json:"watchHelmNamespaceScopedResources,omitempty"`
+	// Retarget switches the subscription to another channel of the same type, once the packages of the two channels
+	// are compared
+	// +optional
+	Retarget *ChannelRetarget `json:"retarget,omitempty"`
+	// ValuesFrom are the ConfigMaps and Secrets of the subscription namespace holding Helm values. They are merged in
+	// order, the later ones taking precedence, then the packageOverrides are applied on top of them.
+	// +optional
+	ValuesFrom []ValuesReference `json:"valuesFrom,omitempty"`
+}
+
+// ValuesReference is a key of a ConfigMap or a Secret holding Helm values in YAML
+type ValuesReference struct {
+	// Kind is ConfigMap or Secret
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+	// Name of the ConfigMap or the Secret, in the namespace of the subscription
+	Name string `json:"name"`
+	// ValuesKey is the key holding the values, values.yaml by default
+	// +optional
+	ValuesKey string `json:"valuesKey,omitempty"`
+	// PackageName limits the values to the chart of the package, they go to all the charts of the subscription
+	// by default
+	// +optional
+	PackageName string `json:"packageName,omitempty"`
+	// Optional ignores a missing ConfigMap, Secret or key, instead of failing the HelmRelease
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+// ChannelRetarget is a request to switch the channel of a subscription, e.g. from a stage Helm repo to the prod one
+type ChannelRetarget struct {
+	// Channel is the namespace/name of the channel to switch to
+	Channel string `json:"channel"`
+	// AllowPackageDeletion confirms the switch when the packages missing from the target channel would be deleted
+	// +optional
+	AllowPackageDeletion bool `json:"allowPackageDeletion,omitempty"`
 }
 
 // SubscriptionPhase defines the phasing of a Subscription
@@ -244,6 +462,36 @@ type AnsibleJobsStatus struct {
 	PosthookJobsHistory []string `json:"posthookjobshistory,omitempty"`
 }
 
+// SubscriberState is the runtime state of the subscriber of a subscription. It is saved in the subscriber state
+// store, so the subscriber resumes from it when the controller restarts or the subscription moves to another
+// controller.
+type SubscriberState struct {
+	// Revision is the revision of the channel content last synced, the commit of a Git repository or the hash of
+	// the subscribed chart versions of a Helm repository
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Generation is the generation of the subscription the revision was synced for
+	// +optional
+	Generation int64 `json:"generation,omitempty"`
+
+	// Successful tells if the last sync of the revision succeeded
+	// +optional
+	Successful bool `json:"successful,omitempty"`
+
+	// Retries counts the syncs of the revision since the subscriber last resynced all its resources
+	// +optional
+	Retries int `json:"retries,omitempty"`
+
+	// FetchFailures counts the syncs in a row that failed to fetch the channel
+	// +optional
+	FetchFailures int `json:"fetchFailures,omitempty"`
+
+	// Inventory lists the resources of the last successful sync, as <apiVersion>/<kind>/<namespace>/<name>
+	// +optional
+	Inventory []string `json:"inventory,omitempty"`
+}
+
 // SubscriptionStatus defines the observed state of Subscription
 // Examples - status of a subscription on hub
 //Status:
@@ -274,6 +522,57 @@ type SubscriptionStatus struct {
 	Reason             string            `json:"reason,omitempty"`
 	LastUpdateTime     metav1.Time       `json:"lastUpdateTime,omitempty"`
 
+	// OutOfSyncSince is the time the subscription started to fail reaching its source or applying its resources,
+	// it is cleared on the next successful sync
+	// +optional
+	OutOfSyncSince *metav1.Time `json:"outOfSyncSince,omitempty"`
+
+	// DeployedCommit is the Git commit whose resources were last applied to the cluster
+	// +optional
+	DeployedCommit string `json:"deployedCommit,omitempty"`
+
+	// DesiredCommitSynced tells if the deployed commit is the git-desired-commit of the subscription. It is not set
+	// when the subscription has no desired commit.
+	// +optional
+	DesiredCommitSynced *bool `json:"desiredCommitSynced,omitempty"`
+
+	// ApplyProgress is the progress of the last apply of the subscription resources on the cluster
+	// +optional
+	ApplyProgress *ApplyProgress `json:"applyProgress,omitempty"`
+
+	// Source is where the resources last applied to the cluster were read from, to reproduce or audit the sync
+	// +optional
+	Source *SubscriptionSource `json:"source,omitempty"`
+
+	// SourceRevision is the revision of the channel content last applied to the cluster, in the same terms for all
+	// the channel types: the commit for a Git repository, sha256:<digest> of the subscribed chart versions for a Helm
+	// repository and sha256:<digest> of the listed objects for an object bucket
+	// +optional
+	SourceRevision string `json:"sourceRevision,omitempty"`
+
+	// DesiredStateHash is a hash of the resources of the last sync of the subscription on the cluster, as rendered
+	// and mutated before they are applied. The deployed resources have it in their desired-state-hash label.
+	// +optional
+	DesiredStateHash string `json:"desiredStateHash,omitempty"`
+
+	// Retarget is the result of the last channel retarget of the subscription
+	// +optional
+	Retarget *ChannelRetargetStatus `json:"retarget,omitempty"`
+
+	// SubscriberState is the runtime state of the subscriber of the subscription, saved when the subscriber state
+	// store is the subscription status
+	// +optional
+	SubscriberState *SubscriberState `json:"subscriberState,omitempty"`
+
+	// Conditions are the conditions of the subscription on the cluster, Degraded when its channel can't be fetched
+	// or deployed
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKeys=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
 	// +optional
 	AnsibleJobsStatus AnsibleJobsStatus `json:"ansiblejobs,omitempty"`
 	// For endpoint, it is the status of subscription, key is packagename,
@@ -281,6 +580,70 @@ type SubscriptionStatus struct {
 	Statuses SubscriptionClusterStatusMap `json:"statuses,omitempty"`
 }
 
+// ApplyProgress tells how many of the subscription resources are applied, it is updated after each batch of the apply
+type ApplyProgress struct {
+	// Applied is the number of resources the apply is done with, successfully or not
+	Applied int `json:"applied"`
+	// Total is the number of resources to apply
+	Total int `json:"total"`
+	// Message is the progress as applied X of Y
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// SubscriptionSource is where the resources of a sync were read from
+type SubscriptionSource struct {
+	// Channel is the namespace/name of the channel the resources were read from, the secondary channel when the
+	// primary channel failed
+	// +optional
+	Channel string `json:"channel,omitempty"`
+	// URL is the repository URL of the channel, after the HTTP redirects for Git, without credentials
+	// +optional
+	URL string `json:"url,omitempty"`
+	// Ref is the Git branch or tag reference the commit was read from
+	// +optional
+	Ref string `json:"ref,omitempty"`
+	// Commit is the Git commit the resources were read from
+	// +optional
+	Commit string `json:"commit,omitempty"`
+	// Submodules are the commits of the Git submodules checked out, by submodule path
+	// +optional
+	Submodules map[string]string `json:"submodules,omitempty"`
+	// ChannelCache is the URL of the hub channel cache the content was fetched through, if any
+	// +optional
+	ChannelCache string `json:"channelCache,omitempty"`
+}
+
+// ChannelRetargetPhase defines the phasing of a channel retarget
+type ChannelRetargetPhase string
+
+const (
+	// ChannelRetargetBlocked means the target channel misses packages of the current one, and their deletion is not
+	// allowed
+	ChannelRetargetBlocked ChannelRetargetPhase = "Blocked"
+	// ChannelRetargetFailed means the packages of the channels can't be compared
+	ChannelRetargetFailed ChannelRetargetPhase = "Failed"
+	// ChannelRetargetCompleted means the subscription is switched to the target channel
+	ChannelRetargetCompleted ChannelRetargetPhase = "Completed"
+)
+
+// ChannelRetargetStatus compares the packages of the current and target channels of a retarget
+type ChannelRetargetStatus struct {
+	Phase ChannelRetargetPhase `json:"phase"`
+	// FromChannel is the channel the subscription is switched from
+	FromChannel string `json:"fromChannel"`
+	// ToChannel is the channel the subscription is switched to
+	ToChannel string `json:"toChannel"`
+	// AddedPackages are the packages of the target channel only
+	// +optional
+	AddedPackages []string `json:"addedPackages,omitempty"`
+	// DeletedPackages are the packages of the current channel only, they are deleted from the clusters by the switch
+	// +optional
+	DeletedPackages []string `json:"deletedPackages,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
 // +genclient
 // +kubebuilder:object:root=true
 
@@ -0,0 +1,92 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	// LabelSubscriptionSet sits in generated subscriptions, gives the namespace.name of the hosting SubscriptionSet
+	LabelSubscriptionSet = SchemeGroupVersion.Group + "/subscription-set"
+)
+
+const (
+	// SubscriptionSetParamCluster is replaced by the managed cluster name in the subscription template
+	SubscriptionSetParamCluster = "{{cluster}}"
+	// SubscriptionSetParamNamespace is replaced by the SubscriptionSet namespace in the subscription template
+	SubscriptionSetParamNamespace = "{{namespace}}"
+	// SubscriptionSetParamName is replaced by the SubscriptionSet name in the subscription template
+	SubscriptionSetParamName = "{{name}}"
+)
+
+// SubscriptionSetGenerator defines the parameters the subscriptions are stamped out with.
+// A subscription is generated for each cluster, in the namespace of the SubscriptionSet.
+type SubscriptionSetGenerator struct {
+	// Clusters lists the managed clusters to generate a subscription for
+	Clusters []string `json:"clusters,omitempty"`
+	// ClusterSelector selects the managed clusters to generate a subscription for
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+}
+
+// SubscriptionTemplate is the template of the generated subscriptions
+type SubscriptionTemplate struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Spec        SubscriptionSpec  `json:"spec"`
+}
+
+// SubscriptionSetSpec defines the desired state of SubscriptionSet
+type SubscriptionSetSpec struct {
+	Generator SubscriptionSetGenerator `json:"generator"`
+	Template  SubscriptionTemplate     `json:"template"`
+}
+
+// SubscriptionSetStatus defines the observed state of SubscriptionSet
+type SubscriptionSetStatus struct {
+	// Subscriptions lists the namespace/name of the generated subscriptions
+	Subscriptions  []string    `json:"subscriptions,omitempty"`
+	Message        string      `json:"message,omitempty"`
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SubscriptionSet stamps out parameterized subscriptions from a template
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="Updated",type="date",JSONPath=".status.lastUpdateTime"
+// +kubebuilder:resource:shortName=appsubset
+type SubscriptionSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SubscriptionSetSpec   `json:"spec"`
+	Status SubscriptionSetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SubscriptionSetList contains a list of SubscriptionSet
+type SubscriptionSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SubscriptionSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SubscriptionSet{}, &SubscriptionSetList{})
+}
@@ -52,6 +52,61 @@ func (in *AnsibleJobsStatus) DeepCopy() *AnsibleJobsStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplyProgress) DeepCopyInto(out *ApplyProgress) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplyProgress.
+func (in *ApplyProgress) DeepCopy() *ApplyProgress {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplyProgress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChannelRetarget) DeepCopyInto(out *ChannelRetarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChannelRetarget.
+func (in *ChannelRetarget) DeepCopy() *ChannelRetarget {
+	if in == nil {
+		return nil
+	}
+	out := new(ChannelRetarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChannelRetargetStatus) DeepCopyInto(out *ChannelRetargetStatus) {
+	*out = *in
+	if in.AddedPackages != nil {
+		in, out := &in.AddedPackages, &out.AddedPackages
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeletedPackages != nil {
+		in, out := &in.DeletedPackages, &out.DeletedPackages
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChannelRetargetStatus.
+func (in *ChannelRetargetStatus) DeepCopy() *ChannelRetargetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ChannelRetargetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterOverride) DeepCopyInto(out *ClusterOverride) {
 	*out = *in
@@ -115,6 +170,11 @@ func (in *Overrides) DeepCopyInto(out *Overrides) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Condition != nil {
+		in, out := &in.Condition, &out.Condition
+		*out = new(PackageCondition)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Overrides.
@@ -159,6 +219,26 @@ func (in *PackageFilter) DeepCopy() *PackageFilter {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PackageCondition) DeepCopyInto(out *PackageCondition) {
+	*out = *in
+	if in.ClusterSelector != nil {
+		in, out := &in.ClusterSelector, &out.ClusterSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PackageCondition.
+func (in *PackageCondition) DeepCopy() *PackageCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(PackageCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PackageOverride) DeepCopyInto(out *PackageOverride) {
 	*out = *in
@@ -230,6 +310,26 @@ func (in *SubscriberItem) DeepCopy() *SubscriberItem {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubscriberState) DeepCopyInto(out *SubscriberState) {
+	*out = *in
+	if in.Inventory != nil {
+		in, out := &in.Inventory, &out.Inventory
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubscriberState.
+func (in *SubscriberState) DeepCopy() *SubscriberState {
+	if in == nil {
+		return nil
+	}
+	out := new(SubscriberState)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Subscription) DeepCopyInto(out *Subscription) {
 	*out = *in
@@ -348,6 +448,150 @@ func (in *SubscriptionPerClusterStatus) DeepCopy() *SubscriptionPerClusterStatus
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubscriptionSet) DeepCopyInto(out *SubscriptionSet) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubscriptionSet.
+func (in *SubscriptionSet) DeepCopy() *SubscriptionSet {
+	if in == nil {
+		return nil
+	}
+	out := new(SubscriptionSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SubscriptionSet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubscriptionSetGenerator) DeepCopyInto(out *SubscriptionSetGenerator) {
+	*out = *in
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ClusterSelector != nil {
+		in, out := &in.ClusterSelector, &out.ClusterSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubscriptionSetGenerator.
+func (in *SubscriptionSetGenerator) DeepCopy() *SubscriptionSetGenerator {
+	if in == nil {
+		return nil
+	}
+	out := new(SubscriptionSetGenerator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubscriptionSetList) DeepCopyInto(out *SubscriptionSetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SubscriptionSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubscriptionSetList.
+func (in *SubscriptionSetList) DeepCopy() *SubscriptionSetList {
+	if in == nil {
+		return nil
+	}
+	out := new(SubscriptionSetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SubscriptionSetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubscriptionSetSpec) DeepCopyInto(out *SubscriptionSetSpec) {
+	*out = *in
+	in.Generator.DeepCopyInto(&out.Generator)
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubscriptionSetSpec.
+func (in *SubscriptionSetSpec) DeepCopy() *SubscriptionSetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SubscriptionSetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubscriptionSetStatus) DeepCopyInto(out *SubscriptionSetStatus) {
+	*out = *in
+	if in.Subscriptions != nil {
+		in, out := &in.Subscriptions, &out.Subscriptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubscriptionSetStatus.
+func (in *SubscriptionSetStatus) DeepCopy() *SubscriptionSetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SubscriptionSetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubscriptionSource) DeepCopyInto(out *SubscriptionSource) {
+	*out = *in
+	if in.Submodules != nil {
+		in, out := &in.Submodules, &out.Submodules
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubscriptionSource.
+func (in *SubscriptionSource) DeepCopy() *SubscriptionSource {
+	if in == nil {
+		return nil
+	}
+	out := new(SubscriptionSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SubscriptionSpec) DeepCopyInto(out *SubscriptionSpec) {
 	*out = *in
@@ -411,6 +655,16 @@ func (in *SubscriptionSpec) DeepCopyInto(out *SubscriptionSpec) {
 			}
 		}
 	}
+	if in.Retarget != nil {
+		in, out := &in.Retarget, &out.Retarget
+		*out = new(ChannelRetarget)
+		**out = **in
+	}
+	if in.ValuesFrom != nil {
+		in, out := &in.ValuesFrom, &out.ValuesFrom
+		*out = make([]ValuesReference, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubscriptionSpec.
@@ -427,6 +681,42 @@ func (in *SubscriptionSpec) DeepCopy() *SubscriptionSpec {
 func (in *SubscriptionStatus) DeepCopyInto(out *SubscriptionStatus) {
 	*out = *in
 	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+	if in.OutOfSyncSince != nil {
+		in, out := &in.OutOfSyncSince, &out.OutOfSyncSince
+		*out = (*in).DeepCopy()
+	}
+	if in.DesiredCommitSynced != nil {
+		in, out := &in.DesiredCommitSynced, &out.DesiredCommitSynced
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ApplyProgress != nil {
+		in, out := &in.ApplyProgress, &out.ApplyProgress
+		*out = new(ApplyProgress)
+		**out = **in
+	}
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(SubscriptionSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retarget != nil {
+		in, out := &in.Retarget, &out.Retarget
+		*out = new(ChannelRetargetStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SubscriberState != nil {
+		in, out := &in.SubscriberState, &out.SubscriberState
+		*out = new(SubscriberState)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	in.AnsibleJobsStatus.DeepCopyInto(&out.AnsibleJobsStatus)
 	if in.Statuses != nil {
 		in, out := &in.Statuses, &out.Statuses
@@ -455,6 +745,36 @@ func (in *SubscriptionStatus) DeepCopy() *SubscriptionStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubscriptionTemplate) DeepCopyInto(out *SubscriptionTemplate) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubscriptionTemplate.
+func (in *SubscriptionTemplate) DeepCopy() *SubscriptionTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(SubscriptionTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SubscriptionUnitStatus) DeepCopyInto(out *SubscriptionUnitStatus) {
 	*out = *in
@@ -500,3 +820,18 @@ func (in *TimeWindow) DeepCopy() *TimeWindow {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValuesReference) DeepCopyInto(out *ValuesReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValuesReference.
+func (in *ValuesReference) DeepCopy() *ValuesReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ValuesReference)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -66,6 +66,16 @@ const (
 	PackageDeployed PackagePhase = "Deployed"
 	// PackageDeployFailed means this package failed to deploy on the manage cluster
 	PackageDeployFailed PackagePhase = "Failed"
+	// PackageUnhealthy means this package is deployed but doesn't pass its health check yet
+	PackageUnhealthy PackagePhase = "Unhealthy"
+	// PackageWaitingForOperator means this package is not deployed yet, the operator serving its kind is installing
+	PackageWaitingForOperator PackagePhase = "WaitingForOperator"
+	// PackageWaitingForWave means this package is not deployed yet, the packages of a previous sync wave don't pass
+	// their health check yet
+	PackageWaitingForWave PackagePhase = "WaitingForWave"
+	// PackageConflictSkipped means this package is not deployed, it conflicts with an existing resource and its
+	// subscription skips the conflicts
+	PackageConflictSkipped PackagePhase = "ConflictSkipped"
 	// PackagePropagationFailed means this package failed to propagate to the manage cluster
 	PackagePropagationFailed PackagePhase = "PropagationFailed"
 )
@@ -0,0 +1,459 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bundle exports the channels and subscriptions of a hub as a portable bundle, and imports them into
+// another hub, for the hub migrations and the disaster recovery drills.
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	plrv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/placementrule/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// Bundle is a v1 List of the channels and subscriptions of a hub, with the config maps and the redacted secrets their
+// channels and subscriptions reference, and the placements of the subscriptions. The items are in the order they are
+// imported.
+type Bundle struct {
+	APIVersion string                      `json:"apiVersion"`
+	Kind       string                      `json:"kind"`
+	Items      []unstructured.Unstructured `json:"items"`
+}
+
+// ImportOptions are the options of the import of a bundle
+type ImportOptions struct {
+	// Overwrite updates the existing objects with the ones of the bundle, they are kept otherwise
+	Overwrite bool
+	// DryRun reports what the import would do without doing it
+	DryRun bool
+}
+
+var (
+	configMapGVK       = corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	secretGVK          = corev1.SchemeGroupVersion.WithKind("Secret")
+	channelGVK         = chnv1.SchemeGroupVersion.WithKind("Channel")
+	placementRuleGVK   = plrv1.SchemeGroupVersion.WithKind("PlacementRule")
+	placementGVK       = clusterv1beta1.SchemeGroupVersion.WithKind("Placement")
+	subscriptionGVK    = appv1.SchemeGroupVersion.WithKind("Subscription")
+	subscriptionSetGVK = appv1.SchemeGroupVersion.WithKind("SubscriptionSet")
+	namespaceGVK       = corev1.SchemeGroupVersion.WithKind("Namespace")
+
+	// the kinds of a bundle, in their import order, the channels and subscriptions after their references
+	bundleKinds = []schema.GroupVersionKind{configMapGVK, secretGVK, channelGVK, placementRuleGVK, placementGVK,
+		subscriptionGVK, subscriptionSetGVK}
+)
+
+// the metadata of the exported objects, set by the API server or the webhooks of the source hub
+var clusterMetadata = [][]string{
+	{"metadata", "uid"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "deletionTimestamp"},
+	{"metadata", "deletionGracePeriodSeconds"},
+	{"metadata", "selfLink"},
+	{"metadata", "managedFields"},
+	{"metadata", "ownerReferences"},
+	{"metadata", "finalizers"},
+	{"metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration"},
+	{"metadata", "annotations", appv1.AnnotationUserIdentity},
+	{"metadata", "annotations", appv1.AnnotationUserGroup},
+	{"status"},
+}
+
+// Export returns the bundle of the channels and subscriptions of the namespace, of all the namespaces if it is empty.
+// The subscriptions generated by the hub for its own cluster are left out, and the ones generated by a subscription
+// set are exported as their subscription set, the new hub generates them again. The bundle has the config maps and secrets
+// the channels reference, and the placement or placement rule, the package filter config map and the values config
+// maps and secrets the subscriptions and the templates of the subscription sets reference. The values of the secrets are redacted, the importer fills them in.
+func Export(ctx context.Context, clt client.Client, namespace string) (*Bundle, error) {
+	chnList := &chnv1.ChannelList{}
+	if err := clt.List(ctx, chnList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list the channels, err: %w", err)
+	}
+
+	subList := &appv1.SubscriptionList{}
+	if err := clt.List(ctx, subList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list the subscriptions, err: %w", err)
+	}
+
+	setList := &appv1.SubscriptionSetList{}
+	if err := clt.List(ctx, setList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list the subscription sets, err: %w", err)
+	}
+
+	items := map[schema.GroupVersionKind]map[types.NamespacedName]*unstructured.Unstructured{}
+
+	add := func(obj runtime.Object, gvk schema.GroupVersionKind) error {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+
+		item := &unstructured.Unstructured{Object: content}
+		item.SetGroupVersionKind(gvk)
+
+		for _, fields := range clusterMetadata {
+			unstructured.RemoveNestedField(item.Object, fields...)
+		}
+
+		if len(item.GetAnnotations()) == 0 {
+			unstructured.RemoveNestedField(item.Object, "metadata", "annotations")
+		}
+
+		if items[gvk] == nil {
+			items[gvk] = map[types.NamespacedName]*unstructured.Unstructured{}
+		}
+
+		items[gvk][types.NamespacedName{Namespace: item.GetNamespace(), Name: item.GetName()}] = item
+
+		return nil
+	}
+
+	for i := range chnList.Items {
+		chn := &chnList.Items[i]
+
+		if err := add(chn, channelGVK); err != nil {
+			return nil, err
+		}
+
+		owner := "channel " + chn.Name
+
+		if ref := chn.Spec.SecretRef; ref != nil {
+			if err := addReference(ctx, clt, chn.Namespace, ref.Name, owner, &corev1.Secret{}, secretGVK, add); err != nil {
+				return nil, err
+			}
+		}
+
+		if ref := chn.Spec.ConfigMapRef; ref != nil {
+			if err := addReference(ctx, clt, chn.Namespace, ref.Name, owner, &corev1.ConfigMap{}, configMapGVK,
+				add); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i := range subList.Items {
+		sub := &subList.Items[i]
+
+		if _, ok := sub.GetAnnotations()[appv1.AnnotationHosting]; ok {
+			klog.V(1).Infof("Skipping subscription %v/%v, it is generated by the hub", sub.Namespace, sub.Name)
+
+			continue
+		}
+
+		if set, ok := sub.GetLabels()[appv1.LabelSubscriptionSet]; ok {
+			klog.V(1).Infof("Skipping subscription %v/%v, it is generated by subscription set %v", sub.Namespace,
+				sub.Name, set)
+
+			continue
+		}
+
+		if err := add(sub, subscriptionGVK); err != nil {
+			return nil, err
+		}
+
+		if err := addSubscriptionReferences(ctx, clt, sub, add); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range setList.Items {
+		set := &setList.Items[i]
+
+		if err := add(set, subscriptionSetGVK); err != nil {
+			return nil, err
+		}
+
+		// the references of the template are the ones of the generated subscriptions, in the namespace of the set
+		tplSub := &appv1.Subscription{
+			ObjectMeta: metav1.ObjectMeta{Namespace: set.Namespace, Name: set.Name},
+			Spec:       *set.Spec.Template.Spec.DeepCopy(),
+		}
+
+		if err := addSubscriptionReferences(ctx, clt, tplSub, add); err != nil {
+			return nil, err
+		}
+	}
+
+	bundle := &Bundle{APIVersion: "v1", Kind: "List", Items: []unstructured.Unstructured{}}
+
+	for _, gvk := range bundleKinds {
+		keys := make([]types.NamespacedName, 0, len(items[gvk]))
+		for key := range items[gvk] {
+			keys = append(keys, key)
+		}
+
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+		for _, key := range keys {
+			bundle.Items = append(bundle.Items, *items[gvk][key])
+		}
+	}
+
+	return bundle, nil
+}
+
+// addSubscriptionReferences adds the objects of the subscription namespace the subscription references: its
+// placement or placement rule, the config map of its package filter, and the config maps and secrets of its values
+func addSubscriptionReferences(ctx context.Context, clt client.Client, sub *appv1.Subscription,
+	add func(runtime.Object, schema.GroupVersionKind) error) error {
+	owner := "subscription " + sub.Name
+
+	if sub.Spec.Placement != nil && sub.Spec.Placement.PlacementRef != nil && sub.Spec.Placement.PlacementRef.Name != "" {
+		ref := sub.Spec.Placement.PlacementRef
+
+		var err error
+
+		// the placement references without a kind are placement rules, like for the hub subscription controller
+		if ref.Kind == placementGVK.Kind {
+			err = addReference(ctx, clt, sub.Namespace, ref.Name, owner, &clusterv1beta1.Placement{}, placementGVK, add)
+		} else {
+			err = addReference(ctx, clt, sub.Namespace, ref.Name, owner, &plrv1.PlacementRule{}, placementRuleGVK, add)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if filter := sub.Spec.PackageFilter; filter != nil && filter.FilterRef != nil && filter.FilterRef.Name != "" {
+		if err := addReference(ctx, clt, sub.Namespace, filter.FilterRef.Name, owner, &corev1.ConfigMap{},
+			configMapGVK, add); err != nil {
+			return err
+		}
+	}
+
+	for _, values := range sub.Spec.ValuesFrom {
+		var err error
+
+		if values.Kind == secretGVK.Kind {
+			err = addReference(ctx, clt, sub.Namespace, values.Name, owner, &corev1.Secret{}, secretGVK, add)
+		} else {
+			err = addReference(ctx, clt, sub.Namespace, values.Name, owner, &corev1.ConfigMap{}, configMapGVK, add)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addReference adds the object of the namespace the owner references, the secrets redacted. It is left out if it
+// doesn't exist.
+func addReference(ctx context.Context, clt client.Client, namespace, name, owner string, obj client.Object,
+	gvk schema.GroupVersionKind, add func(runtime.Object, schema.GroupVersionKind) error) error {
+	err := clt.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj)
+	if errors.IsNotFound(err) {
+		klog.Warningf("%v %v/%v of %v is not found, it is not exported", gvk.Kind, namespace, name, owner)
+
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to get %v %v/%v of %v, err: %w", gvk.Kind, namespace, name, owner, err)
+	}
+
+	if secret, ok := obj.(*corev1.Secret); ok {
+		redactSecret(secret)
+	}
+
+	return add(obj, gvk)
+}
+
+// redactSecret empties the values of the secret and marks it redacted, its keys tell the importer what to fill in
+func redactSecret(secret *corev1.Secret) {
+	for key := range secret.Data {
+		secret.Data[key] = []byte{}
+	}
+
+	for key := range secret.StringData {
+		secret.StringData[key] = ""
+	}
+
+	annotations := secret.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[appv1.AnnotationRedacted] = "true"
+	secret.SetAnnotations(annotations)
+}
+
+// Marshal returns the YAML of the bundle
+func (b *Bundle) Marshal() ([]byte, error) {
+	return yaml.Marshal(b)
+}
+
+// Parse returns the bundle of the YAML or JSON data. The bundle can only have the kinds of an export.
+func Parse(data []byte) (*Bundle, error) {
+	bundle := &Bundle{}
+	if err := yaml.Unmarshal(data, bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse the bundle, err: %w", err)
+	}
+
+	if bundle.APIVersion != "v1" || bundle.Kind != "List" {
+		return nil, fmt.Errorf("invalid bundle %v %v, expecting a v1 List", bundle.APIVersion, bundle.Kind)
+	}
+
+	for _, item := range bundle.Items {
+		if importOrder(item.GroupVersionKind()) < 0 {
+			return nil, fmt.Errorf("unexpected %v %v/%v in the bundle", item.GroupVersionKind(), item.GetNamespace(),
+				item.GetName())
+		}
+
+		if item.GetNamespace() == "" || item.GetName() == "" {
+			return nil, fmt.Errorf("%v %q of the bundle has no namespace or name", item.GetKind(), item.GetName())
+		}
+	}
+
+	return bundle, nil
+}
+
+func importOrder(gvk schema.GroupVersionKind) int {
+	for i, kind := range bundleKinds {
+		if kind == gvk {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Import creates the objects of the bundle, and their missing namespaces, in the order of their kinds. The existing
+// objects are kept, or updated with the Overwrite option, except the secrets existing on the hub, which are always
+// kept over the redacted ones. It returns the report of the import, one line per object.
+func Import(ctx context.Context, clt client.Client, bundle *Bundle, options ImportOptions) ([]string, error) {
+	items := make([]unstructured.Unstructured, len(bundle.Items))
+	copy(items, bundle.Items)
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return importOrder(items[i].GroupVersionKind()) < importOrder(items[j].GroupVersionKind())
+	})
+
+	report := []string{}
+	namespaces := map[string]bool{}
+
+	for i := range items {
+		item := &items[i]
+
+		if !namespaces[item.GetNamespace()] {
+			created, err := ensureNamespace(ctx, clt, item.GetNamespace(), options.DryRun)
+			if err != nil {
+				return report, err
+			}
+
+			if created {
+				report = append(report, fmt.Sprintf("Namespace %v created", item.GetNamespace()))
+			}
+
+			namespaces[item.GetNamespace()] = true
+		}
+
+		line, err := importItem(ctx, clt, item, options)
+		if err != nil {
+			return report, err
+		}
+
+		report = append(report, line)
+	}
+
+	return report, nil
+}
+
+func ensureNamespace(ctx context.Context, clt client.Client, namespace string, dryRun bool) (bool, error) {
+	ns := &unstructured.Unstructured{}
+	ns.SetGroupVersionKind(namespaceGVK)
+
+	err := clt.Get(ctx, types.NamespacedName{Name: namespace}, ns)
+	if err == nil {
+		return false, nil
+	}
+
+	if !errors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to get namespace %v, err: %w", namespace, err)
+	}
+
+	if dryRun {
+		return true, nil
+	}
+
+	ns.SetName(namespace)
+
+	if err := clt.Create(ctx, ns); err != nil && !errors.IsAlreadyExists(err) {
+		return false, fmt.Errorf("failed to create namespace %v, err: %w", namespace, err)
+	}
+
+	return true, nil
+}
+
+func importItem(ctx context.Context, clt client.Client, item *unstructured.Unstructured,
+	options ImportOptions) (string, error) {
+	desc := fmt.Sprintf("%v %v/%v", item.GetKind(), item.GetNamespace(), item.GetName())
+	redacted := item.GetAnnotations()[appv1.AnnotationRedacted] == "true"
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(item.GroupVersionKind())
+
+	err := clt.Get(ctx, types.NamespacedName{Namespace: item.GetNamespace(), Name: item.GetName()}, existing)
+
+	switch {
+	case errors.IsNotFound(err):
+		if !options.DryRun {
+			if err := clt.Create(ctx, item); err != nil {
+				return "", fmt.Errorf("failed to create %v, err: %w", desc, err)
+			}
+		}
+
+		if redacted {
+			return desc + " created, its redacted values must be filled in", nil
+		}
+
+		return desc + " created", nil
+	case err != nil:
+		return "", fmt.Errorf("failed to get %v, err: %w", desc, err)
+	case redacted:
+		return desc + " kept, it is redacted in the bundle", nil
+	case !options.Overwrite:
+		return desc + " kept, it already exists", nil
+	}
+
+	item.SetResourceVersion(existing.GetResourceVersion())
+
+	if !options.DryRun {
+		if err := clt.Update(ctx, item); err != nil {
+			return "", fmt.Errorf("failed to update %v, err: %w", desc, err)
+		}
+	}
+
+	return desc + " updated", nil
+}
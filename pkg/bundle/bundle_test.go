@@ -0,0 +1,297 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/apis"
+	plrv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/placementrule/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func newScheme(g *gomega.WithT) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(gomega.Succeed())
+	g.Expect(apis.AddToScheme(scheme)).To(gomega.Succeed())
+
+	return scheme
+}
+
+func TestExportImport(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	chn := &chnv1.Channel{
+		ObjectMeta: metav1.ObjectMeta{Name: "git", Namespace: "channels", ResourceVersion: "7", UID: "chn-uid"},
+		Spec: chnv1.ChannelSpec{
+			Type:         chnv1.ChannelTypeGit,
+			Pathname:     "https://github.com/example/apps.git",
+			SecretRef:    &corev1.ObjectReference{Name: "git-creds"},
+			ConfigMapRef: &corev1.ObjectReference{Name: "git-ca"},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "git-creds", Namespace: "channels"},
+		Data:       map[string][]byte{"user": []byte("admin"), "accessToken": []byte("s3cr3t")},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "git-ca", Namespace: "channels"},
+		Data:       map[string]string{"caCerts": "-----BEGIN CERTIFICATE-----"},
+	}
+	sub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps", Annotations: map[string]string{
+			appv1.AnnotationGitBranch:    "main",
+			appv1.AnnotationUserIdentity: "a3ViZTphZG1pbg==",
+		}},
+		Spec:   appv1.SubscriptionSpec{Channel: "channels/git"},
+		Status: appv1.SubscriptionStatus{Phase: appv1.SubscriptionPropagated},
+	}
+	// the subscription of the hub for its own cluster
+	localSub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-local", Namespace: "apps", Annotations: map[string]string{
+			appv1.AnnotationHosting: "apps/app",
+		}},
+		Spec: appv1.SubscriptionSpec{Channel: "channels/git"},
+	}
+	set := &appv1.SubscriptionSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "guestbook", Namespace: "apps"},
+		Spec: appv1.SubscriptionSetSpec{
+			Generator: appv1.SubscriptionSetGenerator{Clusters: []string{"cluster1"}},
+			Template:  appv1.SubscriptionTemplate{Spec: appv1.SubscriptionSpec{Channel: "channels/git"}},
+		},
+		Status: appv1.SubscriptionSetStatus{Subscriptions: []string{"apps/guestbook-cluster1"}},
+	}
+	// the subscription generated by the subscription set
+	setSub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{Name: "guestbook-cluster1", Namespace: "apps", Labels: map[string]string{
+			appv1.LabelSubscriptionSet: "apps.guestbook",
+		}},
+		Spec: appv1.SubscriptionSpec{Channel: "channels/git"},
+	}
+
+	source := fake.NewClientBuilder().WithScheme(newScheme(g)).
+		WithObjects(chn, secret, configMap, sub, localSub, set, setSub).Build()
+
+	b, err := Export(context.TODO(), source, "")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	kinds := []string{}
+	for _, item := range b.Items {
+		kinds = append(kinds, item.GetKind()+" "+item.GetNamespace()+"/"+item.GetName())
+	}
+
+	g.Expect(kinds).To(gomega.Equal([]string{
+		"ConfigMap channels/git-ca", "Secret channels/git-creds", "Channel channels/git", "Subscription apps/app",
+		"SubscriptionSet apps/guestbook",
+	}))
+
+	data, err := b.Marshal()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(data)).NotTo(gomega.ContainSubstring("s3cr3t"))
+	g.Expect(string(data)).NotTo(gomega.ContainSubstring("resourceVersion"))
+	g.Expect(string(data)).NotTo(gomega.ContainSubstring(appv1.AnnotationUserIdentity))
+	g.Expect(string(data)).NotTo(gomega.ContainSubstring("Propagated"))
+	g.Expect(string(data)).NotTo(gomega.ContainSubstring("guestbook-cluster1"))
+
+	b, err = Parse(data)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// the target hub already has the secret with its own values
+	targetSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "git-creds", Namespace: "channels"},
+		Data:       map[string][]byte{"user": []byte("admin"), "accessToken": []byte("n3w")},
+	}
+	target := fake.NewClientBuilder().WithScheme(newScheme(g)).
+		WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "channels"}}, targetSecret).Build()
+
+	report, err := Import(context.TODO(), target, b, ImportOptions{DryRun: true})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(report).To(gomega.ContainElement("Namespace apps created"))
+	g.Expect(target.Get(context.TODO(), types.NamespacedName{Name: "apps"}, &corev1.Namespace{})).NotTo(gomega.Succeed())
+
+	report, err = Import(context.TODO(), target, b, ImportOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(report).To(gomega.Equal([]string{
+		"ConfigMap channels/git-ca created",
+		"Secret channels/git-creds kept, it is redacted in the bundle",
+		"Channel channels/git created",
+		"Namespace apps created",
+		"Subscription apps/app created",
+		"SubscriptionSet apps/guestbook created",
+	}))
+
+	imported := &appv1.Subscription{}
+	g.Expect(target.Get(context.TODO(), types.NamespacedName{Namespace: "apps", Name: "app"}, imported)).To(gomega.Succeed())
+	g.Expect(imported.Spec.Channel).To(gomega.Equal("channels/git"))
+	g.Expect(imported.GetAnnotations()[appv1.AnnotationGitBranch]).To(gomega.Equal("main"))
+
+	g.Expect(target.Get(context.TODO(), client.ObjectKeyFromObject(targetSecret), targetSecret)).To(gomega.Succeed())
+	g.Expect(string(targetSecret.Data["accessToken"])).To(gomega.Equal("n3w"))
+
+	// the existing objects are kept unless overwritten
+	report, err = Import(context.TODO(), target, b, ImportOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(report).To(gomega.ContainElement("Subscription apps/app kept, it already exists"))
+
+	report, err = Import(context.TODO(), target, b, ImportOptions{Overwrite: true})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(report).To(gomega.ContainElement("Subscription apps/app updated"))
+}
+
+func TestExportImportSubscriptionReferences(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	rule := &plrv1.PlacementRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-clusters", Namespace: "apps"},
+		Spec: plrv1.PlacementRuleSpec{
+			GenericPlacementFields: plrv1.GenericPlacementFields{
+				ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "dev"}},
+			},
+		},
+		Status: plrv1.PlacementRuleStatus{Decisions: []plrv1.PlacementDecision{{ClusterName: "cluster1"}}},
+	}
+	placement := &clusterv1beta1.Placement{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod-clusters", Namespace: "apps"},
+		Spec:       clusterv1beta1.PlacementSpec{ClusterSets: []string{"prod"}},
+	}
+	filter := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-filter", Namespace: "apps"},
+		Data:       map[string]string{"path": "apps/"},
+	}
+	values := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-values", Namespace: "apps"},
+		Data:       map[string]string{"values.yaml": "replicas: 2"},
+	}
+	secretValues := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-secret-values", Namespace: "apps"},
+		Data:       map[string][]byte{"values.yaml": []byte("password: s3cr3t")},
+	}
+	devSub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-dev", Namespace: "apps"},
+		Spec: appv1.SubscriptionSpec{
+			Channel:       "apps/helm",
+			Placement:     &plrv1.Placement{PlacementRef: &corev1.ObjectReference{Name: "dev-clusters"}},
+			PackageFilter: &appv1.PackageFilter{FilterRef: &corev1.LocalObjectReference{Name: "app-filter"}},
+			ValuesFrom: []appv1.ValuesReference{
+				{Kind: "ConfigMap", Name: "app-values"},
+				{Kind: "Secret", Name: "app-secret-values"},
+				{Kind: "ConfigMap", Name: "missing-values", Optional: true},
+			},
+		},
+	}
+	prodSub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-prod", Namespace: "apps"},
+		Spec: appv1.SubscriptionSpec{
+			Channel:   "apps/helm",
+			Placement: &plrv1.Placement{PlacementRef: &corev1.ObjectReference{Kind: "Placement", Name: "prod-clusters"}},
+		},
+	}
+
+	source := fake.NewClientBuilder().WithScheme(newScheme(g)).
+		WithObjects(rule, placement, filter, values, secretValues, devSub, prodSub).Build()
+
+	b, err := Export(context.TODO(), source, "apps")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	data, err := b.Marshal()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(data)).NotTo(gomega.ContainSubstring("s3cr3t"))
+	g.Expect(string(data)).NotTo(gomega.ContainSubstring("cluster1"))
+
+	b, err = Parse(data)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	target := fake.NewClientBuilder().WithScheme(newScheme(g)).Build()
+
+	report, err := Import(context.TODO(), target, b, ImportOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(report).To(gomega.Equal([]string{
+		"Namespace apps created",
+		"ConfigMap apps/app-filter created",
+		"ConfigMap apps/app-values created",
+		"Secret apps/app-secret-values created, its redacted values must be filled in",
+		"PlacementRule apps/dev-clusters created",
+		"Placement apps/prod-clusters created",
+		"Subscription apps/app-dev created",
+		"Subscription apps/app-prod created",
+	}))
+
+	importedRule := &plrv1.PlacementRule{}
+	g.Expect(target.Get(context.TODO(), client.ObjectKeyFromObject(rule), importedRule)).To(gomega.Succeed())
+	g.Expect(importedRule.Spec.ClusterSelector.MatchLabels).To(gomega.Equal(map[string]string{"env": "dev"}))
+	g.Expect(importedRule.Status.Decisions).To(gomega.BeEmpty())
+
+	importedPlacement := &clusterv1beta1.Placement{}
+	g.Expect(target.Get(context.TODO(), client.ObjectKeyFromObject(placement), importedPlacement)).To(gomega.Succeed())
+	g.Expect(importedPlacement.Spec.ClusterSets).To(gomega.Equal([]string{"prod"}))
+
+	importedValues := &corev1.ConfigMap{}
+	g.Expect(target.Get(context.TODO(), client.ObjectKeyFromObject(values), importedValues)).To(gomega.Succeed())
+	g.Expect(importedValues.Data).To(gomega.Equal(values.Data))
+
+	importedSecret := &corev1.Secret{}
+	g.Expect(target.Get(context.TODO(), client.ObjectKeyFromObject(secretValues), importedSecret)).To(gomega.Succeed())
+	g.Expect(importedSecret.Data).To(gomega.HaveKey("values.yaml"))
+	g.Expect(importedSecret.Data["values.yaml"]).To(gomega.BeEmpty())
+	g.Expect(importedSecret.GetAnnotations()[appv1.AnnotationRedacted]).To(gomega.Equal("true"))
+
+	importedSub := &appv1.Subscription{}
+	g.Expect(target.Get(context.TODO(), client.ObjectKeyFromObject(devSub), importedSub)).To(gomega.Succeed())
+	g.Expect(importedSub.Spec.ValuesFrom).To(gomega.Equal(devSub.Spec.ValuesFrom))
+}
+
+func TestParse(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	_, err := Parse([]byte("apiVersion: v1\nkind: ConfigMap\n"))
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	_, err = Parse([]byte(`apiVersion: v1
+kind: List
+items:
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRoleBinding
+  metadata:
+    name: admin
+`))
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	b, err := Parse([]byte(`apiVersion: v1
+kind: List
+items:
+- apiVersion: apps.open-cluster-management.io/v1
+  kind: Channel
+  metadata:
+    name: helm
+    namespace: channels
+  spec:
+    type: HelmRepo
+    pathname: https://charts.example.com
+`))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(b.Items).To(gomega.HaveLen(1))
+}
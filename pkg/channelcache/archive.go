@@ -0,0 +1,186 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package channelcache
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeArchive writes the content of dir, without the .git folder, to w as a gzipped tarball. The symlinks are kept
+// as symlinks if they point within dir.
+func writeArchive(w io.Writer, dir string) error {
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		link := ""
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			// the links out of the repository are not served, like they are not followed in a clone
+			if !isLinkWithin(dir, path, link) {
+				return nil
+			}
+		case !info.IsDir() && !info.Mode().IsRegular():
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = filepath.ToSlash(rel)
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if info.IsDir() || link != "" {
+			return nil
+		}
+
+		f, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gzw.Close()
+}
+
+// extractArchive extracts the gzipped tarball from r into dir
+func extractArchive(r io.Reader, dir string) error {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+
+	root := filepath.Clean(dir) + string(os.PathSeparator)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(hdr.Name)) // #nosec G305 the target is checked against the root below
+
+		if !strings.HasPrefix(target, root) {
+			return fmt.Errorf("invalid file path %v in channel cache archive", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0750); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := extractFile(tr, target, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if !isLinkWithin(dir, target, hdr.Linkname) {
+				return fmt.Errorf("invalid symlink %v to %v in channel cache archive", hdr.Name, hdr.Linkname)
+			}
+
+			if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+				return err
+			}
+
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// isLinkWithin returns true if the relative symlink at path points within dir
+func isLinkWithin(dir, path, link string) bool {
+	if filepath.IsAbs(link) {
+		return false
+	}
+
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Join(filepath.Dir(path), link))
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+func extractFile(r io.Reader, target string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Clean(target), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	_, err = io.Copy(f, r) // #nosec G110 the archive comes from the authenticated hub cache
+
+	return err
+}
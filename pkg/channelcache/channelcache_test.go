@@ -0,0 +1,143 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package channelcache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+func TestArchive(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	src, err := ioutil.TempDir("", "channelcache-src")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(src)
+
+	g.Expect(os.MkdirAll(filepath.Join(src, ".git"), 0750)).To(gomega.Succeed())
+	g.Expect(os.MkdirAll(filepath.Join(src, "guestbook", "base"), 0750)).To(gomega.Succeed())
+	g.Expect(ioutil.WriteFile(filepath.Join(src, ".git", "HEAD"), []byte("ref: refs/heads/main"), 0600)).To(gomega.Succeed())
+	g.Expect(ioutil.WriteFile(filepath.Join(src, "guestbook", "base", "deployment.yaml"), []byte("kind: Deployment"), 0600)).To(gomega.Succeed())
+
+	// the links within the repo are kept, the ones out of it are dropped
+	g.Expect(os.Symlink("base", filepath.Join(src, "guestbook", "prod"))).To(gomega.Succeed())
+	g.Expect(os.Symlink("../../etc", filepath.Join(src, "guestbook", "etc"))).To(gomega.Succeed())
+	g.Expect(os.Symlink("/etc/passwd", filepath.Join(src, "passwd"))).To(gomega.Succeed())
+
+	buf := &bytes.Buffer{}
+	g.Expect(writeArchive(buf, src)).To(gomega.Succeed())
+
+	dest, err := ioutil.TempDir("", "channelcache-dest")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dest)
+
+	g.Expect(extractArchive(buf, dest)).To(gomega.Succeed())
+
+	content, err := ioutil.ReadFile(filepath.Join(dest, "guestbook", "base", "deployment.yaml"))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(content)).To(gomega.Equal("kind: Deployment"))
+
+	_, err = os.Stat(filepath.Join(dest, ".git"))
+	g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+
+	link, err := os.Readlink(filepath.Join(dest, "guestbook", "prod"))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(link).To(gomega.Equal("base"))
+
+	for _, dropped := range []string{filepath.Join("guestbook", "etc"), "passwd"} {
+		_, err = os.Lstat(filepath.Join(dest, dropped))
+		g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+	}
+
+	g.Expect(isLinkWithin(dest, filepath.Join(dest, "a", "b"), "../c")).To(gomega.BeTrue())
+	g.Expect(isLinkWithin(dest, filepath.Join(dest, "a", "b"), "../../c")).To(gomega.BeFalse())
+}
+
+func TestChannelPath(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	chnKey := types.NamespacedName{Namespace: "ch-git", Name: "git"}
+	opts := &utils.GitCloneOption{
+		Branch:     utils.GetSubscriptionBranchRef("main"),
+		CloneDepth: 1,
+	}
+
+	u := getGitRepoURL("https://cache.example.com", chnKey, opts)
+	g.Expect(u).To(gomega.Equal("https://cache.example.com/git/ch-git/git?branch=refs%2Fheads%2Fmain&depth=1"))
+
+	parsed, err := parseChannelPath("/git/ch-git/git")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(parsed).To(gomega.Equal(chnKey))
+
+	_, err = parseChannelPath("/git/ch-git")
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	_, err = parseChannelPath("/helm/ch-git/git")
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestCacheEviction(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	cacheDir, err := ioutil.TempDir("", "channelcache-entries")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(cacheDir)
+
+	s := &Server{cacheDir: cacheDir, idleTTL: time.Hour, entries: map[string]*cacheEntry{}}
+	chnKey := types.NamespacedName{Namespace: "ch-git", Name: "git"}
+
+	first := s.getEntry(chnKey, "main", "", "", "", 1)
+	g.Expect(os.MkdirAll(first.dir, 0750)).To(gomega.Succeed())
+	g.Expect(s.getEntry(chnKey, "main", "", "", "", 1)).To(gomega.BeIdenticalTo(first))
+
+	// the distinct refs of a channel are bounded, the least recently used clone is removed
+	for i := 1; i <= maxRefsPerChannel; i++ {
+		s.getEntry(chnKey, "", strconv.Itoa(i), "", "", 1)
+	}
+
+	g.Eventually(func() bool {
+		_, err := os.Stat(first.dir)
+
+		return os.IsNotExist(err)
+	}).Should(gomega.BeTrue())
+	g.Expect(s.entries).To(gomega.HaveLen(maxRefsPerChannel))
+	g.Expect(s.getEntry(chnKey, "main", "", "", "", 1)).NotTo(gomega.BeIdenticalTo(first))
+
+	// the idle clones are removed
+	for _, entry := range s.entries {
+		entry.used = time.Now().Add(-2 * time.Hour)
+	}
+
+	s.removeEntries(s.evictIdleEntries())
+	g.Expect(s.entries).To(gomega.BeEmpty())
+
+	first.mtx.Lock()
+	defer first.mtx.Unlock()
+
+	g.Expect(first.evicted).To(gomega.BeTrue())
+}
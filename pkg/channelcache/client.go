@@ -0,0 +1,186 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package channelcache
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+type clientConfig struct {
+	url       string
+	tokenFile string
+	caFile    string
+}
+
+var cacheClientCfg clientConfig
+
+// SetClient makes the git subscriber fetch the channel content from the hub channel cache at cacheURL.
+// The token in tokenFile must be allowed to get the channels; caFile verifies the cache server certificate.
+func SetClient(cacheURL, tokenFile, caFile string) {
+	cacheClientCfg = clientConfig{
+		url:       strings.TrimSuffix(cacheURL, "/"),
+		tokenFile: tokenFile,
+		caFile:    caFile,
+	}
+
+	if cacheClientCfg.url != "" {
+		klog.Info("git channels are fetched from the hub channel cache ", cacheClientCfg.url)
+	}
+}
+
+// IsClientEnabled returns true if the channel content is fetched from the hub channel cache
+func IsClientEnabled() bool {
+	return cacheClientCfg.url != ""
+}
+
+// FetchGitRepo downloads the content of the git channel chnKey from the hub channel cache into opts.DestDir.
+// It returns where the content comes from and its commit author, if known. The download is aborted when ctx is done.
+func FetchGitRepo(ctx context.Context, chnKey types.NamespacedName, opts *utils.GitCloneOption) (*utils.GitSource, *utils.GitCommitAuthor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, getGitRepoURL(cacheClientCfg.url, chnKey, opts), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cacheClientCfg.tokenFile != "" {
+		token, err := ioutil.ReadFile(filepath.Clean(cacheClientCfg.tokenFile))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	httpClient, err := getHTTPClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+
+		return nil, nil, fmt.Errorf("channel cache returned %v: %v", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if err := os.RemoveAll(opts.DestDir); err != nil {
+		return nil, nil, err
+	}
+
+	if err := os.MkdirAll(opts.DestDir, 0750); err != nil {
+		return nil, nil, err
+	}
+
+	if err := extractArchive(resp.Body, opts.DestDir); err != nil {
+		return nil, nil, err
+	}
+
+	var author *utils.GitCommitAuthor
+
+	if resp.Header.Get(HeaderCommitAuthorEmail) != "" || resp.Header.Get(HeaderCommitAuthorName) != "" {
+		author = &utils.GitCommitAuthor{
+			Name:  resp.Header.Get(HeaderCommitAuthorName),
+			Email: resp.Header.Get(HeaderCommitAuthorEmail),
+		}
+	}
+
+	source := &utils.GitSource{
+		URL:          resp.Header.Get(HeaderSourceURL),
+		Ref:          resp.Header.Get(HeaderSourceRef),
+		Commit:       resp.Header.Get(HeaderCommitID),
+		Submodules:   utils.ParseSubmoduleCommits(resp.Header.Get(HeaderSubmoduleCommits)),
+		ChannelCache: cacheClientCfg.url,
+	}
+
+	return source, author, nil
+}
+
+func getGitRepoURL(cacheURL string, chnKey types.NamespacedName, opts *utils.GitCloneOption) string {
+	q := url.Values{}
+
+	if opts.Branch != "" {
+		q.Set("branch", opts.Branch.String())
+	}
+
+	if opts.CommitHash != "" {
+		q.Set("commit", opts.CommitHash)
+	}
+
+	if opts.RevisionTag != "" {
+		q.Set("tag", opts.RevisionTag)
+	}
+
+	if opts.RevisionTagConstraint != "" {
+		q.Set("tagConstraint", opts.RevisionTagConstraint)
+	}
+
+	if opts.CloneDepth > 0 {
+		q.Set("depth", strconv.Itoa(opts.CloneDepth))
+	}
+
+	u := cacheURL + gitPathPrefix + url.PathEscape(chnKey.Namespace) + "/" + url.PathEscape(chnKey.Name)
+
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	return u
+}
+
+func getHTTPClient() (*http.Client, error) {
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         utils.NewFetchDialer().DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+
+	if cacheClientCfg.caFile != "" {
+		caCert, err := ioutil.ReadFile(filepath.Clean(cacheClientCfg.caFile))
+		if err != nil {
+			return nil, err
+		}
+
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificate found in %v", cacheClientCfg.caFile)
+		}
+
+		transport.TLSClientConfig.RootCAs = certPool
+	}
+
+	return &http.Client{Transport: transport, Timeout: 5 * time.Minute}, nil
+}
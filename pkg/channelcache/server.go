@@ -0,0 +1,453 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package channelcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	authzv1 "k8s.io/api/authorization/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+const (
+	// HeaderCommitID carries the commit ID of the served channel content
+	HeaderCommitID = "X-Commit-Id"
+	// HeaderCommitAuthorName carries the author name of the commit, the archive has no git history to look it up
+	HeaderCommitAuthorName = "X-Commit-Author-Name"
+	// HeaderCommitAuthorEmail carries the author email of the commit
+	HeaderCommitAuthorEmail = "X-Commit-Author-Email"
+	// HeaderSourceURL carries the URL the repository was cloned from, after the HTTP redirects
+	HeaderSourceURL = "X-Source-Url"
+	// HeaderSourceRef carries the branch or tag reference the commit was read from
+	HeaderSourceRef = "X-Source-Ref"
+	// HeaderSubmoduleCommits carries the commits of the submodules, as a comma separated list of path=commit
+	HeaderSubmoduleCommits = "X-Submodule-Commits"
+
+	gitPathPrefix   = "/git/"
+	defaultCacheTTL = 3 * time.Minute
+
+	// the clones not requested for defaultIdleTTL are removed, and there are at most maxEntries clones, at most
+	// maxRefsPerChannel of them for the same channel. The least recently used clones are removed first.
+	defaultIdleTTL    = time.Hour
+	maxEntries        = 256
+	maxRefsPerChannel = 16
+)
+
+// Server is the hub side read-through cache of the channel content. It clones the git channels on behalf of the
+// managed clusters, so a repository is cloned once per cache period from the git provider.
+type Server struct {
+	client.Client
+	authClient kubernetes.Interface
+	address    string
+	tlsKeyFile string
+	tlsCrtFile string
+	disableTLS bool
+	cacheDir   string
+	ttl        time.Duration
+	idleTTL    time.Duration
+	mtx        sync.Mutex
+	entries    map[string]*cacheEntry
+	// entrySeq makes the clone directories unique, the clone of an evicted entry is removed after a new entry of the
+	// same key is made
+	entrySeq uint64
+}
+
+type cacheEntry struct {
+	mtx      sync.Mutex
+	dir      string
+	chnKey   types.NamespacedName
+	used     time.Time
+	evicted  bool
+	commitID string
+	// chnSpec is the channel spec of the clone, the clone is stale once the channel spec changes
+	chnSpec chnv1.ChannelSpec
+	source  *utils.GitSource
+	author  *utils.GitCommitAuthor
+	fetched time.Time
+}
+
+// Add creates the channel cache server and adds it to the manager, the server listens on address.
+func Add(mgr manager.Manager, address, tlsKeyFile, tlsCrtFile string, disableTLS bool) error {
+	authClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+
+	s := &Server{
+		Client:     mgr.GetClient(),
+		authClient: authClient,
+		address:    address,
+		tlsKeyFile: tlsKeyFile,
+		tlsCrtFile: tlsCrtFile,
+		disableTLS: disableTLS,
+		cacheDir:   filepath.Join(os.TempDir(), "channel-cache"),
+		ttl:        defaultCacheTTL,
+		idleTTL:    defaultIdleTTL,
+		entries:    map[string]*cacheEntry{},
+	}
+
+	return mgr.Add(s)
+}
+
+// Start serves the channel content until the context is done, this will be triggered by the manager.
+func (s *Server) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.address,
+		Handler:           s,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.idleTTL / 4)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.removeEntries(s.evictIdleEntries())
+			}
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+
+		if err := srv.Shutdown(context.TODO()); err != nil {
+			klog.Error("failed to shut down the channel cache server, err: ", err)
+		}
+	}()
+
+	klog.Info("starting the channel cache server on ", s.address)
+
+	var err error
+
+	if s.disableTLS {
+		err = srv.ListenAndServe()
+	} else {
+		err = utils.ListenAndServeTLS(srv, s.tlsCrtFile, s.tlsKeyFile)
+	}
+
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// NeedLeaderElection makes the cache available on every replica
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// ServeHTTP serves GET /git/<channel namespace>/<channel name>?branch=&commit=&tag=&tagConstraint=&depth= as a gzipped tarball
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	chnKey, err := parseChannelPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+
+		return
+	}
+
+	if code, err := s.authorize(r, chnKey); err != nil {
+		klog.Infof("channel cache request for %v denied: %v", chnKey, err)
+		http.Error(w, err.Error(), code)
+
+		return
+	}
+
+	chn := &chnv1.Channel{}
+	if err := s.Get(r.Context(), chnKey, chn); err != nil {
+		code := http.StatusInternalServerError
+		if k8serrors.IsNotFound(err) {
+			code = http.StatusNotFound
+		}
+
+		http.Error(w, err.Error(), code)
+
+		return
+	}
+
+	if !utils.IsGitChannel(string(chn.Spec.Type)) {
+		http.Error(w, "channel "+chnKey.String()+" is not a git channel", http.StatusBadRequest)
+
+		return
+	}
+
+	q := r.URL.Query()
+	depth, _ := strconv.Atoi(q.Get("depth"))
+
+	var entry *cacheEntry
+
+	// an entry evicted while waiting for its lock has no clone anymore, a new one is made
+	for {
+		entry = s.getEntry(chnKey, q.Get("branch"), q.Get("commit"), q.Get("tag"), q.Get("tagConstraint"), depth)
+
+		entry.mtx.Lock()
+
+		if !entry.evicted {
+			break
+		}
+
+		entry.mtx.Unlock()
+	}
+
+	defer entry.mtx.Unlock()
+
+	if entry.commitID == "" || time.Since(entry.fetched) > s.ttl || !reflect.DeepEqual(entry.chnSpec, chn.Spec) {
+		opts, err := s.getCloneOptions(chn, q.Get("branch"), q.Get("commit"), q.Get("tag"), q.Get("tagConstraint"), depth)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		opts.DestDir = entry.dir
+
+		source, err := utils.CloneGitRepoSource(context.TODO(), opts)
+		if err != nil {
+			entry.commitID = ""
+
+			http.Error(w, err.Error(), http.StatusBadGateway)
+
+			return
+		}
+
+		commitID := source.Commit
+
+		klog.Infof("channel cache refreshed %v branch %v at commit %v", chnKey, opts.Branch, commitID)
+
+		author, err := utils.GetGitCommitAuthor(entry.dir, commitID)
+		if err != nil {
+			klog.Warningf("failed to get the author of commit %v of channel %v, err: %v", commitID, chnKey, err)
+		}
+
+		entry.commitID = commitID
+		entry.chnSpec = *chn.Spec.DeepCopy()
+		entry.source = source
+		entry.author = author
+		entry.fetched = time.Now()
+	}
+
+	w.Header().Set(HeaderCommitID, entry.commitID)
+	w.Header().Set(HeaderSourceURL, entry.source.URL)
+	w.Header().Set(HeaderSourceRef, entry.source.Ref)
+
+	if len(entry.source.Submodules) > 0 {
+		w.Header().Set(HeaderSubmoduleCommits, utils.FormatSubmoduleCommits(entry.source.Submodules))
+	}
+
+	if entry.author != nil {
+		w.Header().Set(HeaderCommitAuthorName, entry.author.Name)
+		w.Header().Set(HeaderCommitAuthorEmail, entry.author.Email)
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+
+	if err := writeArchive(w, entry.dir); err != nil {
+		klog.Errorf("failed to send channel cache content of %v, err: %v", chnKey, err)
+	}
+}
+
+func parseChannelPath(path string) (types.NamespacedName, error) {
+	if !strings.HasPrefix(path, gitPathPrefix) {
+		return types.NamespacedName{}, fmt.Errorf("unknown path %v", path)
+	}
+
+	parsed := strings.Split(strings.Trim(strings.TrimPrefix(path, gitPathPrefix), "/"), "/")
+	if len(parsed) != 2 || parsed[0] == "" || parsed[1] == "" {
+		return types.NamespacedName{}, fmt.Errorf("invalid channel path %v", path)
+	}
+
+	return types.NamespacedName{Namespace: parsed[0], Name: parsed[1]}, nil
+}
+
+// authorize checks the bearer token of the request can get the channel
+func (s *Server) authorize(r *http.Request, chnKey types.NamespacedName) (int, error) {
+	return utils.AuthorizeBearerToken(r, s.authClient, authzv1.ResourceAttributes{
+		Namespace: chnKey.Namespace,
+		Verb:      "get",
+		Group:     chnv1.SchemeGroupVersion.Group,
+		Resource:  "channels",
+		Name:      chnKey.Name,
+	})
+}
+
+func (s *Server) getEntry(chnKey types.NamespacedName, branch, commit, tag, tagConstraint string, depth int) *cacheEntry {
+	key := fmt.Sprintf("%v/%v/%v/%v/%v/%v", chnKey, branch, commit, tag, tagConstraint, depth)
+
+	s.mtx.Lock()
+
+	if entry, ok := s.entries[key]; ok {
+		entry.used = time.Now()
+		s.mtx.Unlock()
+
+		return entry
+	}
+
+	evicted := s.evictIdleEntriesLocked()
+	evicted = append(evicted, s.evictLRUEntriesLocked(chnKey)...)
+
+	s.entrySeq++
+
+	entry := &cacheEntry{
+		dir:    filepath.Join(s.cacheDir, fmt.Sprintf("%x-%d", sha256.Sum256([]byte(key)), s.entrySeq)),
+		chnKey: chnKey,
+		used:   time.Now(),
+	}
+	s.entries[key] = entry
+
+	s.mtx.Unlock()
+
+	go s.removeEntries(evicted)
+
+	return entry
+}
+
+func (s *Server) evictIdleEntries() []*cacheEntry {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.evictIdleEntriesLocked()
+}
+
+// evictIdleEntriesLocked removes the entries not used for the idle TTL from the cache, s.mtx must be held
+func (s *Server) evictIdleEntriesLocked() []*cacheEntry {
+	evicted := []*cacheEntry{}
+
+	for key, entry := range s.entries {
+		if time.Since(entry.used) > s.idleTTL {
+			delete(s.entries, key)
+
+			evicted = append(evicted, entry)
+		}
+	}
+
+	return evicted
+}
+
+// evictLRUEntriesLocked removes the least recently used entries from the cache to make room for a new entry of the
+// channel, s.mtx must be held
+func (s *Server) evictLRUEntriesLocked(chnKey types.NamespacedName) []*cacheEntry {
+	evicted := []*cacheEntry{}
+
+	for {
+		var lruKey, lruChnKey string
+
+		total, refs := 0, 0
+
+		for key, entry := range s.entries {
+			total++
+
+			if entry.chnKey == chnKey {
+				refs++
+
+				if lruChnKey == "" || entry.used.Before(s.entries[lruChnKey].used) {
+					lruChnKey = key
+				}
+			}
+
+			if lruKey == "" || entry.used.Before(s.entries[lruKey].used) {
+				lruKey = key
+			}
+		}
+
+		switch {
+		case refs >= maxRefsPerChannel:
+			lruKey = lruChnKey
+		case total < maxEntries:
+			return evicted
+		}
+
+		evicted = append(evicted, s.entries[lruKey])
+
+		delete(s.entries, lruKey)
+	}
+}
+
+// removeEntries deletes the clones of the evicted entries, once the requests using them are served
+func (s *Server) removeEntries(evicted []*cacheEntry) {
+	for _, entry := range evicted {
+		entry.mtx.Lock()
+
+		entry.evicted = true
+
+		if err := os.RemoveAll(entry.dir); err != nil {
+			klog.Warningf("failed to remove the channel cache clone %v of channel %v, err: %v", entry.dir, entry.chnKey, err)
+		}
+
+		entry.mtx.Unlock()
+	}
+}
+
+func (s *Server) getCloneOptions(chn *chnv1.Channel, branch, commit, tag, tagConstraint string,
+	depth int) (*utils.GitCloneOption, error) {
+	user, pwd, sshKey, passphrase, clientkey, clientcert, err := utils.GetChannelSecret(s.Client, chn)
+	if err != nil {
+		return nil, err
+	}
+
+	connCfg := &utils.ChannelConnectionCfg{
+		RepoURL:            chn.Spec.Pathname,
+		User:               user,
+		Password:           pwd,
+		SSHKey:             sshKey,
+		Passphrase:         passphrase,
+		InsecureSkipVerify: chn.Spec.InsecureSkipVerify,
+		ClientKey:          clientkey,
+		ClientCert:         clientcert,
+		CaCerts:            utils.GetChannelCACerts(s.Client, chn),
+		Proxy:              utils.GetChannelProxy(s.Client, chn),
+		Provider:           utils.GetChannelGitProvider(chn),
+	}
+
+	if depth <= 0 {
+		depth = 1
+	}
+
+	return &utils.GitCloneOption{
+		Branch:                  utils.GetSubscriptionBranchRef(branch),
+		CommitHash:              commit,
+		RevisionTag:             tag,
+		RevisionTagConstraint:   tagConstraint,
+		CloneDepth:              depth,
+		PrimaryConnectionOption: connCfg,
+	}, nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcmhub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	addonV1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	manifestWorkV1 "open-cluster-management.io/api/work/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appSubV1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+const (
+	// appAddonName is the managed cluster addon that installs the subscription agent and its CRDs
+	appAddonName = "application-manager"
+	// reasonMissingDependency is the event reason used when a managed cluster lacks the subscription agent
+	reasonMissingDependency = "MissingDependency"
+	// addonNameLabel labels the manifestWorks deploying an addon with the addon name
+	addonNameLabel = "open-cluster-management.io/addon-name"
+)
+
+// requiredCRDs are the APIs the managed cluster must serve to host the propagated subscriptions
+var requiredCRDs = []string{
+	"subscriptions.apps.open-cluster-management.io",
+	"helmreleases.apps.open-cluster-management.io",
+}
+
+// MissingDependencyError indicates the target managed cluster can't host the propagated subscription
+type MissingDependencyError struct {
+	Cluster string
+	Reason  string
+}
+
+func (e *MissingDependencyError) Error() string {
+	return fmt.Sprintf("%s: cluster %s, %s", reasonMissingDependency, e.Cluster, e.Reason)
+}
+
+// IsMissingDependency returns true if the error is a MissingDependencyError
+func IsMissingDependency(err error) bool {
+	_, ok := err.(*MissingDependencyError)
+
+	return ok
+}
+
+// checkClusterCapability makes sure the application-manager addon is installed and available, and that the
+// Subscription and HelmRelease CRDs it deploys are available on the managed cluster, before propagating to the
+// cluster. If the addon API is not served by the hub, the check is skipped. The errors other than a
+// MissingDependencyError are transient, the reconcile is retried.
+func (r *ReconcileSubscription) checkClusterCapability(cluster ManageClusters, appsub *appSubV1.Subscription) error {
+	if strings.EqualFold(appsub.GetAnnotations()[appSubV1.AnnotationSkipCapabilityCheck], "true") {
+		return nil
+	}
+
+	addon := &addonV1alpha1.ManagedClusterAddOn{}
+
+	err := r.Get(context.TODO(), types.NamespacedName{Name: appAddonName, Namespace: cluster.Cluster}, addon)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			klog.V(1).Infof("ManagedClusterAddOn API not found, skip capability check for cluster: %v", cluster.Cluster)
+
+			return nil
+		}
+
+		if errors.IsNotFound(err) {
+			return &MissingDependencyError{
+				Cluster: cluster.Cluster,
+				Reason:  fmt.Sprintf("the %s addon is not installed, Subscription and HelmRelease APIs are unavailable", appAddonName),
+			}
+		}
+
+		return err
+	}
+
+	if !isAddonAvailable(addon) {
+		return &MissingDependencyError{
+			Cluster: cluster.Cluster,
+			Reason:  fmt.Sprintf("the %s addon is not available", appAddonName),
+		}
+	}
+
+	works := &manifestWorkV1.ManifestWorkList{}
+	if err := r.List(context.TODO(), works, client.InNamespace(cluster.Cluster),
+		client.MatchingLabels{addonNameLabel: appAddonName}); err != nil {
+		return err
+	}
+
+	if missing := missingCRDs(works.Items); len(missing) > 0 {
+		return &MissingDependencyError{
+			Cluster: cluster.Cluster,
+			Reason:  fmt.Sprintf("the %s APIs are not available", strings.Join(missing, ", ")),
+		}
+	}
+
+	return nil
+}
+
+// missingCRDs returns the required CRDs the manifestWorks of the addon don't report available on the managed cluster
+func missingCRDs(works []manifestWorkV1.ManifestWork) []string {
+	available := map[string]bool{}
+
+	for _, work := range works {
+		for _, manifest := range work.Status.ResourceStatus.Manifests {
+			if manifest.ResourceMeta.Group != "apiextensions.k8s.io" ||
+				manifest.ResourceMeta.Resource != "customresourcedefinitions" {
+				continue
+			}
+
+			if meta.IsStatusConditionTrue(manifest.Conditions, string(manifestWorkV1.ManifestAvailable)) {
+				available[manifest.ResourceMeta.Name] = true
+			}
+		}
+	}
+
+	missing := []string{}
+
+	for _, crd := range requiredCRDs {
+		if !available[crd] {
+			missing = append(missing, crd)
+		}
+	}
+
+	return missing
+}
+
+// isAddonAvailable returns false only if the addon explicitly reports it is unavailable,
+// a newly created addon without conditions is given the benefit of the doubt.
+func isAddonAvailable(addon *addonV1alpha1.ManagedClusterAddOn) bool {
+	cond := meta.FindStatusCondition(addon.Status.Conditions, addonV1alpha1.ManagedClusterAddOnConditionAvailable)
+	if cond == nil {
+		return true
+	}
+
+	return cond.Status != metav1.ConditionFalse
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcmhub
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	addonV1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	manifestWorkV1 "open-cluster-management.io/api/work/v1"
+)
+
+func TestIsAddonAvailable(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	addon := &addonV1alpha1.ManagedClusterAddOn{}
+	g.Expect(isAddonAvailable(addon)).To(gomega.BeTrue())
+
+	addon.Status.Conditions = []metav1.Condition{
+		{Type: addonV1alpha1.ManagedClusterAddOnConditionAvailable, Status: metav1.ConditionFalse},
+	}
+	g.Expect(isAddonAvailable(addon)).To(gomega.BeFalse())
+
+	addon.Status.Conditions[0].Status = metav1.ConditionTrue
+	g.Expect(isAddonAvailable(addon)).To(gomega.BeTrue())
+}
+
+func TestIsMissingDependency(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	err := &MissingDependencyError{Cluster: "cluster1", Reason: "addon is not installed"}
+	g.Expect(IsMissingDependency(err)).To(gomega.BeTrue())
+	g.Expect(err.Error()).To(gomega.ContainSubstring("cluster1"))
+	g.Expect(IsMissingDependency(errors.New("other"))).To(gomega.BeFalse())
+}
+
+func TestMissingCRDs(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(missingCRDs(nil)).To(gomega.Equal(requiredCRDs))
+
+	crdStatus := func(name string, status metav1.ConditionStatus) manifestWorkV1.ManifestCondition {
+		return manifestWorkV1.ManifestCondition{
+			ResourceMeta: manifestWorkV1.ManifestResourceMeta{
+				Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions", Name: name,
+			},
+			Conditions: []metav1.Condition{{Type: string(manifestWorkV1.ManifestAvailable), Status: status}},
+		}
+	}
+
+	work := manifestWorkV1.ManifestWork{}
+	work.Status.ResourceStatus.Manifests = []manifestWorkV1.ManifestCondition{
+		crdStatus("subscriptions.apps.open-cluster-management.io", metav1.ConditionTrue),
+		crdStatus("helmreleases.apps.open-cluster-management.io", metav1.ConditionFalse),
+	}
+	g.Expect(missingCRDs([]manifestWorkV1.ManifestWork{work})).
+		To(gomega.Equal([]string{"helmreleases.apps.open-cluster-management.io"}))
+
+	work.Status.ResourceStatus.Manifests[1].Conditions[0].Status = metav1.ConditionTrue
+	g.Expect(missingCRDs([]manifestWorkV1.ManifestWork{work})).To(gomega.BeEmpty())
+}
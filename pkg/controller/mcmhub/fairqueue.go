@@ -0,0 +1,193 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcmhub
+
+import (
+	"context"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// syncWorkers is the number of subscriptions the hub reconciles concurrently
+var syncWorkers = 1
+
+// SetSyncWorkers sets the number of subscriptions the hub reconciles concurrently, 1 if n is not positive
+func SetSyncWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+
+	syncWorkers = n
+}
+
+// fairQueue holds the subscription requests of the watches until a worker is free, and then hands them to the
+// controller in turn across the namespaces. A namespace with hundreds of subscriptions gets one worker at a time
+// while the other namespaces have pending requests, instead of filling the controller queue ahead of them.
+type fairQueue struct {
+	mu sync.Mutex
+	// pending are the requests waiting for a worker, in FIFO order by namespace
+	pending map[string][]reconcile.Request
+	// queued are the pending requests, to drop the duplicates
+	queued map[reconcile.Request]bool
+	// ring are the namespaces with pending requests, in the order they get a worker
+	ring []string
+	// released are the requests handed to the controller and not reconciled yet
+	released    map[reconcile.Request]bool
+	maxReleased int
+	events      chan event.GenericEvent
+}
+
+func newFairQueue(workers int) *fairQueue {
+	return &fairQueue{
+		pending:     map[string][]reconcile.Request{},
+		queued:      map[reconcile.Request]bool{},
+		released:    map[reconcile.Request]bool{},
+		maxReleased: workers,
+		// never blocks, there are at most maxReleased requests released and not reconciled yet
+		events: make(chan event.GenericEvent, workers),
+	}
+}
+
+// Add queues the request behind the pending requests of its namespace
+func (q *fairQueue) Add(req reconcile.Request) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.queued[req] {
+		return
+	}
+
+	q.queued[req] = true
+
+	if len(q.pending[req.Namespace]) == 0 {
+		q.ring = append(q.ring, req.Namespace)
+	}
+
+	q.pending[req.Namespace] = append(q.pending[req.Namespace], req)
+
+	q.releaseLocked()
+}
+
+// Done frees the worker of the request once it is reconciled
+func (q *fairQueue) Done(req reconcile.Request) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.released[req] {
+		// requeued by the controller itself, e.g. on a reconcile error or after the requeue interval
+		return
+	}
+
+	delete(q.released, req)
+
+	q.releaseLocked()
+}
+
+// releaseLocked hands the pending requests to the controller while there are free workers, one namespace after
+// the other. A request being reconciled stays pending until its reconcile is done.
+func (q *fairQueue) releaseLocked() {
+	skipped := 0
+
+	for len(q.released) < q.maxReleased && skipped < len(q.ring) {
+		ns := q.ring[0]
+		q.ring = q.ring[1:]
+
+		reqs := q.pending[ns]
+
+		i := 0
+		for i < len(reqs) && q.released[reqs[i]] {
+			i++
+		}
+
+		if i == len(reqs) {
+			// all the pending requests of the namespace are being reconciled
+			q.ring = append(q.ring, ns)
+			skipped++
+
+			continue
+		}
+
+		req := reqs[i]
+		reqs = append(reqs[:i:i], reqs[i+1:]...)
+
+		if len(reqs) == 0 {
+			delete(q.pending, ns)
+		} else {
+			q.pending[ns] = reqs
+			q.ring = append(q.ring, ns)
+		}
+
+		delete(q.queued, req)
+		q.released[req] = true
+		skipped = 0
+
+		klog.V(5).Infof("releasing subscription %v, %v subscriptions pending", req.NamespacedName, len(q.queued))
+
+		q.events <- event.GenericEvent{
+			Object: &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Namespace: req.Namespace, Name: req.Name}},
+		}
+	}
+}
+
+// handler returns the event handler queueing the requests of the map function in the fair queue, in place of
+// handler.EnqueueRequestsFromMapFunc
+func (q *fairQueue) handler(fn handler.MapFunc) handler.EventHandler {
+	add := func(obj client.Object) {
+		if obj == nil {
+			return
+		}
+
+		for _, req := range fn(obj) {
+			q.Add(req)
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, _ workqueue.RateLimitingInterface) {
+			add(e.Object)
+		},
+		UpdateFunc: func(e event.UpdateEvent, _ workqueue.RateLimitingInterface) {
+			add(e.ObjectOld)
+			add(e.ObjectNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent, _ workqueue.RateLimitingInterface) {
+			add(e.Object)
+		},
+		GenericFunc: func(e event.GenericEvent, _ workqueue.RateLimitingInterface) {
+			add(e.Object)
+		},
+	}
+}
+
+// fairReconciler frees the worker of the fair queue requests once they are reconciled
+type fairReconciler struct {
+	reconcile.Reconciler
+	queue *fairQueue
+}
+
+func (r *fairReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	defer r.queue.Done(req)
+
+	return r.Reconciler.Reconcile(ctx, req)
+}
+
+var _ reconcile.Reconciler = &fairReconciler{}
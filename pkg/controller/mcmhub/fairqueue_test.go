@@ -0,0 +1,105 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcmhub
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func fairRequest(namespace, name string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}
+}
+
+// nextReleased returns the request released by the fair queue, if any
+func nextReleased(q *fairQueue) (reconcile.Request, bool) {
+	select {
+	case e := <-q.events:
+		return fairRequest(e.Object.GetNamespace(), e.Object.GetName()), true
+	default:
+		return reconcile.Request{}, false
+	}
+}
+
+func TestFairQueue(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	q := newFairQueue(1)
+
+	// the first request gets the free worker
+	q.Add(fairRequest("tenant-a", "sub-0"))
+
+	req, ok := nextReleased(q)
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(req).To(gomega.Equal(fairRequest("tenant-a", "sub-0")))
+
+	for _, name := range []string{"sub-1", "sub-2", "sub-3", "sub-2"} {
+		q.Add(fairRequest("tenant-a", name))
+	}
+
+	q.Add(fairRequest("tenant-b", "sub-0"))
+	q.Add(fairRequest("tenant-c", "sub-0"))
+
+	// the worker is busy
+	_, ok = nextReleased(q)
+	g.Expect(ok).To(gomega.BeFalse())
+
+	// queued again while it is being reconciled
+	q.Add(fairRequest("tenant-a", "sub-0"))
+
+	order := []reconcile.Request{}
+	done := req
+
+	for {
+		q.Done(done)
+
+		next, ok := nextReleased(q)
+		if !ok {
+			break
+		}
+
+		order = append(order, next)
+		done = next
+	}
+
+	g.Expect(order).To(gomega.Equal([]reconcile.Request{
+		fairRequest("tenant-a", "sub-1"),
+		fairRequest("tenant-b", "sub-0"),
+		fairRequest("tenant-c", "sub-0"),
+		fairRequest("tenant-a", "sub-2"),
+		fairRequest("tenant-a", "sub-3"),
+		fairRequest("tenant-a", "sub-0"),
+	}))
+
+	// the requests requeued by the controller itself don't free a worker
+	q.Add(fairRequest("tenant-a", "sub-4"))
+	req, ok = nextReleased(q)
+	g.Expect(ok).To(gomega.BeTrue())
+
+	q.Add(fairRequest("tenant-b", "sub-1"))
+	q.Done(fairRequest("tenant-c", "sub-0"))
+
+	_, ok = nextReleased(q)
+	g.Expect(ok).To(gomega.BeFalse())
+
+	q.Done(req)
+
+	req, ok = nextReleased(q)
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(req).To(gomega.Equal(fairRequest("tenant-b", "sub-1")))
+}
@@ -15,6 +15,7 @@
 package mcmhub
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"path/filepath"
@@ -85,9 +86,7 @@ func (r *ReconcileSubscription) GetGitResources(sub *appv1.Subscription, isAdmin
 		}
 
 		baseDir := r.hubGitOps.GetRepoRootDirctory(sub)
-		resourcePath := getResourcePath(r.hubGitOps.ResolveLocalGitFolder, sub)
-
-		objRefList, err = r.processRepo(primaryChannel, sub, r.hubGitOps.ResolveLocalGitFolder(sub), resourcePath, baseDir, isAdmin)
+		objRefList, err = r.processRepo(primaryChannel, sub, r.hubGitOps.ResolveLocalGitFolder(sub), baseDir, isAdmin)
 		if err != nil {
 			klog.Error(err.Error())
 			return nil, err
@@ -135,22 +134,85 @@ func (r *ReconcileSubscription) AddClusterAdminAnnotation(sub *appv1.Subscriptio
 	return false
 }
 
-func getResourcePath(localFolderFunc func(*appv1.Subscription) string, sub *appv1.Subscription) string {
-	resourcePath := localFolderFunc(sub)
+// getFilterRefConfigMap returns the package filter ConfigMap of the appsub, nil if it has none or it can't be read
+func (r *ReconcileSubscription) getFilterRefConfigMap(sub *appv1.Subscription) *v1.ConfigMap {
+	if sub.Spec.PackageFilter == nil || sub.Spec.PackageFilter.FilterRef == nil {
+		return nil
+	}
 
-	annotations := sub.GetAnnotations()
-	if annotations[appv1.AnnotationGithubPath] != "" {
-		resourcePath = filepath.Join(localFolderFunc(sub), annotations[appv1.AnnotationGithubPath])
-	} else if annotations[appv1.AnnotationGitPath] != "" {
-		resourcePath = filepath.Join(localFolderFunc(sub), annotations[appv1.AnnotationGitPath])
+	filterRef := &v1.ConfigMap{}
+	key := types.NamespacedName{Name: sub.Spec.PackageFilter.FilterRef.Name, Namespace: sub.Namespace}
+
+	if err := r.Get(context.TODO(), key, filterRef); err != nil {
+		klog.Error("Failed to get PackageFilter.FilterRef of subscription, error: ", err)
+
+		return nil
 	}
 
-	return resourcePath
+	return filterRef
 }
 
 func (r *ReconcileSubscription) processRepo(chn *chnv1.Channel, sub *appv1.Subscription,
-	localRepoRoot, subPath, baseDir string, isAdmin bool) ([]*v1.ObjectReference, error) {
-	chartDirs, kustomizeDirs, crdsAndNamespaceFiles, rbacFiles, otherFiles, err := utils.SortResources(localRepoRoot, subPath)
+	localRepoRoot, baseDir string, isAdmin bool) ([]*v1.ObjectReference, error) {
+	objMap, err := r.renderRepo(chn, sub, localRepoRoot, baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get list of object references from the map
+	objRefList := []*v1.ObjectReference{}
+
+	for key := range objMap {
+		value := key
+
+		// No need to save the namespace object to the resource list of the appsub
+		if value.Kind == "Namespace" {
+			continue
+		}
+
+		// respect object customized namespace if the appsub user is subscription admin, or apply it to appsub namespace
+		if isAdmin {
+			if value.Namespace == "" {
+				value.Namespace = sub.Namespace
+			}
+		} else {
+			value.Namespace = sub.Namespace
+		}
+
+		objRefList = append(objRefList, &value)
+	}
+
+	return objRefList, nil
+}
+
+// renderRepo returns the kube resources, the kustomize outputs and the HelmReleases of the charts the subscription
+// deploys from the local clone of the git repo, by object reference
+func (r *ReconcileSubscription) renderRepo(chn *chnv1.Channel, sub *appv1.Subscription,
+	localRepoRoot, baseDir string) (map[v1.ObjectReference]*unstructured.Unstructured, error) {
+	filterRef := r.getFilterRefConfigMap(sub)
+
+	resourcePaths, err := utils.GetGitResourcePaths(sub, localRepoRoot, filterRef)
+	if err != nil {
+		klog.Error(err, "Failed to get the resource paths.")
+
+		return nil, err
+	}
+
+	include, exclude := utils.GetGitPathPatterns(sub, filterRef)
+	skip := utils.NewPathFilterSkipFunc(localRepoRoot, include, exclude)
+
+	expectations, err := utils.GetGitPathExpectations(sub, filterRef)
+	if err == nil {
+		err = utils.VerifyGitPathStructure(localRepoRoot, resourcePaths, expectations, skip)
+	}
+
+	if err != nil {
+		klog.Errorf("appsub %v/%v: %v", sub.Namespace, sub.Name, err)
+
+		return nil, err
+	}
+
+	chartDirs, kustomizeDirs, crdsAndNamespaceFiles, rbacFiles, otherFiles, err := utils.SortResourcePaths(localRepoRoot, resourcePaths, skip)
 
 	if err != nil {
 		klog.Error(err, "Failed to sort kubernetes resources and helm charts.")
@@ -171,31 +233,31 @@ func (r *ReconcileSubscription) processRepo(chn *chnv1.Channel, sub *appv1.Subsc
 	b, _ := yaml.Marshal(indexFile)
 	klog.Info("New index file ", string(b))
 
-	// Get object reference map for all the kube resources and helm charts from the git repo
+	// Get object map for all the kube resources and helm charts from the git repo
 	errMessage := ""
-	objRefMap := make(map[v1.ObjectReference]*v1.ObjectReference)
+	objMap := make(map[v1.ObjectReference]*unstructured.Unstructured)
 
-	err = r.subscribeResources(crdsAndNamespaceFiles, objRefMap)
+	err = r.subscribeResources(crdsAndNamespaceFiles, objMap)
 	if err != nil {
 		errMessage += err.Error() + "/n"
 	}
 
-	err = r.subscribeResources(rbacFiles, objRefMap)
+	err = r.subscribeResources(rbacFiles, objMap)
 	if err != nil {
 		errMessage += err.Error() + "/n"
 	}
 
-	err = r.subscribeResources(otherFiles, objRefMap)
+	err = r.subscribeResources(otherFiles, objMap)
 	if err != nil {
 		errMessage += err.Error() + "/n"
 	}
 
-	err = r.subscribeKustomizations(sub, kustomizeDirs, baseDir, objRefMap)
+	err = r.subscribeKustomizations(sub, kustomizeDirs, baseDir, objMap)
 	if err != nil {
 		errMessage += err.Error() + "/n"
 	}
 
-	err = r.subscribeHelmCharts(chn, indexFile, objRefMap)
+	err = r.subscribeHelmCharts(chn, indexFile, objMap)
 	if err != nil {
 		errMessage += err.Error() + "/n"
 	}
@@ -204,32 +266,11 @@ func (r *ReconcileSubscription) processRepo(chn *chnv1.Channel, sub *appv1.Subsc
 		return nil, errors.New(errMessage)
 	}
 
-	// Get list of object references from the map
-	objRefList := []*v1.ObjectReference{}
-
-	for _, value := range objRefMap {
-		// No need to save the namespace object to the resource list of the appsub
-		if value.Kind == "Namespace" {
-			continue
-		}
-
-		// respect object customized namespace if the appsub user is subscription admin, or apply it to appsub namespace
-		if isAdmin {
-			if value.Namespace == "" {
-				value.Namespace = sub.Namespace
-			}
-		} else {
-			value.Namespace = sub.Namespace
-		}
-
-		objRefList = append(objRefList, value)
-	}
-
-	return objRefList, nil
+	return objMap, nil
 }
 
 func (r *ReconcileSubscription) subscribeResources(
-	rscFiles []string, objRefMap map[v1.ObjectReference]*v1.ObjectReference) error {
+	rscFiles []string, objMap map[v1.ObjectReference]*unstructured.Unstructured) error {
 	// sync kube resource manifests
 	for _, rscFile := range rscFiles {
 		file, err := ioutil.ReadFile(rscFile) // #nosec G304 rscFile is not user input
@@ -252,7 +293,7 @@ func (r *ReconcileSubscription) subscribeResources(
 
 		if len(resources) > 0 {
 			for _, resource := range resources {
-				if err := r.addObjectReference(objRefMap, resource); err != nil {
+				if err := r.addObjectReference(objMap, resource); err != nil {
 					klog.Error("Failed to generate object reference", err)
 					return err
 				}
@@ -264,8 +305,8 @@ func (r *ReconcileSubscription) subscribeResources(
 }
 
 func (r *ReconcileSubscription) subscribeKustomizations(sub *appv1.Subscription, kustomizeDirs map[string]string,
-	baseDir string, objRefMap map[v1.ObjectReference]*v1.ObjectReference) error {
-	for _, kustomizeDir := range kustomizeDirs {
+	baseDir string, objMap map[v1.ObjectReference]*unstructured.Unstructured) error {
+	for _, kustomizeDir := range utils.SortedDirs(kustomizeDirs) {
 		klog.Info("Applying kustomization ", kustomizeDir)
 
 		relativePath := kustomizeDir
@@ -298,7 +339,7 @@ func (r *ReconcileSubscription) subscribeKustomizations(sub *appv1.Subscription,
 
 			if t.APIVersion == "" || t.Kind == "" {
 				klog.Info("Not a Kubernetes resource")
-			} else if err := r.addObjectReference(objRefMap, resourceFile); err != nil {
+			} else if err := r.addObjectReference(objMap, resourceFile); err != nil {
 				klog.Error("Failed to generate object reference", err)
 				return err
 			}
@@ -327,8 +368,10 @@ type sourceURLs struct {
 }
 
 func (r *ReconcileSubscription) subscribeHelmCharts(chn *chnv1.Channel, indexFile *repo.IndexFile,
-	objRefMap map[v1.ObjectReference]*v1.ObjectReference) error {
-	for packageName, chartVersions := range indexFile.Entries {
+	objMap map[v1.ObjectReference]*unstructured.Unstructured) error {
+	for _, packageName := range utils.SortedChartNames(indexFile) {
+		chartVersions := indexFile.Entries[packageName]
+
 		klog.Infof("chart: %s\n%v", packageName, chartVersions)
 
 		obj := &unstructured.Unstructured{}
@@ -363,7 +406,7 @@ func (r *ReconcileSubscription) subscribeHelmCharts(chn *chnv1.Channel, indexFil
 
 		klog.V(2).Info("Generating object reference")
 
-		if err := r.addObjectReference(objRefMap, dplSpec); err != nil {
+		if err := r.addObjectReference(objMap, dplSpec); err != nil {
 			klog.Error("Failed to generate object reference", err)
 			return err
 		}
@@ -372,21 +415,21 @@ func (r *ReconcileSubscription) subscribeHelmCharts(chn *chnv1.Channel, indexFil
 	return nil
 }
 
-func (r *ReconcileSubscription) addObjectReference(objRefMap map[v1.ObjectReference]*v1.ObjectReference, filecontent []byte) error {
+func (r *ReconcileSubscription) addObjectReference(objMap map[v1.ObjectReference]*unstructured.Unstructured, filecontent []byte) error {
 	obj := &unstructured.Unstructured{}
 	if err := yaml.Unmarshal(filecontent, obj); err != nil {
 		klog.Error("Failed to unmarshal resource YAML.")
 		return err
 	}
 
-	objRef := &v1.ObjectReference{
+	objRef := v1.ObjectReference{
 		Kind:       obj.GetKind(),
 		Namespace:  obj.GetNamespace(),
 		Name:       obj.GetName(),
 		APIVersion: obj.GetAPIVersion(),
 	}
 
-	objRefMap[*objRef] = objRef
+	objMap[objRef] = obj
 
 	return nil
 }
@@ -39,6 +39,8 @@ import (
 	helmops "open-cluster-management.io/multicloud-operators-subscription/pkg/subscriber/helmrepo"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
 	awsutils "open-cluster-management.io/multicloud-operators-subscription/pkg/utils/aws"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils/azure"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils/gcs"
 )
 
 // doMCMHubReconcile process Subscription on hub - distribute it via manifestWork
@@ -277,6 +279,11 @@ func (r *ReconcileSubscription) IsNamespacedResource(group, version, kind string
 
 	mapping, err := r.restMapper.RESTMapping(pkgGK, version)
 	if err != nil {
+		// the SealedSecret and ExternalSecret CRDs are usually installed on the managed clusters only
+		if _, namespaced, ok := utils.GetSecretOperatorKind(pkgGK); ok {
+			return namespaced
+		}
+
 		klog.Errorf("Failed to get GVR from restmapping, keep the original namespace: group: %v, version: %v, kind: %v, err:%v",
 			group, version, kind, err)
 
@@ -403,11 +410,9 @@ func (r *ReconcileSubscription) createAppAppsubReport(sub *appv1.Subscription, r
 	return nil
 }
 
-func (r *ReconcileSubscription) initObjectStore(channel *chnv1.Channel) (*awsutils.Handler, string, error) {
+func (r *ReconcileSubscription) initObjectStore(channel *chnv1.Channel) (awsutils.ObjectStore, string, error) {
 	var err error
 
-	awshandler := &awsutils.Handler{}
-
 	pathName := channel.Spec.Pathname
 
 	if pathName == "" {
@@ -430,6 +435,8 @@ func (r *ReconcileSubscription) initObjectStore(channel *chnv1.Channel) (*awsuti
 	secretAccessKey := ""
 	region := ""
 
+	var secretData map[string][]byte
+
 	if channel.Spec.SecretRef != nil {
 		channelSecret := &v1.Secret{}
 		chnseckey := types.NamespacedName{
@@ -441,6 +448,8 @@ func (r *ReconcileSubscription) initObjectStore(channel *chnv1.Channel) (*awsuti
 			return nil, "", gerr.Wrap(err, "failed to get reference secret from channel")
 		}
 
+		secretData = channelSecret.Data
+
 		err = yaml.Unmarshal(channelSecret.Data[awsutils.SecretMapKeyAccessKeyID], &accessKeyID)
 		if err != nil {
 			klog.Error("Failed to unmashall accessKey from secret with error:", err)
@@ -467,25 +476,51 @@ func (r *ReconcileSubscription) initObjectStore(channel *chnv1.Channel) (*awsuti
 		}
 	}
 
-	klog.V(1).Info("Trying to connect to object bucket ", endpoint, "|", bucket)
+	settings, err := awsutils.GetChannelObjectStoreSettings(channel, endpoint, accessKeyID, secretAccessKey, region)
+	if err != nil {
+		return nil, "", err
+	}
+
+	klog.V(1).Info("Trying to connect to object bucket ", settings.Endpoint, "|", bucket)
 
-	if err := awshandler.InitObjectStoreConnection(endpoint, accessKeyID, secretAccessKey, region); err != nil {
+	var objectStore awsutils.ObjectStore
+
+	switch {
+	case gcs.IsGCSPathname(pathName):
+		gcsHandler := &gcs.Handler{}
+		err = gcsHandler.InitObjectStoreConnection(settings.Endpoint, secretData[gcs.SecretMapKeyServiceAccountKey])
+		objectStore = gcsHandler
+	case azure.IsAzureChannel(pathName, secretData):
+		azureHandler := &azure.Handler{}
+		err = azureHandler.InitObjectStoreConnection(settings.Endpoint,
+			string(secretData[azure.SecretMapKeyConnectionString]),
+			string(secretData[azure.SecretMapKeyManagedIdentityClientID]))
+		objectStore = azureHandler
+	default:
+		awshandler := &awsutils.Handler{}
+		err = awshandler.InitObjectStore(settings)
+		objectStore = awshandler
+	}
+
+	if err != nil {
 		klog.Error(err, "unable initialize object store settings")
 
 		return nil, "", err
 	}
 	// Check whether the connection is setup successfully
-	if err := awshandler.Exists(bucket); err != nil {
+	if err := objectStore.Exists(bucket); err != nil {
 		klog.Error(err, "Unable to access object store bucket ", bucket, " for channel ", channel.Name)
 
 		return nil, "", err
 	}
 
-	return awshandler, bucket, nil
+	return objectStore, bucket, nil
 }
 
 func (r *ReconcileSubscription) getObjectBucketResources(sub *appv1.Subscription, channel, secondaryChannel *chnv1.Channel,
 	isAdmin bool) ([]*v1.ObjectReference, error) {
+	keyChannel := channel
+
 	awsHandler, bucket, err := r.initObjectStore(channel)
 	if err != nil {
 		klog.Error(err, "Unable to access object store: ")
@@ -494,6 +529,7 @@ func (r *ReconcileSubscription) getObjectBucketResources(sub *appv1.Subscription
 			klog.Infof("trying the secondary channel %s", secondaryChannel.Name)
 			// Try with secondary channel
 			awsHandler, bucket, err = r.initObjectStore(secondaryChannel)
+			keyChannel = secondaryChannel
 
 			if err != nil {
 				klog.Error(err, "Unable to access object store with channel ", channel.Name)
@@ -507,16 +543,14 @@ func (r *ReconcileSubscription) getObjectBucketResources(sub *appv1.Subscription
 		}
 	}
 
-	var folderName *string
-
-	annotations := sub.GetAnnotations()
-	bucketPath := annotations[appv1.AnnotationBucketPath]
+	keyFilter, err := awsutils.GetObjectKeyFilter(keyChannel, sub)
+	if err != nil {
+		klog.Error(err)
 
-	if bucketPath != "" {
-		folderName = &bucketPath
+		return nil, err
 	}
 
-	keys, err := awsHandler.List(bucket, folderName)
+	keys, err := awsHandler.List(bucket, keyFilter.ListPrefix())
 	klog.V(5).Infof("object keys: %v", keys)
 
 	if err != nil {
@@ -525,6 +559,8 @@ func (r *ReconcileSubscription) getObjectBucketResources(sub *appv1.Subscription
 		return nil, err
 	}
 
+	keys = keyFilter.FilterKeys(keys)
+
 	// converting template from object store to resource
 	var errMsgs []string
 
@@ -374,11 +374,12 @@ func (h *HubGitOps) RegisterBranch(subIns *subv1.Subscription) error {
 	}
 
 	cloneOptions := &utils.GitCloneOption{
-		Branch:      utils.GetSubscriptionBranchRef(branchName),
-		CommitHash:  commit,
-		RevisionTag: tag,
-		DestDir:     repoBranchDir,
-		CloneDepth:  depthInt,
+		Branch:                utils.GetSubscriptionBranchRef(branchName),
+		CommitHash:            commit,
+		RevisionTag:           tag,
+		RevisionTagConstraint: utils.GetSubscriptionGitTagConstraint(subIns),
+		DestDir:               repoBranchDir,
+		CloneDepth:            depthInt,
 	}
 
 	primaryChannel, secondaryChannel, err := GetSubscriptionRefChannel(h.clt, subIns)
@@ -392,81 +393,21 @@ func (h *HubGitOps) RegisterBranch(subIns *subv1.Subscription) error {
 		return nil
 	}
 
-	user, pwd, sshKey, passphrase, clientkey, clientcert, err := utils.GetChannelSecret(h.clt, primaryChannel)
-
+	primaryChannelConnectionConfig, err := getGitConnectionConfig(h.clt, primaryChannel)
 	if err != nil {
 		h.logger.Error(err, "failed to register subscription to git watcher register")
 		return err
 	}
 
-	channelConfig := utils.GetChannelConfigMap(h.clt, primaryChannel)
-	caCert := ""
-
-	if channelConfig != nil {
-		caCert = channelConfig.Data[subv1.ChannelCertificateData]
-		if caCert != "" {
-			h.logger.Info("Channel config map with CA certs found")
-		}
-	}
-
-	skipCertVerify := false
-
-	if primaryChannel.Spec.InsecureSkipVerify {
-		skipCertVerify = true
-
-		h.logger.Info("Channel spec has insecureSkipVerify: true.")
-	}
-
-	primaryChannelConnectionConfig := &utils.ChannelConnectionCfg{}
-	primaryChannelConnectionConfig.RepoURL = primaryChannel.Spec.Pathname
-	primaryChannelConnectionConfig.CaCerts = caCert
-	primaryChannelConnectionConfig.InsecureSkipVerify = skipCertVerify
-	primaryChannelConnectionConfig.Passphrase = passphrase
-	primaryChannelConnectionConfig.Password = pwd
-	primaryChannelConnectionConfig.SSHKey = sshKey
-	primaryChannelConnectionConfig.User = user
-	primaryChannelConnectionConfig.ClientCert = clientcert
-	primaryChannelConnectionConfig.ClientKey = clientkey
-
 	cloneOptions.PrimaryConnectionOption = primaryChannelConnectionConfig
 
 	if secondaryChannel != nil {
-		user, pwd, sshKey, passphrase, clientkey, clientcert, err := utils.GetChannelSecret(h.clt, secondaryChannel)
-
+		secondaryChannelConnectionConfig, err := getGitConnectionConfig(h.clt, secondaryChannel)
 		if err != nil {
 			h.logger.Error(err, "failed to register subscription to git watcher register")
 			return err
 		}
 
-		channelConfig := utils.GetChannelConfigMap(h.clt, primaryChannel)
-		caCert := ""
-
-		if channelConfig != nil {
-			caCert = channelConfig.Data[subv1.ChannelCertificateData]
-			if caCert != "" {
-				h.logger.Info("Channel config map with CA certs found")
-			}
-		}
-
-		skipCertVerify := false
-
-		if primaryChannel.Spec.InsecureSkipVerify {
-			skipCertVerify = true
-
-			h.logger.Info("Channel spec has insecureSkipVerify: true.")
-		}
-
-		secondaryChannelConnectionConfig := &utils.ChannelConnectionCfg{}
-		secondaryChannelConnectionConfig.RepoURL = secondaryChannel.Spec.Pathname
-		secondaryChannelConnectionConfig.CaCerts = caCert
-		secondaryChannelConnectionConfig.InsecureSkipVerify = skipCertVerify
-		secondaryChannelConnectionConfig.Passphrase = passphrase
-		secondaryChannelConnectionConfig.Password = pwd
-		secondaryChannelConnectionConfig.SSHKey = sshKey
-		secondaryChannelConnectionConfig.User = user
-		secondaryChannelConnectionConfig.ClientCert = clientcert
-		secondaryChannelConnectionConfig.ClientKey = clientkey
-
 		cloneOptions.SecondaryConnectionOption = secondaryChannelConnectionConfig
 	}
 
@@ -510,7 +451,8 @@ func (h *HubGitOps) RegisterBranch(subIns *subv1.Subscription) error {
 
 	subscriptionRepoInfo.branchs[branchInfoName].lastCommitID = commitID
 
-	// Pick up new channel configurations
+	// Pick up new channel configurations, e.g. a new pathname
+	subscriptionRepoInfo.url = primaryChannelConnectionConfig.RepoURL
 	subscriptionRepoInfo.branchs[branchInfoName].gitCloneOptions = *cloneOptions
 
 	subscriptionRepoInfo.branchs[branchInfoName].registeredSub[subKey] = struct{}{}
@@ -518,6 +460,37 @@ func (h *HubGitOps) RegisterBranch(subIns *subv1.Subscription) error {
 	return nil
 }
 
+// getGitConnectionConfig returns the connection config of the Git channel, from its spec, secret and config map
+func getGitConnectionConfig(clt client.Client, chn *chnv1.Channel) (*utils.ChannelConnectionCfg, error) {
+	user, pwd, sshKey, passphrase, clientkey, clientcert, err := utils.GetChannelSecret(clt, chn)
+	if err != nil {
+		return nil, err
+	}
+
+	caCert := utils.GetChannelCACerts(clt, chn)
+	if caCert != "" {
+		klog.Infof("Channel %v/%v CA certs found", chn.Namespace, chn.Name)
+	}
+
+	if chn.Spec.InsecureSkipVerify {
+		klog.Infof("Channel %v/%v spec has insecureSkipVerify: true.", chn.Namespace, chn.Name)
+	}
+
+	return &utils.ChannelConnectionCfg{
+		RepoURL:            chn.Spec.Pathname,
+		CaCerts:            caCert,
+		InsecureSkipVerify: chn.Spec.InsecureSkipVerify,
+		Passphrase:         passphrase,
+		Password:           pwd,
+		SSHKey:             sshKey,
+		User:               user,
+		ClientCert:         clientcert,
+		ClientKey:          clientkey,
+		Provider:           utils.GetChannelGitProvider(chn),
+		Proxy:              utils.GetChannelProxy(clt, chn),
+	}, nil
+}
+
 func fakeCommitID(c string) string {
 	return fmt.Sprintf("%s%s", c, commitIDSuffix)
 }
@@ -631,7 +604,7 @@ func sortClonedGitRepoGievnDestPath(repoRoot string, destPath string, logger log
 		if len(kustomizeDirs) != 0 {
 			out := [][]byte{}
 
-			for _, dir := range kustomizeDirs {
+			for _, dir := range utils.SortedDirs(kustomizeDirs) {
 				//this will return an []byte
 				r, err := utils.RunKustomizeBuild(dir)
 				if err != nil {
@@ -22,6 +22,7 @@ import (
 	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -243,6 +244,63 @@ func (mapper *channelMapper) Map(obj client.Object) []reconcile.Request {
 	return requests
 }
 
+type channelConfigMapMapper struct {
+	client.Client
+}
+
+func (mapper *channelConfigMapMapper) Map(obj client.Object) []reconcile.Request {
+	if klog.V(utils.QuiteLogLel).Enabled() {
+		fnName := utils.GetFnName()
+		klog.Infof("Entering: %v()", fnName)
+
+		defer klog.Infof("Exiting: %v()", fnName)
+	}
+
+	// if the config map of a channel is updated, e.g. its CA certificates are rotated, the subscriptions of the
+	// channel should be reconciled to connect to the channel with the new config map. The subscriptions whose package
+	// filter config map is updated are reconciled to deploy the new filter.
+
+	chnList := &chnv1.ChannelList{}
+	if err := mapper.List(context.TODO(), chnList, &client.ListOptions{Namespace: obj.GetNamespace()}); err != nil {
+		klog.Error("Listing channels in channelConfigMapMapper and got error:", err)
+
+		return nil
+	}
+
+	chns := map[string]bool{}
+
+	for _, chn := range chnList.Items {
+		if chn.Spec.ConfigMapRef != nil && chn.Spec.ConfigMapRef.Name == obj.GetName() {
+			chns[chn.GetNamespace()+"/"+chn.GetName()] = true
+		}
+	}
+
+	var requests []reconcile.Request
+
+	subList := &appv1.SubscriptionList{}
+	if err := mapper.List(context.TODO(), subList, &client.ListOptions{}); err != nil {
+		klog.Error("Listing all subscriptions in channelConfigMapMapper and got error:", err)
+	}
+
+	for _, sub := range subList.Items {
+		filterRef := sub.GetNamespace() == obj.GetNamespace() && sub.Spec.PackageFilter != nil &&
+			sub.Spec.PackageFilter.FilterRef != nil && sub.Spec.PackageFilter.FilterRef.Name == obj.GetName()
+
+		if chns[sub.Spec.Channel] || chns[sub.Spec.SecondaryChannel] || filterRef {
+			objkey := types.NamespacedName{
+				Name:      sub.GetName(),
+				Namespace: sub.GetNamespace(),
+			}
+
+			requests = append(requests, reconcile.Request{NamespacedName: objkey})
+		}
+	}
+
+	klog.V(1).Info("Out channel config map mapper with requests:", requests)
+
+	return requests
+}
+
 type placementDecisionMapper struct {
 	client.Client
 }
@@ -314,8 +372,20 @@ func (mapper *placementDecisionMapper) Map(obj client.Object) []reconcile.Reques
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
 func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	// the requests of the watches are handed to the controller in turn across the namespaces, so that the
+	// subscriptions of one namespace can't hold all the workers
+	fq := newFairQueue(syncWorkers)
+
 	// Create a new controller
-	c, err := controller.New("mcmhub-subscription-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("mcmhub-subscription-controller", mgr, controller.Options{
+		Reconciler:              &fairReconciler{Reconciler: r, queue: fq},
+		MaxConcurrentReconciles: syncWorkers,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Channel{Source: fq.events}, &handler.EnqueueRequestForObject{})
 	if err != nil {
 		return err
 	}
@@ -324,7 +394,7 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	smapper := &subscriptionMapper{mgr.GetClient()}
 	err = c.Watch(
 		&source.Kind{Type: &appv1.Subscription{}},
-		handler.EnqueueRequestsFromMapFunc(smapper.Map),
+		fq.handler(smapper.Map),
 		utils.SubscriptionPredicateFunctions)
 
 	if err != nil {
@@ -335,19 +405,30 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	cMapper := &channelMapper{mgr.GetClient()}
 	err = c.Watch(
 		&source.Kind{Type: &chnv1.Channel{}},
-		handler.EnqueueRequestsFromMapFunc(cMapper.Map),
+		fq.handler(cMapper.Map),
 		utils.ChannelPredicateFunctions)
 
 	if err != nil {
 		return err
 	}
 
+	// in hub, watch for channel config map changes
+	cmMapper := &channelConfigMapMapper{mgr.GetClient()}
+	err = c.Watch(
+		&source.Kind{Type: &corev1.ConfigMap{}},
+		fq.handler(cmMapper.Map),
+		utils.ChannelConfigMapPredicateFunctions)
+
+	if err != nil {
+		return err
+	}
+
 	// in hub, watch for placement decision changes
 	if utils.IsReadyPlacementDecision(mgr.GetAPIReader()) {
 		pdMapper := &placementDecisionMapper{mgr.GetClient()}
 		err = c.Watch(
 			&source.Kind{Type: &clusterapi.PlacementDecision{}},
-			handler.EnqueueRequestsFromMapFunc(pdMapper.Map), utils.PlacementDecisionPredicateFunctions)
+			fq.handler(pdMapper.Map), utils.PlacementDecisionPredicateFunctions)
 
 		if err != nil {
 			return err
@@ -539,13 +620,29 @@ func (r *ReconcileSubscription) Reconcile(ctx context.Context, request reconcile
 		instance.Status.Phase = appv1.SubscriptionPropagationFailed
 		instance.Status.Reason = "local placement and remote placement cannot be used together"
 	} else if pl != nil && (pl.PlacementRef != nil || pl.Clusters != nil || pl.ClusterSelector != nil) {
-		primaryChannel, _, err := r.getChannel(instance)
+		// switch the channel first, the target channel is registered and propagated on the next reconcile
+		if r.retargetChannel(instance) {
+			return reconcile.Result{}, nil
+		}
+
+		primaryChannel, secondaryChannel, err := r.getChannel(instance)
 
 		if err != nil {
 			klog.Errorf("Failed to find a channel for subscription: %s", instance.GetName())
 			return reconcile.Result{}, nil
 		}
 
+		// neither clone nor propagate the channels referencing hosts out of the channel source allow-list
+		if err := utils.CheckChannelSourceAllowed(primaryChannel, secondaryChannel); err != nil {
+			logger.Info(err.Error())
+
+			instance.Status.Phase = appv1.SubscriptionPropagationFailed
+			instance.Status.Reason = fmt.Sprintf("%s: %v", utils.ReasonSourceNotAllowed, err)
+			instance.Status.Statuses = nil
+
+			return reconcile.Result{}, nil
+		}
+
 		// This block is only for Git subscription
 		if strings.EqualFold(string(primaryChannel.Spec.Type), chnv1.ChannelTypeGit) ||
 			strings.EqualFold(string(primaryChannel.Spec.Type), chnv1.ChannelTypeGitHub) {
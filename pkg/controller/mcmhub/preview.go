@@ -0,0 +1,253 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcmhub
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	spokeClusterV1 "open-cluster-management.io/api/cluster/v1"
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	releasev1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/helmrelease/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	helmops "open-cluster-management.io/multicloud-operators-subscription/pkg/subscriber/helmrepo"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+// ClusterPreview is what a managed cluster gets from a subscription: the resources of its channel after the package
+// conditions, the cluster variables and the cluster overrides of the cluster
+type ClusterPreview struct {
+	Cluster string `json:"cluster"`
+	// Placed is true if the cluster is in the placement of the subscription, the preview of a cluster out of the
+	// placement is what the cluster would get once placed
+	Placed    bool                         `json:"placed"`
+	Resources []*unstructured.Unstructured `json:"resources"`
+	// Skipped are the resources whose package conditions the cluster doesn't meet, with the reason
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// ClusterPreviewer renders the subscriptions for the managed clusters from the hub, without deploying anything
+type ClusterPreviewer struct {
+	r *ReconcileSubscription
+}
+
+// NewClusterPreviewer returns the previewer of the subscriptions of the hub
+func NewClusterPreviewer(clt client.Client, restMapper meta.RESTMapper) *ClusterPreviewer {
+	return &ClusterPreviewer{r: &ReconcileSubscription{Client: clt, restMapper: restMapper}}
+}
+
+// PreviewCluster renders the Git or Helm repo channel of the subscription for the managed cluster
+func (p *ClusterPreviewer) PreviewCluster(ctx context.Context, appsub types.NamespacedName,
+	cluster string) (*ClusterPreview, error) {
+	sub := &appv1.Subscription{}
+	if err := p.r.Get(ctx, appsub, sub); err != nil {
+		return nil, err
+	}
+
+	managedCluster := &spokeClusterV1.ManagedCluster{}
+	if err := p.r.Get(ctx, types.NamespacedName{Name: cluster}, managedCluster); err != nil {
+		return nil, err
+	}
+
+	preview := &ClusterPreview{Cluster: cluster}
+
+	clusters, err := p.r.getClustersByPlacement(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cl := range clusters {
+		if cl.Cluster == cluster {
+			preview.Placed = true
+		}
+	}
+
+	resources, err := p.renderChannel(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	// the namespace of the resources is set like the hub sets the one of their object references
+	isAdmin := sub.GetAnnotations()[appv1.AnnotationClusterAdmin] == "true"
+
+	for _, resource := range resources {
+		gvk := resource.GroupVersionKind()
+
+		if !p.r.IsNamespacedResource(gvk.Group, gvk.Version, gvk.Kind) {
+			continue
+		}
+
+		if !isAdmin || resource.GetNamespace() == "" {
+			resource.SetNamespace(sub.Namespace)
+		}
+	}
+
+	facts, err := getManagedClusterFacts(managedCluster)
+	if err != nil {
+		klog.Infof("no cluster facts for the preview of appsub %v on cluster %v, %v", appsub, cluster, err)
+	}
+
+	preview.Resources, preview.Skipped, err = materializeClusterResources(sub, cluster, facts, resources)
+	if err != nil {
+		return nil, err
+	}
+
+	return preview, nil
+}
+
+// renderChannel returns the resources the subscription deploys from its channel, sorted
+func (p *ClusterPreviewer) renderChannel(sub *appv1.Subscription) ([]*unstructured.Unstructured, error) {
+	primaryChannel, secondaryChannel, err := p.r.getChannel(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	if primaryChannel == nil {
+		return nil, fmt.Errorf("subscription %v/%v has no channel", sub.Namespace, sub.Name)
+	}
+
+	resources := []*unstructured.Unstructured{}
+
+	switch tp := strings.ToLower(string(primaryChannel.Spec.Type)); tp {
+	case chnv1.ChannelTypeGit, chnv1.ChannelTypeGitHub:
+		repoRoot, err := p.r.cloneGitChannel(sub, primaryChannel, "preview")
+		if err != nil {
+			return nil, err
+		}
+
+		defer os.RemoveAll(repoRoot)
+
+		objMap, err := p.r.renderRepo(primaryChannel, sub, repoRoot, repoRoot)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range objMap {
+			resources = append(resources, obj)
+		}
+	case chnv1.ChannelTypeHelmRepo:
+		helmRls, err := helmops.GetSubscriptionChartsOnHub(p.r.Client, primaryChannel, secondaryChannel, sub)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, helmRl := range helmRls {
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(helmRl)
+			if err != nil {
+				return nil, err
+			}
+
+			obj := &unstructured.Unstructured{Object: content}
+			obj.SetGroupVersionKind(releasev1.SchemeGroupVersion.WithKind("HelmRelease"))
+
+			resources = append(resources, obj)
+		}
+	default:
+		return nil, fmt.Errorf("the subscriptions of %v channels can't be previewed", tp)
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		return objectKey(resources[i]) < objectKey(resources[j])
+	})
+
+	return resources, nil
+}
+
+// getManagedClusterFacts returns the cluster claims and the Kubernetes version the managed cluster reports to the hub
+func getManagedClusterFacts(managedCluster *spokeClusterV1.ManagedCluster) (*utils.ClusterFacts, error) {
+	if managedCluster.Status.Version.Kubernetes == "" {
+		return nil, fmt.Errorf("cluster %v has not reported its Kubernetes version", managedCluster.Name)
+	}
+
+	claims := map[string]string{}
+	for _, claim := range managedCluster.Status.ClusterClaims {
+		claims[claim.Name] = claim.Value
+	}
+
+	return utils.NewClusterFacts(claims, managedCluster.Status.Version.Kubernetes)
+}
+
+// materializeClusterResources processes the resources of the subscription for the cluster the way its synchronizer
+// does: the resources whose package conditions the cluster doesn't meet are skipped, the cluster variables are
+// substituted and the cluster overrides are applied. facts is nil if the cluster has not reported them.
+func materializeClusterResources(sub *appv1.Subscription, cluster string, facts *utils.ClusterFacts,
+	resources []*unstructured.Unstructured) ([]*unstructured.Unstructured, []string, error) {
+	overrides, err := utils.PrepareOverrides(types.NamespacedName{Name: cluster, Namespace: cluster}, sub)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var vars map[string]string
+
+	materialized := []*unstructured.Unstructured{}
+	skipped := []string{}
+
+	for _, resource := range resources {
+		if utils.HasPackageCondition(sub, resource) {
+			if facts == nil {
+				return nil, nil, fmt.Errorf("%v has package conditions, and cluster %v has not reported its facts",
+					objectKey(resource), cluster)
+			}
+
+			met, reason, err := utils.IsPackageConditionMet(sub, resource, facts)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if !met {
+				skipped = append(skipped, objectKey(resource)+": "+reason)
+
+				continue
+			}
+		}
+
+		if utils.HasClusterVariables(sub) {
+			if facts == nil {
+				return nil, nil, fmt.Errorf("appsub %v/%v has cluster variables, and cluster %v has not reported its facts",
+					sub.Namespace, sub.Name, cluster)
+			}
+
+			if vars == nil {
+				vars = utils.GetClusterVariables(facts)
+			}
+
+			resource = utils.SubstituteClusterVariables(resource, vars)
+		}
+
+		resource, err = utils.OverrideTemplate(resource, overrides)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		materialized = append(materialized, resource)
+	}
+
+	return materialized, skipped, nil
+}
+
+func objectKey(obj *unstructured.Unstructured) string {
+	return objectReferenceString(&v1.ObjectReference{Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()})
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcmhub
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	spokeClusterV1 "open-cluster-management.io/api/cluster/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestMaterializeClusterResources(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	sub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "app",
+			Namespace:   "apps",
+			Annotations: map[string]string{appv1.AnnotationClusterVariables: "true"},
+		},
+		Spec: appv1.SubscriptionSpec{
+			Overrides: []appv1.ClusterOverrides{{
+				ClusterName: "cluster1",
+				ClusterOverrides: []appv1.ClusterOverride{
+					{RawExtension: runtime.RawExtension{Raw: []byte(`{"path": "data.mode", "value": "fast"}`)}},
+				},
+			}},
+		},
+	}
+
+	settings := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "settings", "namespace": "apps"},
+		"data":       map[string]interface{}{"mode": "slow", "region": "${CLUSTER_REGION}"},
+	}}
+
+	aws := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{"name": "aws", "namespace": "apps",
+			"annotations": map[string]interface{}{appv1.AnnotationClusterSelector: "platform.open-cluster-management.io=AWS"}},
+	}}
+
+	cluster := &spokeClusterV1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster1"},
+		Status: spokeClusterV1.ManagedClusterStatus{
+			Version: spokeClusterV1.ManagedClusterVersion{Kubernetes: "v1.23.3"},
+			ClusterClaims: []spokeClusterV1.ManagedClusterClaim{
+				{Name: "platform.open-cluster-management.io", Value: "GCP"},
+				{Name: "region.open-cluster-management.io", Value: "us-east1"},
+			},
+		},
+	}
+
+	facts, err := getManagedClusterFacts(cluster)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	resources, skipped, err := materializeClusterResources(sub, "cluster1", facts,
+		[]*unstructured.Unstructured{aws, settings})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(skipped).To(gomega.Equal([]string{"ConfigMap apps/aws: " +
+		"cluster claims don't match platform.open-cluster-management.io=AWS"}))
+	g.Expect(resources).To(gomega.HaveLen(1))
+	g.Expect(resources[0].Object["data"]).To(gomega.Equal(map[string]interface{}{"mode": "fast", "region": "us-east1"}))
+
+	// the channel resources are left as they are
+	g.Expect(settings.Object["data"]).To(gomega.HaveKeyWithValue("mode", "slow"))
+
+	// the overrides of the other clusters don't apply
+	resources, _, err = materializeClusterResources(sub, "cluster2", facts, []*unstructured.Unstructured{settings})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(resources[0].Object["data"]).To(gomega.HaveKeyWithValue("mode", "slow"))
+
+	// the cluster variables need the facts of the cluster
+	cluster.Status.Version.Kubernetes = ""
+
+	_, err = getManagedClusterFacts(cluster)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	_, _, err = materializeClusterResources(sub, "cluster1", nil, []*unstructured.Unstructured{settings})
+	g.Expect(err).To(gomega.HaveOccurred())
+}
@@ -143,7 +143,30 @@ func (r *ReconcileSubscription) propagateManifestWorks(clusters []ManageClusters
 		return nil, err
 	}
 
+	// the clusters whose capability couldn't be checked are retried, their manifestWorks are kept meanwhile
+	capErrs := []string{}
+
 	for _, cluster := range clusters {
+		if capErr := r.checkClusterCapability(cluster, instance); capErr != nil {
+			klog.Errorf("Skip propagating to cluster: %v, error: %v", cluster.Cluster, capErr)
+
+			if IsMissingDependency(capErr) {
+				r.eventRecorder.RecordEvent(instance, reasonMissingDependency, capErr.Error(), capErr)
+			} else {
+				capErrs = append(capErrs, fmt.Sprintf("cluster %v: %v", cluster.Cluster, capErr))
+			}
+
+			// keep the existing manifestWork, the cluster could recover without losing the deployed app
+			delete(familymap, cluster.Cluster+"-"+instance.GetNamespace()+"-"+instance.GetName())
+
+			capErr = utils.CreateFailedAppsubReportResult(r.Client, cluster.Cluster, instance.Namespace, instance.Name, capErr.Error())
+			if capErr != nil {
+				klog.Error("Error create cluster appsubReport: ", capErr)
+			}
+
+			continue
+		}
+
 		familymap, err = r.createManifestWork(cluster, hosting, instance, familymap)
 		if err != nil {
 			klog.Errorf("Error in propagating to cluster: %v, error:%v", cluster.Cluster, err)
@@ -155,6 +178,10 @@ func (r *ReconcileSubscription) propagateManifestWorks(clusters []ManageClusters
 		}
 	}
 
+	if len(capErrs) > 0 {
+		return familymap, fmt.Errorf("failed to check the capability of the clusters, %v", strings.Join(capErrs, "; "))
+	}
+
 	return familymap, nil
 }
 
@@ -495,6 +522,13 @@ func (r *ReconcileSubscription) updateSubAnnotations(sub *appSubV1.Subscription,
 		subepanno[appSubV1.AnnotationGitPath] = origsubanno[appSubV1.AnnotationGithubPath]
 	}
 
+	for _, key := range []string{appSubV1.AnnotationGitPaths, appSubV1.AnnotationGitIncludePaths, appSubV1.AnnotationGitExcludePaths,
+		appSubV1.AnnotationGitPathExpect} {
+		if !strings.EqualFold(origsubanno[key], "") {
+			subepanno[key] = origsubanno[key]
+		}
+	}
+
 	if !strings.EqualFold(origsubanno[appSubV1.AnnotationBucketPath], "") {
 		subepanno[appSubV1.AnnotationBucketPath] = origsubanno[appSubV1.AnnotationBucketPath]
 	}
@@ -507,6 +541,10 @@ func (r *ReconcileSubscription) updateSubAnnotations(sub *appSubV1.Subscription,
 		subepanno[appSubV1.AnnotationCurrentNamespaceScoped] = origsubanno[appSubV1.AnnotationCurrentNamespaceScoped]
 	}
 
+	if !strings.EqualFold(origsubanno[appSubV1.AnnotationPreserveNamespace], "") {
+		subepanno[appSubV1.AnnotationPreserveNamespace] = origsubanno[appSubV1.AnnotationPreserveNamespace]
+	}
+
 	if !strings.EqualFold(origsubanno[appSubV1.AnnotationResourceReconcileOption], "") {
 		subepanno[appSubV1.AnnotationResourceReconcileOption] = origsubanno[appSubV1.AnnotationResourceReconcileOption]
 	}
@@ -531,6 +569,38 @@ func (r *ReconcileSubscription) updateSubAnnotations(sub *appSubV1.Subscription,
 		subepanno[appSubV1.AnnotationManualReconcileTime] = origsubanno[appSubV1.AnnotationManualReconcileTime]
 	}
 
+	if !strings.EqualFold(origsubanno[appSubV1.AnnotationResyncPackage], "") {
+		subepanno[appSubV1.AnnotationResyncPackage] = origsubanno[appSubV1.AnnotationResyncPackage]
+	}
+
+	if !strings.EqualFold(origsubanno[appSubV1.AnnotationTemplateParameters], "") {
+		subepanno[appSubV1.AnnotationTemplateParameters] = origsubanno[appSubV1.AnnotationTemplateParameters]
+	}
+
+	if !strings.EqualFold(origsubanno[appSubV1.AnnotationGitSigningKeys], "") {
+		subepanno[appSubV1.AnnotationGitSigningKeys] = origsubanno[appSubV1.AnnotationGitSigningKeys]
+	}
+
+	if !strings.EqualFold(origsubanno[appSubV1.AnnotationSopsDecryptionSecret], "") {
+		subepanno[appSubV1.AnnotationSopsDecryptionSecret] = origsubanno[appSubV1.AnnotationSopsDecryptionSecret]
+	}
+
+	if !strings.EqualFold(origsubanno[appSubV1.AnnotationClusterVariables], "") {
+		subepanno[appSubV1.AnnotationClusterVariables] = origsubanno[appSubV1.AnnotationClusterVariables]
+	}
+
+	if !strings.EqualFold(origsubanno[appSubV1.AnnotationChannelVariables], "") {
+		subepanno[appSubV1.AnnotationChannelVariables] = origsubanno[appSubV1.AnnotationChannelVariables]
+	}
+
+	if !strings.EqualFold(origsubanno[appSubV1.AnnotationHelmReleaseNamespace], "") {
+		subepanno[appSubV1.AnnotationHelmReleaseNamespace] = origsubanno[appSubV1.AnnotationHelmReleaseNamespace]
+	}
+
+	if !strings.EqualFold(origsubanno[appSubV1.AnnotationConflictStrategy], "") {
+		subepanno[appSubV1.AnnotationConflictStrategy] = origsubanno[appSubV1.AnnotationConflictStrategy]
+	}
+
 	// Keep cluster admin annotation from the source subscription.
 	if !strings.EqualFold(origsubanno[appSubV1.AnnotationClusterAdmin], "") {
 		subepanno[appSubV1.AnnotationClusterAdmin] = origsubanno[appSubV1.AnnotationClusterAdmin]
@@ -550,14 +620,32 @@ func (r *ReconcileSubscription) updateSubAnnotations(sub *appSubV1.Subscription,
 		if err != nil {
 			klog.Error("Failed to get PackageFilter.FilterRef of subsciption, error: ", err)
 		} else {
+			// the paths of the ConfigMap take precedence over its path, and the annotations over both
 			gitPath := subscriptionConfigMap.Data["path"]
-			if gitPath != "" {
+			gitPaths := utils.ParsePathPatterns(subscriptionConfigMap.Data[utils.FilterRefPaths])
+			if len(gitPaths) != 0 {
+				if subepanno[appSubV1.AnnotationGitPath] == "" && subepanno[appSubV1.AnnotationGitPaths] == "" {
+					subepanno[appSubV1.AnnotationGitPaths] = strings.Join(gitPaths, ",")
+				}
+			} else if gitPath != "" && subepanno[appSubV1.AnnotationGitPaths] == "" {
 				subepanno[appSubV1.AnnotationGitPath] = gitPath
 			}
 			gitBranch := subscriptionConfigMap.Data["branch"]
 			if gitBranch != "" {
 				subepanno[appSubV1.AnnotationGitBranch] = gitBranch
 			}
+			// the ConfigMap may not be on the managed cluster, pass the path patterns the annotations don't override
+			include, exclude := utils.GetGitPathPatterns(sub, subscriptionConfigMap)
+			if len(include) != 0 {
+				subepanno[appSubV1.AnnotationGitIncludePaths] = strings.Join(include, ",")
+			}
+			if len(exclude) != 0 {
+				subepanno[appSubV1.AnnotationGitExcludePaths] = strings.Join(exclude, ",")
+			}
+			if subepanno[appSubV1.AnnotationGitPathExpect] == "" && subscriptionConfigMap.Data[utils.FilterRefExpect] != "" {
+				subepanno[appSubV1.AnnotationGitPathExpect] = strings.Join(
+					utils.ParsePathPatterns(subscriptionConfigMap.Data[utils.FilterRefExpect]), ",")
+			}
 		}
 	}
 
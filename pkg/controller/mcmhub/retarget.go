@@ -0,0 +1,264 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcmhub
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	helmops "open-cluster-management.io/multicloud-operators-subscription/pkg/subscriber/helmrepo"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+// retargetChannel processes the retarget of the subscription spec. The subscription is switched to the target channel
+// if the target has all the packages of the current channel, or if the deletion of the missing packages is allowed.
+// It returns true if the spec is changed, the subscription is then reconciled again with its new channel.
+func (r *ReconcileSubscription) retargetChannel(sub *appv1.Subscription) bool {
+	retarget := sub.Spec.Retarget
+	if retarget == nil {
+		return false
+	}
+
+	if retarget.Channel == sub.Spec.Channel {
+		klog.Infof("subscription %v/%v is already on channel %v", sub.Namespace, sub.Name, retarget.Channel)
+
+		sub.Spec.Retarget = nil
+
+		return true
+	}
+
+	status := &appv1.ChannelRetargetStatus{FromChannel: sub.Spec.Channel, ToChannel: retarget.Channel}
+
+	added, deleted, err := r.diffChannelPackages(sub, retarget.Channel)
+	if err != nil {
+		klog.Errorf("failed to compare the packages of channels %v and %v for subscription %v/%v, err: %v",
+			sub.Spec.Channel, retarget.Channel, sub.Namespace, sub.Name, err)
+
+		status.Phase = appv1.ChannelRetargetFailed
+		status.Message = err.Error()
+		sub.Status.Retarget = status
+
+		return false
+	}
+
+	status.AddedPackages = added
+	status.DeletedPackages = deleted
+
+	if len(deleted) > 0 && !retarget.AllowPackageDeletion {
+		klog.Infof("retarget of subscription %v/%v to channel %v would delete packages %v, waiting for confirmation",
+			sub.Namespace, sub.Name, retarget.Channel, deleted)
+
+		status.Phase = appv1.ChannelRetargetBlocked
+		status.Message = "the switch deletes " + strconv.Itoa(len(deleted)) +
+			" packages missing from the target channel, set spec.retarget.allowPackageDeletion to true to confirm"
+		sub.Status.Retarget = status
+
+		return false
+	}
+
+	// the status is patched before the spec, the final commit of the reconcile doesn't patch it on spec changes
+	status.Phase = appv1.ChannelRetargetCompleted
+	status.Message = fmt.Sprintf("switched from channel %v to %v", sub.Spec.Channel, retarget.Channel)
+
+	orig := sub.DeepCopy()
+	sub.Status.Retarget = status
+
+	if err := r.Client.Status().Patch(context.TODO(), sub, client.MergeFrom(orig)); err != nil {
+		klog.Errorf("failed to update the retarget status of subscription %v/%v, err: %v", sub.Namespace, sub.Name, err)
+
+		return false
+	}
+
+	klog.Infof("switching subscription %v/%v from channel %v to %v", sub.Namespace, sub.Name, sub.Spec.Channel, retarget.Channel)
+
+	sub.Spec.Channel = retarget.Channel
+	sub.Spec.Retarget = nil
+
+	return true
+}
+
+// diffChannelPackages returns the packages the subscription would add and delete by switching to the target channel
+func (r *ReconcileSubscription) diffChannelPackages(sub *appv1.Subscription, target string) ([]string, []string, error) {
+	current, err := parseGetChannel(r.Client, sub.Spec.Channel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get channel %v, err: %w", sub.Spec.Channel, err)
+	}
+
+	targetChannel, err := parseGetChannel(r.Client, target)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get channel %v, err: %w", target, err)
+	}
+
+	if !strings.EqualFold(string(current.Spec.Type), string(targetChannel.Spec.Type)) &&
+		!(utils.IsGitChannel(string(current.Spec.Type)) && utils.IsGitChannel(string(targetChannel.Spec.Type))) {
+		return nil, nil, fmt.Errorf("channel %v is of type %v, not %v", target, targetChannel.Spec.Type, current.Spec.Type)
+	}
+
+	currentPackages, err := r.getChannelPackages(sub, current)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list the packages of channel %v, err: %w", sub.Spec.Channel, err)
+	}
+
+	targetPackages, err := r.getChannelPackages(sub, targetChannel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list the packages of channel %v, err: %w", target, err)
+	}
+
+	added, deleted := diffPackages(currentPackages, targetPackages)
+
+	return added, deleted, nil
+}
+
+// getChannelPackages returns the packages the subscription deploys from the channel: the charts of the Helm repos,
+// and the resources of the Git repos and the object buckets
+func (r *ReconcileSubscription) getChannelPackages(sub *appv1.Subscription, chn *chnv1.Channel) ([]string, error) {
+	isAdmin := sub.GetAnnotations()[appv1.AnnotationClusterAdmin] == "true"
+
+	var resources []*v1.ObjectReference
+
+	var err error
+
+	switch tp := strings.ToLower(string(chn.Spec.Type)); tp {
+	case chnv1.ChannelTypeGit, chnv1.ChannelTypeGitHub:
+		resources, err = r.getGitChannelResources(sub, chn, isAdmin)
+	case chnv1.ChannelTypeHelmRepo:
+		helmRls, err := helmops.GetSubscriptionChartsOnHub(r.Client, chn, nil, sub)
+		if err != nil {
+			return nil, err
+		}
+
+		packages := []string{}
+
+		for _, helmRl := range helmRls {
+			packages = append(packages, helmRl.Repo.ChartName)
+		}
+
+		return packages, nil
+	case chnv1.ChannelTypeObjectBucket:
+		resources, err = r.getObjectBucketResources(sub, chn, nil, isAdmin)
+	default:
+		return nil, fmt.Errorf("the packages of %v channels can't be listed", tp)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	packages := []string{}
+
+	for _, resource := range resources {
+		packages = append(packages, objectReferenceString(resource))
+	}
+
+	return packages, nil
+}
+
+// getGitChannelResources clones the Git channel in a temporary directory to list the resources the subscription
+// deploys from it
+func (r *ReconcileSubscription) getGitChannelResources(sub *appv1.Subscription, chn *chnv1.Channel,
+	isAdmin bool) ([]*v1.ObjectReference, error) {
+	repoRoot, err := r.cloneGitChannel(sub, chn, "retarget")
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.RemoveAll(repoRoot)
+
+	return r.processRepo(chn, sub, repoRoot, repoRoot, isAdmin)
+}
+
+// cloneGitChannel clones the branch, commit or tag of the subscription from the Git channel in a new temporary
+// directory, the caller removes it
+func (r *ReconcileSubscription) cloneGitChannel(sub *appv1.Subscription, chn *chnv1.Channel, prefix string) (string, error) {
+	connectionConfig, err := getGitConnectionConfig(r.Client, chn)
+	if err != nil {
+		return "", err
+	}
+
+	repoRoot, err := ioutil.TempDir("", prefix)
+	if err != nil {
+		return "", err
+	}
+
+	branchName, commit, tag, _ := getBranchCommitDepthAndTag(sub)
+
+	if _, err := cloneGitRepoBranch(&utils.GitCloneOption{
+		Branch:                  utils.GetSubscriptionBranchRef(branchName),
+		CommitHash:              commit,
+		RevisionTag:             tag,
+		RevisionTagConstraint:   utils.GetSubscriptionGitTagConstraint(sub),
+		DestDir:                 repoRoot,
+		PrimaryConnectionOption: connectionConfig,
+	}); err != nil {
+		os.RemoveAll(repoRoot)
+
+		return "", err
+	}
+
+	return repoRoot, nil
+}
+
+// diffPackages returns the sorted packages of target only, and of current only
+func diffPackages(current, target []string) ([]string, []string) {
+	currentSet := map[string]bool{}
+	for _, pkg := range current {
+		currentSet[pkg] = true
+	}
+
+	targetSet := map[string]bool{}
+	for _, pkg := range target {
+		targetSet[pkg] = true
+	}
+
+	added := []string{}
+
+	for pkg := range targetSet {
+		if !currentSet[pkg] {
+			added = append(added, pkg)
+		}
+	}
+
+	deleted := []string{}
+
+	for pkg := range currentSet {
+		if !targetSet[pkg] {
+			deleted = append(deleted, pkg)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(deleted)
+
+	return added, deleted
+}
+
+func objectReferenceString(ref *v1.ObjectReference) string {
+	if ref.Namespace == "" {
+		return ref.Kind + " " + ref.Name
+	}
+
+	return ref.Kind + " " + ref.Namespace + "/" + ref.Name
+}
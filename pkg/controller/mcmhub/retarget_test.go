@@ -0,0 +1,37 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcmhub
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestDiffPackages(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	added, deleted := diffPackages([]string{"nginx", "redis", "mongodb", "nginx"}, []string{"nginx", "postgresql", "mongodb"})
+	g.Expect(added).To(gomega.Equal([]string{"postgresql"}))
+	g.Expect(deleted).To(gomega.Equal([]string{"redis"}))
+
+	added, deleted = diffPackages([]string{"nginx"}, []string{"nginx"})
+	g.Expect(added).To(gomega.BeEmpty())
+	g.Expect(deleted).To(gomega.BeEmpty())
+
+	g.Expect(objectReferenceString(&v1.ObjectReference{Kind: "ConfigMap", Namespace: "ns", Name: "cm"})).To(gomega.Equal("ConfigMap ns/cm"))
+	g.Expect(objectReferenceString(&v1.ObjectReference{Kind: "ClusterRole", Name: "role"})).To(gomega.Equal("ClusterRole role"))
+}
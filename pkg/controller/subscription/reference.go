@@ -15,12 +15,20 @@
 package subscription
 
 import (
+	"context"
+	"strings"
+
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
 	subutil "open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
 )
@@ -54,3 +62,71 @@ func (r *ReconcileSubscription) ListAndDeployReferredObject(instance *appv1.Subs
 func (r *ReconcileSubscription) DeleteReferredObjects(rq types.NamespacedName, gvk schema.GroupVersionKind) error {
 	return subutil.DeleteReferredObjects(r.Client, rq, gvk)
 }
+
+// referenceMapper maps the config maps and secrets referenced by the subscriptions to the subscriptions, so their
+// change is deployed without waiting for the next reconcile: the package filter config maps and, on a standalone
+// cluster, the config maps and secrets of the channels. The channels of a managed cluster are on the hub.
+type referenceMapper struct {
+	client.Client
+	standalone bool
+}
+
+func (mapper *referenceMapper) Map(obj client.Object) []reconcile.Request {
+	_, isConfigMap := obj.(*corev1.ConfigMap)
+
+	chns := map[string]bool{}
+
+	if mapper.standalone {
+		chnList := &chnv1.ChannelList{}
+		if err := mapper.List(context.TODO(), chnList, client.InNamespace(obj.GetNamespace())); err != nil {
+			klog.Error("Listing channels in referenceMapper and got error:", err)
+
+			return nil
+		}
+
+		for _, chn := range chnList.Items {
+			ref := chn.Spec.SecretRef
+			if isConfigMap {
+				ref = chn.Spec.ConfigMapRef
+			}
+
+			if ref != nil && ref.Name == obj.GetName() {
+				chns[chn.GetNamespace()+"/"+chn.GetName()] = true
+			}
+		}
+	}
+
+	subList := &appv1.SubscriptionList{}
+	if err := mapper.List(context.TODO(), subList); err != nil {
+		klog.Error("Listing all subscriptions in referenceMapper and got error:", err)
+
+		return nil
+	}
+
+	var requests []reconcile.Request
+
+	for i := range subList.Items {
+		sub := &subList.Items[i]
+
+		if chns[sub.Spec.Channel] || chns[sub.Spec.SecondaryChannel] || (isConfigMap && isFilterRef(sub, obj)) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: sub.GetName(), Namespace: sub.GetNamespace()},
+			})
+		}
+	}
+
+	klog.V(5).Info("Out reference mapper with requests:", requests)
+
+	return requests
+}
+
+// isFilterRef returns true if the config map is the package filter config map of the subscription, in the namespace
+// of the subscription or of its channel
+func isFilterRef(sub *appv1.Subscription, cm client.Object) bool {
+	if sub.Spec.PackageFilter == nil || sub.Spec.PackageFilter.FilterRef == nil ||
+		sub.Spec.PackageFilter.FilterRef.Name != cm.GetName() {
+		return false
+	}
+
+	return sub.GetNamespace() == cm.GetNamespace() || strings.HasPrefix(sub.Spec.Channel, cm.GetNamespace()+"/")
+}
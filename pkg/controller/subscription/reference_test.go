@@ -22,13 +22,18 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/onsi/gomega"
 
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
 	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	subutil "open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
 )
 
 var (
@@ -195,3 +200,75 @@ func TestDeleteReferredObjects(t *testing.T) {
 		})
 	}
 }
+
+func TestReferenceMapper(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(appv1alpha1.SchemeBuilder.AddToScheme(scheme)).To(gomega.Succeed())
+	g.Expect(chnv1.AddToScheme(scheme)).To(gomega.Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(gomega.Succeed())
+
+	chn := &chnv1.Channel{
+		ObjectMeta: metav1.ObjectMeta{Name: chKey.Name, Namespace: chKey.Namespace},
+		Spec: chnv1.ChannelSpec{
+			Type:         chnv1.ChannelTypeGit,
+			SecretRef:    &corev1.ObjectReference{Name: "git-creds"},
+			ConfigMapRef: &corev1.ObjectReference{Name: "git-ca"},
+		},
+	}
+
+	newSub := func(name, filterRef string) *appv1alpha1.Subscription {
+		sub := &appv1alpha1.Subscription{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: nssubTest},
+			Spec:       appv1alpha1.SubscriptionSpec{Channel: chKey.String()},
+		}
+
+		if filterRef != "" {
+			sub.Spec.PackageFilter = &appv1alpha1.PackageFilter{FilterRef: &corev1.LocalObjectReference{Name: filterRef}}
+		}
+
+		return sub
+	}
+
+	clt := fake.NewClientBuilder().WithScheme(scheme).WithObjects(chn, newSub("sub-a", "paths"), newSub("sub-b", "")).Build()
+
+	subA := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: nssubTest, Name: "sub-a"}}
+	subB := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: nssubTest, Name: "sub-b"}}
+
+	secret := func(namespace, name string) client.Object {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	}
+
+	configMap := func(namespace, name string) client.Object {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	}
+
+	mapper := &referenceMapper{Client: clt, standalone: true}
+
+	g.Expect(mapper.Map(secret(chKey.Namespace, "git-creds"))).To(gomega.ConsistOf(subA, subB))
+	g.Expect(mapper.Map(configMap(chKey.Namespace, "git-ca"))).To(gomega.ConsistOf(subA, subB))
+	g.Expect(mapper.Map(configMap(nssubTest, "paths"))).To(gomega.ConsistOf(subA))
+	g.Expect(mapper.Map(configMap(chKey.Namespace, "paths"))).To(gomega.ConsistOf(subA))
+	g.Expect(mapper.Map(secret(nssubTest, "paths"))).To(gomega.BeEmpty())
+	g.Expect(mapper.Map(secret(nssubTest, "git-creds"))).To(gomega.BeEmpty())
+
+	// the channels of a managed cluster are on the hub
+	mapper.standalone = false
+
+	g.Expect(mapper.Map(secret(chKey.Namespace, "git-creds"))).To(gomega.BeEmpty())
+	g.Expect(mapper.Map(configMap(nssubTest, "paths"))).To(gomega.ConsistOf(subA))
+
+	// the subscriber items are reconciled again only if the data of a reference changed
+	previous := &appv1alpha1.SubscriberItem{
+		ChannelSecret: &corev1.Secret{Data: map[string][]byte{"accessToken": []byte("old")}},
+	}
+	item := previous.DeepCopy()
+	item.ChannelSecret.ResourceVersion = "2"
+	item.SubscriptionConfigMap = &corev1.ConfigMap{}
+
+	g.Expect(subutil.IsSubscriberItemReferenceChanged(previous, item)).To(gomega.BeFalse())
+
+	item.ChannelSecret.Data["accessToken"] = []byte("new")
+	g.Expect(subutil.IsSubscriberItemReferenceChanged(previous, item)).To(gomega.BeTrue())
+}
@@ -102,7 +102,7 @@ func (mapper *channelMapper) Map(obj client.Object) []reconcile.Request {
 	}
 
 	for _, sub := range subList.Items {
-		if sub.Spec.Channel == chn {
+		if sub.Spec.Channel == chn || sub.Spec.SecondaryChannel == chn {
 			objkey := types.NamespacedName{
 				Name:      sub.GetName(),
 				Namespace: sub.GetNamespace(),
@@ -161,7 +161,21 @@ func add(mgr manager.Manager, r reconcile.Reconciler, standalone bool) error {
 		}
 	}
 
-	return nil
+	// Watch for changes to the config maps and secrets referenced by the subscriptions
+	rmapper := &referenceMapper{Client: mgr.GetClient(), standalone: standalone}
+
+	err = c.Watch(
+		&source.Kind{Type: &corev1.ConfigMap{}},
+		handler.EnqueueRequestsFromMapFunc(rmapper.Map),
+		utils.ChannelConfigMapPredicateFunctions)
+	if err != nil {
+		return err
+	}
+
+	return c.Watch(
+		&source.Kind{Type: &corev1.Secret{}},
+		handler.EnqueueRequestsFromMapFunc(rmapper.Map),
+		utils.ChannelSecretPredicateFunctions)
 }
 
 // blank assignment to verify that ReconcileSubscription implements reconcile.Reconciler.
@@ -345,6 +359,10 @@ func (r *ReconcileSubscription) doReconcile(instance *appv1.Subscription) error
 		}
 	}
 
+	if err := utils.CheckChannelSourceAllowed(subitem.Channel, subitem.SecondaryChannel); err != nil {
+		return fmt.Errorf("%s: %w", utils.ReasonSourceNotAllowed, err)
+	}
+
 	if subitem.Channel.Spec.SecretRef != nil {
 		subitem.ChannelSecret = &corev1.Secret{}
 		chnseckey := types.NamespacedName{
@@ -0,0 +1,390 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subscriptionset
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	spokeClusterV1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	placementv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/placementrule/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+// Add creates a new SubscriptionSet Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager) error {
+	return add(mgr, newReconciler(mgr))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	return &ReconcileSubscriptionSet{Client: mgr.GetClient()}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	c, err := controller.New("subscriptionset-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource SubscriptionSet
+	err = c.Watch(&source.Kind{Type: &appv1.SubscriptionSet{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	// Watch the generated subscriptions, so that manual changes are reverted
+	err = c.Watch(&source.Kind{Type: &appv1.Subscription{}}, &handler.EnqueueRequestForOwner{
+		OwnerType: &appv1.SubscriptionSet{}, IsController: true})
+	if err != nil {
+		return err
+	}
+
+	// Watch for managed cluster changes, the cluster selector of every set may pick them up
+	cmapper := &clusterMapper{mgr.GetClient()}
+
+	return c.Watch(&source.Kind{Type: &spokeClusterV1.ManagedCluster{}}, handler.EnqueueRequestsFromMapFunc(cmapper.Map))
+}
+
+type clusterMapper struct {
+	client.Client
+}
+
+func (mapper *clusterMapper) Map(obj client.Object) []reconcile.Request {
+	setList := &appv1.SubscriptionSetList{}
+	if err := mapper.List(context.TODO(), setList); err != nil {
+		klog.Error("Listing all subscriptionsets in clusterMapper and got error:", err)
+
+		return nil
+	}
+
+	var requests []reconcile.Request
+
+	for _, set := range setList.Items {
+		if set.Spec.Generator.ClusterSelector == nil {
+			continue
+		}
+
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: set.Name, Namespace: set.Namespace}})
+	}
+
+	return requests
+}
+
+var _ reconcile.Reconciler = &ReconcileSubscriptionSet{}
+
+// ReconcileSubscriptionSet reconciles a SubscriptionSet object
+type ReconcileSubscriptionSet struct {
+	client.Client
+}
+
+// Reconcile stamps out a subscription per generated parameter set and prunes the ones no longer generated.
+func (r *ReconcileSubscriptionSet) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	klog.Info("Reconciling SubscriptionSet: ", request.NamespacedName)
+
+	set := &appv1.SubscriptionSet{}
+
+	err := r.Get(ctx, request.NamespacedName, set)
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+
+		// the set is gone, the subscriptions it generated are garbage collected with it
+		return reconcile.Result{}, nil
+	}
+
+	clusters, err := r.getClusters(ctx, set)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	subs, err := generateSubscriptions(set, clusters)
+	if err != nil {
+		return reconcile.Result{}, r.updateStatus(ctx, set, nil, err.Error())
+	}
+
+	keep := map[types.NamespacedName]bool{}
+	names := []string{}
+
+	for _, sub := range subs {
+		if err := r.applySubscription(ctx, sub); err != nil {
+			klog.Errorf("failed to apply generated subscription %v/%v, err: %v", sub.Namespace, sub.Name, err)
+
+			return reconcile.Result{}, err
+		}
+
+		keep[types.NamespacedName{Namespace: sub.Namespace, Name: sub.Name}] = true
+
+		names = append(names, sub.Namespace+"/"+sub.Name)
+	}
+
+	if err := r.pruneSubscriptions(ctx, set, keep); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	sort.Strings(names)
+
+	return reconcile.Result{}, r.updateStatus(ctx, set, names, "")
+}
+
+func (r *ReconcileSubscriptionSet) getClusters(ctx context.Context, set *appv1.SubscriptionSet) ([]string, error) {
+	gen := set.Spec.Generator
+
+	clusters := append([]string{}, gen.Clusters...)
+
+	if gen.ClusterSelector != nil {
+		selector, err := utils.ConvertLabels(gen.ClusterSelector)
+		if err != nil {
+			return nil, err
+		}
+
+		clList := &spokeClusterV1.ManagedClusterList{}
+		if err := r.List(ctx, clList, &client.ListOptions{LabelSelector: selector}); err != nil {
+			return nil, err
+		}
+
+		for _, cl := range clList.Items {
+			clusters = append(clusters, cl.Name)
+		}
+	}
+
+	return clusters, nil
+}
+
+// generateSubscriptions renders the set template for every cluster of the generator, in the namespace of the set.
+// If the generator has no cluster, one subscription is generated with the template placement.
+func generateSubscriptions(set *appv1.SubscriptionSet, clusters []string) ([]*appv1.Subscription, error) {
+	tpl, err := json.Marshal(set.Spec.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	subs := []*appv1.Subscription{}
+
+	if len(clusters) == 0 && (len(set.Spec.Generator.Clusters) > 0 || set.Spec.Generator.ClusterSelector != nil) {
+		// a cluster generator without any matching cluster generates nothing
+		return subs, nil
+	}
+
+	targets := clusters
+	if len(targets) == 0 {
+		targets = []string{""}
+	}
+
+	for _, cluster := range targets {
+		name := set.Name
+		if cluster != "" {
+			name = set.Name + "-" + cluster
+		}
+
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		sub, err := renderSubscription(set, string(tpl), set.Namespace, name, cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
+
+func renderSubscription(set *appv1.SubscriptionSet, tpl, namespace, name, cluster string) (*appv1.Subscription, error) {
+	rendered := strings.NewReplacer(
+		appv1.SubscriptionSetParamCluster, cluster,
+		appv1.SubscriptionSetParamNamespace, namespace,
+		appv1.SubscriptionSetParamName, set.Name,
+	).Replace(tpl)
+
+	subTpl := &appv1.SubscriptionTemplate{}
+	if err := json.Unmarshal([]byte(rendered), subTpl); err != nil {
+		return nil, fmt.Errorf("failed to render subscription template for cluster %v, err: %w", cluster, err)
+	}
+
+	lbls := subTpl.Labels
+	if lbls == nil {
+		lbls = map[string]string{}
+	}
+
+	lbls[appv1.LabelSubscriptionSet] = subscriptionSetLabelValue(set.Namespace, set.Name)
+
+	annotations := subTpl.Annotations
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	// the generated subscriptions have the identity of the author of the set, stamped on the set by the admission
+	// webhook, never the one of the template
+	for _, key := range []string{appv1.AnnotationUserIdentity, appv1.AnnotationUserGroup} {
+		delete(annotations, key)
+
+		if value := set.GetAnnotations()[key]; value != "" {
+			annotations[key] = value
+		}
+	}
+
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+
+	sub := &appv1.Subscription{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appv1.SchemeGroupVersion.String(),
+			Kind:       "Subscription",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      lbls,
+			Annotations: annotations,
+			// the generated subscriptions are garbage collected with the set
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(set, appv1.SchemeGroupVersion.WithKind("SubscriptionSet"))},
+		},
+		Spec: subTpl.Spec,
+	}
+
+	if cluster != "" {
+		sub.Spec.Placement = &placementv1.Placement{
+			GenericPlacementFields: placementv1.GenericPlacementFields{
+				Clusters: []placementv1.GenericClusterReference{{Name: cluster}},
+			},
+		}
+	}
+
+	return sub, nil
+}
+
+// subscriptionSetLabelValue returns namespace.name of the set, or a prefix of it followed by a hash of the full value
+// if it is longer than a label value can be
+func subscriptionSetLabelValue(namespace, name string) string {
+	value := namespace + "." + name
+	if len(value) <= 63 {
+		return value
+	}
+
+	return fmt.Sprintf("%s-%x", value[:52], sha256.Sum256([]byte(value)))[:63]
+}
+
+func (r *ReconcileSubscriptionSet) applySubscription(ctx context.Context, sub *appv1.Subscription) error {
+	existing := &appv1.Subscription{}
+
+	err := r.Get(ctx, types.NamespacedName{Namespace: sub.Namespace, Name: sub.Name}, existing)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			klog.Infof("creating generated subscription %v/%v", sub.Namespace, sub.Name)
+
+			return r.Create(ctx, sub)
+		}
+
+		return err
+	}
+
+	if owner := metav1.GetControllerOf(existing); owner == nil || owner.UID != metav1.GetControllerOf(sub).UID {
+		return fmt.Errorf("subscription %v/%v already exists and is not generated by this subscriptionset", sub.Namespace, sub.Name)
+	}
+
+	if equality.Semantic.DeepEqual(existing.Spec, sub.Spec) &&
+		equality.Semantic.DeepEqual(existing.GetLabels(), sub.GetLabels()) &&
+		equality.Semantic.DeepEqual(existing.GetAnnotations(), sub.GetAnnotations()) {
+		return nil
+	}
+
+	existing.SetLabels(sub.GetLabels())
+	existing.SetAnnotations(sub.GetAnnotations())
+	existing.Spec = sub.Spec
+
+	klog.Infof("updating generated subscription %v/%v", sub.Namespace, sub.Name)
+
+	return r.Update(ctx, existing)
+}
+
+// pruneSubscriptions deletes the subscriptions generated by the set which are not in keep. The subscriptions with
+// the label of the set but not controlled by it are left alone.
+func (r *ReconcileSubscriptionSet) pruneSubscriptions(ctx context.Context, set *appv1.SubscriptionSet, keep map[types.NamespacedName]bool) error {
+	subList := &appv1.SubscriptionList{}
+
+	err := r.List(ctx, subList, client.InNamespace(set.Namespace),
+		client.MatchingLabels{appv1.LabelSubscriptionSet: subscriptionSetLabelValue(set.Namespace, set.Name)})
+	if err != nil {
+		return err
+	}
+
+	for i := range subList.Items {
+		sub := &subList.Items[i]
+
+		if keep[types.NamespacedName{Namespace: sub.Namespace, Name: sub.Name}] {
+			continue
+		}
+
+		if owner := metav1.GetControllerOf(sub); owner == nil || owner.UID != set.UID {
+			klog.Infof("keeping subscription %v/%v, it is not generated by subscriptionset %v/%v", sub.Namespace, sub.Name,
+				set.Namespace, set.Name)
+
+			continue
+		}
+
+		klog.Infof("deleting subscription %v/%v no longer generated by subscriptionset %v/%v", sub.Namespace, sub.Name,
+			set.Namespace, set.Name)
+
+		if err := r.Delete(ctx, sub); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *ReconcileSubscriptionSet) updateStatus(ctx context.Context, set *appv1.SubscriptionSet, subs []string, msg string) error {
+	newStatus := appv1.SubscriptionSetStatus{
+		Subscriptions: subs,
+		Message:       msg,
+	}
+
+	if equality.Semantic.DeepEqual(set.Status.Subscriptions, newStatus.Subscriptions) && set.Status.Message == newStatus.Message {
+		return nil
+	}
+
+	newStatus.LastUpdateTime = metav1.Now()
+	set.Status = newStatus
+
+	return r.Status().Update(ctx, set)
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subscriptionset
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestGenerateSubscriptions(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	set := &appv1.SubscriptionSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "guestbook",
+			Namespace: "apps",
+		},
+		Spec: appv1.SubscriptionSetSpec{
+			Generator: appv1.SubscriptionSetGenerator{
+				Clusters: []string{"cluster1", "cluster2"},
+			},
+			Template: appv1.SubscriptionTemplate{
+				Annotations: map[string]string{
+					appv1.AnnotationGitPath: "overlays/{{cluster}}",
+				},
+				Spec: appv1.SubscriptionSpec{
+					Channel: "{{namespace}}/{{name}}-chn",
+				},
+			},
+		},
+	}
+
+	subs, err := generateSubscriptions(set, set.Spec.Generator.Clusters)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(subs).To(gomega.HaveLen(2))
+
+	g.Expect(subs[1].Name).To(gomega.Equal("guestbook-cluster2"))
+	g.Expect(subs[1].Namespace).To(gomega.Equal("apps"))
+	g.Expect(subs[1].Spec.Channel).To(gomega.Equal("apps/guestbook-chn"))
+	g.Expect(subs[1].GetAnnotations()[appv1.AnnotationGitPath]).To(gomega.Equal("overlays/cluster2"))
+	g.Expect(subs[1].GetLabels()[appv1.LabelSubscriptionSet]).To(gomega.Equal("apps.guestbook"))
+	g.Expect(subs[1].Spec.Placement.Clusters[0].Name).To(gomega.Equal("cluster2"))
+
+	// the identity of the template is replaced with the one of the set
+	set.SetAnnotations(map[string]string{appv1.AnnotationUserIdentity: "ZGV2"})
+	set.Spec.Template.Annotations[appv1.AnnotationUserIdentity] = "a3ViZTphZG1pbg=="
+	set.Spec.Template.Annotations[appv1.AnnotationUserGroup] = "c3lzdGVtOm1hc3RlcnM="
+
+	subs, err = generateSubscriptions(set, set.Spec.Generator.Clusters)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(subs[0].GetAnnotations()[appv1.AnnotationUserIdentity]).To(gomega.Equal("ZGV2"))
+	g.Expect(subs[0].GetAnnotations()).NotTo(gomega.HaveKey(appv1.AnnotationUserGroup))
+
+	// no cluster selected by the generator, nothing is generated
+	subs, err = generateSubscriptions(set, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(subs).To(gomega.BeEmpty())
+
+	// no cluster generator, the template placement is kept
+	set.Spec.Generator = appv1.SubscriptionSetGenerator{}
+
+	subs, err = generateSubscriptions(set, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(subs).To(gomega.HaveLen(1))
+	g.Expect(subs[0].Name).To(gomega.Equal("guestbook"))
+	g.Expect(subs[0].Namespace).To(gomega.Equal("apps"))
+	g.Expect(subs[0].Spec.Channel).To(gomega.Equal("apps/guestbook-chn"))
+	g.Expect(subs[0].Spec.Placement).To(gomega.BeNil())
+	g.Expect(metav1.GetControllerOf(subs[0]).Name).To(gomega.Equal("guestbook"))
+}
+
+func TestSubscriptionSetLabelValue(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(subscriptionSetLabelValue("apps", "guestbook")).To(gomega.Equal("apps.guestbook"))
+
+	long := strings.Repeat("a", 60)
+	value := subscriptionSetLabelValue("apps", long)
+	g.Expect(value).To(gomega.HaveLen(63))
+	g.Expect(validation.IsValidLabelValue(value)).To(gomega.BeEmpty())
+	g.Expect(subscriptionSetLabelValue("apps", long+"b")).NotTo(gomega.Equal(value))
+}
+
+func TestPruneSubscriptions(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	set := &appv1.SubscriptionSet{ObjectMeta: metav1.ObjectMeta{Name: "guestbook", Namespace: "apps", UID: "set-uid"}}
+	otherSet := &appv1.SubscriptionSet{ObjectMeta: metav1.ObjectMeta{Name: "guestbook", Namespace: "apps", UID: "other-uid"}}
+	labels := map[string]string{appv1.LabelSubscriptionSet: subscriptionSetLabelValue("apps", "guestbook")}
+
+	newSub := func(namespace, name string, owner *appv1.SubscriptionSet) *appv1.Subscription {
+		sub := &appv1.Subscription{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}}
+		if owner != nil {
+			sub.OwnerReferences = []metav1.OwnerReference{
+				*metav1.NewControllerRef(owner, appv1.SchemeGroupVersion.WithKind("SubscriptionSet"))}
+		}
+
+		return sub
+	}
+
+	s := runtime.NewScheme()
+	g.Expect(appv1.SchemeBuilder.AddToScheme(s)).To(gomega.Succeed())
+
+	clt := fake.NewClientBuilder().WithScheme(s).WithObjects(
+		newSub("apps", "guestbook-cluster1", set),
+		newSub("apps", "guestbook-cluster2", set),
+		// the label of the set, but another namespace, another owner or no owner
+		newSub("team-b", "guestbook-cluster2", set),
+		newSub("apps", "guestbook-copy", otherSet),
+		newSub("apps", "guestbook-manual", nil),
+	).Build()
+
+	r := &ReconcileSubscriptionSet{Client: clt}
+
+	keep := map[types.NamespacedName]bool{{Namespace: "apps", Name: "guestbook-cluster1"}: true}
+	g.Expect(r.pruneSubscriptions(context.TODO(), set, keep)).To(gomega.Succeed())
+
+	subList := &appv1.SubscriptionList{}
+	g.Expect(clt.List(context.TODO(), subList)).To(gomega.Succeed())
+
+	names := []string{}
+	for _, sub := range subList.Items {
+		names = append(names, sub.Namespace+"/"+sub.Name)
+	}
+
+	g.Expect(names).To(gomega.ConsistOf("apps/guestbook-cluster1", "team-b/guestbook-cluster2", "apps/guestbook-copy",
+		"apps/guestbook-manual"))
+	g.Expect(clt.Get(context.TODO(), client.ObjectKey{Namespace: "apps", Name: "guestbook-cluster2"},
+		&appv1.Subscription{})).NotTo(gomega.Succeed())
+}
@@ -0,0 +1,165 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventstream
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// EventType is the subscription lifecycle step of an event
+type EventType string
+
+const (
+	// SyncStarted is published when the synchronizer starts applying the resources of a subscription
+	SyncStarted EventType = "SyncStarted"
+	// Applied is published when a resource of a subscription is applied
+	Applied EventType = "Applied"
+	// Failed is published when a resource of a subscription fails to apply
+	Failed EventType = "Failed"
+	// Pruned is published when a resource no longer subscribed is deleted
+	Pruned EventType = "Pruned"
+	// Recreated is published when a resource is deleted and created again, its update changing an immutable field
+	Recreated EventType = "Recreated"
+
+	// subscriberBuffer is the number of events queued for a stream client, the events are dropped when it is full
+	subscriberBuffer = 256
+)
+
+// Event is a subscription lifecycle event
+type Event struct {
+	Type         EventType `json:"type"`
+	Time         time.Time `json:"time"`
+	Cluster      string    `json:"cluster"`
+	Subscription string    `json:"subscription"`
+	Namespace    string    `json:"namespace"`
+	APIVersion   string    `json:"apiVersion,omitempty"`
+	Kind         string    `json:"kind,omitempty"`
+	Name         string    `json:"name,omitempty"`
+	// ResourceNamespace is the namespace of the resource, Namespace is the one of the subscription
+	ResourceNamespace string `json:"resourceNamespace,omitempty"`
+	Message           string `json:"message,omitempty"`
+}
+
+// Broker fans out the published events to the stream clients
+type Broker struct {
+	mtx         sync.RWMutex
+	subscribers map[chan Event]string
+}
+
+var defaultBroker = NewBroker()
+
+// NewBroker creates a broker without stream clients
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[chan Event]string{}}
+}
+
+// Subscribe returns the channel of the events of the subscriptions in namespace, all namespaces if empty. Call the
+// returned function to stop receiving the events.
+func (b *Broker) Subscribe(namespace string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mtx.Lock()
+	b.subscribers[ch] = namespace
+	b.mtx.Unlock()
+
+	return ch, func() {
+		b.mtx.Lock()
+		delete(b.subscribers, ch)
+		b.mtx.Unlock()
+	}
+}
+
+// Publish sends the event to the stream clients without blocking, a client not keeping up misses the event
+func (b *Broker) Publish(evt Event) {
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	for ch, namespace := range b.subscribers {
+		if namespace != "" && namespace != evt.Namespace {
+			continue
+		}
+
+		select {
+		case ch <- evt:
+		default:
+			klog.V(1).Infof("event stream client is not keeping up, dropped %v event of %v/%v",
+				evt.Type, evt.Namespace, evt.Subscription)
+		}
+	}
+}
+
+// PublishSyncStarted publishes the synchronizer started applying the resources of the subscription
+func PublishSyncStarted(hostSub types.NamespacedName, cluster string) {
+	defaultBroker.Publish(Event{Type: SyncStarted, Cluster: cluster, Subscription: hostSub.Name, Namespace: hostSub.Namespace})
+}
+
+// PublishApplied publishes the result of applying a resource of the subscription
+func PublishApplied(hostSub types.NamespacedName, cluster string, resource *unstructured.Unstructured, err error) {
+	evt := Event{
+		Type:              Applied,
+		Cluster:           cluster,
+		Subscription:      hostSub.Name,
+		Namespace:         hostSub.Namespace,
+		APIVersion:        resource.GetAPIVersion(),
+		Kind:              resource.GetKind(),
+		Name:              resource.GetName(),
+		ResourceNamespace: resource.GetNamespace(),
+	}
+
+	if err != nil {
+		evt.Type = Failed
+		evt.Message = err.Error()
+	}
+
+	defaultBroker.Publish(evt)
+}
+
+// PublishRecreated publishes a resource of the subscription was deleted and created again
+func PublishRecreated(hostSub types.NamespacedName, cluster string, resource *unstructured.Unstructured, reason string) {
+	defaultBroker.Publish(Event{
+		Type:              Recreated,
+		Cluster:           cluster,
+		Subscription:      hostSub.Name,
+		Namespace:         hostSub.Namespace,
+		APIVersion:        resource.GetAPIVersion(),
+		Kind:              resource.GetKind(),
+		Name:              resource.GetName(),
+		ResourceNamespace: resource.GetNamespace(),
+		Message:           reason,
+	})
+}
+
+// PublishPruned publishes a resource no longer subscribed was deleted
+func PublishPruned(hostSub types.NamespacedName, cluster, apiVersion, kind, namespace, name string) {
+	defaultBroker.Publish(Event{
+		Type:              Pruned,
+		Cluster:           cluster,
+		Subscription:      hostSub.Name,
+		Namespace:         hostSub.Namespace,
+		APIVersion:        apiVersion,
+		Kind:              kind,
+		Name:              name,
+		ResourceNamespace: namespace,
+	})
+}
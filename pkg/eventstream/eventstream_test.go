@@ -0,0 +1,195 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventstream
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestBrokerNamespaceFilter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	b := NewBroker()
+
+	all, unsubscribeAll := b.Subscribe("")
+	defer unsubscribeAll()
+
+	ns1, unsubscribeNs1 := b.Subscribe("ns1")
+
+	b.Publish(Event{Type: SyncStarted, Namespace: "ns1", Subscription: "sub"})
+	b.Publish(Event{Type: SyncStarted, Namespace: "ns2", Subscription: "sub"})
+
+	g.Expect(all).To(gomega.HaveLen(2))
+	g.Expect(ns1).To(gomega.HaveLen(1))
+
+	evt := <-ns1
+	g.Expect(evt.Namespace).To(gomega.Equal("ns1"))
+	g.Expect(evt.Time.IsZero()).To(gomega.BeFalse())
+
+	unsubscribeNs1()
+
+	b.Publish(Event{Type: SyncStarted, Namespace: "ns1", Subscription: "sub"})
+	g.Expect(ns1).To(gomega.BeEmpty())
+	g.Expect(all).To(gomega.HaveLen(3))
+}
+
+// newFakeAuthClient authenticates the token "valid" as a user allowed to watch the subscriptions of ns1 only
+func newFakeAuthClient() *fake.Clientset {
+	authClient := fake.NewSimpleClientset()
+
+	authClient.PrependReactor("create", "tokenreviews",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			review := action.(clienttesting.CreateAction).GetObject().(*authnv1.TokenReview)
+			review.Status.Authenticated = review.Spec.Token == "valid"
+			review.Status.User.Username = "viewer"
+
+			return true, review, nil
+		})
+
+	authClient.PrependReactor("create", "subjectaccessreviews",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			sar := action.(clienttesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+			attrs := sar.Spec.ResourceAttributes
+			sar.Status.Allowed = attrs.Namespace == "ns1" && attrs.Verb == "watch" && attrs.Resource == "subscriptions"
+
+			return true, sar, nil
+		})
+
+	return authClient
+}
+
+func TestServeEvents(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	s := &Server{broker: NewBroker(), authClient: newFakeAuthClient()}
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	get := func(path, token string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		return resp
+	}
+
+	resp := get("/events?namespace=ns1", "")
+	resp.Body.Close()
+	g.Expect(resp.StatusCode).To(gomega.Equal(http.StatusUnauthorized))
+
+	resp = get("/events?namespace=ns1", "invalid")
+	resp.Body.Close()
+	g.Expect(resp.StatusCode).To(gomega.Equal(http.StatusUnauthorized))
+
+	resp = get("/events", "valid")
+	resp.Body.Close()
+	g.Expect(resp.StatusCode).To(gomega.Equal(http.StatusForbidden))
+
+	resp = get("/other", "valid")
+	resp.Body.Close()
+	g.Expect(resp.StatusCode).To(gomega.Equal(http.StatusNotFound))
+
+	resp = get("/events?namespace=ns1", "valid")
+	defer resp.Body.Close()
+
+	g.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK))
+	g.Expect(resp.Header.Get("Content-Type")).To(gomega.Equal("text/event-stream"))
+
+	// the stream client is subscribed once the headers are sent
+	g.Eventually(func() int {
+		s.broker.mtx.RLock()
+		defer s.broker.mtx.RUnlock()
+
+		return len(s.broker.subscribers)
+	}).Should(gomega.Equal(1))
+
+	s.broker.Publish(Event{Type: SyncStarted, Namespace: "ns2", Subscription: "other"})
+	s.broker.Publish(Event{Type: Failed, Namespace: "ns1", Subscription: "sub", Kind: "ConfigMap", Name: "settings",
+		ResourceNamespace: "app", Message: "denied"})
+
+	reader := bufio.NewReader(resp.Body)
+
+	line, err := reader.ReadString('\n')
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(line).To(gomega.Equal("event: Failed\n"))
+
+	line, err = reader.ReadString('\n')
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(strings.HasPrefix(line, "data: ")).To(gomega.BeTrue())
+
+	evt := Event{}
+	g.Expect(json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &evt)).To(gomega.Succeed())
+	g.Expect(evt.Subscription).To(gomega.Equal("sub"))
+	g.Expect(evt.Name).To(gomega.Equal("settings"))
+	g.Expect(evt.Message).To(gomega.Equal("denied"))
+}
+
+func TestPublishApplied(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	events, unsubscribe := defaultBroker.Subscribe("ns1")
+	defer unsubscribe()
+
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetName("settings")
+	cm.SetNamespace("app")
+
+	hostSub := types.NamespacedName{Namespace: "ns1", Name: "sub"}
+
+	PublishSyncStarted(hostSub, "cluster1")
+	PublishApplied(hostSub, "cluster1", cm, nil)
+	PublishApplied(hostSub, "cluster1", cm, errors.New("denied"))
+	PublishPruned(hostSub, "cluster1", "v1", "ConfigMap", "app", "old")
+
+	g.Expect(events).To(gomega.HaveLen(4))
+	g.Expect((<-events).Type).To(gomega.Equal(SyncStarted))
+
+	evt := <-events
+	g.Expect(evt.Type).To(gomega.Equal(Applied))
+	g.Expect(evt.Cluster).To(gomega.Equal("cluster1"))
+	g.Expect(evt.ResourceNamespace).To(gomega.Equal("app"))
+
+	evt = <-events
+	g.Expect(evt.Type).To(gomega.Equal(Failed))
+	g.Expect(evt.Message).To(gomega.Equal("denied"))
+
+	evt = <-events
+	g.Expect(evt.Type).To(gomega.Equal(Pruned))
+	g.Expect(evt.Name).To(gomega.Equal("old"))
+}
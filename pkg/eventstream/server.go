@@ -0,0 +1,176 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	authzv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+const (
+	eventsPath = "/events"
+	// heartbeatInterval keeps the idle streams open through the proxies
+	heartbeatInterval = 30 * time.Second
+)
+
+// Server streams the subscription lifecycle events to the clients as server-sent events
+type Server struct {
+	broker     *Broker
+	authClient kubernetes.Interface
+	address    string
+	tlsKeyFile string
+	tlsCrtFile string
+	disableTLS bool
+}
+
+// Add creates the event stream server and adds it to the manager, the server listens on address.
+func Add(mgr manager.Manager, address, tlsKeyFile, tlsCrtFile string, disableTLS bool) error {
+	authClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+
+	return mgr.Add(&Server{
+		broker:     defaultBroker,
+		authClient: authClient,
+		address:    address,
+		tlsKeyFile: tlsKeyFile,
+		tlsCrtFile: tlsCrtFile,
+		disableTLS: disableTLS,
+	})
+}
+
+// Start serves the event stream until the context is done, this will be triggered by the manager.
+func (s *Server) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.address,
+		Handler:           s,
+		ReadHeaderTimeout: 30 * time.Second,
+		BaseContext:       func(_ net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		if err := srv.Shutdown(context.TODO()); err != nil {
+			klog.Error("failed to shut down the event stream server, err: ", err)
+		}
+	}()
+
+	klog.Info("starting the event stream server on ", s.address)
+
+	var err error
+
+	if s.disableTLS {
+		err = srv.ListenAndServe()
+	} else {
+		err = utils.ListenAndServeTLS(srv, s.tlsCrtFile, s.tlsKeyFile)
+	}
+
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// NeedLeaderElection streams the events of every replica
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// ServeHTTP streams GET /events?namespace= as server-sent events, the bearer token user needs to watch the
+// subscriptions of the namespace, or of all namespaces if it is empty
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	if r.URL.Path != eventsPath {
+		http.Error(w, "unknown path "+r.URL.Path, http.StatusNotFound)
+
+		return
+	}
+
+	namespace := r.URL.Query().Get("namespace")
+
+	if code, err := utils.AuthorizeBearerToken(r, s.authClient, authzv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "watch",
+		Group:     appv1.SchemeGroupVersion.Group,
+		Resource:  "subscriptions",
+	}); err != nil {
+		klog.Infof("event stream request for namespace %q denied: %v", namespace, err)
+		http.Error(w, err.Error(), code)
+
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+
+		return
+	}
+
+	events, unsubscribe := s.broker.Subscribe(namespace)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case evt := <-events:
+			data, err := json.Marshal(evt)
+			if err != nil {
+				klog.Errorf("failed to marshal the %v event of %v/%v, err: %v", evt.Type, evt.Namespace, evt.Subscription, err)
+
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, data); err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
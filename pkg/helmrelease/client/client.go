@@ -33,6 +33,7 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/helmrelease/internal/util/k8sutil"
+	subutils "open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
@@ -102,8 +103,11 @@ func NewRESTClientGetter(mgr manager.Manager, ns string) (genericclioptions.REST
 
 var _ kube.Interface = &ownerRefInjectingClient{}
 
+// NewOwnerRefInjectingClient returns the client of the chart resources of the HelmRelease cr, with the owner reference
+// of cr. The namespace containment policy of the subscription namespace applies to the namespaced resources of the
+// chart, like to the resources of the other subscriptions.
 func NewOwnerRefInjectingClient(base kube.Client, restMapper meta.RESTMapper,
-	cr *unstructured.Unstructured) (kube.Interface, error) {
+	cr *unstructured.Unstructured, namespace, namespaceContainment string) (kube.Interface, error) {
 
 	if cr != nil {
 		if cr.GetObjectKind() != nil {
@@ -114,16 +118,20 @@ func NewOwnerRefInjectingClient(base kube.Client, restMapper meta.RESTMapper,
 		}
 	}
 	return &ownerRefInjectingClient{
-		Client:     base,
-		restMapper: restMapper,
-		owner:      cr,
+		Client:               base,
+		restMapper:           restMapper,
+		owner:                cr,
+		namespace:            namespace,
+		namespaceContainment: namespaceContainment,
 	}, nil
 }
 
 type ownerRefInjectingClient struct {
 	kube.Client
-	restMapper meta.RESTMapper
-	owner      *unstructured.Unstructured
+	restMapper           meta.RESTMapper
+	owner                *unstructured.Unstructured
+	namespace            string
+	namespaceContainment string
 }
 
 func (c *ownerRefInjectingClient) Build(reader io.Reader, validate bool) (kube.ResourceList, error) {
@@ -140,6 +148,10 @@ func (c *ownerRefInjectingClient) Build(reader io.Reader, validate bool) (kube.R
 			return err
 		}
 		u := &unstructured.Unstructured{Object: objMap}
+		if err := c.containNamespace(r, u); err != nil {
+			return err
+		}
+
 		useOwnerRef, err := k8sutil.SupportsOwnerReference(c.restMapper, c.owner, u, "")
 		if err != nil {
 			return err
@@ -164,6 +176,25 @@ func (c *ownerRefInjectingClient) Build(reader io.Reader, validate bool) (kube.R
 	return resourceList, nil
 }
 
+// containNamespace moves the namespaced resource to the subscription namespace, or rejects it, if it is in another
+// namespace and the namespace containment policy is force or reject. The resources without a namespace are in the
+// release namespace.
+func (c *ownerRefInjectingClient) containNamespace(r *resource.Info, u *unstructured.Unstructured) error {
+	if !r.Namespaced() || r.Namespace == c.namespace {
+		return nil
+	}
+
+	u.SetNamespace(r.Namespace)
+
+	if err := subutils.ContainResourceNamespace(u, c.namespace, c.namespaceContainment); err != nil {
+		return err
+	}
+
+	r.Namespace = u.GetNamespace()
+
+	return nil
+}
+
 func containsResourcePolicyKeep(annotations map[string]string) bool {
 	if annotations == nil {
 		return false
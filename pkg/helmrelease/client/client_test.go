@@ -20,6 +20,11 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"helm.sh/helm/v3/pkg/kube"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	subutils "open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
 )
 
 func TestContainsResourcePolicyKeep(t *testing.T) {
@@ -77,3 +82,34 @@ func TestContainsResourcePolicyKeep(t *testing.T) {
 		assert.Equal(t, test.expectedVal, containsResourcePolicyKeep(test.input), test.name)
 	}
 }
+
+func TestContainNamespace(t *testing.T) {
+	newInfo := func(namespace string, scope meta.RESTScope) (*resource.Info, *unstructured.Unstructured) {
+		u := &unstructured.Unstructured{}
+		u.SetKind("ConfigMap")
+		u.SetName("settings")
+
+		return &resource.Info{Namespace: namespace, Mapping: &meta.RESTMapping{Scope: scope}, Object: u}, u
+	}
+
+	c := &ownerRefInjectingClient{namespace: "tenant1"}
+
+	info, u := newInfo("kube-system", meta.RESTScopeNamespace)
+	assert.NoError(t, c.containNamespace(info, u))
+	assert.Equal(t, "kube-system", info.Namespace)
+
+	c.namespaceContainment = subutils.NamespaceContainmentForce
+	assert.NoError(t, c.containNamespace(info, u))
+	assert.Equal(t, "tenant1", info.Namespace)
+	assert.Equal(t, "tenant1", u.GetNamespace())
+
+	c.namespaceContainment = subutils.NamespaceContainmentReject
+	info, u = newInfo("kube-system", meta.RESTScopeNamespace)
+	assert.Error(t, c.containNamespace(info, u))
+
+	info, u = newInfo("tenant1", meta.RESTScopeNamespace)
+	assert.NoError(t, c.containNamespace(info, u))
+
+	info, u = newInfo("", meta.RESTScopeRoot)
+	assert.NoError(t, c.containNamespace(info, u))
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/action"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/helmrelease/v1"
+	appSubStatusV1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1alpha1"
+	helmoperator "open-cluster-management.io/multicloud-operators-subscription/pkg/helmrelease/release"
+)
+
+// crdWaitRequeue is the period the HelmReleases waiting for CRDs are requeued at, in case a CRD event is missed
+const crdWaitRequeue = 30 * time.Second
+
+var crdGVK = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+
+// installDryRun renders the release without contacting the cluster
+func installDryRun(install *action.Install) error {
+	install.DryRun = true
+	install.ClientOnly = true
+
+	return nil
+}
+
+// waitForCRDs defers the install or upgrade of the HelmRelease while its manifests have kinds the cluster doesn't
+// serve yet, e.g. the CRDs installed by an operator of another chart. The HelmRelease gets the WaitingForCRDs
+// condition listing the missing kinds, and it is requeued until the CRDs are established. It returns true if the
+// HelmRelease is waiting.
+func (r *ReconcileHelmRelease) waitForCRDs(instance *appv1.HelmRelease,
+	dryRunManager helmoperator.Manager) (reconcile.Result, bool) {
+	rel, err := dryRunManager.InstallRelease(context.TODO(), installDryRun)
+	if err != nil {
+		// the install or the upgrade reports the errors rendering the chart
+		klog.Info("Failed to render HelmRelease ", helmreleaseNsn(instance), " to check its CRDs, err: ", err)
+
+		return reconcile.Result{}, false
+	}
+
+	kinds, err := getMissingKinds(r.GetRESTMapper(), rel)
+	if err != nil {
+		klog.Error("Failed to check the CRDs of HelmRelease ", helmreleaseNsn(instance), " ", err)
+
+		return reconcile.Result{}, false
+	}
+
+	if len(kinds) == 0 {
+		instance.Status.RemoveCondition(appv1.ConditionWaitingForCRDs)
+
+		return reconcile.Result{}, false
+	}
+
+	msg := "waiting for the CRDs of " + strings.Join(kinds, ", ") + " to be established"
+
+	klog.Info("HelmRelease ", helmreleaseNsn(instance), " is ", msg)
+
+	instance.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionWaitingForCRDs,
+		Status:  appv1.StatusTrue,
+		Reason:  appv1.ReasonCRDsNotEstablished,
+		Message: msg,
+	})
+	_ = r.updateResourceStatus(instance)
+	r.populateReleaseAppSubStatus(appSubStatusV1alpha1.PackageWaitingForOperator, msg, instance)
+
+	return reconcile.Result{RequeueAfter: crdWaitRequeue}, true
+}
+
+// getMissingKinds returns the kinds of the release manifests and hooks the rest mapper doesn't know. The kinds of the
+// CRDs shipped in the chart crds directory or in its templates are not missing, Helm creates them first.
+func getMissingKinds(restMapper meta.RESTMapper, rel *rpb.Release) ([]string, error) {
+	chartCRDs := map[schema.GroupKind]bool{}
+
+	if rel.Chart != nil {
+		for _, crd := range rel.Chart.CRDObjects() {
+			if crd.File == nil {
+				continue
+			}
+
+			if err := addCRDKinds(chartCRDs, string(crd.File.Data)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	manifests := []string{rel.Manifest}
+
+	for _, hook := range rel.Hooks {
+		if hook != nil {
+			manifests = append(manifests, hook.Manifest)
+		}
+	}
+
+	gvks := []schema.GroupVersionKind{}
+
+	for _, manifest := range manifests {
+		if err := addCRDKinds(chartCRDs, manifest); err != nil {
+			return nil, err
+		}
+
+		objs, err := splitObjects(manifest)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range objs {
+			gvks = append(gvks, obj.GroupVersionKind())
+		}
+	}
+
+	missing := map[string]bool{}
+
+	for _, gvk := range gvks {
+		if gvk.Empty() || chartCRDs[gvk.GroupKind()] {
+			continue
+		}
+
+		if _, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			if !meta.IsNoMatchError(err) {
+				return nil, err
+			}
+
+			missing[fmt.Sprintf("%v (%v)", gvk.Kind, gvk.GroupVersion())] = true
+		}
+	}
+
+	kinds := []string{}
+	for kind := range missing {
+		kinds = append(kinds, kind)
+	}
+
+	sort.Strings(kinds)
+
+	return kinds, nil
+}
+
+// addCRDKinds adds the kinds of the CRDs of the manifest
+func addCRDKinds(kinds map[schema.GroupKind]bool, manifest string) error {
+	objs, err := splitObjects(manifest)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objs {
+		if obj.GroupVersionKind().GroupKind() != crdGVK.GroupKind() {
+			continue
+		}
+
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+
+		kinds[schema.GroupKind{Group: group, Kind: kind}] = true
+	}
+
+	return nil
+}
+
+// splitObjects parses the objects of the manifest, the items of the lists are returned as objects
+func splitObjects(manifest string) ([]*unstructured.Unstructured, error) {
+	objs := []*unstructured.Unstructured{}
+
+	for _, resource := range releaseutil.SplitManifests(manifest) {
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(resource), u); err != nil {
+			return nil, err
+		}
+
+		if len(u.Object) == 0 {
+			continue
+		}
+
+		if !u.IsList() {
+			objs = append(objs, u)
+
+			continue
+		}
+
+		err := u.EachListItem(func(item runtime.Object) error {
+			if itemObj, ok := item.(*unstructured.Unstructured); ok {
+				objs = append(objs, itemObj)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return objs, nil
+}
+
+// watchCRDs requeues the HelmReleases waiting for CRDs when the CRDs change. Only the metadata of the CRDs is cached.
+func watchCRDs(r *ReconcileHelmRelease, c controller.Controller) error {
+	crd := &metav1.PartialObjectMetadata{}
+	crd.SetGroupVersionKind(crdGVK)
+
+	mapFn := func(obj client.Object) []reconcile.Request {
+		hrList := &appv1.HelmReleaseList{}
+		if err := r.GetClient().List(context.TODO(), hrList); err != nil {
+			klog.Error("Failed to list the HelmReleases waiting for CRDs, err: ", err)
+
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+
+		for _, hr := range hrList.Items {
+			for _, condition := range hr.Status.Conditions {
+				if condition.Type == appv1.ConditionWaitingForCRDs && condition.Status == appv1.StatusTrue {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{Namespace: hr.Namespace, Name: hr.Name}})
+				}
+			}
+		}
+
+		return requests
+	}
+
+	return c.Watch(&source.Kind{Type: crd}, handler.EnqueueRequestsFromMapFunc(mapFn))
+}
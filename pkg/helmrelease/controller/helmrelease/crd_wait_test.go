@@ -0,0 +1,116 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmrelease
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const crdWaitManifest = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+---
+apiVersion: example.com/v1
+kind: Gadget
+metadata:
+  name: gadget
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: monitoring.coreos.com/v1
+  kind: ServiceMonitor
+  metadata:
+    name: monitor
+`
+
+const crdWaitChartCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: gadgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Gadget
+`
+
+func TestGetMissingKinds(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	restMapper.Add(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"},
+		meta.RESTScopeRoot)
+
+	rel := &rpb.Release{
+		Manifest: crdWaitManifest,
+		Hooks: []*rpb.Hook{{Manifest: `apiVersion: batch.example.com/v1
+kind: Migration
+metadata:
+  name: migrate
+`}},
+		Chart: &chart.Chart{},
+	}
+
+	kinds, err := getMissingKinds(restMapper, rel)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(kinds).To(gomega.Equal([]string{
+		"Gadget (example.com/v1)",
+		"Migration (batch.example.com/v1)",
+		"ServiceMonitor (monitoring.coreos.com/v1)",
+	}))
+
+	// the CRDs of the chart crds directory are created first
+	rel.Chart.Files = []*chart.File{{Name: "crds/gadgets.yaml", Data: []byte(crdWaitChartCRD)}}
+
+	kinds, err = getMissingKinds(restMapper, rel)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(kinds).To(gomega.Equal([]string{
+		"Migration (batch.example.com/v1)",
+		"ServiceMonitor (monitoring.coreos.com/v1)",
+	}))
+
+	// the CRDs are established
+	restMapper.Add(schema.GroupVersionKind{Group: "batch.example.com", Version: "v1", Kind: "Migration"},
+		meta.RESTScopeNamespace)
+	restMapper.Add(schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"},
+		meta.RESTScopeNamespace)
+
+	kinds, err = getMissingKinds(restMapper, rel)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(kinds).To(gomega.BeEmpty())
+}
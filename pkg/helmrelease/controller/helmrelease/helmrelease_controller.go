@@ -46,6 +46,7 @@ import (
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -58,6 +59,7 @@ import (
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/helmrelease/internal/util/k8sutil"
 	helmoperator "open-cluster-management.io/multicloud-operators-subscription/pkg/helmrelease/release"
 	kubesynchronizer "open-cluster-management.io/multicloud-operators-subscription/pkg/synchronizer/kubernetes"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
 )
 
 const (
@@ -100,13 +102,36 @@ func Add(mgr manager.Manager) error {
 
 	// Watch for changes to primary resource HelmRelease
 	if err := c.Watch(&source.Kind{Type: &appv1.HelmRelease{}}, &handler.EnqueueRequestForObject{},
-		predicate.GenerationChangedPredicate{}); err != nil {
+		predicate.Or(predicate.GenerationChangedPredicate{}, resyncRequestedPredicate)); err != nil {
 		return err
 	}
 
 	watchDependentResources(mgr, r, c)
 
-	return nil
+	return watchCRDs(r, c)
+}
+
+// resyncRequestedPredicate passes the HelmRelease updates carrying a new resync-package request,
+// they don't change the generation
+var resyncRequestedPredicate = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		return false
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.ObjectOld == nil || e.ObjectNew == nil {
+			return false
+		}
+
+		newRequest := e.ObjectNew.GetAnnotations()[appsubv1.AnnotationResyncPackage]
+
+		return newRequest != "" && newRequest != e.ObjectOld.GetAnnotations()[appsubv1.AnnotationResyncPackage]
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return false
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
 }
 
 // blank assignment to verify that ReconcileHelmRelease implements reconcile.Reconciler
@@ -118,7 +143,7 @@ type ReleaseHookFunc func(*rpb.Release) error
 // ReconcileHelmRelease reconciles a HelmRelease object
 type ReconcileHelmRelease struct {
 	manager.Manager
-	synchronizer *kubesynchronizer.KubeSynchronizer
+	synchronizer kubesynchronizer.SyncSource
 	releaseHook  ReleaseHookFunc
 }
 
@@ -266,6 +291,10 @@ func (r *ReconcileHelmRelease) Reconcile(ctx context.Context, request reconcile.
 		Status: appv1.StatusTrue,
 	})
 
+	if result, waiting := r.waitForCRDs(instance, dryRunManager); waiting {
+		return result, nil
+	}
+
 	klog.Info("Sync Release ", helmreleaseNsn(instance))
 
 	if err := manager.Sync(context.TODO()); err != nil {
@@ -392,13 +421,6 @@ func (r *ReconcileHelmRelease) install(instance *appv1.HelmRelease, manager helm
 
 	klog.Info("Installing (dry-run) Release ", helmreleaseNsn(instance))
 
-	installDryRun := func(install *action.Install) error {
-		install.DryRun = true
-		install.ClientOnly = true
-
-		return nil
-	}
-
 	installedRelease, err := dryRunManager.InstallRelease(context.TODO(), installDryRun)
 	if err != nil {
 		klog.Error("Failed to install (dry-run) HelmRelease ",
@@ -889,7 +911,7 @@ func (r *ReconcileHelmRelease) populateAppSubStatus(
 					appSubUnitStatuses = append(appSubUnitStatuses, appSubUnitStatus)
 
 					appsubClusterStatus := kubesynchronizer.SubscriptionClusterStatus{
-						Cluster:                   r.synchronizer.SynchronizerID.Name,
+						Cluster:                   r.synchronizer.GetClusterName(),
 						AppSub:                    types.NamespacedName{Name: hrOwner.Name, Namespace: instance.GetNamespace()},
 						Action:                    "APPLY",
 						SubscriptionPackageStatus: appSubUnitStatuses,
@@ -919,69 +941,83 @@ func (r *ReconcileHelmRelease) populateAppSubStatus(
 
 func (r *ReconcileHelmRelease) populateErrorAppSubStatus(
 	errMsg string, instance *appv1.HelmRelease) {
-	for _, hrOwner := range instance.OwnerReferences {
-		if hrOwner.Kind == "Subscription" {
+	r.populateReleaseAppSubStatus(appSubStatusV1alpha1.PackageDeployFailed, errMsg, instance)
+}
 
-			appSubUnitStatuses := []kubesynchronizer.SubscriptionUnitStatus{}
+// populateReleaseAppSubStatus reports the HelmRelease itself in the status of its parent appsub
+func (r *ReconcileHelmRelease) populateReleaseAppSubStatus(
+	phase appSubStatusV1alpha1.PackagePhase, msg string, instance *appv1.HelmRelease) {
+	appsubKey := getParentAppSub(instance)
+	if appsubKey == nil {
+		return
+	}
 
-			appSubUnitStatus := kubesynchronizer.SubscriptionUnitStatus{}
-			appSubUnitStatus.APIVersion = instance.APIVersion
-			appSubUnitStatus.Kind = instance.Kind
-			appSubUnitStatus.Name = instance.Name
-			appSubUnitStatus.Namespace = instance.Namespace
+	appSubUnitStatuses := []kubesynchronizer.SubscriptionUnitStatus{}
 
-			appSubUnitStatus.Phase = string(appSubStatusV1alpha1.PackageDeployFailed)
-			appSubUnitStatus.Message = errMsg
-			appSubUnitStatuses = append(appSubUnitStatuses, appSubUnitStatus)
+	appSubUnitStatus := kubesynchronizer.SubscriptionUnitStatus{}
+	appSubUnitStatus.APIVersion = instance.APIVersion
+	appSubUnitStatus.Kind = instance.Kind
+	appSubUnitStatus.Name = instance.Name
+	appSubUnitStatus.Namespace = instance.Namespace
 
-			appsubClusterStatus := kubesynchronizer.SubscriptionClusterStatus{
-				Cluster:                   r.synchronizer.SynchronizerID.Name,
-				AppSub:                    types.NamespacedName{Name: hrOwner.Name, Namespace: instance.GetNamespace()},
-				Action:                    "APPLY",
-				SubscriptionPackageStatus: appSubUnitStatuses,
-			}
+	appSubUnitStatus.Phase = string(phase)
+	appSubUnitStatus.Message = msg
+	appSubUnitStatuses = append(appSubUnitStatuses, appSubUnitStatus)
 
-			// get the parent appsub
-			appsub := &appsubv1.Subscription{}
-			appsubKey := types.NamespacedName{
-				Namespace: instance.GetNamespace(),
-				Name:      hrOwner.Name,
-			}
-			err := r.GetClient().Get(context.TODO(), appsubKey, appsub)
-			if err != nil {
-				klog.Warning("failed to get parent appsub, err: ", err)
-			}
+	appsubClusterStatus := kubesynchronizer.SubscriptionClusterStatus{
+		Cluster:                   r.synchronizer.GetClusterName(),
+		AppSub:                    *appsubKey,
+		Action:                    "APPLY",
+		SubscriptionPackageStatus: appSubUnitStatuses,
+	}
 
-			skipOrphanDelete := true
-			err = r.synchronizer.SyncAppsubClusterStatus(appsub, appsubClusterStatus, &skipOrphanDelete, nil)
-			if err != nil {
-				klog.Warning("error while sync app sub cluster status: ", err)
-			}
-		}
+	// get the parent appsub
+	appsub := &appsubv1.Subscription{}
+	err := r.GetClient().Get(context.TODO(), *appsubKey, appsub)
+	if err != nil {
+		klog.Warning("failed to get parent appsub, err: ", err)
+	}
+
+	skipOrphanDelete := true
+	err = r.synchronizer.SyncAppsubClusterStatus(appsub, appsubClusterStatus, &skipOrphanDelete, nil)
+	if err != nil {
+		klog.Warning("error while sync app sub cluster status: ", err)
 	}
 }
 
 func (r *ReconcileHelmRelease) deleteAppSubStatus(instance *appv1.HelmRelease) {
-	for _, hrOwner := range instance.OwnerReferences {
-		if strings.EqualFold(hrOwner.APIVersion, "apps.open-cluster-management.io/v1") &&
-			strings.EqualFold(hrOwner.Kind, "Subscription") {
+	appsubKey := getParentAppSub(instance)
+	if appsubKey == nil {
+		return
+	}
 
-			appSubUnitStatuses := []kubesynchronizer.SubscriptionUnitStatus{}
+	appSubUnitStatuses := []kubesynchronizer.SubscriptionUnitStatus{}
 
-			appsubClusterStatus := kubesynchronizer.SubscriptionClusterStatus{
-				Cluster:                   r.synchronizer.SynchronizerID.Name,
-				AppSub:                    types.NamespacedName{Name: hrOwner.Name, Namespace: instance.GetNamespace()},
-				Action:                    "DELETE",
-				SubscriptionPackageStatus: appSubUnitStatuses,
-			}
+	appsubClusterStatus := kubesynchronizer.SubscriptionClusterStatus{
+		Cluster:                   r.synchronizer.GetClusterName(),
+		AppSub:                    *appsubKey,
+		Action:                    "DELETE",
+		SubscriptionPackageStatus: appSubUnitStatuses,
+	}
 
-			skipOrphanDelete := true
-			err := r.synchronizer.SyncAppsubClusterStatus(nil, appsubClusterStatus, &skipOrphanDelete, nil)
-			if err != nil {
-				klog.Warning("error while sync app sub cluster status: ", err)
-			}
+	skipOrphanDelete := true
+	err := r.synchronizer.SyncAppsubClusterStatus(nil, appsubClusterStatus, &skipOrphanDelete, nil)
+	if err != nil {
+		klog.Warning("error while sync app sub cluster status: ", err)
+	}
+}
+
+// getParentAppSub returns the appsub owning the HelmRelease, or hosting it for the HelmReleases in another namespace
+// than their appsub, which can't be owned by it
+func getParentAppSub(instance *appv1.HelmRelease) *types.NamespacedName {
+	for _, hrOwner := range instance.OwnerReferences {
+		if strings.EqualFold(hrOwner.APIVersion, "apps.open-cluster-management.io/v1") &&
+			strings.EqualFold(hrOwner.Kind, "Subscription") {
+			return &types.NamespacedName{Name: hrOwner.Name, Namespace: instance.GetNamespace()}
 		}
 	}
+
+	return utils.GetHostSubscriptionFromObject(instance)
 }
 
 func helmreleaseNsn(hr *appv1.HelmRelease) string {
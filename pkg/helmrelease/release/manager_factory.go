@@ -33,6 +33,7 @@ import (
 
 	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/helmrelease/v1"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/helmrelease/client"
+	subutils "open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
 )
 
 // ManagerFactory creates Managers that are specific to custom resources. It is
@@ -68,9 +69,17 @@ func (f managerFactory) NewManager(cr *unstructured.Unstructured, overrideValues
 		return nil, fmt.Errorf("failed to get REST client getter from manager: %w", err)
 	}
 
+	// the chart resources are contained in the namespace of the subscription of the HelmRelease, which may be in
+	// another namespace by the helm-release-namespace annotation of the subscription
+	subNamespace := cr.GetNamespace()
+	if host := subutils.GetHostSubscriptionFromObject(cr); host != nil {
+		subNamespace = host.Namespace
+	}
+
 	kubeClient := kube.New(rcg)
 	restMapper := f.mgr.GetRESTMapper()
-	ownerRefClient, err := client.NewOwnerRefInjectingClient(*kubeClient, restMapper, cr)
+	ownerRefClient, err := client.NewOwnerRefInjectingClient(*kubeClient, restMapper, cr, subNamespace,
+		subutils.GetNamespaceContainment(f.mgr.GetClient(), subNamespace))
 	if err != nil {
 		return nil, fmt.Errorf("failed to inject owner references: %w", err)
 	}
@@ -85,6 +94,10 @@ func (f managerFactory) NewManager(cr *unstructured.Unstructured, overrideValues
 			return nil, fmt.Errorf("failed to load chart dir, most likely the given chart name is incorrect: %w", err)
 		}
 
+		if err := subutils.CheckHelmChartAPIVersion(crChart); err != nil {
+			return nil, err
+		}
+
 		releaseName, err = getReleaseName(storageBackend, crChart.Name(), cr)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get helm release name: %w", err)
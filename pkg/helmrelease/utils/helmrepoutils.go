@@ -24,7 +24,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -48,26 +47,28 @@ import (
 	"k8s.io/klog/v2"
 
 	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/helmrelease/v1"
+	subutils "open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
 )
 
-//GetHelmRepoClient returns an *http.client to access the helm repo
-func GetHelmRepoClient(parentNamespace string, configMap *corev1.ConfigMap, skipCertVerify bool) (rest.HTTPClient, error) {
-	/* #nosec G402 */
+//GetHelmRepoClient returns an *http.client to access the helm repo, with the CA certificates and the client
+//certificate of the secret and the config map of the channel
+func GetHelmRepoClient(parentNamespace string, configMap *corev1.ConfigMap, secret *corev1.Secret,
+	skipCertVerify bool) (rest.HTTPClient, error) {
+	tlsConfig, err := subutils.HelmRepoTLSConfig(secret, configMap, skipCertVerify)
+	if err != nil {
+		klog.Error(err, " - Unable to build the TLS config of the helm repo")
+
+		return nil, err
+	}
+
 	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           subutils.NewFetchDialer().DialContext,
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: skipCertVerify, // #nosec G402 InsecureSkipVerify conditionally
-			MinVersion:         tls.VersionTLS12,
-		},
+		TLSClientConfig:       tlsConfig,
 	}
 
 	if skipCertVerify {
@@ -100,8 +101,8 @@ func GetHelmRepoClient(parentNamespace string, configMap *corev1.ConfigMap, skip
 		klog.V(5).Info("configMap is nil")
 	}
 
-	httpClient := http.DefaultClient
-	httpClient.Transport = transport
+	// the client certificate of the channel is not set on the default client shared by the other helm releases
+	httpClient := &http.Client{Transport: transport}
 	klog.V(5).Info("InsecureSkipVerify equal ", transport.TLSClientConfig.InsecureSkipVerify)
 
 	return httpClient, nil
@@ -314,11 +315,11 @@ func getKnownHostFromURL(sshURL string, filepath string) error {
 			return err
 		}
 
-		sshhostname = strings.Split(u.Host, ":")[0]
+		sshhostname = u.Hostname()
 
 		sshhostport = u.Host
 	} else if strings.HasPrefix(sshURL, "git@") {
-		sshhostname = strings.Split(strings.SplitAfter(sshURL, "@")[1], ":")[0]
+		sshhostname, _ = subutils.ParseSSHHost(sshURL)
 	}
 
 	klog.Info("Getting public SSH host key for " + sshhostname)
@@ -444,11 +445,17 @@ func getHTTPOptions(options *git.CloneOptions, caCerts string, insecureSkipVerif
 		installProtocol = true
 	}
 
+	// the default client doesn't resolve names with the custom DNS server
+	if subutils.IsFetchDNSResolverSet() {
+		installProtocol = true
+	}
+
 	if installProtocol {
 		klog.Info("HTTP_PROXY = " + os.Getenv("HTTP_PROXY"))
 		klog.Info("HTTPS_PROXY = " + os.Getenv("HTTPS_PROXY"))
 
 		transportConfig := &http.Transport{
+			DialContext: subutils.NewFetchDialer().DialContext,
 			/* #nosec G402 */
 			TLSClientConfig: clientConfig,
 		}
@@ -535,6 +542,19 @@ func downloadChartFromURL(configMap *corev1.ConfigMap,
 		return "", downloadErr
 	}
 
+	downloadErr = verifyChartZip(configMap, secret, destRepo, s, url, chartZip, digestTrim)
+	if downloadErr != nil {
+		//Remove zip because it is not the chart of the repo index
+		rErr := os.RemoveAll(chartZip)
+		if rErr != nil {
+			klog.Error(rErr, "- Failed to remove all: ", chartZip)
+		}
+
+		klog.Error(downloadErr, " - url: ", url)
+
+		return "", downloadErr
+	}
+
 	r, downloadErr := os.Open(filepath.Clean(chartZip))
 	if downloadErr != nil {
 		klog.Error(downloadErr, " - Failed to open: ", chartZip, " using url: ", url)
@@ -566,6 +586,56 @@ func downloadChartFromURL(configMap *corev1.ConfigMap,
 	return chartDir, nil
 }
 
+//verifyChartZip verifies the digest of the chart archive against the repo index, and its provenance file if the
+//charts are verified with a keyring
+func verifyChartZip(configMap *corev1.ConfigMap,
+	secret *corev1.Secret,
+	destRepo string,
+	s *appv1.HelmRelease,
+	fileURL string,
+	chartZip string,
+	digestTrim string) error {
+	if err := subutils.VerifyHelmChartDigest(chartZip, s.Repo.Digest); err != nil {
+		return err
+	}
+
+	if !subutils.IsHelmProvenanceVerified() {
+		return nil
+	}
+
+	var archiveName, provFile string
+
+	if subutils.IsOCIHelmRepo(fileURL) {
+		//the provenance file is pulled with the chart
+		archiveName = subutils.OCIChartArchiveName(fileURL)
+		provFile = chartZip + ".prov"
+	} else {
+		URLP, err := url.Parse(fileURL)
+		if err != nil {
+			return err
+		}
+
+		archiveName = filepath.Base(strings.SplitN(URLP.RequestURI(), "?", 2)[0])
+
+		provFile, err = downloadFile(s.Namespace, configMap, fileURL+".prov", secret, destRepo,
+			s.Repo.InsecureSkipVerify, digestTrim)
+		if err != nil {
+			return fmt.Errorf("failed to download the provenance file of chart archive %v, err: %w", archiveName, err)
+		}
+	}
+
+	if err := subutils.VerifyHelmChartProvenance(chartZip, provFile, archiveName); err != nil {
+		rErr := os.RemoveAll(provFile)
+		if rErr != nil {
+			klog.Error(rErr, "- Failed to remove all: ", provFile)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
 //downloadFile downloads a files and post it in the chartsDir.
 func downloadFile(parentNamespace string, configMap *corev1.ConfigMap,
 	fileURL string,
@@ -601,6 +671,8 @@ func downloadFile(parentNamespace string, configMap *corev1.ConfigMap,
 		downloadErr = downloadFileLocal(URLP, chartZip)
 	case "http", "https":
 		downloadErr = downloadFileHTTP(parentNamespace, configMap, fileURL, secret, chartZip, insecureSkipVerify)
+	case "oci":
+		downloadErr = downloadFileOCI(fileURL, secret, chartZip)
 	default:
 		downloadErr = fmt.Errorf("unsupported scheme %s", URLP.Scheme)
 	}
@@ -650,7 +722,7 @@ func downloadFileHTTP(parentNamespace string, configMap *corev1.ConfigMap,
 	}
 
 	if os.IsNotExist(err) {
-		httpClient, downloadErr := GetHelmRepoClient(parentNamespace, configMap, insecureSkipVerify)
+		httpClient, downloadErr := GetHelmRepoClient(parentNamespace, configMap, secret, insecureSkipVerify)
 		if downloadErr != nil {
 			klog.Error(downloadErr, " - Failed to create httpClient")
 			return downloadErr
@@ -710,6 +782,38 @@ func downloadFileHTTP(parentNamespace string, configMap *corev1.ConfigMap,
 	return nil
 }
 
+//downloadFileOCI pulls the chart of the oci:// URL from its registry, with the credentials of the docker-config or
+//the user and password secret
+func downloadFileOCI(fileURL string, secret *corev1.Secret, chartZip string) error {
+	withProv := subutils.IsHelmProvenanceVerified()
+	provFile := chartZip + ".prov"
+
+	if _, err := os.Stat(chartZip); err == nil {
+		if _, err := os.Stat(provFile); err == nil || !withProv {
+			klog.V(5).Info("Skip download chartZip already exists: ", chartZip)
+
+			return nil
+		}
+	}
+
+	data, provData, err := subutils.PullOCIChart(fileURL, secret, withProv)
+	if err != nil {
+		klog.Error(err, " - Unable to retrieve chart")
+
+		return err
+	}
+
+	klog.V(5).Info("Pull chart from OCI registry succeeded: ", fileURL)
+
+	if withProv {
+		if err := ioutil.WriteFile(filepath.Clean(provFile), provData, 0600); err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(filepath.Clean(chartZip), data, 0600)
+}
+
 func closeHelper(file io.Closer) {
 	if err := file.Close(); err != nil {
 		klog.Error(err, " - Failed to close file: ", file)
@@ -39,6 +39,9 @@ var (
 	configMapNS   = "default"
 	secretName    = "secret-helmoutils"
 	secretNS      = "default"
+
+	// testChartDigest is the sha256 digest of testhr/helmrepo/subscription-release-test-1-0.1.0.tgz
+	testChartDigest = "2b9ada622755a18b6b9ab72e942f819bf7c2ba7362f15d8e8bf8056429f38769"
 )
 
 func TestGetConfig(t *testing.T) {
@@ -218,7 +221,7 @@ func TestDownloadChartHelmRepo(t *testing.T) {
 				},
 			},
 			ChartName: "subscription-release-test-1",
-			Digest:    "long-fake-digest-that-is-very-long",
+			Digest:    testChartDigest,
 		},
 	}
 	dir, err := ioutil.TempDir("/tmp", "charts")
@@ -232,7 +235,7 @@ func TestDownloadChartHelmRepo(t *testing.T) {
 	_, err = os.Stat(filepath.Join(destDir, "Chart.yaml"))
 	assert.NoError(t, err)
 
-	_, err = os.Stat(filepath.Join(destDir, "../", "subscription-release-test-1-0.1.0.tgz.long-f"))
+	_, err = os.Stat(filepath.Join(destDir, "../", "subscription-release-test-1-0.1.0.tgz.2b9ada"))
 	assert.NoError(t, err)
 }
 
@@ -395,7 +398,7 @@ func TestDownloadChartFromHelmRepoHTTP(t *testing.T) {
 				},
 			},
 			ChartName: "subscription-release-test-1",
-			Digest:    "short",
+			Digest:    testChartDigest,
 		},
 	}
 	dir, err := ioutil.TempDir("/tmp", "charts")
@@ -409,7 +412,7 @@ func TestDownloadChartFromHelmRepoHTTP(t *testing.T) {
 	_, err = os.Stat(filepath.Join(chartDir, "Chart.yaml"))
 	assert.NoError(t, err)
 
-	_, err = os.Stat(filepath.Join(chartDir, "../", "subscription-release-test-1-0.1.0.tgz.short"))
+	_, err = os.Stat(filepath.Join(chartDir, "../", "subscription-release-test-1-0.1.0.tgz.2b9ada"))
 	assert.NoError(t, err)
 }
 
@@ -459,7 +462,7 @@ func TestDownloadChartFromHelmRepoLocal(t *testing.T) {
 				},
 			},
 			ChartName: "subscription-release-test-1",
-			Digest:    "digest",
+			Digest:    testChartDigest,
 		},
 	}
 	dir, err := ioutil.TempDir("/tmp", "charts")
@@ -473,7 +476,7 @@ func TestDownloadChartFromHelmRepoLocal(t *testing.T) {
 	_, err = os.Stat(filepath.Join(chartDir, "Chart.yaml"))
 	assert.NoError(t, err)
 
-	_, err = os.Stat(filepath.Join(chartDir, "../", "subscription-release-test-1-0.1.0.tgz.digest"))
+	_, err = os.Stat(filepath.Join(chartDir, "../", "subscription-release-test-1-0.1.0.tgz.2b9ada"))
 	assert.NoError(t, err)
 }
 
@@ -524,3 +527,74 @@ func TestDownloadGitRepo(t *testing.T) {
 
 	assert.NotEqual(t, commitID, "")
 }
+
+func TestDownloadChartFromHelmRepoDigestMismatch(t *testing.T) {
+	hr := &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "subscription-release-test-1-cr",
+			Namespace: "default",
+		},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{
+				SourceType: appv1.HelmRepoSourceType,
+				HelmRepo: &appv1.HelmRepo{
+					Urls: []string{"file:../../../testhr/helmrepo/subscription-release-test-1-0.1.0.tgz"},
+				},
+			},
+			ChartName: "subscription-release-test-1",
+			Digest:    "cdadd08a4c424d57e2ca8af6c71dc3b6236a7f82ed333ba38bda05fd95d23b98",
+		},
+	}
+	dir, err := ioutil.TempDir("/tmp", "charts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	_, err = DownloadChartFromHelmRepo(nil, nil, dir, hr)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "digest mismatch")
+
+	_, err = os.Stat(filepath.Join(dir, "subscription-release-test-1-0.1.0.tgz.cdadd0"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadChartFromHelmRepoProvenance(t *testing.T) {
+	hr := &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "signtest-cr",
+			Namespace: "default",
+		},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{
+				SourceType: appv1.HelmRepoSourceType,
+				HelmRepo: &appv1.HelmRepo{
+					Urls: []string{"file:../../../testhr/helmrepo/provenance/signtest-0.1.0.tgz"},
+				},
+			},
+			ChartName: "signtest",
+			Digest:    "e5ef611620fb97704d8751c16bab17fedb68883bfb0edc76f78a70e9173f9b55",
+		},
+	}
+	dir, err := ioutil.TempDir("/tmp", "charts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	testutils.SetHelmProvenanceKeyring("../../../testhr/helmrepo/provenance/helm-test-key.pub")
+	defer testutils.SetHelmProvenanceKeyring("")
+
+	chartDir, err := DownloadChartFromHelmRepo(nil, nil, dir, hr)
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(chartDir, "Chart.yaml"))
+	assert.NoError(t, err)
+
+	// the chart without provenance file is not deployed
+	hr.Repo.Source.HelmRepo.Urls = []string{"file:../../../testhr/helmrepo/subscription-release-test-1-0.1.0.tgz"}
+	hr.Repo.ChartName = "subscription-release-test-1"
+	hr.Repo.Digest = testChartDigest
+
+	_, err = DownloadChartFromHelmRepo(nil, nil, dir, hr)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "provenance file")
+}
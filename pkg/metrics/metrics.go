@@ -0,0 +1,107 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// Subsystem prefixes the subscription metric names
+	Subsystem = "appsub"
+
+	// LastSyncTimestamp is the unix time the subscription resources were last applied without failure
+	LastSyncTimestamp = "last_sync_timestamp_seconds"
+	// GitCloneFailures counts the failed clones of the subscribed git repository
+	GitCloneFailures = "git_clone_failures_total"
+	// DriftDetected counts the deployed resources found changed from the subscribed template
+	DriftDetected = "drift_detected_total"
+	// OutOfSyncSince is the unix time the subscription started to fail reaching its source or applying its resources
+	OutOfSyncSince = "out_of_sync_since_timestamp_seconds"
+)
+
+var (
+	lastSyncTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: Subsystem,
+		Name:      LastSyncTimestamp,
+		Help:      "Unix time the subscription resources were last applied without failure",
+	}, []string{"namespace", "name"})
+
+	gitCloneFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: Subsystem,
+		Name:      GitCloneFailures,
+		Help:      "Count the failed clones of the subscribed git repository",
+	}, []string{"namespace", "name"})
+
+	driftDetected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: Subsystem,
+		Name:      DriftDetected,
+		Help:      "Count the deployed resources found changed from the subscribed template",
+	}, []string{"namespace", "name"})
+
+	outOfSyncSince = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: Subsystem,
+		Name:      OutOfSyncSince,
+		Help:      "Unix time the subscription started to fail reaching its source or applying its resources",
+	}, []string{"namespace", "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(lastSyncTimestamp, gitCloneFailures, driftDetected, outOfSyncSince)
+}
+
+// FullName returns the exported name of the metric
+func FullName(name string) string {
+	return prometheus.BuildFQName("", Subsystem, name)
+}
+
+// RecordSync records the subscription resources were applied without failure
+func RecordSync(sub types.NamespacedName) {
+	lastSyncTimestamp.WithLabelValues(sub.Namespace, sub.Name).Set(float64(time.Now().Unix()))
+}
+
+// RecordGitCloneFailure records a failed clone of the subscribed git repository
+func RecordGitCloneFailure(sub types.NamespacedName) {
+	gitCloneFailures.WithLabelValues(sub.Namespace, sub.Name).Inc()
+}
+
+// RecordDrift records a deployed resource of the subscription was changed outside of the subscription
+func RecordDrift(sub types.NamespacedName) {
+	driftDetected.WithLabelValues(sub.Namespace, sub.Name).Inc()
+}
+
+// RecordOutOfSync records the subscription is out of sync since the given time
+func RecordOutOfSync(sub types.NamespacedName, since time.Time) {
+	outOfSyncSince.WithLabelValues(sub.Namespace, sub.Name).Set(float64(since.Unix()))
+}
+
+// RecordInSync drops the out of sync series of the subscription, it only exists while the subscription is out of sync
+func RecordInSync(sub types.NamespacedName) {
+	outOfSyncSince.Delete(prometheus.Labels{"namespace": sub.Namespace, "name": sub.Name})
+}
+
+// DeleteSubscription drops the series of a removed subscription, so it doesn't show as a stale sync
+func DeleteSubscription(sub types.NamespacedName) {
+	labels := prometheus.Labels{"namespace": sub.Namespace, "name": sub.Name}
+
+	lastSyncTimestamp.Delete(labels)
+	gitCloneFailures.Delete(labels)
+	driftDetected.Delete(labels)
+	outOfSyncSince.Delete(labels)
+}
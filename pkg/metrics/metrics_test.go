@@ -0,0 +1,91 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRecordAndDeleteSubscription(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	sub := types.NamespacedName{Namespace: "default", Name: "git-sub"}
+
+	RecordSync(sub)
+	RecordGitCloneFailure(sub)
+	RecordGitCloneFailure(sub)
+	RecordDrift(sub)
+	RecordOutOfSync(sub, time.Unix(1600000000, 0))
+
+	g.Expect(testutil.ToFloat64(lastSyncTimestamp.WithLabelValues(sub.Namespace, sub.Name))).To(gomega.BeNumerically(">", 0))
+	g.Expect(testutil.ToFloat64(gitCloneFailures.WithLabelValues(sub.Namespace, sub.Name))).To(gomega.Equal(float64(2)))
+	g.Expect(testutil.ToFloat64(driftDetected.WithLabelValues(sub.Namespace, sub.Name))).To(gomega.Equal(float64(1)))
+	g.Expect(testutil.ToFloat64(outOfSyncSince.WithLabelValues(sub.Namespace, sub.Name))).To(gomega.Equal(float64(1600000000)))
+
+	RecordInSync(sub)
+
+	g.Expect(testutil.CollectAndCount(outOfSyncSince)).To(gomega.Equal(0))
+
+	RecordOutOfSync(sub, time.Now())
+	DeleteSubscription(sub)
+
+	g.Expect(testutil.CollectAndCount(lastSyncTimestamp)).To(gomega.Equal(0))
+	g.Expect(testutil.CollectAndCount(gitCloneFailures)).To(gomega.Equal(0))
+	g.Expect(testutil.CollectAndCount(driftDetected)).To(gomega.Equal(0))
+	g.Expect(testutil.CollectAndCount(outOfSyncSince)).To(gomega.Equal(0))
+}
+
+func TestMonitoringResources(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	b, err := PrometheusRule("monitoring")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	pr := &prometheusRule{}
+	g.Expect(yaml.Unmarshal(b, pr)).To(gomega.Succeed())
+	g.Expect(pr.Kind).To(gomega.Equal("PrometheusRule"))
+	g.Expect(pr.Namespace).To(gomega.Equal("monitoring"))
+	g.Expect(pr.Spec.Groups).To(gomega.HaveLen(1))
+
+	exprs := []string{}
+	for _, r := range pr.Spec.Groups[0].Rules {
+		exprs = append(exprs, r.Expr)
+	}
+
+	for _, name := range []string{LastSyncTimestamp, GitCloneFailures, DriftDetected, OutOfSyncSince} {
+		g.Expect(strings.Join(exprs, "\n")).To(gomega.ContainSubstring(FullName(name)))
+	}
+
+	b, err = DashboardConfigMap("monitoring")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	cm := &corev1.ConfigMap{}
+	g.Expect(yaml.Unmarshal(b, cm)).To(gomega.Succeed())
+	g.Expect(cm.Labels[DashboardConfigMapLabel]).To(gomega.Equal("1"))
+
+	d := &dashboard{}
+	g.Expect(json.Unmarshal([]byte(cm.Data[DashboardFileName]), d)).To(gomega.Succeed())
+	g.Expect(d.Panels).To(gomega.HaveLen(4))
+	g.Expect(d.Panels[1].Targets[0].Expr).To(gomega.ContainSubstring("appsub_git_clone_failures_total"))
+}
@@ -0,0 +1,245 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// MonitoringName is the name of the generated PrometheusRule and dashboard ConfigMap
+	MonitoringName = "multicluster-operators-subscription"
+	// DashboardFileName is the dashboard key in the dashboard ConfigMap
+	DashboardFileName = "appsub-dashboard.json"
+	// DashboardConfigMapLabel makes the grafana dashboard sidecar load the ConfigMap
+	DashboardConfigMapLabel = "grafana_dashboard"
+
+	// StaleSyncSeconds is how long a subscription can go without a successful sync before it is alerted.
+	// The resources are re-applied every 15 minutes by default, so it covers a few missed rounds.
+	StaleSyncSeconds = 3600
+	// CloneFailureThreshold is the number of failed git clones in 30 minutes before it is alerted
+	CloneFailureThreshold = 3
+	// OutOfSyncSeconds is how long a subscription can stay out of sync before it is alerted
+	OutOfSyncSeconds = 1800
+)
+
+// prometheusRule is the monitoring.coreos.com/v1 PrometheusRule, the prometheus operator types are not vendored
+type prometheusRule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              prometheusRuleSpec `json:"spec"`
+}
+
+type prometheusRuleSpec struct {
+	Groups []ruleGroup `json:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `json:"name"`
+	Rules []rule `json:"rules"`
+}
+
+type rule struct {
+	Alert       string            `json:"alert"`
+	Expr        string            `json:"expr"`
+	For         string            `json:"for,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// PrometheusRule returns the PrometheusRule yaml alerting on stale syncs, repeated git clone failures, subscriptions
+// staying out of sync and drift
+func PrometheusRule(namespace string) ([]byte, error) {
+	pr := prometheusRule{
+		TypeMeta: metav1.TypeMeta{APIVersion: "monitoring.coreos.com/v1", Kind: "PrometheusRule"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      MonitoringName,
+			Namespace: namespace,
+		},
+		Spec: prometheusRuleSpec{
+			Groups: []ruleGroup{
+				{
+					Name:  "appsub.rules",
+					Rules: alertRules(),
+				},
+			},
+		},
+	}
+
+	return yaml.Marshal(pr)
+}
+
+func alertRules() []rule {
+	return []rule{
+		{
+			Alert: "AppSubSyncStale",
+			Expr:  fmt.Sprintf("time() - %s > %d", FullName(LastSyncTimestamp), StaleSyncSeconds),
+			For:   "10m",
+			Labels: map[string]string{
+				"severity": "warning",
+			},
+			Annotations: map[string]string{
+				"summary": "Subscription {{ $labels.namespace }}/{{ $labels.name }} has not synced successfully for over an hour.",
+			},
+		},
+		{
+			Alert: "AppSubGitCloneFailing",
+			Expr:  fmt.Sprintf("increase(%s[30m]) >= %d", FullName(GitCloneFailures), CloneFailureThreshold),
+			Labels: map[string]string{
+				"severity": "warning",
+			},
+			Annotations: map[string]string{
+				"summary": "Subscription {{ $labels.namespace }}/{{ $labels.name }} failed to clone its git repository " +
+					"{{ $value }} times in 30 minutes.",
+			},
+		},
+		{
+			Alert: "AppSubOutOfSync",
+			Expr:  fmt.Sprintf("time() - %s > %d", FullName(OutOfSyncSince), OutOfSyncSeconds),
+			Labels: map[string]string{
+				"severity": "warning",
+			},
+			Annotations: map[string]string{
+				"summary": "Subscription {{ $labels.namespace }}/{{ $labels.name }} has been out of sync for " +
+					"{{ $value | humanizeDuration }}.",
+			},
+		},
+		{
+			Alert: "AppSubDriftDetected",
+			Expr:  fmt.Sprintf("increase(%s[1h]) > 0", FullName(DriftDetected)),
+			Labels: map[string]string{
+				"severity": "info",
+			},
+			Annotations: map[string]string{
+				"summary": "Resources of subscription {{ $labels.namespace }}/{{ $labels.name }} were changed outside of the subscription.",
+			},
+		},
+	}
+}
+
+type dashboard struct {
+	Title         string   `json:"title"`
+	UID           string   `json:"uid"`
+	Tags          []string `json:"tags"`
+	SchemaVersion int      `json:"schemaVersion"`
+	Refresh       string   `json:"refresh"`
+	Time          timeSpan `json:"time"`
+	Panels        []panel  `json:"panels"`
+}
+
+type timeSpan struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type panel struct {
+	ID      int           `json:"id"`
+	Title   string        `json:"title"`
+	Type    string        `json:"type"`
+	GridPos gridPos       `json:"gridPos"`
+	Targets []panelTarget `json:"targets"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type panelTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	RefID        string `json:"refId"`
+}
+
+// Dashboard returns the grafana dashboard JSON of the subscription metrics
+func Dashboard() ([]byte, error) {
+	legend := "{{namespace}}/{{name}}"
+
+	d := dashboard{
+		Title:         "Application Subscriptions",
+		UID:           "appsub",
+		Tags:          []string{"open-cluster-management", "subscription"},
+		SchemaVersion: 27,
+		Refresh:       "1m",
+		Time:          timeSpan{From: "now-6h", To: "now"},
+		Panels: []panel{
+			{
+				ID:      1,
+				Title:   "Time since last successful sync",
+				Type:    "timeseries",
+				GridPos: gridPos{H: 8, W: 24, X: 0, Y: 0},
+				Targets: []panelTarget{
+					{Expr: "time() - " + FullName(LastSyncTimestamp), LegendFormat: legend, RefID: "A"},
+				},
+			},
+			{
+				ID:      2,
+				Title:   "Git clone failures",
+				Type:    "timeseries",
+				GridPos: gridPos{H: 8, W: 12, X: 0, Y: 8},
+				Targets: []panelTarget{
+					{Expr: fmt.Sprintf("increase(%s[30m])", FullName(GitCloneFailures)), LegendFormat: legend, RefID: "A"},
+				},
+			},
+			{
+				ID:      3,
+				Title:   "Drift detected",
+				Type:    "timeseries",
+				GridPos: gridPos{H: 8, W: 12, X: 12, Y: 8},
+				Targets: []panelTarget{
+					{Expr: fmt.Sprintf("increase(%s[1h])", FullName(DriftDetected)), LegendFormat: legend, RefID: "A"},
+				},
+			},
+			{
+				ID:      4,
+				Title:   "Time out of sync",
+				Type:    "timeseries",
+				GridPos: gridPos{H: 8, W: 24, X: 0, Y: 16},
+				Targets: []panelTarget{
+					{Expr: "time() - " + FullName(OutOfSyncSince), LegendFormat: legend, RefID: "A"},
+				},
+			},
+		},
+	}
+
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// DashboardConfigMap returns the ConfigMap yaml holding the grafana dashboard, labeled for the grafana sidecar
+func DashboardConfigMap(namespace string) ([]byte, error) {
+	d, err := Dashboard()
+	if err != nil {
+		return nil, err
+	}
+
+	cm := corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      MonitoringName + "-dashboard",
+			Namespace: namespace,
+			Labels:    map[string]string{DashboardConfigMapLabel: "1"},
+		},
+		Data: map[string]string{DashboardFileName: string(d)},
+	}
+
+	return yaml.Marshal(cm)
+}
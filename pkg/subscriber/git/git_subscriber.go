@@ -16,15 +16,15 @@ package git
 
 import (
 	"errors"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
@@ -34,21 +34,14 @@ import (
 
 type itemmap map[types.NamespacedName]*SubscriberItem
 
-type SyncSource interface {
-	GetInterval() int
-	GetLocalClient() client.Client
-	GetLocalNonCachedClient() client.Client
-	GetRemoteClient() client.Client
-	GetRemoteNonCachedClient() client.Client
-	IsResourceNamespaced(*unstructured.Unstructured) bool
-	ProcessSubResources(*appv1alpha1.Subscription, []kubesynchronizer.ResourceUnit,
-		map[string]map[string]string, map[string]map[string]string, bool) error
-	PurgeAllSubscribedResources(*appv1alpha1.Subscription) error
-}
+// SyncSource is the synchronizer the subscriber applies the resources with
+type SyncSource = kubesynchronizer.SyncSource
 
 // Subscriber - information to run namespace subscription
 type Subscriber struct {
 	itemmap
+	// mu guards the itemmap, read by the Git webhook server
+	mu           sync.RWMutex
 	manager      manager.Manager
 	synchronizer SyncSource
 	syncinterval int
@@ -81,7 +74,7 @@ func Add(mgr manager.Manager, hubconfig *rest.Config, syncid *types.NamespacedNa
 		return err
 	}
 
-	sync.SkipAppSubStatusResDel = false
+	sync.SetSkipAppSubStatusResDel(false)
 
 	defaultSubscriber = CreateGitHubSubscriber(hubconfig, mgr.GetScheme(), mgr, sync, syncinterval)
 	if defaultSubscriber == nil {
@@ -95,6 +88,8 @@ func Add(mgr manager.Manager, hubconfig *rest.Config, syncid *types.NamespacedNa
 
 // SubscribeItem subscribes a subscriber item with namespace channel.
 func (ghs *Subscriber) SubscribeItem(subitem *appv1alpha1.SubscriberItem) error {
+	ghs.mu.Lock()
+
 	if ghs.itemmap == nil {
 		ghs.itemmap = make(map[types.NamespacedName]*SubscriberItem)
 	}
@@ -108,20 +103,33 @@ func (ghs *Subscriber) SubscribeItem(subitem *appv1alpha1.SubscriberItem) error
 		ghssubitem = &SubscriberItem{}
 		ghssubitem.syncinterval = ghs.syncinterval
 		ghssubitem.synchronizer = ghs.synchronizer
+		ghssubitem.syncch = make(chan struct{}, 1)
 	}
 
+	previous := ghssubitem.SubscriberItem
+
 	subitem.DeepCopyInto(&ghssubitem.SubscriberItem)
 
 	ghs.itemmap[itemkey] = ghssubitem
 
+	ghs.mu.Unlock()
+
+	if !ok {
+		ghssubitem.restoreState()
+	}
+
 	previousReconcileLevel := ghssubitem.reconcileRate
 
 	previousDesiredCommit := ghssubitem.desiredCommit
 
 	previousDesiredTag := ghssubitem.desiredTag
 
+	previousDesiredTagConstraint := ghssubitem.desiredTagConstraint
+
 	previousSyncTime := ghssubitem.syncTime
 
+	previousResyncPackage := ghssubitem.resyncPackage
+
 	chnAnnotations := ghssubitem.Channel.GetAnnotations()
 
 	subAnnotations := ghssubitem.Subscription.GetAnnotations()
@@ -150,7 +158,43 @@ func (ghs *Subscriber) SubscribeItem(subitem *appv1alpha1.SubscriberItem) error
 
 	ghssubitem.desiredCommit = subAnnotations[appv1alpha1.AnnotationGitTargetCommit]
 	ghssubitem.desiredTag = subAnnotations[appv1alpha1.AnnotationGitTag]
+	ghssubitem.desiredTagConstraint = utils.GetSubscriptionGitTagConstraint(ghssubitem.Subscription)
 	ghssubitem.syncTime = subAnnotations[appv1alpha1.AnnotationManualReconcileTime]
+	ghssubitem.resyncPackage = subAnnotations[appv1alpha1.AnnotationResyncPackage]
+
+	// A new resync request only re-applies the requested package. A request already present when the item is
+	// created, e.g. after a restart of the subscription pod, was handled before.
+	if ok && ghssubitem.resyncPackage != "" && previousResyncPackage != ghssubitem.resyncPackage {
+		klog.Infof("Resync of package %s is requested", ghssubitem.resyncPackage)
+
+		ghssubitem.resyncPending = true
+	}
+	// If the channel credentials or the package filter have changed, re-render and re-apply the resources immediately
+	referenceChanged := ok && utils.IsSubscriberItemReferenceChanged(&previous, &ghssubitem.SubscriberItem)
+	if referenceChanged {
+		klog.Infof("The referenced secrets or config maps of %v have changed. restart to reconcile resources", itemkey)
+
+		// reset commit ID to force sync
+		ghssubitem.commitID = ""
+	}
+
+	// If the channel spec has changed, e.g. its pathname or secretRef, the clone and the commit of the previous source
+	// are stale. Clone the new source and reconcile the resources like a new commit, the ones removed are deleted.
+	channelChanged := ok && utils.IsSubscriberItemChannelChanged(&previous, &ghssubitem.SubscriberItem)
+	if channelChanged {
+		klog.Infof("The channel of %v has changed. restart to reconcile resources from the new source", itemkey)
+
+		ghssubitem.commitID = ""
+
+		// the sync in progress may still be reading or updating the clone, it is stopped before the clone is
+		// removed, and restarted below
+		if !ghssubitem.Stop() {
+			klog.Warningf("the sync of %v is still running, keeping its git clone", itemkey.String())
+		} else if err := os.RemoveAll(utils.GetLocalGitFolder(ghssubitem.Subscription)); err != nil {
+			klog.Warningf("failed to remove the git clone of %v, err: %v", itemkey.String(), err)
+		}
+	}
+
 	ghssubitem.userID = strings.Trim(subAnnotations[appv1alpha1.AnnotationUserIdentity], "")
 	ghssubitem.userGroup = strings.Trim(subAnnotations[appv1alpha1.AnnotationUserGroup], "")
 
@@ -163,7 +207,7 @@ func (ghs *Subscriber) SubscribeItem(subitem *appv1alpha1.SubscriberItem) error
 		// applied until the next webhook event.
 		ghssubitem.successful = false
 
-		ghssubitem.doSubscriptionWithRetries(time.Hour*3, 10)
+		ghssubitem.doSubscriptionWithRetries(ghssubitem.syncContext(), time.Hour*3, 10)
 
 		klog.Info("Webhook event processed")
 
@@ -203,6 +247,13 @@ func (ghs *Subscriber) SubscribeItem(subitem *appv1alpha1.SubscriberItem) error
 		restart = true
 	}
 
+	if previousDesiredTagConstraint != ghssubitem.desiredTagConstraint {
+		klog.Infof("desired tag constraint has changed from %s to %s. restart to reconcile resources",
+			previousDesiredTagConstraint, ghssubitem.desiredTagConstraint)
+
+		restart = true
+	}
+
 	// If manual sync time is updated, we want to restart the reconcile cycle and deploy the new commit immediately
 	if !strings.EqualFold(previousSyncTime, ghssubitem.syncTime) {
 		klog.Infof("Manual reconcile time has changed from %s to %s. restart to reconcile resources", previousSyncTime, ghssubitem.syncTime)
@@ -213,6 +264,10 @@ func (ghs *Subscriber) SubscribeItem(subitem *appv1alpha1.SubscriberItem) error
 		restart = true
 	}
 
+	if ghssubitem.resyncPending || referenceChanged || channelChanged {
+		restart = true
+	}
+
 	ghssubitem.Start(restart)
 
 	return nil
@@ -222,11 +277,22 @@ func (ghs *Subscriber) SubscribeItem(subitem *appv1alpha1.SubscriberItem) error
 func (ghs *Subscriber) UnsubscribeItem(key types.NamespacedName) error {
 	klog.Info("git UnsubscribeItem ", key)
 
+	ghs.mu.Lock()
 	subitem, ok := ghs.itemmap[key]
+	delete(ghs.itemmap, key)
+	ghs.mu.Unlock()
 
 	if ok {
-		subitem.Stop()
-		delete(ghs.itemmap, key)
+		stopped := subitem.Stop()
+
+		utils.DeleteSubscriberState(ghs.synchronizer.GetLocalClient(), subitem.Subscription)
+
+		// the clone is not reused once the subscription is deleted, unless the sync still running is using it
+		if !stopped {
+			klog.Warningf("the sync of %v is still running, keeping its git clone", key.String())
+		} else if err := os.RemoveAll(utils.GetLocalGitFolder(subitem.Subscription)); err != nil {
+			klog.Warningf("failed to remove the git clone of %v, err: %v", key.String(), err)
+		}
 
 		if err := ghs.synchronizer.PurgeAllSubscribedResources(subitem.Subscription); err != nil {
 			klog.Errorf("failed to unsubscribe  %v, err: %v", key.String(), err)
@@ -17,6 +17,7 @@ package git
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
 	"strconv"
@@ -29,13 +30,14 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
 
 	corev1 "k8s.io/api/core/v1"
 
 	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
 	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/channelcache"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/metrics"
 	kubesynchronizer "open-cluster-management.io/multicloud-operators-subscription/pkg/synchronizer/kubernetes"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
 )
@@ -47,6 +49,8 @@ const (
 	AccessToken = "accessToken"
 	// Path is the key of GitHub package filter config map
 	Path = "path"
+	// stopTimeout is how long Stop waits for the sync in flight to abort
+	stopTimeout = 30 * time.Second
 )
 
 var (
@@ -65,17 +69,33 @@ type SubscriberItem struct {
 	otherFiles             []string
 	repoRoot               string
 	commitID               string
+	commitAuthor           *utils.GitCommitAuthor // set when the content comes without git history
 	reconcileRate          string
 	desiredCommit          string
 	desiredTag             string
+	desiredTagConstraint   string
 	syncTime               string
+	resyncPackage          string
+	resyncPending          bool
 	stopch                 chan struct{}
+	syncch                 chan struct{} // signaled by the Git push webhooks
+	ctx                    context.Context
+	cancel                 context.CancelFunc // aborts the sync in flight when the item is stopped
+	done                   chan struct{}      // closed when the reconcile goroutine exits
 	syncinterval           int
 	count                  int
 	synchronizer           SyncSource
 	chartDirs              map[string]string
 	kustomizeDirs          map[string]string
 	resources              []kubesynchronizer.ResourceUnit
+	inventory              []string                     // the resources of the last successful sync
+	savedState             *appv1.SubscriberState       // the subscriber state last saved in the state store
+	allowedGroupResources  map[string]map[string]string // the allow list of the subscription, by apiVersion and kind
+	deniedGroupResources   map[string]map[string]string // the deny list of the subscription, by apiVersion and kind
+	namespaceContainment   string                       // the namespace containment policy of the subscription namespace
+	sopsKeys               []string                     // the keys the SOPS encrypted files are decrypted with
+	pluginErr              error                        // the failure of a package filter plugin in the sync
+	pluginCandidates       packageCandidates            // the resources to check with the package filter plugins
 	indexFile              *repo.IndexFile
 	webhookEnabled         bool
 	successful             bool
@@ -85,6 +105,9 @@ type SubscriberItem struct {
 	userGroup              string
 }
 
+// packageCandidates are the package candidates of the resources, the resources as they are in the repo
+type packageCandidates map[*unstructured.Unstructured]utils.PackageCandidate
+
 type kubeResource struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -98,76 +121,180 @@ func (ghsi *SubscriberItem) Start(restart bool) {
 			// restart this goroutine
 			klog.Info("Stopping SubscriberItem: ", ghsi.Subscription.Name)
 			ghsi.Stop()
+
+			ghsi.count = 0 // reset the counter, a new item resumes from its restored state
 		} else {
 			klog.Info("SubscriberItem already started: ", ghsi.Subscription.Name)
 			return
 		}
 	}
 
-	ghsi.count = 0 // reset the counter
+	if ghsi.cancel != nil {
+		// the context of the webhook syncs
+		ghsi.cancel()
+	}
 
 	ghsi.stopch = make(chan struct{})
+	ghsi.ctx, ghsi.cancel = context.WithCancel(context.Background())
 
 	loopPeriod, retryInterval, retries := utils.GetReconcileInterval(ghsi.reconcileRate, chnv1.ChannelTypeGit)
 
 	if strings.EqualFold(ghsi.reconcileRate, "off") {
 		klog.Infof("auto-reconcile is OFF")
 
-		ghsi.doSubscriptionWithRetries(retryInterval, retries)
+		ghsi.doSubscriptionWithRetries(ghsi.ctx, retryInterval, retries)
 
 		return
 	}
 
-	go wait.Until(func() {
-		tw := ghsi.SubscriberItem.Subscription.Spec.TimeWindow
-		if tw != nil {
-			nextRun := utils.NextStartPoint(tw, time.Now())
-			if nextRun > time.Duration(0) {
-				klog.Infof("Subscription is currently blocked by the time window. It %v/%v will be deployed after %v",
-					ghsi.SubscriberItem.Subscription.GetNamespace(),
-					ghsi.SubscriberItem.Subscription.GetName(), nextRun)
+	stopch := ghsi.stopch
+	ctx := ghsi.ctx
+	ghsi.done = make(chan struct{})
+	done := ghsi.done
+
+	go func() {
+		defer close(done)
+
+		for {
+			select {
+			case <-stopch:
+				return
+			default:
+			}
+
+			ghsi.reconcile(ctx, retryInterval, retries)
+
+			// wait for the next reconcile, or for a push to the Git repository
+			timer := time.NewTimer(loopPeriod)
+
+			select {
+			case <-stopch:
+				timer.Stop()
 
 				return
+			case <-ghsi.syncch:
+				timer.Stop()
+				klog.Infof("Git push event received, syncing subscription %v/%v",
+					ghsi.SubscriberItem.Subscription.GetNamespace(), ghsi.SubscriberItem.Subscription.GetName())
+			case <-timer.C:
 			}
 		}
+	}()
+}
 
-		// if the subscription pause lable is true, stop subscription here.
-		if utils.GetPauseLabel(ghsi.SubscriberItem.Subscription) {
-			klog.Infof("Git Subscription %v/%v is paused.", ghsi.SubscriberItem.Subscription.GetNamespace(), ghsi.SubscriberItem.Subscription.GetName())
+func (ghsi *SubscriberItem) reconcile(ctx context.Context, retryInterval time.Duration, retries int) {
+	tw := ghsi.SubscriberItem.Subscription.Spec.TimeWindow
+	if tw != nil {
+		nextRun := utils.NextStartPoint(tw, time.Now())
+		if nextRun > time.Duration(0) {
+			klog.Infof("Subscription is currently blocked by the time window. It %v/%v will be deployed after %v",
+				ghsi.SubscriberItem.Subscription.GetNamespace(),
+				ghsi.SubscriberItem.Subscription.GetName(), nextRun)
 
 			return
 		}
+	}
+
+	// if the subscription pause lable is true, stop subscription here.
+	if utils.GetPauseLabel(ghsi.SubscriberItem.Subscription) {
+		klog.Infof("Git Subscription %v/%v is paused.", ghsi.SubscriberItem.Subscription.GetNamespace(), ghsi.SubscriberItem.Subscription.GetName())
+
+		return
+	}
 
-		ghsi.doSubscriptionWithRetries(retryInterval, retries)
-	}, loopPeriod, ghsi.stopch)
+	ghsi.doSubscriptionWithRetries(ctx, retryInterval, retries)
 }
 
-// Stop unsubscribes a subscriber item with namespace channel
-func (ghsi *SubscriberItem) Stop() {
+// triggerSync syncs the item now instead of at the next reconcile, a sync already pending is not queued twice
+func (ghsi *SubscriberItem) triggerSync() {
+	select {
+	case ghsi.syncch <- struct{}{}:
+	default:
+	}
+}
+
+// Stop unsubscribes a subscriber item with namespace channel. The sync in flight is aborted, and Stop waits for
+// the reconcile goroutine to exit, up to stopTimeout. It returns false if the goroutine is still running then.
+func (ghsi *SubscriberItem) Stop() bool {
+	stopped := true
+
 	klog.Info("Stopping SubscriberItem ", ghsi.Subscription.Name)
-	close(ghsi.stopch)
+
+	if ghsi.cancel != nil {
+		ghsi.cancel()
+
+		// the next syncs get a new context
+		ghsi.ctx, ghsi.cancel = nil, nil
+	}
+
+	if ghsi.stopch != nil {
+		close(ghsi.stopch)
+		ghsi.stopch = nil
+	}
+
+	if ghsi.done != nil {
+		select {
+		case <-ghsi.done:
+		case <-time.After(stopTimeout):
+			klog.Warningf("SubscriberItem %v/%v is still syncing after %v, not waiting for it",
+				ghsi.Subscription.Namespace, ghsi.Subscription.Name, stopTimeout)
+
+			stopped = false
+		}
+
+		ghsi.done = nil
+	}
+
+	return stopped
+}
+
+// syncContext returns the context of the item syncs, cancelled when the item is stopped
+func (ghsi *SubscriberItem) syncContext() context.Context {
+	if ghsi.ctx == nil {
+		ghsi.ctx, ghsi.cancel = context.WithCancel(context.Background())
+	}
+
+	return ghsi.ctx
 }
 
-func (ghsi *SubscriberItem) doSubscriptionWithRetries(retryInterval time.Duration, retries int) {
-	err := ghsi.doSubscription()
+func (ghsi *SubscriberItem) doSubscriptionWithRetries(ctx context.Context, retryInterval time.Duration, retries int) {
+	err := ghsi.doSubscription(ctx)
 
 	if err != nil {
 		klog.Error(err, "Subscription error.")
 	}
 
+	if ctx.Err() != nil {
+		// the item is stopped, its subscription may be deleted already
+		return
+	}
+
+	ghsi.updateSyncStatus(err == nil)
+
 	// If the initial subscription fails, retry.
 	n := 0
 
 	for n < retries {
 		if !ghsi.successful {
-			time.Sleep(retryInterval)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryInterval):
+			}
+
 			klog.Infof("Re-try #%d: subcribing to the Git repo", n+1)
 
-			err = ghsi.doSubscription()
+			err = ghsi.doSubscription(ctx)
 			if err != nil {
 				klog.Error(err, "Subscription error.")
 			}
 
+			if ctx.Err() != nil {
+				return
+			}
+
+			ghsi.updateSyncStatus(err == nil)
+
 			n++
 		} else {
 			break
@@ -175,7 +302,48 @@ func (ghsi *SubscriberItem) doSubscriptionWithRetries(retryInterval time.Duratio
 	}
 }
 
-func (ghsi *SubscriberItem) doSubscription() error {
+// updateSyncStatus records the result of the sync and the deployed commit in the appsub status, and saves the
+// subscriber state
+func (ghsi *SubscriberItem) updateSyncStatus(inSync bool) {
+	utils.UpdateOutOfSyncStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, inSync)
+	utils.UpdateDeployedCommitStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, ghsi.commitID, ghsi.desiredCommit)
+
+	state := &appv1.SubscriberState{
+		Revision:   ghsi.commitID,
+		Generation: ghsi.Subscription.Generation,
+		Successful: ghsi.successful,
+		Retries:    ghsi.count,
+		Inventory:  ghsi.inventory,
+	}
+
+	ghsi.savedState = utils.SaveSubscriberState(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, state, ghsi.savedState)
+}
+
+// restoreState resumes the item from the subscriber state saved by the previous controller of the subscription, the
+// commit it deployed is not deployed again unless it failed
+func (ghsi *SubscriberItem) restoreState() {
+	state := utils.LoadSubscriberState(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription)
+	if state == nil {
+		return
+	}
+
+	klog.Infof("Restoring the subscriber state of %v/%v, commit: %v, successful: %v", ghsi.Subscription.Namespace,
+		ghsi.Subscription.Name, state.Revision, state.Successful)
+
+	ghsi.count = state.Retries
+	ghsi.inventory = state.Inventory
+	ghsi.savedState = state
+
+	if state.Generation != ghsi.Subscription.Generation {
+		// the subscription changed since the commit was deployed, it is deployed again
+		return
+	}
+
+	ghsi.commitID = state.Revision
+	ghsi.successful = state.Successful
+}
+
+func (ghsi *SubscriberItem) doSubscription(ctx context.Context) error {
 	hostkey := types.NamespacedName{Name: ghsi.Subscription.Name, Namespace: ghsi.Subscription.Namespace}
 	klog.Info("enter doSubscription: ", hostkey.String())
 
@@ -187,7 +355,7 @@ func (ghsi *SubscriberItem) doSubscription() error {
 	if ghsi.webhookEnabled {
 		klog.Infof("Git Webhook is enabled on subscription %s.", ghsi.Subscription.Name)
 
-		if ghsi.successful {
+		if ghsi.successful && !ghsi.resyncPending {
 			klog.Infof("All resources are reconciled successfully. Waiting for the next Git Webhook event.")
 			return nil
 		}
@@ -218,6 +386,9 @@ func (ghsi *SubscriberItem) doSubscription() error {
 		if sec != nil {
 			klog.V(1).Info("updated in memory channel secret for ", ghsi.Subscription.Name)
 			ghsi.ChannelSecret = sec
+		} else if ghsi.Channel.Spec.SecretRef == nil {
+			// the secret reference is removed from the channel
+			ghsi.ChannelSecret = nil
 		}
 
 		if cm != nil {
@@ -246,6 +417,8 @@ func (ghsi *SubscriberItem) doSubscription() error {
 		if sec != nil {
 			klog.Info("updated in memory secondary channel secret for ", ghsi.Subscription.Name)
 			ghsi.SecondaryChannelSecret = sec
+		} else if ghsi.SecondaryChannel.Spec.SecretRef == nil {
+			ghsi.SecondaryChannelSecret = nil
 		}
 
 		if cm != nil {
@@ -254,17 +427,60 @@ func (ghsi *SubscriberItem) doSubscription() error {
 		}
 	}
 
+	if ghsi.isBranchUnchanged() {
+		klog.Infof("Appsub %s Git commit: %s hasn't changed on the Git provider. Skip reconcile.", hostkey.String(), ghsi.commitID)
+
+		return nil
+	}
+
 	//Clone the git repo
-	commitID, err := ghsi.cloneGitRepo()
+	source, err := ghsi.cloneGitRepo(ctx)
 	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("subscription %v is stopped, aborting the sync, err: %w", hostkey, ctx.Err())
+		}
+
 		klog.Error(err, "Unable to clone the git repo ", ghsi.Channel.Spec.Pathname)
 		ghsi.successful = false
 
+		metrics.RecordGitCloneFailure(hostkey)
+
+		if utils.IsRepoLimitExceeded(err) {
+			utils.UpdateRepoLimitStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, err.Error())
+		}
+
+		if utils.IsGitAuthFailed(err) {
+			// the next sync reads the channel secrets again, a rotated token is picked up without a restart
+			utils.InvalidateGitCredentials(ghsi.ChannelSecret)
+			utils.InvalidateGitCredentials(ghsi.SecondaryChannelSecret)
+			utils.UpdateAuthFailedStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, err.Error())
+		}
+
 		return err
 	}
 
+	utils.UpdateAuthFailedStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, "")
+
+	commitID := source.Commit
+
 	klog.Info("Git commit: ", commitID)
 
+	if err := ghsi.checkCommitAuthor(commitID); err != nil {
+		klog.Error(err, " Skip deploying git commit ", commitID)
+
+		ghsi.successful = false
+
+		return err
+	}
+
+	if err := ghsi.checkCommitSignature(source); err != nil {
+		klog.Error(err, " Skip deploying git commit ", commitID)
+
+		ghsi.successful = false
+
+		return err
+	}
+
 	if strings.EqualFold(ghsi.reconcileRate, "medium") {
 		// every 3 minutes, compare commit ID. If changed, reconcile resources.
 		// every 15 minutes, reconcile resources without commit ID comparison.
@@ -274,7 +490,7 @@ func (ghsi *SubscriberItem) doSubscription() error {
 			klog.Infof("No previous commit. DEPLOY")
 		} else {
 			if ghsi.count < 6 {
-				if commitID == ghsi.commitID && ghsi.successful {
+				if commitID == ghsi.commitID && ghsi.successful && !ghsi.resyncPending {
 					klog.Infof("Appsub %s Git commit: %s hasn't changed. Skip reconcile.", hostkey.String(), commitID)
 
 					return nil
@@ -287,6 +503,8 @@ func (ghsi *SubscriberItem) doSubscription() error {
 	}
 
 	ghsi.resources = []kubesynchronizer.ResourceUnit{}
+	ghsi.allowedGroupResources, ghsi.deniedGroupResources = utils.GetAllowDenyLists(*ghsi.Subscription)
+	ghsi.namespaceContainment = utils.GetNamespaceContainment(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription.Namespace)
 
 	err = ghsi.sortClonedGitRepo()
 	if err != nil {
@@ -294,45 +512,55 @@ func (ghsi *SubscriberItem) doSubscription() error {
 
 		ghsi.successful = false
 
+		if utils.IsRepoLimitExceeded(err) {
+			utils.UpdateRepoLimitStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, err.Error())
+		}
+
 		return err
 	}
 
-	errMsg := ""
+	utils.UpdateRepoLimitStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, "")
+
+	// the errors of the resources failing to subscribe, the others are still applied
+	errMsgs := []string{}
+
+	ghsi.pluginErr = nil
+	ghsi.pluginCandidates = packageCandidates{}
 
 	klog.Info("Applying crd resources: ", ghsi.crdsAndNamespaceFiles)
 
-	err = ghsi.subscribeResources(ghsi.crdsAndNamespaceFiles)
+	err = ghsi.subscribeResources(ctx, ghsi.crdsAndNamespaceFiles)
 
 	if err != nil {
 		klog.Error(err, " Unable to subscribe crd and ns resources")
 
 		ghsi.successful = false
 
-		errMsg += err.Error()
+		errMsgs = append(errMsgs, err.Error())
 	}
 
 	klog.Info("Applying rbac resources: ", ghsi.rbacFiles)
 
-	err = ghsi.subscribeResources(ghsi.rbacFiles)
+	err = ghsi.subscribeResources(ctx, ghsi.rbacFiles)
 
 	if err != nil {
 		klog.Error(err, " Unable to subscribe rbac resources")
 
 		ghsi.successful = false
 
-		errMsg += err.Error()
+		errMsgs = append(errMsgs, err.Error())
 	}
 
 	klog.Info("Applying other resources: ", ghsi.otherFiles)
 
-	err = ghsi.subscribeResources(ghsi.otherFiles)
+	err = ghsi.subscribeResources(ctx, ghsi.otherFiles)
 
 	if err != nil {
 		klog.Error(err, " Unable to subscribe other resources")
 
 		ghsi.successful = false
 
-		errMsg += err.Error()
+		errMsgs = append(errMsgs, err.Error())
 	}
 
 	klog.Info("Applying kustomizations: ", ghsi.kustomizeDirs)
@@ -344,7 +572,17 @@ func (ghsi *SubscriberItem) doSubscription() error {
 
 		ghsi.successful = false
 
-		errMsg += err.Error()
+		errMsgs = append(errMsgs, err.Error())
+	}
+
+	// the resources are sent to the package filter plugins together
+	if err := ghsi.filterResourcesOnPlugins(); err != nil {
+		klog.Error(err, " Unable to filter the resources with the package filter plugins")
+
+		ghsi.successful = false
+		ghsi.pluginErr = err
+
+		errMsgs = append(errMsgs, err.Error())
 	}
 
 	klog.Info("Applying helm charts..")
@@ -356,7 +594,31 @@ func (ghsi *SubscriberItem) doSubscription() error {
 
 		ghsi.successful = false
 
-		errMsg += err.Error()
+		errMsgs = append(errMsgs, err.Error())
+	}
+
+	if ghsi.pluginErr != nil {
+		// the resources the package filter plugin couldn't decide on would be pruned, nothing is applied until it
+		// answers
+		pluginErr := ghsi.pluginErr
+
+		ghsi.resetSortedResources()
+
+		utils.UpdateFailureReasonStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, utils.ReasonResourceErrors,
+			strings.Join(errMsgs, "; "))
+
+		return fmt.Errorf("git commit %v is not applied, err: %w", commitID, pluginErr)
+	}
+
+	if len(errMsgs) > 0 && utils.IsStrictSync() {
+		// nothing is applied, the resources of the failed packages would be pruned. The commit is retried until all
+		// its packages are applied
+		ghsi.resetSortedResources()
+
+		utils.UpdateFailureReasonStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, utils.ReasonResourceErrors,
+			strings.Join(errMsgs, "; "))
+
+		return fmt.Errorf("strict sync, git commit %v is not applied, err: %v", commitID, strings.Join(errMsgs, "; "))
 	}
 
 	standaloneSubscription := false
@@ -375,13 +637,36 @@ func (ghsi *SubscriberItem) doSubscription() error {
 			klog.Error("failed to prepare resources to apply and there is no resource to apply. quit")
 		}
 
-		return errors.New("failed to prepare resources to apply and there is no resource to apply. err: " + errMsg)
+		return errors.New("failed to prepare resources to apply and there is no resource to apply. err: " + strings.Join(errMsgs, "; "))
 	}
 
-	allowedGroupResources, deniedGroupResources := utils.GetAllowDenyLists(*ghsi.Subscription)
+	// nothing is applied once the item is stopped, the resources of a deleted subscription are being purged
+	if ctx.Err() != nil {
+		ghsi.resetSortedResources()
+
+		return fmt.Errorf("subscription %v is stopped, aborting the sync, err: %w", hostkey, ctx.Err())
+	}
+
+	if ghsi.resyncPending {
+		// the request is handled once, the next round reconciles all resources as usual
+		ghsi.resyncPending = false
+
+		err := ghsi.synchronizer.ResyncSubResources(ghsi.Subscription, ghsi.resources,
+			ghsi.allowedGroupResources, ghsi.deniedGroupResources, ghsi.clusterAdmin)
+
+		ghsi.resetSortedResources()
+
+		if err != nil {
+			klog.Error(err)
+
+			return err
+		}
+
+		return nil
+	}
 
 	if err := ghsi.synchronizer.ProcessSubResources(ghsi.Subscription, ghsi.resources,
-		allowedGroupResources, deniedGroupResources, ghsi.clusterAdmin); err != nil {
+		ghsi.allowedGroupResources, ghsi.deniedGroupResources, ghsi.clusterAdmin); err != nil {
 		klog.Error(err)
 
 		ghsi.successful = false
@@ -389,8 +674,52 @@ func (ghsi *SubscriberItem) doSubscription() error {
 		return err
 	}
 
+	if len(errMsgs) > 0 && utils.IsAtLeastOnce(ghsi.Subscription) {
+		// the commit is not deployed until all its packages are applied once, the failed ones are retried
+		ghsi.resetSortedResources()
+
+		utils.UpdateFailureReasonStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, utils.ReasonResourceErrors,
+			strings.Join(errMsgs, "; "))
+
+		return fmt.Errorf("git commit %v is applied without its failed packages, retrying them, err: %v",
+			commitID, strings.Join(errMsgs, "; "))
+	}
+
 	ghsi.commitID = commitID
 
+	utils.UpdateSourceStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, ghsi.getSubscriptionSource(source), commitID)
+	ghsi.synchronizer.RecordSyncAudit(ghsi.Subscription, commitID)
+
+	// the resources subscribed are applied, report the ones that failed in the status. An empty summary clears the
+	// errors reported by a previous commit
+	utils.UpdateFailureReasonStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, utils.ReasonResourceErrors,
+		strings.Join(errMsgs, "; "))
+
+	ghsi.inventory = getInventory(ghsi.resources)
+
+	ghsi.resetSortedResources()
+	ghsi.successful = true
+
+	return nil
+}
+
+// getInventory returns the inventory keys of the resources
+func getInventory(resources []kubesynchronizer.ResourceUnit) []string {
+	inventory := []string{}
+
+	for _, resource := range resources {
+		if resource.Resource == nil {
+			continue
+		}
+
+		inventory = append(inventory, utils.GetInventoryKey(resource.Resource.GetAPIVersion(), resource.Resource.GetKind(),
+			resource.Resource.GetNamespace(), resource.Resource.GetName()))
+	}
+
+	return inventory
+}
+
+func (ghsi *SubscriberItem) resetSortedResources() {
 	ghsi.resources = nil
 	ghsi.chartDirs = nil
 	ghsi.kustomizeDirs = nil
@@ -398,13 +727,15 @@ func (ghsi *SubscriberItem) doSubscription() error {
 	ghsi.rbacFiles = nil
 	ghsi.otherFiles = nil
 	ghsi.indexFile = nil
-	ghsi.successful = true
-
-	return nil
+	ghsi.allowedGroupResources = nil
+	ghsi.deniedGroupResources = nil
+	ghsi.sopsKeys = nil
+	ghsi.pluginErr = nil
+	ghsi.pluginCandidates = nil
 }
 
 func (ghsi *SubscriberItem) subscribeKustomizations() error {
-	for _, kustomizeDir := range ghsi.kustomizeDirs {
+	for _, kustomizeDir := range utils.SortedDirs(ghsi.kustomizeDirs) {
 		klog.Info("Applying kustomization ", kustomizeDir)
 
 		relativePath := kustomizeDir
@@ -463,69 +794,242 @@ func checkSubscriptionAnnotation(resource kubeResource) error {
 	return nil
 }
 
-func (ghsi *SubscriberItem) subscribeResources(rscFiles []string) error {
+// subscribeResources adds the resources of the files to the resources to apply. A file failing doesn't stop the
+// other files from being subscribed, the errors of all the files are returned together.
+func (ghsi *SubscriberItem) subscribeResources(ctx context.Context, rscFiles []string) error {
+	fileErrs := []string{}
+
 	// sync kube resource manifests
 	for _, rscFile := range rscFiles {
-		file, err := ioutil.ReadFile(rscFile) // #nosec G304 rscFile is not user input
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 
-		if err != nil {
-			klog.Error(err, "Failed to read YAML file "+rscFile)
+		if err := ghsi.subscribeResourcesOfFile(rscFile); err != nil {
+			klog.Error(err, " Failed to subscribe resources of YAML file "+rscFile)
 
-			return err
+			relPath, relErr := filepath.Rel(ghsi.repoRoot, rscFile)
+			if relErr != nil {
+				relPath = rscFile
+			}
+
+			fileErrs = append(fileErrs, relPath+": "+err.Error())
 		}
+	}
+
+	if len(fileErrs) > 0 {
+		return fmt.Errorf("failed to subscribe %d of %d files: %v", len(fileErrs), len(rscFiles), strings.Join(fileErrs, "; "))
+	}
 
-		resources := utils.ParseKubeResoures(file)
+	return nil
+}
 
-		if len(resources) > 0 {
-			for _, resource := range resources {
-				t := kubeResource{}
-				err := yaml.Unmarshal(resource, &t)
+func (ghsi *SubscriberItem) subscribeResourcesOfFile(rscFile string) error {
+	file, err := ioutil.ReadFile(rscFile) // #nosec G304 rscFile is not user input
 
-				if err != nil {
-					// Ignore if it does not have apiVersion or kind fields in the YAML
-					klog.Infof("Invalid kube resources. err: %v ", err)
+	if err != nil {
+		return err
+	}
 
-					continue
-				}
+	resources, parseErr := utils.ParseKubeResourcesWithErrors(file)
 
-				klog.V(1).Info("Applying Kubernetes resource of kind ", t.Kind)
+	errs := []string{}
+	if parseErr != nil {
+		errs = append(errs, parseErr.Error())
+	}
 
-				if t.Kind == "Subscription" {
-					klog.V(1).Infof("Injecting userID(%s), Group(%s) to subscription", ghsi.userID, ghsi.userGroup)
+	resources, err = ghsi.decryptSopsResources(resources)
+	if err != nil {
+		errs = append(errs, "SOPS encrypted documents: "+err.Error())
+	}
 
-					o := &unstructured.Unstructured{}
-					if err := yaml.Unmarshal(resource, o); err != nil {
-						klog.Error("Failed to unmarshal resource YAML.")
+	for _, resource := range resources {
+		t := kubeResource{}
+		err = yaml.Unmarshal(resource, &t)
 
-						return err
-					}
+		if err != nil {
+			// Ignore if it does not have apiVersion or kind fields in the YAML
+			klog.Infof("Invalid kube resources. err: %v ", err)
 
-					annotations := o.GetAnnotations()
-					if len(annotations) == 0 {
-						annotations = map[string]string{}
-					}
+			continue
+		}
 
-					annotations[appv1.AnnotationUserIdentity] = ghsi.userID
-					annotations[appv1.AnnotationUserGroup] = ghsi.userGroup
-					o.SetAnnotations(annotations)
+		klog.V(1).Info("Applying Kubernetes resource of kind ", t.Kind)
 
-					resource, err = yaml.Marshal(o)
-					if err != nil {
-						klog.Error(err)
+		if t.Kind == "Subscription" {
+			klog.V(1).Infof("Injecting userID(%s), Group(%s) to subscription", ghsi.userID, ghsi.userGroup)
 
-						continue
-					}
-				}
+			o := &unstructured.Unstructured{}
+			if err := yaml.Unmarshal(resource, o); err != nil {
+				klog.Error("Failed to unmarshal resource YAML.")
+
+				errs = append(errs, "Subscription "+t.Name+": "+err.Error())
 
-				ghsi.subscribeResourceFile(resource)
+				continue
+			}
+
+			annotations := o.GetAnnotations()
+			if len(annotations) == 0 {
+				annotations = map[string]string{}
 			}
+
+			annotations[appv1.AnnotationUserIdentity] = ghsi.userID
+			annotations[appv1.AnnotationUserGroup] = ghsi.userGroup
+			o.SetAnnotations(annotations)
+
+			resource, err = yaml.Marshal(o)
+			if err != nil {
+				klog.Error(err)
+
+				errs = append(errs, "Subscription "+t.Name+": "+err.Error())
+
+				continue
+			}
+		}
+
+		if t.GroupVersionKind().GroupKind() == utils.OpenShiftTemplateGroupKind {
+			if err := ghsi.subscribeOpenShiftTemplate(resource); err != nil {
+				errs = append(errs, t.Kind+" "+t.Name+": "+err.Error())
+			}
+
+			continue
 		}
+
+		if err := ghsi.subscribeResourceFile(resource); err != nil {
+			errs = append(errs, t.Kind+" "+t.Name+": "+err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
 	}
 
 	return nil
 }
 
-func (ghsi *SubscriberItem) subscribeResourceFile(file []byte) {
+// decryptSopsResources decrypts the resources of a file encrypted by SOPS together, with the keys of the decryption
+// secret of the subscription, since its message authentication code covers all of them. The keys are read once per
+// sync. If they can't be decrypted, only the resources that are not encrypted are returned.
+func (ghsi *SubscriberItem) decryptSopsResources(resources [][]byte) ([][]byte, error) {
+	plain := [][]byte{}
+	encrypted := [][]byte{}
+
+	for _, resource := range resources {
+		if utils.IsSopsEncrypted(resource) {
+			encrypted = append(encrypted, resource)
+		} else {
+			plain = append(plain, resource)
+		}
+	}
+
+	if len(encrypted) == 0 {
+		return resources, nil
+	}
+
+	if ghsi.sopsKeys == nil {
+		keys, err := utils.GetSopsDecryptionKeys(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription)
+		if err != nil {
+			return plain, err
+		}
+
+		ghsi.sopsKeys = keys
+	}
+
+	decrypted, err := utils.DecryptSopsDocuments(encrypted, ghsi.sopsKeys)
+	if err != nil {
+		return plain, err
+	}
+
+	// keep the order of the resources in the file
+	result := make([][]byte, 0, len(resources))
+
+	for _, resource := range resources {
+		if utils.IsSopsEncrypted(resource) {
+			resource, decrypted = decrypted[0], decrypted[1:]
+		}
+
+		result = append(result, resource)
+	}
+
+	return result, nil
+}
+
+// subscribeOpenShiftTemplate subscribes the objects of the OpenShift template, processed with the parameter values of
+// the template-parameters ConfigMap and of the packageOverrides of the template
+func (ghsi *SubscriberItem) subscribeOpenShiftTemplate(file []byte) error {
+	tpl := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal(file, &tpl); err != nil {
+		return err
+	}
+
+	values, err := ghsi.getTemplateParameters()
+	if err != nil {
+		return err
+	}
+
+	overrides, err := utils.GetTemplateParameterOverrides(tpl.GetName(), ghsi.Subscription)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range overrides {
+		values[k] = v
+	}
+
+	objects, err := utils.ProcessOpenShiftTemplate(tpl, values)
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("Processed OpenShift template %v into %d objects", tpl.GetName(), len(objects))
+
+	errs := []string{}
+
+	for _, obj := range objects {
+		resource, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+
+		if err := ghsi.subscribeResourceFile(resource); err != nil {
+			errs = append(errs, obj.GetKind()+" "+obj.GetName()+": "+err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// getTemplateParameters returns the data of the ConfigMap named by the template-parameters annotation. The ConfigMap
+// is looked up in the subscription namespace on the managed cluster, then on the hub.
+func (ghsi *SubscriberItem) getTemplateParameters() (map[string]string, error) {
+	values := map[string]string{}
+
+	cmName := ghsi.Subscription.GetAnnotations()[appv1.AnnotationTemplateParameters]
+	if cmName == "" {
+		return values, nil
+	}
+
+	cmKey := types.NamespacedName{Name: cmName, Namespace: ghsi.Subscription.Namespace}
+	cm := &corev1.ConfigMap{}
+
+	if err := ghsi.synchronizer.GetLocalClient().Get(context.TODO(), cmKey, cm); err != nil {
+		if err := ghsi.synchronizer.GetRemoteClient().Get(context.TODO(), cmKey, cm); err != nil {
+			return nil, fmt.Errorf("failed to get the template parameters ConfigMap %v: %w", cmKey, err)
+		}
+	}
+
+	for k, v := range cm.Data {
+		values[k] = v
+	}
+
+	return values, nil
+}
+
+func (ghsi *SubscriberItem) subscribeResourceFile(file []byte) error {
 	resourceToSync, validgvk, err := ghsi.subscribeResource(file)
 	if err != nil {
 		klog.Error(err)
@@ -534,13 +1038,22 @@ func (ghsi *SubscriberItem) subscribeResourceFile(file []byte) {
 	if resourceToSync == nil || validgvk == nil {
 		klog.Info("Skipping resource")
 
-		return
+		return err
 	}
 
 	ghsi.resources = append(ghsi.resources, kubesynchronizer.ResourceUnit{Resource: resourceToSync, Gvk: *validgvk})
+
+	return err
 }
 
 func (ghsi *SubscriberItem) subscribeResource(file []byte) (*unstructured.Unstructured, *schema.GroupVersionKind, error) {
+	return ghsi.prepareResource(file, true)
+}
+
+// prepareResource sets the namespace, the annotations and the labels of the subscription on the resource. The package
+// filter, the package filter plugins and the package overrides of the subscription are applied by resource name if
+// byPackage is true, they are applied to the chart instead of its rendered resources.
+func (ghsi *SubscriberItem) prepareResource(file []byte, byPackage bool) (*unstructured.Unstructured, *schema.GroupVersionKind, error) {
 	rsc := &unstructured.Unstructured{}
 	err := yaml.Unmarshal(file, &rsc)
 
@@ -551,11 +1064,17 @@ func (ghsi *SubscriberItem) subscribeResource(file []byte) (*unstructured.Unstru
 	validgvk := rsc.GetObjectKind().GroupVersionKind()
 
 	if ghsi.synchronizer.IsResourceNamespaced(rsc) {
+		if err := utils.ContainResourceNamespace(rsc, ghsi.Subscription.Namespace, ghsi.namespaceContainment); err != nil {
+			klog.Info(err.Error())
+
+			return nil, nil, err
+		}
+
 		if ghsi.clusterAdmin {
 			klog.Info("cluster-admin is true.")
 
 			if rsc.GetNamespace() != "" {
-				if ghsi.currentNamespaceScoped {
+				if ghsi.currentNamespaceScoped && !ghsi.preservesNamespace(rsc) {
 					// If current-namespace-scoped annotation is true, deploy resources into subscription's namespace
 					klog.Info("Setting it to subscription namespace " + ghsi.Subscription.Namespace)
 					rsc.SetNamespace(ghsi.Subscription.Namespace)
@@ -579,13 +1098,15 @@ func (ghsi *SubscriberItem) subscribeResource(file []byte) (*unstructured.Unstru
 				rscAnnotations[appv1.AnnotationClusterAdmin] = "true"
 				rsc.SetAnnotations(rscAnnotations)
 			}
+		} else if ghsi.preservesNamespace(rsc) {
+			klog.Info("No cluster-admin. Preserving the resource namespace " + rsc.GetNamespace())
 		} else {
 			klog.Info("No cluster-admin. Setting it to subscription namespace " + ghsi.Subscription.Namespace)
 			rsc.SetNamespace(ghsi.Subscription.Namespace)
 		}
 	}
 
-	if ghsi.Subscription.Spec.PackageFilter != nil {
+	if byPackage && ghsi.Subscription.Spec.PackageFilter != nil {
 		errMsg := ghsi.checkFilters(rsc)
 		if errMsg != "" {
 			klog.Infof("failed to check package filter, err: %v", errMsg)
@@ -594,7 +1115,23 @@ func (ghsi *SubscriberItem) subscribeResource(file []byte) (*unstructured.Unstru
 		}
 	}
 
-	if ghsi.Subscription.Spec.PackageOverrides != nil {
+	var pluginCandidate *utils.PackageCandidate
+
+	if byPackage && utils.HasPackageFilterPlugins() {
+		// the candidate is the resource as it is in the repo, it is checked after all the resources are prepared
+		candidate := utils.NewResourcePackageCandidate(rsc)
+		pluginCandidate = &candidate
+	}
+
+	// a resource outside of the allow and deny lists is rejected before it is registered with the synchronizer
+	if err := utils.CheckResourceAllowDeny(*rsc, ghsi.allowedGroupResources, ghsi.deniedGroupResources,
+		ghsi.clusterAdmin); err != nil {
+		klog.Info(err.Error())
+
+		return nil, nil, err
+	}
+
+	if byPackage && ghsi.Subscription.Spec.PackageOverrides != nil {
 		rsc, err = utils.OverrideResourceBySubscription(rsc, rsc.GetName(), ghsi.Subscription)
 		if err != nil {
 			errmsg := "Failed override package " + rsc.GetName() + " with error: " + err.Error()
@@ -637,20 +1174,78 @@ func (ghsi *SubscriberItem) subscribeResource(file []byte) (*unstructured.Unstru
 	// Set app label
 	utils.SetPartOfLabel(ghsi.SubscriberItem.Subscription, rsc)
 
+	if pluginCandidate != nil && ghsi.pluginCandidates != nil {
+		ghsi.pluginCandidates[rsc] = *pluginCandidate
+	}
+
 	return rsc, &validgvk, nil
 }
 
+// filterResourcesOnPlugins removes the resources excluded by the package filter plugins from the resources to apply.
+// The resources prepared by package are sent to each plugin in one request.
+func (ghsi *SubscriberItem) filterResourcesOnPlugins() error {
+	if len(ghsi.pluginCandidates) == 0 {
+		return nil
+	}
+
+	candidates := []utils.PackageCandidate{}
+	// the index in ghsi.resources of each candidate
+	indexes := []int{}
+
+	for i, resource := range ghsi.resources {
+		if candidate, ok := ghsi.pluginCandidates[resource.Resource]; ok {
+			candidates = append(candidates, candidate)
+			indexes = append(indexes, i)
+		}
+	}
+
+	reasons, err := utils.CheckPackageFilterPlugins(ghsi.Subscription, candidates)
+	if err != nil {
+		return err
+	}
+
+	excluded := map[int]bool{}
+
+	for j, reason := range reasons {
+		if reason != "" {
+			klog.Infof("Skipping resource %v, %v", ghsi.resources[indexes[j]].Resource.GetName(), reason)
+
+			excluded[indexes[j]] = true
+		}
+	}
+
+	resources := make([]kubesynchronizer.ResourceUnit, 0, len(ghsi.resources)-len(excluded))
+
+	for i, resource := range ghsi.resources {
+		if !excluded[i] {
+			resources = append(resources, resource)
+		}
+	}
+
+	ghsi.resources = resources
+
+	return nil
+}
+
+// preservesNamespace returns true if the resource keeps its namespace by the preserve-namespace annotation, and the
+// user of the subscription can deploy it there
+func (ghsi *SubscriberItem) preservesNamespace(rsc *unstructured.Unstructured) bool {
+	return utils.PreservesResourceNamespace(ghsi.Subscription, rsc) &&
+		ghsi.synchronizer.CanDeployResource(ghsi.Subscription, rsc)
+}
+
+// canDeployHelmRelease returns true if the user of the subscription can deploy the HelmRelease in its namespace
+func (ghsi *SubscriberItem) canDeployHelmRelease(hr *unstructured.Unstructured) bool {
+	return ghsi.synchronizer.CanDeployResource(ghsi.Subscription, hr)
+}
+
 func (ghsi *SubscriberItem) checkFilters(rsc *unstructured.Unstructured) (errMsg string) {
-	if ghsi.Subscription.Spec.Package != "" && ghsi.Subscription.Spec.Package != rsc.GetName() {
+	if !utils.IsSubscribedPackage(ghsi.Subscription, rsc.GetName()) {
 		errMsg = "Name does not match, skiping:" + ghsi.Subscription.Spec.Package + "|" + rsc.GetName()
 
 		return errMsg
 	}
 
-	if ghsi.Subscription.Spec.Package == rsc.GetName() {
-		klog.V(4).Info("Name does matches: " + ghsi.Subscription.Spec.Package + "|" + rsc.GetName())
-	}
-
 	if ghsi.Subscription.Spec.PackageFilter != nil {
 		if utils.LabelChecker(ghsi.Subscription.Spec.PackageFilter.LabelSelector, rsc.GetLabels()) {
 			klog.V(4).Info("Passed label check on resource " + rsc.GetName())
@@ -693,11 +1288,14 @@ func (ghsi *SubscriberItem) checkFilters(rsc *unstructured.Unstructured) (errMsg
 }
 
 func (ghsi *SubscriberItem) subscribeHelmCharts(indexFile *repo.IndexFile) (err error) {
-	for packageName, chartVersions := range indexFile.Entries {
+	for _, packageName := range utils.SortedChartNames(indexFile) {
+		chartVersions := indexFile.Entries[packageName]
+
 		klog.V(1).Infof("chart: %s\n%v", packageName, chartVersions)
 
 		helmReleaseCR, err := utils.CreateHelmCRManifest(
-			"", packageName, chartVersions, ghsi.synchronizer.GetLocalClient(), ghsi.Channel, ghsi.SecondaryChannel, ghsi.Subscription, ghsi.clusterAdmin)
+			"", packageName, chartVersions, ghsi.synchronizer.GetLocalClient(), ghsi.Channel, ghsi.SecondaryChannel, ghsi.Subscription, ghsi.clusterAdmin,
+			ghsi.canDeployHelmRelease)
 
 		if err != nil {
 			klog.Error("Failed to create a helmrelease CR manifest, err: ", err)
@@ -705,13 +1303,119 @@ func (ghsi *SubscriberItem) subscribeHelmCharts(indexFile *repo.IndexFile) (err
 			return err
 		}
 
+		if err := ghsi.mergeChartValuesFile(chartVersions, helmReleaseCR); err != nil {
+			klog.Error("Failed to merge the values file of helm chart ", packageName, ", err: ", err)
+
+			return err
+		}
+
+		if utils.IsHelmRenderLocal(ghsi.Subscription) {
+			if err := ghsi.subscribeRenderedHelmChart(chartVersions, helmReleaseCR); err != nil {
+				klog.Error("Failed to render helm chart ", packageName, ", err: ", err)
+
+				return err
+			}
+
+			continue
+		}
+
 		ghsi.resources = append(ghsi.resources, kubesynchronizer.ResourceUnit{Resource: helmReleaseCR, Gvk: helmGvk})
 	}
 
 	return err
 }
 
-func (ghsi *SubscriberItem) cloneGitRepo() (commitID string, err error) {
+// getChartDir returns the directory of the chart in the cloned repo
+func (ghsi *SubscriberItem) getChartDir(chartVersions repo.ChartVersions) (string, error) {
+	if len(chartVersions) == 0 || len(chartVersions[0].URLs) == 0 {
+		return "", errors.New("no chart directory in the helm index")
+	}
+
+	// the chart URL of the index is the chart directory relative to the repo root
+	return filepath.Join(ghsi.repoRoot, chartVersions[0].URLs[0]), nil
+}
+
+// mergeChartValuesFile merges the values file of the chart directory under the values of its HelmRelease CR, so the
+// values of the packageOverrides win over the values of the repo
+func (ghsi *SubscriberItem) mergeChartValuesFile(chartVersions repo.ChartVersions, helmReleaseCR *unstructured.Unstructured) error {
+	chartDir, err := ghsi.getChartDir(chartVersions)
+	if err != nil {
+		return err
+	}
+
+	valuesFile, err := utils.GetChartValuesFile(ghsi.Subscription, ghsi.repoRoot, chartDir)
+	if err != nil {
+		return err
+	}
+
+	values, _, err := unstructured.NestedMap(helmReleaseCR.Object, "spec")
+	if err != nil {
+		return err
+	}
+
+	// the placeholder of an empty spec
+	delete(values, "")
+
+	values, err = utils.MergeChartValuesFile(valuesFile, values)
+	if err != nil {
+		return err
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	return unstructured.SetNestedMap(helmReleaseCR.Object, values, "spec")
+}
+
+// subscribeRenderedHelmChart templates the chart in the subscription controller with the values and the release name
+// of its HelmRelease CR, and subscribes the rendered resources in place of the HelmRelease CR
+func (ghsi *SubscriberItem) subscribeRenderedHelmChart(chartVersions repo.ChartVersions, helmReleaseCR *unstructured.Unstructured) error {
+	chartDir, err := ghsi.getChartDir(chartVersions)
+	if err != nil {
+		return err
+	}
+
+	values, _, err := unstructured.NestedMap(helmReleaseCR.Object, "spec")
+	if err != nil {
+		return err
+	}
+
+	// the placeholder of an empty spec
+	delete(values, "")
+
+	manifests, err := utils.RenderHelmChart(chartDir, helmReleaseCR.GetName(), ghsi.Subscription.Namespace, values)
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("Rendered helm chart %v into %d resources", chartVersions[0].Name, len(manifests))
+
+	errs := []string{}
+
+	for _, manifest := range manifests {
+		rsc, gvk, err := ghsi.prepareResource([]byte(manifest), false)
+		if err != nil {
+			errs = append(errs, err.Error())
+
+			continue
+		}
+
+		if rsc == nil || gvk == nil || gvk.Kind == "" {
+			continue
+		}
+
+		ghsi.resources = append(ghsi.resources, kubesynchronizer.ResourceUnit{Resource: rsc, Gvk: *gvk})
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+func (ghsi *SubscriberItem) cloneGitRepo(ctx context.Context) (source *utils.GitSource, err error) {
 	annotations := ghsi.Subscription.GetAnnotations()
 
 	cloneDepth := 1
@@ -729,22 +1433,24 @@ func (ghsi *SubscriberItem) cloneGitRepo() (commitID string, err error) {
 	ghsi.repoRoot = utils.GetLocalGitFolder(ghsi.Subscription)
 
 	cloneOptions := &utils.GitCloneOption{
-		CommitHash:  ghsi.desiredCommit,
-		RevisionTag: ghsi.desiredTag,
-		CloneDepth:  cloneDepth,
-		Branch:      utils.GetSubscriptionBranch(ghsi.Subscription),
-		DestDir:     ghsi.repoRoot,
+		CommitHash:            ghsi.desiredCommit,
+		RevisionTag:           ghsi.desiredTag,
+		RevisionTagConstraint: ghsi.desiredTagConstraint,
+		CloneDepth:            cloneDepth,
+		Branch:                utils.GetSubscriptionBranch(ghsi.Subscription),
+		DestDir:               ghsi.repoRoot,
 	}
 
 	// Get the primary channel connection options
 	primaryChannelConnectionConfig, err := getChannelConnectionConfig(ghsi.ChannelSecret, ghsi.ChannelConfigMap)
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	primaryChannelConnectionConfig.RepoURL = ghsi.Channel.Spec.Pathname
 	primaryChannelConnectionConfig.InsecureSkipVerify = ghsi.Channel.Spec.InsecureSkipVerify
+	primaryChannelConnectionConfig.Provider = utils.GetChannelGitProvider(ghsi.Channel)
 	cloneOptions.PrimaryConnectionOption = primaryChannelConnectionConfig
 
 	// Get the secondary channel connection options
@@ -753,22 +1459,186 @@ func (ghsi *SubscriberItem) cloneGitRepo() (commitID string, err error) {
 		secondaryChannelConnectionConfig, err := getChannelConnectionConfig(ghsi.SecondaryChannelSecret, ghsi.SecondaryChannelConfigMap)
 
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		secondaryChannelConnectionConfig.RepoURL = ghsi.SecondaryChannel.Spec.Pathname
 		secondaryChannelConnectionConfig.InsecureSkipVerify = ghsi.SecondaryChannel.Spec.InsecureSkipVerify
+		secondaryChannelConnectionConfig.Provider = utils.GetChannelGitProvider(ghsi.SecondaryChannel)
 		cloneOptions.SecondaryConnectionOption = secondaryChannelConnectionConfig
 	}
 
-	return utils.CloneGitRepo(cloneOptions)
+	ghsi.commitAuthor = nil
+
+	// the cache serves the files of the commit without its git objects, the signatures are verified on a clone
+	if channelcache.IsClientEnabled() && !utils.HasGitSigningKeys(ghsi.Subscription) {
+		source, ghsi.commitAuthor, err = ghsi.fetchFromChannelCache(ctx, cloneOptions)
+		if err == nil {
+			return source, nil
+		}
+
+		klog.Warningf("failed to fetch appsub %v/%v from the hub channel cache, cloning the git repo directly. err: %v",
+			ghsi.Subscription.Namespace, ghsi.Subscription.Name, err)
+	}
+
+	return utils.CloneGitRepoSource(ctx, cloneOptions)
+}
+
+// fetchFromChannelCache fetches the content of the channel from the hub channel cache, or else of the secondary channel
+func (ghsi *SubscriberItem) fetchFromChannelCache(ctx context.Context,
+	cloneOptions *utils.GitCloneOption) (*utils.GitSource, *utils.GitCommitAuthor, error) {
+	chnKey := types.NamespacedName{Namespace: ghsi.Channel.Namespace, Name: ghsi.Channel.Name}
+
+	source, author, err := channelcache.FetchGitRepo(ctx, chnKey, cloneOptions)
+	if err == nil || ghsi.SecondaryChannel == nil {
+		return source, author, err
+	}
+
+	klog.Warningf("failed to fetch channel %v from the hub channel cache, trying the secondary channel. err: %v", chnKey, err)
+
+	chnKey = types.NamespacedName{Namespace: ghsi.SecondaryChannel.Namespace, Name: ghsi.SecondaryChannel.Name}
+
+	source, author, err = channelcache.FetchGitRepo(ctx, chnKey, cloneOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source.Secondary = true
+
+	return source, author, nil
+}
+
+// getSubscriptionSource returns the appsub status source of the content of the git repo clone
+func (ghsi *SubscriberItem) getSubscriptionSource(source *utils.GitSource) *appv1.SubscriptionSource {
+	chn := ghsi.Channel
+
+	if source.Secondary && ghsi.SecondaryChannel != nil {
+		chn = ghsi.SecondaryChannel
+	}
+
+	sourceURL := source.URL
+	if sourceURL == "" {
+		sourceURL = utils.RedactGitURL(chn.Spec.Pathname)
+	}
+
+	return &appv1.SubscriptionSource{
+		Channel:      chn.Namespace + "/" + chn.Name,
+		URL:          sourceURL,
+		Ref:          source.Ref,
+		Commit:       source.Commit,
+		Submodules:   source.Submodules,
+		ChannelCache: source.ChannelCache,
+	}
+}
+
+// isBranchUnchanged checks with the Git provider API, without cloning the repo, that the head of the subscribed branch
+// is still the deployed commit, when the medium reconcile rate would skip reconciling the unchanged commit anyway
+func (ghsi *SubscriberItem) isBranchUnchanged() bool {
+	if !strings.EqualFold(ghsi.reconcileRate, "medium") || ghsi.commitID == "" || ghsi.count+1 >= 6 ||
+		!ghsi.successful || ghsi.resyncPending ||
+		ghsi.desiredCommit != "" || ghsi.desiredTag != "" || ghsi.desiredTagConstraint != "" {
+		return false
+	}
+
+	provider := utils.GetChannelGitProvider(ghsi.Channel)
+	if provider == utils.GitProviderGeneric {
+		return false
+	}
+
+	connCfg, err := getChannelConnectionConfig(ghsi.ChannelSecret, ghsi.ChannelConfigMap)
+	if err != nil {
+		return false
+	}
+
+	connCfg.RepoURL = ghsi.Channel.Spec.Pathname
+	connCfg.InsecureSkipVerify = ghsi.Channel.Spec.InsecureSkipVerify
+	connCfg.Provider = provider
+
+	commitID, err := utils.GetRemoteBranchCommit(connCfg, string(utils.GetSubscriptionBranch(ghsi.Subscription)))
+	if err != nil {
+		klog.Infof("failed to get the head commit from the %v API, cloning the git repo. err: %v", provider, err)
+
+		return false
+	}
+
+	if commitID != ghsi.commitID {
+		return false
+	}
+
+	ghsi.count++
+
+	return true
+}
+
+// checkCommitAuthor returns an error if the author of the commit is not in the allowed authors of the subscription
+// or channel, and reports the policy violation in the subscription status.
+func (ghsi *SubscriberItem) checkCommitAuthor(commitID string) error {
+	allowed := utils.GetAllowedGitAuthors(ghsi.Subscription, ghsi.Channel)
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	author := ghsi.commitAuthor
+	if author == nil {
+		var err error
+
+		author, err = utils.GetGitCommitAuthor(ghsi.repoRoot, commitID)
+		if err != nil {
+			klog.Error(err, " failed to get the author of git commit ", commitID)
+		}
+	}
+
+	if !utils.IsGitAuthorAllowed(author, allowed) {
+		authorStr := "unknown author"
+		if author != nil {
+			authorStr = author.String()
+		}
+
+		violation := fmt.Sprintf("commit %s by %s is not from an allowed author", commitID, authorStr)
+
+		utils.UpdatePolicyViolationStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, violation)
+
+		return errors.New(violation)
+	}
+
+	utils.UpdatePolicyViolationStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, "")
+
+	return nil
+}
+
+// checkCommitSignature returns an error if the subscription has signing keys and the commit, or the tag it was read
+// from, is not signed by one of them, and reports the verification failure in the subscription status.
+func (ghsi *SubscriberItem) checkCommitSignature(source *utils.GitSource) error {
+	keys, err := utils.GetGitSigningKeys(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription)
+	if err == nil && len(keys) == 0 {
+		return nil
+	}
+
+	if err == nil {
+		var signer string
+
+		signer, err = utils.VerifyGitSignature(ghsi.repoRoot, source.Commit, source.Ref, keys)
+		if err == nil {
+			klog.Infof("Git commit %v is signed by %v", source.Commit, signer)
+		}
+	}
+
+	if err != nil {
+		utils.UpdateSignatureVerificationStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, err.Error())
+
+		return err
+	}
+
+	utils.UpdateSignatureVerificationStatus(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, "")
+
+	return nil
 }
 
 func getChannelConnectionConfig(secret *corev1.Secret, configmap *corev1.ConfigMap) (connCfg *utils.ChannelConnectionCfg, err error) {
 	connCfg = &utils.ChannelConnectionCfg{}
 
 	if secret != nil {
-		user, token, sshKey, passphrase, clientkey, clientcert, err := utils.ParseChannelSecret(secret)
+		user, token, sshKey, passphrase, clientkey, clientcert, err := utils.ParseChannelSecret(secret, configmap)
 
 		if err != nil {
 			return nil, err
@@ -782,11 +1652,8 @@ func getChannelConnectionConfig(secret *corev1.Secret, configmap *corev1.ConfigM
 		connCfg.ClientCert = clientcert
 	}
 
-	if configmap != nil {
-		caCert := configmap.Data[appv1.ChannelCertificateData]
-
-		connCfg.CaCerts = caCert
-	}
+	connCfg.CaCerts = utils.ChannelCACerts(secret, configmap)
+	connCfg.Proxy = utils.GetChannelProxyConfig(secret, configmap)
 
 	return connCfg, nil
 }
@@ -805,23 +1672,42 @@ func (ghsi *SubscriberItem) sortClonedGitRepo() error {
 		}
 	}
 
-	resourcePath := ghsi.repoRoot
-
-	annotations := ghsi.Subscription.GetAnnotations()
+	resourcePaths, err := utils.GetGitResourcePaths(ghsi.Subscription, ghsi.repoRoot, ghsi.SubscriberItem.SubscriptionConfigMap)
+	if err != nil {
+		klog.Error(err, "Failed to get the resource paths.")
 
-	if annotations[appv1.AnnotationGithubPath] != "" {
-		resourcePath = filepath.Join(ghsi.repoRoot, annotations[appv1.AnnotationGithubPath])
-	} else if annotations[appv1.AnnotationGitPath] != "" {
-		resourcePath = filepath.Join(ghsi.repoRoot, annotations[appv1.AnnotationGitPath])
-	} else if ghsi.SubscriberItem.SubscriptionConfigMap != nil {
-		resourcePath = filepath.Join(ghsi.repoRoot, ghsi.SubscriberItem.SubscriptionConfigMap.Data["path"])
+		return err
 	}
 
 	// chartDirs contains helm chart directories
 	// crdsAndNamespaceFiles contains CustomResourceDefinition and Namespace Kubernetes resources file paths
 	// rbacFiles contains ServiceAccount, ClusterRole and Role Kubernetes resource file paths
 	// otherFiles contains all other Kubernetes resource file paths
-	chartDirs, kustomizeDirs, crdsAndNamespaceFiles, rbacFiles, otherFiles, err := utils.SortResources(ghsi.repoRoot, resourcePath, utils.SkipHooksOnManaged)
+	include, exclude := utils.GetGitPathPatterns(ghsi.Subscription, ghsi.SubscriberItem.SubscriptionConfigMap)
+	skip := utils.ChainSkipFuncs(utils.SkipHooksOnManaged, utils.NewPathFilterSkipFunc(ghsi.repoRoot, include, exclude))
+
+	// the subscribed paths not matching the structure expected by the filterRef fail the sync, instead of deploying
+	// nothing or something else
+	expectations, err := utils.GetGitPathExpectations(ghsi.Subscription, ghsi.SubscriberItem.SubscriptionConfigMap)
+	if err == nil {
+		err = utils.VerifyGitPathStructure(ghsi.repoRoot, resourcePaths, expectations, skip)
+	}
+
+	if err != nil {
+		klog.Error(err)
+
+		utils.UpdateDegradedCondition(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, true,
+			utils.ReasonUnexpectedRepoStructure, err.Error())
+
+		return err
+	}
+
+	if len(expectations) > 0 {
+		utils.UpdateDegradedCondition(ghsi.synchronizer.GetLocalClient(), ghsi.Subscription, false,
+			utils.ReasonExpectedRepoStructure, "the subscribed paths have the expected structure")
+	}
+
+	chartDirs, kustomizeDirs, crdsAndNamespaceFiles, rbacFiles, otherFiles, err := utils.SortResourcePaths(ghsi.repoRoot, resourcePaths, skip)
 	if err != nil {
 		klog.Error(err, "Failed to sort kubernetes resources and helm charts.")
 
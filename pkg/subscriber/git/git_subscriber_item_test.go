@@ -0,0 +1,56 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestStopSubscriberItem(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	// an item never started is stopped without error
+	ghsi := newWebhookItem("https://github.com/org/repo.git", nil)
+	ghsi.Stop()
+
+	// the paused subscription keeps the reconcile goroutine idle
+	ghsi.Subscription.SetLabels(map[string]string{appv1.LabelSubscriptionPause: "true"})
+	ghsi.reconcileRate = "high"
+
+	ghsi.Start(false)
+
+	ctx := ghsi.ctx
+	done := ghsi.done
+
+	g.Expect(ctx.Err()).NotTo(gomega.HaveOccurred())
+	g.Expect(done).NotTo(gomega.BeClosed())
+
+	g.Expect(ghsi.Stop()).To(gomega.BeTrue())
+
+	g.Expect(ctx.Err()).To(gomega.HaveOccurred())
+	g.Expect(ghsi.ctx).To(gomega.BeNil())
+	g.Expect(done).To(gomega.BeClosed())
+	g.Expect(ghsi.stopch).To(gomega.BeNil())
+
+	// the item can be started again
+	ghsi.Start(false)
+	g.Expect(ghsi.ctx.Err()).NotTo(gomega.HaveOccurred())
+
+	ghsi.Stop()
+}
@@ -97,7 +97,7 @@ metadata:
 type: Opaque
 data:
   id: YWRtaW4=
-  accessToken: MWYyZDFlMmU2N2Rm`
+  token: MWYyZDFlMmU2N2Rm`
 
 var (
 	sharedkey = types.NamespacedName{
@@ -254,7 +254,7 @@ var _ = Describe("test subscribing to bitbucket repository", func() {
 		subitem.Subscription = bitbucketsub
 		subitem.Channel = bitbucketchn
 		subitem.synchronizer = defaultSubscriber.synchronizer
-		commitid, err := subitem.cloneGitRepo()
+		commitid, err := subitem.cloneGitRepo(context.TODO())
 		Expect(commitid).ToNot(Equal(""))
 		Expect(err).NotTo(HaveOccurred())
 
@@ -277,7 +277,7 @@ var _ = Describe("test subscribing to bitbucket repository", func() {
 		bitbucketchn.Spec.InsecureSkipVerify = true
 		subitem.Channel = bitbucketchn
 		subitem.synchronizer = defaultSubscriber.synchronizer
-		commitid, err := subitem.cloneGitRepo()
+		commitid, err := subitem.cloneGitRepo(context.TODO())
 		Expect(commitid).ToNot(Equal(""))
 		Expect(err).NotTo(HaveOccurred())
 
@@ -315,7 +315,7 @@ var _ = Describe("test subscribe invalid resource", func() {
 		subitem.Subscription = githubsub
 		subitem.Channel = githubchn
 		subitem.synchronizer = defaultSubscriber.synchronizer
-		commitid, err := subitem.cloneGitRepo()
+		commitid, err := subitem.cloneGitRepo(context.TODO())
 		Expect(commitid).ToNot(Equal(""))
 		Expect(err).NotTo(HaveOccurred())
 
@@ -335,16 +335,16 @@ var _ = Describe("test subscribe invalid resource", func() {
 		Expect(err).NotTo(HaveOccurred())
 
 		subitem.SubscriberItem.ChannelSecret = chnIncorrectSecret
-		_, err = subitem.cloneGitRepo()
-		Expect(err.Error()).To(Equal("ssh_key (and optionally passphrase) or user and accressToken need to be specified in the channel secret"))
+		_, err = subitem.cloneGitRepo(context.TODO())
+		Expect(err.Error()).To(Equal("ssh_key (and optionally passphrase) or accessToken (and optionally user) need to be specified in the channel secret"))
 
 		chnIncorrectSecret2 := &corev1.Secret{}
 		err = yaml.Unmarshal([]byte(incorrectSecret2), &chnIncorrectSecret2)
 		Expect(err).NotTo(HaveOccurred())
 		subitem.SubscriberItem.ChannelSecret = chnIncorrectSecret2
 
-		_, err = subitem.cloneGitRepo()
-		Expect(err.Error()).To(Equal("ssh_key (and optionally passphrase) or user and accressToken need to be specified in the channel secret"))
+		_, err = subitem.cloneGitRepo(context.TODO())
+		Expect(err.Error()).To(Equal("ssh_key (and optionally passphrase) or accessToken (and optionally user) need to be specified in the channel secret"))
 
 		err = k8sClient.Delete(context.TODO(), chnSecret)
 		Expect(err).NotTo(HaveOccurred())
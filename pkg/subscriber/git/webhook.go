@@ -0,0 +1,360 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v42/github"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+const (
+	webhookPath = "/webhook"
+	// maxWebhookPayload is the largest push event payload read, the GitHub payloads are capped at 25MB
+	maxWebhookPayload = 25 << 20
+
+	githubEventHeader     = "X-Github-Event"
+	githubSignatureHeader = "X-Hub-Signature-256"
+	gitlabEventHeader     = "X-Gitlab-Event"
+	gitlabTokenHeader     = "X-Gitlab-Token"
+	gitlabPushEvent       = "Push Hook"
+	gitlabTagPushEvent    = "Tag Push Hook"
+
+	tagRefPrefix = "refs/tags/"
+)
+
+// pushEvent is a push to a Git repository, from the webhook of the Git provider
+type pushEvent struct {
+	// repoURLs are the URLs of the pushed repository, the channels match any of them
+	repoURLs []string
+	// ref is the pushed branch or tag reference, e.g. refs/heads/main
+	ref string
+	// defaultBranch is the default branch of the repository, if sent by the provider
+	defaultBranch string
+	// validate returns true if the event is signed with the webhook secret of the channel
+	validate func(secret string) bool
+}
+
+type gitlabPushPayload struct {
+	Ref     string `json:"ref"`
+	Project struct {
+		WebURL        string `json:"web_url"`
+		GitHTTPURL    string `json:"git_http_url"`
+		GitSSHURL     string `json:"git_ssh_url"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"project"`
+}
+
+// WebhookServer receives the push webhooks of GitHub and GitLab and syncs the subscriptions of the pushed branch
+// immediately, instead of waiting for the next reconcile of the subscriptions
+type WebhookServer struct {
+	subscriber *Subscriber
+	address    string
+	tlsKeyFile string
+	tlsCrtFile string
+	disableTLS bool
+}
+
+// AddWebhookServer creates the Git push webhook server of the default git subscriber and adds it to the manager, the
+// server listens on address.
+func AddWebhookServer(mgr manager.Manager, address, tlsKeyFile, tlsCrtFile string, disableTLS bool) error {
+	if defaultSubscriber == nil {
+		return errors.New("the default git subscriber is not set up")
+	}
+
+	return mgr.Add(&WebhookServer{
+		subscriber: defaultSubscriber,
+		address:    address,
+		tlsKeyFile: tlsKeyFile,
+		tlsCrtFile: tlsCrtFile,
+		disableTLS: disableTLS,
+	})
+}
+
+// Start serves the Git push webhooks until the context is done, this will be triggered by the manager.
+func (s *WebhookServer) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.address,
+		Handler:           s,
+		ReadHeaderTimeout: 30 * time.Second,
+		BaseContext:       func(_ net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		if err := srv.Shutdown(context.TODO()); err != nil {
+			klog.Error("failed to shut down the Git webhook server, err: ", err)
+		}
+	}()
+
+	klog.Info("starting the Git webhook server on ", s.address)
+
+	var err error
+
+	if s.disableTLS {
+		err = srv.ListenAndServe()
+	} else {
+		err = utils.ListenAndServeTLS(srv, s.tlsCrtFile, s.tlsKeyFile)
+	}
+
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// NeedLeaderElection serves the webhooks on the leader only, the subscriber items run on the leader
+func (s *WebhookServer) NeedLeaderElection() bool {
+	return true
+}
+
+// ServeHTTP handles POST /webhook, the push events of GitHub and GitLab. The other events are acknowledged and ignored.
+func (s *WebhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	if r.URL.Path != webhookPath {
+		http.Error(w, "unknown path "+r.URL.Path, http.StatusNotFound)
+
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookPayload))
+	if err != nil {
+		http.Error(w, "failed to read the payload: "+err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	event, err := parsePushEvent(r, body)
+	if err != nil {
+		klog.Info("invalid Git webhook event, err: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if event == nil {
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	synced := s.subscriber.syncPushedItems(event)
+
+	klog.Infof("Git push of %v to %v syncs %d subscriptions", event.ref, event.repoURLs, synced)
+
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "%d subscriptions synced\n", synced)
+}
+
+// parsePushEvent returns the push event of the webhook request, nil for the other events
+func parsePushEvent(r *http.Request, body []byte) (*pushEvent, error) {
+	switch {
+	case r.Header.Get(githubEventHeader) != "":
+		if github.WebHookType(r) != "push" {
+			return nil, nil
+		}
+
+		payload := &github.PushEvent{}
+		if err := json.Unmarshal(body, payload); err != nil {
+			return nil, err
+		}
+
+		signature := r.Header.Get(githubSignatureHeader)
+		repo := payload.GetRepo()
+
+		return &pushEvent{
+			repoURLs:      []string{repo.GetCloneURL(), repo.GetHTMLURL(), repo.GetSSHURL(), repo.GetGitURL()},
+			ref:           payload.GetRef(),
+			defaultBranch: repo.GetDefaultBranch(),
+			validate: func(secret string) bool {
+				return github.ValidateSignature(signature, body, []byte(secret)) == nil
+			},
+		}, nil
+	case r.Header.Get(gitlabEventHeader) != "":
+		if event := r.Header.Get(gitlabEventHeader); event != gitlabPushEvent && event != gitlabTagPushEvent {
+			return nil, nil
+		}
+
+		payload := &gitlabPushPayload{}
+		if err := json.Unmarshal(body, payload); err != nil {
+			return nil, err
+		}
+
+		token := r.Header.Get(gitlabTokenHeader)
+
+		return &pushEvent{
+			repoURLs:      []string{payload.Project.GitHTTPURL, payload.Project.WebURL, payload.Project.GitSSHURL},
+			ref:           payload.Ref,
+			defaultBranch: payload.Project.DefaultBranch,
+			validate: func(secret string) bool {
+				return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+			},
+		}, nil
+	}
+
+	return nil, errors.New("unsupported webhook, only the GitHub and GitLab push events are supported")
+}
+
+// syncPushedItems triggers the sync of the subscriber items subscribed to the pushed branch or tags, and returns
+// their number
+func (ghs *Subscriber) syncPushedItems(event *pushEvent) int {
+	ghs.mu.RLock()
+	defer ghs.mu.RUnlock()
+
+	synced := 0
+
+	for key, ghsi := range ghs.itemmap {
+		if !ghsi.isPushed(event) {
+			continue
+		}
+
+		if !validatePushEvent(ghs.synchronizer.GetRemoteClient(), ghsi.Channel, event) {
+			klog.Infof("Git push event for subscription %v is not signed with the channel webhook secret, skipping", key)
+
+			continue
+		}
+
+		klog.Infof("Git push of %v syncs subscription %v", event.ref, key)
+
+		ghsi.triggerSync()
+
+		synced++
+	}
+
+	return synced
+}
+
+// validatePushEvent returns true if the event is signed with the webhook secret of the channel, which can't be empty.
+// The unsigned events are only accepted by the channels without a webhook secret that opt in with the
+// webhook-allow-unsigned annotation.
+func validatePushEvent(clt client.Client, chn *chnv1.Channel, event *pushEvent) bool {
+	secretName := chn.GetAnnotations()[appv1alpha1.AnnotationWebhookSecret]
+	if secretName == "" {
+		return strings.EqualFold(chn.GetAnnotations()[appv1alpha1.AnnotationWebhookAllowUnsigned], "true")
+	}
+
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Name: secretName, Namespace: chn.GetNamespace()}
+
+	if err := clt.Get(context.TODO(), key, secret); err != nil {
+		klog.Errorf("failed to get the webhook secret %v of channel %v/%v, err: %v", key, chn.GetNamespace(),
+			chn.GetName(), err)
+
+		return false
+	}
+
+	// an empty GitLab token or GitHub HMAC key would validate the events anyone can forge
+	webhookSecret := strings.TrimSpace(string(secret.Data["secret"]))
+	if webhookSecret == "" {
+		klog.Errorf("the webhook secret %v of channel %v/%v has no secret value", key, chn.GetNamespace(), chn.GetName())
+
+		return false
+	}
+
+	return event.validate(webhookSecret)
+}
+
+// isPushed returns true if the item subscribes to the pushed branch of a repository of its channels. The tag pushes
+// match the items subscribed to tags, the items pinned to a commit never match.
+func (ghsi *SubscriberItem) isPushed(event *pushEvent) bool {
+	if ghsi.Subscription == nil || ghsi.Channel == nil || !utils.IsGitChannel(string(ghsi.Channel.Spec.Type)) {
+		return false
+	}
+
+	repoMatched := false
+
+	for _, url := range event.repoURLs {
+		if url == "" {
+			continue
+		}
+
+		if sameRepoURL(ghsi.Channel.Spec.Pathname, url) ||
+			(ghsi.SecondaryChannel != nil && sameRepoURL(ghsi.SecondaryChannel.Spec.Pathname, url)) {
+			repoMatched = true
+
+			break
+		}
+	}
+
+	if !repoMatched {
+		return false
+	}
+
+	annotations := ghsi.Subscription.GetAnnotations()
+
+	if annotations[appv1alpha1.AnnotationGitTargetCommit] != "" {
+		return false
+	}
+
+	if strings.HasPrefix(event.ref, tagRefPrefix) {
+		return annotations[appv1alpha1.AnnotationGitTag] != "" || utils.GetSubscriptionGitTagConstraint(ghsi.Subscription) != ""
+	}
+
+	branch := utils.GetSubscriptionBranch(ghsi.Subscription)
+	if branch == "" {
+		// the default branch is subscribed, any branch matches if the provider does not tell the default one
+		return event.defaultBranch == "" || event.ref == string(utils.GetSubscriptionBranchRef(event.defaultBranch))
+	}
+
+	return event.ref == string(branch)
+}
+
+// sameRepoURL returns true if the URLs are the same repository, regardless of the protocol, the credentials and the
+// .git suffix
+func sameRepoURL(a, b string) bool {
+	return normalizeRepoURL(a) == normalizeRepoURL(b)
+}
+
+func normalizeRepoURL(url string) string {
+	url = strings.ToLower(strings.TrimSpace(url))
+
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	} else if strings.Contains(url, "@") {
+		// scp-like SSH URL, e.g. git@github.com:org/repo.git
+		url = strings.Replace(url, ":", "/", 1)
+	}
+
+	if i := strings.Index(url, "@"); i >= 0 {
+		url = url[i+1:]
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+}
@@ -0,0 +1,187 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	kubesynchronizer "open-cluster-management.io/multicloud-operators-subscription/pkg/synchronizer/kubernetes"
+)
+
+const githubPushPayload = `{
+  "ref": "refs/heads/main",
+  "repository": {
+    "clone_url": "https://github.com/org/repo.git",
+    "html_url": "https://github.com/org/repo",
+    "ssh_url": "git@github.com:org/repo.git",
+    "default_branch": "main"
+  }
+}`
+
+func newWebhookItem(pathname string, annotations map[string]string) *SubscriberItem {
+	return &SubscriberItem{
+		SubscriberItem: appv1.SubscriberItem{
+			Subscription: &appv1.Subscription{
+				ObjectMeta: metav1.ObjectMeta{Name: "sub", Namespace: "default", Annotations: annotations},
+			},
+			Channel: &chnv1.Channel{
+				ObjectMeta: metav1.ObjectMeta{Name: "chn", Namespace: "default", Annotations: map[string]string{
+					appv1.AnnotationWebhookAllowUnsigned: "true",
+				}},
+				Spec: chnv1.ChannelSpec{Type: chnv1.ChannelTypeGit, Pathname: pathname},
+			},
+		},
+		syncch: make(chan struct{}, 1),
+	}
+}
+
+func TestSameRepoURL(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(sameRepoURL("https://github.com/org/repo", "https://github.com/org/repo.git")).To(gomega.BeTrue())
+	g.Expect(sameRepoURL("https://user@GitHub.com/org/repo/", "git@github.com:org/repo.git")).To(gomega.BeTrue())
+	g.Expect(sameRepoURL("https://github.com/org/repo", "https://github.com/org/repo2")).To(gomega.BeFalse())
+}
+
+func TestParsePushEvent(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	req := httptest.NewRequest(http.MethodPost, webhookPath, nil)
+	req.Header.Set(githubEventHeader, "push")
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(githubPushPayload))
+	req.Header.Set(githubSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	event, err := parsePushEvent(req, []byte(githubPushPayload))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(event.ref).To(gomega.Equal("refs/heads/main"))
+	g.Expect(event.defaultBranch).To(gomega.Equal("main"))
+	g.Expect(event.validate("secret")).To(gomega.BeTrue())
+	g.Expect(event.validate("other")).To(gomega.BeFalse())
+
+	req.Header.Set(githubEventHeader, "ping")
+
+	event, err = parsePushEvent(req, []byte(`{}`))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(event).To(gomega.BeNil())
+
+	req = httptest.NewRequest(http.MethodPost, webhookPath, nil)
+	req.Header.Set(gitlabEventHeader, gitlabTagPushEvent)
+	req.Header.Set(gitlabTokenHeader, "secret")
+
+	event, err = parsePushEvent(req, []byte(`{"ref": "refs/tags/v1.0.0", "project": {"git_http_url": "https://gitlab.com/group/repo.git"}}`))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(event.ref).To(gomega.Equal("refs/tags/v1.0.0"))
+	g.Expect(event.repoURLs).To(gomega.ContainElement("https://gitlab.com/group/repo.git"))
+	g.Expect(event.validate("secret")).To(gomega.BeTrue())
+
+	_, err = parsePushEvent(httptest.NewRequest(http.MethodPost, webhookPath, nil), []byte(`{}`))
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestSyncPushedItems(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defaultBranch := newWebhookItem("https://github.com/org/repo", nil)
+	otherBranch := newWebhookItem("https://github.com/org/repo.git", map[string]string{appv1.AnnotationGitBranch: "dev"})
+	pinned := newWebhookItem("https://github.com/org/repo.git", map[string]string{appv1.AnnotationGitTargetCommit: "1111"})
+	otherRepo := newWebhookItem("https://github.com/org/other.git", nil)
+
+	ghs := &Subscriber{
+		synchronizer: &kubesynchronizer.KubeSynchronizer{},
+		itemmap: map[types.NamespacedName]*SubscriberItem{
+			{Namespace: "default", Name: "default-branch"}: defaultBranch,
+			{Namespace: "default", Name: "other-branch"}:   otherBranch,
+			{Namespace: "default", Name: "pinned"}:         pinned,
+			{Namespace: "default", Name: "other-repo"}:     otherRepo,
+		},
+	}
+
+	srv := &WebhookServer{subscriber: ghs}
+
+	req := httptest.NewRequest(http.MethodPost, webhookPath, strings.NewReader(githubPushPayload))
+	req.Header.Set(githubEventHeader, "push")
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	g.Expect(w.Code).To(gomega.Equal(http.StatusAccepted))
+	g.Expect(w.Body.String()).To(gomega.Equal("1 subscriptions synced\n"))
+	g.Expect(defaultBranch.syncch).To(gomega.HaveLen(1))
+	g.Expect(otherBranch.syncch).To(gomega.BeEmpty())
+	g.Expect(pinned.syncch).To(gomega.BeEmpty())
+	g.Expect(otherRepo.syncch).To(gomega.BeEmpty())
+
+	// a second push before the sync is not queued twice
+	g.Expect(ghs.syncPushedItems(&pushEvent{repoURLs: []string{"https://github.com/org/repo"}, ref: "refs/heads/dev"})).To(gomega.Equal(2))
+	g.Expect(defaultBranch.syncch).To(gomega.HaveLen(1))
+	g.Expect(otherBranch.syncch).To(gomega.HaveLen(1))
+}
+
+func TestValidatePushEvent(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "default"},
+		Data:       map[string][]byte{"secret": []byte("s3cr3t")},
+	}
+	clt := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+
+	event := &pushEvent{validate: func(secret string) bool { return secret == "s3cr3t" }}
+	unsigned := &pushEvent{validate: func(secret string) bool { return false }}
+
+	// the unsigned events are rejected unless the channel opts in
+	chn := &chnv1.Channel{ObjectMeta: metav1.ObjectMeta{Name: "chn", Namespace: "default"}}
+	g.Expect(validatePushEvent(clt, chn, unsigned)).To(gomega.BeFalse())
+
+	chn.Annotations = map[string]string{appv1.AnnotationWebhookAllowUnsigned: "true"}
+	g.Expect(validatePushEvent(clt, chn, unsigned)).To(gomega.BeTrue())
+
+	// the webhook secret takes precedence
+	chn.Annotations[appv1.AnnotationWebhookSecret] = "webhook"
+	g.Expect(validatePushEvent(clt, chn, unsigned)).To(gomega.BeFalse())
+	g.Expect(validatePushEvent(clt, chn, event)).To(gomega.BeTrue())
+
+	chn.Annotations[appv1.AnnotationWebhookSecret] = "missing"
+	g.Expect(validatePushEvent(clt, chn, event)).To(gomega.BeFalse())
+
+	// an empty secret doesn't validate the events with an empty token or signature
+	empty := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "default"},
+		Data:       map[string][]byte{"secret": []byte(" \n")},
+	}
+	g.Expect(clt.Create(context.TODO(), empty)).To(gomega.Succeed())
+
+	chn.Annotations[appv1.AnnotationWebhookSecret] = "empty"
+	g.Expect(validatePushEvent(clt, chn, &pushEvent{validate: func(secret string) bool { return secret == "" }})).
+		To(gomega.BeFalse())
+}
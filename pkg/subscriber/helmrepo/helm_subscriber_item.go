@@ -15,13 +15,14 @@
 package helmrepo
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1" // #nosec G505 Used only to generate random value to be used to generate hash string
-	"crypto/tls"
 	"fmt"
 	"io/ioutil"
-	"net"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -33,6 +34,7 @@ import (
 	"github.com/ghodss/yaml"
 	"helm.sh/helm/v3/pkg/repo"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -51,12 +53,31 @@ type SubscriberItem struct {
 	hash          string
 	reconcileRate string
 	syncTime      string
+	resyncPackage string
+	resyncPending bool
 	stopch        chan struct{}
 	count         int
 	syncinterval  int
 	success       bool
 	synchronizer  SyncSource
 	clusterAdmin  bool
+	fetchFailures int // the syncs in a row the Helm repo index failed to be fetched
+	// the last index downloaded from the Helm repo of each channel, by index URL
+	indexCaches map[string]*indexCache
+	// the HelmReleases of the last successful sync
+	inventory []string
+	// the subscriber state last saved in the state store
+	savedState *appv1.SubscriberState
+}
+
+// indexCache is the last index downloaded from a Helm repo, filtered for the subscription, with the ETag and
+// Last-Modified validators of its download. The index isn't downloaded and filtered again while the repo answers
+// 304 Not Modified.
+type indexCache struct {
+	etag         string
+	lastModified string
+	indexFile    *repo.IndexFile
+	hash         string
 }
 
 var (
@@ -67,6 +88,17 @@ var (
 	}
 )
 
+// maxHelmRepoIndexPages limits the pages of the Helm repo indexes split in pages
+const maxHelmRepoIndexPages = 100
+
+// helmRepoDegradedFailures is the number of syncs in a row the Helm repo index fails to be fetched before the
+// subscription is Degraded
+const helmRepoDegradedFailures = 3
+
+// helmRepoFetchBackoff is the backoff of the retries of the transient failures of the Helm repo index fetch, within
+// one sync
+var helmRepoFetchBackoff = wait.Backoff{Duration: time.Second, Factor: 2, Jitter: 0.5, Steps: 4}
+
 // SubscribeItem subscribes a subscriber item with namespace channel.
 func (hrsi *SubscriberItem) Start(restart bool) {
 	// do nothing if already started
@@ -75,6 +107,8 @@ func (hrsi *SubscriberItem) Start(restart bool) {
 			// restart this goroutine
 			klog.Info("Stopping SubscriberItem: ", hrsi.Subscription.Name)
 			hrsi.Stop()
+
+			hrsi.count = 0 // reset the counter, a new item resumes from its restored state
 		} else {
 			klog.Info("SubscriberItem already started: ", hrsi.Subscription.Name)
 
@@ -82,8 +116,6 @@ func (hrsi *SubscriberItem) Start(restart bool) {
 		}
 	}
 
-	hrsi.count = 0 // reset the counter
-
 	hrsi.stopch = make(chan struct{})
 
 	loopPeriod, retryInterval, retries := utils.GetReconcileInterval(hrsi.reconcileRate, chnv1.ChannelTypeHelmRepo)
@@ -130,6 +162,9 @@ func (hrsi *SubscriberItem) Stop() {
 func (hrsi *SubscriberItem) doSubscriptionWithRetries(retryInterval time.Duration, retries int) {
 	hrsi.doSubscription()
 
+	utils.UpdateOutOfSyncStatus(hrsi.synchronizer.GetLocalClient(), hrsi.Subscription, hrsi.success)
+	hrsi.saveState()
+
 	// If the initial subscription fails, retry.
 	n := 0
 
@@ -138,6 +173,8 @@ func (hrsi *SubscriberItem) doSubscriptionWithRetries(retryInterval time.Duratio
 			time.Sleep(retryInterval)
 			klog.Infof("Re-try #%d: subcribing to the Helm repo", n+1)
 			hrsi.doSubscription()
+			utils.UpdateOutOfSyncStatus(hrsi.synchronizer.GetLocalClient(), hrsi.Subscription, hrsi.success)
+			hrsi.saveState()
 			n++
 		} else {
 			break
@@ -145,31 +182,145 @@ func (hrsi *SubscriberItem) doSubscriptionWithRetries(retryInterval time.Duratio
 	}
 }
 
+// saveState saves the subscriber state of the item
+func (hrsi *SubscriberItem) saveState() {
+	state := &appv1.SubscriberState{
+		Revision:      hrsi.hash,
+		Generation:    hrsi.Subscription.Generation,
+		Successful:    hrsi.success,
+		Retries:       hrsi.count,
+		FetchFailures: hrsi.fetchFailures,
+		Inventory:     hrsi.inventory,
+	}
+
+	hrsi.savedState = utils.SaveSubscriberState(hrsi.synchronizer.GetLocalClient(), hrsi.Subscription, state, hrsi.savedState)
+}
+
+// restoreState resumes the item from the subscriber state saved by the previous controller of the subscription, the
+// HelmReleases of the same index are not updated again unless they failed
+func (hrsi *SubscriberItem) restoreState() {
+	state := utils.LoadSubscriberState(hrsi.synchronizer.GetLocalClient(), hrsi.Subscription)
+	if state == nil {
+		return
+	}
+
+	klog.Infof("Restoring the subscriber state of %v/%v, index hash: %v, successful: %v", hrsi.Subscription.Namespace,
+		hrsi.Subscription.Name, state.Revision, state.Successful)
+
+	hrsi.count = state.Retries
+	hrsi.fetchFailures = state.FetchFailures
+	hrsi.inventory = state.Inventory
+	hrsi.savedState = state
+
+	if state.Generation != hrsi.Subscription.Generation {
+		// the subscription changed since the HelmReleases were updated, they are updated again
+		return
+	}
+
+	hrsi.hash = state.Revision
+	hrsi.success = state.Successful
+}
+
 func (hrsi *SubscriberItem) getRepoInfo(usePrimary bool) (*repo.IndexFile, string, error) {
 	channel := hrsi.Channel
+	chnSrt, chnCfg := hrsi.ChannelSecret, hrsi.ChannelConfigMap
 
 	if !usePrimary && hrsi.SecondaryChannel != nil {
 		channel = hrsi.SecondaryChannel
+		chnSrt, chnCfg = hrsi.SecondaryChannelSecret, hrsi.SecondaryChannelConfigMap
 	}
 
-	//Retrieve the helm repo
-	repoURL := channel.Spec.Pathname
+	httpClient, err := getHelmRepoClient(chnSrt, chnCfg, channel.Spec.InsecureSkipVerify)
 
-	httpClient, err := getHelmRepoClient(hrsi.ChannelConfigMap, channel.Spec.InsecureSkipVerify)
+	if err != nil {
+		klog.Error(err, "Unable to create client for helm repo", channel.Spec.Pathname)
+		return nil, "", err
+	}
+
+	if hrsi.indexCaches == nil {
+		hrsi.indexCaches = map[string]*indexCache{}
+	}
+
+	//Retrieve the helm repos of the channel, they all have to be available
+	repoURLs := []string{}
+	indexFiles := []*repo.IndexFile{}
+	hashes := []string{}
+
+	for _, chnRepoURL := range utils.GetChannelHelmRepoURLs(channel) {
+		repoURL := utils.GetSubscriptionHelmRepoURL(hrsi.Subscription, chnRepoURL)
+
+		cache, ok := hrsi.indexCaches[repoURL]
+		if !ok {
+			cache = &indexCache{}
+			hrsi.indexCaches[repoURL] = cache
+		}
+
+		indexFile, hash, err := getHelmRepoIndexWithRetries(httpClient, hrsi.Subscription, chnSrt, repoURL, cache)
+
+		if err != nil {
+			klog.Error(err, "Unable to retrieve the helm repo index", repoURL)
+			return nil, "", err
+		}
 
+		repoURLs = append(repoURLs, repoURL)
+		indexFiles = append(indexFiles, indexFile)
+		hashes = append(hashes, hash)
+	}
+
+	indexFile, hash, err := mergeChannelHelmRepoIndexes(hrsi.Subscription, channel, repoURLs, indexFiles, hashes)
 	if err != nil {
-		klog.Error(err, "Unable to create client for helm repo", repoURL)
 		return nil, "", err
 	}
 
-	indexFile, hash, err := getHelmRepoIndex(httpClient, hrsi.Subscription, hrsi.ChannelSecret, repoURL)
+	// the HelmReleases templated with the channel metadata change with it
+	return indexFile, hash + getChannelVariablesHash(hrsi.Subscription, hrsi.Channel), nil
+}
+
+// getChannelVariablesHash returns a hash of the channel variables, if the HelmReleases of the subscription are
+// templated with them
+func getChannelVariablesHash(sub *appv1.Subscription, channel *chnv1.Channel) string {
+	if !utils.HasChannelVariables(sub) && sub.GetAnnotations()[appv1.AnnotationHelmReleaseNamespace] == "" {
+		return ""
+	}
+
+	vars := utils.GetChannelVariables(channel)
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	b := &strings.Builder{}
+	for _, name := range names {
+		b.WriteString(name + "=" + vars[name] + "\n")
+	}
+
+	return hashKey([]byte(b.String()))
+}
+
+// mergeChannelHelmRepoIndexes merges the indexes of the Helm repos of the channel by the precedence of the channel.
+// The relative chart URLs are resolved against their repo first, and the hash of the merged index is the one of all
+// the indexes.
+func mergeChannelHelmRepoIndexes(sub *appv1.Subscription, channel *chnv1.Channel, repoURLs []string,
+	indexFiles []*repo.IndexFile, hashes []string) (*repo.IndexFile, string, error) {
+	if len(indexFiles) == 1 {
+		return indexFiles[0], hashes[0], nil
+	}
+
+	for i, indexFile := range indexFiles {
+		utils.ResolveHelmChartURLs(indexFile, repoURLs[i])
+	}
 
+	merged, err := utils.MergeHelmRepoIndexes(sub, indexFiles, utils.GetChannelHelmRepoPrecedence(channel))
 	if err != nil {
-		klog.Error(err, "Unable to retrieve the helm repo index", repoURL)
 		return nil, "", err
 	}
 
-	return indexFile, hash, nil
+	klog.V(1).Infof("Merged the indexes of the Helm repos %v: %d charts", repoURLs, len(merged.Entries))
+
+	return merged, hashKey([]byte(strings.Join(hashes, "\n"))), nil
 }
 
 func (hrsi *SubscriberItem) doSubscription() {
@@ -240,6 +391,9 @@ func (hrsi *SubscriberItem) doSubscription() {
 
 	indexFile, hash, err = hrsi.getRepoInfo(true) // true for using primary channel
 
+	// the channel the index is read from
+	channel := hrsi.Channel
+
 	if err != nil {
 		klog.Error(err, "Unable to retrieve the helm repo index from the primary channel.")
 
@@ -247,22 +401,42 @@ func (hrsi *SubscriberItem) doSubscription() {
 			klog.Info("Trying the secondary channel")
 
 			indexFile, hash, err = hrsi.getRepoInfo(false) // true for using primary channel
+			channel = hrsi.SecondaryChannel
 
 			if err != nil {
 				klog.Error(err, "Unable to retrieve the helm repo index from the secondary channel.")
 
+				hrsi.success = false
+				hrsi.updateFetchStatus(err)
+
 				return
 			}
 		} else {
+			hrsi.success = false
+			hrsi.updateFetchStatus(err)
+
 			return
 		}
 	}
 
+	hrsi.updateFetchStatus(nil)
+
 	if indexFile != nil && indexFile.Entries != nil && len(indexFile.Entries) == 0 {
 		klog.Warning("Failed to find any matching Helm chart for deployment. Check spec.packageFilter: ",
 			hrsi.Subscription.GetNamespace(), "/", hrsi.Subscription.GetName())
 	}
 
+	if hrsi.resyncPending {
+		// the request is handled once, the next round reconciles all packages as usual
+		hrsi.resyncPending = false
+
+		if err := hrsi.resyncHelmCR(indexFile); err != nil {
+			klog.Error("Failed to resync helm repo package with error:", err)
+		}
+
+		return
+	}
+
 	klog.V(4).Infof("Check if helmRepo changed with hash %s", hash)
 
 	isParentMultiClusterHub := isParentMultiClusterHub(hrsi.Subscription)
@@ -278,7 +452,7 @@ func (hrsi *SubscriberItem) doSubscription() {
 			existsHelmRelease := false
 			populatedHelmReleaseStatus := false
 
-			existsHelmRelease, err = isHelmReleaseExists(hrsi.synchronizer.GetLocalClient(), hrsi.Subscription.Namespace, hrName)
+			existsHelmRelease, err = isHelmReleaseExists(hrsi.synchronizer.GetLocalClient(), hrsi.helmReleaseNamespace(), hrName)
 			if err != nil {
 				klog.Error("Failed to determine if HelmRelease exists: ", err)
 
@@ -290,7 +464,7 @@ func (hrsi *SubscriberItem) doSubscription() {
 			if existsHelmRelease {
 				populatedHelmReleaseStatus, err = isHelmReleaseStatusPopulated(hrsi.synchronizer.GetLocalClient(),
 					types.NamespacedName{Name: hrsi.Subscription.Name,
-						Namespace: hrsi.Subscription.Namespace}, hrsi.Subscription.Namespace, hrName)
+						Namespace: hrsi.Subscription.Namespace}, hrsi.helmReleaseNamespace(), hrName)
 				if err != nil {
 					klog.Error("Failed to determine if HelmRelease status is populated: ", err)
 
@@ -304,7 +478,7 @@ func (hrsi *SubscriberItem) doSubscription() {
 				klog.Infof("Processing Helm Subscription... isHashDiff=%v isUnsuccessful=%v existsHelmRelease=%v populatedHelmReleaseStatus=%v",
 					isHashDiff, isUnsuccessful, existsHelmRelease, populatedHelmReleaseStatus)
 
-				if err := hrsi.processSubscription(indexFile, hash); err != nil {
+				if err := hrsi.processSubscription(indexFile, hash, channel); err != nil {
 					klog.Error("Failed to process helm repo subscription with error:", err)
 
 					hrsi.success = false
@@ -325,7 +499,7 @@ func (hrsi *SubscriberItem) doSubscription() {
 		hrNames := getHelmReleaseNames(indexFile, hrsi.Subscription)
 
 		for _, hrName := range hrNames {
-			existsHelmRelease, err = isHelmReleaseExists(hrsi.synchronizer.GetLocalClient(), hrsi.Subscription.Namespace, hrName)
+			existsHelmRelease, err = isHelmReleaseExists(hrsi.synchronizer.GetLocalClient(), hrsi.helmReleaseNamespace(), hrName)
 			if err != nil {
 				klog.Error("Failed to determine if HelmRelease exists: ", err)
 
@@ -339,7 +513,7 @@ func (hrsi *SubscriberItem) doSubscription() {
 			klog.Infof("Processing Helm Subscription... isHashDiff=%v isUnsuccessful=%v existsHelmRelease=%v",
 				isHashDiff, isUnsuccessful, existsHelmRelease)
 
-			if err := hrsi.processSubscription(indexFile, hash); err != nil {
+			if err := hrsi.processSubscription(indexFile, hash, channel); err != nil {
 				klog.Error("Failed to process helm repo subscription with error:", err)
 
 				hrsi.success = false
@@ -352,12 +526,37 @@ func (hrsi *SubscriberItem) doSubscription() {
 	}
 }
 
+// updateFetchStatus counts the syncs in a row the Helm repo index failed to be fetched. The subscription is Degraded
+// after helmRepoDegradedFailures of them, until the index is fetched again.
+func (hrsi *SubscriberItem) updateFetchStatus(err error) {
+	if err == nil {
+		hrsi.fetchFailures = 0
+
+		utils.UpdateDegradedCondition(hrsi.synchronizer.GetLocalClient(), hrsi.Subscription, false, "ChartRepoAvailable",
+			"the Helm repo index is fetched")
+
+		return
+	}
+
+	hrsi.fetchFailures++
+
+	if hrsi.fetchFailures < helmRepoDegradedFailures {
+		return
+	}
+
+	klog.Warningf("appsub %v/%v failed to fetch the Helm repo index %d syncs in a row",
+		hrsi.Subscription.Namespace, hrsi.Subscription.Name, hrsi.fetchFailures)
+
+	utils.UpdateDegradedCondition(hrsi.synchronizer.GetLocalClient(), hrsi.Subscription, true, "ChartRepoUnavailable",
+		fmt.Sprintf("failed to fetch the Helm repo index %d syncs in a row, err: %v", helmRepoDegradedFailures, err))
+}
+
 func getHelmReleaseNames(indexFile *repo.IndexFile, sub *appv1.Subscription) []string {
 	klog.Infof("Calculating the HelmRelease names")
 
 	var hrNames []string
 
-	for packageName := range indexFile.Entries {
+	for _, packageName := range utils.SortedChartNames(indexFile) {
 		releaseCRName, err := utils.PkgToReleaseCRName(sub, packageName)
 		if err != nil {
 			klog.Error(err, "Unable to get HelmRelease name for package: ", packageName)
@@ -420,39 +619,69 @@ func isHelmReleaseStatusPopulated(client client.Client, hostSub types.Namespaced
 	return true, nil
 }
 
-func (hrsi *SubscriberItem) processSubscription(indexFile *repo.IndexFile, hash string) error {
+func (hrsi *SubscriberItem) processSubscription(indexFile *repo.IndexFile, hash string, channel *chnv1.Channel) error {
 	if err := hrsi.manageHelmCR(indexFile); err != nil {
 		return err
 	}
 
 	hrsi.hash = hash
+	hrsi.inventory = []string{}
+
+	for _, hrName := range getHelmReleaseNames(indexFile, hrsi.Subscription) {
+		hrsi.inventory = append(hrsi.inventory, utils.GetInventoryKey(releasev1.SchemeGroupVersion.String(), "HelmRelease",
+			hrsi.helmReleaseNamespace(), hrName))
+	}
+
+	source := &appv1.SubscriptionSource{
+		Channel: channel.Namespace + "/" + channel.Name,
+		URL:     utils.GetSubscriptionHelmRepoURL(hrsi.Subscription, channel.Spec.Pathname),
+	}
+
+	revision := utils.GetHelmIndexRevision(indexFile)
+
+	utils.UpdateSourceStatus(hrsi.synchronizer.GetLocalClient(), hrsi.Subscription, source, revision)
+	hrsi.synchronizer.RecordSyncAudit(hrsi.Subscription, revision)
 
 	return nil
 }
 
-func getHelmRepoClient(chnCfg *corev1.ConfigMap, insecureSkipVerify bool) (*http.Client, error) {
-	client := http.DefaultClient
+// helmReleaseNamespace returns the namespace of the HelmReleases of the subscription, the subscription namespace if
+// its helm-release-namespace annotation is invalid or not allowed, the HelmReleases failing to be generated then
+func (hrsi *SubscriberItem) helmReleaseNamespace() string {
+	namespace, err := utils.ResolveHelmReleaseNamespace(hrsi.synchronizer.GetLocalClient(), hrsi.Subscription, hrsi.Channel,
+		hrsi.clusterAdmin, hrsi.canDeployHelmRelease)
+	if err != nil {
+		return hrsi.Subscription.Namespace
+	}
+
+	return namespace
+}
 
+// canDeployHelmRelease returns true if the user of the subscription can deploy the HelmRelease in its namespace
+func (hrsi *SubscriberItem) canDeployHelmRelease(hr *unstructured.Unstructured) bool {
+	return hrsi.synchronizer.CanDeployResource(hrsi.Subscription, hr)
+}
+
+//getHelmRepoClient returns the client of the Helm repo of the channel, with the CA certificates and the client
+//certificate of the channel secret and config map
+func getHelmRepoClient(chnSrt *corev1.Secret, chnCfg *corev1.ConfigMap, insecureSkipVerify bool) (*http.Client, error) {
 	if insecureSkipVerify {
 		klog.Info("Channel spec has insecureSkipVerify: true. Skipping Helm repo server certificate verification.")
 	}
 
+	tlsConfig, err := utils.HelmRepoTLSConfig(chnSrt, chnCfg, insecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+
 	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           utils.NewFetchDialer().DialContext,
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
-		/* #nosec G402 */
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: insecureSkipVerify, // #nosec G402 InsecureSkipVerify optionally
-			MinVersion:         tls.VersionTLS12,
-		},
+		TLSClientConfig:       tlsConfig,
 	}
 
 	if chnCfg != nil && !insecureSkipVerify {
@@ -479,23 +708,193 @@ func getHelmRepoClient(chnCfg *corev1.ConfigMap, insecureSkipVerify bool) (*http
 		klog.Info("s.HelmRepoConfig is nil")
 	}
 
-	client.Transport = transport
-
-	return client, nil
+	// the client cert of the channel is not set on the default client shared by the other channels
+	return &http.Client{Transport: transport}, nil
 }
 
-//getHelmRepoIndex retreives the index.yaml, loads it into a repo.IndexFile and filters it
+//getHelmRepoIndex retreives the index.yaml, loads it into a repo.IndexFile and filters it. If cache isn't nil, the
+//index is downloaded only if it changed since the index of the cache, which is updated. The index split in pages is
+//followed through the next links of the Link header of the pages.
 func getHelmRepoIndex(client rest.HTTPClient, sub *appv1.Subscription,
-	chnSrt *corev1.Secret, repoURL string) (indexFile *repo.IndexFile, hash string, err error) {
+	chnSrt *corev1.Secret, repoURL string, cache *indexCache) (indexFile *repo.IndexFile, hash string, err error) {
+	if utils.IsOCIHelmRepo(repoURL) {
+		return getOCIHelmRepoIndex(sub, chnSrt, repoURL)
+	}
+
 	cleanRepoURL := strings.TrimSuffix(repoURL, "/") + "/index.yaml"
-	req, err := http.NewRequest(http.MethodGet, cleanRepoURL, nil)
+	req, err := newHelmRepoRequest(chnSrt, cleanRepoURL)
 
 	if err != nil {
-		klog.Error(err, "Can not build request: ", cleanRepoURL)
+		return nil, "", err
+	}
+
+	if cache != nil && cache.indexFile != nil {
+		if cache.etag != "" {
+			req.Header.Set("If-None-Match", cache.etag)
+		}
+
+		if cache.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cache.lastModified)
+		}
+	}
+
+	klog.V(1).Info(req)
+	resp, err := client.Do(req)
+
+	if err != nil {
+		klog.Error(err, "Http request failed: ", cleanRepoURL)
+
+		return nil, "", err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cache != nil && cache.indexFile != nil {
+		resp.Body.Close()
+
+		klog.Infof("Helm repo index %s hasn't changed. Skip the download.", cleanRepoURL)
+
+		return cache.indexFile, cache.hash, nil
+	}
+
+	body, err := readHelmRepoIndexPage(resp, cleanRepoURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	indexfile, err := loadIndex(body)
+
+	if err != nil {
+		klog.Error(err, "Unable to parse the indexfile: ", cleanRepoURL)
 
 		return nil, "", err
 	}
 
+	// the pages of the index are loaded with the entries of the first page, as one index
+	pages := [][]byte{body}
+	pageURL := cleanRepoURL
+
+	for nextURL := nextPageURL(resp, pageURL); nextURL != ""; nextURL = nextPageURL(resp, pageURL) {
+		if len(pages) == maxHelmRepoIndexPages {
+			return nil, "", fmt.Errorf("helm repo index %s has more than %d pages", cleanRepoURL, maxHelmRepoIndexPages)
+		}
+
+		// the credentials of the channel are only sent to the repo
+		if err := checkHelmRepoPageURL(cleanRepoURL, nextURL); err != nil {
+			return nil, "", err
+		}
+
+		pageURL = nextURL
+
+		req, err := newHelmRepoRequest(chnSrt, pageURL)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if resp, err = client.Do(req); err != nil {
+			klog.Error(err, "Http request failed: ", pageURL)
+
+			return nil, "", err
+		}
+
+		pageBody, err := readHelmRepoIndexPage(resp, pageURL)
+		if err != nil {
+			return nil, "", err
+		}
+
+		pageIndex, err := loadIndex(pageBody)
+		if err != nil {
+			klog.Error(err, "Unable to parse the indexfile page: ", pageURL)
+
+			return nil, "", err
+		}
+
+		klog.V(1).Infof("Helm repo index %s page %d: %d charts", cleanRepoURL, len(pages)+1, len(pageIndex.Entries))
+
+		indexfile.Merge(pageIndex)
+
+		pages = append(pages, pageBody)
+	}
+
+	hash = hashKey(bytes.Join(pages, []byte("\n")))
+
+	// the charts of the scoped repos and of the pages are relative to the repo, not to the channel
+	if len(pages) > 1 || sub.GetAnnotations()[appv1.AnnotationHelmRepoProject] != "" {
+		utils.ResolveHelmChartURLs(indexfile, repoURL)
+	}
+
+	err = utils.FilterCharts(sub, indexfile)
+
+	// the repos without validators are downloaded every time, and so are the paginated indexes, the validators of
+	// the first page don't cover the other pages
+	if cache != nil && err == nil {
+		*cache = indexCache{hash: hash}
+
+		if len(pages) == 1 {
+			cache.etag = resp.Header.Get("ETag")
+			cache.lastModified = resp.Header.Get("Last-Modified")
+		}
+
+		if cache.etag != "" || cache.lastModified != "" {
+			cache.indexFile = indexfile
+		}
+	}
+
+	return indexfile, hash, err
+}
+
+// getHelmRepoIndexWithRetries retries the transient failures of getHelmRepoIndex with an exponential backoff and
+// jitter, so a Helm repo briefly unavailable doesn't fail the sync until the next reconcile
+func getHelmRepoIndexWithRetries(client rest.HTTPClient, sub *appv1.Subscription,
+	chnSrt *corev1.Secret, repoURL string, cache *indexCache) (*repo.IndexFile, string, error) {
+	backoff := helmRepoFetchBackoff
+
+	for {
+		indexFile, hash, err := getHelmRepoIndex(client, sub, chnSrt, repoURL, cache)
+		if err == nil || !isTransientHelmRepoError(err) || backoff.Steps <= 1 {
+			return indexFile, hash, err
+		}
+
+		delay := backoff.Step()
+
+		klog.Warningf("Failed to fetch the Helm repo index %v, retrying in %v. err: %v", repoURL, delay, err)
+
+		time.Sleep(delay)
+	}
+}
+
+// helmRepoStatusError is the error status a Helm repo answered a request with
+type helmRepoStatusError struct {
+	url        string
+	status     string
+	statusCode int
+}
+
+func (e *helmRepoStatusError) Error() string {
+	return fmt.Sprintf("http request %s failed: status %s", e.url, e.status)
+}
+
+// isTransientHelmRepoError returns true if the Helm repo request failed to connect, or if the Helm repo answered with
+// a server error or asked to slow down
+func isTransientHelmRepoError(err error) bool {
+	var statusErr *helmRepoStatusError
+	if gerr.As(err, &statusErr) {
+		return statusErr.statusCode >= http.StatusInternalServerError || statusErr.statusCode == http.StatusTooManyRequests
+	}
+
+	var urlErr *url.Error
+
+	return gerr.As(err, &urlErr) && urlErr.Op != "parse"
+}
+
+// newHelmRepoRequest returns the GET request of the Helm repo URL with the credentials of the channel secret
+func newHelmRepoRequest(chnSrt *corev1.Secret, reqURL string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+
+	if err != nil {
+		klog.Error(err, "Can not build request: ", reqURL)
+
+		return nil, err
+	}
+
 	if chnSrt != nil && chnSrt.Data != nil {
 		if authHeader, ok := chnSrt.Data["authHeader"]; ok {
 			req.Header.Set("Authorization", string(authHeader))
@@ -503,49 +902,113 @@ func getHelmRepoIndex(client rest.HTTPClient, sub *appv1.Subscription,
 			if password, ok := chnSrt.Data["password"]; ok {
 				req.SetBasicAuth(string(user), string(password))
 			} else {
-				return nil, "", fmt.Errorf("password not found in secret for basic authentication")
+				return nil, fmt.Errorf("password not found in secret for basic authentication")
 			}
 		}
 	}
 
-	klog.V(1).Info(req)
-	resp, err := client.Do(req)
-
-	if err != nil {
-		klog.Error(err, "Http request failed: ", cleanRepoURL)
+	return req, nil
+}
 
-		return nil, "", err
-	}
+// readHelmRepoIndexPage reads the body of the response of a page of the Helm repo index
+func readHelmRepoIndexPage(resp *http.Response, pageURL string) ([]byte, error) {
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		klog.Errorf("http request %s failed: status %s", cleanRepoURL, resp.Status)
+		klog.Errorf("http request %s failed: status %s", pageURL, resp.Status)
 
-		return nil, "", fmt.Errorf("http request %s failed: status %s", cleanRepoURL, resp.Status)
+		return nil, &helmRepoStatusError{url: pageURL, status: resp.Status, statusCode: resp.StatusCode}
 	}
 
-	klog.V(5).Info("Get succeeded: ", cleanRepoURL)
+	klog.V(5).Info("Get succeeded: ", pageURL)
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		klog.Error(err, "Unable to read body: ", cleanRepoURL)
+		klog.Error(err, "Unable to read body: ", pageURL)
 
-		return nil, "", err
+		return nil, err
 	}
 
-	defer resp.Body.Close()
+	return body, nil
+}
 
-	hash = hashKey(body)
-	indexfile, err := loadIndex(body)
+// checkHelmRepoPageURL returns an error if the next page URL of the Helm repo index is not on the scheme and host of
+// the repo, or not in the channel source allow-list
+func checkHelmRepoPageURL(repoURL, pageURL string) error {
+	repo, err := url.Parse(repoURL)
+	if err != nil {
+		return err
+	}
 
+	page, err := url.Parse(pageURL)
 	if err != nil {
-		klog.Error(err, "Unable to parse the indexfile: ", cleanRepoURL)
+		return err
+	}
+
+	if !strings.EqualFold(page.Scheme, repo.Scheme) || !strings.EqualFold(page.Host, repo.Host) {
+		return fmt.Errorf("next page %v of Helm repo index %v is not on the host of the repo", utils.RedactGitURL(pageURL),
+			repoURL)
+	}
+
+	return utils.CheckSourceURLAllowed(pageURL)
+}
+
+// nextPageURL returns the URL of the rel="next" link of the Link header of the response, resolved against the page
+// URL, or "" if the page is the last one
+func nextPageURL(resp *http.Response, pageURL string) string {
+	for _, header := range resp.Header.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			for _, param := range parts[1:] {
+				if !strings.EqualFold(strings.NewReplacer(" ", "", `"`, "").Replace(param), "rel=next") {
+					continue
+				}
+
+				base, err := url.Parse(pageURL)
+				if err != nil {
+					return ""
+				}
+
+				next, err := base.Parse(strings.Trim(target, "<>"))
+				if err != nil {
+					klog.Errorf("invalid next link %v of Helm repo index page %v", target, pageURL)
+
+					return ""
+				}
+
+				return next.String()
+			}
+		}
+	}
+
+	return ""
+}
+
+// getOCIHelmRepoIndex lists the versions of the charts of the subscription in the OCI registry, and filters them
+func getOCIHelmRepoIndex(sub *appv1.Subscription, chnSrt *corev1.Secret,
+	repoURL string) (indexFile *repo.IndexFile, hash string, err error) {
+	indexFile, err = utils.GetOCIHelmIndex(repoURL, chnSrt, utils.GetSubscriptionPackages(sub))
+	if err != nil {
+		klog.Error(err)
 
 		return nil, "", err
 	}
 
-	err = utils.FilterCharts(sub, indexfile)
+	// the generated time of the index changes on every listing
+	body, err := yaml.Marshal(indexFile.Entries)
+	if err != nil {
+		return nil, "", err
+	}
 
-	return indexfile, hash, err
+	err = utils.FilterCharts(sub, indexFile)
+
+	return indexFile, hashKey(body), err
 }
 
 func GetSubscriptionChartsOnHub(hubClt client.Client, channel, secondChannel *chnv1.Channel, sub *appv1.Subscription) ([]*releasev1.HelmRelease, error) {
@@ -616,18 +1079,32 @@ func getChartIndexWithChannel(hubClt client.Client, channel *chnv1.Channel, sub
 		klog.Infof("got configmap %v from channel %v", chnCfgKey.String(), channel)
 	}
 
-	httpClient, err := getHelmRepoClient(chnCfg, channel.Spec.InsecureSkipVerify)
+	httpClient, err := getHelmRepoClient(chSecret, chnCfg, channel.Spec.InsecureSkipVerify)
 
 	if err != nil {
 		return nil, gerr.Wrapf(err, "Unable to create client for helm repo %v", channel.Spec.Pathname)
 	}
 
-	indexFile, _, err := getHelmRepoIndex(httpClient, sub, chSecret, channel.Spec.Pathname)
-	if err != nil {
-		return nil, gerr.Wrapf(err, "unable to retrieve the helm repo index %v", channel.Spec.Pathname)
+	repoURLs := []string{}
+	indexFiles := []*repo.IndexFile{}
+	hashes := []string{}
+
+	for _, chnRepoURL := range utils.GetChannelHelmRepoURLs(channel) {
+		repoURL := utils.GetSubscriptionHelmRepoURL(sub, chnRepoURL)
+
+		indexFile, hash, err := getHelmRepoIndex(httpClient, sub, chSecret, repoURL, nil)
+		if err != nil {
+			return nil, gerr.Wrapf(err, "unable to retrieve the helm repo index %v", repoURL)
+		}
+
+		repoURLs = append(repoURLs, repoURL)
+		indexFiles = append(indexFiles, indexFile)
+		hashes = append(hashes, hash)
 	}
 
-	return indexFile, nil
+	indexFile, _, err := mergeChannelHelmRepoIndexes(sub, channel, repoURLs, indexFiles, hashes)
+
+	return indexFile, err
 }
 
 func ChartIndexToHelmReleases(hclt client.Client,
@@ -637,7 +1114,9 @@ func ChartIndexToHelmReleases(hclt client.Client,
 	indexFile *repo.IndexFile) ([]*releasev1.HelmRelease, error) {
 	helms := make([]*releasev1.HelmRelease, 0)
 
-	for pkgName, chartVer := range indexFile.Entries {
+	for _, pkgName := range utils.SortedChartNames(indexFile) {
+		chartVer := indexFile.Entries[pkgName]
+
 		releaseCRName, err := utils.PkgToReleaseCRName(sub, pkgName)
 		if err != nil {
 			return nil, gerr.Wrapf(err, "failed to generate releaseCRName of helm chart %v for subscription %v", pkgName, sub)
@@ -652,6 +1131,8 @@ func ChartIndexToHelmReleases(hclt client.Client,
 			return nil, err
 		}
 
+		utils.SubstituteChannelVariables(helm, sub, chn)
+
 		helms = append(helms, helm)
 	}
 
@@ -689,17 +1170,47 @@ func hashKey(b []byte) string {
 }
 
 func (hrsi *SubscriberItem) manageHelmCR(indexFile *repo.IndexFile) error {
+	resources, doErr := hrsi.getHelmCRResources(indexFile)
+
+	if len(resources) > 0 || (len(resources) == 0 && doErr == nil) {
+		if len(resources) == 0 {
+			klog.Warningf("The resources length is 0, this might lead to deregistration for subscription %s/%s",
+				hrsi.Subscription.Namespace, hrsi.Subscription.Name)
+		}
+
+		if err := hrsi.synchronizer.ProcessSubResources(hrsi.Subscription, resources, nil, nil, false); err != nil {
+			klog.Warningf("failed to put helm manifest to cache (will retry), err: %v", err)
+			doErr = err
+		}
+	}
+
+	return doErr
+}
+
+// resyncHelmCR force re-applies the HelmRelease of the package requested by the resync-package annotation
+func (hrsi *SubscriberItem) resyncHelmCR(indexFile *repo.IndexFile) error {
+	resources, err := hrsi.getHelmCRResources(indexFile)
+	if err != nil {
+		klog.Warning("failed to create some helmrelease CR manifests, err: ", err)
+	}
+
+	return hrsi.synchronizer.ResyncSubResources(hrsi.Subscription, resources, nil, nil, false)
+}
+
+func (hrsi *SubscriberItem) getHelmCRResources(indexFile *repo.IndexFile) ([]kubesynchronizer.ResourceUnit, error) {
 	var doErr error
 
 	resources := make([]kubesynchronizer.ResourceUnit, 0)
 
 	//Loop on all packages selected by the subscription
-	for packageName, chartVersions := range indexFile.Entries {
+	for _, packageName := range utils.SortedChartNames(indexFile) {
+		chartVersions := indexFile.Entries[packageName]
+
 		klog.Infof("chart: %s\n%v", packageName, chartVersions)
 
 		dpl, err := utils.CreateHelmCRManifest(
-			hrsi.Channel.Spec.Pathname, packageName, chartVersions, hrsi.synchronizer.GetLocalClient(),
-			hrsi.Channel, hrsi.SecondaryChannel, hrsi.Subscription, hrsi.clusterAdmin)
+			utils.GetSubscriptionHelmRepoURL(hrsi.Subscription, hrsi.Channel.Spec.Pathname), packageName, chartVersions, hrsi.synchronizer.GetLocalClient(),
+			hrsi.Channel, hrsi.SecondaryChannel, hrsi.Subscription, hrsi.clusterAdmin, hrsi.canDeployHelmRelease)
 
 		if err != nil {
 			klog.Error("failed to create a helmrelease CR manifest, err: ", err)
@@ -713,19 +1224,7 @@ func (hrsi *SubscriberItem) manageHelmCR(indexFile *repo.IndexFile) error {
 		resources = append(resources, unit)
 	}
 
-	if len(resources) > 0 || (len(resources) == 0 && doErr == nil) {
-		if len(resources) == 0 {
-			klog.Warningf("The resources length is 0, this might lead to deregistration for subscription %s/%s",
-				hrsi.Subscription.Namespace, hrsi.Subscription.Name)
-		}
-
-		if err := hrsi.synchronizer.ProcessSubResources(hrsi.Subscription, resources, nil, nil, false); err != nil {
-			klog.Warningf("failed to put helm manifest to cache (will retry), err: %v", err)
-			doErr = err
-		}
-	}
-
-	return doErr
+	return resources, doErr
 }
 
 func isParentMultiClusterHub(sub *appv1.Subscription) bool {
@@ -0,0 +1,385 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmrepo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+const testIndex = `apiVersion: v1
+entries:
+  nginx-ingress:
+  - name: nginx-ingress
+    version: %s
+    urls:
+    - https://charts.example.com/nginx-ingress-%s.tgz
+`
+
+func TestGetHelmRepoIndexCache(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	version := "1.0.0"
+	downloads := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + version + `"`
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		downloads++
+
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(fmt.Sprintf(testIndex, version, version)))
+	}))
+	defer srv.Close()
+
+	sub := &appv1alpha1.Subscription{Spec: appv1alpha1.SubscriptionSpec{Package: "nginx-ingress"}}
+	cache := &indexCache{}
+
+	indexFile, hash, err := getHelmRepoIndex(srv.Client(), sub, nil, srv.URL, cache)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(indexFile.Entries["nginx-ingress"][0].Version).To(gomega.Equal("1.0.0"))
+	g.Expect(cache.etag).To(gomega.Equal(`"1.0.0"`))
+
+	// the unchanged index is not downloaded again
+	cached, cachedHash, err := getHelmRepoIndex(srv.Client(), sub, nil, srv.URL, cache)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cached).To(gomega.BeIdenticalTo(indexFile))
+	g.Expect(cachedHash).To(gomega.Equal(hash))
+	g.Expect(downloads).To(gomega.Equal(1))
+
+	version = "1.1.0"
+
+	indexFile, newHash, err := getHelmRepoIndex(srv.Client(), sub, nil, srv.URL, cache)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(indexFile.Entries["nginx-ingress"][0].Version).To(gomega.Equal("1.1.0"))
+	g.Expect(newHash).NotTo(gomega.Equal(hash))
+	g.Expect(downloads).To(gomega.Equal(2))
+
+	// without cache, the index is always downloaded
+	_, _, err = getHelmRepoIndex(srv.Client(), sub, nil, srv.URL, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(downloads).To(gomega.Equal(3))
+}
+
+const testIndexPage = `apiVersion: v1
+entries:
+  %s:
+  - name: %s
+    version: 1.0.0
+    urls:
+    - charts/%s-1.0.0.tgz
+`
+
+func TestGetHelmRepoIndexPages(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chartrepo/team-a/index.yaml", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", `</chartrepo/team-a/index.yaml?page=2>; rel="next"`)
+			_, _ = w.Write([]byte(fmt.Sprintf(testIndexPage, "nginx-ingress", "nginx-ingress", "nginx-ingress")))
+		case "2":
+			w.Header().Add("Link", `<index.yaml>; rel="first", <index.yaml?page=3>; rel=next`)
+			_, _ = w.Write([]byte(fmt.Sprintf(testIndexPage, "redis", "redis", "redis")))
+		default:
+			_, _ = w.Write([]byte(fmt.Sprintf(testIndexPage, "mongodb", "mongodb", "mongodb")))
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	sub := &appv1alpha1.Subscription{Spec: appv1alpha1.SubscriptionSpec{Package: "nginx-ingress,redis"}}
+	sub.SetAnnotations(map[string]string{appv1alpha1.AnnotationHelmRepoProject: "/team-a/"})
+
+	repoURL := utils.GetSubscriptionHelmRepoURL(sub, srv.URL+"/chartrepo/")
+	g.Expect(repoURL).To(gomega.Equal(srv.URL + "/chartrepo/team-a"))
+
+	indexFile, hash, err := getHelmRepoIndex(srv.Client(), sub, nil, repoURL, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(indexFile.Entries).To(gomega.HaveLen(2))
+	g.Expect(indexFile.Entries["redis"][0].URLs).To(gomega.Equal([]string{srv.URL + "/chartrepo/team-a/charts/redis-1.0.0.tgz"}))
+
+	// the hash covers all the pages
+	firstPage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(fmt.Sprintf(testIndexPage, "nginx-ingress", "nginx-ingress", "nginx-ingress")))
+	}))
+	defer firstPage.Close()
+
+	_, firstHash, err := getHelmRepoIndex(firstPage.Client(), sub, nil, firstPage.URL, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(firstHash).NotTo(gomega.Equal(hash))
+
+	// the index looping on its pages is rejected
+	loop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `<index.yaml>; rel="next"`)
+		_, _ = w.Write([]byte(fmt.Sprintf(testIndexPage, "nginx-ingress", "nginx-ingress", "nginx-ingress")))
+	}))
+	defer loop.Close()
+
+	_, _, err = getHelmRepoIndex(loop.Client(), sub, nil, loop.URL, nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	// the next links to another host are not followed with the credentials of the channel
+	offHost := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "<"+srv.URL+"/chartrepo/team-a/index.yaml?page=2>; rel=next")
+		_, _ = w.Write([]byte(fmt.Sprintf(testIndexPage, "nginx-ingress", "nginx-ingress", "nginx-ingress")))
+	}))
+	defer offHost.Close()
+
+	secret := &corev1.Secret{Data: map[string][]byte{"authHeader": []byte("Bearer s3cr3t")}}
+
+	_, _, err = getHelmRepoIndex(offHost.Client(), sub, secret, offHost.URL, nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	// the paginated indexes are not cached, a 304 of the first page doesn't cover the other pages
+	cache := &indexCache{}
+
+	_, _, err = getHelmRepoIndex(srv.Client(), sub, nil, repoURL, cache)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cache.indexFile).To(gomega.BeNil())
+	g.Expect(cache.etag).To(gomega.BeEmpty())
+
+	// without project, the channel URL is the repo URL
+	g.Expect(utils.GetSubscriptionHelmRepoURL(&appv1alpha1.Subscription{}, srv.URL+"/chartrepo")).To(
+		gomega.Equal(srv.URL + "/chartrepo"))
+}
+
+func TestGetHelmRepoIndexRetries(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func(backoff wait.Backoff) { helmRepoFetchBackoff = backoff }(helmRepoFetchBackoff)
+
+	helmRepoFetchBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 2, Jitter: 0.5, Steps: 4}
+
+	status := http.StatusServiceUnavailable
+	failures := 2
+	requests := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if requests <= failures {
+			w.WriteHeader(status)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(fmt.Sprintf(testIndex, "1.0.0", "1.0.0")))
+	}))
+	defer srv.Close()
+
+	sub := &appv1alpha1.Subscription{Spec: appv1alpha1.SubscriptionSpec{Package: "nginx-ingress"}}
+
+	indexFile, _, err := getHelmRepoIndexWithRetries(srv.Client(), sub, nil, srv.URL, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(indexFile.Entries).To(gomega.HaveKey("nginx-ingress"))
+	g.Expect(requests).To(gomega.Equal(3))
+
+	// the retries are bounded
+	requests, failures = 0, 10
+
+	_, _, err = getHelmRepoIndexWithRetries(srv.Client(), sub, nil, srv.URL, nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(isTransientHelmRepoError(err)).To(gomega.BeTrue())
+	g.Expect(requests).To(gomega.Equal(4))
+
+	// the client errors are not retried
+	requests, status = 0, http.StatusNotFound
+
+	_, _, err = getHelmRepoIndexWithRetries(srv.Client(), sub, nil, srv.URL, nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(isTransientHelmRepoError(err)).To(gomega.BeFalse())
+	g.Expect(requests).To(gomega.Equal(1))
+}
+
+func TestGetChartIndexWithAggregatedChannel(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(fmt.Sprintf(testIndexPage, "nginx-ingress", "nginx-ingress", "nginx-ingress")))
+	}))
+	defer mirror.Close()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`apiVersion: v1
+entries:
+  nginx-ingress:
+  - name: nginx-ingress
+    version: 1.1.0
+    urls:
+    - charts/nginx-ingress-1.1.0.tgz
+  - name: nginx-ingress
+    version: 1.0.0
+    urls:
+    - charts/nginx-ingress-1.0.0.tgz
+  redis:
+  - name: redis
+    version: 1.0.0
+    urls:
+    - charts/redis-1.0.0.tgz
+`))
+	}))
+	defer upstream.Close()
+
+	channel := &chnv1.Channel{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "charts",
+			Namespace:   "default",
+			Annotations: map[string]string{appv1alpha1.AnnotationHelmRepoURLs: upstream.URL + ", " + mirror.URL},
+		},
+		Spec: chnv1.ChannelSpec{Type: chnv1.ChannelTypeHelmRepo, Pathname: mirror.URL},
+	}
+
+	g.Expect(utils.GetChannelHelmRepoURLs(channel)).To(gomega.Equal([]string{mirror.URL, upstream.URL}))
+
+	sub := &appv1alpha1.Subscription{Spec: appv1alpha1.SubscriptionSpec{Package: "nginx-ingress,redis"}}
+
+	// the highest version of a chart is taken across the repos
+	indexFile, err := getChartIndexWithChannel(nil, channel, sub)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(indexFile.Entries).To(gomega.HaveLen(2))
+	g.Expect(indexFile.Entries["nginx-ingress"][0].Version).To(gomega.Equal("1.1.0"))
+	g.Expect(indexFile.Entries["nginx-ingress"][0].URLs).To(gomega.Equal(
+		[]string{upstream.URL + "/charts/nginx-ingress-1.1.0.tgz"}))
+	g.Expect(indexFile.Entries["redis"][0].URLs).To(gomega.Equal([]string{upstream.URL + "/charts/redis-1.0.0.tgz"}))
+
+	// the version in several repos is taken from the first one
+	sub.Spec.PackageFilter = &appv1alpha1.PackageFilter{Version: "1.0.0"}
+
+	indexFile, err = getChartIndexWithChannel(nil, channel, sub)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(indexFile.Entries["nginx-ingress"][0].URLs).To(gomega.Equal(
+		[]string{mirror.URL + "/charts/nginx-ingress-1.0.0.tgz"}))
+
+	// the charts of the first repo hide the ones of the next repos
+	sub.Spec.PackageFilter = nil
+	channel.Annotations[appv1alpha1.AnnotationHelmRepoPrecedence] = appv1alpha1.HelmRepoPrecedenceOrder
+
+	indexFile, err = getChartIndexWithChannel(nil, channel, sub)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(indexFile.Entries["nginx-ingress"][0].Version).To(gomega.Equal("1.0.0"))
+	g.Expect(indexFile.Entries["redis"][0].Version).To(gomega.Equal("1.0.0"))
+
+	// all the repos of the channel have to be available
+	upstream.Close()
+
+	_, err = getChartIndexWithChannel(nil, channel, sub)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+// newTestClientCert returns a self-signed client certificate and its key, in PEM
+func newTestClientCert(g *gomega.WithT) (*x509.Certificate, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "helm-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	cert, err := x509.ParseCertificate(der)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestGetHelmRepoClientTLS(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	clientCert, clientCertPEM, clientKeyPEM := newTestClientCert(g)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(fmt.Sprintf(testIndex, "1.0.0", "1.0.0")))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs, MinVersion: tls.VersionTLS12}
+	srv.StartTLS()
+
+	defer srv.Close()
+
+	sub := &appv1alpha1.Subscription{Spec: appv1alpha1.SubscriptionSpec{Package: "nginx-ingress"}}
+
+	caCerts := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+	chnCfg := &corev1.ConfigMap{Data: map[string]string{utils.CACerts: caCerts}}
+	chnSrt := &corev1.Secret{Data: map[string][]byte{utils.ClientCert: clientCertPEM, utils.ClientKey: clientKeyPEM}}
+
+	client, err := getHelmRepoClient(chnSrt, chnCfg, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	indexFile, _, err := getHelmRepoIndex(client, sub, chnSrt, srv.URL, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(indexFile.Entries).To(gomega.HaveKey("nginx-ingress"))
+
+	// the server requires the client certificate
+	client, err = getHelmRepoClient(nil, chnCfg, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, _, err = getHelmRepoIndex(client, sub, nil, srv.URL, nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	// the server certificate is not trusted without the CA certificates of the channel
+	client, err = getHelmRepoClient(chnSrt, nil, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, _, err = getHelmRepoIndex(client, sub, chnSrt, srv.URL, nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	// the client certificate needs its key
+	_, err = getHelmRepoClient(&corev1.Secret{Data: map[string][]byte{utils.ClientCert: clientCertPEM}}, chnCfg, false)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
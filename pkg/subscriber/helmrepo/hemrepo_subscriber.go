@@ -18,7 +18,6 @@ import (
 	"errors"
 	"strings"
 
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
@@ -26,21 +25,11 @@ import (
 	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
 	kubesynchronizer "open-cluster-management.io/multicloud-operators-subscription/pkg/synchronizer/kubernetes"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
-type SyncSource interface {
-	GetInterval() int
-	GetLocalClient() client.Client
-	GetLocalNonCachedClient() client.Client
-	GetRemoteClient() client.Client
-	GetRemoteNonCachedClient() client.Client
-	IsResourceNamespaced(*unstructured.Unstructured) bool
-	ProcessSubResources(*appv1alpha1.Subscription, []kubesynchronizer.ResourceUnit,
-		map[string]map[string]string, map[string]map[string]string, bool) error
-	PurgeAllSubscribedResources(*appv1alpha1.Subscription) error
-}
+// SyncSource is the synchronizer the subscriber applies the resources with
+type SyncSource = kubesynchronizer.SyncSource
 
 type itemmap map[types.NamespacedName]*SubscriberItem
 
@@ -79,7 +68,7 @@ func Add(mgr manager.Manager, hubconfig *rest.Config, syncid *types.NamespacedNa
 		return err
 	}
 
-	sync.SkipAppSubStatusResDel = true
+	sync.SetSkipAppSubStatusResDel(true)
 
 	defaultSubscriber = CreateHelmRepoSubsriber(hubconfig, mgr.GetScheme(), mgr, sync, syncinterval)
 	if defaultSubscriber == nil {
@@ -108,13 +97,22 @@ func (hrs *Subscriber) SubscribeItem(subitem *appv1alpha1.SubscriberItem) error
 		hrssubitem.synchronizer = hrs.synchronizer
 	}
 
+	previous := hrssubitem.SubscriberItem
+
 	subitem.DeepCopyInto(&hrssubitem.SubscriberItem)
 	hrssubitem.hash = ""
+	// the index is filtered again for the updated subscription
+	hrssubitem.indexCaches = nil
 
 	hrs.itemmap[itemkey] = hrssubitem
 
+	if !ok {
+		hrssubitem.restoreState()
+	}
+
 	previousReconcileLevel := hrssubitem.reconcileRate
 	previousSyncTime := hrssubitem.syncTime
+	previousResyncPackage := hrssubitem.resyncPackage
 
 	chnAnnotations := hrssubitem.Channel.GetAnnotations()
 
@@ -127,6 +125,7 @@ func (hrs *Subscriber) SubscribeItem(subitem *appv1alpha1.SubscriberItem) error
 
 	hrssubitem.reconcileRate = utils.GetReconcileRate(chnAnnotations, subAnnotations)
 	hrssubitem.syncTime = subAnnotations[appv1alpha1.AnnotationManualReconcileTime]
+	hrssubitem.resyncPackage = subAnnotations[appv1alpha1.AnnotationResyncPackage]
 
 	// Reconcile level can be overridden to be
 	if strings.EqualFold(subAnnotations[appv1alpha1.AnnotationResourceReconcileLevel], "off") {
@@ -148,6 +147,29 @@ func (hrs *Subscriber) SubscribeItem(subitem *appv1alpha1.SubscriberItem) error
 		restart = true
 	}
 
+	// If the channel credentials or config have changed, we want to restart the reconcile cycle and deploy the charts
+	// immediately
+	if ok && utils.IsSubscriberItemReferenceChanged(&previous, &hrssubitem.SubscriberItem) {
+		klog.Infof("The referenced secrets or config maps of %v have changed. restart to reconcile resources", itemkey)
+
+		restart = true
+	}
+
+	// If the channel spec has changed, e.g. its pathname or secretRef, reconcile the resources from the new source
+	if ok && utils.IsSubscriberItemChannelChanged(&previous, &hrssubitem.SubscriberItem) {
+		klog.Infof("The channel of %v has changed. restart to reconcile resources from the new source", itemkey)
+
+		restart = true
+	}
+
+	// A request already present when the item is created, e.g. after a restart of the subscription pod, was handled before.
+	if ok && hrssubitem.resyncPackage != "" && previousResyncPackage != hrssubitem.resyncPackage {
+		klog.Infof("Resync of package %s is requested. restart to resync the package", hrssubitem.resyncPackage)
+
+		hrssubitem.resyncPending = true
+		restart = true
+	}
+
 	hrssubitem.Start(restart)
 
 	return nil
@@ -163,6 +185,8 @@ func (hrs *Subscriber) UnsubscribeItem(key types.NamespacedName) error {
 		subitem.Stop()
 		delete(hrs.itemmap, key)
 
+		utils.DeleteSubscriberState(hrs.synchronizer.GetLocalClient(), subitem.Subscription)
+
 		if err := hrs.synchronizer.PurgeAllSubscribedResources(subitem.Subscription); err != nil {
 			klog.Errorf("failed to unsubscribe  %v, err: %v", key.String(), err)
 
@@ -18,7 +18,6 @@ import (
 	"errors"
 	"strings"
 
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
@@ -26,23 +25,13 @@ import (
 	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
 	kubesynchronizer "open-cluster-management.io/multicloud-operators-subscription/pkg/synchronizer/kubernetes"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
 type itemmap map[types.NamespacedName]*SubscriberItem
 
-type SyncSource interface {
-	GetInterval() int
-	GetLocalClient() client.Client
-	GetLocalNonCachedClient() client.Client
-	GetRemoteClient() client.Client
-	GetRemoteNonCachedClient() client.Client
-	IsResourceNamespaced(*unstructured.Unstructured) bool
-	ProcessSubResources(*appv1alpha1.Subscription, []kubesynchronizer.ResourceUnit,
-		map[string]map[string]string, map[string]map[string]string, bool) error
-	PurgeAllSubscribedResources(*appv1alpha1.Subscription) error
-}
+// SyncSource is the synchronizer the subscriber applies the resources with
+type SyncSource = kubesynchronizer.SyncSource
 
 // Subscriber - information to run object bucket subscription.
 type Subscriber struct {
@@ -79,7 +68,7 @@ func Add(mgr manager.Manager, hubconfig *rest.Config, syncid *types.NamespacedNa
 		return err
 	}
 
-	sync.SkipAppSubStatusResDel = false
+	sync.SetSkipAppSubStatusResDel(false)
 
 	defaultSubscriber = CreateObjectBucketSubsriber(hubconfig, mgr.GetScheme(), mgr, sync, syncinterval)
 	if defaultSubscriber == nil {
@@ -108,6 +97,8 @@ func (obs *Subscriber) SubscribeItem(subitem *appv1alpha1.SubscriberItem) error
 		obssubitem.synchronizer = obs.synchronizer
 	}
 
+	previous := obssubitem.SubscriberItem
+
 	subitem.DeepCopyInto(&obssubitem.SubscriberItem)
 
 	obs.itemmap[itemkey] = obssubitem
@@ -146,6 +137,20 @@ func (obs *Subscriber) SubscribeItem(subitem *appv1alpha1.SubscriberItem) error
 		restart = true
 	}
 
+	// If the channel credentials or the package filter have changed, re-apply the resources immediately
+	if ok && utils.IsSubscriberItemReferenceChanged(&previous, &obssubitem.SubscriberItem) {
+		klog.Infof("The referenced secrets or config maps of %v have changed. restart to reconcile resources", itemkey)
+
+		restart = true
+	}
+
+	// If the channel spec has changed, e.g. its pathname or secretRef, reconcile the resources from the new source
+	if ok && utils.IsSubscriberItemChannelChanged(&previous, &obssubitem.SubscriberItem) {
+		klog.Infof("The channel of %v has changed. restart to reconcile resources from the new source", itemkey)
+
+		restart = true
+	}
+
 	obssubitem.Start(restart)
 
 	return nil
@@ -161,6 +166,8 @@ func (obs *Subscriber) UnsubscribeItem(key types.NamespacedName) error {
 		subitem.Stop()
 		delete(obs.itemmap, key)
 
+		utils.DeleteSubscriberState(obs.synchronizer.GetLocalClient(), subitem.Subscription)
+
 		if err := obs.synchronizer.PurgeAllSubscribedResources(subitem.Subscription); err != nil {
 			klog.Errorf("failed to unsubscribe  %v, err: %v", key.String(), err)
 
@@ -31,10 +31,15 @@ import (
 
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
 	awsutils "open-cluster-management.io/multicloud-operators-subscription/pkg/utils/aws"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils/azure"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils/gcs"
 )
 
 var SubscriptionGVK = schema.GroupVersionKind{Group: "apps.open-cluster-management.io", Kind: "Subscription", Version: "v1"}
 
+// ReasonInvalidKeyFilter prefixes the subscription status reason when the object key filter of its channel is invalid
+const ReasonInvalidKeyFilter = "InvalidKeyFilter"
+
 // SubscriberItem - defines the unit of namespace subscription.
 type SubscriberItem struct {
 	appv1.SubscriberItem
@@ -48,6 +53,15 @@ type SubscriberItem struct {
 	clusterAdmin  bool
 	syncinterval  int
 	synchronizer  SyncSource
+
+	// namespaceContainment is the namespace containment policy of the subscription namespace
+	namespaceContainment string
+
+	// revision and inventory are the revision and the resources of the last successful sync
+	revision  string
+	inventory []string
+	// savedState is the subscriber state last saved in the state store
+	savedState *appv1.SubscriberState
 }
 
 // SubscribeItem subscribes a subscriber item with namespace channel.
@@ -188,38 +202,77 @@ func (obsi *SubscriberItem) getChannelConfig(primary bool) (endpoint, accessKeyI
 }
 
 func (obsi *SubscriberItem) getAwsHandler(primary bool) error {
-	awshandler := &awsutils.Handler{}
-
 	endpoint, accessKeyID, secretAccessKey, region, err := obsi.getChannelConfig(primary)
 
 	if err != nil {
 		return err
 	}
 
-	klog.V(1).Info("Trying to connect to object bucket ", endpoint, "|", obsi.bucket)
+	channel := obsi.Channel
+
+	if !primary {
+		channel = obsi.SecondaryChannel
+	}
+
+	settings, err := awsutils.GetChannelObjectStoreSettings(channel, endpoint, accessKeyID, secretAccessKey, region)
+	if err != nil {
+		return err
+	}
+
+	klog.V(1).Info("Trying to connect to object bucket ", settings.Endpoint, "|", obsi.bucket)
 
-	if err := awshandler.InitObjectStoreConnection(endpoint, accessKeyID, secretAccessKey, region); err != nil {
+	var (
+		objectStore awsutils.ObjectStore
+		secretData  map[string][]byte
+	)
+
+	if !primary && obsi.SecondaryChannelSecret != nil {
+		secretData = obsi.SecondaryChannelSecret.Data
+	} else if primary && obsi.ChannelSecret != nil {
+		secretData = obsi.ChannelSecret.Data
+	}
+
+	switch {
+	case gcs.IsGCSPathname(channel.Spec.Pathname):
+		gcsHandler := &gcs.Handler{}
+		err = gcsHandler.InitObjectStoreConnection(settings.Endpoint, secretData[gcs.SecretMapKeyServiceAccountKey])
+		objectStore = gcsHandler
+	case azure.IsAzureChannel(channel.Spec.Pathname, secretData):
+		azureHandler := &azure.Handler{}
+		err = azureHandler.InitObjectStoreConnection(settings.Endpoint,
+			string(secretData[azure.SecretMapKeyConnectionString]),
+			string(secretData[azure.SecretMapKeyManagedIdentityClientID]))
+		objectStore = azureHandler
+	default:
+		awshandler := &awsutils.Handler{}
+		err = awshandler.InitObjectStore(settings)
+		objectStore = awshandler
+	}
+
+	if err != nil {
 		klog.Error(err, "unable initialize object store settings")
 		return err
 	}
 	// Check whether the connection is setup successfully
-	if err := awshandler.Exists(obsi.bucket); err != nil {
+	if err := objectStore.Exists(obsi.bucket); err != nil {
 		klog.Error(err, "Unable to access object store bucket ", obsi.bucket, " for channel ", obsi.Channel.Name)
 		return err
 	}
 
-	obsi.objectStore = awshandler
+	obsi.objectStore = objectStore
 
 	return nil
 }
 
-func (obsi *SubscriberItem) initObjectStore() error {
+// initObjectStore connects to the bucket of the primary channel, or of the secondary channel if it fails, and returns
+// the channel connected to
+func (obsi *SubscriberItem) initObjectStore() (*chnv1.Channel, error) {
 	// Get AWS handler with the primary channel first
 	err := obsi.getAwsHandler(true)
 
 	if err != nil {
 		if obsi.SecondaryChannel == nil {
-			return err
+			return nil, err
 		}
 
 		klog.Warning("failed to connect with the primary channel, err: " + err.Error())
@@ -229,16 +282,21 @@ func (obsi *SubscriberItem) initObjectStore() error {
 
 		if err2 != nil {
 			klog.Error("failed to connect with the secondary channel, err: " + err2.Error())
-			return err2
+			return nil, err2
 		}
+
+		return obsi.SecondaryChannel, nil
 	}
 
-	return nil
+	return obsi.Channel, nil
 }
 
 func (obsi *SubscriberItem) doSubscriptionWithRetries(retryInterval time.Duration, retries int) {
 	obsi.doSubscription()
 
+	utils.UpdateOutOfSyncStatus(obsi.synchronizer.GetLocalClient(), obsi.Subscription, obsi.successful)
+	obsi.saveState()
+
 	// If the initial subscription fails, retry.
 	n := 0
 
@@ -247,6 +305,8 @@ func (obsi *SubscriberItem) doSubscriptionWithRetries(retryInterval time.Duratio
 			time.Sleep(retryInterval)
 			klog.Infof("Re-try #%d: subcribing to the object bucket: %v", n+1, obsi.bucket)
 			obsi.doSubscription()
+			utils.UpdateOutOfSyncStatus(obsi.synchronizer.GetLocalClient(), obsi.Subscription, obsi.successful)
+			obsi.saveState()
 			n++
 		} else {
 			break
@@ -254,9 +314,20 @@ func (obsi *SubscriberItem) doSubscriptionWithRetries(retryInterval time.Duratio
 	}
 }
 
-func (obsi *SubscriberItem) doSubscription() {
-	var folderName *string
+// saveState saves the subscriber state of the item. The objects are listed and applied on every sync, the state is
+// not restored.
+func (obsi *SubscriberItem) saveState() {
+	state := &appv1.SubscriberState{
+		Revision:   obsi.revision,
+		Generation: obsi.Subscription.Generation,
+		Successful: obsi.successful,
+		Inventory:  obsi.inventory,
+	}
 
+	obsi.savedState = utils.SaveSubscriberState(obsi.synchronizer.GetLocalClient(), obsi.Subscription, state, obsi.savedState)
+}
+
+func (obsi *SubscriberItem) doSubscription() {
 	//Update the secret and config map
 	if obsi.Channel != nil {
 		sec, cm := utils.FetchChannelReferences(obsi.synchronizer.GetRemoteNonCachedClient(), *obsi.Channel)
@@ -314,7 +385,7 @@ func (obsi *SubscriberItem) doSubscription() {
 		}
 	}
 
-	err := obsi.initObjectStore()
+	channel, err := obsi.initObjectStore()
 
 	if err != nil {
 		klog.Errorf("Unable to initialize object store connection for subscription. sub: %v, channel: %v, err: %v ", obsi.Subscription.Name, obsi.Channel.Name, err)
@@ -323,14 +394,19 @@ func (obsi *SubscriberItem) doSubscription() {
 		return
 	}
 
-	annotations := obsi.Subscription.GetAnnotations()
-	bucketPath := annotations[appv1.AnnotationBucketPath]
+	keyFilter, err := awsutils.GetObjectKeyFilter(channel, obsi.Subscription)
+	if err != nil {
+		klog.Error(err)
+		utils.UpdateFailureReasonStatus(obsi.synchronizer.GetLocalClient(), obsi.Subscription, ReasonInvalidKeyFilter,
+			err.Error())
+		obsi.successful = false
 
-	if bucketPath != "" {
-		folderName = &bucketPath
+		return
 	}
 
-	keys, err := obsi.objectStore.List(obsi.bucket, folderName)
+	utils.UpdateFailureReasonStatus(obsi.synchronizer.GetLocalClient(), obsi.Subscription, ReasonInvalidKeyFilter, "")
+
+	keys, err := obsi.objectStore.List(obsi.bucket, keyFilter.ListPrefix())
 	klog.Infof("object keys: %v", keys)
 
 	if err != nil {
@@ -340,8 +416,13 @@ func (obsi *SubscriberItem) doSubscription() {
 		return
 	}
 
+	keys = keyFilter.FilterKeys(keys)
+
 	tpls := []unstructured.Unstructured{}
 
+	// the contents of the listed objects, for the source revision
+	objects := map[string][]byte{}
+
 	// converting template from obeject store to DPL
 	for _, key := range keys {
 		tplb, err := obsi.objectStore.Get(obsi.bucket, key)
@@ -352,6 +433,8 @@ func (obsi *SubscriberItem) doSubscription() {
 			return
 		}
 
+		objects[key] = tplb.Content
+
 		// skip empty body object store
 		if len(tplb.Content) == 0 {
 			continue
@@ -375,6 +458,8 @@ func (obsi *SubscriberItem) doSubscription() {
 	// track if there's any error when doSubscribeManifest, if there's any, then we should retry this
 	var doErr error
 
+	obsi.namespaceContainment = utils.GetNamespaceContainment(obsi.synchronizer.GetLocalClient(), obsi.Subscription.Namespace)
+
 	for _, tpl := range tpls {
 		tpl := tpl
 		resource, err := obsi.doSubscribeManifest(&tpl) // this is now the address of the inner tpl
@@ -400,12 +485,30 @@ func (obsi *SubscriberItem) doSubscription() {
 		return
 	}
 
+	source := &appv1.SubscriptionSource{
+		Channel: channel.Namespace + "/" + channel.Name,
+		URL:     channel.Spec.Pathname,
+	}
+
+	revision := utils.GetObjectsRevision(objects)
+
+	utils.UpdateSourceStatus(obsi.synchronizer.GetLocalClient(), obsi.Subscription, source, revision)
+	obsi.synchronizer.RecordSyncAudit(obsi.Subscription, revision)
+
 	if doErr != nil {
 		obsi.successful = false
 
 		return
 	}
 
+	obsi.revision = revision
+	obsi.inventory = []string{}
+
+	for _, resource := range resources {
+		obsi.inventory = append(obsi.inventory, utils.GetInventoryKey(resource.Resource.GetAPIVersion(),
+			resource.Resource.GetKind(), resource.Resource.GetNamespace(), resource.Resource.GetName()))
+	}
+
 	obsi.successful = true
 }
 
@@ -414,14 +517,14 @@ func (obsi *SubscriberItem) doSubscribeManifest(template *unstructured.Unstructu
 	// Set app label
 	utils.SetPartOfLabel(obsi.SubscriberItem.Subscription, template)
 
-	if obsi.Subscription.Spec.PackageFilter != nil {
-		if obsi.Subscription.Spec.Package != "" && obsi.Subscription.Spec.Package != tplName {
-			errmsg := "Name does not match, skiping:" + obsi.Subscription.Spec.Package + "|" + tplName
-			klog.Info(errmsg)
+	if !utils.IsSubscribedPackage(obsi.Subscription, tplName) {
+		errmsg := "Name does not match, skiping:" + obsi.Subscription.Spec.Package + "|" + tplName
+		klog.Info(errmsg)
 
-			return nil, errors.New(errmsg)
-		}
+		return nil, errors.New(errmsg)
+	}
 
+	if obsi.Subscription.Spec.PackageFilter != nil {
 		if !utils.LabelChecker(obsi.Subscription.Spec.PackageFilter.LabelSelector, template.GetLabels()) {
 			errmsg := "Failed to pass label check to deployable " + tplName
 			klog.Info(errmsg)
@@ -486,6 +589,12 @@ func (obsi *SubscriberItem) doSubscribeManifest(template *unstructured.Unstructu
 		template.SetAnnotations(rscAnnotations)
 	}
 
+	if err := utils.ContainResourceNamespace(template, obsi.Subscription.Namespace, obsi.namespaceContainment); err != nil {
+		klog.Info(err.Error())
+
+		return nil, err
+	}
+
 	if obsi.clusterAdmin {
 		klog.Info("cluster-admin is true.")
 
@@ -495,6 +604,8 @@ func (obsi *SubscriberItem) doSubscribeManifest(template *unstructured.Unstructu
 			klog.Info("Setting it to subscription namespace " + obsi.Subscription.Namespace)
 			template.SetNamespace(obsi.Subscription.Namespace)
 		}
+	} else if utils.PreservesResourceNamespace(obsi.Subscription, template) && obsi.synchronizer.CanDeployResource(obsi.Subscription, template) {
+		klog.Info("No cluster-admin. Preserving the resource namespace " + template.GetNamespace())
 	} else {
 		klog.Info("No cluster-admin. Setting it to subscription namespace " + obsi.Subscription.Namespace)
 		template.SetNamespace(obsi.Subscription.Namespace)
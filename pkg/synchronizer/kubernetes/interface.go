@@ -0,0 +1,132 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// SyncSource is the interface of the synchronizer for the subscribers, the controllers and the operators embedding
+// it. Use it instead of the fields of KubeSynchronizer, which may change.
+type SyncSource interface {
+	// GetInterval returns the resync interval in seconds
+	GetInterval() int
+	// GetClusterName returns the name of the cluster the resources are applied to
+	GetClusterName() string
+
+	GetLocalClient() client.Client
+	GetLocalNonCachedClient() client.Client
+	GetRemoteClient() client.Client
+	GetRemoteNonCachedClient() client.Client
+
+	IsResourceNamespaced(*unstructured.Unstructured) bool
+	// CanDeployResource returns true if the user identity of the appsub can get, create and update the resource in
+	// its namespace
+	CanDeployResource(appsub *appv1alpha1.Subscription, rsc *unstructured.Unstructured) bool
+
+	// ProcessSubResources applies the resources of the appsub and deletes the ones it no longer subscribes
+	ProcessSubResources(appsub *appv1alpha1.Subscription, resources []ResourceUnit,
+		allowlist, denyList map[string]map[string]string, isAdmin bool) error
+	// ResyncSubResources force re-applies the package requested by the resync-package annotation of the appsub
+	ResyncSubResources(appsub *appv1alpha1.Subscription, resources []ResourceUnit,
+		allowlist, denyList map[string]map[string]string, isAdmin bool) error
+	// PurgeAllSubscribedResources deletes all the resources deployed by the appsub
+	PurgeAllSubscribedResources(appsub *appv1alpha1.Subscription) error
+	// SyncAppsubClusterStatus reports the package statuses of the appsub on the cluster
+	SyncAppsubClusterStatus(appsub *appv1alpha1.Subscription, appsubClusterStatus SubscriptionClusterStatus,
+		skipOrphanDelete *bool, skipUpdate *bool) error
+
+	// RecordSyncAudit records the revision of the channel content of the last sync of the appsub, with the resources
+	// it applied, in the sync audit config map of the appsub
+	RecordSyncAudit(appsub *appv1alpha1.Subscription, revision string)
+
+	// SetSkipAppSubStatusResDel sets whether the resources missing from the appsub status are kept
+	SetSkipAppSubStatusResDel(skip bool)
+	// AddApplyHook adds a hook called around each resource applied. The hooks are added before the synchronizer
+	// starts processing appsubs.
+	AddApplyHook(hook ApplyHook)
+	// AddManifestMutator adds a mutator of the rendered resources of each appsub sync, called in the order they are
+	// added. The mutators are added before the synchronizer starts processing appsubs.
+	AddManifestMutator(mutator ManifestMutator)
+	// SetSecretScanner sets the scanner flagging the resources of the appsubs with inline credentials before they are
+	// mutated and applied. The scanner is set before the synchronizer starts processing appsubs.
+	SetSecretScanner(scanner *SecretScanner)
+	// AddConflictStrategy adds a strategy selected by the appsubs with the conflict-strategy annotation set to its
+	// name, in place of the built-in fail, overwrite, merge and skip strategies. The strategies are added before the
+	// synchronizer starts processing appsubs.
+	AddConflictStrategy(name string, strategy ConflictStrategy)
+}
+
+// ApplyHook is called before and after the synchronizer applies a resource of an appsub to a cluster
+type ApplyHook interface {
+	// PreApply is called with the resource to apply, after the package overrides. An error skips the resource and
+	// is reported as its deploy failure.
+	PreApply(hostSub types.NamespacedName, cluster string, resource *unstructured.Unstructured) error
+	// PostApply is called with the result of applying the resource
+	PostApply(hostSub types.NamespacedName, cluster string, resource *unstructured.Unstructured, err error)
+}
+
+// ManifestMutator modifies the rendered resources of an appsub before the synchronizer applies them to a cluster
+type ManifestMutator interface {
+	// Mutate returns the resources to apply in place of the rendered ones, which may be modified, dropped or
+	// completed with new resources. An error fails the sync of the appsub, nothing is applied nor deleted.
+	Mutate(hostSub types.NamespacedName, cluster string, resources []ResourceUnit) ([]ResourceUnit, error)
+}
+
+// ConflictKind is the kind of conflict an apply hits
+type ConflictKind string
+
+const (
+	// ConflictOwnership is an existing resource owned by another appsub or by no appsub
+	ConflictOwnership ConflictKind = "Ownership"
+	// ConflictImmutable is an update rejected for changing an immutable field of the existing resource
+	ConflictImmutable ConflictKind = "Immutable"
+)
+
+// Conflict is an apply of a resource of an appsub hitting an existing resource
+type Conflict struct {
+	Kind     ConflictKind
+	HostSub  types.NamespacedName
+	Existing *unstructured.Unstructured
+	Desired  *unstructured.Unstructured
+	// Err is the update error of the immutable conflicts
+	Err error
+}
+
+// ConflictResolution is how the synchronizer goes on with an apply in conflict
+type ConflictResolution string
+
+const (
+	// ResolutionFail fails the resource
+	ResolutionFail ConflictResolution = "Fail"
+	// ResolutionOverwrite replaces the existing resource, which is deleted and created again on immutable conflicts
+	ResolutionOverwrite ConflictResolution = "Overwrite"
+	// ResolutionMerge patches the existing resource, the resources owned by others are not taken over
+	ResolutionMerge ConflictResolution = "Merge"
+	// ResolutionSkip leaves the existing resource as it is, the package is reported as skipped
+	ResolutionSkip ConflictResolution = "Skip"
+)
+
+// ConflictStrategy resolves the conflicts of the applies of an appsub. The ownership conflicts of the appsubs not
+// created by a cluster admin are only resolved by ResolutionFail and ResolutionSkip, the others are failed.
+type ConflictStrategy interface {
+	Resolve(conflict Conflict) ConflictResolution
+}
+
+var _ SyncSource = &KubeSynchronizer{}
@@ -0,0 +1,81 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// recordingHook records the resources it is called with and rejects the one named reject
+type recordingHook struct {
+	reject  string
+	pre     []string
+	post    []string
+	cluster string
+}
+
+func (h *recordingHook) PreApply(hostSub types.NamespacedName, cluster string, resource *unstructured.Unstructured) error {
+	h.pre = append(h.pre, resource.GetName())
+	h.cluster = cluster
+
+	if resource.GetName() == h.reject {
+		return errors.New("rejected by the hook")
+	}
+
+	return nil
+}
+
+func (h *recordingHook) PostApply(hostSub types.NamespacedName, cluster string, resource *unstructured.Unstructured,
+	err error) {
+	if err == nil {
+		h.post = append(h.post, resource.GetName())
+	}
+}
+
+func TestApplyHooks(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	env, err := newScaleEnv(1, 3)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	var syncSource SyncSource = env.sync
+
+	hook := &recordingHook{reject: "appsub-0-cm-1"}
+	syncSource.AddApplyHook(hook)
+
+	g.Expect(syncSource.ProcessSubResources(env.appsubs[0], copyResourceUnits(env.resources[0]), nil, nil, false)).
+		To(gomega.Succeed())
+
+	g.Expect(hook.cluster).To(gomega.Equal("cluster1"))
+	g.Expect(hook.pre).To(gomega.ConsistOf("appsub-0-cm-0", "appsub-0-cm-1", "appsub-0-cm-2"))
+	g.Expect(hook.post).To(gomega.ConsistOf("appsub-0-cm-0", "appsub-0-cm-2"))
+
+	// the rejected resource is not applied
+	configMaps := env.dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+	_, err = configMaps.Namespace(scaleNamespace).Get(context.TODO(), "appsub-0-cm-1", metav1.GetOptions{})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	_, err = configMaps.Namespace(scaleNamespace).Get(context.TODO(), "appsub-0-cm-0", metav1.GetOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+}
@@ -256,14 +256,7 @@ func (sync *KubeSynchronizer) SyncAppsubClusterStatus(appsub *appv1.Subscription
 		}
 
 		// Check if there are any package failures
-		deployFailed := false
-
-		for _, resource := range appsubClusterStatus.SubscriptionPackageStatus {
-			if v1alpha1.PackagePhase(resource.Phase) == v1alpha1.PackageDeployFailed {
-				deployFailed = true
-				break
-			}
-		}
+		deployFailed := hasFailedPackage(appsubClusterStatus.SubscriptionPackageStatus)
 
 		// Update result in cluster AppsubReport
 		if err := updateAppsubReportResult(sync.RemoteClient, appsubClusterStatus.AppSub.Namespace,
@@ -341,14 +334,7 @@ func (sync *KubeSynchronizer) SyncAppsubClusterStatus(appsub *appv1.Subscription
 				}
 
 				// Check if there are any package failures
-				deployFailed := false
-
-				for _, resource := range appsubClusterStatus.SubscriptionPackageStatus {
-					if v1alpha1.PackagePhase(resource.Phase) == v1alpha1.PackageDeployFailed {
-						deployFailed = true
-						break
-					}
-				}
+				deployFailed := hasFailedPackage(appsubClusterStatus.SubscriptionPackageStatus)
 
 				// Update result in cluster AppsubReport
 				if err := updateAppsubReportResult(sync.RemoteClient, appsubClusterStatus.AppSub.Namespace,
@@ -606,6 +592,21 @@ func getClusterAppsubReport(rClient client.Client, clusterAppsubReportNs string,
 	return appsubReport, nil
 }
 
+// hasFailedPackage returns true if any package failed to deploy, is deployed but unhealthy, or waits for its operator
+// or for its previous sync waves
+func hasFailedPackage(pkgStatuses []SubscriptionUnitStatus) bool {
+	for _, resource := range pkgStatuses {
+		if v1alpha1.PackagePhase(resource.Phase) == v1alpha1.PackageDeployFailed ||
+			v1alpha1.PackagePhase(resource.Phase) == v1alpha1.PackageUnhealthy ||
+			v1alpha1.PackagePhase(resource.Phase) == v1alpha1.PackageWaitingForOperator ||
+			v1alpha1.PackagePhase(resource.Phase) == v1alpha1.PackageWaitingForWave {
+			return true
+		}
+	}
+
+	return false
+}
+
 func shouldSkip(appsubClusterStatus SubscriptionClusterStatus, foundPkgStatus bool,
 	pkgstatus v1alpha1.SubscriptionStatus) bool {
 	if len(appsubClusterStatus.SubscriptionPackageStatus) == 1 &&
@@ -0,0 +1,235 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	appSubStatusV1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1alpha1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+const (
+	// SyncAuditSuffix is appended to the name of the appsub to name its sync audit config map
+	SyncAuditSuffix = "-sync-audit"
+	// syncAuditKeyFormat names the records of the sync audit config map after their time, in chronological order
+	syncAuditKeyFormat = "20060102T150405.000000000Z"
+	// syncAuditMaxSize keeps the sync audit config maps under the 1MiB limit of the config maps
+	syncAuditMaxSize = 768 * 1024
+)
+
+// syncAuditRetention is the number of syncs kept in the sync audit config map of each appsub, 0 disables the audit
+var syncAuditRetention int
+
+// SetSyncAuditRetention sets the number of syncs recorded in the sync audit config map of each appsub, 0 disables
+// the audit
+func SetSyncAuditRetention(retention int) {
+	if retention > 0 {
+		klog.Infof("The last %d syncs of each appsub are recorded in its sync audit config map", retention)
+	}
+
+	syncAuditRetention = retention
+}
+
+// SyncAuditRecord is a sync of an appsub, in its sync audit config map
+type SyncAuditRecord struct {
+	Time    metav1.Time `json:"time"`
+	Cluster string      `json:"cluster"`
+	// Revision is the revision of the channel content applied, the commit for a Git repository
+	Revision string `json:"revision"`
+	// User and Groups are the identity of the user who last created or updated the appsub
+	User   string `json:"user,omitempty"`
+	Groups string `json:"groups,omitempty"`
+	// Resources are the <apiVersion>/<kind>/<namespace>/<name> keys of the resources applied
+	Resources []string `json:"resources"`
+}
+
+// recordApplied keeps the keys of the resources applied by the last sync of the appsub, for its sync audit
+func (sync *KubeSynchronizer) recordApplied(hostSub types.NamespacedName, statuses []SubscriptionUnitStatus) {
+	if syncAuditRetention <= 0 {
+		return
+	}
+
+	applied := []string{}
+
+	for _, status := range statuses {
+		if status.Phase == string(appSubStatusV1alpha1.PackageDeployed) ||
+			status.Phase == string(appSubStatusV1alpha1.PackageUnhealthy) {
+			applied = append(applied, strings.Join([]string{status.APIVersion, status.Kind, status.Namespace, status.Name}, "/"))
+		}
+	}
+
+	sort.Strings(applied)
+
+	sync.rmtx.Lock()
+	defer sync.rmtx.Unlock()
+
+	if sync.applied == nil {
+		sync.applied = map[types.NamespacedName][]string{}
+	}
+
+	sync.applied[hostSub] = applied
+}
+
+// RecordSyncAudit records the revision of the last sync of the appsub, with the resources it applied and the user of
+// the appsub, in the sync audit config map of the appsub. A sync applying the same revision and resources as the
+// previous record is not recorded again. The oldest records are dropped beyond the retention.
+func (sync *KubeSynchronizer) RecordSyncAudit(appsub *appv1alpha1.Subscription, revision string) {
+	if syncAuditRetention <= 0 {
+		return
+	}
+
+	hostSub := types.NamespacedName{Namespace: appsub.GetNamespace(), Name: appsub.GetName()}
+
+	sync.rmtx.Lock()
+	applied, ok := sync.applied[hostSub]
+	sync.rmtx.Unlock()
+
+	if !ok {
+		return
+	}
+
+	annotations := appsub.GetAnnotations()
+
+	record := SyncAuditRecord{
+		Time:      metav1.Now(),
+		Cluster:   sync.GetClusterName(),
+		Revision:  revision,
+		User:      utils.Base64StringDecode(annotations[appv1alpha1.AnnotationUserIdentity]),
+		Groups:    utils.Base64StringDecode(annotations[appv1alpha1.AnnotationUserGroup]),
+		Resources: applied,
+	}
+
+	cm := &corev1.ConfigMap{}
+	cmKey := types.NamespacedName{Namespace: hostSub.Namespace, Name: hostSub.Name + SyncAuditSuffix}
+
+	err := sync.LocalNonCachedClient.Get(context.TODO(), cmKey, cm)
+	if err != nil && !errors.IsNotFound(err) {
+		klog.Warningf("failed to get the sync audit of appsub %v, err: %v", hostSub, err)
+
+		return
+	}
+
+	exists := err == nil
+
+	if last, ok := getLastSyncAuditRecord(cm); ok && last.Revision == record.Revision &&
+		reflect.DeepEqual(last.Resources, record.Resources) {
+		return
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		klog.Warningf("failed to record the sync audit of appsub %v, err: %v", hostSub, err)
+
+		return
+	}
+
+	if !exists {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      cmKey.Name,
+				Namespace: cmKey.Namespace,
+				// the audit is deleted with the appsub
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: appv1alpha1.SchemeGroupVersion.String(),
+					Kind:       "Subscription",
+					Name:       appsub.GetName(),
+					UID:        appsub.GetUID(),
+				}},
+			},
+		}
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+
+	cm.Data[record.Time.UTC().Format(syncAuditKeyFormat)] = string(data)
+	trimSyncAudit(cm.Data, syncAuditRetention)
+
+	if exists {
+		err = sync.LocalNonCachedClient.Update(context.TODO(), cm)
+	} else {
+		err = sync.LocalNonCachedClient.Create(context.TODO(), cm)
+	}
+
+	if err != nil {
+		klog.Warningf("failed to record the sync audit of appsub %v, err: %v", hostSub, err)
+
+		return
+	}
+
+	klog.Infof("recorded the sync of revision %v of appsub %v, %d resources", revision, hostSub, len(applied))
+}
+
+// getLastSyncAuditRecord returns the latest record of the sync audit config map
+func getLastSyncAuditRecord(cm *corev1.ConfigMap) (SyncAuditRecord, bool) {
+	record := SyncAuditRecord{}
+
+	keys := getSyncAuditKeys(cm.Data)
+	if len(keys) == 0 {
+		return record, false
+	}
+
+	if err := json.Unmarshal([]byte(cm.Data[keys[len(keys)-1]]), &record); err != nil {
+		return record, false
+	}
+
+	return record, true
+}
+
+// trimSyncAudit drops the oldest records beyond the retention or the size limit, the latest one is always kept
+func trimSyncAudit(data map[string]string, retention int) {
+	keys := getSyncAuditKeys(data)
+
+	size := 0
+	for _, key := range keys {
+		size += len(key) + len(data[key])
+	}
+
+	for len(keys) > 1 && (len(keys) > retention || size > syncAuditMaxSize) {
+		size -= len(keys[0]) + len(data[keys[0]])
+
+		delete(data, keys[0])
+		keys = keys[1:]
+	}
+}
+
+// getSyncAuditKeys returns the keys of the records of the sync audit config map, oldest first
+func getSyncAuditKeys(data map[string]string) []string {
+	keys := []string{}
+
+	for key := range data {
+		if _, err := time.Parse(syncAuditKeyFormat, key); err == nil {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
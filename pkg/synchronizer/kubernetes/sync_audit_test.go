@@ -0,0 +1,91 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestRecordSyncAudit(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	SetSyncAuditRetention(2)
+	defer SetSyncAuditRetention(0)
+
+	env, err := newScaleEnv(1, 2)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	appsub := env.appsubs[0]
+	appsub.SetAnnotations(map[string]string{
+		appv1.AnnotationUserIdentity: base64.StdEncoding.EncodeToString([]byte("alice")),
+	})
+
+	// no sync to record yet
+	env.sync.RecordSyncAudit(appsub, "c0ffee")
+
+	cm := &corev1.ConfigMap{}
+	cmKey := types.NamespacedName{Namespace: appsub.Namespace, Name: appsub.Name + SyncAuditSuffix}
+	g.Expect(env.sync.LocalClient.Get(context.TODO(), cmKey, cm)).NotTo(gomega.Succeed())
+
+	g.Expect(env.sync.ProcessSubResources(appsub, copyResourceUnits(env.resources[0]), nil, nil, false)).To(gomega.Succeed())
+
+	getRecords := func() (int, SyncAuditRecord) {
+		cm := &corev1.ConfigMap{}
+		g.Expect(env.sync.LocalClient.Get(context.TODO(), cmKey, cm)).To(gomega.Succeed())
+
+		last, ok := getLastSyncAuditRecord(cm)
+		g.Expect(ok).To(gomega.BeTrue())
+
+		return len(getSyncAuditKeys(cm.Data)), last
+	}
+
+	env.sync.RecordSyncAudit(appsub, "c0ffee")
+
+	count, last := getRecords()
+	g.Expect(count).To(gomega.Equal(1))
+	g.Expect(last.Revision).To(gomega.Equal("c0ffee"))
+	g.Expect(last.Cluster).To(gomega.Equal("cluster1"))
+	g.Expect(last.User).To(gomega.Equal("alice"))
+	g.Expect(last.Resources).To(gomega.Equal([]string{
+		"v1/ConfigMap/" + scaleNamespace + "/appsub-0-cm-0",
+		"v1/ConfigMap/" + scaleNamespace + "/appsub-0-cm-1",
+	}))
+
+	// the same sync is recorded once
+	env.sync.RecordSyncAudit(appsub, "c0ffee")
+
+	count, _ = getRecords()
+	g.Expect(count).To(gomega.Equal(1))
+
+	// the oldest syncs are dropped beyond the retention
+	env.sync.RecordSyncAudit(appsub, "decaf")
+	env.sync.RecordSyncAudit(appsub, "beef")
+
+	count, last = getRecords()
+	g.Expect(count).To(gomega.Equal(2))
+	g.Expect(last.Revision).To(gomega.Equal("beef"))
+
+	data := map[string]string{"20261015T120000.000000000Z": "old", "20261015T130000.000000000Z": "new", "other": "kept"}
+	trimSyncAudit(data, 1)
+	g.Expect(data).To(gomega.Equal(map[string]string{"20261015T130000.000000000Z": "new", "other": "kept"}))
+}
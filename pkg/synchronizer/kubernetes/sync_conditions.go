@@ -0,0 +1,140 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog/v2"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+var clusterClaimGVR = schema.GroupVersionResource{
+	Group:    "cluster.open-cluster-management.io",
+	Version:  "v1alpha1",
+	Resource: "clusterclaims",
+}
+
+// filterByClusterConditions drops the resources whose package conditions are not met by the cluster, so they are
+// not registered and get removed like any other resource no longer subscribed. If the cluster facts can't be read or
+// a condition is invalid, an error is returned and the sync is aborted, rather than removing the resources.
+func (sync *KubeSynchronizer) filterByClusterConditions(appsub *appv1alpha1.Subscription,
+	resources []ResourceUnit) ([]ResourceUnit, error) {
+	var facts *utils.ClusterFacts
+
+	filtered := []ResourceUnit{}
+
+	for _, resource := range resources {
+		if !utils.HasPackageCondition(appsub, resource.Resource) {
+			filtered = append(filtered, resource)
+
+			continue
+		}
+
+		if facts == nil {
+			var err error
+
+			facts, err = sync.getClusterFacts()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the cluster facts to evaluate the package conditions, err: %w", err)
+			}
+		}
+
+		met, reason, err := utils.IsPackageConditionMet(appsub, resource.Resource, facts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate the package condition of %v %v/%v, err: %w", resource.Resource.GetKind(),
+				resource.Resource.GetNamespace(), resource.Resource.GetName(), err)
+		}
+
+		if !met {
+			klog.Infof("skip %v %v/%v of appsub %v/%v, %v", resource.Resource.GetKind(), resource.Resource.GetNamespace(),
+				resource.Resource.GetName(), appsub.Namespace, appsub.Name, reason)
+
+			continue
+		}
+
+		filtered = append(filtered, resource)
+	}
+
+	return filtered, nil
+}
+
+// getClusterFacts returns the cluster claims and the Kubernetes version of the local cluster
+func (sync *KubeSynchronizer) getClusterFacts() (*utils.ClusterFacts, error) {
+	claims := map[string]string{}
+
+	claimList, err := sync.DynamicClient.Resource(clusterClaimGVR).List(context.TODO(), metav1.ListOptions{})
+
+	switch {
+	case err == nil:
+		for _, claim := range claimList.Items {
+			value, _, _ := unstructured.NestedString(claim.Object, "spec", "value")
+			claims[claim.GetName()] = value
+		}
+	case errors.IsNotFound(err) || meta.IsNoMatchError(err):
+		// not a managed cluster, there is no cluster claim
+		klog.V(1).Info("cluster claims are not available on the cluster")
+	default:
+		return nil, err
+	}
+
+	dc, err := discovery.NewDiscoveryClientForConfig(sync.localConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := dc.ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.NewClusterFacts(claims, info.GitVersion)
+}
+
+// substituteClusterVariables replaces the cluster variables in the resources of the appsubs enabling them. The
+// HelmRelease resources get them in their values, so the charts are rendered with them too.
+func (sync *KubeSynchronizer) substituteClusterVariables(appsub *appv1alpha1.Subscription,
+	resources []ResourceUnit) ([]ResourceUnit, error) {
+	if !utils.HasClusterVariables(appsub) {
+		return resources, nil
+	}
+
+	facts, err := sync.getClusterFacts()
+	if err != nil {
+		return nil, err
+	}
+
+	vars := utils.GetClusterVariables(facts)
+	substituted := make([]ResourceUnit, 0, len(resources))
+
+	for _, resource := range resources {
+		if resource.Resource != nil {
+			resource.Resource = utils.SubstituteClusterVariables(resource.Resource, vars)
+		}
+
+		substituted = append(substituted, resource)
+	}
+
+	return substituted, nil
+}
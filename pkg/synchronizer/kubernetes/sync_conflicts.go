@@ -0,0 +1,205 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/eventstream"
+)
+
+// conflictStrategy is a built-in strategy, resolving all the conflicts the same way
+type conflictStrategy ConflictResolution
+
+func (s conflictStrategy) Resolve(conflict Conflict) ConflictResolution {
+	return ConflictResolution(s)
+}
+
+var builtinConflictStrategies = map[string]ConflictStrategy{
+	appv1alpha1.ConflictStrategyFail:      conflictStrategy(ResolutionFail),
+	appv1alpha1.ConflictStrategyOverwrite: conflictStrategy(ResolutionOverwrite),
+	appv1alpha1.ConflictStrategyMerge:     conflictStrategy(ResolutionMerge),
+	appv1alpha1.ConflictStrategySkip:      conflictStrategy(ResolutionSkip),
+}
+
+// getConflictStrategy returns the strategy selected by the conflict-strategy annotation of the appsub, nil without
+// annotation. The appsubs without strategy keep the reconcile options of the cluster-admin appsubs, and ignore the
+// immutable field errors. An unknown strategy fails the conflicts.
+func (sync *KubeSynchronizer) getConflictStrategy(appsub *appv1alpha1.Subscription) ConflictStrategy {
+	name := strings.ToLower(strings.TrimSpace(appsub.GetAnnotations()[appv1alpha1.AnnotationConflictStrategy]))
+	if name == "" {
+		return nil
+	}
+
+	if strategy, ok := sync.conflictStrategies[name]; ok {
+		return strategy
+	}
+
+	if strategy, ok := builtinConflictStrategies[name]; ok {
+		return strategy
+	}
+
+	klog.Errorf("unknown conflict strategy %v of appsub %v/%v, failing the conflicts", name, appsub.Namespace, appsub.Name)
+
+	return builtinConflictStrategies[appv1alpha1.ConflictStrategyFail]
+}
+
+// resolveConflict resolves the conflict with the strategy. Only the cluster-admin appsubs take over, or patch, the
+// resources owned by others.
+func resolveConflict(strategy ConflictStrategy, conflict Conflict, isAdmin bool) ConflictResolution {
+	resolution := strategy.Resolve(conflict)
+
+	if conflict.Kind == ConflictOwnership && !isAdmin &&
+		(resolution == ResolutionOverwrite || resolution == ResolutionMerge) {
+		klog.Infof("appsub %v is not a cluster-admin appsub, failing the %v conflict of %v %v/%v instead of %v",
+			conflict.HostSub, conflict.Kind, conflict.Desired.GetKind(), conflict.Desired.GetNamespace(),
+			conflict.Desired.GetName(), resolution)
+
+		return ResolutionFail
+	}
+
+	klog.Infof("%v conflict of %v %v/%v of appsub %v resolved by %v", conflict.Kind, conflict.Desired.GetKind(),
+		conflict.Desired.GetNamespace(), conflict.Desired.GetName(), conflict.HostSub, resolution)
+
+	return resolution
+}
+
+// resolveImmutableConflict goes on with an update rejected for changing an immutable field of the existing resource.
+// The merge resolution fails it, a patch can't change an immutable field either. The resources with the
+// recreate-on-immutable annotation are recreated whatever the strategy, which may be nil.
+func (sync *KubeSynchronizer) resolveImmutableConflict(ri dynamic.ResourceInterface, strategy ConflictStrategy,
+	conflict Conflict, isAdmin bool) error {
+	resolution := ResolutionOverwrite
+	if !recreateOnImmutable(conflict.Desired) {
+		resolution = resolveConflict(strategy, conflict, isAdmin)
+	}
+
+	switch resolution {
+	case ResolutionOverwrite:
+		uid := conflict.Existing.GetUID()
+		propagation := metav1.DeletePropagationBackground
+
+		err := ri.Delete(context.TODO(), conflict.Existing.GetName(), metav1.DeleteOptions{
+			Preconditions:     &metav1.Preconditions{UID: &uid},
+			PropagationPolicy: &propagation,
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			klog.Errorf("failed to delete %v %v/%v to recreate it, err: %v", conflict.Existing.GetKind(),
+				conflict.Existing.GetNamespace(), conflict.Existing.GetName(), err)
+
+			return err
+		}
+
+		// a resource with finalizers is still terminating, it is created again by a later sync
+		recreated := conflict.Desired.DeepCopy()
+		recreated.SetResourceVersion("")
+
+		if _, err = ri.Create(context.TODO(), recreated, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+
+		sync.recordRecreated(conflict)
+
+		return nil
+	case ResolutionSkip:
+		return &conflictSkippedError{conflict: conflict}
+	default:
+		return conflict.Err
+	}
+}
+
+// recreateOnImmutable returns true if the resource is recreated when its update changes an immutable field
+func recreateOnImmutable(rsc *unstructured.Unstructured) bool {
+	return strings.EqualFold(rsc.GetAnnotations()[appv1alpha1.AnnotationRecreateOnImmutable], "true")
+}
+
+// recordRecreated records a recreated resource in the event of its appsub and in the event stream, and keeps its
+// package status message until the apply takes it
+func (sync *KubeSynchronizer) recordRecreated(conflict Conflict) {
+	rsc := conflict.Desired
+	msg := fmt.Sprintf("recreated, its update changed an immutable field: %v", conflict.Err)
+
+	klog.Infof("%v %v/%v of appsub %v is %v", rsc.GetKind(), rsc.GetNamespace(), rsc.GetName(), conflict.HostSub, msg)
+
+	if sync.recreated == nil {
+		sync.recreated = map[string]string{}
+	}
+
+	sync.recreated[recreatedKey(rsc)] = msg
+
+	eventstream.PublishRecreated(conflict.HostSub, sync.GetClusterName(), rsc, msg)
+
+	if sync.eventrecorder == nil {
+		return
+	}
+
+	if appsub, err := sync.getHostingAppSub(conflict.HostSub); err == nil {
+		sync.eventrecorder.RecordEvent(appsub, "Recreated", fmt.Sprintf("%v %v/%v is %v", rsc.GetKind(),
+			rsc.GetNamespace(), rsc.GetName(), msg), nil)
+	}
+}
+
+// takeRecreated returns the status message of the resource if the apply recreated it, empty otherwise
+func (sync *KubeSynchronizer) takeRecreated(rsc *unstructured.Unstructured) string {
+	key := recreatedKey(rsc)
+
+	msg := sync.recreated[key]
+	delete(sync.recreated, key)
+
+	return msg
+}
+
+func recreatedKey(rsc *unstructured.Unstructured) string {
+	return rsc.GetAPIVersion() + "/" + rsc.GetKind() + "/" + rsc.GetNamespace() + "/" + rsc.GetName()
+}
+
+// conflictSkippedError is the apply of a resource left as it is by the ResolutionSkip of its conflict
+type conflictSkippedError struct {
+	conflict Conflict
+}
+
+func (e *conflictSkippedError) Error() string {
+	desired := e.conflict.Desired
+
+	if e.conflict.Kind == ConflictImmutable {
+		return fmt.Sprintf("%v %v/%v is skipped, its update changes an immutable field: %v", desired.GetKind(),
+			desired.GetNamespace(), desired.GetName(), e.conflict.Err)
+	}
+
+	return fmt.Sprintf("%v %v/%v is skipped, it exists and is owned by others", desired.GetKind(), desired.GetNamespace(),
+		desired.GetName())
+}
+
+// isConflictSkipped returns true if the error, or the error it wraps, is an apply skipped for its conflict
+func isConflictSkipped(err error) bool {
+	skipped := &conflictSkippedError{}
+
+	return errors.As(err, &skipped)
+}
+
+// isImmutableFieldError returns true if the update is rejected for changing an immutable field
+func isImmutableFieldError(err error) bool {
+	return apierrors.IsInvalid(err) && strings.Contains(err.Error(), "immutable")
+}
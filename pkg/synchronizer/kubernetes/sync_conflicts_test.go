@@ -0,0 +1,165 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	clienttesting "k8s.io/client-go/testing"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+type skipAllStrategy struct{}
+
+func (skipAllStrategy) Resolve(conflict Conflict) ConflictResolution {
+	return ResolutionSkip
+}
+
+func TestConflictStrategies(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	env, err := newScaleEnv(1, 1)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	appsub := env.appsubs[0]
+	hostSub := types.NamespacedName{Namespace: appsub.Namespace, Name: appsub.Name}
+
+	g.Expect(env.sync.getConflictStrategy(appsub)).To(gomega.BeNil())
+
+	appsub.SetAnnotations(map[string]string{appv1.AnnotationConflictStrategy: " Skip "})
+	g.Expect(env.sync.getConflictStrategy(appsub)).To(gomega.Equal(conflictStrategy(ResolutionSkip)))
+
+	appsub.SetAnnotations(map[string]string{appv1.AnnotationConflictStrategy: "typo"})
+	g.Expect(env.sync.getConflictStrategy(appsub)).To(gomega.Equal(conflictStrategy(ResolutionFail)))
+
+	env.sync.AddConflictStrategy("skip-all", skipAllStrategy{})
+	appsub.SetAnnotations(map[string]string{appv1.AnnotationConflictStrategy: "skip-all"})
+	g.Expect(env.sync.getConflictStrategy(appsub)).To(gomega.Equal(skipAllStrategy{}))
+
+	configMaps := env.dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+	resource := copyResourceUnits(env.resources[0])[0]
+	g.Expect(env.sync.Extension.SetHostToObject(resource.Resource, hostSub, env.sync.SynchronizerID)).To(gomega.Succeed())
+
+	// the config map of another appsub
+	existing := resource.Resource.DeepCopy()
+	existing.SetAnnotations(map[string]string{appv1.AnnotationHosting: scaleNamespace + "/other"})
+	g.Expect(unstructured.SetNestedField(existing.Object, "other", "data", "index")).To(gomega.Succeed())
+
+	_, err = configMaps.Namespace(scaleNamespace).Create(context.TODO(), existing, metav1.CreateOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	apply := func(isAdmin bool, strategy ConflictStrategy) error {
+		return env.sync.applyResource(hostSub, configMaps, true, resource, false, nil, nil, isAdmin, strategy)
+	}
+
+	getLive := func() *unstructured.Unstructured {
+		live, err := configMaps.Namespace(scaleNamespace).Get(context.TODO(), resource.Resource.GetName(), metav1.GetOptions{})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		return live
+	}
+
+	err = apply(true, nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(isConflictSkipped(err)).To(gomega.BeFalse())
+
+	err = apply(false, builtinConflictStrategies[appv1.ConflictStrategySkip])
+	g.Expect(isConflictSkipped(err)).To(gomega.BeTrue())
+
+	// only the cluster-admin appsubs merge or overwrite the resources of others
+	err = apply(false, builtinConflictStrategies[appv1.ConflictStrategyMerge])
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(isConflictSkipped(err)).To(gomega.BeFalse())
+
+	g.Expect(apply(true, builtinConflictStrategies[appv1.ConflictStrategyMerge])).To(gomega.Succeed())
+
+	live := getLive()
+	g.Expect(live.GetAnnotations()).To(gomega.HaveKeyWithValue(appv1.AnnotationHosting, scaleNamespace+"/other"))
+	g.Expect(live.Object["data"]).To(gomega.HaveKeyWithValue("index", "0"))
+
+	g.Expect(apply(true, builtinConflictStrategies[appv1.ConflictStrategyOverwrite])).To(gomega.Succeed())
+	g.Expect(getLive().GetAnnotations()).To(gomega.HaveKeyWithValue(appv1.AnnotationHosting, hostSub.String()))
+
+	// the config map is owned by the appsub now, its updates are rejected for an immutable field
+	env.dynamic.PrependReactor("patch", "configmaps", func(action clienttesting.Action) (bool, apiruntime.Object, error) {
+		return true, nil, errors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, resource.Resource.GetName(),
+			field.ErrorList{field.Forbidden(field.NewPath("data"), "field is immutable when `immutable` is set")})
+	})
+
+	g.Expect(unstructured.SetNestedField(resource.Resource.Object, "changed", "data", "index")).To(gomega.Succeed())
+
+	err = apply(false, builtinConflictStrategies[appv1.ConflictStrategySkip])
+	g.Expect(isConflictSkipped(err)).To(gomega.BeTrue())
+
+	err = apply(false, builtinConflictStrategies[appv1.ConflictStrategyFail])
+	g.Expect(errors.IsInvalid(err)).To(gomega.BeTrue())
+
+	g.Expect(apply(false, builtinConflictStrategies[appv1.ConflictStrategyOverwrite])).To(gomega.Succeed())
+
+	g.Expect(getLive().Object["data"]).To(gomega.HaveKeyWithValue("index", "changed"))
+}
+
+func TestRecreateOnImmutable(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	env, err := newScaleEnv(1, 1)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	hostSub := types.NamespacedName{Namespace: env.appsubs[0].Namespace, Name: env.appsubs[0].Name}
+	configMaps := env.dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+	resource := copyResourceUnits(env.resources[0])[0]
+	g.Expect(env.sync.Extension.SetHostToObject(resource.Resource, hostSub, env.sync.SynchronizerID)).To(gomega.Succeed())
+
+	apply := func() error {
+		return env.sync.applyResource(hostSub, configMaps, true, resource, false, nil, nil, false, nil)
+	}
+
+	g.Expect(apply()).To(gomega.Succeed())
+
+	env.dynamic.PrependReactor("patch", "configmaps", func(action clienttesting.Action) (bool, apiruntime.Object, error) {
+		return true, nil, errors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, resource.Resource.GetName(),
+			field.ErrorList{field.Forbidden(field.NewPath("data"), "field is immutable when `immutable` is set")})
+	})
+
+	g.Expect(unstructured.SetNestedField(resource.Resource.Object, "changed", "data", "index")).To(gomega.Succeed())
+
+	g.Expect(errors.IsInvalid(apply())).To(gomega.BeTrue())
+	g.Expect(env.sync.takeRecreated(resource.Resource)).To(gomega.BeEmpty())
+
+	annotations := resource.Resource.GetAnnotations()
+	annotations[appv1.AnnotationRecreateOnImmutable] = "true"
+	resource.Resource.SetAnnotations(annotations)
+
+	g.Expect(apply()).To(gomega.Succeed())
+
+	live, err := configMaps.Namespace(scaleNamespace).Get(context.TODO(), resource.Resource.GetName(), metav1.GetOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(live.Object["data"]).To(gomega.HaveKeyWithValue("index", "changed"))
+
+	g.Expect(env.sync.takeRecreated(resource.Resource)).To(gomega.HavePrefix("recreated"))
+	g.Expect(env.sync.takeRecreated(resource.Resource)).To(gomega.BeEmpty())
+}
@@ -0,0 +1,237 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/metrics"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+const (
+	// ReasonChangeFreeze prefixes the subscription status reason when a change freeze holds the changes of the appsub
+	ReasonChangeFreeze = "ChangeFreeze"
+
+	// ChangeFreezeKey is the key of the change freeze config map set to true to freeze the changes
+	ChangeFreezeKey = "frozen"
+	// ChangeFreezeReasonKey is the key of the change freeze config map explaining the freeze
+	ChangeFreezeReasonKey = "reason"
+
+	// maxFrozenChangesReported limits the pending changes listed in the status of the appsub
+	maxFrozenChangesReported = 5
+)
+
+// changeFreezeConfigMap is the config map freezing the changes of all the appsubs, nil if there is no change freeze
+var changeFreezeConfigMap *types.NamespacedName
+
+// SetChangeFreezeConfigMap sets the <namespace>/<name> config map freezing the changes of all the appsubs while its
+// frozen key is true, e.g. during an incident or a holiday change freeze. No change freeze is checked if ref is empty.
+func SetChangeFreezeConfigMap(ref string) error {
+	if ref == "" {
+		changeFreezeConfigMap = nil
+
+		return nil
+	}
+
+	parts := strings.Split(ref, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("invalid change freeze config map %q, it must be <namespace>/<name>", ref)
+	}
+
+	klog.Infof("The changes of the subscriptions are frozen while config map %v has %v: true", ref, ChangeFreezeKey)
+
+	changeFreezeConfigMap = &types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+
+	return nil
+}
+
+// getChangeFreeze returns true and the reason of the freeze if the change freeze config map freezes the changes
+func (sync *KubeSynchronizer) getChangeFreeze() (bool, string, error) {
+	if changeFreezeConfigMap == nil {
+		return false, "", nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	if err := sync.LocalClient.Get(context.TODO(), *changeFreezeConfigMap, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return false, "", nil
+		}
+
+		return false, "", err
+	}
+
+	if !strings.EqualFold(cm.Data[ChangeFreezeKey], "true") {
+		return false, "", nil
+	}
+
+	return true, cm.Data[ChangeFreezeReasonKey], nil
+}
+
+// checkChangeFreeze holds the resources of the appsub as they are deployed during a change freeze. It returns true if
+// the sync is skipped, with an error if the resources of the appsub would change: the resources to create or update,
+// the ones to delete, and the ones drifted from the last sync, which are reported in the appsub status. The change
+// freeze is cleared from the appsub status once it is lifted. The sync is not applied if the freeze can't be checked,
+// and an error is returned if the changes held can't be determined, so the sync is retried.
+func (sync *KubeSynchronizer) checkChangeFreeze(appsub *appv1alpha1.Subscription, resources []ResourceUnit) (bool, error) {
+	if changeFreezeConfigMap == nil {
+		return false, nil
+	}
+
+	hostSub := types.NamespacedName{Namespace: appsub.GetNamespace(), Name: appsub.GetName()}
+
+	frozen, reason, err := sync.getChangeFreeze()
+	if err != nil {
+		msg := fmt.Sprintf("failed to get the change freeze config map %v, err: %v", *changeFreezeConfigMap, err)
+		utils.UpdateFailureReasonStatus(sync.LocalClient, appsub, ReasonChangeFreeze, msg)
+
+		return true, fmt.Errorf("no resource of appsub %v is applied, %v", hostSub, msg)
+	}
+
+	if !frozen {
+		utils.UpdateFailureReasonStatus(sync.LocalClient, appsub, ReasonChangeFreeze, "")
+
+		return false, nil
+	}
+
+	changes, err := sync.getFrozenChanges(hostSub, resources)
+	if err != nil {
+		changes = []string{fmt.Sprintf("the changes can't be compared, err: %v", err)}
+	}
+
+	msg := ""
+
+	if len(changes) > 0 {
+		msg = "changes held by the change freeze: " + summarizeFrozenChanges(changes)
+
+		if reason != "" {
+			msg += ", freeze reason: " + reason
+		}
+	}
+
+	utils.UpdateFailureReasonStatus(sync.LocalClient, appsub, ReasonChangeFreeze, msg)
+
+	if msg != "" {
+		return true, fmt.Errorf("no resource of appsub %v is applied, %v", hostSub, msg)
+	}
+
+	klog.Infof("Changes are frozen, appsub %v is in sync", hostSub)
+
+	return true, nil
+}
+
+// getFrozenChanges compares the resources of the appsub to the cluster and to its last sync, and returns the changes
+// the sync would make
+func (sync *KubeSynchronizer) getFrozenChanges(hostSub types.NamespacedName, resources []ResourceUnit) ([]string, error) {
+	desired := []*unstructured.Unstructured{}
+
+	for _, resource := range resources {
+		resource := resource
+
+		template, err := sync.OverrideResource(hostSub, &resource)
+		if err != nil {
+			// the resource fails to deploy once the freeze is lifted, it is not a change
+			klog.Infof("Skipping the change freeze check of %v %v, err: %v", resource.Resource.GetKind(),
+				resource.Resource.GetName(), err)
+
+			continue
+		}
+
+		desired = append(desired, template)
+	}
+
+	// the resources the sync would delete are the ones of the last sync, e.g. all of them on the purge path, they are
+	// not known until the appsub is synced after a restart
+	render, ok := sync.GetLastRender(hostSub)
+	if !ok {
+		return nil, fmt.Errorf("the resources of the last sync of appsub %v are not known", hostSub)
+	}
+
+	// the resources of the last sync changed outside of the subscription are reported as drift, they are not corrected
+	lastRender := map[string]bool{}
+
+	drifts, err := sync.diffResources(render.Resources)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, diff := range drifts {
+		lastRender[getResourceKey(diff.APIVersion, diff.Kind, diff.Namespace, diff.Name)] = true
+
+		if diff.Patch != nil {
+			metrics.RecordDrift(hostSub)
+		}
+	}
+
+	diffs, err := sync.diffResources(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []string{}
+
+	for _, diff := range diffs {
+		key := getResourceKey(diff.APIVersion, diff.Kind, diff.Namespace, diff.Name)
+		delete(lastRender, key)
+
+		switch {
+		case diff.Missing:
+			changes = append(changes, "create "+key)
+		case diff.Patch != nil:
+			changes = append(changes, "update "+key)
+		}
+	}
+
+	// the resources of the last sync the appsub no longer has would be deleted
+	deleted := []string{}
+
+	for key := range lastRender {
+		deleted = append(deleted, "delete "+key)
+	}
+
+	sort.Strings(deleted)
+
+	return append(changes, deleted...), nil
+}
+
+func getResourceKey(apiVersion, kind, namespace, name string) string {
+	return strings.Join([]string{apiVersion, kind, namespace, name}, "/")
+}
+
+func summarizeFrozenChanges(changes []string) string {
+	listed := []string{}
+
+	for i, change := range changes {
+		if i == maxFrozenChangesReported {
+			listed = append(listed, fmt.Sprintf("and %d more", len(changes)-i))
+
+			break
+		}
+
+		listed = append(listed, change)
+	}
+
+	return strings.Join(listed, ", ")
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestChangeFreeze(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(SetChangeFreezeConfigMap("change-freeze")).NotTo(gomega.Succeed())
+	g.Expect(SetChangeFreezeConfigMap("ops/change-freeze")).To(gomega.Succeed())
+
+	defer func() { g.Expect(SetChangeFreezeConfigMap("")).To(gomega.Succeed()) }()
+
+	s := apiruntime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(s)).To(gomega.Succeed())
+	g.Expect(appv1.SchemeBuilder.AddToScheme(s)).To(gomega.Succeed())
+
+	appsub := &appv1.Subscription{ObjectMeta: metav1.ObjectMeta{Name: "sub", Namespace: "ns1"}}
+	freeze := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "change-freeze", Namespace: "ops"},
+		Data:       map[string]string{ChangeFreezeKey: "true", ChangeFreezeReasonKey: "incident 42"},
+	}
+
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(configMapGVK, meta.RESTScopeNamespace)
+
+	dynamic := dynamicfake.NewSimpleDynamicClient(apiruntime.NewScheme())
+	configMaps := dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}).Namespace("ns1")
+
+	sync := &KubeSynchronizer{
+		LocalClient:    fake.NewClientBuilder().WithScheme(s).WithObjects(appsub, freeze).Build(),
+		DynamicClient:  dynamic,
+		RestMapper:     restMapper,
+		SynchronizerID: &types.NamespacedName{Name: "cluster1", Namespace: "cluster1"},
+		Extension:      defaultExtension,
+	}
+
+	hostSub := types.NamespacedName{Namespace: "ns1", Name: "sub"}
+	resources := []ResourceUnit{{
+		Resource: renderConfigMap("settings", map[string]interface{}{"mode": "fast"}),
+		Gvk:      configMapGVK,
+	}}
+
+	// the resource is deployed as the appsub renders it, then changed by the next revision
+	deployed, err := sync.OverrideResource(hostSub, &resources[0])
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, err = configMaps.Create(context.TODO(), deployed, metav1.CreateOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// the resources to delete are not known before the first sync, e.g. after a restart
+	skip, err := sync.checkChangeFreeze(appsub, resources)
+	g.Expect(skip).To(gomega.BeTrue())
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("can't be compared")))
+
+	skip, err = sync.checkChangeFreeze(appsub, nil)
+	g.Expect(skip).To(gomega.BeTrue())
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	sync.recordRender(hostSub, []*unstructured.Unstructured{deployed}, nil)
+
+	skip, err = sync.checkChangeFreeze(appsub, resources)
+	g.Expect(skip).To(gomega.BeTrue())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// the purge would delete the resources of the last sync
+	skip, err = sync.checkChangeFreeze(appsub, nil)
+	g.Expect(skip).To(gomega.BeTrue())
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("delete v1/ConfigMap/ns1/settings")))
+
+	resources[0].Resource = renderConfigMap("settings", map[string]interface{}{"mode": "slow"})
+	sync.recordRender(hostSub, []*unstructured.Unstructured{deployed, renderConfigMap("removed", nil)}, nil)
+
+	skip, err = sync.checkChangeFreeze(appsub, resources)
+	g.Expect(skip).To(gomega.BeTrue())
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	curSub := &appv1.Subscription{}
+	g.Expect(sync.LocalClient.Get(context.TODO(), hostSub, curSub)).To(gomega.Succeed())
+	g.Expect(curSub.Status.Phase).To(gomega.Equal(appv1.SubscriptionFailed))
+	g.Expect(curSub.Status.Reason).To(gomega.Equal("ChangeFreeze: changes held by the change freeze: " +
+		"update v1/ConfigMap/ns1/settings, delete v1/ConfigMap/ns1/removed, freeze reason: incident 42"))
+
+	// the freeze is lifted, the sync goes on and clears the status
+	freeze.Data[ChangeFreezeKey] = "false"
+	g.Expect(sync.LocalClient.Update(context.TODO(), freeze)).To(gomega.Succeed())
+
+	skip, err = sync.checkChangeFreeze(appsub, resources)
+	g.Expect(skip).To(gomega.BeFalse())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	g.Expect(sync.LocalClient.Get(context.TODO(), hostSub, curSub)).To(gomega.Succeed())
+	g.Expect(strings.HasPrefix(curSub.Status.Reason, ReasonChangeFreeze)).To(gomega.BeFalse())
+}
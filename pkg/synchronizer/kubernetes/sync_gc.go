@@ -0,0 +1,126 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+// gcResources are the intermediate kinds generated for a subscription. Their names are derived from the
+// subscription and channel names, so renaming either leaves the old ones behind.
+var gcResources = []schema.GroupVersionResource{
+	{Group: appv1.SchemeGroupVersion.Group, Version: "v1", Resource: "helmreleases"},
+	{Group: appv1.SchemeGroupVersion.Group, Version: "v1", Resource: "deployables"},
+}
+
+// collectOrphans deletes the generated HelmReleases and deployables whose hosting subscription no longer exists.
+func (sync *KubeSynchronizer) collectOrphans() {
+	klog.V(1).Info("Start collecting orphaned helmreleases and deployables")
+
+	// give the subscribers a full sync cycle to settle newly created subscriptions
+	minAge := time.Duration(sync.Interval) * time.Second
+
+	for _, gvr := range gcResources {
+		objList, err := sync.DynamicClient.Resource(gvr).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				klog.Errorf("failed to list %v for garbage collection, err: %v", gvr.Resource, err)
+			}
+
+			continue
+		}
+
+		for i := range objList.Items {
+			obj := &objList.Items[i]
+
+			hostSub := getGarbageCandidateHost(obj, time.Now(), minAge)
+			if hostSub == nil {
+				continue
+			}
+
+			if sync.isHostingSubscriptionAlive(*hostSub) {
+				continue
+			}
+
+			klog.Infof("deleting orphaned %v %v/%v, hosting subscription %v is gone",
+				gvr.Resource, obj.GetNamespace(), obj.GetName(), hostSub.String())
+
+			deletepolicy := metav1.DeletePropagationBackground
+
+			err := sync.DynamicClient.Resource(gvr).Namespace(obj.GetNamespace()).Delete(context.TODO(), obj.GetName(),
+				metav1.DeleteOptions{PropagationPolicy: &deletepolicy})
+			if err != nil && !errors.IsNotFound(err) {
+				klog.Errorf("failed to delete orphaned %v %v/%v, err: %v", gvr.Resource, obj.GetNamespace(), obj.GetName(), err)
+			}
+		}
+	}
+}
+
+// getGarbageCandidateHost returns the hosting subscription of a generated resource which may be collected,
+// nil if the resource is not generated by a subscription, is too young, asks not to be deleted or is generated by a
+// newer operator.
+func getGarbageCandidateHost(obj *unstructured.Unstructured, now time.Time, minAge time.Duration) *types.NamespacedName {
+	if obj.GetDeletionTimestamp() != nil {
+		return nil
+	}
+
+	if obj.GetAnnotations()[appv1.AnnotationResourceDoNotDeleteOption] == "true" {
+		return nil
+	}
+
+	// a newer operator may name the resources of its subscriptions differently
+	if utils.IsGeneratedByNewerOperator(obj) {
+		return nil
+	}
+
+	if now.Sub(obj.GetCreationTimestamp().Time) < minAge {
+		return nil
+	}
+
+	return utils.GetHostSubscriptionFromObject(obj)
+}
+
+func (sync *KubeSynchronizer) isHostingSubscriptionAlive(hostSub types.NamespacedName) bool {
+	// read through to the api server, the cache may not have seen a just created subscription yet
+	clt := sync.LocalNonCachedClient
+	if clt == nil {
+		clt = sync.LocalClient
+	}
+
+	err := clt.Get(context.TODO(), hostSub, &appv1.Subscription{})
+	if err == nil {
+		return true
+	}
+
+	if !errors.IsNotFound(err) {
+		klog.Errorf("failed to get hosting subscription %v, err: %v", hostSub.String(), err)
+
+		// keep the resource if in doubt
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+func TestGetGarbageCandidateHost(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	now := time.Now()
+	minAge := time.Minute
+
+	hr := &unstructured.Unstructured{}
+	hr.SetAPIVersion("apps.open-cluster-management.io/v1")
+	hr.SetKind("HelmRelease")
+	hr.SetName("nginx-ingress-1c2a3")
+	hr.SetNamespace("default")
+	hr.SetCreationTimestamp(metav1.NewTime(now.Add(-time.Hour)))
+
+	// not generated by a subscription
+	g.Expect(getGarbageCandidateHost(hr, now, minAge)).To(gomega.BeNil())
+
+	hr.SetAnnotations(map[string]string{appv1.AnnotationHosting: "default/old-sub"})
+	g.Expect(getGarbageCandidateHost(hr, now, minAge)).To(gomega.Equal(&types.NamespacedName{Namespace: "default", Name: "old-sub"}))
+
+	// too young, the hosting subscription may not be in place yet
+	hr.SetCreationTimestamp(metav1.NewTime(now))
+	g.Expect(getGarbageCandidateHost(hr, now, minAge)).To(gomega.BeNil())
+
+	hr.SetCreationTimestamp(metav1.NewTime(now.Add(-time.Hour)))
+	hr.SetAnnotations(map[string]string{
+		appv1.AnnotationHosting:                   "default/old-sub",
+		appv1.AnnotationResourceDoNotDeleteOption: "true",
+	})
+	g.Expect(getGarbageCandidateHost(hr, now, minAge)).To(gomega.BeNil())
+
+	// generated by a newer operator
+	hr.SetAnnotations(map[string]string{
+		appv1.AnnotationHosting:          "default/old-sub",
+		appv1.AnnotationGeneratorVersion: strconv.Itoa(utils.GeneratorVersion + 1),
+	})
+	g.Expect(getGarbageCandidateHost(hr, now, minAge)).To(gomega.BeNil())
+}
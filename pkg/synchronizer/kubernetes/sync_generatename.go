@@ -0,0 +1,94 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+const (
+	// generatedNameSuffixLen is the length of the hash appended to the generateName prefix
+	generatedNameSuffixLen = 10
+	// maxGeneratedNameLen keeps the generated names valid for the resources whose names are DNS labels
+	maxGeneratedNameLen = 63
+)
+
+// getGenerateNameMode returns how the appsub deploys the resources with a generateName, from its generate-name
+// annotation
+func getGenerateNameMode(appsub *appv1alpha1.Subscription) string {
+	mode := strings.ToLower(strings.TrimSpace(appsub.GetAnnotations()[appv1alpha1.AnnotationGenerateName]))
+
+	switch mode {
+	case appv1alpha1.GenerateNameHash, appv1alpha1.GenerateNameReject:
+		return mode
+	case "":
+	default:
+		klog.Warningf("invalid %v annotation %q of appsub %v/%v, using %v", appv1alpha1.AnnotationGenerateName, mode,
+			appsub.GetNamespace(), appsub.GetName(), appv1alpha1.GenerateNameReject)
+	}
+
+	return appv1alpha1.GenerateNameReject
+}
+
+// nameGeneratedResources names the resources with a generateName and no name from a hash of the appsub, their kind,
+// namespace, generateName and rank among the resources with the same generateName, if the appsub hashes the generated
+// names. The same resource gets the same name on every sync, rather than a new object. The resources are returned as
+// they are otherwise, and rejected when they are applied.
+func nameGeneratedResources(appsub *appv1alpha1.Subscription, resources []ResourceUnit) []ResourceUnit {
+	if getGenerateNameMode(appsub) != appv1alpha1.GenerateNameHash {
+		return resources
+	}
+
+	named := make([]ResourceUnit, 0, len(resources))
+	ranks := map[string]int{}
+
+	for _, resource := range resources {
+		rsc := resource.Resource
+		if rsc == nil || rsc.GetName() != "" || rsc.GetGenerateName() == "" {
+			named = append(named, resource)
+
+			continue
+		}
+
+		identity := strings.Join([]string{appsub.GetNamespace(), appsub.GetName(), rsc.GroupVersionKind().GroupKind().String(),
+			rsc.GetNamespace(), rsc.GetGenerateName()}, "/")
+
+		digest := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", identity, ranks[identity])))
+		ranks[identity]++
+
+		prefix := rsc.GetGenerateName()
+		if len(prefix) > maxGeneratedNameLen-generatedNameSuffixLen {
+			prefix = prefix[:maxGeneratedNameLen-generatedNameSuffixLen]
+		}
+
+		resource.Resource = rsc.DeepCopy()
+		resource.Resource.SetName(prefix + hex.EncodeToString(digest[:])[:generatedNameSuffixLen])
+		resource.Resource.SetGenerateName("")
+
+		klog.Infof("Named %v with generateName %v of appsub %v/%v as %v", rsc.GetKind(), rsc.GetGenerateName(),
+			appsub.GetNamespace(), appsub.GetName(), resource.Resource.GetName())
+
+		named = append(named, resource)
+	}
+
+	return named
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestNameGeneratedResources(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	newJob := func(name, generateName string) ResourceUnit {
+		job := &unstructured.Unstructured{}
+		job.SetAPIVersion("batch/v1")
+		job.SetKind("Job")
+		job.SetNamespace("default")
+		job.SetName(name)
+		job.SetGenerateName(generateName)
+
+		return ResourceUnit{Resource: job, Gvk: job.GroupVersionKind()}
+	}
+
+	resources := []ResourceUnit{newJob("", "migrate-"), newJob("", "migrate-"), newJob("seed", "")}
+
+	appsub := &appv1alpha1.Subscription{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+
+	// the resources are rejected when they are applied by default
+	g.Expect(getGenerateNameMode(appsub)).To(gomega.Equal(appv1alpha1.GenerateNameReject))
+	g.Expect(nameGeneratedResources(appsub, resources)).To(gomega.Equal(resources))
+
+	appsub.SetAnnotations(map[string]string{appv1alpha1.AnnotationGenerateName: "Hash"})
+
+	named := nameGeneratedResources(appsub, resources)
+	g.Expect(named).To(gomega.HaveLen(3))
+	g.Expect(named[0].Resource.GetName()).To(gomega.HavePrefix("migrate-"))
+	g.Expect(named[0].Resource.GetName()).To(gomega.HaveLen(len("migrate-") + generatedNameSuffixLen))
+	g.Expect(named[0].Resource.GetGenerateName()).To(gomega.BeEmpty())
+	g.Expect(named[1].Resource.GetName()).NotTo(gomega.Equal(named[0].Resource.GetName()))
+	g.Expect(named[2].Resource.GetName()).To(gomega.Equal("seed"))
+
+	// the rendered resources are left as they are
+	g.Expect(resources[0].Resource.GetName()).To(gomega.BeEmpty())
+
+	// the same names on the next sync
+	again := nameGeneratedResources(appsub, resources)
+	g.Expect(again[0].Resource.GetName()).To(gomega.Equal(named[0].Resource.GetName()))
+	g.Expect(again[1].Resource.GetName()).To(gomega.Equal(named[1].Resource.GetName()))
+
+	// other appsubs get other names
+	other := appsub.DeepCopy()
+	other.SetName("other")
+	g.Expect(nameGeneratedResources(other, resources)[0].Resource.GetName()).NotTo(gomega.Equal(named[0].Resource.GetName()))
+
+	// the long prefixes are truncated
+	long := nameGeneratedResources(appsub, []ResourceUnit{newJob("", strings.Repeat("a", 70))})
+	g.Expect(long[0].Resource.GetName()).To(gomega.HaveLen(maxGeneratedNameLen))
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+)
+
+// kubectlLastApplied maintains the kubectl last-applied-configuration annotation on the applied resources
+var kubectlLastApplied bool
+
+// SetKubectlLastApplied sets the kubectl.kubernetes.io/last-applied-configuration annotation of the applied resources
+// to their subscribed configuration, the way kubectl apply does, so kubectl apply and diff on them compute their three
+// way merge from what the subscription applied
+func SetKubectlLastApplied(enabled bool) {
+	if enabled {
+		klog.Info("The kubectl last-applied-configuration annotation is maintained on the applied resources")
+	}
+
+	kubectlLastApplied = enabled
+}
+
+// withLastAppliedConfiguration returns a copy of the template with the kubectl last-applied-configuration annotation
+// set to the JSON of the template without the annotation itself, as kubectl apply sets it
+func withLastAppliedConfiguration(tplunit *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	tpl := tplunit.DeepCopy()
+
+	annotations := tpl.GetAnnotations()
+	if _, ok := annotations[corev1.LastAppliedConfigAnnotation]; ok {
+		delete(annotations, corev1.LastAppliedConfigAnnotation)
+
+		// no empty annotations are recorded
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+
+		tpl.SetAnnotations(annotations)
+	}
+
+	lastApplied, err := tpl.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[corev1.LastAppliedConfigAnnotation] = string(lastApplied)
+
+	tpl.SetAnnotations(annotations)
+
+	return tpl, nil
+}
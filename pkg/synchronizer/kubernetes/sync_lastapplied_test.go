@@ -0,0 +1,56 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestWithLastAppliedConfiguration(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	tpl := &unstructured.Unstructured{}
+	tpl.SetAPIVersion("v1")
+	tpl.SetKind("ConfigMap")
+	tpl.SetName("cm")
+	tpl.SetNamespace("default")
+	tpl.SetAnnotations(map[string]string{"apps.open-cluster-management.io/hosting-subscription": "default/sub"})
+	g.Expect(unstructured.SetNestedField(tpl.Object, "v", "data", "k")).To(gomega.Succeed())
+
+	applied, err := withLastAppliedConfiguration(tpl)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(tpl.GetAnnotations()).NotTo(gomega.HaveKey(corev1.LastAppliedConfigAnnotation))
+
+	lastApplied := map[string]interface{}{}
+	g.Expect(json.Unmarshal([]byte(applied.GetAnnotations()[corev1.LastAppliedConfigAnnotation]), &lastApplied)).To(gomega.Succeed())
+	g.Expect(lastApplied).To(gomega.Equal(tpl.Object))
+
+	// the annotation of a template applied again doesn't nest
+	again, err := withLastAppliedConfiguration(applied)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(again.GetAnnotations()).To(gomega.Equal(applied.GetAnnotations()))
+
+	// a template without annotations records none
+	tpl.SetAnnotations(nil)
+
+	applied, err = withLastAppliedConfiguration(tpl)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(applied.GetAnnotations()[corev1.LastAppliedConfigAnnotation]).NotTo(gomega.ContainSubstring("annotations"))
+}
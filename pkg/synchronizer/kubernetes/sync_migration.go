@@ -0,0 +1,94 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+// stampGeneratorVersion returns copies of the resources stamped with the generator version of the operator. The
+// stamp is not part of the desired state hash, so a new generator version doesn't change the hash of every appsub.
+func stampGeneratorVersion(resources []ResourceUnit) []ResourceUnit {
+	stamped := make([]ResourceUnit, 0, len(resources))
+
+	for _, resource := range resources {
+		if resource.Resource != nil {
+			resource.Resource = resource.Resource.DeepCopy()
+			utils.SetGeneratorVersion(resource.Resource)
+		}
+
+		stamped = append(stamped, resource)
+	}
+
+	return stamped
+}
+
+// migrateGeneratedObjects migrates the generated HelmReleases and deployables of the previous operators to the
+// generator version of the operator, once at startup. Their names and annotations then match what the subscribers
+// generate, and the first syncs after an upgrade find nothing to recreate. The objects of a newer operator are left
+// as they are.
+func (sync *KubeSynchronizer) migrateGeneratedObjects() {
+	migrated := 0
+
+	for _, gvr := range gcResources {
+		objList, err := sync.DynamicClient.Resource(gvr).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				klog.Errorf("failed to list %v for the generator version migration, err: %v", gvr.Resource, err)
+			}
+
+			continue
+		}
+
+		for i := range objList.Items {
+			obj := &objList.Items[i]
+
+			if utils.GetHostSubscriptionFromObject(obj) == nil || obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+
+			if utils.IsGeneratedByNewerOperator(obj) {
+				klog.Infof("%v %v/%v is generated by a newer operator, generator version %v, skipping its migration",
+					gvr.Resource, obj.GetNamespace(), obj.GetName(), utils.GetGeneratorVersion(obj))
+
+				continue
+			}
+
+			if !utils.MigrateGeneratedObject(obj) {
+				continue
+			}
+
+			_, err := sync.DynamicClient.Resource(gvr).Namespace(obj.GetNamespace()).Update(context.TODO(), obj,
+				metav1.UpdateOptions{})
+			if err != nil {
+				// the next sync of the appsub updates it
+				klog.Errorf("failed to migrate %v %v/%v to generator version %v, err: %v", gvr.Resource, obj.GetNamespace(),
+					obj.GetName(), utils.GeneratorVersion, err)
+
+				continue
+			}
+
+			migrated++
+		}
+	}
+
+	klog.Infof("migrated %d generated objects to generator version %v", migrated, utils.GeneratorVersion)
+}
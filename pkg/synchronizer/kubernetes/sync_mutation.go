@@ -0,0 +1,194 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+const (
+	// ReasonMutationFailed prefixes the subscription status reason when a mutation webhook failed its resources
+	ReasonMutationFailed = "MutationFailed"
+
+	// FailurePolicyFail fails the sync of the appsub when the mutation webhook can't be called
+	FailurePolicyFail = utils.WebhookFailurePolicyFail
+	// FailurePolicyIgnore applies the resources as rendered when the mutation webhook can't be called
+	FailurePolicyIgnore = utils.WebhookFailurePolicyIgnore
+
+	// maxMutationResponseSize limits the mutation webhook responses read by the synchronizer
+	maxMutationResponseSize = 64 * 1024 * 1024
+)
+
+// mutateResources runs the manifest mutators on the rendered resources of the appsub, and sets or clears the mutation
+// failure of the appsub status
+func (sync *KubeSynchronizer) mutateResources(appsub *appv1alpha1.Subscription, resources []ResourceUnit) ([]ResourceUnit, error) {
+	if len(sync.mutators) == 0 {
+		return resources, nil
+	}
+
+	hostSub := types.NamespacedName{Namespace: appsub.GetNamespace(), Name: appsub.GetName()}
+
+	var err error
+
+	for _, mutator := range sync.mutators {
+		resources, err = mutator.Mutate(hostSub, sync.GetClusterName(), resources)
+		if err != nil {
+			break
+		}
+	}
+
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+
+	utils.UpdateFailureReasonStatus(sync.LocalClient, appsub, ReasonMutationFailed, msg)
+
+	return resources, err
+}
+
+// MutationWebhookConfig is an external HTTP endpoint mutating the rendered resources of the appsubs, its URL receives
+// the POST requests of the resources to mutate
+type MutationWebhookConfig struct {
+	utils.WebhookConfig `json:",inline"`
+}
+
+// MutationWebhooksConfig is the config file of the mutation webhooks, they are called in order
+type MutationWebhooksConfig struct {
+	Webhooks []MutationWebhookConfig `json:"webhooks"`
+}
+
+// MutationRequest is the body of the requests sent to the mutation webhooks
+type MutationRequest struct {
+	Subscription types.NamespacedName         `json:"subscription"`
+	Cluster      string                       `json:"cluster"`
+	Resources    []*unstructured.Unstructured `json:"resources"`
+}
+
+// MutationResponse is the body of the mutation webhook responses, the resources replace the ones of the request
+type MutationResponse struct {
+	Resources []*unstructured.Unstructured `json:"resources"`
+}
+
+// MutationWebhook is a manifest mutator calling an external HTTP endpoint
+type MutationWebhook struct {
+	config MutationWebhookConfig
+	client *utils.WebhookClient
+}
+
+var _ ManifestMutator = &MutationWebhook{}
+
+// LoadMutationWebhooks reads the YAML or JSON config file of the mutation webhooks
+func LoadMutationWebhooks(configFile string) ([]*MutationWebhook, error) {
+	content, err := ioutil.ReadFile(configFile) // #nosec G304 the config file is an operator flag
+	if err != nil {
+		return nil, err
+	}
+
+	config := &MutationWebhooksConfig{}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("failed to parse mutation webhooks config %v, err: %w", configFile, err)
+	}
+
+	webhooks := []*MutationWebhook{}
+
+	for _, webhookConfig := range config.Webhooks {
+		webhook, err := NewMutationWebhook(webhookConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+// NewMutationWebhook validates the mutation webhook config and creates its client
+func NewMutationWebhook(config MutationWebhookConfig) (*MutationWebhook, error) {
+	if config.Name == "" || config.URL == "" {
+		return nil, fmt.Errorf("mutation webhook %q: name and url are required", config.Name)
+	}
+
+	if err := config.SetDefaults(); err != nil {
+		return nil, fmt.Errorf("mutation webhook %v: %w", config.Name, err)
+	}
+
+	client, err := utils.NewWebhookClient(config.WebhookConfig, maxMutationResponseSize)
+	if err != nil {
+		return nil, fmt.Errorf("mutation webhook %v: %w", config.Name, err)
+	}
+
+	return &MutationWebhook{config: config, client: client}, nil
+}
+
+// Mutate sends the resources to the webhook and returns the resources of its response. If the webhook fails, the
+// resources are returned as is with the Ignore failure policy, and an error is returned with the Fail one.
+func (w *MutationWebhook) Mutate(hostSub types.NamespacedName, cluster string, resources []ResourceUnit) ([]ResourceUnit, error) {
+	mutated, err := w.call(hostSub, cluster, resources)
+	if err == nil {
+		klog.V(1).Infof("mutation webhook %v returned %d resources of %d for appsub %v", w.config.Name, len(mutated),
+			len(resources), hostSub)
+
+		return mutated, nil
+	}
+
+	if w.config.IgnoresFailures() {
+		klog.Warningf("ignoring the failure of mutation webhook %v for appsub %v, err: %v", w.config.Name, hostSub, err)
+
+		return resources, nil
+	}
+
+	return nil, fmt.Errorf("mutation webhook %v failed: %w", w.config.Name, err)
+}
+
+func (w *MutationWebhook) call(hostSub types.NamespacedName, cluster string, resources []ResourceUnit) ([]ResourceUnit, error) {
+	req := &MutationRequest{Subscription: hostSub, Cluster: cluster, Resources: make([]*unstructured.Unstructured, 0, len(resources))}
+
+	for _, resource := range resources {
+		req.Resources = append(req.Resources, resource.Resource)
+	}
+
+	mutationResp := &MutationResponse{}
+	if err := w.client.Post(req, mutationResp); err != nil {
+		return nil, err
+	}
+
+	// an empty set would delete all the resources of the appsub
+	if len(mutationResp.Resources) == 0 {
+		return nil, fmt.Errorf("the response has no resource")
+	}
+
+	mutated := make([]ResourceUnit, 0, len(mutationResp.Resources))
+
+	for _, resource := range mutationResp.Resources {
+		if resource == nil || resource.GetAPIVersion() == "" || resource.GetKind() == "" || resource.GetName() == "" {
+			return nil, fmt.Errorf("the response has a resource without apiVersion, kind or name")
+		}
+
+		mutated = append(mutated, ResourceUnit{Resource: resource, Gvk: resource.GroupVersionKind()})
+	}
+
+	return mutated, nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+// newMutationServer labels the resources it receives with their cluster and injects a cost center ConfigMap
+func newMutationServer(g *gomega.WithT) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.Expect(r.Header.Get("Authorization")).To(gomega.Equal("Bearer secret-token"))
+
+		req := &MutationRequest{}
+		g.Expect(json.NewDecoder(r.Body).Decode(req)).To(gomega.Succeed())
+
+		for _, resource := range req.Resources {
+			resource.SetLabels(map[string]string{"cluster": req.Cluster})
+		}
+
+		cm := &unstructured.Unstructured{}
+		cm.SetGroupVersionKind(configMapGVK)
+		cm.SetName(req.Subscription.Name + "-cost-center")
+		cm.SetNamespace(req.Subscription.Namespace)
+
+		g.Expect(json.NewEncoder(w).Encode(&MutationResponse{Resources: append(req.Resources, cm)})).To(gomega.Succeed())
+	}))
+}
+
+func TestMutationWebhook(t *testing.T) {
+	defer silenceKlog()()
+
+	g := gomega.NewGomegaWithT(t)
+
+	srv := newMutationServer(g)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "mutation")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	g.Expect(ioutil.WriteFile(tokenFile, []byte("secret-token\n"), 0600)).To(gomega.Succeed())
+
+	configFile := filepath.Join(dir, "webhooks.yaml")
+	config := "webhooks:\n- name: cost-labels\n  url: " + srv.URL + "\n  tokenFile: " + tokenFile + "\n"
+	g.Expect(ioutil.WriteFile(configFile, []byte(config), 0600)).To(gomega.Succeed())
+
+	webhooks, err := LoadMutationWebhooks(configFile)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(webhooks).To(gomega.HaveLen(1))
+
+	env, err := newScaleEnv(1, 2)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	env.sync.AddManifestMutator(webhooks[0])
+
+	g.Expect(env.sync.ProcessSubResources(env.appsubs[0], copyResourceUnits(env.resources[0]), nil, nil, false)).
+		To(gomega.Succeed())
+
+	configMaps := env.dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}).Namespace(scaleNamespace)
+
+	for _, name := range []string{"appsub-0-cm-0", "appsub-0-cm-1"} {
+		cm, err := configMaps.Get(context.TODO(), name, metav1.GetOptions{})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(cm.GetLabels()).To(gomega.HaveKeyWithValue("cluster", "cluster1"))
+	}
+
+	_, err = configMaps.Get(context.TODO(), "appsub-0-cost-center", metav1.GetOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+}
+
+func TestMutationWebhookFailurePolicy(t *testing.T) {
+	defer silenceKlog()()
+
+	g := gomega.NewGomegaWithT(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "sidecar injector unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	env, err := newScaleEnv(1, 2)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	webhook, err := NewMutationWebhook(MutationWebhookConfig{WebhookConfig: utils.WebhookConfig{Name: "sidecars", URL: srv.URL}})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	env.sync.AddManifestMutator(webhook)
+
+	// nothing is applied and the failure is reported in the appsub status
+	g.Expect(env.sync.ProcessSubResources(env.appsubs[0], copyResourceUnits(env.resources[0]), nil, nil, false)).
+		NotTo(gomega.Succeed())
+
+	configMaps := env.dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}).Namespace(scaleNamespace)
+
+	_, err = configMaps.Get(context.TODO(), "appsub-0-cm-0", metav1.GetOptions{})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	appsub := &appv1.Subscription{}
+	g.Expect(env.sync.LocalClient.Get(context.TODO(),
+		types.NamespacedName{Namespace: scaleNamespace, Name: "appsub-0"}, appsub)).To(gomega.Succeed())
+	g.Expect(appsub.Status.Phase).To(gomega.Equal(appv1.SubscriptionFailed))
+	g.Expect(strings.HasPrefix(appsub.Status.Reason, ReasonMutationFailed)).To(gomega.BeTrue())
+	g.Expect(appsub.Status.Reason).To(gomega.ContainSubstring("sidecar injector unavailable"))
+
+	// the resources are applied as rendered with the Ignore failure policy, and the failure is cleared
+	webhook, err = NewMutationWebhook(MutationWebhookConfig{
+		WebhookConfig: utils.WebhookConfig{Name: "sidecars", URL: srv.URL, FailurePolicy: FailurePolicyIgnore},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	env.sync.mutators = []ManifestMutator{webhook}
+
+	g.Expect(env.sync.ProcessSubResources(env.appsubs[0], copyResourceUnits(env.resources[0]), nil, nil, false)).
+		To(gomega.Succeed())
+
+	_, err = configMaps.Get(context.TODO(), "appsub-0-cm-0", metav1.GetOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	g.Expect(env.sync.LocalClient.Get(context.TODO(),
+		types.NamespacedName{Namespace: scaleNamespace, Name: "appsub-0"}, appsub)).To(gomega.Succeed())
+	g.Expect(appsub.Status.Reason).To(gomega.BeEmpty())
+
+	_, err = NewMutationWebhook(MutationWebhookConfig{
+		WebhookConfig: utils.WebhookConfig{Name: "sidecars", URL: srv.URL, FailurePolicy: "Retry"},
+	})
+	g.Expect(err).To(gomega.HaveOccurred())
+}
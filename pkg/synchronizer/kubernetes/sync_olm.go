@@ -0,0 +1,182 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+const (
+	olmGroup = "operators.coreos.com"
+
+	olmOperatorGroupKind         = "OperatorGroup"
+	olmCatalogSourceKind         = "CatalogSource"
+	olmSubscriptionKind          = "Subscription"
+	olmClusterServiceVersionKind = "ClusterServiceVersion"
+
+	csvPhaseSucceeded = "Succeeded"
+)
+
+var csvGVR = schema.GroupVersionResource{
+	Group:    olmGroup,
+	Version:  "v1alpha1",
+	Resource: "clusterserviceversions",
+}
+
+// getOLMApplyOrder ranks the resources of the same wave and phase: the operator groups and catalog sources first, so
+// the operators they select can be installed, then the OLM subscriptions and cluster service versions, then the
+// others, like the custom resources served by the operators.
+func getOLMApplyOrder(gvk schema.GroupVersionKind) int {
+	if gvk.Group != olmGroup {
+		return 2
+	}
+
+	switch gvk.Kind {
+	case olmOperatorGroupKind, olmCatalogSourceKind:
+		return 0
+	case olmSubscriptionKind, olmClusterServiceVersionKind:
+		return 1
+	}
+
+	return 2
+}
+
+// isOperatorResource returns true if the resource installs an OLM operator
+func isOperatorResource(gvk schema.GroupVersionKind) bool {
+	return gvk.Group == olmGroup && (gvk.Kind == olmSubscriptionKind || gvk.Kind == olmClusterServiceVersionKind)
+}
+
+// operatorGate holds back the custom resources of the operators of an appsub that are not installed yet. The
+// custom resources applied before their operator is running may be rejected or never reconciled.
+type operatorGate struct {
+	// ownedKinds has the kinds owned by the cluster service versions that have not succeeded yet, by CSV
+	ownedKinds map[schema.GroupKind]string
+	// unresolved has the operators whose cluster service version is not known yet
+	unresolved []string
+}
+
+func newOperatorGate() *operatorGate {
+	return &operatorGate{ownedKinds: map[schema.GroupKind]string{}}
+}
+
+// waitingFor returns the operator the resources of the kind wait for, empty if they can be applied. The kinds not
+// served yet wait for the operators whose cluster service version is not known, which may own them.
+func (gate *operatorGate) waitingFor(gk schema.GroupKind, served bool) string {
+	if gk.Group == olmGroup {
+		return ""
+	}
+
+	if csv, ok := gate.ownedKinds[gk]; ok {
+		return "ClusterServiceVersion " + csv
+	}
+
+	if !served && len(gate.unresolved) > 0 {
+		return "operator " + strings.Join(gate.unresolved, ", ")
+	}
+
+	return ""
+}
+
+// observeOperator records the state of the operator installed by the OLM subscription or cluster service version
+// just applied
+func (sync *KubeSynchronizer) observeOperator(gate *operatorGate, resource *unstructured.Unstructured) {
+	namespace := resource.GetNamespace()
+	csvName := resource.GetName()
+	operator := namespace + "/" + resource.GetName()
+
+	if resource.GetKind() == olmSubscriptionKind {
+		olmSub, err := sync.DynamicClient.Resource(schema.GroupVersionResource{
+			Group:    olmGroup,
+			Version:  "v1alpha1",
+			Resource: "subscriptions",
+		}).Namespace(namespace).Get(context.TODO(), resource.GetName(), metav1.GetOptions{})
+		if err != nil {
+			klog.Infof("failed to get the OLM subscription %v, err: %v", operator, err)
+
+			gate.unresolved = append(gate.unresolved, operator)
+
+			return
+		}
+
+		csvName, _, _ = unstructured.NestedString(olmSub.Object, "status", "installedCSV")
+		if csvName == "" {
+			csvName, _, _ = unstructured.NestedString(olmSub.Object, "status", "currentCSV")
+		}
+
+		if csvName == "" {
+			klog.Infof("the OLM subscription %v has no cluster service version yet", operator)
+
+			gate.unresolved = append(gate.unresolved, operator)
+
+			return
+		}
+	}
+
+	csv, err := sync.DynamicClient.Resource(csvGVR).Namespace(namespace).Get(context.TODO(), csvName, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			klog.Infof("failed to get the cluster service version %v/%v, err: %v", namespace, csvName, err)
+		}
+
+		gate.unresolved = append(gate.unresolved, operator)
+
+		return
+	}
+
+	phase, _, _ := unstructured.NestedString(csv.Object, "status", "phase")
+	if phase == csvPhaseSucceeded {
+		return
+	}
+
+	klog.Infof("the cluster service version %v/%v is in phase %q, holding back its custom resources", namespace, csvName, phase)
+
+	for _, gk := range getCSVOwnedKinds(csv) {
+		gate.ownedKinds[gk] = namespace + "/" + csvName
+	}
+}
+
+// getCSVOwnedKinds returns the kinds of the custom resource definitions owned by the cluster service version
+func getCSVOwnedKinds(csv *unstructured.Unstructured) []schema.GroupKind {
+	owned, _, _ := unstructured.NestedSlice(csv.Object, "spec", "customresourcedefinitions", "owned")
+
+	kinds := []schema.GroupKind{}
+
+	for _, item := range owned {
+		crd, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		// the name of an owned CRD is <plural>.<group>
+		name, _, _ := unstructured.NestedString(crd, "name")
+		kind, _, _ := unstructured.NestedString(crd, "kind")
+
+		parts := strings.SplitN(name, ".", 2)
+		if len(parts) != 2 || kind == "" {
+			continue
+		}
+
+		kinds = append(kinds, schema.GroupKind{Group: parts[1], Kind: kind})
+	}
+
+	return kinds
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func olmResource(kind, name string, status map[string]interface{}) *unstructured.Unstructured {
+	rsc := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	rsc.SetAPIVersion(olmGroup + "/v1alpha1")
+	rsc.SetKind(kind)
+	rsc.SetNamespace("operators")
+	rsc.SetName(name)
+
+	if status != nil {
+		rsc.Object["status"] = status
+	}
+
+	return rsc
+}
+
+func TestSortOLMResources(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	resources := []ResourceUnit{}
+
+	for _, gvk := range []schema.GroupVersionKind{
+		{Group: "etcd.database.coreos.com", Version: "v1beta2", Kind: "EtcdCluster"},
+		{Group: olmGroup, Version: "v1alpha1", Kind: "Subscription"},
+		{Group: olmGroup, Version: "v1", Kind: "OperatorGroup"},
+		{Version: "v1", Kind: "Namespace"},
+	} {
+		rsc := &unstructured.Unstructured{}
+		rsc.SetGroupVersionKind(gvk)
+		resources = append(resources, ResourceUnit{Resource: rsc, Gvk: gvk})
+	}
+
+	kinds := []string{}
+	for _, resource := range sortResourcesByWave(types.NamespacedName{Namespace: "ns", Name: "appsub"}, resources) {
+		kinds = append(kinds, resource.Gvk.Kind)
+	}
+
+	g.Expect(kinds).To(gomega.Equal([]string{"Namespace", "OperatorGroup", "Subscription", "EtcdCluster"}))
+}
+
+func TestOperatorGate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	etcdCluster := schema.GroupKind{Group: "etcd.database.coreos.com", Kind: "EtcdCluster"}
+	backup := schema.GroupKind{Group: "backup.example.com", Kind: "Backup"}
+
+	csv := olmResource("ClusterServiceVersion", "etcdoperator.v0.9.4", map[string]interface{}{"phase": "Installing"})
+	csv.Object["spec"] = map[string]interface{}{
+		"customresourcedefinitions": map[string]interface{}{
+			"owned": []interface{}{
+				map[string]interface{}{"name": "etcdclusters.etcd.database.coreos.com", "kind": "EtcdCluster", "version": "v1beta2"},
+			},
+		},
+	}
+
+	sync := &KubeSynchronizer{
+		DynamicClient: dynamicfake.NewSimpleDynamicClient(apiruntime.NewScheme(),
+			olmResource("Subscription", "etcd", map[string]interface{}{"installedCSV": "etcdoperator.v0.9.4"}),
+			olmResource("Subscription", "backup", nil),
+			csv),
+	}
+
+	// the kinds owned by a CSV that has not succeeded yet wait for it
+	gate := newOperatorGate()
+	sync.observeOperator(gate, olmResource("Subscription", "etcd", nil))
+	g.Expect(gate.waitingFor(etcdCluster, true)).To(gomega.Equal("ClusterServiceVersion operators/etcdoperator.v0.9.4"))
+	g.Expect(gate.waitingFor(backup, false)).To(gomega.BeEmpty())
+
+	// the kinds not served yet wait for the operators without a CSV
+	sync.observeOperator(gate, olmResource("Subscription", "backup", nil))
+	g.Expect(gate.waitingFor(backup, false)).To(gomega.Equal("operator operators/backup"))
+	g.Expect(gate.waitingFor(backup, true)).To(gomega.BeEmpty())
+	g.Expect(gate.waitingFor(schema.GroupKind{Group: olmGroup, Kind: "OperatorGroup"}, false)).To(gomega.BeEmpty())
+
+	// nothing waits for a succeeded CSV
+	csv.Object["status"] = map[string]interface{}{"phase": csvPhaseSucceeded}
+	sync.DynamicClient = dynamicfake.NewSimpleDynamicClient(apiruntime.NewScheme(), csv)
+
+	gate = newOperatorGate()
+	sync.observeOperator(gate, olmResource("ClusterServiceVersion", "etcdoperator.v0.9.4", nil))
+	g.Expect(gate.waitingFor(etcdCluster, true)).To(gomega.BeEmpty())
+}
+
+func TestWaitingForSecretCRD(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	sealedSecret := schema.GroupVersionKind{Group: "bitnami.com", Version: "v1alpha1", Kind: "SealedSecret"}
+	noMatch := fmt.Errorf("failed to get GVR from restmapping: %w",
+		&meta.NoKindMatchError{GroupKind: sealedSecret.GroupKind(), SearchedVersions: []string{"v1alpha1"}})
+
+	g.Expect(waitingForSecretCRD(sealedSecret, noMatch)).To(gomega.Equal("the SealedSecret CRD of Sealed Secrets"))
+	g.Expect(waitingForSecretCRD(sealedSecret, nil)).To(gomega.BeEmpty())
+	g.Expect(waitingForSecretCRD(sealedSecret, errors.New("connection refused"))).To(gomega.BeEmpty())
+	g.Expect(waitingForSecretCRD(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"},
+		noMatch)).To(gomega.BeEmpty())
+}
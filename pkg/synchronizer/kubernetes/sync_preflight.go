@@ -0,0 +1,393 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"sort"
+	"strings"
+	gosync "sync"
+	"time"
+
+	authzv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	appSubStatusV1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1alpha1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+const (
+	// ReasonPreflightFailed prefixes the subscription status reason when the pre-flight check of its resources failed
+	ReasonPreflightFailed = "PreflightFailed"
+
+	// accessReviewTTL is how long the access reviews are reused, RBAC changes are picked up after it
+	accessReviewTTL = time.Minute
+)
+
+// PreflightError lists the namespaces and the permissions missing to apply the resources of a subscription
+type PreflightError struct {
+	MissingNamespaces  []string
+	MissingPermissions []string
+}
+
+func (e *PreflightError) Error() string {
+	msgs := []string{}
+
+	if len(e.MissingNamespaces) > 0 {
+		msgs = append(msgs, "missing namespaces, which can't be created: "+strings.Join(e.MissingNamespaces, ", "))
+	}
+
+	if len(e.MissingPermissions) > 0 {
+		msgs = append(msgs, "missing permissions: "+strings.Join(e.MissingPermissions, "; "))
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+type accessKey struct {
+	// user and groups are the subject of the review, the synchronizer identity if they are empty
+	user      string
+	groups    string
+	gvr       schema.GroupVersionResource
+	namespace string
+	verb      string
+}
+
+type accessReview struct {
+	allowed  bool
+	reviewed time.Time
+}
+
+// accessReviewCache caches the access reviews of the synchronizer identity and of the appsub identities
+type accessReviewCache struct {
+	mtx     gosync.Mutex
+	reviews map[accessKey]accessReview
+}
+
+// runPreflight checks the resources can be applied, and sets or clears the pre-flight failure of the appsub status.
+// The check is skipped if the synchronizer has no authorization client.
+func (sync *KubeSynchronizer) runPreflight(appsub *appv1alpha1.Subscription, resources []ResourceUnit,
+	allowlist, denyList map[string]map[string]string, isAdmin bool) error {
+	if sync.authClient == nil {
+		return nil
+	}
+
+	err := sync.preflightCheck(appsub, resources, allowlist, denyList, isAdmin)
+
+	perr := &PreflightError{}
+	if err != nil && !goerrors.As(err, &perr) {
+		// the apply reports its own errors if the check itself can't be done
+		klog.Warningf("skipping the pre-flight check of appsub %v/%v, err: %v", appsub.GetNamespace(), appsub.GetName(), err)
+
+		return nil
+	}
+
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+
+	utils.UpdateFailureReasonStatus(sync.LocalClient, appsub, ReasonPreflightFailed, msg)
+
+	return err
+}
+
+// preflightCheck returns a PreflightError if the namespaces of the resources don't exist and can't be created, or if
+// the synchronizer identity can't get, create and update the resources. The resources the apply would skip or fail
+// for other reasons are not checked.
+func (sync *KubeSynchronizer) preflightCheck(appsub *appv1alpha1.Subscription, resources []ResourceUnit,
+	allowlist, denyList map[string]map[string]string, isAdmin bool) error {
+	hostSub := types.NamespacedName{Namespace: appsub.GetNamespace(), Name: appsub.GetName()}
+
+	// the namespaces of the set are created before the other resources
+	subscribedNamespaces := map[string]bool{}
+
+	for _, resource := range resources {
+		if resource.Gvk.Group == "" && resource.Gvk.Kind == "Namespace" {
+			subscribedNamespaces[resource.Resource.GetName()] = true
+		}
+	}
+
+	checkedNamespaces := map[string]bool{}
+	missingNamespaces := []string{}
+
+	// the missing verbs per resource and namespace, in the order of the resources
+	missingVerbs := map[string][]string{}
+	permissions := []string{}
+
+	for _, resource := range resources {
+		resource := resource
+
+		template, err := sync.OverrideResource(hostSub, &resource)
+		if err != nil {
+			continue
+		}
+
+		if utils.IsResourceDenied(*template, denyList, isAdmin) || !utils.IsResourceAllowed(*template, allowlist, isAdmin) {
+			continue
+		}
+
+		gvr, namespaced, err := sync.getGVRfromGVK(resource.Gvk.Group, resource.Gvk.Version, resource.Gvk.Kind)
+		if err != nil {
+			continue
+		}
+
+		namespace := ""
+
+		if namespaced {
+			namespace = template.GetNamespace()
+
+			if namespace != "" && !checkedNamespaces[namespace] && !subscribedNamespaces[namespace] {
+				checkedNamespaces[namespace] = true
+
+				exists, err := sync.namespaceExists(namespace)
+				if err != nil {
+					return err
+				}
+
+				if !exists {
+					// the apply creates the missing namespaces
+					allowed, err := sync.isAllowed(namespaceGVR, "", "create")
+					if err != nil {
+						return err
+					}
+
+					if !allowed {
+						missingNamespaces = append(missingNamespaces, namespace)
+					}
+				}
+			}
+		}
+
+		verbs := []string{"get", "create", "update"}
+		if isSpecialResource(gvr) {
+			verbs[2] = "patch"
+		}
+
+		permission := gvr.GroupResource().String()
+		if namespace != "" {
+			permission += " in namespace " + namespace
+		}
+
+		for _, verb := range verbs {
+			allowed, err := sync.isAllowed(gvr, namespace, verb)
+			if err != nil {
+				return err
+			}
+
+			if allowed || containsString(missingVerbs[permission], verb) {
+				continue
+			}
+
+			if len(missingVerbs[permission]) == 0 {
+				permissions = append(permissions, permission)
+			}
+
+			missingVerbs[permission] = append(missingVerbs[permission], verb)
+		}
+	}
+
+	if len(missingNamespaces) == 0 && len(permissions) == 0 {
+		return nil
+	}
+
+	sort.Strings(missingNamespaces)
+
+	perr := &PreflightError{MissingNamespaces: missingNamespaces}
+
+	for _, permission := range permissions {
+		perr.MissingPermissions = append(perr.MissingPermissions, strings.Join(missingVerbs[permission], ", ")+" "+permission)
+	}
+
+	return perr
+}
+
+func (sync *KubeSynchronizer) namespaceExists(namespace string) (bool, error) {
+	_, err := sync.DynamicClient.Resource(namespaceGVR).Get(context.TODO(), namespace, metav1.GetOptions{})
+	if err == nil {
+		return true, nil
+	}
+
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("failed to get namespace %v, err: %w", namespace, err)
+}
+
+// CanDeployResource returns true if the identity of the appsub, from its user-identity and user-group annotations,
+// can get, create and update the resource in its namespace. The synchronizer identity can deploy anywhere, so an
+// appsub without an identity, or a synchronizer without an authorization client, can't.
+func (sync *KubeSynchronizer) CanDeployResource(appsub *appv1alpha1.Subscription, rsc *unstructured.Unstructured) bool {
+	annotations := appsub.GetAnnotations()
+	user := utils.Base64StringDecode(strings.TrimSpace(annotations[appv1alpha1.AnnotationUserIdentity]))
+	groups := utils.Base64StringDecode(strings.TrimSpace(annotations[appv1alpha1.AnnotationUserGroup]))
+
+	if user == "" && groups == "" {
+		klog.Infof("The appsub %v/%v has no user identity to review the access to %v %v", appsub.Namespace,
+			appsub.Name, rsc.GetKind(), rsc.GetName())
+
+		return false
+	}
+
+	if sync.authClient == nil {
+		klog.Infof("No authorization client to review the access of the appsub %v/%v", appsub.Namespace, appsub.Name)
+
+		return false
+	}
+
+	gvk := rsc.GroupVersionKind()
+
+	gvr, namespaced, err := sync.getGVRfromGVK(gvk.Group, gvk.Version, gvk.Kind)
+	if err != nil {
+		klog.Infof("Failed to get GVR of %v %v, err: %v", gvk.Kind, rsc.GetName(), err)
+
+		return false
+	}
+
+	namespace := ""
+	if namespaced {
+		namespace = rsc.GetNamespace()
+	}
+
+	verbs := []string{"get", "create", "update"}
+	if isSpecialResource(gvr) {
+		verbs[2] = "patch"
+	}
+
+	for _, verb := range verbs {
+		allowed, err := sync.reviewAccess(accessKey{user: user, groups: groups, gvr: gvr, namespace: namespace, verb: verb})
+		if err != nil {
+			klog.Info(err.Error())
+
+			return false
+		}
+
+		if !allowed {
+			klog.Infof("The user %v of the appsub %v/%v can't %v %v in namespace %v", user, appsub.Namespace,
+				appsub.Name, verb, gvr.GroupResource(), namespace)
+
+			return false
+		}
+	}
+
+	return true
+}
+
+// isAllowed returns true if the synchronizer identity can do the verb on the resources of the namespace
+func (sync *KubeSynchronizer) isAllowed(gvr schema.GroupVersionResource, namespace, verb string) (bool, error) {
+	return sync.reviewAccess(accessKey{gvr: gvr, namespace: namespace, verb: verb})
+}
+
+// reviewAccess returns true if the subject of the key can do its verb on the resources of its namespace, with a self
+// subject access review for the synchronizer identity, and a subject access review for the others. The groups are
+// comma separated.
+func (sync *KubeSynchronizer) reviewAccess(key accessKey) (bool, error) {
+	// the lock is not held during the review, the concurrent reviews of a key are cached by the last one
+	sync.accessReviews.mtx.Lock()
+	review, ok := sync.accessReviews.reviews[key]
+	sync.accessReviews.mtx.Unlock()
+
+	if ok && time.Since(review.reviewed) < accessReviewTTL {
+		return review.allowed, nil
+	}
+
+	attributes := &authzv1.ResourceAttributes{
+		Namespace: key.namespace,
+		Verb:      key.verb,
+		Group:     key.gvr.Group,
+		Version:   key.gvr.Version,
+		Resource:  key.gvr.Resource,
+	}
+
+	var allowed bool
+
+	if key.user == "" && key.groups == "" {
+		ssar := &authzv1.SelfSubjectAccessReview{
+			Spec: authzv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attributes},
+		}
+
+		result, err := sync.authClient.AuthorizationV1().SelfSubjectAccessReviews().Create(context.TODO(), ssar, metav1.CreateOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to review the %v access to %v, err: %w", key.verb, key.gvr.GroupResource(), err)
+		}
+
+		allowed = result.Status.Allowed
+	} else {
+		sar := &authzv1.SubjectAccessReview{
+			Spec: authzv1.SubjectAccessReviewSpec{User: key.user, ResourceAttributes: attributes},
+		}
+
+		if key.groups != "" {
+			sar.Spec.Groups = strings.Split(key.groups, ",")
+		}
+
+		result, err := sync.authClient.AuthorizationV1().SubjectAccessReviews().Create(context.TODO(), sar, metav1.CreateOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to review the %v access of %v to %v, err: %w", key.verb, key.user,
+				key.gvr.GroupResource(), err)
+		}
+
+		allowed = result.Status.Allowed
+	}
+
+	sync.accessReviews.mtx.Lock()
+	defer sync.accessReviews.mtx.Unlock()
+
+	if sync.accessReviews.reviews == nil {
+		sync.accessReviews.reviews = map[accessKey]accessReview{}
+	}
+
+	sync.accessReviews.reviews[key] = accessReview{allowed: allowed, reviewed: time.Now()}
+
+	return allowed, nil
+}
+
+// preflightFailedStatuses reports all the resources failed by the pre-flight check, the reason is set in the
+// appsub status
+func preflightFailedStatuses(resources []ResourceUnit) []SubscriptionUnitStatus {
+	statuses := make([]SubscriptionUnitStatus, 0, len(resources))
+
+	for _, resource := range resources {
+		statuses = append(statuses, SubscriptionUnitStatus{
+			Name:       resource.Resource.GetName(),
+			Namespace:  resource.Resource.GetNamespace(),
+			APIVersion: resource.Resource.GetAPIVersion(),
+			Kind:       resource.Resource.GetKind(),
+			Phase:      string(appSubStatusV1alpha1.PackageDeployFailed),
+			Message:    "not applied, the pre-flight check of the subscription failed",
+		})
+	}
+
+	return statuses
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	authzv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestPreflightCheck(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	env, err := newScaleEnv(1, 2)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// the identity can't create namespaces nor update the configmaps
+	denied := map[string]bool{"create namespaces": true, "update configmaps": true}
+	reviews := 0
+
+	authClient := k8sfake.NewSimpleClientset()
+	authClient.PrependReactor("create", "selfsubjectaccessreviews",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			reviews++
+
+			ssar := action.(clienttesting.CreateAction).GetObject().(*authzv1.SelfSubjectAccessReview)
+			attrs := ssar.Spec.ResourceAttributes
+			ssar.Status.Allowed = !denied[attrs.Verb+" "+attrs.Resource]
+
+			return true, ssar, nil
+		})
+
+	env.sync.authClient = authClient
+
+	cm := &unstructured.Unstructured{}
+	cm.SetGroupVersionKind(configMapGVK)
+	cm.SetName("appsub-0-cm-missing")
+	cm.SetNamespace("missing-ns")
+
+	resources := append(copyResourceUnits(env.resources[0]), ResourceUnit{Resource: cm, Gvk: configMapGVK})
+
+	g.Expect(env.sync.ProcessSubResources(env.appsubs[0], resources, nil, nil, false)).To(gomega.Succeed())
+
+	appsub := &appv1.Subscription{}
+	appsubKey := types.NamespacedName{Namespace: env.appsubs[0].Namespace, Name: env.appsubs[0].Name}
+	g.Expect(env.sync.LocalClient.Get(context.TODO(), appsubKey, appsub)).To(gomega.Succeed())
+
+	g.Expect(appsub.Status.Phase).To(gomega.Equal(appv1.SubscriptionFailed))
+	g.Expect(strings.HasPrefix(appsub.Status.Reason, ReasonPreflightFailed+": ")).To(gomega.BeTrue())
+	g.Expect(appsub.Status.Reason).To(gomega.ContainSubstring("missing namespaces, which can't be created: missing-ns"))
+	g.Expect(appsub.Status.Reason).To(gomega.ContainSubstring(
+		"missing permissions: update configmaps in namespace scale-ns; update configmaps in namespace missing-ns"))
+
+	// no resource is applied
+	configMaps := env.dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+	_, err = configMaps.Namespace(scaleNamespace).Get(context.TODO(), "appsub-0-cm-0", metav1.GetOptions{})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	// the access reviews are cached
+	reviewed := reviews
+
+	g.Expect(env.sync.ProcessSubResources(env.appsubs[0], copyResourceUnits(env.resources[0]), nil, nil, false)).
+		To(gomega.Succeed())
+	g.Expect(reviews).To(gomega.Equal(reviewed))
+
+	// once granted, the resources are applied and the failure is cleared
+	denied = map[string]bool{}
+	env.sync.accessReviews.reviews = nil
+
+	g.Expect(env.sync.ProcessSubResources(env.appsubs[0], copyResourceUnits(env.resources[0]), nil, nil, false)).
+		To(gomega.Succeed())
+
+	g.Expect(env.sync.LocalClient.Get(context.TODO(), appsubKey, appsub)).To(gomega.Succeed())
+	g.Expect(appsub.Status.Reason).To(gomega.BeEmpty())
+
+	_, err = configMaps.Namespace(scaleNamespace).Get(context.TODO(), "appsub-0-cm-0", metav1.GetOptions{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// the subscribers preserve the namespace of a resource only if the user of the appsub can deploy it there, the
+	// admin can, the developer is only allowed in the namespace of the appsub
+	authClient.PrependReactor("create", "subjectaccessreviews",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			sar := action.(clienttesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+			sar.Status.Allowed = sar.Spec.User == "admin" || sar.Spec.ResourceAttributes.Namespace == scaleNamespace
+
+			return true, sar, nil
+		})
+
+	adminSub := env.appsubs[0].DeepCopy()
+	adminSub.SetAnnotations(map[string]string{appv1.AnnotationUserIdentity: base64.StdEncoding.EncodeToString([]byte("admin"))})
+	g.Expect(env.sync.CanDeployResource(adminSub, cm)).To(gomega.BeTrue())
+
+	devSub := env.appsubs[0].DeepCopy()
+	devSub.SetAnnotations(map[string]string{
+		appv1.AnnotationUserIdentity: base64.StdEncoding.EncodeToString([]byte("dev")),
+		appv1.AnnotationUserGroup:    base64.StdEncoding.EncodeToString([]byte("system:authenticated,devs")),
+	})
+	g.Expect(env.sync.CanDeployResource(devSub, cm)).To(gomega.BeFalse())
+
+	// the synchronizer identity is allowed, but it doesn't stand for an appsub without identity
+	g.Expect(env.sync.CanDeployResource(env.appsubs[0], cm)).To(gomega.BeFalse())
+}
@@ -0,0 +1,183 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	jsonpatch "k8s.io/apimachinery/pkg/util/jsonmergepatch"
+
+	appSubStatusV1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1alpha1"
+)
+
+// Render is the resources of the last sync of an appsub, as they were applied
+type Render struct {
+	Time      metav1.Time                  `json:"time"`
+	Resources []*unstructured.Unstructured `json:"resources"`
+	// Failures are the resources of the sync that were not deployed
+	Failures []ResourceFailure `json:"failures,omitempty"`
+}
+
+// ResourceFailure is a resource the last sync of an appsub didn't deploy, with the phase and message of its status
+type ResourceFailure struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Phase      string `json:"phase"`
+	Message    string `json:"message,omitempty"`
+}
+
+// ResourceDiff is the change re-applying the last render would make to a resource
+type ResourceDiff struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	// Missing is true if the resource is not on the cluster
+	Missing bool `json:"missing,omitempty"`
+	// Patch is the JSON merge patch bringing the resource back to the render, empty if it matches the render
+	Patch json.RawMessage `json:"patch,omitempty"`
+}
+
+// recordRender keeps the resources applied by the last sync of the appsub, and the ones it failed to deploy
+func (sync *KubeSynchronizer) recordRender(hostSub types.NamespacedName, resources []*unstructured.Unstructured,
+	statuses []SubscriptionUnitStatus) {
+	failures := []ResourceFailure{}
+
+	for _, status := range statuses {
+		if status.Phase == string(appSubStatusV1alpha1.PackageDeployed) {
+			continue
+		}
+
+		failures = append(failures, ResourceFailure{
+			APIVersion: status.APIVersion,
+			Kind:       status.Kind,
+			Namespace:  status.Namespace,
+			Name:       status.Name,
+			Phase:      status.Phase,
+			Message:    status.Message,
+		})
+	}
+
+	sync.rmtx.Lock()
+	defer sync.rmtx.Unlock()
+
+	if sync.renders == nil {
+		sync.renders = map[types.NamespacedName]*Render{}
+	}
+
+	sync.renders[hostSub] = &Render{Time: metav1.Now(), Resources: resources, Failures: failures}
+}
+
+// forgetRender drops the last render of the appsub once its resources are purged
+func (sync *KubeSynchronizer) forgetRender(hostSub types.NamespacedName) {
+	sync.rmtx.Lock()
+	defer sync.rmtx.Unlock()
+
+	delete(sync.renders, hostSub)
+	delete(sync.applied, hostSub)
+}
+
+// GetLastRender returns the resources applied by the last sync of the appsub, false if it hasn't synced since the
+// synchronizer started
+func (sync *KubeSynchronizer) GetLastRender(hostSub types.NamespacedName) (*Render, bool) {
+	sync.rmtx.Lock()
+	defer sync.rmtx.Unlock()
+
+	render, ok := sync.renders[hostSub]
+
+	return render, ok
+}
+
+// DiffLastRender compares the resources of the last render of the appsub to the ones on the cluster. A resource
+// changed outside of the subscription has the patch the next sync would apply to it.
+func (sync *KubeSynchronizer) DiffLastRender(hostSub types.NamespacedName) ([]ResourceDiff, error) {
+	render, ok := sync.GetLastRender(hostSub)
+	if !ok {
+		return nil, fmt.Errorf("appsub %v has not synced yet", hostSub)
+	}
+
+	return sync.diffResources(render.Resources)
+}
+
+// diffResources compares the resources to the ones on the cluster, a resource that doesn't match has the patch the
+// merge apply would make to it
+func (sync *KubeSynchronizer) diffResources(resources []*unstructured.Unstructured) ([]ResourceDiff, error) {
+	diffs := []ResourceDiff{}
+
+	for _, tpl := range resources {
+		gvk := tpl.GroupVersionKind()
+
+		gvr, namespaced, err := sync.getGVRfromGVK(gvk.Group, gvk.Version, gvk.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		diff := ResourceDiff{APIVersion: tpl.GetAPIVersion(), Kind: tpl.GetKind(), Name: tpl.GetName()}
+
+		if namespaced {
+			diff.Namespace = tpl.GetNamespace()
+		}
+
+		live, err := sync.DynamicClient.Resource(gvr).Namespace(diff.Namespace).Get(context.TODO(), tpl.GetName(),
+			metav1.GetOptions{})
+
+		switch {
+		case errors.IsNotFound(err):
+			diff.Missing = true
+		case err != nil:
+			return nil, err
+		default:
+			if diff.Patch, err = getRenderPatch(tpl, live); err != nil {
+				return nil, err
+			}
+		}
+
+		diffs = append(diffs, diff)
+	}
+
+	return diffs, nil
+}
+
+// getRenderPatch returns the JSON merge patch the merge apply would make to the live resource, nil if there is none
+func getRenderPatch(tpl, live *unstructured.Unstructured) (json.RawMessage, error) {
+	tplb, err := tpl.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	liveb, err := live.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := jsonpatch.CreateThreeWayJSONMergePatch(tplb, tplb, liveb)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(patch) == "{}" {
+		return nil, nil
+	}
+
+	return patch, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func renderConfigMap(name string, data map[string]interface{}) *unstructured.Unstructured {
+	cm := &unstructured.Unstructured{Object: map[string]interface{}{"data": data}}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetNamespace("ns1")
+	cm.SetName(name)
+
+	return cm
+}
+
+func TestDiffLastRender(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(configMapGVK, meta.RESTScopeNamespace)
+
+	live := renderConfigMap("settings", map[string]interface{}{"mode": "slow", "extra": "kept"})
+
+	sync := &KubeSynchronizer{
+		DynamicClient: dynamicfake.NewSimpleDynamicClient(apiruntime.NewScheme(), live,
+			renderConfigMap("unchanged", map[string]interface{}{"mode": "fast"})),
+		RestMapper: restMapper,
+	}
+
+	hostSub := types.NamespacedName{Namespace: "ns1", Name: "sub"}
+
+	_, err := sync.DiffLastRender(hostSub)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	sync.recordRender(hostSub, []*unstructured.Unstructured{
+		renderConfigMap("settings", map[string]interface{}{"mode": "fast"}),
+		renderConfigMap("unchanged", map[string]interface{}{"mode": "fast"}),
+		renderConfigMap("deleted", map[string]interface{}{"mode": "fast"}),
+	}, []SubscriptionUnitStatus{
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns1", Name: "settings", Phase: "Deployed"},
+		{APIVersion: "v1", Kind: "Secret", Namespace: "ns1", Name: "denied", Phase: "Failed", Message: "denied"},
+	})
+
+	render, ok := sync.GetLastRender(hostSub)
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(render.Failures).To(gomega.Equal([]ResourceFailure{
+		{APIVersion: "v1", Kind: "Secret", Namespace: "ns1", Name: "denied", Phase: "Failed", Message: "denied"},
+	}))
+
+	diffs, err := sync.DiffLastRender(hostSub)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(diffs).To(gomega.HaveLen(3))
+
+	// the fields set outside of the render are not part of the diff
+	g.Expect(string(diffs[0].Patch)).To(gomega.Equal(`{"data":{"mode":"fast"}}`))
+	g.Expect(diffs[1].Patch).To(gomega.BeNil())
+	g.Expect(diffs[1].Missing).To(gomega.BeFalse())
+	g.Expect(diffs[2].Missing).To(gomega.BeTrue())
+
+	sync.forgetRender(hostSub)
+
+	_, ok = sync.GetLastRender(hostSub)
+	g.Expect(ok).To(gomega.BeFalse())
+}
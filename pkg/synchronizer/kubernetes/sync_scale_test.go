@@ -0,0 +1,479 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	appSubStatusV1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1alpha1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+// The scale benchmarks and the soak test simulate N appsubs of M resources each against fake API servers:
+//
+//	go test ./pkg/synchronizer/kubernetes/ -run '^$' -bench BenchmarkProcessSubResources -benchmem
+//	SCALE_SOAK_DURATION=10m go test ./pkg/synchronizer/kubernetes/ -run TestSoakProcessSubResources -v
+//
+// SCALE_SUBSCRIPTIONS and SCALE_RESOURCES are comma separated lists of the sizes to run.
+const (
+	scaleSubscriptionsEnv = "SCALE_SUBSCRIPTIONS"
+	scaleResourcesEnv     = "SCALE_RESOURCES"
+	scaleSoakDurationEnv  = "SCALE_SOAK_DURATION"
+
+	scaleNamespace = "scale-ns"
+)
+
+var configMapGVK = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+// countingClient counts the calls to the API server made through the controller-runtime client
+type countingClient struct {
+	client.Client
+	calls *int64
+}
+
+func (c countingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	atomic.AddInt64(c.calls, 1)
+
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c countingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	atomic.AddInt64(c.calls, 1)
+
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c countingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	atomic.AddInt64(c.calls, 1)
+
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c countingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	atomic.AddInt64(c.calls, 1)
+
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c countingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	atomic.AddInt64(c.calls, 1)
+
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c countingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	atomic.AddInt64(c.calls, 1)
+
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c countingClient) Status() client.StatusWriter {
+	return countingStatusWriter{StatusWriter: c.Client.Status(), calls: c.calls}
+}
+
+type countingStatusWriter struct {
+	client.StatusWriter
+	calls *int64
+}
+
+func (w countingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	atomic.AddInt64(w.calls, 1)
+
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w countingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	atomic.AddInt64(w.calls, 1)
+
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+// scaleEnv is a synchronizer of a managed cluster whose API servers are faked
+type scaleEnv struct {
+	sync        *KubeSynchronizer
+	dynamic     *dynamicfake.FakeDynamicClient
+	clientCalls int64
+	appsubs     []*appv1.Subscription
+	resources   [][]ResourceUnit
+}
+
+func newScaleEnv(subscriptions, resources int) (*scaleEnv, error) {
+	s := apiruntime.NewScheme()
+
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		return nil, err
+	}
+
+	if err := appv1.SchemeBuilder.AddToScheme(s); err != nil {
+		return nil, err
+	}
+
+	if err := appSubStatusV1alpha1.AddToScheme(s); err != nil {
+		return nil, err
+	}
+
+	env := &scaleEnv{}
+
+	ns := &corev1.Namespace{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{Name: scaleNamespace},
+	}
+
+	env.dynamic = dynamicfake.NewSimpleDynamicClient(s, ns)
+
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(configMapGVK, meta.RESTScopeNamespace)
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+
+	localClient := countingClient{
+		Client: fake.NewClientBuilder().WithScheme(s).Build(),
+		calls:  &env.clientCalls,
+	}
+
+	env.sync = &KubeSynchronizer{
+		LocalClient:          localClient,
+		LocalNonCachedClient: localClient,
+		RemoteClient:         localClient,
+		DynamicClient:        env.dynamic,
+		RestMapper:           restMapper,
+		SynchronizerID:       &types.NamespacedName{Name: "cluster1", Namespace: "cluster1"},
+		Extension:            defaultExtension,
+		eventrecorder:        &utils.EventRecorder{EventRecorder: &record.FakeRecorder{}},
+	}
+
+	for i := 0; i < subscriptions; i++ {
+		appsub := &appv1.Subscription{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("appsub-%d", i), Namespace: scaleNamespace},
+		}
+
+		if err := localClient.Create(context.TODO(), appsub); err != nil {
+			return nil, err
+		}
+
+		units := make([]ResourceUnit, 0, resources)
+
+		for j := 0; j < resources; j++ {
+			cm := &unstructured.Unstructured{}
+			cm.SetGroupVersionKind(configMapGVK)
+			cm.SetName(fmt.Sprintf("%s-cm-%d", appsub.Name, j))
+			cm.SetNamespace(scaleNamespace)
+
+			if err := unstructured.SetNestedField(cm.Object, strconv.Itoa(j), "data", "index"); err != nil {
+				return nil, err
+			}
+
+			units = append(units, ResourceUnit{Resource: cm, Gvk: configMapGVK})
+		}
+
+		env.appsubs = append(env.appsubs, appsub)
+		env.resources = append(env.resources, units)
+	}
+
+	atomic.StoreInt64(&env.clientCalls, 0)
+
+	return env, nil
+}
+
+// syncAll runs one sync cycle of all the appsubs and returns the number of API calls it made
+func (env *scaleEnv) syncAll() (int64, error) {
+	for i, appsub := range env.appsubs {
+		if err := env.sync.ProcessSubResources(appsub, copyResourceUnits(env.resources[i]), nil, nil, false); err != nil {
+			return 0, err
+		}
+	}
+
+	calls := atomic.SwapInt64(&env.clientCalls, 0) + int64(len(env.dynamic.Actions()))
+	env.dynamic.ClearActions()
+
+	return calls, nil
+}
+
+// copyResourceUnits copies the resources as the subscribers build new ones on every cycle
+func copyResourceUnits(units []ResourceUnit) []ResourceUnit {
+	copied := make([]ResourceUnit, 0, len(units))
+
+	for _, unit := range units {
+		copied = append(copied, ResourceUnit{Resource: unit.Resource.DeepCopy(), Gvk: unit.Gvk})
+	}
+
+	return copied
+}
+
+func getScaleSizes(env string, defaults []int) ([]int, error) {
+	value := os.Getenv(env)
+	if value == "" {
+		return defaults, nil
+	}
+
+	sizes := []int{}
+
+	for _, s := range strings.Split(value, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid %v size %v", env, s)
+		}
+
+		sizes = append(sizes, size)
+	}
+
+	return sizes, nil
+}
+
+func silenceKlog() func() {
+	klog.LogToStderr(false)
+	klog.SetOutput(ioutil.Discard)
+
+	return func() {
+		klog.LogToStderr(true)
+		klog.SetOutput(os.Stderr)
+	}
+}
+
+func heapInuseMB() float64 {
+	runtime.GC()
+
+	stats := runtime.MemStats{}
+	runtime.ReadMemStats(&stats)
+
+	return float64(stats.HeapInuse) / (1 << 20)
+}
+
+// BenchmarkProcessSubResources measures a sync cycle of all the appsubs. The first cycle creates the resources, the
+// next ones find the resources unchanged, which is the steady state of a managed cluster.
+func BenchmarkProcessSubResources(b *testing.B) {
+	defer silenceKlog()()
+
+	subscriptions, err := getScaleSizes(scaleSubscriptionsEnv, []int{10, 50})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	resources, err := getScaleSizes(scaleResourcesEnv, []int{10, 100})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, n := range subscriptions {
+		for _, m := range resources {
+			n, m := n, m
+
+			b.Run(fmt.Sprintf("create/subs=%d/resources=%d", n, m), func(b *testing.B) {
+				benchmarkSyncCycles(b, n, m, false)
+			})
+
+			b.Run(fmt.Sprintf("steady/subs=%d/resources=%d", n, m), func(b *testing.B) {
+				benchmarkSyncCycles(b, n, m, true)
+			})
+		}
+	}
+}
+
+func benchmarkSyncCycles(b *testing.B, subscriptions, resources int, steady bool) {
+	b.ReportAllocs()
+
+	var (
+		env      *scaleEnv
+		err      error
+		calls    int64
+		duration time.Duration
+	)
+
+	for i := 0; i < b.N; i++ {
+		if env == nil || !steady {
+			b.StopTimer()
+
+			env, err = newScaleEnv(subscriptions, resources)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			if steady {
+				if _, err := env.syncAll(); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.StartTimer()
+		}
+
+		start := time.Now()
+
+		cycleCalls, err := env.syncAll()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		duration += time.Since(start)
+		calls += cycleCalls
+	}
+
+	b.StopTimer()
+
+	b.ReportMetric(float64(duration.Nanoseconds())/float64(b.N*subscriptions), "ns/appsub")
+	b.ReportMetric(float64(calls)/float64(b.N), "api-calls/op")
+	b.ReportMetric(heapInuseMB(), "heap-MB")
+}
+
+// TestSoakProcessSubResources syncs the appsubs for SCALE_SOAK_DURATION and fails if the memory or the API calls of a
+// cycle keep growing.
+func TestSoakProcessSubResources(t *testing.T) {
+	value := os.Getenv(scaleSoakDurationEnv)
+	if value == "" {
+		t.Skip(scaleSoakDurationEnv + " is not set")
+	}
+
+	soakDuration, err := time.ParseDuration(value)
+	if err != nil {
+		t.Fatalf("invalid %v %v: %v", scaleSoakDurationEnv, value, err)
+	}
+
+	defer silenceKlog()()
+
+	subscriptions, err := getScaleSizes(scaleSubscriptionsEnv, []int{50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resources, err := getScaleSizes(scaleResourcesEnv, []int{100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := newScaleEnv(subscriptions[0], resources[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first cycle creates the resources, the baseline is the first steady cycle
+	if _, err := env.syncAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	baseCalls, err := env.syncAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	baseHeap := heapInuseMB()
+	deadline := time.Now().Add(soakDuration)
+	lastReport := time.Now()
+
+	for cycle := 1; time.Now().Before(deadline); cycle++ {
+		start := time.Now()
+
+		calls, err := env.syncAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if calls != baseCalls {
+			t.Fatalf("cycle %d made %d API calls, the first steady cycle made %d", cycle, calls, baseCalls)
+		}
+
+		if time.Since(lastReport) >= time.Minute || !time.Now().Before(deadline) {
+			heap := heapInuseMB()
+
+			t.Logf("cycle %d: %v, %d API calls, heap %.1f MB", cycle, time.Since(start), calls, heap)
+
+			if heap > 2*baseHeap {
+				t.Fatalf("heap grew from %.1f MB to %.1f MB", baseHeap, heap)
+			}
+
+			lastReport = time.Now()
+		}
+	}
+}
+
+// progressClient records the apply progress changes of the appsub status updates, the other status updates carry the
+// last progress
+type progressClient struct {
+	client.Client
+	progress *[]string
+}
+
+func (c progressClient) Status() client.StatusWriter {
+	return progressStatusWriter{StatusWriter: c.Client.Status(), progress: c.progress}
+}
+
+type progressStatusWriter struct {
+	client.StatusWriter
+	progress *[]string
+}
+
+func (w progressStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if appsub, ok := obj.(*appv1.Subscription); ok && appsub.Status.ApplyProgress != nil {
+		if n := len(*w.progress); n == 0 || (*w.progress)[n-1] != appsub.Status.ApplyProgress.Message {
+			*w.progress = append(*w.progress, appsub.Status.ApplyProgress.Message)
+		}
+	}
+
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func TestApplyProgress(t *testing.T) {
+	defer silenceKlog()()
+
+	env, err := newScaleEnv(1, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	progress := []string{}
+	env.sync.LocalClient = progressClient{Client: env.sync.LocalClient, progress: &progress}
+
+	appsub := env.appsubs[0].DeepCopy()
+	appsub.SetAnnotations(map[string]string{appv1.AnnotationApplyBatchSize: "10"})
+
+	if err := env.sync.ProcessSubResources(appsub, copyResourceUnits(env.resources[0]), nil, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	wanted := []string{"applied 10 of 25", "applied 20 of 25", "applied 25 of 25"}
+	if strings.Join(progress, ", ") != strings.Join(wanted, ", ") {
+		t.Errorf("wanted progress %v, got %v", wanted, progress)
+	}
+
+	// the invalid batch sizes fall back to the default
+	appsub.SetAnnotations(map[string]string{appv1.AnnotationApplyBatchSize: "-1"})
+
+	if size := utils.GetApplyBatchSize(appsub); size != appv1.DefaultApplyBatchSize {
+		t.Errorf("wanted the default batch size %d, got %d", appv1.DefaultApplyBatchSize, size)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"errors"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+// waitingForSecretCRD returns the operator a SealedSecret or ExternalSecret resource is waiting for, if the cluster
+// doesn't serve its kind yet. The rest mapper rediscovers the kinds it doesn't know, so the resource is applied by a
+// later sync once the CRD is installed.
+func waitingForSecretCRD(gvk schema.GroupVersionKind, mappingErr error) string {
+	operator, _, ok := utils.GetSecretOperatorKind(gvk.GroupKind())
+	if !ok || !isNoMatchError(mappingErr) {
+		return ""
+	}
+
+	return "the " + gvk.Kind + " CRD of " + operator
+}
+
+// isNoMatchError returns true if the error, or the error it wraps, is a rest mapping error of an unknown kind
+func isNoMatchError(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if meta.IsNoMatchError(err) {
+			return true
+		}
+	}
+
+	return false
+}
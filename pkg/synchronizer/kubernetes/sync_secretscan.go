@@ -0,0 +1,366 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+const (
+	// ReasonSecretLeak prefixes the subscription status reason when the secret scanner blocked its resources
+	ReasonSecretLeak = "SecretLeakDetected"
+
+	// ScanPolicyWarn applies the resources flagged by the secret scanner and reports them in the logs and the events
+	// of the appsub
+	ScanPolicyWarn = "Warn"
+	// ScanPolicyBlock fails the sync of the appsub when the secret scanner flags one of its resources
+	ScanPolicyBlock = "Block"
+
+	// maxSecretFindingsReported limits the findings listed in the status and the events of the appsub
+	maxSecretFindingsReported = 5
+)
+
+// SecretScanRule flags the string values of the resources that look like inline credentials. A value is flagged if it
+// matches all the conditions set in the rule.
+type SecretScanRule struct {
+	Name string `json:"name"`
+	// Pattern is a regular expression matching the values
+	Pattern string `json:"pattern,omitempty"`
+	// KeyPattern is a regular expression matching the names of the fields of the values, or the name of the name and
+	// value pairs like the env of the containers
+	KeyPattern string `json:"keyPattern,omitempty"`
+	// MinEntropy is the minimum Shannon entropy of the values, in bits per character
+	MinEntropy float64 `json:"minEntropy,omitempty"`
+	// MinLength is the minimum length of the values
+	MinLength int `json:"minLength,omitempty"`
+	// Policy overrides the policy of the scanner for the rule
+	Policy string `json:"policy,omitempty"`
+}
+
+// SecretScanConfig is the config file of the secret scanner
+type SecretScanConfig struct {
+	// Policy is Warn or Block, Warn if not set
+	Policy string `json:"policy,omitempty"`
+	// Rules replace the default rules if set
+	Rules []SecretScanRule `json:"rules,omitempty"`
+}
+
+// DefaultSecretScanRules flag the private keys, the AWS access keys, the GitHub tokens, and the random looking values
+// of the password, secret, token and API key fields
+var DefaultSecretScanRules = []SecretScanRule{
+	{Name: "private-key", Pattern: `-----BEGIN[A-Z ]*PRIVATE KEY( BLOCK)?-----`},
+	{Name: "aws-access-key", Pattern: `\b(AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "github-token", Pattern: `\bgh[pousr]_[A-Za-z0-9]{36,}\b`},
+	{
+		Name:       "credential",
+		KeyPattern: `(?i)(passw(or)?d|secret|token|api[_-]?key|credentials?)$`,
+		MinEntropy: 3,
+		MinLength:  8,
+	},
+}
+
+// SecretFinding is a value of a resource flagged by a rule of the secret scanner, the value itself is not kept
+type SecretFinding struct {
+	Rule   string
+	Policy string
+	// Resource is the <kind>/<namespace>/<name> of the resource
+	Resource string
+	// Path is the path of the field of the value in the resource, like data.password
+	Path string
+}
+
+func (f SecretFinding) String() string {
+	return f.Resource + " " + f.Path + " (" + f.Rule + ")"
+}
+
+// SecretScanner flags the resources of the appsubs containing inline credentials or private keys before they are
+// applied
+type SecretScanner struct {
+	rules []*secretScanRule
+}
+
+type secretScanRule struct {
+	SecretScanRule
+	pattern    *regexp.Regexp
+	keyPattern *regexp.Regexp
+}
+
+// LoadSecretScanner reads the YAML or JSON config file of the secret scanner
+func LoadSecretScanner(configFile string) (*SecretScanner, error) {
+	content, err := ioutil.ReadFile(configFile) // #nosec G304 the config file is an operator flag
+	if err != nil {
+		return nil, err
+	}
+
+	config := SecretScanConfig{}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse secret scanner config %v, err: %w", configFile, err)
+	}
+
+	return NewSecretScanner(config)
+}
+
+// NewSecretScanner validates the rules of the secret scanner config and compiles their patterns
+func NewSecretScanner(config SecretScanConfig) (*SecretScanner, error) {
+	policy, err := parseScanPolicy(config.Policy, ScanPolicyWarn)
+	if err != nil {
+		return nil, fmt.Errorf("secret scanner: %w", err)
+	}
+
+	rules := config.Rules
+	if len(rules) == 0 {
+		rules = DefaultSecretScanRules
+	}
+
+	scanner := &SecretScanner{}
+
+	for _, rule := range rules {
+		if rule.Name == "" || (rule.Pattern == "" && rule.KeyPattern == "") {
+			return nil, fmt.Errorf("secret scan rule %q: name and pattern or keyPattern are required", rule.Name)
+		}
+
+		if rule.Policy, err = parseScanPolicy(rule.Policy, policy); err != nil {
+			return nil, fmt.Errorf("secret scan rule %v: %w", rule.Name, err)
+		}
+
+		compiled := &secretScanRule{SecretScanRule: rule}
+
+		if rule.Pattern != "" {
+			if compiled.pattern, err = regexp.Compile(rule.Pattern); err != nil {
+				return nil, fmt.Errorf("secret scan rule %v: invalid pattern, err: %w", rule.Name, err)
+			}
+		}
+
+		if rule.KeyPattern != "" {
+			if compiled.keyPattern, err = regexp.Compile(rule.KeyPattern); err != nil {
+				return nil, fmt.Errorf("secret scan rule %v: invalid keyPattern, err: %w", rule.Name, err)
+			}
+		}
+
+		scanner.rules = append(scanner.rules, compiled)
+	}
+
+	return scanner, nil
+}
+
+func parseScanPolicy(policy, defaultPolicy string) (string, error) {
+	switch policy {
+	case "":
+		return defaultPolicy, nil
+	case ScanPolicyWarn, ScanPolicyBlock:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown policy %v, use %v or %v", policy, ScanPolicyWarn, ScanPolicyBlock)
+	}
+}
+
+// Scan returns the values of the resources flagged by the rules. The data of the Secrets is decoded before it is
+// scanned, it is in plain text in the channel.
+func (s *SecretScanner) Scan(resources []ResourceUnit) []SecretFinding {
+	findings := []SecretFinding{}
+
+	for _, resource := range resources {
+		if resource.Resource == nil {
+			continue
+		}
+
+		obj := resource.Resource
+		name := strings.Join([]string{obj.GetKind(), obj.GetNamespace(), obj.GetName()}, "/")
+
+		isSecret := obj.GroupVersionKind().Group == "" && obj.GetKind() == "Secret"
+
+		for _, field := range sortedKeys(obj.Object) {
+			value := obj.Object[field]
+
+			if data, ok := value.(map[string]interface{}); ok && isSecret && field == "data" {
+				value = decodeSecretData(data)
+			}
+
+			s.scanValue(field, field, value, func(rule *secretScanRule, path string) {
+				findings = append(findings, SecretFinding{Rule: rule.Name, Policy: rule.Policy, Resource: name, Path: path})
+			})
+		}
+	}
+
+	return findings
+}
+
+// scanValue scans the strings of the value, key is the name of the field of the value
+func (s *SecretScanner) scanValue(path, key string, value interface{}, flag func(*secretScanRule, string)) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, field := range sortedKeys(v) {
+			fieldKey := field
+
+			// the value of the name and value pairs, like the env of the containers, is keyed by their name
+			if name, ok := v["name"].(string); ok && field == "value" {
+				fieldKey = name
+			}
+
+			s.scanValue(path+"."+field, fieldKey, v[field], flag)
+		}
+	case []interface{}:
+		for i, item := range v {
+			s.scanValue(path+"["+strconv.Itoa(i)+"]", key, item, flag)
+		}
+	case string:
+		for _, rule := range s.rules {
+			if rule.matches(key, v) {
+				flag(rule, path)
+
+				// a value is reported once
+				return
+			}
+		}
+	}
+}
+
+func (r *secretScanRule) matches(key, value string) bool {
+	if len(value) < r.MinLength {
+		return false
+	}
+
+	if r.keyPattern != nil && !r.keyPattern.MatchString(key) {
+		return false
+	}
+
+	if r.pattern != nil && !r.pattern.MatchString(value) {
+		return false
+	}
+
+	return r.MinEntropy <= 0 || shannonEntropy(value) >= r.MinEntropy
+}
+
+// shannonEntropy returns the entropy of the characters of the string, in bits per character
+func shannonEntropy(value string) float64 {
+	counts := map[rune]int{}
+	total := 0
+
+	for _, c := range value {
+		counts[c]++
+		total++
+	}
+
+	entropy := 0.0
+
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
+
+// decodeSecretData returns the decoded values of the data of a Secret, the values that are not base64 are kept as is
+func decodeSecretData(data map[string]interface{}) map[string]interface{} {
+	decoded := make(map[string]interface{}, len(data))
+
+	for key, value := range data {
+		decoded[key] = value
+
+		if encoded, ok := value.(string); ok {
+			if plain, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+				decoded[key] = string(plain)
+			}
+		}
+	}
+
+	return decoded
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// scanResources runs the secret scanner on the resources of the appsub, as they come from the channel. The findings
+// of the Warn rules are logged and recorded as events of the appsub, the findings of the Block rules fail the sync and
+// are reported in the appsub status. The values flagged are never reported.
+func (sync *KubeSynchronizer) scanResources(appsub *appv1alpha1.Subscription, resources []ResourceUnit) error {
+	if sync.secretScanner == nil {
+		return nil
+	}
+
+	hostSub := types.NamespacedName{Namespace: appsub.GetNamespace(), Name: appsub.GetName()}
+
+	warned, blocked := []SecretFinding{}, []SecretFinding{}
+
+	for _, finding := range sync.secretScanner.Scan(resources) {
+		if finding.Policy == ScanPolicyBlock {
+			blocked = append(blocked, finding)
+		} else {
+			warned = append(warned, finding)
+		}
+	}
+
+	if len(warned) > 0 {
+		msg := "possible inline credentials: " + summarizeSecretFindings(warned)
+
+		klog.Warningf("appsub %v has %v", hostSub, msg)
+
+		if sync.eventrecorder != nil {
+			sync.eventrecorder.RecordEvent(appsub, "SecretLeak", msg, fmt.Errorf("%v", msg))
+		}
+	}
+
+	msg := ""
+	if len(blocked) > 0 {
+		msg = "inline credentials: " + summarizeSecretFindings(blocked)
+	}
+
+	utils.UpdateFailureReasonStatus(sync.LocalClient, appsub, ReasonSecretLeak, msg)
+
+	if msg != "" {
+		return fmt.Errorf("the secret scanner blocked the resources of appsub %v, %v", hostSub, msg)
+	}
+
+	return nil
+}
+
+func summarizeSecretFindings(findings []SecretFinding) string {
+	listed := []string{}
+
+	for i, finding := range findings {
+		if i == maxSecretFindingsReported {
+			listed = append(listed, fmt.Sprintf("and %d more", len(findings)-i))
+
+			break
+		}
+
+		listed = append(listed, finding.String())
+	}
+
+	return strings.Join(listed, ", ")
+}
@@ -17,20 +17,25 @@ package kubernetes
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
+	appSubStatusV1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1alpha1"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
 )
 
@@ -75,6 +80,30 @@ type KubeSynchronizer struct {
 	eventrecorder          *utils.EventRecorder
 	dmtx                   sync.Mutex //this lock protect the dynamicFactory and stopCh
 	SkipAppSubStatusResDel bool       // used by helm subscriber to skip resource delete based on AppSubStatus
+
+	// standalone target cluster synchronizers by kubeconfig secret, and the packages deployed per appsub and target
+	targetClusters map[types.NamespacedName]*targetCluster
+	targetPackages map[types.NamespacedName]map[string][]appSubStatusV1alpha1.SubscriptionUnitStatus
+
+	applyHooks []ApplyHook
+	// the target cluster synchronizers have no mutator, they apply the resources mutated for the appsub
+	mutators []ManifestMutator
+	// the secret scanner of the resources of the appsubs, nil if they are not scanned
+	secretScanner *SecretScanner
+	// the conflict strategies added to the built-in ones, by name
+	conflictStrategies map[string]ConflictStrategy
+	// the status messages of the resources recreated by the apply in progress, by package, guarded by kmtx
+	recreated map[string]string
+
+	// authClient reviews the access of the synchronizer identity in the pre-flight check, which is skipped if nil
+	authClient    kubernetes.Interface
+	accessReviews accessReviewCache
+
+	// the resources applied by the last sync of each appsub, served by the admin API
+	renders map[types.NamespacedName]*Render
+	// the keys of the resources applied by the last sync of each appsub, for the sync audit
+	applied map[types.NamespacedName][]string
+	rmtx    sync.Mutex
 }
 
 var defaultSynchronizer *KubeSynchronizer
@@ -167,6 +196,13 @@ func CreateSynchronizer(config, remoteConfig *rest.Config, scheme *runtime.Schem
 		}
 	}
 
+	s.authClient, err = kubernetes.NewForConfig(config)
+	if err != nil {
+		klog.Error("Failed to create the authorization client of the pre-flight check. err: ", err)
+
+		return nil, err
+	}
+
 	defaultExtension.localClient = s.LocalClient
 	defaultExtension.remoteClient = s.RemoteClient
 
@@ -214,6 +250,12 @@ func (sync *KubeSynchronizer) Start(ctx context.Context) error {
 
 	klog.Info("remote config cache started")
 
+	go sync.migrateGeneratedObjects()
+
+	if sync.Interval > 0 {
+		go wait.Until(sync.collectOrphans, time.Duration(sync.Interval)*time.Second, ctx.Done())
+	}
+
 	return nil
 }
 
@@ -221,6 +263,34 @@ func (sync *KubeSynchronizer) GetInterval() int {
 	return sync.Interval
 }
 
+func (sync *KubeSynchronizer) GetClusterName() string {
+	return sync.SynchronizerID.Name
+}
+
+func (sync *KubeSynchronizer) SetSkipAppSubStatusResDel(skip bool) {
+	sync.SkipAppSubStatusResDel = skip
+}
+
+func (sync *KubeSynchronizer) AddApplyHook(hook ApplyHook) {
+	sync.applyHooks = append(sync.applyHooks, hook)
+}
+
+func (sync *KubeSynchronizer) AddManifestMutator(mutator ManifestMutator) {
+	sync.mutators = append(sync.mutators, mutator)
+}
+
+func (sync *KubeSynchronizer) SetSecretScanner(scanner *SecretScanner) {
+	sync.secretScanner = scanner
+}
+
+func (sync *KubeSynchronizer) AddConflictStrategy(name string, strategy ConflictStrategy) {
+	if sync.conflictStrategies == nil {
+		sync.conflictStrategies = map[string]ConflictStrategy{}
+	}
+
+	sync.conflictStrategies[strings.ToLower(name)] = strategy
+}
+
 func (sync *KubeSynchronizer) GetLocalClient() client.Client {
 	return sync.LocalClient
 }
@@ -0,0 +1,96 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// desiredStateHashLen is the length of the desired state hash, short enough for a label value
+const desiredStateHashLen = 40
+
+// desiredStateHash returns a hash of the resources of an appsub sync, whatever their order. It is the hex sha256 of
+// the sorted sha256 of each resource, truncated to fit in a label value.
+func desiredStateHash(resources []ResourceUnit) string {
+	digests := make([]string, 0, len(resources))
+
+	for _, resource := range resources {
+		if resource.Resource == nil {
+			continue
+		}
+
+		rsc := resource.Resource.DeepCopy()
+
+		// the hash of the last sync is not part of the desired state
+		labels := rsc.GetLabels()
+		if _, ok := labels[appv1alpha1.LabelDesiredStateHash]; ok {
+			delete(labels, appv1alpha1.LabelDesiredStateHash)
+
+			if len(labels) == 0 {
+				labels = nil
+			}
+
+			rsc.SetLabels(labels)
+		}
+
+		content, err := rsc.MarshalJSON()
+		if err != nil {
+			content = []byte(fmt.Sprintf("%v", rsc.Object))
+		}
+
+		digest := sha256.Sum256(content)
+		digests = append(digests, hex.EncodeToString(digest[:]))
+	}
+
+	sort.Strings(digests)
+
+	h := sha256.New()
+
+	for _, digest := range digests {
+		h.Write([]byte(digest + "\n"))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:desiredStateHashLen]
+}
+
+// labelDesiredStateHash returns copies of the resources labeled with their desired state hash, and the hash
+func labelDesiredStateHash(resources []ResourceUnit) ([]ResourceUnit, string) {
+	stateHash := desiredStateHash(resources)
+
+	labeled := make([]ResourceUnit, 0, len(resources))
+
+	for _, resource := range resources {
+		if resource.Resource != nil {
+			resource.Resource = resource.Resource.DeepCopy()
+
+			labels := resource.Resource.GetLabels()
+			if labels == nil {
+				labels = map[string]string{}
+			}
+
+			labels[appv1alpha1.LabelDesiredStateHash] = stateHash
+			resource.Resource.SetLabels(labels)
+		}
+
+		labeled = append(labeled, resource)
+	}
+
+	return labeled, stateHash
+}
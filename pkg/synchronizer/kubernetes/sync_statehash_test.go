@@ -0,0 +1,58 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestDesiredStateHash(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	newConfigMap := func(name, value string) ResourceUnit {
+		cm := &unstructured.Unstructured{}
+		cm.SetAPIVersion("v1")
+		cm.SetKind("ConfigMap")
+		cm.SetName(name)
+		g.Expect(unstructured.SetNestedField(cm.Object, value, "data", "k")).To(gomega.Succeed())
+
+		return ResourceUnit{Resource: cm, Gvk: cm.GroupVersionKind()}
+	}
+
+	resources := []ResourceUnit{newConfigMap("a", "1"), newConfigMap("b", "2")}
+
+	labeled, stateHash := labelDesiredStateHash(resources)
+	g.Expect(stateHash).To(gomega.HaveLen(desiredStateHashLen))
+	g.Expect(labeled).To(gomega.HaveLen(2))
+
+	for _, resource := range labeled {
+		g.Expect(resource.Resource.GetLabels()).To(gomega.HaveKeyWithValue(appv1alpha1.LabelDesiredStateHash, stateHash))
+	}
+
+	// the rendered resources are left as they are
+	g.Expect(resources[0].Resource.GetLabels()).To(gomega.BeEmpty())
+
+	// the hash doesn't depend on the order of the resources, or on the hash label of a previous sync
+	g.Expect(desiredStateHash([]ResourceUnit{resources[1], resources[0]})).To(gomega.Equal(stateHash))
+	g.Expect(desiredStateHash(labeled)).To(gomega.Equal(stateHash))
+
+	g.Expect(desiredStateHash([]ResourceUnit{newConfigMap("a", "1"), newConfigMap("b", "3")})).NotTo(gomega.Equal(stateHash))
+	g.Expect(desiredStateHash([]ResourceUnit{newConfigMap("a", "1")})).NotTo(gomega.Equal(stateHash))
+}
@@ -0,0 +1,488 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	appSubStatusV1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1alpha1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+// TargetKubeconfigSecretKey is the key of the kubeconfig in the target cluster secrets
+const TargetKubeconfigSecretKey = "kubeconfig"
+
+// targetCluster is the synchronizer of an external cluster, built from the kubeconfig secret at resourceVersion
+type targetCluster struct {
+	resourceVersion string
+	sync            *KubeSynchronizer
+}
+
+// getTargetKubeconfigSecrets returns the kubeconfig secret names of the external clusters the appsub deploys to
+func getTargetKubeconfigSecrets(appsub *appv1alpha1.Subscription) []string {
+	secrets := []string{}
+
+	for _, name := range strings.Split(appsub.GetAnnotations()[appv1alpha1.AnnotationTargetKubeconfigSecrets], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			secrets = append(secrets, name)
+		}
+	}
+
+	return secrets
+}
+
+// getTargetSynchronizer returns the synchronizer of the cluster in the kubeconfig secret, it is rebuilt when the secret changes.
+// The target synchronizer reads the hosting appsub from the local cluster.
+func (sync *KubeSynchronizer) getTargetSynchronizer(secretKey types.NamespacedName) (*KubeSynchronizer, error) {
+	secret := &corev1.Secret{}
+	if err := sync.LocalNonCachedClient.Get(context.TODO(), secretKey, secret); err != nil {
+		return nil, err
+	}
+
+	if tc, ok := sync.targetClusters[secretKey]; ok && tc.resourceVersion == secret.GetResourceVersion() {
+		return tc.sync, nil
+	}
+
+	kubeconfig, ok := secret.Data[TargetKubeconfigSecretKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %v has no %v key", secretKey, TargetKubeconfigSecretKey)
+	}
+
+	cfg, err := restConfigFromTargetKubeconfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig in secret %v: %w", secretKey, err)
+	}
+
+	// the target clusters are reached from the subscription operator network like the channel sources
+	if err := utils.CheckSourceURLAllowed(cfg.Host); err != nil {
+		return nil, fmt.Errorf("target cluster of secret %v: %w", secretKey, err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	restMapper, err := apiutil.NewDynamicRESTMapper(cfg, apiutil.WithLazyDiscovery)
+	if err != nil {
+		return nil, err
+	}
+
+	authClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	target := &KubeSynchronizer{
+		Interval:             sync.Interval,
+		LocalClient:          sync.LocalClient,
+		LocalNonCachedClient: sync.LocalNonCachedClient,
+		hub:                  sync.hub,
+		standalone:           sync.standalone,
+		DynamicClient:        dynamicClient,
+		RestMapper:           restMapper,
+		localConfig:          cfg,
+		authClient:           authClient,
+		SynchronizerID:       &types.NamespacedName{Name: secretKey.Name},
+		Extension:            sync.Extension,
+		applyHooks:           sync.applyHooks,
+		conflictStrategies:   sync.conflictStrategies,
+	}
+
+	if sync.targetClusters == nil {
+		sync.targetClusters = map[types.NamespacedName]*targetCluster{}
+	}
+
+	sync.targetClusters[secretKey] = &targetCluster{resourceVersion: secret.GetResourceVersion(), sync: target}
+
+	klog.Infof("built synchronizer of target cluster %v, host: %v", secretKey, cfg.Host)
+
+	return target, nil
+}
+
+// restConfigFromTargetKubeconfig builds the client config of a kubeconfig secret. The kubeconfig is written by the
+// appsub users, it can't run commands or read the files of the subscription operator, so only the inline
+// credentials and certificates are allowed.
+func restConfigFromTargetKubeconfig(kubeconfig []byte) (*rest.Config, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, cluster := range config.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return nil, fmt.Errorf("cluster %v: certificate-authority files are not allowed, use certificate-authority-data", name)
+		}
+	}
+
+	for name, user := range config.AuthInfos {
+		switch {
+		case user.Exec != nil:
+			return nil, fmt.Errorf("user %v: exec credential plugins are not allowed", name)
+		case user.AuthProvider != nil:
+			return nil, fmt.Errorf("user %v: auth providers are not allowed", name)
+		case user.TokenFile != "":
+			return nil, fmt.Errorf("user %v: token files are not allowed, use token", name)
+		case user.ClientCertificate != "" || user.ClientKey != "":
+			return nil, fmt.Errorf("user %v: client certificate and key files are not allowed, use client-certificate-data "+
+				"and client-key-data", name)
+		}
+	}
+
+	return clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{}).ClientConfig()
+}
+
+// applyToTargetClusters applies the appsub resources to the external clusters of the appsub kubeconfig secrets, and
+// deletes the resources that are no longer subscribed from them. The package conditions, the pre-flight check, the
+// operator gate and the health checks are evaluated per target cluster, like on the local cluster. The result is
+// reported in the appsub status per target, and an error is returned if some targets have to be synced again.
+// The caller holds the kmtx lock.
+func (sync *KubeSynchronizer) applyToTargetClusters(appsub *appv1alpha1.Subscription, resources []ResourceUnit,
+	allowlist, denyList map[string]map[string]string, isAdmin bool) error {
+	hostSub := types.NamespacedName{Namespace: appsub.GetNamespace(), Name: appsub.GetName()}
+	targets := getTargetKubeconfigSecrets(appsub)
+	prevDeployed := sync.getTargetPackages(appsub)
+
+	if len(targets) == 0 && len(prevDeployed) == 0 {
+		return nil
+	}
+
+	deployed := map[string][]appSubStatusV1alpha1.SubscriptionUnitStatus{}
+	statuses := appv1alpha1.SubscriptionClusterStatusMap{}
+	pending := []string{}
+
+	for _, target := range targets {
+		pkgStatuses := map[string]*appv1alpha1.SubscriptionUnitStatus{}
+		statuses[target] = &appv1alpha1.SubscriptionPerClusterStatus{SubscriptionPackageStatus: pkgStatuses}
+
+		targetSync, err := sync.getTargetSynchronizer(types.NamespacedName{Namespace: hostSub.Namespace, Name: target})
+		if err == nil {
+			var targetResources []ResourceUnit
+
+			targetResources, err = targetSync.filterTargetResources(appsub, resources, allowlist, denyList, isAdmin)
+			if err == nil {
+				var waiting, unhealthy int
+
+				deployed[target], waiting, unhealthy = targetSync.applyTargetResources(hostSub, targetResources, allowlist,
+					denyList, isAdmin, targetSync.getConflictStrategy(appsub), pkgStatuses)
+
+				targetSync.deleteTargetOrphans(hostSub, prevDeployed[target], deployed[target])
+
+				if waiting > 0 || unhealthy > 0 {
+					pending = append(pending, target)
+				}
+
+				continue
+			}
+		}
+
+		klog.Errorf("no resource of appsub %v is applied to target cluster %v, err: %v", hostSub, target, err)
+
+		pkgStatuses["kubeconfig-secret/"+target] = &appv1alpha1.SubscriptionUnitStatus{
+			Phase:          appv1alpha1.SubscriptionFailed,
+			Message:        err.Error(),
+			LastUpdateTime: metav1.Now(),
+		}
+
+		// keep what was deployed, it is cleaned up once the target can be synced again
+		deployed[target] = prevDeployed[target]
+
+		if !isPreflightError(err) {
+			pending = append(pending, target)
+		}
+	}
+
+	// clean up the targets removed from the appsub
+	for target, pkgs := range prevDeployed {
+		if _, ok := statuses[target]; ok {
+			continue
+		}
+
+		targetSync, err := sync.getTargetSynchronizer(types.NamespacedName{Namespace: hostSub.Namespace, Name: target})
+		if err != nil {
+			klog.Errorf("failed to connect to removed target cluster %v of appsub %v, skip cleaning it up. err: %v", target, hostSub, err)
+
+			continue
+		}
+
+		targetSync.deleteTargetOrphans(hostSub, pkgs, nil)
+	}
+
+	if sync.targetPackages == nil {
+		sync.targetPackages = map[types.NamespacedName]map[string][]appSubStatusV1alpha1.SubscriptionUnitStatus{}
+	}
+
+	sync.targetPackages[hostSub] = deployed
+
+	sync.updateTargetClusterStatus(hostSub, statuses)
+
+	if len(pending) > 0 {
+		return fmt.Errorf("target clusters %v of appsub %v are not synced yet", strings.Join(pending, ", "), hostSub)
+	}
+
+	return nil
+}
+
+// filterTargetResources returns the resources whose package conditions are met by the target cluster. A
+// PreflightError is returned if the target kubeconfig identity can't deploy them.
+func (sync *KubeSynchronizer) filterTargetResources(appsub *appv1alpha1.Subscription, resources []ResourceUnit,
+	allowlist, denyList map[string]map[string]string, isAdmin bool) ([]ResourceUnit, error) {
+	filtered, err := sync.filterByClusterConditions(appsub, resources)
+	if err != nil {
+		return nil, err
+	}
+
+	if sync.authClient == nil {
+		return filtered, nil
+	}
+
+	if err := sync.preflightCheck(appsub, filtered, allowlist, denyList, isAdmin); err != nil {
+		if isPreflightError(err) {
+			return nil, err
+		}
+
+		// the apply reports its own errors if the check itself can't be done
+		klog.Warningf("skipping the pre-flight check of appsub %v/%v on target cluster %v, err: %v", appsub.GetNamespace(),
+			appsub.GetName(), sync.SynchronizerID.Name, err)
+	}
+
+	return filtered, nil
+}
+
+func isPreflightError(err error) bool {
+	perr := &PreflightError{}
+
+	return goerrors.As(err, &perr)
+}
+
+// applyTargetResources applies the resources to the target cluster. It returns the applied packages, and the number
+// of the packages waiting for their operators or their previous sync waves and of the unhealthy ones.
+func (sync *KubeSynchronizer) applyTargetResources(hostSub types.NamespacedName, resources []ResourceUnit,
+	allowlist, denyList map[string]map[string]string, isAdmin bool, conflicts ConflictStrategy,
+	pkgStatuses map[string]*appv1alpha1.SubscriptionUnitStatus) ([]appSubStatusV1alpha1.SubscriptionUnitStatus, int, int) {
+	applied := []appSubStatusV1alpha1.SubscriptionUnitStatus{}
+	gate := newOperatorGate()
+	waves := newWaveGate()
+	waiting := 0
+	unhealthy := 0
+
+	for _, resource := range resources {
+		resource := resource
+		heldBy := waves.waitingFor(getSyncWave(hostSub, resource), unhealthy)
+
+		// the overrides of the appsub apply per target cluster, named after the kubeconfig secret
+		template, err := sync.OverrideResource(hostSub, &resource)
+		if err != nil {
+			klog.Errorf("failed to override resource for target cluster %v, err: %v", sync.SynchronizerID.Name, err)
+
+			continue
+		}
+
+		// the appsub only lives on the local cluster, an owner reference to it would get the resource garbage collected
+		template = utils.RemoveSubOwnerRef(template)
+
+		pkg := appSubStatusV1alpha1.SubscriptionUnitStatus{
+			APIVersion: template.GetAPIVersion(),
+			Kind:       template.GetKind(),
+			Name:       template.GetName(),
+		}
+
+		pkgStatus := &appv1alpha1.SubscriptionUnitStatus{
+			Phase:          appv1alpha1.SubscriptionSubscribed,
+			LastUpdateTime: metav1.Now(),
+		}
+
+		pkgGVR, isNamespaced, err := sync.getGVRfromGVK(resource.Gvk.Group, resource.Gvk.Version, resource.Gvk.Kind)
+
+		if isNamespaced {
+			pkg.Namespace = template.GetNamespace()
+		}
+
+		operator := gate.waitingFor(resource.Gvk.GroupKind(), err == nil)
+		if operator == "" {
+			operator = waitingForSecretCRD(resource.Gvk, err)
+		}
+
+		switch {
+		case heldBy != "":
+			// not applied yet, but kept as deployed so it is not deleted from the target meanwhile
+			pkg.Namespace = template.GetNamespace()
+			pkgStatus.Phase = appv1alpha1.SubscriptionPhase(appSubStatusV1alpha1.PackageWaitingForWave)
+			pkgStatus.Message = heldBy
+			waiting++
+			err = nil
+		case operator != "":
+			// not applied yet, but kept as deployed so it is not deleted from the target meanwhile
+			pkg.Namespace = template.GetNamespace()
+			pkgStatus.Phase = appv1alpha1.SubscriptionPhase(appSubStatusV1alpha1.PackageWaitingForOperator)
+			pkgStatus.Message = "waiting for " + operator + " to be installed"
+			waiting++
+		case err == nil:
+			resource.Resource = template
+			nri := sync.DynamicClient.Resource(pkgGVR)
+
+			err = sync.applyResource(hostSub, nri, isNamespaced, resource, isSpecialResource(pkgGVR), allowlist, denyList,
+				isAdmin, conflicts)
+			if err != nil {
+				break
+			}
+
+			if isOperatorResource(resource.Gvk) {
+				sync.observeOperator(gate, template)
+			}
+
+			pkgStatus.Message = sync.takeRecreated(template)
+
+			if healthy, msg := sync.checkResourceHealth(nri, isNamespaced, template); !healthy {
+				pkgStatus.Phase = appv1alpha1.SubscriptionPhase(appSubStatusV1alpha1.PackageUnhealthy)
+				pkgStatus.Message = msg
+				unhealthy++
+			}
+		}
+
+		if err != nil && operator == "" {
+			klog.Errorf("failed to apply %v to target cluster %v, err: %v", targetPackageKey(pkg), sync.SynchronizerID.Name, err)
+
+			pkgStatus.Phase = appv1alpha1.SubscriptionFailed
+			pkgStatus.Message = err.Error()
+		}
+
+		pkgStatuses[targetPackageKey(pkg)] = pkgStatus
+
+		applied = append(applied, pkg)
+	}
+
+	return applied, waiting, unhealthy
+}
+
+// deleteTargetOrphans deletes the previously deployed packages that are not deployed anymore from the target cluster
+func (sync *KubeSynchronizer) deleteTargetOrphans(hostSub types.NamespacedName,
+	prevDeployed, deployed []appSubStatusV1alpha1.SubscriptionUnitStatus) {
+	current := map[string]bool{}
+	for _, pkg := range deployed {
+		current[targetPackageKey(pkg)] = true
+	}
+
+	for _, pkg := range prevDeployed {
+		if current[targetPackageKey(pkg)] {
+			continue
+		}
+
+		klog.Infof("deleting %v from target cluster %v, appsub: %v", targetPackageKey(pkg), sync.SynchronizerID.Name, hostSub)
+
+		if err := sync.DeleteSingleSubscribedResource(hostSub, pkg); err != nil {
+			klog.Errorf("failed to delete %v from target cluster %v, err: %v", targetPackageKey(pkg), sync.SynchronizerID.Name, err)
+		}
+	}
+}
+
+// purgeTargetClusters deletes the appsub resources from all the target clusters. The caller holds the kmtx lock.
+func (sync *KubeSynchronizer) purgeTargetClusters(appsub *appv1alpha1.Subscription) {
+	hostSub := types.NamespacedName{Namespace: appsub.GetNamespace(), Name: appsub.GetName()}
+
+	for target, pkgs := range sync.getTargetPackages(appsub) {
+		targetSync, err := sync.getTargetSynchronizer(types.NamespacedName{Namespace: hostSub.Namespace, Name: target})
+		if err != nil {
+			klog.Errorf("failed to connect to target cluster %v of appsub %v, skip purging it. err: %v", target, hostSub, err)
+
+			continue
+		}
+
+		targetSync.deleteTargetOrphans(hostSub, pkgs, nil)
+	}
+
+	delete(sync.targetPackages, hostSub)
+}
+
+// getTargetPackages returns the packages deployed to each target cluster of the appsub. It falls back to the appsub
+// status, e.g. after a restart.
+func (sync *KubeSynchronizer) getTargetPackages(appsub *appv1alpha1.Subscription) map[string][]appSubStatusV1alpha1.SubscriptionUnitStatus {
+	hostSub := types.NamespacedName{Namespace: appsub.GetNamespace(), Name: appsub.GetName()}
+
+	if deployed, ok := sync.targetPackages[hostSub]; ok {
+		return deployed
+	}
+
+	deployed := map[string][]appSubStatusV1alpha1.SubscriptionUnitStatus{}
+
+	for target, clusterStatus := range appsub.Status.Statuses {
+		if clusterStatus == nil {
+			continue
+		}
+
+		for key := range clusterStatus.SubscriptionPackageStatus {
+			if pkg, ok := parseTargetPackageKey(key); ok {
+				deployed[target] = append(deployed[target], pkg)
+			}
+		}
+	}
+
+	return deployed
+}
+
+// updateTargetClusterStatus reports the target cluster statuses in the appsub status
+func (sync *KubeSynchronizer) updateTargetClusterStatus(hostSub types.NamespacedName, statuses appv1alpha1.SubscriptionClusterStatusMap) {
+	appsub := &appv1alpha1.Subscription{}
+	if err := sync.LocalNonCachedClient.Get(context.TODO(), hostSub, appsub); err != nil {
+		klog.Warningf("failed to get appsub %v to update the target cluster status, err: %v", hostSub, err)
+
+		return
+	}
+
+	if len(statuses) == 0 {
+		statuses = nil
+	}
+
+	appsub.Status.Statuses = statuses
+
+	if err := sync.LocalNonCachedClient.Status().Update(context.TODO(), appsub); err != nil {
+		klog.Warningf("failed to update the target cluster status of appsub %v, err: %v", hostSub, err)
+	}
+}
+
+// targetPackageKey returns the <apiVersion>/<kind>/<namespace>/<name> key of the package in the appsub status
+func targetPackageKey(pkg appSubStatusV1alpha1.SubscriptionUnitStatus) string {
+	return strings.Join([]string{pkg.APIVersion, pkg.Kind, pkg.Namespace, pkg.Name}, "/")
+}
+
+func parseTargetPackageKey(key string) (appSubStatusV1alpha1.SubscriptionUnitStatus, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) < 4 {
+		return appSubStatusV1alpha1.SubscriptionUnitStatus{}, false
+	}
+
+	n := len(parts)
+
+	return appSubStatusV1alpha1.SubscriptionUnitStatus{
+		APIVersion: strings.Join(parts[:n-3], "/"),
+		Kind:       parts[n-3],
+		Namespace:  parts[n-2],
+		Name:       parts[n-1],
+	}, true
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	appSubStatusV1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1alpha1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+const targetKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: east
+  cluster:
+    server: https://[fd00::10]:6443
+contexts:
+- name: east
+  context:
+    cluster: east
+    user: east
+current-context: east
+users:
+- name: east
+  user:
+    token: abc
+`
+
+func TestTargetPackageKey(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	pkgs := []appSubStatusV1alpha1.SubscriptionUnitStatus{
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web"},
+		{APIVersion: "v1", Kind: "Namespace", Name: "web"},
+	}
+
+	for _, pkg := range pkgs {
+		parsed, ok := parseTargetPackageKey(targetPackageKey(pkg))
+		g.Expect(ok).To(gomega.BeTrue())
+		g.Expect(parsed).To(gomega.Equal(pkg))
+	}
+
+	_, ok := parseTargetPackageKey("kubeconfig-secret/east")
+	g.Expect(ok).To(gomega.BeFalse())
+}
+
+func TestGetTargetPackages(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	appsub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web",
+			Namespace:   "default",
+			Annotations: map[string]string{appv1.AnnotationTargetKubeconfigSecrets: "east, west,"},
+		},
+		Status: appv1.SubscriptionStatus{
+			Statuses: appv1.SubscriptionClusterStatusMap{
+				"east": &appv1.SubscriptionPerClusterStatus{
+					SubscriptionPackageStatus: map[string]*appv1.SubscriptionUnitStatus{
+						"apps/v1/Deployment/default/web": {Phase: appv1.SubscriptionSubscribed},
+					},
+				},
+				"west": &appv1.SubscriptionPerClusterStatus{
+					SubscriptionPackageStatus: map[string]*appv1.SubscriptionUnitStatus{
+						"kubeconfig-secret/west": {Phase: appv1.SubscriptionFailed},
+					},
+				},
+			},
+		},
+	}
+
+	g.Expect(getTargetKubeconfigSecrets(appsub)).To(gomega.Equal([]string{"east", "west"}))
+
+	sync := &KubeSynchronizer{}
+
+	// nothing recorded in memory, e.g. after a restart, falls back to the appsub status
+	g.Expect(sync.getTargetPackages(appsub)).To(gomega.Equal(map[string][]appSubStatusV1alpha1.SubscriptionUnitStatus{
+		"east": {{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web"}},
+	}))
+
+	sync.targetPackages = map[types.NamespacedName]map[string][]appSubStatusV1alpha1.SubscriptionUnitStatus{
+		{Namespace: "default", Name: "web"}: {},
+	}
+
+	g.Expect(sync.getTargetPackages(appsub)).To(gomega.BeEmpty())
+}
+
+func TestGetTargetSynchronizer(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "east", Namespace: "default"},
+		Data:       map[string][]byte{TargetKubeconfigSecretKey: []byte(targetKubeconfig)},
+	}
+
+	clt := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	sync := &KubeSynchronizer{LocalClient: clt, LocalNonCachedClient: clt}
+
+	secretKey := types.NamespacedName{Namespace: "default", Name: "east"}
+
+	target, err := sync.getTargetSynchronizer(secretKey)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(target.SynchronizerID.Name).To(gomega.Equal("east"))
+	g.Expect(target.DynamicClient).NotTo(gomega.BeNil())
+	g.Expect(target.authClient).NotTo(gomega.BeNil())
+	g.Expect(target.localConfig.Host).To(gomega.Equal("https://[fd00::10]:6443"))
+
+	// unchanged secret reuses the target synchronizer
+	cached, err := sync.getTargetSynchronizer(secretKey)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cached).To(gomega.BeIdenticalTo(target))
+
+	// the target synchronizer is rebuilt when the secret changes
+	secret.Data[TargetKubeconfigSecretKey] = []byte("invalid")
+	g.Expect(clt.Update(context.TODO(), secret)).To(gomega.Succeed())
+
+	_, err = sync.getTargetSynchronizer(secretKey)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	_, err = sync.getTargetSynchronizer(types.NamespacedName{Namespace: "default", Name: "west"})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	// the target cluster servers are checked against the channel source allow-list
+	secret.Data[TargetKubeconfigSecretKey] = []byte(targetKubeconfig)
+	g.Expect(clt.Update(context.TODO(), secret)).To(gomega.Succeed())
+
+	g.Expect(utils.SetChannelSourceAllowList("fd00::20")).To(gomega.Succeed())
+
+	defer func() {
+		g.Expect(utils.SetChannelSourceAllowList("")).To(gomega.Succeed())
+	}()
+
+	_, err = sync.getTargetSynchronizer(secretKey)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("allow-list")))
+
+	g.Expect(utils.SetChannelSourceAllowList("fd00::10")).To(gomega.Succeed())
+
+	_, err = sync.getTargetSynchronizer(secretKey)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+}
+
+func TestRestConfigFromTargetKubeconfig(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	cfg, err := restConfigFromTargetKubeconfig([]byte(targetKubeconfig))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(cfg.BearerToken).To(gomega.Equal("abc"))
+
+	// the kubeconfig can't run commands or read the local files
+	for _, user := range []string{
+		"exec:\n      apiVersion: client.authentication.k8s.io/v1beta1\n      command: /bin/sh",
+		"auth-provider:\n      name: oidc",
+		"tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token",
+		"client-certificate: /etc/pki/tls.crt\n    client-key: /etc/pki/tls.key",
+	} {
+		_, err := restConfigFromTargetKubeconfig([]byte(strings.Replace(targetKubeconfig, "token: abc", user, 1)))
+		g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("not allowed")))
+	}
+
+	_, err = restConfigFromTargetKubeconfig([]byte(strings.Replace(targetKubeconfig, "server: https://[fd00::10]:6443",
+		"server: https://[fd00::10]:6443\n    certificate-authority: /etc/pki/ca.crt", 1)))
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("not allowed")))
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+)
+
+// sortResourcesByWave returns the resources in the order they are applied: by sync wave, the lower waves first, then
+// by the phase of their kinds, the CRDs and namespaces first, then the RBAC resources and the others last. The OLM
+// resources go first in their phase, the operator groups before the operators. The other resources of the same wave
+// and phase keep the order of the channel. The slice of the caller is left as is.
+func sortResourcesByWave(hostSub types.NamespacedName, resources []ResourceUnit) []ResourceUnit {
+	waves := make([]int, len(resources))
+	sorted := make([]int, len(resources))
+
+	for i, resource := range resources {
+		waves[i] = getSyncWave(hostSub, resource)
+		sorted[i] = i
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+
+		if waves[a] != waves[b] {
+			return waves[a] < waves[b]
+		}
+
+		phaseA, phaseB := utils.GetKindApplyPhase(resources[a].Gvk.Kind), utils.GetKindApplyPhase(resources[b].Gvk.Kind)
+		if phaseA != phaseB {
+			return phaseA < phaseB
+		}
+
+		return getOLMApplyOrder(resources[a].Gvk) < getOLMApplyOrder(resources[b].Gvk)
+	})
+
+	ordered := make([]ResourceUnit, 0, len(resources))
+
+	for _, i := range sorted {
+		ordered = append(ordered, resources[i])
+	}
+
+	return ordered
+}
+
+// getSyncWave returns the sync wave of the resource, 0 if it has none or an invalid one
+func getSyncWave(hostSub types.NamespacedName, resource ResourceUnit) int {
+	if resource.Resource == nil {
+		return 0
+	}
+
+	value, ok := resource.Resource.GetAnnotations()[appv1alpha1.AnnotationSyncWave]
+	if !ok {
+		return 0
+	}
+
+	wave, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		klog.Warningf("invalid %v annotation %q of %v %v/%v of appsub %v, applying it in wave 0", appv1alpha1.AnnotationSyncWave,
+			value, resource.Resource.GetKind(), resource.Resource.GetNamespace(), resource.Resource.GetName(), hostSub)
+
+		return 0
+	}
+
+	return wave
+}
+
+// waveGate holds the resources of the next sync waves once the resources of a wave don't pass their health checks
+type waveGate struct {
+	started bool
+	wave    int
+	heldBy  string
+}
+
+func newWaveGate() *waveGate {
+	return &waveGate{}
+}
+
+// waitingFor returns why the resource of the wave is held, empty if it can be applied. The resources are passed in
+// their apply order, with the number of the resources applied before them that don't pass their health check.
+func (gate *waveGate) waitingFor(wave, unhealthy int) string {
+	if gate.heldBy == "" && gate.started && wave != gate.wave && unhealthy > 0 {
+		gate.heldBy = fmt.Sprintf("waiting for the resources of sync wave %d to pass their health check", gate.wave)
+	}
+
+	gate.started = true
+	gate.wave = wave
+
+	return gate.heldBy
+}
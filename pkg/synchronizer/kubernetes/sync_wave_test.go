@@ -0,0 +1,95 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func waveResource(kind, name, wave string) ResourceUnit {
+	rsc := &unstructured.Unstructured{}
+	rsc.SetKind(kind)
+	rsc.SetName(name)
+
+	if wave != "" {
+		rsc.SetAnnotations(map[string]string{appv1alpha1.AnnotationSyncWave: wave})
+	}
+
+	return ResourceUnit{Resource: rsc, Gvk: schema.GroupVersionKind{Kind: kind}}
+}
+
+func TestSortResourcesByWave(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	resources := []ResourceUnit{
+		waveResource("Deployment", "web", ""),
+		waveResource("ConfigMap", "settings", "-1"),
+		waveResource("Role", "web", ""),
+		waveResource("Job", "migrate", "5"),
+		waveResource("Namespace", "web", ""),
+		waveResource("Deployment", "worker", "not a wave"),
+		waveResource("CustomResourceDefinition", "backups", "5"),
+		waveResource("Service", "web", ""),
+	}
+
+	sorted := sortResourcesByWave(types.NamespacedName{Namespace: "ns", Name: "appsub"}, resources)
+
+	names := []string{}
+	for _, resource := range sorted {
+		names = append(names, resource.Resource.GetKind()+"/"+resource.Resource.GetName())
+	}
+
+	g.Expect(names).To(gomega.Equal([]string{
+		"ConfigMap/settings",
+		"Namespace/web",
+		"Role/web",
+		"Deployment/web",
+		"Deployment/worker",
+		"Service/web",
+		"CustomResourceDefinition/backups",
+		"Job/migrate",
+	}))
+
+	// the resources of the caller are not reordered
+	g.Expect(resources[0].Resource.GetName()).To(gomega.Equal("web"))
+	g.Expect(resources[0].Resource.GetKind()).To(gomega.Equal("Deployment"))
+}
+
+func TestWaveGate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	gate := newWaveGate()
+
+	// the unhealthy resources of a wave don't hold the rest of the wave
+	g.Expect(gate.waitingFor(-1, 0)).To(gomega.BeEmpty())
+	g.Expect(gate.waitingFor(0, 0)).To(gomega.BeEmpty())
+	g.Expect(gate.waitingFor(0, 1)).To(gomega.BeEmpty())
+
+	// the next waves are held
+	g.Expect(gate.waitingFor(1, 1)).To(gomega.ContainSubstring("sync wave 0"))
+	g.Expect(gate.waitingFor(2, 1)).To(gomega.ContainSubstring("sync wave 0"))
+
+	// the first wave is not held by the resources before it
+	gate = newWaveGate()
+	g.Expect(gate.waitingFor(3, 0)).To(gomega.BeEmpty())
+	g.Expect(gate.waitingFor(4, 0)).To(gomega.BeEmpty())
+}
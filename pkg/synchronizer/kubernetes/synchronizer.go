@@ -28,6 +28,8 @@ import (
 
 	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
 	appSubStatusV1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1alpha1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/eventstream"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/metrics"
 	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
 )
 
@@ -117,6 +119,9 @@ func (sync *KubeSynchronizer) DeleteSingleSubscribedResource(hostSub types.Names
 		return err
 	}
 
+	eventstream.PublishPruned(hostSub, sync.GetClusterName(), pkgStatus.APIVersion, pkgStatus.Kind, pkgStatus.Namespace,
+		pkgStatus.Name)
+
 	return nil
 }
 
@@ -132,6 +137,13 @@ func (sync *KubeSynchronizer) PurgeAllSubscribedResources(appsub *appv1alpha1.Su
 
 	klog.Infof("Prepare to purge all resources deployed by the appsub: %v", hostSub.String())
 
+	metrics.DeleteSubscription(hostSub)
+	sync.forgetRender(hostSub)
+
+	if sync.standalone {
+		sync.purgeTargetClusters(appsub)
+	}
+
 	appSubStatus := &appSubStatusV1alpha1.SubscriptionStatus{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "SubscriptionStatus",
@@ -227,15 +239,99 @@ func (sync *KubeSynchronizer) ProcessSubResources(appsub *appv1alpha1.Subscripti
 	}
 	// meaning clean up all the resource from a source:host
 	if len(resources) == 0 {
+		// the resources are kept during a change freeze
+		if skip, err := sync.checkChangeFreeze(appsub, nil); skip {
+			return err
+		}
+
 		return sync.PurgeAllSubscribedResources(appsub)
 	}
 
+	eventstream.PublishSyncStarted(hostSub, sync.GetClusterName())
+
+	// keep the deployed resources as they are if the channel content has inline credentials
+	if err := sync.scanResources(appsub, resources); err != nil {
+		klog.Errorf("no resource of appsub %v is applied. err: %v", hostSub, err)
+
+		return err
+	}
+
+	resources, err := sync.substituteClusterVariables(appsub, resources)
+	if err != nil {
+		klog.Errorf("failed to get the cluster variables of appsub %v, no resource is applied. err: %v", hostSub, err)
+
+		return err
+	}
+
+	// keep the deployed resources as they are if the mutation fails, rather than deleting the ones missing
+	resources, err = sync.mutateResources(appsub, resources)
+	if err != nil {
+		klog.Errorf("failed to mutate the resources of appsub %v, no resource is applied. err: %v", hostSub, err)
+
+		return err
+	}
+
+	resources = nameGeneratedResources(appsub, resources)
+	resources, stateHash := labelDesiredStateHash(resources)
+	resources = stampGeneratorVersion(resources)
+
+	// the target clusters apply the resources in the same order
+	resources = sortResourcesByWave(hostSub, resources)
+
+	// keep the deployed resources as they are during a change freeze, the changes held are reported
+	if skip, err := sync.checkChangeFreeze(appsub, resources); skip {
+		if err != nil {
+			klog.Infof("change freeze of appsub %v, err: %v", hostSub, err)
+		}
+
+		return err
+	}
+
+	// keep the deployed resources as they are if the package conditions can't be evaluated
+	filtered, err := sync.filterByClusterConditions(appsub, resources)
+	if err != nil {
+		klog.Errorf("no resource of appsub %v is applied. err: %v", hostSub, err)
+
+		return err
+	}
+
 	// handle orphan resource
 	sync.kmtx.Lock()
 
 	appSubUnitStatuses := []SubscriptionUnitStatus{}
 
-	for _, resource := range resources {
+	// report the missing namespaces and permissions at once, rather than the apply failures of each resource
+	if err := sync.runPreflight(appsub, filtered, allowlist, denyList, isAdmin); err != nil {
+		klog.Infof("Pre-flight check of appsub %v failed, no resource is applied. err: %v", hostSub, err)
+
+		appSubUnitStatuses = preflightFailedStatuses(filtered)
+		filtered = nil
+	}
+
+	// report the progress of the large applies after each batch, and once the apply is done
+	batchSize := utils.GetApplyBatchSize(appsub)
+	total := len(filtered)
+
+	// the custom resources of the operators being installed, and the secret operator resources whose CRD is not
+	// installed yet, are applied by the next syncs
+	gate := newOperatorGate()
+	waiting := 0
+	unhealthy := 0
+	rendered := []*unstructured.Unstructured{}
+	conflicts := sync.getConflictStrategy(appsub)
+
+	// the next sync waves are held while the resources of a wave don't pass their health checks
+	waves := newWaveGate()
+	held := 0
+
+	for i, resource := range filtered {
+		if i > 0 && i%batchSize == 0 {
+			klog.Infof("appsub %v applied %d of %d resources", hostSub, i, total)
+			utils.UpdateApplyProgressStatus(sync.LocalClient, appsub, i, total)
+		}
+
+		heldBy := waves.waitingFor(getSyncWave(hostSub, resource), unhealthy)
+
 		appSubUnitStatus := SubscriptionUnitStatus{}
 
 		resource := resource
@@ -253,17 +349,47 @@ func (sync *KubeSynchronizer) ProcessSubResources(appsub *appv1alpha1.Subscripti
 		}
 
 		resource.Resource = template
+		rendered = append(rendered, template)
 
 		appSubUnitStatus.APIVersion = resource.Resource.GetAPIVersion()
 		appSubUnitStatus.Kind = resource.Resource.GetKind()
 		appSubUnitStatus.Name = resource.Resource.GetName()
 
+		if heldBy != "" {
+			// not applied yet, but reported so it is not deleted as an orphan meanwhile
+			appSubUnitStatus.Namespace = resource.Resource.GetNamespace()
+			appSubUnitStatus.Phase = string(appSubStatusV1alpha1.PackageWaitingForWave)
+			appSubUnitStatus.Message = heldBy
+			appSubUnitStatuses = append(appSubUnitStatuses, appSubUnitStatus)
+			held++
+
+			continue
+		}
+
 		pkgGVR, isNamespaced, err := sync.getGVRfromGVK(resource.Gvk.Group, resource.Gvk.Version, resource.Gvk.Kind)
 
 		if isNamespaced {
 			appSubUnitStatus.Namespace = resource.Resource.GetNamespace()
 		}
 
+		operator := gate.waitingFor(resource.Gvk.GroupKind(), err == nil)
+		if operator == "" {
+			operator = waitingForSecretCRD(resource.Gvk, err)
+		}
+
+		if operator != "" {
+			appSubUnitStatus.Namespace = resource.Resource.GetNamespace()
+			appSubUnitStatus.Phase = string(appSubStatusV1alpha1.PackageWaitingForOperator)
+			appSubUnitStatus.Message = "waiting for " + operator + " to be installed"
+			appSubUnitStatuses = append(appSubUnitStatuses, appSubUnitStatus)
+			waiting++
+
+			klog.Infof("%v %v/%v of appsub %v is %v", appSubUnitStatus.Kind, appSubUnitStatus.Namespace,
+				appSubUnitStatus.Name, hostSub, appSubUnitStatus.Message)
+
+			continue
+		}
+
 		if err != nil {
 			appSubUnitStatus.Namespace = resource.Resource.GetNamespace()
 			appSubUnitStatus.Phase = string(appSubStatusV1alpha1.PackageDeployFailed)
@@ -277,7 +403,18 @@ func (sync *KubeSynchronizer) ProcessSubResources(appsub *appv1alpha1.Subscripti
 
 		nri := sync.DynamicClient.Resource(pkgGVR)
 
-		err = sync.applyTemplate(nri, isNamespaced, resource, isSpecialResource(pkgGVR), allowlist, denyList, isAdmin)
+		err = sync.applyResource(hostSub, nri, isNamespaced, resource, isSpecialResource(pkgGVR), allowlist, denyList, isAdmin,
+			conflicts)
+
+		if isConflictSkipped(err) {
+			appSubUnitStatus.Phase = string(appSubStatusV1alpha1.PackageConflictSkipped)
+			appSubUnitStatus.Message = err.Error()
+			appSubUnitStatuses = append(appSubUnitStatuses, appSubUnitStatus)
+
+			klog.Infof("Skipped kind template, pkg: %v/%v, reason: %v", appSubUnitStatus.Namespace, appSubUnitStatus.Name, err)
+
+			continue
+		}
 
 		if err != nil {
 			appSubUnitStatus.Phase = string(appSubStatusV1alpha1.PackageDeployFailed)
@@ -290,11 +427,48 @@ func (sync *KubeSynchronizer) ProcessSubResources(appsub *appv1alpha1.Subscripti
 			continue
 		}
 
+		if isOperatorResource(resource.Gvk) {
+			sync.observeOperator(gate, resource.Resource)
+		}
+
 		appSubUnitStatus.Phase = string(appSubStatusV1alpha1.PackageDeployed)
-		appSubUnitStatus.Message = ""
+		appSubUnitStatus.Message = sync.takeRecreated(resource.Resource)
+
+		if healthy, msg := sync.checkResourceHealth(nri, isNamespaced, resource.Resource); !healthy {
+			appSubUnitStatus.Phase = string(appSubStatusV1alpha1.PackageUnhealthy)
+			appSubUnitStatus.Message = msg
+			unhealthy++
+		}
+
 		appSubUnitStatuses = append(appSubUnitStatuses, appSubUnitStatus)
 	}
 
+	if total > 0 {
+		utils.UpdateApplyProgressStatus(sync.LocalClient, appsub, total, total)
+	}
+
+	sync.recordRender(hostSub, rendered, appSubUnitStatuses)
+	sync.recordApplied(hostSub, appSubUnitStatuses)
+
+	deployFailed := waiting > 0 || held > 0
+
+	for _, unitStatus := range appSubUnitStatuses {
+		if unitStatus.Phase == string(appSubStatusV1alpha1.PackageDeployFailed) {
+			deployFailed = true
+		}
+	}
+
+	if !deployFailed {
+		metrics.RecordSync(hostSub)
+		utils.UpdateDesiredStateHashStatus(sync.LocalClient, appsub, stateHash)
+	}
+
+	// the package conditions of the target clusters are evaluated against them, not against the local cluster
+	var targetErr error
+	if sync.standalone {
+		targetErr = sync.applyToTargetClusters(appsub, resources, allowlist, denyList, isAdmin)
+	}
+
 	appsubClusterStatus := SubscriptionClusterStatus{
 		Cluster:                   sync.SynchronizerID.Name,
 		AppSub:                    hostSub,
@@ -302,13 +476,125 @@ func (sync *KubeSynchronizer) ProcessSubResources(appsub *appv1alpha1.Subscripti
 		SubscriptionPackageStatus: appSubUnitStatuses,
 	}
 
-	err := sync.SyncAppsubClusterStatus(appsub, appsubClusterStatus, nil, nil)
+	err = sync.SyncAppsubClusterStatus(appsub, appsubClusterStatus, nil, nil)
 	if err != nil {
 		klog.Warning("error while sync app sub cluster status: ", err)
 	}
 
 	sync.kmtx.Unlock()
 
+	// the subscribers retry the failed syncs sooner than the next reconcile
+	if waiting > 0 {
+		return fmt.Errorf("%d resources of appsub %v are waiting for their operators to be installed", waiting, hostSub)
+	}
+
+	// the retries apply the waves held once the health checks of the previous waves pass
+	if held > 0 {
+		return fmt.Errorf("%d resources of appsub %v are waiting for the previous sync waves, %d resources don't pass "+
+			"their health check yet", held, hostSub, unhealthy)
+	}
+
+	// the health checks still in progress are evaluated again by the retries, until they pass
+	if unhealthy > 0 {
+		return fmt.Errorf("%d resources of appsub %v don't pass their health check yet", unhealthy, hostSub)
+	}
+
+	return targetErr
+}
+
+// ResyncSubResources force re-applies the package requested by the resync-package annotation of the appsub. The
+// other resources are left untouched, so resources is expected to be the full set of the appsub.
+func (sync *KubeSynchronizer) ResyncSubResources(appsub *appv1alpha1.Subscription, resources []ResourceUnit,
+	allowlist, denyList map[string]map[string]string, isAdmin bool) error {
+	hostSub := types.NamespacedName{
+		Namespace: appsub.GetNamespace(),
+		Name:      appsub.GetName(),
+	}
+
+	request := appsub.GetAnnotations()[appv1alpha1.AnnotationResyncPackage]
+
+	pkgName := utils.GetResyncPackageName(request)
+	if pkgName == "" {
+		return nil
+	}
+
+	if err := sync.scanResources(appsub, resources); err != nil {
+		return err
+	}
+
+	resources, err := sync.substituteClusterVariables(appsub, resources)
+	if err != nil {
+		return err
+	}
+
+	resources, err = sync.mutateResources(appsub, resources)
+	if err != nil {
+		return err
+	}
+
+	resources = nameGeneratedResources(appsub, resources)
+
+	// the resynced package keeps the desired state hash of the other resources
+	resources, _ = labelDesiredStateHash(resources)
+	resources = stampGeneratorVersion(resources)
+
+	conflicts := sync.getConflictStrategy(appsub)
+
+	filtered, err := sync.filterByClusterConditions(appsub, resources)
+	if err != nil {
+		return err
+	}
+
+	sync.kmtx.Lock()
+	defer sync.kmtx.Unlock()
+
+	found := false
+
+	for _, resource := range filtered {
+		if !utils.IsPackageResource(resource.Resource, pkgName) {
+			continue
+		}
+
+		found = true
+		resource := resource
+
+		template, err := sync.OverrideResource(hostSub, &resource)
+		if err != nil {
+			return err
+		}
+
+		// stamp the request, so the resource is updated and its controller reconciles it even if nothing else changed
+		tplanno := template.GetAnnotations()
+		if tplanno == nil {
+			tplanno = make(map[string]string)
+		}
+
+		tplanno[appv1alpha1.AnnotationResyncPackage] = request
+		template.SetAnnotations(tplanno)
+
+		resource.Resource = template
+
+		pkgGVR, isNamespaced, err := sync.getGVRfromGVK(resource.Gvk.Group, resource.Gvk.Version, resource.Gvk.Kind)
+		if err != nil {
+			return err
+		}
+
+		err = sync.applyResource(hostSub, sync.DynamicClient.Resource(pkgGVR), isNamespaced, resource,
+			isSpecialResource(pkgGVR), allowlist, denyList, isAdmin, conflicts)
+		if err != nil {
+			return err
+		}
+
+		// the resync reports a recreated package in the events only
+		sync.takeRecreated(template)
+
+		klog.Infof("Resynced package %v of appsub %v, kind: %v, name: %v", pkgName, hostSub, template.GetKind(), template.GetName())
+	}
+
+	if !found {
+		return fmt.Errorf("package %v to resync is not found in appsub %v", pkgName, hostSub)
+	}
+
 	return nil
 }
 
@@ -389,8 +675,10 @@ func (sync *KubeSynchronizer) createNewResourceByTemplateUnit(ri dynamic.Resourc
 //ri gets namespace info from applyTemplate func
 //
 //updateResourceByTemplateUnit will then update,patch the obj given tplunit.
-func (sync *KubeSynchronizer) updateResourceByTemplateUnit(ri dynamic.ResourceInterface,
-	origUnit *unstructured.Unstructured, tplunit *unstructured.Unstructured, specialResource bool) error {
+//The ownership conflicts and the immutable field errors are resolved by the conflict strategy of the appsub.
+func (sync *KubeSynchronizer) updateResourceByTemplateUnit(hostSub types.NamespacedName, ri dynamic.ResourceInterface,
+	origUnit *unstructured.Unstructured, tplunit *unstructured.Unstructured, specialResource bool, isAdmin bool,
+	conflicts ConflictStrategy) error {
 	var err error
 
 	overwrite := false
@@ -418,10 +706,28 @@ func (sync *KubeSynchronizer) updateResourceByTemplateUnit(ri dynamic.ResourceIn
 
 			overwrite = true
 		} else {
-			errmsg := "Obj " + tplunit.GetNamespace() + "/" + tplunit.GetName() + " exists and owned by others, backoff"
-			klog.Info(errmsg)
+			resolution := ResolutionFail
+			conflict := Conflict{Kind: ConflictOwnership, HostSub: hostSub, Existing: origUnit, Desired: tplunit}
+
+			if conflicts != nil {
+				resolution = resolveConflict(conflicts, conflict, isAdmin)
+			}
 
-			return errors.NewBadRequest("Obj " + tplunit.GetNamespace() + "/" + tplunit.GetName() + " exists and owned by others, backoff")
+			switch resolution {
+			case ResolutionOverwrite:
+				// replace, the appsub takes the resource over
+				overwrite = true
+				merge = false
+			case ResolutionMerge:
+				overwrite = true
+			case ResolutionSkip:
+				return &conflictSkippedError{conflict: conflict}
+			default:
+				errmsg := "Obj " + tplunit.GetNamespace() + "/" + tplunit.GetName() + " exists and owned by others, backoff"
+				klog.Info(errmsg)
+
+				return errors.NewBadRequest("Obj " + tplunit.GetNamespace() + "/" + tplunit.GetName() + " exists and owned by others, backoff")
+			}
 		}
 	}
 
@@ -484,14 +790,29 @@ func (sync *KubeSynchronizer) updateResourceByTemplateUnit(ri dynamic.ResourceIn
 		}
 
 		klog.Infof("Patch object. obj: %s, %s, patch: %s", origUnit.GetName(), origUnit.GroupVersionKind().String(), string(pb))
+
+		// the deployed resource no longer matches the subscribed template
+		if tplown != nil && string(pb) != "{}" {
+			metrics.RecordDrift(*tplown)
+		}
 		klog.V(1).Info("Generating Patch for service update.\nObjb:", string(objb), "\ntplb:", string(tplb), "\nPatch:", string(pb))
 
 		_, err = ri.Patch(context.TODO(), origUnit.GetName(), types.MergePatchType, pb, metav1.PatchOptions{})
+
+		if isImmutableFieldError(err) && (conflicts != nil || recreateOnImmutable(tplunit)) {
+			return sync.resolveImmutableConflict(ri, conflicts,
+				Conflict{Kind: ConflictImmutable, HostSub: hostSub, Existing: origUnit, Desired: newobj, Err: err}, isAdmin)
+		}
 	} else {
 		klog.Info("Apply object. newobj: " + newobj.GroupVersionKind().String())
 		klog.V(1).Infof("Apply object. newobj: %#v", newobj)
 		_, err = ri.Update(context.TODO(), newobj, metav1.UpdateOptions{})
 
+		if isImmutableFieldError(err) && (conflicts != nil || recreateOnImmutable(tplunit)) {
+			return sync.resolveImmutableConflict(ri, conflicts,
+				Conflict{Kind: ConflictImmutable, HostSub: hostSub, Existing: origUnit, Desired: newobj, Err: err}, isAdmin)
+		}
+
 		// Some kubernetes resources are immutable after creation. Log and ignore update errors.
 		if errors.IsForbidden(err) {
 			klog.Info(err.Error())
@@ -538,8 +859,36 @@ func isSpecialResource(gvr schema.GroupVersionResource) bool {
 	return gvr == serviceGVR || gvr == serviceAccountGVR || gvr == namespaceGVR
 }
 
-func (sync *KubeSynchronizer) applyTemplate(nri dynamic.NamespaceableResourceInterface, namespaced bool,
-	resource ResourceUnit, specialResource bool, allowlist, denyList map[string]map[string]string, isAdmin bool) error {
+// applyResource applies the resource of the appsub, running the apply hooks around it
+func (sync *KubeSynchronizer) applyResource(hostSub types.NamespacedName, nri dynamic.NamespaceableResourceInterface,
+	namespaced bool, resource ResourceUnit, specialResource bool, allowlist, denyList map[string]map[string]string,
+	isAdmin bool, conflicts ConflictStrategy) error {
+	cluster := sync.GetClusterName()
+
+	for _, hook := range sync.applyHooks {
+		if err := hook.PreApply(hostSub, cluster, resource.Resource); err != nil {
+			klog.Infof("pre-apply hook rejected %v %v/%v of appsub %v, err: %v", resource.Resource.GetKind(),
+				resource.Resource.GetNamespace(), resource.Resource.GetName(), hostSub, err)
+			eventstream.PublishApplied(hostSub, cluster, resource.Resource, err)
+
+			return err
+		}
+	}
+
+	err := sync.applyTemplate(hostSub, nri, namespaced, resource, specialResource, allowlist, denyList, isAdmin, conflicts)
+
+	for _, hook := range sync.applyHooks {
+		hook.PostApply(hostSub, cluster, resource.Resource, err)
+	}
+
+	eventstream.PublishApplied(hostSub, cluster, resource.Resource, err)
+
+	return err
+}
+
+func (sync *KubeSynchronizer) applyTemplate(hostSub types.NamespacedName, nri dynamic.NamespaceableResourceInterface,
+	namespaced bool, resource ResourceUnit, specialResource bool, allowlist, denyList map[string]map[string]string,
+	isAdmin bool, conflicts ConflictStrategy) error {
 	tplunit := resource.Resource
 	klog.Infof("Applying template: %v/%v, kind: %v", tplunit.GetNamespace(), tplunit.GetName(), tplunit.GetKind())
 
@@ -556,27 +905,22 @@ func (sync *KubeSynchronizer) applyTemplate(nri dynamic.NamespaceableResourceInt
 		return nil
 	}
 
-	if utils.IsResourceDenied(*tplunit, denyList, isAdmin) {
-		denyError := fmt.Errorf("the resource apiVersion: %s kind: %s is on the deny list. Not deployed",
-			tplunit.GetAPIVersion(), tplunit.GetKind())
-
-		klog.Info(denyError.Error())
+	if err := utils.CheckResourceAllowDeny(*tplunit, allowlist, denyList, isAdmin); err != nil {
+		klog.Info(err.Error())
 
-		return denyError
+		return err
 	}
 
-	if !utils.IsResourceAllowed(*tplunit, allowlist, isAdmin) {
-		denyError := fmt.Errorf("the resource apiVersion: %s kind: %s is not on the allow list. Not deployed",
-			tplunit.GetAPIVersion(), tplunit.GetKind())
+	if kubectlLastApplied {
+		lastApplied, err := withLastAppliedConfiguration(tplunit)
+		if err != nil {
+			klog.Errorf("Failed to set the last applied configuration of %v/%v, kind: %v, err: %v", tplunit.GetNamespace(),
+				tplunit.GetName(), tplunit.GetKind(), err)
 
-		if !isAdmin {
-			denyError = fmt.Errorf("not deployed by a subscription admin. the resource apiVersion: %s kind: %s is not deployed",
-				tplunit.GetAPIVersion(), tplunit.GetKind())
+			return err
 		}
 
-		klog.Info(denyError.Error())
-
-		return denyError
+		tplunit = lastApplied
 	}
 
 	origUnit, err := ri.Get(context.TODO(), tplunit.GetName(), metav1.GetOptions{})
@@ -587,8 +931,12 @@ func (sync *KubeSynchronizer) applyTemplate(nri dynamic.NamespaceableResourceInt
 		} else {
 			klog.Error("Failed to apply resource with error:", err)
 		}
+	} else if utils.IsGeneratedByNewerOperator(origUnit) {
+		// a newer operator owns the conventions of the resource, during a rolling upgrade or a rollback
+		klog.Infof("Skipping %v/%v, kind: %v, it is generated by a newer operator, generator version %v",
+			tplunit.GetNamespace(), tplunit.GetName(), tplunit.GetKind(), utils.GetGeneratorVersion(origUnit))
 	} else {
-		err = sync.updateResourceByTemplateUnit(ri, origUnit, tplunit, specialResource)
+		err = sync.updateResourceByTemplateUnit(hostSub, ri, origUnit, tplunit, specialResource, isAdmin, conflicts)
 	}
 
 	klog.Infof("Applied Kind Template: %v/%v, err: %v ", tplunit.GetNamespace(), tplunit.GetName(), err)
@@ -596,6 +944,39 @@ func (sync *KubeSynchronizer) applyTemplate(nri dynamic.NamespaceableResourceInt
 	return err
 }
 
+// checkResourceHealth evaluates the health-check annotation of the package against the deployed resource.
+// Resources without a health check are always healthy.
+func (sync *KubeSynchronizer) checkResourceHealth(nri dynamic.NamespaceableResourceInterface, namespaced bool,
+	tplunit *unstructured.Unstructured) (bool, string) {
+	hc, err := utils.GetHealthCheck(tplunit)
+	if err != nil {
+		return false, err.Error()
+	}
+
+	if hc == nil {
+		return true, ""
+	}
+
+	var ri dynamic.ResourceInterface
+	if namespaced {
+		ri = nri.Namespace(tplunit.GetNamespace())
+	} else {
+		ri = nri
+	}
+
+	live, err := ri.Get(context.TODO(), tplunit.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Sprintf("failed to get the resource for health check, err: %v", err)
+	}
+
+	healthy, msg, err := hc.IsHealthy(live)
+	if err != nil {
+		return false, err.Error()
+	}
+
+	return healthy, msg
+}
+
 // OverrideResource updates resource based on the hosting appsub before the resource is deployed.
 func (sync *KubeSynchronizer) OverrideResource(hostSub types.NamespacedName, resource *ResourceUnit) (*unstructured.Unstructured, error) {
 	// Parse the resource in template
@@ -619,6 +1000,13 @@ func (sync *KubeSynchronizer) OverrideResource(hostSub types.NamespacedName, res
 		return nil, errors.NewBadRequest("Failed to update template with empty kind. gvk:" + template.GetObjectKind().GroupVersionKind().String())
 	}
 
+	// the resources with a generateName would be created again on every sync
+	if template.GetName() == "" && template.GetGenerateName() != "" {
+		return nil, errors.NewBadRequest(fmt.Sprintf("%v with generateName %v has no name, name it or set the %v annotation "+
+			"of the subscription to %v", template.GetKind(), template.GetGenerateName(), appv1alpha1.AnnotationGenerateName,
+			appv1alpha1.GenerateNameHash))
+	}
+
 	// set name to resource name if not given
 	if template.GetName() == "" {
 		template.SetName(hostSub.Name)
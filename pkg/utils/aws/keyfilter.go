@@ -0,0 +1,122 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// ObjectKeyFilter selects the objects of a channel in its bucket: the keys under the prefix of the channel, followed
+// by the bucket path of the subscription, that match one of the key globs of the channel, if it has any.
+type ObjectKeyFilter struct {
+	// ChannelPrefix is the folder of the channel in the bucket, ending with /
+	ChannelPrefix string
+	// Prefix is the prefix of the listed keys, the channel prefix followed by the bucket path of the subscription
+	Prefix string
+	// Globs match the keys relative to the channel prefix, or their base name for the globs without /
+	Globs []string
+}
+
+// GetObjectKeyFilter returns the key filter of the objects of the channel subscribed by the subscription, from the
+// object-store-prefix and object-store-key-filter annotations of the channel and the bucket-path annotation of the
+// subscription.
+func GetObjectKeyFilter(chn *chnv1.Channel, sub *appv1.Subscription) (ObjectKeyFilter, error) {
+	filter := ObjectKeyFilter{}
+
+	if chn != nil {
+		annotations := chn.GetAnnotations()
+
+		if prefix := strings.Trim(strings.TrimSpace(annotations[appv1.AnnotationObjectStorePrefix]), "/"); prefix != "" {
+			filter.ChannelPrefix = prefix + "/"
+		}
+
+		for _, glob := range strings.Split(annotations[appv1.AnnotationObjectStoreKeyFilter], ",") {
+			glob = strings.TrimSpace(glob)
+			if glob == "" {
+				continue
+			}
+
+			if _, err := path.Match(glob, ""); err != nil {
+				return filter, fmt.Errorf("invalid object key filter %q of channel %v/%v: %w", glob, chn.GetNamespace(),
+					chn.GetName(), err)
+			}
+
+			filter.Globs = append(filter.Globs, glob)
+		}
+	}
+
+	filter.Prefix = filter.ChannelPrefix
+
+	if sub != nil {
+		filter.Prefix += sub.GetAnnotations()[appv1.AnnotationBucketPath]
+	}
+
+	return filter, nil
+}
+
+// ListPrefix returns the prefix to list the keys with, nil to list the whole bucket
+func (f ObjectKeyFilter) ListPrefix() *string {
+	if f.Prefix == "" {
+		return nil
+	}
+
+	prefix := f.Prefix
+
+	return &prefix
+}
+
+// Matches returns true if the key is under the prefix and matches one of the globs, if there are any
+func (f ObjectKeyFilter) Matches(key string) bool {
+	if !strings.HasPrefix(key, f.Prefix) {
+		return false
+	}
+
+	if len(f.Globs) == 0 {
+		return true
+	}
+
+	relative := strings.TrimPrefix(key, f.ChannelPrefix)
+
+	for _, glob := range f.Globs {
+		name := relative
+		if !strings.Contains(glob, "/") {
+			name = path.Base(relative)
+		}
+
+		if matched, _ := path.Match(glob, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FilterKeys returns the keys matching the filter, in their order
+func (f ObjectKeyFilter) FilterKeys(keys []string) []string {
+	filtered := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		if f.Matches(key) {
+			filtered = append(filtered, key)
+		}
+	}
+
+	return filtered
+}
@@ -17,18 +17,26 @@ package aws
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io/ioutil"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"k8s.io/klog/v2"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
 )
 
-// ObjectStore interface.
+// ObjectStore is the bucket operations of an object store, the S3 handler, the Google Cloud Storage one or the Azure
+// Blob Storage one. The handlers are connected to their object store by their own init.
 type ObjectStore interface {
-	InitObjectStoreConnection(endpoint, accessKeyID, secretAccessKey, region string) error
 	Exists(bucket string) error
 	Create(bucket string) error
 	List(bucket string, folderName *string) ([]string, error)
@@ -54,8 +62,43 @@ const (
 	DeployableVersionMeta = "x-amz-meta-deployableversion"
 	// Deployable generate name key within the meta map.
 	DeployableMetaVersionKey = "Deployableversion"
+
+	// defaultObjectStoreRegion is the region of the object stores of the pathname endpoints other than AWS S3
+	defaultObjectStoreRegion = "minio"
+	// defaultCustomEndpointRegion is the region of the explicit object store endpoints without region
+	defaultCustomEndpointRegion = "us-east-1"
 )
 
+// ambientCredentials are the namespaces of the channels allowed to use the ambient credentials, set by the operator.
+// The ambient credentials are disabled if it is nil, and allowed to all the namespaces if it is empty.
+var ambientCredentials map[string]bool
+
+// SetAmbientCredentials enables the ambient credentials for the channels of the comma separated namespaces, or of
+// all the namespaces if there are none. The ambient credentials are the cloud identity of the operator, a channel can
+// only select them when the operator allows its namespace.
+func SetAmbientCredentials(enabled bool, namespaces string) {
+	ambientCredentials = nil
+
+	if !enabled {
+		return
+	}
+
+	ambientCredentials = map[string]bool{}
+
+	for _, ns := range strings.Split(namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			ambientCredentials[ns] = true
+		}
+	}
+
+	klog.Infof("Object store ambient credentials enabled, namespaces: %v", namespaces)
+}
+
+// ambientCredentialsAllowed returns true if the channels of the namespace can use the ambient credentials
+func ambientCredentialsAllowed(namespace string) bool {
+	return ambientCredentials != nil && (len(ambientCredentials) == 0 || ambientCredentials[namespace])
+}
+
 // Handler handles connections to aws.
 type Handler struct {
 	*s3.Client
@@ -104,47 +147,161 @@ func isAwsS3ObjectBucket(endpoint string) bool {
 	return false
 }
 
-// InitObjectStoreConnection connect to object store.
-func (h *Handler) InitObjectStoreConnection(endpoint, accessKeyID, secretAccessKey, region string) error {
-	klog.Infof("Preparing S3 settings endpoint: %v", endpoint)
+// ObjectStoreSettings are the connection settings of an object store.
+type ObjectStoreSettings struct {
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	Region          string
+	// CustomEndpoint sends the requests to the endpoint rather than to the AWS S3 endpoint of the region
+	CustomEndpoint bool
+	// PathStyle addresses the buckets as <endpoint>/<bucket> rather than as <bucket>.<endpoint>
+	PathStyle bool
+	// AmbientCredentials signs the requests with the credentials of the default AWS chain rather than the access keys:
+	// the environment, the web identity token of IRSA, the container or the instance profile credentials. The
+	// requests are anonymous without ambient credentials nor access keys.
+	AmbientCredentials bool
+}
 
-	// set the default object store region  as minio
-	objectRegion := "minio"
+// NewObjectStoreSettings returns the settings of the object store of the endpoint. The AWS S3 endpoints are resolved
+// from the region, the other endpoints are S3 compatible object stores like MinIO, addressed path-style.
+func NewObjectStoreSettings(endpoint, accessKeyID, secretAccessKey, region string) ObjectStoreSettings {
+	settings := ObjectStoreSettings{
+		Endpoint:        endpoint,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		Region:          region,
+	}
 
-	if isAwsS3ObjectBucket(endpoint) {
-		objectRegion = region
+	if !isAwsS3ObjectBucket(endpoint) {
+		settings.Region = defaultObjectStoreRegion
+		settings.CustomEndpoint = true
+		settings.PathStyle = true
 	}
 
+	return settings
+}
+
+// GetChannelObjectStoreSettings returns the settings of the object store of the channel, the endpoint of its
+// pathname and the credentials and region of its secret, overridden by the object store annotations of the channel.
+// An explicit endpoint is addressed path-style unless the channel says otherwise. The channel can only select the
+// ambient credentials if the operator allows them in its namespace.
+func GetChannelObjectStoreSettings(chn *chnv1.Channel, endpoint, accessKeyID, secretAccessKey,
+	region string) (ObjectStoreSettings, error) {
+	settings := NewObjectStoreSettings(endpoint, accessKeyID, secretAccessKey, region)
+	annotations := chn.GetAnnotations()
+
+	if customEndpoint := strings.TrimSpace(annotations[appv1.AnnotationObjectStoreEndpoint]); customEndpoint != "" {
+		u, err := url.Parse(customEndpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return settings, fmt.Errorf("invalid object store endpoint %q of channel %v/%v", customEndpoint,
+				chn.GetNamespace(), chn.GetName())
+		}
+
+		settings.Endpoint = customEndpoint
+		settings.CustomEndpoint = true
+		settings.PathStyle = true
+		settings.Region = region
+
+		if settings.Region == "" {
+			settings.Region = defaultCustomEndpointRegion
+		}
+	}
+
+	if customRegion := strings.TrimSpace(annotations[appv1.AnnotationObjectStoreRegion]); customRegion != "" {
+		settings.Region = customRegion
+	}
+
+	if pathStyle := strings.TrimSpace(annotations[appv1.AnnotationObjectStorePathStyle]); pathStyle != "" {
+		usePathStyle, err := strconv.ParseBool(pathStyle)
+		if err != nil {
+			return settings, fmt.Errorf("invalid object store path style %q of channel %v/%v, it must be true or false",
+				pathStyle, chn.GetNamespace(), chn.GetName())
+		}
+
+		settings.PathStyle = usePathStyle
+	}
+
+	switch credentials := strings.ToLower(strings.TrimSpace(annotations[appv1.AnnotationObjectStoreCredentials])); credentials {
+	case "":
+	case appv1.ObjectStoreCredentialsSecret:
+		settings.AmbientCredentials = false
+	case appv1.ObjectStoreCredentialsAmbient:
+		if !ambientCredentialsAllowed(chn.GetNamespace()) {
+			return settings, fmt.Errorf("the object store ambient credentials of channel %v/%v are not allowed by the "+
+				"operator in namespace %v", chn.GetNamespace(), chn.GetName(), chn.GetNamespace())
+		}
+
+		settings.AmbientCredentials = true
+	default:
+		return settings, fmt.Errorf("invalid object store credentials %q of channel %v/%v, it must be %v or %v",
+			credentials, chn.GetNamespace(), chn.GetName(), appv1.ObjectStoreCredentialsSecret,
+			appv1.ObjectStoreCredentialsAmbient)
+	}
+
+	return settings, nil
+}
+
+// InitObjectStoreConnection connect to object store.
+func (h *Handler) InitObjectStoreConnection(endpoint, accessKeyID, secretAccessKey, region string) error {
+	return h.InitObjectStore(NewObjectStoreSettings(endpoint, accessKeyID, secretAccessKey, region))
+}
+
+// InitObjectStore connects to the object store of the settings.
+func (h *Handler) InitObjectStore(settings ObjectStoreSettings) error {
+	klog.Infof("Preparing S3 settings endpoint: %v, region: %v, path style: %v, ambient credentials: %v",
+		settings.Endpoint, settings.Region, settings.PathStyle, settings.AmbientCredentials)
+
 	// aws s3 object store doesn't need to specify URL.
-	// minio object store needs immutable URL. The aws sdk is not allowed to modify the host name of the minio URL
+	// the custom endpoints, like the minio ones, need the URL. The aws sdk is not allowed to modify the host name of
+	// the URL unless the buckets are addressed as hosts
 	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
 		klog.V(1).Infof("service: %v, region: %v", service, region)
-		if region == "minio" {
+		// the STS requests of the ambient credentials go to AWS
+		if settings.CustomEndpoint && service == s3.ServiceID {
 			return aws.Endpoint{
-				URL:               endpoint,
-				HostnameImmutable: true,
+				URL:               settings.Endpoint,
+				HostnameImmutable: settings.PathStyle,
 			}, nil
 		}
 		return aws.Endpoint{}, &aws.EndpointNotFoundError{}
 	})
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithEndpointResolverWithOptions(customResolver))
+	// dial with the fetch dialer for dual-stack support and the custom DNS server, if any
+	httpClient := awshttp.NewBuildableClient().WithDialerOptions(utils.ConfigureFetchDialer)
+
+	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithEndpointResolverWithOptions(customResolver),
+		config.WithHTTPClient(httpClient))
 	if err != nil {
 		klog.Error("Failed to load aws config. error: ", err)
 
 		return err
 	}
 
-	objCredential := credentialProvider{
-		Value: aws.Credentials{
-			AccessKeyID:     accessKeyID,
-			SecretAccessKey: secretAccessKey,
-		},
+	// the default chain credentials are cached and refreshed before they expire
+	var objCredential aws.CredentialsProvider = cfg.Credentials
+
+	switch {
+	case settings.AmbientCredentials:
+	case settings.AccessKeyID == "" && settings.SecretAccessKey == "":
+		// the public buckets are read without credentials
+		objCredential = aws.AnonymousCredentials{}
+	default:
+		objCredential = credentialProvider{
+			Value: aws.Credentials{
+				AccessKeyID:     settings.AccessKeyID,
+				SecretAccessKey: settings.SecretAccessKey,
+			},
+		}
 	}
 
 	h.Client = s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.Region = objectRegion
+		// without a region in the secret, the region of the pod environment, like AWS_REGION, is kept
+		if settings.Region != "" {
+			o.Region = settings.Region
+		}
 		o.Credentials = objCredential
+		o.UsePathStyle = settings.PathStyle
 	})
 
 	if h.Client == nil {
@@ -21,6 +21,10 @@ import (
 	"github.com/johannesboyne/gofakes3"
 	"github.com/johannesboyne/gofakes3/backend/s3mem"
 	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
 )
 
 func TestObjectstore(t *testing.T) {
@@ -110,3 +114,144 @@ func TestObjectstore(t *testing.T) {
 	_, err = awshandler.Get("test", "testObj")
 	g.Expect(err).To(gomega.HaveOccurred())
 }
+
+func TestChannelObjectStoreSettings(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	backend := s3mem.New()
+	faker := gofakes3.New(backend)
+	ts := httptest.NewServer(faker.Server())
+
+	defer ts.Close()
+
+	chn := &chnv1.Channel{ObjectMeta: metav1.ObjectMeta{Name: "objstore", Namespace: "default"}}
+
+	// the AWS S3 pathnames are resolved from the region of the secret
+	settings, err := GetChannelObjectStoreSettings(chn, "https://s3.amazonaws.com", "id", "key", "eu-west-1")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(settings.CustomEndpoint).To(gomega.BeFalse())
+	g.Expect(settings.PathStyle).To(gomega.BeFalse())
+	g.Expect(settings.Region).To(gomega.Equal("eu-west-1"))
+	g.Expect(settings.AmbientCredentials).To(gomega.BeFalse())
+
+	// the AWS S3 pathnames without access keys are read anonymously
+	settings, err = GetChannelObjectStoreSettings(chn, "https://s3.amazonaws.com", "", "", "eu-west-1")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(settings.AmbientCredentials).To(gomega.BeFalse())
+
+	// the channels can't select the ambient credentials unless the operator allows them in their namespace
+	chn.SetAnnotations(map[string]string{appv1.AnnotationObjectStoreCredentials: "ambient"})
+
+	_, err = GetChannelObjectStoreSettings(chn, "https://s3.amazonaws.com", "", "", "eu-west-1")
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	SetAmbientCredentials(true, "kuberesources")
+
+	defer SetAmbientCredentials(false, "")
+
+	_, err = GetChannelObjectStoreSettings(chn, "https://s3.amazonaws.com", "", "", "eu-west-1")
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	SetAmbientCredentials(true, "")
+
+	settings, err = GetChannelObjectStoreSettings(chn, "https://s3.amazonaws.com", "", "", "eu-west-1")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(settings.AmbientCredentials).To(gomega.BeTrue())
+
+	chn.SetAnnotations(map[string]string{appv1.AnnotationObjectStoreCredentials: "Secret"})
+
+	settings, err = GetChannelObjectStoreSettings(chn, "https://s3.amazonaws.com", "", "", "eu-west-1")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(settings.AmbientCredentials).To(gomega.BeFalse())
+
+	chn.SetAnnotations(map[string]string{appv1.AnnotationObjectStoreCredentials: "ambient"})
+
+	settings, err = GetChannelObjectStoreSettings(chn, ts.URL, "id", "key", "")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(settings.AmbientCredentials).To(gomega.BeTrue())
+	g.Expect(settings.CustomEndpoint).To(gomega.BeTrue())
+
+	chn.SetAnnotations(map[string]string{appv1.AnnotationObjectStoreCredentials: "long-lived"})
+
+	_, err = GetChannelObjectStoreSettings(chn, "https://s3.amazonaws.com", "id", "key", "eu-west-1")
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	// an explicit endpoint replaces the pathname one
+	chn.SetAnnotations(map[string]string{
+		appv1.AnnotationObjectStoreEndpoint: ts.URL,
+		appv1.AnnotationObjectStoreRegion:   "ceph",
+	})
+
+	settings, err = GetChannelObjectStoreSettings(chn, "https://s3.amazonaws.com", "id", "key", "eu-west-1")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(settings).To(gomega.Equal(ObjectStoreSettings{
+		Endpoint:        ts.URL,
+		AccessKeyID:     "id",
+		SecretAccessKey: "key",
+		Region:          "ceph",
+		CustomEndpoint:  true,
+		PathStyle:       true,
+	}))
+
+	awshandler := &Handler{}
+	g.Expect(awshandler.InitObjectStore(settings)).To(gomega.Succeed())
+	g.Expect(awshandler.Create("test")).To(gomega.Succeed())
+	g.Expect(awshandler.Exists("test")).To(gomega.Succeed())
+
+	chn.SetAnnotations(map[string]string{appv1.AnnotationObjectStoreEndpoint: ts.URL,
+		appv1.AnnotationObjectStorePathStyle: "false"})
+
+	settings, err = GetChannelObjectStoreSettings(chn, "https://s3.amazonaws.com", "id", "key", "")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(settings.PathStyle).To(gomega.BeFalse())
+	g.Expect(settings.Region).To(gomega.Equal(defaultCustomEndpointRegion))
+
+	chn.SetAnnotations(map[string]string{appv1.AnnotationObjectStorePathStyle: "sometimes"})
+
+	_, err = GetChannelObjectStoreSettings(chn, ts.URL, "id", "key", "")
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	chn.SetAnnotations(map[string]string{appv1.AnnotationObjectStoreEndpoint: "minio:9000"})
+
+	_, err = GetChannelObjectStoreSettings(chn, ts.URL, "id", "key", "")
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestObjectKeyFilter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	keys := []string{"team-a/app.yaml", "team-a/base/cm.yaml", "team-a/README.md", "team-ab/app.yaml", "team-b/app.yaml"}
+
+	// without annotations, the whole bucket is listed
+	filter, err := GetObjectKeyFilter(&chnv1.Channel{}, &appv1.Subscription{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(filter.ListPrefix()).To(gomega.BeNil())
+	g.Expect(filter.FilterKeys(keys)).To(gomega.Equal(keys))
+
+	chn := &chnv1.Channel{ObjectMeta: metav1.ObjectMeta{Name: "objstore", Namespace: "default", Annotations: map[string]string{
+		appv1.AnnotationObjectStorePrefix:    "/team-a",
+		appv1.AnnotationObjectStoreKeyFilter: "*.yaml, base/*",
+	}}}
+
+	filter, err = GetObjectKeyFilter(chn, &appv1.Subscription{})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(*filter.ListPrefix()).To(gomega.Equal("team-a/"))
+	g.Expect(filter.FilterKeys(keys)).To(gomega.Equal([]string{"team-a/app.yaml", "team-a/base/cm.yaml"}))
+
+	// the bucket path of the subscription is a folder of the channel one, the globs are still relative to the channel
+	sub := &appv1.Subscription{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		appv1.AnnotationBucketPath: "base/",
+	}}}
+
+	chn.Annotations[appv1.AnnotationObjectStoreKeyFilter] = "base/*.yaml"
+
+	filter, err = GetObjectKeyFilter(chn, sub)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(*filter.ListPrefix()).To(gomega.Equal("team-a/base/"))
+	g.Expect(filter.FilterKeys(keys)).To(gomega.Equal([]string{"team-a/base/cm.yaml"}))
+
+	chn.Annotations[appv1.AnnotationObjectStoreKeyFilter] = "[a-"
+
+	_, err = GetObjectKeyFilter(chn, sub)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
@@ -0,0 +1,464 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package azure is the Azure Blob Storage handler of the object bucket channels, on the Blob service REST API
+package azure
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/adal"
+	"k8s.io/klog/v2"
+
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+	awsutils "open-cluster-management.io/multicloud-operators-subscription/pkg/utils/aws"
+)
+
+const (
+	// SecretMapKeyConnectionString is key of the connection string of the storage account in secret. The managed
+	// identity of the pod is used if the secret has none.
+	SecretMapKeyConnectionString = "ConnectionString"
+	// SecretMapKeyManagedIdentityClientID is key of the client ID of the user-assigned managed identity in secret.
+	SecretMapKeyManagedIdentityClientID = "ManagedIdentityClientID"
+	// BlobEndpointSuffix is the host suffix of the Azure Blob Storage endpoints.
+	BlobEndpointSuffix = ".blob.core.windows.net"
+
+	// metadata key for storing the deployable generatename name.
+	DeployableGenerateNameMeta = "x-ms-meta-generatename"
+	// metadata key for storing the deployable version.
+	DeployableVersionMeta = "x-ms-meta-deployableversion"
+
+	storageVersion  = "2020-10-02"
+	storageResource = "https://storage.azure.com/"
+	requestTimeout  = 60 * time.Second
+)
+
+// Handler handles connections to Azure Blob Storage.
+type Handler struct {
+	client     *http.Client
+	endpoint   string
+	account    string
+	accountKey []byte
+	sas        url.Values
+	token      *adal.ServicePrincipalToken
+}
+
+var _ awsutils.ObjectStore = &Handler{}
+
+// IsAzurePathname tells if the pathname of an object bucket channel is an Azure Blob Storage container, a
+// https://<account>.blob.core.windows.net/<container> URL.
+func IsAzurePathname(pathname string) bool {
+	u, err := url.Parse(strings.ToLower(strings.TrimSpace(pathname)))
+
+	return err == nil && strings.HasSuffix(u.Hostname(), BlobEndpointSuffix)
+}
+
+// IsAzureChannel tells if an object bucket channel is an Azure Blob Storage container, from its pathname or from the
+// connection string of its secret, like for the Azurite emulator
+func IsAzureChannel(pathname string, secretData map[string][]byte) bool {
+	return IsAzurePathname(pathname) || len(secretData[SecretMapKeyConnectionString]) > 0
+}
+
+// InitObjectStoreConnection connects to Azure Blob Storage at the endpoint with the connection string of the storage
+// account, with its account key or its shared access signature. The managed identity of the pod, the user-assigned
+// one of the client ID if set, is used if the connection string is empty.
+func (h *Handler) InitObjectStoreConnection(endpoint, connectionString, managedIdentityClientID string) error {
+	klog.Infof("Preparing Azure Blob Storage settings endpoint: %v", endpoint)
+
+	// dial with the fetch dialer for dual-stack support and the custom DNS server, if any
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = utils.NewFetchDialer().DialContext
+
+	h.client = &http.Client{Transport: transport, Timeout: requestTimeout}
+	h.endpoint = strings.TrimSuffix(endpoint, "/")
+
+	if strings.TrimSpace(connectionString) != "" {
+		return h.parseConnectionString(connectionString)
+	}
+
+	token, err := adal.NewServicePrincipalTokenFromManagedIdentity(storageResource,
+		&adal.ManagedIdentityOptions{ClientID: managedIdentityClientID})
+	if err != nil {
+		klog.Error("Failed to get the Azure managed identity token. error: ", err)
+
+		return err
+	}
+
+	token.SetSender(h.client)
+
+	h.token = token
+
+	klog.V(1).Info("Azure Blob Storage configured with the managed identity")
+
+	return nil
+}
+
+// parseConnectionString sets the account and its key, or the shared access signature, of the connection string. Its
+// blob endpoint, if set, replaces the endpoint of the pathname, like for the Azurite emulator. It must be in the
+// channel source allow-list, like the pathname.
+func (h *Handler) parseConnectionString(connectionString string) error {
+	settings := map[string]string{}
+
+	for _, setting := range strings.Split(strings.TrimSpace(connectionString), ";") {
+		if setting == "" {
+			continue
+		}
+
+		kv := strings.SplitN(setting, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid setting %q in the Azure connection string", kv[0])
+		}
+
+		settings[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+	}
+
+	if blobEndpoint := settings["blobendpoint"]; blobEndpoint != "" {
+		if err := utils.CheckSourceURLAllowed(blobEndpoint); err != nil {
+			return fmt.Errorf("blob endpoint of the Azure connection string: %w", err)
+		}
+
+		h.endpoint = strings.TrimSuffix(blobEndpoint, "/")
+	}
+
+	if sas := settings["sharedaccesssignature"]; sas != "" {
+		values, err := url.ParseQuery(strings.TrimPrefix(sas, "?"))
+		if err != nil {
+			return fmt.Errorf("invalid shared access signature in the Azure connection string: %w", err)
+		}
+
+		h.sas = values
+
+		klog.V(1).Info("Azure Blob Storage configured with a shared access signature")
+
+		return nil
+	}
+
+	h.account = settings["accountname"]
+
+	accountKey, err := base64.StdEncoding.DecodeString(settings["accountkey"])
+	if err != nil || h.account == "" || len(accountKey) == 0 {
+		return fmt.Errorf("the Azure connection string needs an AccountName and a base64 AccountKey, or a " +
+			"SharedAccessSignature")
+	}
+
+	h.accountKey = accountKey
+
+	klog.V(1).Info("Azure Blob Storage configured with the key of account ", h.account)
+
+	return nil
+}
+
+// blobURL returns the URL of the container, or of its blob if the name is set, with the query
+func (h *Handler) blobURL(container, name string, query url.Values) string {
+	reqURL := h.endpoint + "/" + url.PathEscape(container)
+
+	if name != "" {
+		// the blob names keep their folders
+		segments := strings.Split(name, "/")
+		for i := range segments {
+			segments[i] = url.PathEscape(segments[i])
+		}
+
+		reqURL += "/" + strings.Join(segments, "/")
+	}
+
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	return reqURL
+}
+
+// do sends the authorized request and returns the response, a 404 is returned as an error with the not found status
+func (h *Handler) do(method, reqURL string, header http.Header, body []byte) ([]byte, http.Header, int, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), requestTimeout)
+	defer cancel()
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reqBody)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if err := h.authorize(req, len(body)); err != nil {
+		return nil, nil, 0, err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.Header, resp.StatusCode, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, resp.Header, resp.StatusCode, fmt.Errorf("%v %v failed with status %v: %v", method, req.URL.Path,
+			resp.Status, resp.Header.Get("x-ms-error-code"))
+	}
+
+	return respBody, resp.Header, resp.StatusCode, nil
+}
+
+// authorize signs the request with the account key, adds the shared access signature, or the bearer token of the
+// managed identity
+func (h *Handler) authorize(req *http.Request, contentLength int) error {
+	req.Header.Set("x-ms-version", storageVersion)
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+
+	switch {
+	case h.sas != nil:
+		query := req.URL.Query()
+
+		for key, values := range h.sas {
+			query[key] = values
+		}
+
+		req.URL.RawQuery = query.Encode()
+	case h.accountKey != nil:
+		req.Header.Set("Authorization", "SharedKey "+h.account+":"+h.sharedKeySignature(req, contentLength))
+	case h.token != nil:
+		if err := h.token.EnsureFresh(); err != nil {
+			return fmt.Errorf("failed to refresh the Azure managed identity token: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+h.token.OAuthToken())
+	}
+
+	return nil
+}
+
+// sharedKeySignature returns the Shared Key signature of the request
+func (h *Handler) sharedKeySignature(req *http.Request, contentLength int) string {
+	length := ""
+	if contentLength > 0 {
+		length = strconv.Itoa(contentLength)
+	}
+
+	msHeaders := []string{}
+
+	for key := range req.Header {
+		if key = strings.ToLower(key); strings.HasPrefix(key, "x-ms-") {
+			msHeaders = append(msHeaders, key)
+		}
+	}
+
+	sort.Strings(msHeaders)
+
+	canonicalizedHeaders := ""
+	for _, key := range msHeaders {
+		canonicalizedHeaders += key + ":" + strings.TrimSpace(req.Header.Get(key)) + "\n"
+	}
+
+	canonicalizedResource := "/" + h.account + req.URL.EscapedPath()
+
+	query := req.URL.Query()
+	params := []string{}
+
+	for key := range query {
+		params = append(params, key)
+	}
+
+	sort.Strings(params)
+
+	for _, key := range params {
+		values := query[key]
+		sort.Strings(values)
+		canonicalizedResource += "\n" + strings.ToLower(key) + ":" + strings.Join(values, ",")
+	}
+
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		length,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, x-ms-date is set
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+		canonicalizedHeaders + canonicalizedResource,
+	}, "\n")
+
+	mac := hmac.New(sha256.New, h.accountKey)
+	mac.Write([]byte(stringToSign))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Create a container.
+func (h *Handler) Create(container string) error {
+	if _, _, _, err := h.do(http.MethodPut, h.blobURL(container, "", url.Values{"restype": {"container"}}), nil,
+		nil); err != nil {
+		klog.Error("Failed to create container ", container, ". error: ", err)
+
+		return err
+	}
+
+	return nil
+}
+
+// Exists Checks whether a container exists and is accessible.
+func (h *Handler) Exists(container string) error {
+	if _, _, _, err := h.do(http.MethodGet, h.blobURL(container, "", url.Values{"restype": {"container"}}), nil,
+		nil); err != nil {
+		klog.Error("Failed to access container ", container, ". error: ", err)
+
+		return err
+	}
+
+	return nil
+}
+
+type blobList struct {
+	Blobs struct {
+		Blob []struct {
+			Name string `xml:"Name"`
+		} `xml:"Blob"`
+	} `xml:"Blobs"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// List all objects in container.
+func (h *Handler) List(container string, folderName *string) ([]string, error) {
+	var keys []string
+
+	query := url.Values{"restype": {"container"}, "comp": {"list"}}
+
+	if folderName != nil {
+		query.Set("prefix", *folderName)
+	}
+
+	for {
+		body, _, _, err := h.do(http.MethodGet, h.blobURL(container, "", query), nil, nil)
+		if err != nil {
+			klog.Infof("Got error retrieving list of objects. err: %v", err)
+
+			return keys, err
+		}
+
+		list := &blobList{}
+		if err := xml.Unmarshal(body, list); err != nil {
+			return keys, err
+		}
+
+		for _, blob := range list.Blobs.Blob {
+			if len(blob.Name) > 0 && !strings.HasSuffix(blob.Name, "/") {
+				keys = append(keys, blob.Name)
+			} else {
+				klog.V(1).Info("Skipping Azure Blob: ", blob.Name)
+			}
+		}
+
+		if list.NextMarker == "" {
+			break
+		}
+
+		query.Set("marker", list.NextMarker)
+	}
+
+	klog.Infof("List Azure Blobs result, keys: %v", keys)
+
+	return keys, nil
+}
+
+// Get get existing object.
+func (h *Handler) Get(container, name string) (awsutils.DeployableObject, error) {
+	dplObj := awsutils.DeployableObject{}
+
+	content, header, _, err := h.do(http.MethodGet, h.blobURL(container, name, nil), nil, nil)
+	if err != nil {
+		klog.Error("Failed to send Get request. error: ", err)
+
+		return dplObj, err
+	}
+
+	if len(content) == 0 {
+		return awsutils.DeployableObject{}, nil
+	}
+
+	dplObj.Name = name
+	dplObj.GenerateName = header.Get(DeployableGenerateNameMeta)
+	dplObj.Version = header.Get(DeployableVersionMeta)
+	dplObj.Content = content
+
+	klog.V(1).Info("Get Success: \n", string(content))
+
+	return dplObj, nil
+}
+
+// Put create new object.
+func (h *Handler) Put(container string, dplObj awsutils.DeployableObject) error {
+	if dplObj.Name == "" && dplObj.GenerateName == "" && len(dplObj.Content) == 0 {
+		klog.V(1).Infof("got an empty deployableObject to put to object store")
+
+		return nil
+	}
+
+	header := http.Header{}
+	header.Set("x-ms-blob-type", "BlockBlob")
+
+	if _, _, _, err := h.do(http.MethodPut, h.blobURL(container, dplObj.Name, nil), header, dplObj.Content); err != nil {
+		klog.Error("Failed to send Put request. error: ", err)
+
+		return err
+	}
+
+	klog.V(5).Info("Put Success")
+
+	return nil
+}
+
+// Delete delete existing object.
+func (h *Handler) Delete(container, name string) error {
+	_, _, status, err := h.do(http.MethodDelete, h.blobURL(container, name, nil), nil, nil)
+	if err != nil && status != http.StatusNotFound {
+		klog.Error("Failed to send Delete request. error: ", err)
+
+		return err
+	}
+
+	klog.V(1).Info("Delete Success")
+
+	return nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/onsi/gomega"
+
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+	awsutils "open-cluster-management.io/multicloud-operators-subscription/pkg/utils/aws"
+)
+
+// the well-known key of the Azurite emulator account
+const azuriteKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+func TestIsAzureChannel(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(IsAzureChannel("https://appstore.blob.core.windows.net/app-container", nil)).To(gomega.BeTrue())
+	g.Expect(IsAzureChannel("https://s3.amazonaws.com/app-bucket", nil)).To(gomega.BeFalse())
+	g.Expect(IsAzureChannel("http://127.0.0.1:10000/devstoreaccount1/app-container",
+		map[string][]byte{SecretMapKeyConnectionString: []byte("UseDevelopmentStorage=true")})).To(gomega.BeTrue())
+}
+
+func TestParseConnectionString(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	h := &Handler{endpoint: "https://appstore.blob.core.windows.net"}
+	g.Expect(h.parseConnectionString("DefaultEndpointsProtocol=https;AccountName=appstore;AccountKey=" + azuriteKey +
+		";EndpointSuffix=core.windows.net")).To(gomega.Succeed())
+	g.Expect(h.account).To(gomega.Equal("appstore"))
+	g.Expect(h.accountKey).To(gomega.HaveLen(64))
+	g.Expect(h.endpoint).To(gomega.Equal("https://appstore.blob.core.windows.net"))
+
+	h = &Handler{endpoint: "https://appstore.blob.core.windows.net"}
+	g.Expect(h.parseConnectionString("BlobEndpoint=https://blobs.example.com/;SharedAccessSignature=sv=2020-10-02&sig=abc%3D")).
+		To(gomega.Succeed())
+	g.Expect(h.endpoint).To(gomega.Equal("https://blobs.example.com"))
+	g.Expect(h.sas.Get("sig")).To(gomega.Equal("abc="))
+	g.Expect(h.accountKey).To(gomega.BeNil())
+
+	g.Expect((&Handler{}).parseConnectionString("AccountName=appstore")).NotTo(gomega.Succeed())
+	g.Expect((&Handler{}).parseConnectionString("AccountName=appstore;AccountKey=not-base64!")).NotTo(gomega.Succeed())
+	g.Expect((&Handler{}).parseConnectionString("AccountName")).NotTo(gomega.Succeed())
+
+	// the blob endpoint must be in the channel source allow-list
+	g.Expect(utils.SetChannelSourceAllowList("*.blob.core.windows.net")).To(gomega.Succeed())
+
+	defer func() {
+		g.Expect(utils.SetChannelSourceAllowList("")).To(gomega.Succeed())
+	}()
+
+	g.Expect((&Handler{}).parseConnectionString("BlobEndpoint=https://blobs.example.com/;SharedAccessSignature=sv=2020-10-02")).
+		NotTo(gomega.Succeed())
+	g.Expect((&Handler{}).parseConnectionString("BlobEndpoint=https://appstore.blob.core.windows.net/;" +
+		"SharedAccessSignature=sv=2020-10-02")).To(gomega.Succeed())
+}
+
+func TestHandler(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	// the fake Blob service keeps the blobs of the app-container container of the devstoreaccount1 account, listed
+	// one per page
+	var mu sync.Mutex
+
+	blobs := map[string][]byte{"folder/": nil}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		g.Expect(r.Header.Get("x-ms-version")).To(gomega.Equal(storageVersion))
+		g.Expect(r.Header.Get("Authorization")).To(gomega.HavePrefix("SharedKey devstoreaccount1:"))
+
+		query := r.URL.Query()
+		path := r.URL.Path
+
+		switch {
+		case path == "/devstoreaccount1/app-container" && query.Get("comp") == "list":
+			names := []string{}
+
+			for name := range blobs {
+				if strings.HasPrefix(name, query.Get("prefix")) && name > query.Get("marker") {
+					names = append(names, name)
+				}
+			}
+
+			sort.Strings(names)
+
+			if len(names) == 0 {
+				_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs/>` +
+					`<NextMarker/></EnumerationResults>`))
+
+				return
+			}
+
+			_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs><Blob>`+
+				`<Name>%s</Name></Blob></Blobs><NextMarker>%s</NextMarker></EnumerationResults>`, names[0], names[0])
+		case path == "/devstoreaccount1/app-container" && query.Get("restype") == "container":
+			g.Expect(r.Method).To(gomega.Equal(http.MethodGet))
+		case strings.HasPrefix(path, "/devstoreaccount1/app-container/"):
+			name := strings.TrimPrefix(path, "/devstoreaccount1/app-container/")
+
+			if r.Method == http.MethodPut {
+				g.Expect(r.Header.Get("x-ms-blob-type")).To(gomega.Equal("BlockBlob"))
+
+				content, err := ioutil.ReadAll(r.Body)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+
+				blobs[name] = content
+				w.WriteHeader(http.StatusCreated)
+
+				return
+			}
+
+			content, ok := blobs[name]
+			if !ok {
+				w.Header().Set("x-ms-error-code", "BlobNotFound")
+				w.WriteHeader(http.StatusNotFound)
+
+				return
+			}
+
+			if r.Method == http.MethodDelete {
+				delete(blobs, name)
+				w.WriteHeader(http.StatusAccepted)
+
+				return
+			}
+
+			w.Header().Set(DeployableGenerateNameMeta, "app-")
+			w.Header().Set(DeployableVersionMeta, "v1")
+			_, _ = w.Write(content)
+		default:
+			w.Header().Set("x-ms-error-code", "ContainerNotFound")
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	h := &Handler{client: server.Client()}
+	g.Expect(h.parseConnectionString("AccountName=devstoreaccount1;AccountKey=" + azuriteKey + ";BlobEndpoint=" +
+		server.URL + "/devstoreaccount1;")).To(gomega.Succeed())
+
+	g.Expect(h.Exists("app-container")).To(gomega.Succeed())
+	g.Expect(h.Exists("other-container")).NotTo(gomega.Succeed())
+
+	for _, name := range []string{"folder/configmap.yaml", "folder/secret.yaml", "deployment.yaml"} {
+		g.Expect(h.Put("app-container", awsutils.DeployableObject{Name: name, Content: []byte("kind: " + name)})).
+			To(gomega.Succeed())
+	}
+
+	keys, err := h.List("app-container", nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(keys).To(gomega.Equal([]string{"deployment.yaml", "folder/configmap.yaml", "folder/secret.yaml"}))
+
+	folder := "folder/"
+	keys, err = h.List("app-container", &folder)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(keys).To(gomega.Equal([]string{"folder/configmap.yaml", "folder/secret.yaml"}))
+
+	dplObj, err := h.Get("app-container", "folder/configmap.yaml")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(dplObj).To(gomega.Equal(awsutils.DeployableObject{
+		Name:         "folder/configmap.yaml",
+		GenerateName: "app-",
+		Version:      "v1",
+		Content:      []byte("kind: folder/configmap.yaml"),
+	}))
+
+	g.Expect(h.Delete("app-container", "folder/configmap.yaml")).To(gomega.Succeed())
+	g.Expect(h.Delete("app-container", "folder/configmap.yaml")).To(gomega.Succeed())
+
+	_, err = h.Get("app-container", "folder/configmap.yaml")
+	g.Expect(err).To(gomega.HaveOccurred())
+}
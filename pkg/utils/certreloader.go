@@ -0,0 +1,131 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// CertReloader serves the certificate of a TLS key pair of files, reloaded when one of the files changes. The
+// rotated certificates are served to the new connections without restarting the operator, the established
+// connections keep the certificate they were opened with.
+type CertReloader struct {
+	crtFile string
+	keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	crtStat fileStamp
+	keyStat fileStamp
+}
+
+// fileStamp identifies the content of a file, the mounted secrets are replaced as a whole when they are updated
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewCertReloader loads the TLS key pair of files, it fails if they can't be loaded
+func NewCertReloader(crtFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{crtFile: crtFile, keyFile: keyFile}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, reloaded first if its files changed. The previous certificate is
+// still served if the new files can't be loaded, e.g. when the key is rotated before the certificate.
+func (r *CertReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if err := r.reload(); err != nil {
+		klog.Warningf("failed to reload the TLS key pair %v %v, serving the previous certificate, err: %v", r.crtFile, r.keyFile, err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+func (r *CertReloader) reload() error {
+	crtStat, err := stampFile(r.crtFile)
+	if err != nil {
+		return err
+	}
+
+	keyStat, err := stampFile(r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && crtStat == r.crtStat && keyStat == r.keyStat
+	r.mu.RUnlock()
+
+	if unchanged {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.crtFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cert != nil {
+		klog.Infof("reloaded the TLS key pair %v %v", r.crtFile, r.keyFile)
+	}
+
+	r.cert = &cert
+	r.crtStat = crtStat
+	r.keyStat = keyStat
+
+	return nil
+}
+
+func stampFile(name string) (fileStamp, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return fileStamp{}, err
+	}
+
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// ListenAndServeTLS serves the server with the certificate of the TLS key pair of files, reloaded when they change
+func ListenAndServeTLS(srv *http.Server, crtFile, keyFile string) error {
+	reloader, err := NewCertReloader(crtFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	if srv.TLSConfig == nil {
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	srv.TLSConfig.GetCertificate = reloader.GetCertificate
+
+	return srv.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func writeKeyPair(g *gomega.WithT, crtFile, keyFile string, serial int64, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "subscription"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	g.Expect(ioutil.WriteFile(crtFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)).To(gomega.Succeed())
+	g.Expect(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)).To(gomega.Succeed())
+
+	g.Expect(os.Chtimes(crtFile, modTime, modTime)).To(gomega.Succeed())
+	g.Expect(os.Chtimes(keyFile, modTime, modTime)).To(gomega.Succeed())
+}
+
+func TestCertReloader(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "certreloader")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	crtFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	_, err = NewCertReloader(crtFile, keyFile)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	now := time.Now()
+	writeKeyPair(g, crtFile, keyFile, 1, now.Add(-time.Minute))
+
+	reloader, err := NewCertReloader(crtFile, keyFile)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	serial := func() int64 {
+		cert, err := reloader.GetCertificate(nil)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		return x509Cert.SerialNumber.Int64()
+	}
+
+	g.Expect(serial()).To(gomega.Equal(int64(1)))
+
+	// the rotated certificate is served
+	writeKeyPair(g, crtFile, keyFile, 2, now)
+	g.Expect(serial()).To(gomega.Equal(int64(2)))
+
+	// a broken key pair is not served
+	g.Expect(ioutil.WriteFile(keyFile, []byte("not a key"), 0600)).To(gomega.Succeed())
+	g.Expect(serial()).To(gomega.Equal(int64(2)))
+
+	g.Expect(os.Remove(crtFile)).To(gomega.Succeed())
+	g.Expect(serial()).To(gomega.Equal(int64(2)))
+
+	writeKeyPair(g, crtFile, keyFile, 3, now.Add(time.Minute))
+	g.Expect(serial()).To(gomega.Equal(int64(3)))
+}
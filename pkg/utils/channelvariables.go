@@ -0,0 +1,137 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	releasev1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/helmrelease/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+const (
+	// channelLabelVariablePrefix prefixes the variables of the channel labels, by label name
+	channelLabelVariablePrefix = "CHANNEL_LABEL_"
+	// channelAnnotationVariablePrefix prefixes the variables of the channel annotations, by annotation name
+	channelAnnotationVariablePrefix = "CHANNEL_ANNOTATION_"
+)
+
+// HasChannelVariables returns true if the channel variables are substituted in the HelmRelease values of the
+// subscription
+func HasChannelVariables(sub *appv1.Subscription) bool {
+	return strings.EqualFold(sub.GetAnnotations()[appv1.AnnotationChannelVariables], "true")
+}
+
+// GetChannelVariables returns the variables of the channel: CHANNEL_NAME and CHANNEL_NAMESPACE,
+// CHANNEL_LABEL_<NAME> for every label and CHANNEL_ANNOTATION_<NAME> for every annotation, their name upper cased
+// with the other characters than letters and digits replaced by _
+func GetChannelVariables(chn *chnv1.Channel) map[string]string {
+	vars := map[string]string{}
+
+	if chn == nil {
+		return vars
+	}
+
+	vars["CHANNEL_NAME"] = chn.GetName()
+	vars["CHANNEL_NAMESPACE"] = chn.GetNamespace()
+
+	for name, value := range chn.GetLabels() {
+		vars[channelLabelVariablePrefix+nonVariableChars.ReplaceAllString(strings.ToUpper(name), "_")] = value
+	}
+
+	for name, value := range chn.GetAnnotations() {
+		vars[channelAnnotationVariablePrefix+nonVariableChars.ReplaceAllString(strings.ToUpper(name), "_")] = value
+	}
+
+	return vars
+}
+
+// GetHelmReleaseNamespace returns the namespace of the HelmReleases of the subscription, its helm-release-namespace
+// annotation with the channel variables substituted, or the subscription namespace
+func GetHelmReleaseNamespace(sub *appv1.Subscription, chn *chnv1.Channel) (string, error) {
+	tmpl := strings.TrimSpace(sub.GetAnnotations()[appv1.AnnotationHelmReleaseNamespace])
+	if tmpl == "" {
+		return sub.GetNamespace(), nil
+	}
+
+	namespace, _ := substituteTemplateString(tmpl, GetChannelVariables(chn)).(string)
+
+	if strings.Contains(namespace, "${") {
+		return "", fmt.Errorf("the helm release namespace %q of subscription %v/%v references variables channel %v/%v "+
+			"doesn't have", tmpl, sub.GetNamespace(), sub.GetName(), chn.GetNamespace(), chn.GetName())
+	}
+
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return "", fmt.Errorf("invalid helm release namespace %q of subscription %v/%v: %v", namespace,
+			sub.GetNamespace(), sub.GetName(), strings.Join(errs, ", "))
+	}
+
+	return namespace, nil
+}
+
+// ResolveHelmReleaseNamespace returns the namespace the HelmReleases of the subscription are deployed in. The
+// HelmReleases of another namespace than the subscription one have no owner reference to it, so the
+// helm-release-namespace annotation is only honored for the cluster-admin subscriptions, or if canDeploy confirms the
+// subscription identity can deploy the HelmRelease there. The namespace containment policy of the subscription
+// namespace applies to it like to the other subscribed resources.
+func ResolveHelmReleaseNamespace(clt client.Client, sub *appv1.Subscription, chn *chnv1.Channel, clusterAdmin bool,
+	canDeploy func(*unstructured.Unstructured) bool) (string, error) {
+	namespace, err := GetHelmReleaseNamespace(sub, chn)
+	if err != nil || namespace == sub.GetNamespace() {
+		return namespace, err
+	}
+
+	hr := &unstructured.Unstructured{}
+	hr.SetGroupVersionKind(releasev1.SchemeGroupVersion.WithKind("HelmRelease"))
+	hr.SetNamespace(namespace)
+	hr.SetName(sub.GetName())
+
+	if err := ContainResourceNamespace(hr, sub.GetNamespace(), GetNamespaceContainment(clt, sub.GetNamespace())); err != nil {
+		return "", err
+	}
+
+	// moved to the subscription namespace by the force policy
+	if hr.GetNamespace() == sub.GetNamespace() {
+		return sub.GetNamespace(), nil
+	}
+
+	if !clusterAdmin && (canDeploy == nil || !canDeploy(hr)) {
+		return "", fmt.Errorf("the helm release namespace %v of subscription %v/%v is not allowed, the subscription is "+
+			"not cluster-admin and its user can't deploy helmreleases there", namespace, sub.GetNamespace(), sub.GetName())
+	}
+
+	return namespace, nil
+}
+
+// SubstituteChannelVariables replaces the ${NAME} references to the channel variables in the string values of the
+// HelmRelease spec, the chart values, if the subscription has the channel variables. The references to unknown
+// variables are kept.
+func SubstituteChannelVariables(helmRelease *releasev1.HelmRelease, sub *appv1.Subscription, chn *chnv1.Channel) {
+	if !HasChannelVariables(sub) || helmRelease.Spec == nil {
+		return
+	}
+
+	klog.V(1).Infof("Substituting the variables of channel %v/%v in helmrelease %v/%v", chn.GetNamespace(),
+		chn.GetName(), helmRelease.Namespace, helmRelease.Name)
+
+	helmRelease.Spec = substituteTemplateParameters(helmRelease.Spec, GetChannelVariables(chn))
+}
@@ -0,0 +1,152 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	releasev1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/helmrelease/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestChannelVariables(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	chn := &chnv1.Channel{ObjectMeta: metav1.ObjectMeta{
+		Name:        "prod-charts",
+		Namespace:   "channels",
+		Labels:      map[string]string{"environment": "prod"},
+		Annotations: map[string]string{"example.com/replicas": "3"},
+	}}
+
+	g.Expect(GetChannelVariables(chn)).To(gomega.Equal(map[string]string{
+		"CHANNEL_NAME":                            "prod-charts",
+		"CHANNEL_NAMESPACE":                       "channels",
+		"CHANNEL_LABEL_ENVIRONMENT":               "prod",
+		"CHANNEL_ANNOTATION_EXAMPLE_COM_REPLICAS": "3",
+	}))
+
+	sub := &appv1.Subscription{ObjectMeta: metav1.ObjectMeta{Name: "nginx-sub", Namespace: "apps"}}
+
+	namespace, err := GetHelmReleaseNamespace(sub, chn)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(namespace).To(gomega.Equal("apps"))
+
+	sub.SetAnnotations(map[string]string{appv1.AnnotationHelmReleaseNamespace: "nginx-${CHANNEL_LABEL_ENVIRONMENT}"})
+
+	namespace, err = GetHelmReleaseNamespace(sub, chn)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(namespace).To(gomega.Equal("nginx-prod"))
+
+	sub.SetAnnotations(map[string]string{appv1.AnnotationHelmReleaseNamespace: "nginx-${CHANNEL_LABEL_TIER}"})
+
+	_, err = GetHelmReleaseNamespace(sub, chn)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	sub.SetAnnotations(map[string]string{appv1.AnnotationHelmReleaseNamespace: "Nginx_${CHANNEL_LABEL_ENVIRONMENT}"})
+
+	_, err = GetHelmReleaseNamespace(sub, chn)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	hr := &releasev1.HelmRelease{Spec: map[string]interface{}{
+		"environment":  "${CHANNEL_LABEL_ENVIRONMENT}",
+		"replicaCount": "${{CHANNEL_ANNOTATION_EXAMPLE_COM_REPLICAS}}",
+		"region":       "${CLUSTER_REGION}",
+	}}
+
+	// the channel variables are opted in
+	SubstituteChannelVariables(hr, sub, chn)
+	g.Expect(hr.Spec).To(gomega.HaveKeyWithValue("environment", "${CHANNEL_LABEL_ENVIRONMENT}"))
+
+	sub.SetAnnotations(map[string]string{appv1.AnnotationChannelVariables: "true"})
+
+	SubstituteChannelVariables(hr, sub, chn)
+	g.Expect(hr.Spec).To(gomega.Equal(map[string]interface{}{
+		"environment":  "prod",
+		"replicaCount": int64(3),
+		"region":       "${CLUSTER_REGION}",
+	}))
+}
+
+func TestResolveHelmReleaseNamespace(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(gomega.Succeed())
+
+	clt := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "forced",
+			Annotations: map[string]string{appv1.AnnotationNamespaceContainment: "force"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "rejected",
+			Annotations: map[string]string{appv1.AnnotationNamespaceContainment: "reject"}}},
+	).Build()
+
+	chn := &chnv1.Channel{ObjectMeta: metav1.ObjectMeta{Name: "charts", Namespace: "channels"}}
+	sub := &appv1.Subscription{ObjectMeta: metav1.ObjectMeta{Name: "nginx-sub", Namespace: "apps"}}
+
+	reviewed := []string{}
+	canDeploy := func(hr *unstructured.Unstructured) bool {
+		reviewed = append(reviewed, hr.GetKind()+" "+hr.GetNamespace())
+
+		return hr.GetNamespace() == "nginx"
+	}
+
+	// the subscription namespace is not reviewed
+	namespace, err := ResolveHelmReleaseNamespace(clt, sub, chn, false, canDeploy)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(namespace).To(gomega.Equal("apps"))
+	g.Expect(reviewed).To(gomega.BeEmpty())
+
+	sub.SetAnnotations(map[string]string{appv1.AnnotationHelmReleaseNamespace: "nginx"})
+
+	namespace, err = ResolveHelmReleaseNamespace(clt, sub, chn, false, canDeploy)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(namespace).To(gomega.Equal("nginx"))
+	g.Expect(reviewed).To(gomega.Equal([]string{"HelmRelease nginx"}))
+
+	// the subscription user can't deploy in kube-system, unless the subscription is cluster-admin
+	sub.SetAnnotations(map[string]string{appv1.AnnotationHelmReleaseNamespace: "kube-system"})
+
+	_, err = ResolveHelmReleaseNamespace(clt, sub, chn, false, canDeploy)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	_, err = ResolveHelmReleaseNamespace(clt, sub, chn, false, nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	namespace, err = ResolveHelmReleaseNamespace(clt, sub, chn, true, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(namespace).To(gomega.Equal("kube-system"))
+
+	// the namespace containment policy applies even to cluster-admin
+	sub.Namespace = "forced"
+
+	namespace, err = ResolveHelmReleaseNamespace(clt, sub, chn, true, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(namespace).To(gomega.Equal("forced"))
+
+	sub.Namespace = "rejected"
+
+	_, err = ResolveHelmReleaseNamespace(clt, sub, chn, true, nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// clusterClaimVariablePrefix prefixes the variables of all the cluster claims, by claim name
+const clusterClaimVariablePrefix = "CLUSTER_CLAIM_"
+
+// clusterClaimVariables are the short variables of the well-known cluster claims
+var clusterClaimVariables = map[string]string{
+	"CLUSTER_PLATFORM": "platform.open-cluster-management.io",
+	"CLUSTER_PRODUCT":  "product.open-cluster-management.io",
+	"CLUSTER_REGION":   "region.open-cluster-management.io",
+}
+
+var nonVariableChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// HasClusterVariables returns true if the cluster variables are substituted in the resources of the subscription
+func HasClusterVariables(sub *appv1.Subscription) bool {
+	return strings.EqualFold(sub.GetAnnotations()[appv1.AnnotationClusterVariables], "true")
+}
+
+// GetClusterVariables returns the variables of the cluster facts: CLUSTER_PLATFORM, CLUSTER_PRODUCT and
+// CLUSTER_REGION for the well-known cluster claims, CLUSTER_VERSION for the Kubernetes version, and
+// CLUSTER_CLAIM_<NAME> for every cluster claim, its name upper cased with the other characters than letters and
+// digits replaced by _
+func GetClusterVariables(facts *ClusterFacts) map[string]string {
+	vars := map[string]string{}
+
+	for name, value := range facts.Labels {
+		vars[clusterClaimVariablePrefix+nonVariableChars.ReplaceAllString(strings.ToUpper(name), "_")] = value
+	}
+
+	for variable, claim := range clusterClaimVariables {
+		if value, ok := facts.Labels[claim]; ok {
+			vars[variable] = value
+		}
+	}
+
+	if facts.KubeVersion != nil {
+		vars["CLUSTER_VERSION"] = facts.KubeVersion.String()
+	}
+
+	return vars
+}
+
+// SubstituteClusterVariables returns the resource with the ${NAME} references to the cluster variables replaced in
+// its string values, the way the OpenShift template parameters are. The references to unknown variables are kept.
+func SubstituteClusterVariables(resource *unstructured.Unstructured, vars map[string]string) *unstructured.Unstructured {
+	obj, ok := substituteTemplateParameters(resource.Object, vars).(map[string]interface{})
+	if !ok {
+		return resource
+	}
+
+	return &unstructured.Unstructured{Object: obj}
+}
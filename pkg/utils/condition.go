@@ -0,0 +1,143 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// ClusterFacts are the facts about a cluster the package conditions are evaluated against
+type ClusterFacts struct {
+	// Labels are the cluster claims of the cluster, claim name to value
+	Labels map[string]string
+	// KubeVersion is the Kubernetes version of the cluster, without the pre-release and build metadata
+	KubeVersion *semver.Version
+}
+
+// NewClusterFacts returns the cluster facts of the cluster claims and the Kubernetes git version of the cluster
+func NewClusterFacts(claims map[string]string, gitVersion string) (*ClusterFacts, error) {
+	v, err := semver.NewVersion(gitVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the Kubernetes version %v, err: %w", gitVersion, err)
+	}
+
+	// vendor versions such as 1.21.5-gke.1302 are pre-releases to semver, which the constraints don't match
+	kubeVersion, err := semver.NewVersion(fmt.Sprintf("%d.%d.%d", v.Major(), v.Minor(), v.Patch()))
+	if err != nil {
+		return nil, err
+	}
+
+	if claims == nil {
+		claims = map[string]string{}
+	}
+
+	return &ClusterFacts{Labels: claims, KubeVersion: kubeVersion}, nil
+}
+
+// HasPackageCondition returns true if the subscribed resource has a condition, from its annotations or the
+// packageOverrides of its package
+func HasPackageCondition(appsub *appv1.Subscription, resource *unstructured.Unstructured) bool {
+	annotations := resource.GetAnnotations()
+
+	if annotations[appv1.AnnotationClusterSelector] != "" || annotations[appv1.AnnotationKubeVersion] != "" {
+		return true
+	}
+
+	return len(getPackageConditions(appsub, resource)) > 0
+}
+
+// IsPackageConditionMet checks the cluster meets all the conditions of the subscribed resource. If not, the reason
+// is returned.
+func IsPackageConditionMet(appsub *appv1.Subscription, resource *unstructured.Unstructured,
+	facts *ClusterFacts) (bool, string, error) {
+	annotations := resource.GetAnnotations()
+
+	if sel := annotations[appv1.AnnotationClusterSelector]; sel != "" {
+		selector, err := labels.Parse(sel)
+		if err != nil {
+			return false, "", fmt.Errorf("invalid %v annotation %v, err: %w", appv1.AnnotationClusterSelector, sel, err)
+		}
+
+		if !selector.Matches(labels.Set(facts.Labels)) {
+			return false, "cluster claims don't match " + sel, nil
+		}
+	}
+
+	if met, reason, err := isKubeVersionMet(annotations[appv1.AnnotationKubeVersion], facts); err != nil || !met {
+		return met, reason, err
+	}
+
+	for _, cond := range getPackageConditions(appsub, resource) {
+		if cond.ClusterSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(cond.ClusterSelector)
+			if err != nil {
+				return false, "", fmt.Errorf("invalid clusterSelector of package condition, err: %w", err)
+			}
+
+			if !selector.Matches(labels.Set(facts.Labels)) {
+				return false, "cluster claims don't match " + selector.String(), nil
+			}
+		}
+
+		if met, reason, err := isKubeVersionMet(cond.KubeVersion, facts); err != nil || !met {
+			return met, reason, err
+		}
+	}
+
+	return true, "", nil
+}
+
+func isKubeVersionMet(constraint string, facts *ClusterFacts) (bool, string, error) {
+	if strings.TrimSpace(constraint) == "" {
+		return true, "", nil
+	}
+
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return false, "", fmt.Errorf("invalid kubeVersion constraint %v, err: %w", constraint, err)
+	}
+
+	if facts.KubeVersion == nil || !c.Check(facts.KubeVersion) {
+		return false, fmt.Sprintf("Kubernetes version %v doesn't meet %v", facts.KubeVersion, constraint), nil
+	}
+
+	return true, "", nil
+}
+
+func getPackageConditions(appsub *appv1.Subscription, resource *unstructured.Unstructured) []*appv1.PackageCondition {
+	if appsub == nil {
+		return nil
+	}
+
+	conds := []*appv1.PackageCondition{}
+
+	for _, ov := range appsub.Spec.PackageOverrides {
+		if ov == nil || ov.Condition == nil || !IsPackageResource(resource, ov.PackageName) {
+			continue
+		}
+
+		conds = append(conds, ov.Condition)
+	}
+
+	return conds
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestNewClusterFacts(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	facts, err := NewClusterFacts(nil, "v1.21.5-gke.1302+build")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(facts.KubeVersion.String()).To(gomega.Equal("1.21.5"))
+	g.Expect(facts.Labels).NotTo(gomega.BeNil())
+
+	_, err = NewClusterFacts(nil, "unknown")
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestIsPackageConditionMet(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	facts, err := NewClusterFacts(map[string]string{"gpu": "true", "platform.open-cluster-management.io": "AWS"}, "v1.22.3")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	appsub := &appv1.Subscription{}
+
+	cm := &unstructured.Unstructured{}
+	cm.SetKind("ConfigMap")
+	cm.SetName("settings")
+
+	g.Expect(HasPackageCondition(appsub, cm)).To(gomega.BeFalse())
+
+	// conditions from the resource annotations
+	cm.SetAnnotations(map[string]string{
+		appv1.AnnotationClusterSelector: "gpu=true",
+		appv1.AnnotationKubeVersion:     ">= 1.21",
+	})
+
+	g.Expect(HasPackageCondition(appsub, cm)).To(gomega.BeTrue())
+
+	met, _, err := IsPackageConditionMet(appsub, cm, facts)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(met).To(gomega.BeTrue())
+
+	cm.SetAnnotations(map[string]string{appv1.AnnotationKubeVersion: "< 1.22"})
+
+	met, reason, err := IsPackageConditionMet(appsub, cm, facts)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(met).To(gomega.BeFalse())
+	g.Expect(reason).To(gomega.ContainSubstring("1.22.3"))
+
+	cm.SetAnnotations(map[string]string{appv1.AnnotationClusterSelector: "gpu in (true,"})
+
+	_, _, err = IsPackageConditionMet(appsub, cm, facts)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	// conditions from the packageOverrides
+	cm.SetAnnotations(nil)
+
+	appsub.Spec.PackageOverrides = []*appv1.Overrides{
+		{
+			PackageName: "settings",
+			Condition: &appv1.PackageCondition{
+				ClusterSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"platform.open-cluster-management.io": "Azure"},
+				},
+			},
+		},
+	}
+
+	g.Expect(HasPackageCondition(appsub, cm)).To(gomega.BeTrue())
+
+	met, _, err = IsPackageConditionMet(appsub, cm, facts)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(met).To(gomega.BeFalse())
+
+	appsub.Spec.PackageOverrides[0].PackageName = "other"
+
+	g.Expect(HasPackageCondition(appsub, cm)).To(gomega.BeFalse())
+}
+
+func TestSubstituteClusterVariables(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	facts, err := NewClusterFacts(map[string]string{
+		"platform.open-cluster-management.io": "AWS",
+		"region.open-cluster-management.io":   "us-east-1",
+	}, "v1.23.3")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	vars := GetClusterVariables(facts)
+	g.Expect(vars).To(gomega.HaveKeyWithValue("CLUSTER_PLATFORM", "AWS"))
+	g.Expect(vars).To(gomega.HaveKeyWithValue("CLUSTER_REGION", "us-east-1"))
+	g.Expect(vars).To(gomega.HaveKeyWithValue("CLUSTER_VERSION", "1.23.3"))
+	g.Expect(vars).To(gomega.HaveKeyWithValue("CLUSTER_CLAIM_PLATFORM_OPEN_CLUSTER_MANAGEMENT_IO", "AWS"))
+	g.Expect(vars).NotTo(gomega.HaveKey("CLUSTER_PRODUCT"))
+
+	release := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps.open-cluster-management.io/v1",
+		"kind":       "HelmRelease",
+		"metadata":   map[string]interface{}{"name": "ingress-${CLUSTER_PLATFORM}"},
+		"spec": map[string]interface{}{
+			"cloud":    map[string]interface{}{"provider": "${CLUSTER_PLATFORM}", "region": "${CLUSTER_REGION}"},
+			"zones":    []interface{}{"${CLUSTER_REGION}a", "${CLUSTER_REGION}b"},
+			"product":  "${CLUSTER_PRODUCT}",
+			"replicas": 2,
+		},
+	}}
+
+	substituted := SubstituteClusterVariables(release, vars)
+	g.Expect(substituted.GetName()).To(gomega.Equal("ingress-AWS"))
+	g.Expect(substituted.Object["spec"]).To(gomega.Equal(map[string]interface{}{
+		"cloud":    map[string]interface{}{"provider": "AWS", "region": "us-east-1"},
+		"zones":    []interface{}{"us-east-1a", "us-east-1b"},
+		"product":  "${CLUSTER_PRODUCT}",
+		"replicas": 2,
+	}))
+
+	// the original resource is left as it is
+	g.Expect(release.GetName()).To(gomega.Equal("ingress-${CLUSTER_PLATFORM}"))
+
+	sub := &appv1.Subscription{}
+	g.Expect(HasClusterVariables(sub)).To(gomega.BeFalse())
+
+	sub.SetAnnotations(map[string]string{appv1.AnnotationClusterVariables: "true"})
+	g.Expect(HasClusterVariables(sub)).To(gomega.BeTrue())
+}
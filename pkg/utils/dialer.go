@@ -0,0 +1,124 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultDNSPort = "53"
+	// fallbackDelay is how long the dialer waits for the IPv6 attempt before racing IPv4 (RFC 6555)
+	fallbackDelay = 300 * time.Millisecond
+)
+
+// fetchDNSResolver is the host:port of the DNS server used by the channel fetchers, empty for the system resolver
+var fetchDNSResolver string
+
+// SetFetchDNSResolver sets the DNS server the git, helm repo and object store fetchers resolve names with.
+// The address can be an IPv4 or IPv6 literal with an optional port, e.g. 10.0.0.10, [fd00::10]:53 or fd00::10
+func SetFetchDNSResolver(addr string) {
+	fetchDNSResolver = normalizeDNSResolver(addr)
+
+	if fetchDNSResolver != "" {
+		klog.Infof("channel fetchers resolve names with DNS server %v", fetchDNSResolver)
+	}
+}
+
+// IsFetchDNSResolverSet returns true if the fetchers resolve names with a custom DNS server
+func IsFetchDNSResolverSet() bool {
+	return fetchDNSResolver != ""
+}
+
+func normalizeDNSResolver(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return ""
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+
+	// bare address, either an IPv6 literal with or without brackets, or an IPv4 address/hostname
+	return net.JoinHostPort(strings.Trim(addr, "[]"), defaultDNSPort)
+}
+
+// NewFetchDialer returns the dialer used by the channel fetchers. It races IPv6 and IPv4 addresses on
+// dual-stack hosts and resolves names with the configured DNS server, if any.
+func NewFetchDialer() *net.Dialer {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	ConfigureFetchDialer(dialer)
+
+	return dialer
+}
+
+// ConfigureFetchDialer applies the fetch dialer settings to a dialer built by a third party client
+func ConfigureFetchDialer(dialer *net.Dialer) {
+	dialer.FallbackDelay = fallbackDelay
+
+	if fetchDNSResolver == "" {
+		return
+	}
+
+	resolver := fetchDNSResolver
+
+	dialer.Resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: 5 * time.Second}
+
+			return d.DialContext(ctx, network, resolver)
+		},
+	}
+}
+
+// ParseSSHHost returns the host and port of an ssh git URL. It supports the ssh://user@host:port/path form and the
+// scp-like user@host:path form, with IPv6 literals in brackets, e.g. git@[fd00::1]:org/repo.git
+func ParseSSHHost(sshURL string) (string, string) {
+	if strings.HasPrefix(sshURL, "ssh:") {
+		u, err := url.Parse(sshURL)
+		if err != nil {
+			klog.Error("failed to parse SSH URL: ", err)
+
+			return "", ""
+		}
+
+		return u.Hostname(), u.Port()
+	}
+
+	hostpath := sshURL
+	if i := strings.Index(hostpath, "@"); i >= 0 {
+		hostpath = hostpath[i+1:]
+	}
+
+	if strings.HasPrefix(hostpath, "[") {
+		if end := strings.Index(hostpath, "]"); end > 0 {
+			return hostpath[1:end], ""
+		}
+	}
+
+	return strings.Split(hostpath, ":")[0], ""
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestNormalizeDNSResolver(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(normalizeDNSResolver("")).To(gomega.Equal(""))
+	g.Expect(normalizeDNSResolver("10.0.0.10")).To(gomega.Equal("10.0.0.10:53"))
+	g.Expect(normalizeDNSResolver("10.0.0.10:5353")).To(gomega.Equal("10.0.0.10:5353"))
+	g.Expect(normalizeDNSResolver("fd00::10")).To(gomega.Equal("[fd00::10]:53"))
+	g.Expect(normalizeDNSResolver("[fd00::10]")).To(gomega.Equal("[fd00::10]:53"))
+	g.Expect(normalizeDNSResolver("[fd00::10]:5353")).To(gomega.Equal("[fd00::10]:5353"))
+
+	SetFetchDNSResolver("fd00::10")
+	defer SetFetchDNSResolver("")
+
+	g.Expect(IsFetchDNSResolverSet()).To(gomega.BeTrue())
+	g.Expect(NewFetchDialer().Resolver).NotTo(gomega.BeNil())
+}
+
+func TestParseSSHHost(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	host, port := ParseSSHHost("git@github.com:open-cluster-management/repo.git")
+	g.Expect(host).To(gomega.Equal("github.com"))
+	g.Expect(port).To(gomega.Equal(""))
+
+	host, _ = ParseSSHHost("git@[fd00::1]:open-cluster-management/repo.git")
+	g.Expect(host).To(gomega.Equal("fd00::1"))
+
+	host, port = ParseSSHHost("ssh://git@[fd00::1]:2222/open-cluster-management/repo.git")
+	g.Expect(host).To(gomega.Equal("fd00::1"))
+	g.Expect(port).To(gomega.Equal("2222"))
+
+	host, port = ParseSSHHost("ssh://git@gitlab.example.com/repo.git")
+	g.Expect(host).To(gomega.Equal("gitlab.example.com"))
+	g.Expect(port).To(gomega.Equal(""))
+}
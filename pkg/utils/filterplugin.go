@@ -0,0 +1,375 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/repo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+const (
+	// PackageTypeHelmChart is the type of the Helm chart packages sent to the package filter plugins
+	PackageTypeHelmChart = "HelmChart"
+	// PackageTypeResource is the type of the resource packages of the Git repos sent to the package filter plugins
+	PackageTypeResource = "Resource"
+
+	// PluginFailurePolicyFail fails the sync of the subscription when the package filter plugin can't be called
+	PluginFailurePolicyFail = WebhookFailurePolicyFail
+	// PluginFailurePolicyIgnore deploys the packages when the package filter plugin can't be called
+	PluginFailurePolicyIgnore = WebhookFailurePolicyIgnore
+
+	// maxPackageFilterResponseSize limits the package filter plugin responses
+	maxPackageFilterResponseSize = 4 * 1024 * 1024
+)
+
+// PackageCandidate is the metadata of a package a subscription is about to deploy, the latest version of a Helm chart
+// matching the package filter, or a resource of a Git repo
+type PackageCandidate struct {
+	// Type is HelmChart or Resource
+	Type string `json:"type"`
+	Name string `json:"name"`
+	// Version and Digest are the ones of the Helm chart version
+	Version string `json:"version,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+	// APIVersion, Kind and Namespace are the ones of the resource
+	APIVersion  string            `json:"apiVersion,omitempty"`
+	Kind        string            `json:"kind,omitempty"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// PackageFilterRequest is sent to the package filter plugins with the packages of a subscription sync
+type PackageFilterRequest struct {
+	Subscription types.NamespacedName `json:"subscription"`
+	Channel      string               `json:"channel"`
+	Packages     []PackageCandidate   `json:"packages"`
+}
+
+// PackageFilterDecision is the decision of a package filter plugin on a package, it is skipped unless it is included
+type PackageFilterDecision struct {
+	Include bool   `json:"include"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// PackageFilterResponse is the answer of a package filter plugin, the decisions on the packages of the request in
+// their order
+type PackageFilterResponse struct {
+	Decisions []PackageFilterDecision `json:"decisions"`
+}
+
+// PackageFilterPlugin decides if the packages passing the package filter of a subscription are deployed, e.g. to
+// implement the promotion rules of an organization. It returns a decision per package of the request, in their
+// order. An error fails the sync of the subscription.
+type PackageFilterPlugin interface {
+	Name() string
+	Filter(req *PackageFilterRequest) ([]PackageFilterDecision, error)
+}
+
+// packageFilterPlugins are called in order, the first one excluding a package skips it
+var packageFilterPlugins []PackageFilterPlugin
+
+// AddPackageFilterPlugin adds a plugin filtering the packages of the subscriptions after their package filter
+func AddPackageFilterPlugin(plugin PackageFilterPlugin) {
+	klog.Infof("Package filter plugin %v added", plugin.Name())
+
+	packageFilterPlugins = append(packageFilterPlugins, plugin)
+}
+
+// HasPackageFilterPlugins returns true if the packages of the subscriptions are filtered by plugins
+func HasPackageFilterPlugins() bool {
+	return len(packageFilterPlugins) > 0
+}
+
+// CheckPackageFilterPlugins asks the package filter plugins which packages of the subscription are deployed, each
+// plugin is called once with the packages the previous ones included. It returns the reason of the plugin excluding
+// each package, empty for the included ones, or the error of the plugin that failed.
+func CheckPackageFilterPlugins(sub *appv1.Subscription, candidates []PackageCandidate) ([]string, error) {
+	reasons := make([]string, len(candidates))
+
+	for _, plugin := range packageFilterPlugins {
+		req := &PackageFilterRequest{
+			Subscription: types.NamespacedName{Namespace: sub.Namespace, Name: sub.Name},
+			Channel:      sub.Spec.Channel,
+			Packages:     []PackageCandidate{},
+		}
+
+		// the index in candidates of each package of the request
+		included := []int{}
+
+		for i, candidate := range candidates {
+			if reasons[i] == "" {
+				req.Packages = append(req.Packages, candidate)
+				included = append(included, i)
+			}
+		}
+
+		if len(included) == 0 {
+			break
+		}
+
+		decisions, err := plugin.Filter(req)
+		if err != nil {
+			return nil, fmt.Errorf("package filter plugin %v failed on %d packages: %w", plugin.Name(), len(included), err)
+		}
+
+		for j, decision := range decisions {
+			if !decision.Include {
+				reasons[included[j]] = fmt.Sprintf("excluded by package filter plugin %v: %v", plugin.Name(),
+					decision.Reason)
+			}
+		}
+	}
+
+	return reasons, nil
+}
+
+// NewChartPackageCandidate returns the package candidate of a Helm chart version
+func NewChartPackageCandidate(chartVersion *repo.ChartVersion) PackageCandidate {
+	candidate := PackageCandidate{Type: PackageTypeHelmChart, Digest: chartVersion.Digest}
+
+	if chartVersion.Metadata != nil {
+		candidate.Name = chartVersion.Name
+		candidate.Version = chartVersion.Version
+		candidate.Annotations = chartVersion.Annotations
+	}
+
+	return candidate
+}
+
+// NewResourcePackageCandidate returns the package candidate of a resource
+func NewResourcePackageCandidate(rsc *unstructured.Unstructured) PackageCandidate {
+	return PackageCandidate{
+		Type:        PackageTypeResource,
+		Name:        rsc.GetName(),
+		APIVersion:  rsc.GetAPIVersion(),
+		Kind:        rsc.GetKind(),
+		Namespace:   rsc.GetNamespace(),
+		Labels:      rsc.GetLabels(),
+		Annotations: rsc.GetAnnotations(),
+	}
+}
+
+// filterChartsOnPlugins removes the charts of the index excluded by the package filter plugins
+func filterChartsOnPlugins(sub *appv1.Subscription, indexFile *repo.IndexFile) error {
+	if !HasPackageFilterPlugins() {
+		return nil
+	}
+
+	names := []string{}
+	candidates := []PackageCandidate{}
+
+	for _, name := range SortedChartNames(indexFile) {
+		chartVersions := indexFile.Entries[name]
+		if len(chartVersions) == 0 || chartVersions[0] == nil {
+			continue
+		}
+
+		names = append(names, name)
+		candidates = append(candidates, NewChartPackageCandidate(chartVersions[0]))
+	}
+
+	reasons, err := CheckPackageFilterPlugins(sub, candidates)
+	if err != nil {
+		return err
+	}
+
+	for i, reason := range reasons {
+		if reason != "" {
+			klog.Infof("Skipping chart %v of subscription %v/%v, %v", names[i], sub.Namespace, sub.Name, reason)
+
+			delete(indexFile.Entries, names[i])
+		}
+	}
+
+	return nil
+}
+
+// PackageFilterPluginConfig is an external package filter, a webhook or a command
+type PackageFilterPluginConfig struct {
+	// WebhookConfig is the webhook receiving the POST requests of the packages to filter, or the name, timeout and
+	// failure policy of the command
+	WebhookConfig `json:",inline"`
+	// Command is run for each sync, with the request on its stdin, and writes the decisions to its stdout
+	Command []string `json:"command,omitempty"`
+}
+
+// PackageFilterPluginsConfig is the config file of the package filter plugins, they are called in order
+type PackageFilterPluginsConfig struct {
+	Plugins []PackageFilterPluginConfig `json:"plugins"`
+}
+
+// LoadPackageFilterPlugins reads the YAML or JSON config file of the package filter plugins
+func LoadPackageFilterPlugins(configFile string) ([]PackageFilterPlugin, error) {
+	content, err := ioutil.ReadFile(configFile) // #nosec G304 the config file is an operator flag
+	if err != nil {
+		return nil, err
+	}
+
+	config := &PackageFilterPluginsConfig{}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("failed to parse package filter plugins config %v, err: %w", configFile, err)
+	}
+
+	plugins := []PackageFilterPlugin{}
+
+	for _, pluginConfig := range config.Plugins {
+		plugin, err := NewPackageFilterPlugin(pluginConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		plugins = append(plugins, plugin)
+	}
+
+	return plugins, nil
+}
+
+// NewPackageFilterPlugin validates the config of a package filter plugin and creates its webhook or exec plugin
+func NewPackageFilterPlugin(config PackageFilterPluginConfig) (PackageFilterPlugin, error) {
+	if config.Name == "" || (config.URL == "") == (len(config.Command) == 0) {
+		return nil, fmt.Errorf("package filter plugin %q: a name and either a url or a command are required", config.Name)
+	}
+
+	if err := config.SetDefaults(); err != nil {
+		return nil, fmt.Errorf("package filter plugin %v: %w", config.Name, err)
+	}
+
+	if len(config.Command) > 0 {
+		return &PackageFilterExec{config: config}, nil
+	}
+
+	client, err := NewWebhookClient(config.WebhookConfig, maxPackageFilterResponseSize)
+	if err != nil {
+		return nil, fmt.Errorf("package filter plugin %v: %w", config.Name, err)
+	}
+
+	return &PackageFilterWebhook{config: config, client: client}, nil
+}
+
+// applyFailurePolicy includes the packages if the plugin failed with the Ignore failure policy, and checks the plugin
+// decided on each package otherwise
+func applyFailurePolicy(config PackageFilterPluginConfig, req *PackageFilterRequest,
+	resp *PackageFilterResponse, err error) ([]PackageFilterDecision, error) {
+	if err == nil && len(resp.Decisions) != len(req.Packages) {
+		err = fmt.Errorf("the response has %d decisions for %d packages", len(resp.Decisions), len(req.Packages))
+	}
+
+	if err == nil {
+		return resp.Decisions, nil
+	}
+
+	if config.IgnoresFailures() {
+		klog.Warningf("ignoring the failure of package filter plugin %v for %d packages of subscription %v, err: %v",
+			config.Name, len(req.Packages), req.Subscription, err)
+
+		decisions := make([]PackageFilterDecision, len(req.Packages))
+		for i := range decisions {
+			decisions[i].Include = true
+		}
+
+		return decisions, nil
+	}
+
+	return nil, err
+}
+
+// PackageFilterWebhook is a package filter plugin calling an external HTTP endpoint
+type PackageFilterWebhook struct {
+	config PackageFilterPluginConfig
+	client *WebhookClient
+}
+
+var _ PackageFilterPlugin = &PackageFilterWebhook{}
+
+// Name returns the name of the plugin
+func (w *PackageFilterWebhook) Name() string {
+	return w.config.Name
+}
+
+// Filter sends the packages to the webhook and returns its decisions
+func (w *PackageFilterWebhook) Filter(req *PackageFilterRequest) ([]PackageFilterDecision, error) {
+	resp := &PackageFilterResponse{}
+	err := w.client.Post(req, resp)
+
+	return applyFailurePolicy(w.config, req, resp, err)
+}
+
+// PackageFilterExec is a package filter plugin running a command
+type PackageFilterExec struct {
+	config PackageFilterPluginConfig
+}
+
+var _ PackageFilterPlugin = &PackageFilterExec{}
+
+// Name returns the name of the plugin
+func (e *PackageFilterExec) Name() string {
+	return e.config.Name
+}
+
+// Filter runs the command with the packages on its stdin and returns the decisions of its stdout
+func (e *PackageFilterExec) Filter(req *PackageFilterRequest) ([]PackageFilterDecision, error) {
+	resp, err := e.run(req)
+
+	return applyFailurePolicy(e.config, req, resp, err)
+}
+
+func (e *PackageFilterExec) run(req *PackageFilterRequest) (*PackageFilterResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := e.config.Timeout()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.config.Command[0], e.config.Command[1:]...) // #nosec G204 the command is an operator config
+	cmd.Stdin = bytes.NewReader(body)
+
+	stdout := &limitedBuffer{max: maxPackageFilterResponseSize}
+	stderr := &limitedBuffer{max: maxPackageFilterResponseSize}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("timed out after %v", timeout)
+		}
+
+		return nil, fmt.Errorf("%v: %.256s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	resp := &PackageFilterResponse{}
+	if err := json.Unmarshal(stdout.Bytes(), resp); err != nil {
+		return nil, fmt.Errorf("invalid response, err: %w", err)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestPackageFilterPlugins(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func() { packageFilterPlugins = nil }()
+
+	// the webhook only promotes the chart versions without pre-release
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &PackageFilterRequest{}
+		g.Expect(json.NewDecoder(r.Body).Decode(req)).To(gomega.Succeed())
+		g.Expect(req.Subscription.Name).To(gomega.Equal("sub"))
+		g.Expect(req.Channel).To(gomega.Equal("ns/helm"))
+
+		resp := PackageFilterResponse{}
+		for _, pkg := range req.Packages {
+			resp.Decisions = append(resp.Decisions, PackageFilterDecision{Include: pkg.Version == "1.0.0", Reason: "not promoted"})
+		}
+
+		g.Expect(json.NewEncoder(w).Encode(resp)).To(gomega.Succeed())
+	}))
+	defer server.Close()
+
+	// the command includes all the packages, and records each of its runs
+	dir := t.TempDir()
+	runsFile := filepath.Join(dir, "runs")
+	approved := "n=$(grep -o '\"type\"' | wc -l); echo run >> " + runsFile + "; printf '{\"decisions\": ['; " +
+		"i=0; while [ $i -lt $n ]; do [ $i -gt 0 ] && printf ','; printf '{\"include\": true}'; i=$((i+1)); done; " +
+		"printf ']}'"
+
+	configFile := filepath.Join(dir, "plugins.yaml")
+	config, err := json.Marshal(PackageFilterPluginsConfig{Plugins: []PackageFilterPluginConfig{
+		{WebhookConfig: WebhookConfig{Name: "promotion", URL: server.URL}},
+		{WebhookConfig: WebhookConfig{Name: "approved"}, Command: []string{"sh", "-c", approved}},
+	}})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(ioutil.WriteFile(configFile, config, 0600)).To(gomega.Succeed())
+
+	plugins, err := LoadPackageFilterPlugins(configFile)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(plugins).To(gomega.HaveLen(2))
+
+	for _, plugin := range plugins {
+		AddPackageFilterPlugin(plugin)
+	}
+
+	sub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{Name: "sub", Namespace: "default"},
+		Spec:       appv1.SubscriptionSpec{Channel: "ns/helm"},
+	}
+
+	indexFile := &repo.IndexFile{Entries: map[string]repo.ChartVersions{
+		"promoted": {{Metadata: &chart.Metadata{Name: "promoted", Version: "1.0.0"}}},
+		"released": {{Metadata: &chart.Metadata{Name: "released", Version: "1.0.0"}}},
+		"staged":   {{Metadata: &chart.Metadata{Name: "staged", Version: "1.1.0-rc.1"}}},
+	}}
+
+	g.Expect(FilterCharts(sub, indexFile)).To(gomega.Succeed())
+	g.Expect(indexFile.Entries).To(gomega.HaveKey("promoted"))
+	g.Expect(indexFile.Entries).To(gomega.HaveKey("released"))
+	g.Expect(indexFile.Entries).NotTo(gomega.HaveKey("staged"))
+
+	// the command is run once for the charts the webhook included
+	runs, err := ioutil.ReadFile(runsFile)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(runs)).To(gomega.Equal("run\n"))
+
+	reasons, err := CheckPackageFilterPlugins(sub, []PackageCandidate{
+		{Type: PackageTypeResource, Name: "cm"},
+		{Type: PackageTypeHelmChart, Name: "promoted", Version: "1.0.0"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(reasons).To(gomega.Equal([]string{"excluded by package filter plugin promotion: not promoted", ""}))
+
+	// a failing plugin skips the packages, unless its failure policy is Ignore
+	failing, err := NewPackageFilterPlugin(PackageFilterPluginConfig{WebhookConfig: WebhookConfig{Name: "failing"},
+		Command: []string{"false"}})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	packageFilterPlugins = []PackageFilterPlugin{failing}
+
+	_, err = CheckPackageFilterPlugins(sub, []PackageCandidate{{Type: PackageTypeResource, Name: "cm"}})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	// a plugin must decide on each package
+	partial, err := NewPackageFilterPlugin(PackageFilterPluginConfig{WebhookConfig: WebhookConfig{Name: "partial"},
+		Command: []string{"sh", "-c", "cat > /dev/null; echo '{\"decisions\": []}'"}})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	packageFilterPlugins = []PackageFilterPlugin{partial}
+
+	_, err = CheckPackageFilterPlugins(sub, []PackageCandidate{{Type: PackageTypeResource, Name: "cm"}})
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("0 decisions for 1 packages")))
+
+	ignored, err := NewPackageFilterPlugin(PackageFilterPluginConfig{
+		WebhookConfig: WebhookConfig{Name: "ignored", FailurePolicy: PluginFailurePolicyIgnore},
+		Command:       []string{"false"},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	packageFilterPlugins = []PackageFilterPlugin{ignored}
+
+	reasons, err = CheckPackageFilterPlugins(sub, []PackageCandidate{{Type: PackageTypeResource, Name: "cm"}})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(reasons).To(gomega.Equal([]string{""}))
+
+	// a plugin is either a webhook or a command
+	_, err = NewPackageFilterPlugin(PackageFilterPluginConfig{WebhookConfig: WebhookConfig{Name: "both", URL: server.URL},
+		Command: []string{"true"}})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	_, err = NewPackageFilterPlugin(PackageFilterPluginConfig{
+		WebhookConfig: WebhookConfig{Name: "policy", URL: server.URL, FailurePolicy: "Retry"},
+	})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	_, err = LoadPackageFilterPlugins(filepath.Join(dir, "missing.yaml"))
+	g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+}
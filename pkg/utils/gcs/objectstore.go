@@ -0,0 +1,320 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcs is the Google Cloud Storage handler of the object bucket channels, on the JSON API of Cloud Storage
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"k8s.io/klog/v2"
+
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/utils"
+	awsutils "open-cluster-management.io/multicloud-operators-subscription/pkg/utils/aws"
+)
+
+const (
+	// SecretMapKeyServiceAccountKey is key of the JSON key of the Google service account in secret. The default
+	// credentials, like the workload identity of the pod, are used if the secret has none.
+	SecretMapKeyServiceAccountKey = "ServiceAccountKey"
+	// DefaultEndpoint is the endpoint of Google Cloud Storage.
+	DefaultEndpoint = "https://storage.googleapis.com"
+	// GCSScheme is the scheme of the gs://<bucket> pathnames.
+	GCSScheme = "gs://"
+
+	// metadata key for storing the deployable generatename name.
+	DeployableGenerateNameMeta = "generatename"
+	// metadata key for storing the deployable version.
+	DeployableVersionMeta = "deployableversion"
+
+	storageScope   = "https://www.googleapis.com/auth/devstorage.read_write"
+	requestTimeout = 60 * time.Second
+)
+
+// Handler handles connections to Google Cloud Storage.
+type Handler struct {
+	client    *http.Client
+	endpoint  string
+	projectID string
+}
+
+var _ awsutils.ObjectStore = &Handler{}
+
+// IsGCSPathname tells if the pathname of an object bucket channel is a Google Cloud Storage bucket, a gs://<bucket>
+// or https://storage.googleapis.com/<bucket> URL.
+func IsGCSPathname(pathname string) bool {
+	pathname = strings.ToLower(strings.TrimSpace(pathname))
+
+	if strings.HasPrefix(pathname, GCSScheme) {
+		return true
+	}
+
+	u, err := url.Parse(pathname)
+
+	return err == nil && u.Host == strings.TrimPrefix(DefaultEndpoint, "https://")
+}
+
+// InitObjectStoreConnection connects to Google Cloud Storage at the endpoint, Google Cloud Storage itself if the
+// endpoint is a gs: one, with the JSON key of a service account, or with the default credentials if the key is empty.
+func (h *Handler) InitObjectStoreConnection(endpoint string, serviceAccountKey []byte) error {
+	klog.Infof("Preparing Google Cloud Storage settings endpoint: %v", endpoint)
+
+	if endpoint == "" || strings.HasPrefix(strings.ToLower(endpoint), "gs:") {
+		endpoint = DefaultEndpoint
+	}
+
+	// dial with the fetch dialer for dual-stack support and the custom DNS server, if any
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = utils.NewFetchDialer().DialContext
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport})
+
+	var (
+		creds *google.Credentials
+		err   error
+	)
+
+	if len(serviceAccountKey) > 0 {
+		creds, err = google.CredentialsFromJSON(ctx, serviceAccountKey, storageScope)
+	} else {
+		// the workload identity of the pod, or the credentials of GOOGLE_APPLICATION_CREDENTIALS
+		creds, err = google.FindDefaultCredentials(ctx, storageScope)
+	}
+
+	if err != nil {
+		klog.Error("Failed to load the Google Cloud credentials. error: ", err)
+
+		return err
+	}
+
+	h.client = oauth2.NewClient(ctx, creds.TokenSource)
+	h.client.Timeout = requestTimeout
+	h.endpoint = strings.TrimSuffix(endpoint, "/")
+	h.projectID = creds.ProjectID
+
+	klog.V(1).Info("Google Cloud Storage configured ")
+
+	return nil
+}
+
+// objectURL returns the JSON API URL of the object of the bucket
+func (h *Handler) objectURL(bucket, name string) string {
+	return h.endpoint + "/storage/v1/b/" + url.PathEscape(bucket) + "/o/" + url.PathEscape(name)
+}
+
+// do sends the request and returns the response body, a 404 is returned as an error with the not found status
+func (h *Handler) do(method, reqURL string, body []byte) ([]byte, int, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), requestTimeout)
+	defer cancel()
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reqBody)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, resp.StatusCode, fmt.Errorf("%v %v failed with status %v: %v", method, reqURL, resp.Status,
+			strings.TrimSpace(string(respBody)))
+	}
+
+	return respBody, resp.StatusCode, nil
+}
+
+// Create a bucket.
+func (h *Handler) Create(bucket string) error {
+	if h.projectID == "" {
+		return fmt.Errorf("failed to create bucket %v, the Google Cloud credentials have no project", bucket)
+	}
+
+	body, err := json.Marshal(map[string]string{"name": bucket})
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := h.do(http.MethodPost, h.endpoint+"/storage/v1/b?project="+url.QueryEscape(h.projectID), body); err != nil {
+		klog.Error("Failed to create bucket ", bucket, ". error: ", err)
+
+		return err
+	}
+
+	return nil
+}
+
+// Exists Checks whether a bucket exists and is accessible.
+func (h *Handler) Exists(bucket string) error {
+	if _, _, err := h.do(http.MethodGet, h.endpoint+"/storage/v1/b/"+url.PathEscape(bucket), nil); err != nil {
+		klog.Error("Failed to access bucket ", bucket, ". error: ", err)
+
+		return err
+	}
+
+	return nil
+}
+
+type objectList struct {
+	Items []struct {
+		Name string `json:"name"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// List all objects in bucket.
+func (h *Handler) List(bucket string, folderName *string) ([]string, error) {
+	var keys []string
+
+	query := url.Values{}
+
+	if folderName != nil {
+		query.Set("prefix", *folderName)
+	}
+
+	for {
+		body, _, err := h.do(http.MethodGet, h.endpoint+"/storage/v1/b/"+url.PathEscape(bucket)+"/o?"+query.Encode(), nil)
+		if err != nil {
+			klog.Infof("Got error retrieving list of objects. err: %v", err)
+
+			return keys, err
+		}
+
+		list := &objectList{}
+		if err := json.Unmarshal(body, list); err != nil {
+			return keys, err
+		}
+
+		for _, item := range list.Items {
+			if len(item.Name) > 0 && !strings.HasSuffix(item.Name, "/") {
+				keys = append(keys, item.Name)
+			} else {
+				klog.V(1).Info("Skipping Google Cloud Storage Object: ", item.Name)
+			}
+		}
+
+		if list.NextPageToken == "" {
+			break
+		}
+
+		query.Set("pageToken", list.NextPageToken)
+	}
+
+	klog.Infof("List Google Cloud Storage Objects result, keys: %v", keys)
+
+	return keys, nil
+}
+
+// Get get existing object.
+func (h *Handler) Get(bucket, name string) (awsutils.DeployableObject, error) {
+	dplObj := awsutils.DeployableObject{}
+
+	body, _, err := h.do(http.MethodGet, h.objectURL(bucket, name), nil)
+	if err != nil {
+		klog.Error("Failed to send Get request. error: ", err)
+
+		return dplObj, err
+	}
+
+	object := &struct {
+		Metadata map[string]string `json:"metadata"`
+	}{}
+
+	if err := json.Unmarshal(body, object); err != nil {
+		klog.Error("Failed to parse Get request. error: ", err)
+
+		return dplObj, err
+	}
+
+	content, _, err := h.do(http.MethodGet, h.objectURL(bucket, name)+"?alt=media", nil)
+	if err != nil {
+		klog.Error("Failed to send Get request. error: ", err)
+
+		return dplObj, err
+	}
+
+	if len(content) == 0 {
+		return awsutils.DeployableObject{}, nil
+	}
+
+	dplObj.Name = name
+	dplObj.GenerateName = object.Metadata[DeployableGenerateNameMeta]
+	dplObj.Version = object.Metadata[DeployableVersionMeta]
+	dplObj.Content = content
+
+	klog.V(1).Info("Get Success: \n", string(content))
+
+	return dplObj, nil
+}
+
+// Put create new object.
+func (h *Handler) Put(bucket string, dplObj awsutils.DeployableObject) error {
+	if dplObj.Name == "" && dplObj.GenerateName == "" && len(dplObj.Content) == 0 {
+		klog.V(1).Infof("got an empty deployableObject to put to object store")
+
+		return nil
+	}
+
+	query := url.Values{"uploadType": []string{"media"}, "name": []string{dplObj.Name}}
+
+	_, _, err := h.do(http.MethodPost, h.endpoint+"/upload/storage/v1/b/"+url.PathEscape(bucket)+"/o?"+query.Encode(),
+		dplObj.Content)
+	if err != nil {
+		klog.Error("Failed to send Put request. error: ", err)
+
+		return err
+	}
+
+	klog.V(5).Info("Put Success")
+
+	return nil
+}
+
+// Delete delete existing object.
+func (h *Handler) Delete(bucket, name string) error {
+	_, status, err := h.do(http.MethodDelete, h.objectURL(bucket, name), nil)
+	if err != nil && status != http.StatusNotFound {
+		klog.Error("Failed to send Delete request. error: ", err)
+
+		return err
+	}
+
+	klog.V(1).Info("Delete Success")
+
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/onsi/gomega"
+
+	awsutils "open-cluster-management.io/multicloud-operators-subscription/pkg/utils/aws"
+)
+
+func TestIsGCSPathname(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(IsGCSPathname("gs://app-bucket")).To(gomega.BeTrue())
+	g.Expect(IsGCSPathname("https://storage.googleapis.com/app-bucket")).To(gomega.BeTrue())
+	g.Expect(IsGCSPathname("https://s3.amazonaws.com/app-bucket")).To(gomega.BeFalse())
+	g.Expect(IsGCSPathname("http://minio.example.com:9000/app-bucket")).To(gomega.BeFalse())
+}
+
+func TestHandler(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	// the fake Cloud Storage keeps the objects of the app-bucket bucket, listed one per page
+	var mu sync.Mutex
+
+	objects := map[string][]byte{"folder/": nil}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		path := r.URL.EscapedPath()
+
+		switch {
+		case r.Method == http.MethodGet && path == "/storage/v1/b/app-bucket":
+			_, _ = w.Write([]byte(`{"name": "app-bucket"}`))
+		case r.Method == http.MethodGet && path == "/storage/v1/b/app-bucket/o":
+			names := []string{}
+
+			for name := range objects {
+				if strings.HasPrefix(name, r.URL.Query().Get("prefix")) && name > r.URL.Query().Get("pageToken") {
+					names = append(names, name)
+				}
+			}
+
+			list := map[string]interface{}{}
+
+			if len(names) > 0 {
+				first := names[0]
+				for _, name := range names {
+					if name < first {
+						first = name
+					}
+				}
+
+				list["items"] = []map[string]string{{"name": first}}
+				list["nextPageToken"] = first
+			}
+
+			_ = json.NewEncoder(w).Encode(list)
+		case r.Method == http.MethodPost && path == "/upload/storage/v1/b/app-bucket/o":
+			g.Expect(r.URL.Query().Get("uploadType")).To(gomega.Equal("media"))
+
+			content, err := ioutil.ReadAll(r.Body)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			objects[r.URL.Query().Get("name")] = content
+		case strings.HasPrefix(path, "/storage/v1/b/app-bucket/o/"):
+			name := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/app-bucket/o/")
+
+			content, ok := objects[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+
+				return
+			}
+
+			switch {
+			case r.Method == http.MethodDelete:
+				delete(objects, name)
+			case r.URL.Query().Get("alt") == "media":
+				_, _ = w.Write(content)
+			default:
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{
+					"name":     name,
+					"metadata": map[string]string{DeployableGenerateNameMeta: "app-", DeployableVersionMeta: "v1"},
+				})
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	h := &Handler{client: server.Client(), endpoint: server.URL}
+
+	g.Expect(h.Exists("app-bucket")).To(gomega.Succeed())
+	g.Expect(h.Exists("other-bucket")).NotTo(gomega.Succeed())
+
+	// buckets are created in the project of the credentials
+	g.Expect(h.Create("other-bucket")).NotTo(gomega.Succeed())
+
+	for _, name := range []string{"folder/configmap.yaml", "folder/secret.yaml", "deployment.yaml"} {
+		g.Expect(h.Put("app-bucket", awsutils.DeployableObject{Name: name, Content: []byte("kind: " + name)})).
+			To(gomega.Succeed())
+	}
+
+	keys, err := h.List("app-bucket", nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(keys).To(gomega.Equal([]string{"deployment.yaml", "folder/configmap.yaml", "folder/secret.yaml"}))
+
+	folder := "folder/"
+	keys, err = h.List("app-bucket", &folder)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(keys).To(gomega.Equal([]string{"folder/configmap.yaml", "folder/secret.yaml"}))
+
+	dplObj, err := h.Get("app-bucket", "folder/configmap.yaml")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(dplObj).To(gomega.Equal(awsutils.DeployableObject{
+		Name:         "folder/configmap.yaml",
+		GenerateName: "app-",
+		Version:      "v1",
+		Content:      []byte("kind: folder/configmap.yaml"),
+	}))
+
+	g.Expect(h.Delete("app-bucket", "folder/configmap.yaml")).To(gomega.Succeed())
+	g.Expect(h.Delete("app-bucket", "folder/configmap.yaml")).To(gomega.Succeed())
+
+	_, err = h.Get("app-bucket", "folder/configmap.yaml")
+	g.Expect(err).To(gomega.HaveOccurred())
+}
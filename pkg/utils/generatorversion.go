@@ -0,0 +1,102 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// GeneratorVersion is the version of the naming and annotation conventions of the generated objects. It is bumped
+// with a new generatedObjectMigration whenever the conventions change, so the objects of the previous operator are
+// migrated in place at startup rather than recreated by the next syncs.
+const GeneratorVersion = 1
+
+// generatedObjectMigration moves a generated object from the previous generator version to its version
+type generatedObjectMigration struct {
+	version int
+	migrate func(obj metav1.Object)
+}
+
+var generatedObjectMigrations = []generatedObjectMigration{
+	{
+		// the objects generated before the conventions were versioned may still have the legacy hosting deployable
+		version: 1,
+		migrate: func(obj metav1.Object) {
+			annotations := obj.GetAnnotations()
+			delete(annotations, appv1.AnnotationHostingDeployable)
+			obj.SetAnnotations(annotations)
+		},
+	},
+}
+
+// GetGeneratorVersion returns the generator version of the object, 0 if it was generated before the conventions
+// were versioned
+func GetGeneratorVersion(obj metav1.Object) int {
+	value, ok := obj.GetAnnotations()[appv1.AnnotationGeneratorVersion]
+	if !ok {
+		return 0
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 0 {
+		klog.Warningf("invalid %v annotation %q of %v/%v, it is migrated", appv1.AnnotationGeneratorVersion, value,
+			obj.GetNamespace(), obj.GetName())
+
+		return 0
+	}
+
+	return version
+}
+
+// SetGeneratorVersion stamps the object with the generator version of the operator
+func SetGeneratorVersion(obj metav1.Object) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[appv1.AnnotationGeneratorVersion] = strconv.Itoa(GeneratorVersion)
+	obj.SetAnnotations(annotations)
+}
+
+// IsGeneratedByNewerOperator tells if the object was generated by a newer operator, during a rolling upgrade or a
+// rollback. The conventions of a newer operator are unknown, such objects are left as they are.
+func IsGeneratedByNewerOperator(obj metav1.Object) bool {
+	return GetGeneratorVersion(obj) > GeneratorVersion
+}
+
+// MigrateGeneratedObject applies the migrations of the generator versions newer than the object's and stamps it with
+// the generator version of the operator. It returns false if the object is already current or is newer.
+func MigrateGeneratedObject(obj metav1.Object) bool {
+	version := GetGeneratorVersion(obj)
+	if version >= GeneratorVersion {
+		return false
+	}
+
+	for _, migration := range generatedObjectMigrations {
+		if migration.version > version && migration.version <= GeneratorVersion {
+			migration.migrate(obj)
+		}
+	}
+
+	SetGeneratorVersion(obj)
+
+	return true
+}
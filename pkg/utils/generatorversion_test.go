@@ -0,0 +1,62 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestMigrateGeneratedObject(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &unstructured.Unstructured{}
+	hr.SetAPIVersion("apps.open-cluster-management.io/v1")
+	hr.SetKind("HelmRelease")
+	hr.SetName("nginx-ingress-1c2a3")
+	hr.SetNamespace("default")
+	hr.SetAnnotations(map[string]string{
+		appv1.AnnotationHosting:           "default/nginx-sub",
+		appv1.AnnotationHostingDeployable: "default/nginx-deployable",
+	})
+
+	// generated before the conventions were versioned
+	g.Expect(GetGeneratorVersion(hr)).To(gomega.Equal(0))
+	g.Expect(IsGeneratedByNewerOperator(hr)).To(gomega.BeFalse())
+
+	g.Expect(MigrateGeneratedObject(hr)).To(gomega.BeTrue())
+	g.Expect(GetGeneratorVersion(hr)).To(gomega.Equal(GeneratorVersion))
+	g.Expect(hr.GetAnnotations()).To(gomega.Equal(map[string]string{
+		appv1.AnnotationHosting:          "default/nginx-sub",
+		appv1.AnnotationGeneratorVersion: strconv.Itoa(GeneratorVersion),
+	}))
+
+	// already current
+	g.Expect(MigrateGeneratedObject(hr)).To(gomega.BeFalse())
+
+	// generated by a newer operator, left as it is
+	hr.SetAnnotations(map[string]string{appv1.AnnotationGeneratorVersion: strconv.Itoa(GeneratorVersion + 1)})
+	g.Expect(IsGeneratedByNewerOperator(hr)).To(gomega.BeTrue())
+	g.Expect(MigrateGeneratedObject(hr)).To(gomega.BeFalse())
+	g.Expect(GetGeneratorVersion(hr)).To(gomega.Equal(GeneratorVersion + 1))
+
+	hr.SetAnnotations(map[string]string{appv1.AnnotationGeneratorVersion: "latest"})
+	g.Expect(GetGeneratorVersion(hr)).To(gomega.Equal(0))
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// ReasonAuthFailed prefixes the subscription status reason when the Git server rejects the channel credentials, or
+// the channel secret holds invalid ones
+const ReasonAuthFailed = "AuthFailed"
+
+// gitAuthFailures are the messages of the errors of rejected or invalid credentials. The clone errors only keep the
+// message of the go-git errors.
+var gitAuthFailures = []string{
+	transport.ErrAuthenticationRequired.Error(),
+	transport.ErrAuthorizationFailed.Error(),
+	transport.ErrInvalidAuthMethod.Error(),
+	"ssh: unable to authenticate",
+	"in the channel secret",
+}
+
+// IsGitAuthFailed returns true if the Git error is a credential failure
+func IsGitAuthFailed(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	msg := err.Error()
+
+	for _, failure := range gitAuthFailures {
+		if strings.Contains(msg, failure) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UpdateAuthFailedStatus sets the subscription failed with the credential failure of its channel, or clears a
+// previous failure if failure is empty.
+func UpdateAuthFailedStatus(clt client.Client, instance *appv1.Subscription, failure string) {
+	UpdateFailureReasonStatus(clt, instance, ReasonAuthFailed, failure)
+}
+
+// InvalidateGitCredentials drops the credentials cached for the channel secret, the next clone gets new ones from
+// the secret
+func InvalidateGitCredentials(secret *corev1.Secret) {
+	if secret == nil || !isGitHubAppSecret(secret) {
+		return
+	}
+
+	app, err := parseGitHubApp(secret)
+	if err != nil {
+		return
+	}
+
+	gitHubAppTokensMtx.Lock()
+	defer gitHubAppTokensMtx.Unlock()
+
+	if _, ok := gitHubAppTokens[app]; ok {
+		klog.Infof("dropped the installation token of GitHub App %v installation %v", app.appID, app.installationID)
+
+		delete(gitHubAppTokens, app)
+	}
+}
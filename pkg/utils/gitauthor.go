@@ -0,0 +1,124 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// ReasonPolicyViolation prefixes the subscription status reason when the commit author is not allowed
+const ReasonPolicyViolation = "PolicyViolation"
+
+// GitCommitAuthor is the author of a git commit
+type GitCommitAuthor struct {
+	Name  string
+	Email string
+}
+
+func (a *GitCommitAuthor) String() string {
+	return fmt.Sprintf("%s <%s>", a.Name, a.Email)
+}
+
+// GetGitCommitAuthor returns the author of the commit in the cloned repository
+func GetGitCommitAuthor(repoRoot, commitID string) (*GitCommitAuthor, error) {
+	repo, err := git.PlainOpen(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := repo.CommitObject(plumbing.NewHash(commitID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &GitCommitAuthor{Name: commit.Author.Name, Email: commit.Author.Email}, nil
+}
+
+// GetAllowedGitAuthors returns the lists of allowed commit authors of the subscription and channel annotations, the
+// ones that are set
+func GetAllowedGitAuthors(sub *appv1.Subscription, chn *chnv1.Channel) [][]string {
+	allowed := [][]string{}
+
+	lists := []string{sub.GetAnnotations()[appv1.AnnotationGitAllowedAuthors]}
+	if chn != nil {
+		lists = append(lists, chn.GetAnnotations()[appv1.AnnotationGitAllowedAuthors])
+	}
+
+	for _, list := range lists {
+		authors := []string{}
+
+		for _, author := range strings.Split(list, ",") {
+			if author = strings.TrimSpace(author); author != "" {
+				authors = append(authors, author)
+			}
+		}
+
+		if len(authors) > 0 {
+			allowed = append(allowed, authors)
+		}
+	}
+
+	return allowed
+}
+
+// IsGitAuthorAllowed checks the author is in every allowed list, the subscription list can only narrow down the
+// channel list.
+func IsGitAuthorAllowed(author *GitCommitAuthor, allowed [][]string) bool {
+	for _, list := range allowed {
+		if !isGitAuthorInList(author, list) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isGitAuthorInList checks the author against an allowed list. An entry starting with @ matches the email domain,
+// other entries match the author name or email.
+func isGitAuthorInList(author *GitCommitAuthor, allowed []string) bool {
+	if author == nil {
+		return false
+	}
+
+	for _, entry := range allowed {
+		if strings.HasPrefix(entry, "@") {
+			if strings.HasSuffix(strings.ToLower(author.Email), strings.ToLower(entry)) {
+				return true
+			}
+
+			continue
+		}
+
+		if entry == author.Name || strings.EqualFold(entry, author.Email) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UpdatePolicyViolationStatus sets the subscription failed with the policy violation, or clears a previous
+// policy violation if violation is empty.
+func UpdatePolicyViolationStatus(clt client.Client, instance *appv1.Subscription, violation string) {
+	UpdateFailureReasonStatus(clt, instance, ReasonPolicyViolation, violation)
+}
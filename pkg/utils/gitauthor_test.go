@@ -0,0 +1,96 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestGitAllowedAuthors(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	sub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{appv1.AnnotationGitAllowedAuthors: "release-bot, @example.com"},
+		},
+	}
+	chn := &chnv1.Channel{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{appv1.AnnotationGitAllowedAuthors: "admin@corp.io"},
+		},
+	}
+
+	allowed := GetAllowedGitAuthors(sub, nil)
+	g.Expect(allowed).To(gomega.Equal([][]string{{"release-bot", "@example.com"}}))
+
+	g.Expect(IsGitAuthorAllowed(&GitCommitAuthor{Name: "Jane", Email: "jane@Example.com"}, allowed)).To(gomega.BeTrue())
+	g.Expect(IsGitAuthorAllowed(&GitCommitAuthor{Name: "release-bot", Email: "bot@ci.io"}, allowed)).To(gomega.BeTrue())
+	g.Expect(IsGitAuthorAllowed(&GitCommitAuthor{Name: "Admin", Email: "ADMIN@corp.io"}, allowed)).To(gomega.BeFalse())
+	g.Expect(IsGitAuthorAllowed(&GitCommitAuthor{Name: "Joe", Email: "joe@example.com.evil.io"}, allowed)).To(gomega.BeFalse())
+	g.Expect(IsGitAuthorAllowed(nil, allowed)).To(gomega.BeFalse())
+
+	// both lists are set, the author must be allowed by both
+	chn.Annotations[appv1.AnnotationGitAllowedAuthors] = "admin@corp.io, @example.com"
+	allowed = GetAllowedGitAuthors(sub, chn)
+	g.Expect(allowed).To(gomega.Equal([][]string{{"release-bot", "@example.com"}, {"admin@corp.io", "@example.com"}}))
+
+	g.Expect(IsGitAuthorAllowed(&GitCommitAuthor{Name: "Jane", Email: "jane@example.com"}, allowed)).To(gomega.BeTrue())
+	g.Expect(IsGitAuthorAllowed(&GitCommitAuthor{Name: "release-bot", Email: "bot@ci.io"}, allowed)).To(gomega.BeFalse())
+	g.Expect(IsGitAuthorAllowed(&GitCommitAuthor{Name: "Admin", Email: "admin@corp.io"}, allowed)).To(gomega.BeFalse())
+
+	// no policy, every author is allowed
+	g.Expect(IsGitAuthorAllowed(nil, GetAllowedGitAuthors(&appv1.Subscription{}, nil))).To(gomega.BeTrue())
+}
+
+func TestGetGitCommitAuthor(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "gitauthor")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	repo, err := git.PlainInit(dir, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	g.Expect(ioutil.WriteFile(filepath.Join(dir, "cm.yaml"), []byte("kind: ConfigMap"), 0600)).To(gomega.Succeed())
+
+	wt, err := repo.Worktree()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, err = wt.Add("cm.yaml")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	hash, err := wt.Commit("add configmap", &git.CommitOptions{
+		Author: &object.Signature{Name: "Jane", Email: "jane@example.com", When: time.Now()},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	author, err := GetGitCommitAuthor(dir, hash.String())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(author).To(gomega.Equal(&GitCommitAuthor{Name: "Jane", Email: "jane@example.com"}))
+}
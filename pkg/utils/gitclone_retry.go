@@ -0,0 +1,267 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"k8s.io/klog/v2"
+)
+
+// cloneAdjustment is the change of the clone options that made a repository clonable
+type cloneAdjustment struct {
+	fullDepth    bool
+	noSubmodules bool
+}
+
+var (
+	cloneAdjustments    = map[string]cloneAdjustment{}
+	cloneAdjustmentsMtx sync.Mutex
+)
+
+// some git servers don't support shallow clones, these are the errors they fail the shallow fetch with
+var shallowCloneErrors = []string{
+	"shallow",
+	"deepen",
+	transport.ErrEmptyUploadPackRequest.Error(),
+	plumbing.ErrObjectNotFound.Error(),
+}
+
+// the errors of a clone into a workspace corrupted by an earlier clone, lowercase. A bare unexpected EOF is left out,
+// it is mostly a connection dropped by the server, not a corrupted workspace.
+var corruptCloneErrors = []string{
+	"index.lock",
+	".lock': file exists",
+	"malformed pack file",
+	"zlib: invalid",
+	"invalid checksum",
+}
+
+// plainClone clones the repository into destDir. If the server rejects the shallow clone or a submodule fails, the
+// clone is retried with the full depth or without the submodules, and the working options are remembered for the
+// repository URL, so the next clones start with them. A workspace left corrupted by an earlier clone is wiped and
+// the clone retried once. The clone is aborted when ctx is done, or when it exceeds the repository size limit.
+func plainClone(ctx context.Context, destDir string, options *git.CloneOptions) (*git.Repository, error) {
+	adjustment := getCloneAdjustment(options.URL)
+
+	// e.g. the partial clone of the primary channel before the secondary channel is tried
+	if err := wipeLeftoverClone(destDir); err != nil {
+		return nil, err
+	}
+
+	wiped := false
+
+	for {
+		adjusted := *options
+
+		if adjustment.fullDepth {
+			adjusted.Depth = 0
+		}
+
+		if adjustment.noSubmodules {
+			adjusted.RecurseSubmodules = git.NoRecurseSubmodules
+		}
+
+		repo, err := cloneWithinSizeLimit(ctx, destDir, &adjusted)
+		if err == nil {
+			setCloneAdjustment(options.URL, adjustment)
+
+			return repo, nil
+		}
+
+		// the submodules are not dropped when they are not allowed, the clone fails
+		if errors.Is(err, errSourceNotAllowed) {
+			return nil, err
+		}
+
+		// free the disk space taken by the oversized clone
+		if IsRepoLimitExceeded(err) {
+			if cleanErr := cleanCloneDir(destDir); cleanErr != nil {
+				klog.Warningf("Failed to clean the clone workspace %v, err: %v", destDir, cleanErr)
+			}
+
+			return nil, err
+		}
+
+		if !wiped && ctx.Err() == nil && isCorruptCloneError(err) {
+			klog.Warningf("Failed to clone %v into a corrupted workspace, wiping %v and cloning again. err: %v", options.URL, destDir, err)
+
+			if err := cleanCloneDir(destDir); err != nil {
+				return nil, fmt.Errorf("failed to wipe the corrupted clone workspace %v: %w", destDir, err)
+			}
+
+			wiped = true
+
+			continue
+		}
+
+		next := adjustment
+
+		if adjusted.Depth > 0 && isShallowCloneError(err) {
+			next.fullDepth = true
+		}
+
+		if adjusted.RecurseSubmodules != git.NoRecurseSubmodules && isSubmoduleError(destDir, err) {
+			next.noSubmodules = true
+		}
+
+		if next == adjustment || ctx.Err() != nil {
+			return nil, err
+		}
+
+		klog.Warningf("Failed to clone %v, retrying with full depth: %v, without submodules: %v. err: %v",
+			options.URL, next.fullDepth, next.noSubmodules, err)
+
+		if err := cleanCloneDir(destDir); err != nil {
+			return nil, err
+		}
+
+		adjustment = next
+	}
+}
+
+func isShallowCloneError(err error) bool {
+	msg := strings.ToLower(err.Error())
+
+	for _, e := range shallowCloneErrors {
+		if strings.Contains(msg, strings.ToLower(e)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSubmoduleError checks the clone failed updating the submodules. The submodules are updated last, so if the
+// repository has a HEAD the main repository was cloned.
+func isSubmoduleError(destDir string, err error) bool {
+	if strings.Contains(strings.ToLower(err.Error()), "submodule") {
+		return true
+	}
+
+	repo, openErr := git.PlainOpen(destDir)
+	if openErr != nil {
+		return false
+	}
+
+	_, headErr := repo.Head()
+
+	return headErr == nil
+}
+
+// isCorruptCloneError checks the clone failed on the leftovers of an earlier clone in the workspace, e.g. a
+// .git directory, a lock file of an interrupted clone or a truncated pack file
+func isCorruptCloneError(err error) bool {
+	if errors.Is(err, git.ErrRepositoryAlreadyExists) {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+
+	for _, e := range corruptCloneErrors {
+		if strings.Contains(msg, e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wipeLeftoverClone wipes the files left in destDir by an earlier clone, the SSH known_hosts file is kept
+func wipeLeftoverClone(destDir string) error {
+	files, err := ioutil.ReadDir(destDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, f := range files {
+		if f.Name() == "known_hosts" {
+			continue
+		}
+
+		klog.V(1).Infof("Wiping the files left in the clone workspace %v by an earlier clone", destDir)
+
+		if err := cleanCloneDir(destDir); err != nil {
+			return fmt.Errorf("failed to wipe the clone workspace %v: %w", destDir, err)
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+// cleanCloneDir removes the failed clone from destDir, keeping the SSH known_hosts file
+func cleanCloneDir(destDir string) error {
+	files, err := ioutil.ReadDir(destDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, f := range files {
+		if f.Name() == "known_hosts" {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(destDir, f.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getCloneAdjustment(url string) cloneAdjustment {
+	cloneAdjustmentsMtx.Lock()
+	defer cloneAdjustmentsMtx.Unlock()
+
+	return cloneAdjustments[url]
+}
+
+func setCloneAdjustment(url string, adjustment cloneAdjustment) {
+	cloneAdjustmentsMtx.Lock()
+	defer cloneAdjustmentsMtx.Unlock()
+
+	if adjustment == (cloneAdjustment{}) {
+		delete(cloneAdjustments, url)
+
+		return
+	}
+
+	if cloneAdjustments[url] != adjustment {
+		klog.Infof("Remembering to clone %v with full depth: %v, without submodules: %v",
+			url, adjustment.fullDepth, adjustment.noSubmodules)
+	}
+
+	cloneAdjustments[url] = adjustment
+}
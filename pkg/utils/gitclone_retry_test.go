@@ -0,0 +1,217 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestCloneErrors(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(isShallowCloneError(errors.New("remote: shallow update not allowed"))).To(gomega.BeTrue())
+	g.Expect(isShallowCloneError(plumbing.ErrObjectNotFound)).To(gomega.BeTrue())
+	g.Expect(isShallowCloneError(errors.New("authentication required"))).To(gomega.BeFalse())
+
+	srcDir, err := ioutil.TempDir("", "gitclone-src")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(srcDir)
+
+	repo, err := git.PlainInit(srcDir, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	g.Expect(ioutil.WriteFile(filepath.Join(srcDir, "cm.yaml"), []byte("kind: ConfigMap"), 0600)).To(gomega.Succeed())
+
+	wt, err := repo.Worktree()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, err = wt.Add("cm.yaml")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, err = wt.Commit("add configmap", &git.CommitOptions{
+		Author: &object.Signature{Name: "Jane", Email: "jane@example.com", When: time.Now()},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	destDir, err := ioutil.TempDir("", "gitclone-dest")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(destDir)
+
+	// a submodule failure is told from a failure of the main repository by the cloned HEAD
+	g.Expect(isSubmoduleError(destDir, errors.New("repository not found"))).To(gomega.BeFalse())
+
+	_, err = plainClone(context.TODO(), destDir, &git.CloneOptions{URL: srcDir, Depth: 1, RecurseSubmodules: git.DefaultSubmoduleRecursionDepth})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(getCloneAdjustment(srcDir)).To(gomega.Equal(cloneAdjustment{}))
+
+	g.Expect(isSubmoduleError(destDir, errors.New("repository not found"))).To(gomega.BeTrue())
+
+	g.Expect(ioutil.WriteFile(filepath.Join(destDir, "known_hosts"), []byte(""), 0600)).To(gomega.Succeed())
+
+	g.Expect(cleanCloneDir(destDir)).To(gomega.Succeed())
+
+	files, err := ioutil.ReadDir(destDir)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(files).To(gomega.HaveLen(1))
+	g.Expect(files[0].Name()).To(gomega.Equal("known_hosts"))
+
+	// the working adjustment is remembered per repository
+	setCloneAdjustment(srcDir, cloneAdjustment{fullDepth: true})
+	g.Expect(getCloneAdjustment(srcDir)).To(gomega.Equal(cloneAdjustment{fullDepth: true}))
+
+	_, err = plainClone(context.TODO(), destDir, &git.CloneOptions{URL: srcDir, Depth: 1})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(getCloneAdjustment(srcDir)).To(gomega.Equal(cloneAdjustment{fullDepth: true}))
+}
+
+func TestCorruptCloneWorkspace(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(isCorruptCloneError(git.ErrRepositoryAlreadyExists)).To(gomega.BeTrue())
+	g.Expect(isCorruptCloneError(errors.New("open .git/index.lock: file exists"))).To(gomega.BeTrue())
+	g.Expect(isCorruptCloneError(errors.New("malformed pack file"))).To(gomega.BeTrue())
+	g.Expect(isCorruptCloneError(errors.New("unexpected EOF"))).To(gomega.BeFalse())
+	g.Expect(isCorruptCloneError(errors.New("authentication required"))).To(gomega.BeFalse())
+
+	srcDir, err := ioutil.TempDir("", "gitclone-src")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(srcDir)
+
+	repo, err := git.PlainInit(srcDir, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	g.Expect(ioutil.WriteFile(filepath.Join(srcDir, "cm.yaml"), []byte("kind: ConfigMap"), 0600)).To(gomega.Succeed())
+
+	wt, err := repo.Worktree()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, err = wt.Add("cm.yaml")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, err = wt.Commit("add configmap", &git.CommitOptions{
+		Author: &object.Signature{Name: "Jane", Email: "jane@example.com", When: time.Now()},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	destDir, err := ioutil.TempDir("", "gitclone-dest")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(destDir)
+
+	// the leftovers of an interrupted clone
+	_, err = git.PlainInit(destDir, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(ioutil.WriteFile(filepath.Join(destDir, ".git", "index.lock"), []byte(""), 0600)).To(gomega.Succeed())
+	g.Expect(ioutil.WriteFile(filepath.Join(destDir, "known_hosts"), []byte("github.com ssh-rsa AAAA"), 0600)).To(gomega.Succeed())
+
+	// the SSH known_hosts file written for the clone is kept
+	g.Expect(wipeLeftoverClone(destDir)).To(gomega.Succeed())
+
+	files, err := ioutil.ReadDir(destDir)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(files).To(gomega.HaveLen(1))
+	g.Expect(files[0].Name()).To(gomega.Equal("known_hosts"))
+
+	_, err = git.PlainInit(destDir, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(ioutil.WriteFile(filepath.Join(destDir, ".git", "index.lock"), []byte(""), 0600)).To(gomega.Succeed())
+
+	cloned, err := plainClone(context.TODO(), destDir, &git.CloneOptions{URL: srcDir})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, err = cloned.Head()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	g.Expect(filepath.Join(destDir, ".git", "index.lock")).NotTo(gomega.BeAnExistingFile())
+	g.Expect(filepath.Join(destDir, "cm.yaml")).To(gomega.BeAnExistingFile())
+}
+
+func TestRepoLimits(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer SetRepoLimits(RepoLimits{})
+
+	srcDir, err := ioutil.TempDir("", "gitlimits-src")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(srcDir)
+
+	repo, err := git.PlainInit(srcDir, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	wt, err := repo.Worktree()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	for _, name := range []string{"cm1.yaml", "cm2.yaml", "cm3.yaml"} {
+		manifest := []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name)
+		g.Expect(ioutil.WriteFile(filepath.Join(srcDir, name), manifest, 0600)).To(gomega.Succeed())
+
+		_, err = wt.Add(name)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+	}
+
+	_, err = wt.Commit("add configmaps", &git.CommitOptions{
+		Author: &object.Signature{Name: "Jane", Email: "jane@example.com", When: time.Now()},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	destDir, err := ioutil.TempDir("", "gitlimits-dest")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(destDir)
+
+	// the oversized clone is aborted and wiped
+	SetRepoLimits(RepoLimits{MaxRepoSize: 100})
+
+	_, err = plainClone(context.TODO(), destDir, &git.CloneOptions{URL: srcDir, Depth: 1})
+	g.Expect(IsRepoLimitExceeded(err)).To(gomega.BeTrue())
+
+	files, err := ioutil.ReadDir(destDir)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(files).To(gomega.BeEmpty())
+
+	SetRepoLimits(RepoLimits{MaxRepoSize: 10 << 20, MaxManifests: 3})
+
+	_, err = plainClone(context.TODO(), destDir, &git.CloneOptions{URL: srcDir, Depth: 1})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	_, _, _, _, _, err = SortResources(destDir, destDir)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	SetRepoLimits(RepoLimits{MaxManifests: 2})
+
+	_, _, _, _, _, err = SortResources(destDir, destDir)
+	g.Expect(IsRepoLimitExceeded(err)).To(gomega.BeTrue())
+
+	SetRepoLimits(RepoLimits{MaxFileSize: 10})
+
+	_, _, _, _, _, err = SortResources(destDir, destDir)
+	g.Expect(IsRepoLimitExceeded(err)).To(gomega.BeTrue())
+	g.Expect(err.Error()).To(gomega.ContainSubstring("file size limit"))
+}
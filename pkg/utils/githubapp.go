@@ -0,0 +1,200 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/go-github/v42/github"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// GitHubAppID is the key of the GitHub App ID in the channel secret
+	GitHubAppID = "githubAppID"
+	// GitHubAppInstallationID is the key of the GitHub App installation ID in the channel secret
+	GitHubAppInstallationID = "githubAppInstallationID"
+	// GitHubAppPrivateKey is the key of the GitHub App private key in the channel secret
+	GitHubAppPrivateKey = "githubAppPrivateKey"
+	// GitHubAppAPIURL is the key of the GitHub Enterprise API URL in the channel secret, GitHub.com by default
+	GitHubAppAPIURL = "githubAppAPIURL"
+
+	// gitHubAppTokenUser is the user cloning with an installation token
+	gitHubAppTokenUser = "x-access-token"
+	// the installation tokens expire after an hour, they are renewed once they expire in less than the margin
+	gitHubAppTokenRefreshMargin = 10 * time.Minute
+	gitHubAppJWTLifetime        = 9 * time.Minute
+	gitHubAppRequestTimeout     = 30 * time.Second
+)
+
+// gitHubApp keys the cached installation tokens, the hash of the private key makes sure a token is only returned to
+// the secrets holding the key it was minted with
+type gitHubApp struct {
+	apiURL         string
+	appID          int64
+	installationID int64
+	keyHash        [sha256.Size]byte
+}
+
+type gitHubAppToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+var (
+	gitHubAppTokens    = map[gitHubApp]gitHubAppToken{}
+	gitHubAppTokensMtx sync.Mutex
+)
+
+// isGitHubAppSecret checks the channel secret holds GitHub App credentials
+func isGitHubAppSecret(secret *corev1.Secret) bool {
+	return len(bytes.TrimSpace(secret.Data[GitHubAppID])) > 0
+}
+
+// getGitHubAppToken returns an installation token of the GitHub App of the channel secret. The token is cached until
+// it is about to expire, then a new one is minted. The GitHub API is called with the CA certificates and the proxy of
+// the channel secret and config map, like the Git clones.
+func getGitHubAppToken(secret *corev1.Secret, configmap *corev1.ConfigMap) (string, error) {
+	app, err := parseGitHubApp(secret)
+	if err != nil {
+		return "", err
+	}
+
+	gitHubAppTokensMtx.Lock()
+	cached, ok := gitHubAppTokens[app]
+	gitHubAppTokensMtx.Unlock()
+
+	if ok && time.Until(cached.expiresAt) > gitHubAppTokenRefreshMargin {
+		return cached.token, nil
+	}
+
+	// the lock is not held while calling GitHub, the other channels keep using their cached tokens
+	transport, err := gitProviderAPITransport(ChannelCACerts(secret, configmap), false,
+		GetChannelProxyConfig(secret, configmap))
+	if err != nil {
+		return "", err
+	}
+
+	token, err := createGitHubAppToken(app, bytes.TrimSpace(secret.Data[GitHubAppPrivateKey]), transport)
+	if err != nil {
+		return "", err
+	}
+
+	klog.Infof("minted an installation token of GitHub App %v installation %v, expires at %v",
+		app.appID, app.installationID, token.expiresAt)
+
+	gitHubAppTokensMtx.Lock()
+	gitHubAppTokens[app] = token
+	gitHubAppTokensMtx.Unlock()
+
+	return token.token, nil
+}
+
+func parseGitHubApp(secret *corev1.Secret) (gitHubApp, error) {
+	app := gitHubApp{apiURL: string(bytes.TrimSpace(secret.Data[GitHubAppAPIURL]))}
+
+	// the installation token is minted by the API server, it must be a source the channels can use
+	if app.apiURL != "" {
+		if err := CheckSourceURLAllowed(app.apiURL); err != nil {
+			return app, fmt.Errorf("invalid %s in the channel secret: %w", GitHubAppAPIURL, err)
+		}
+	}
+
+	var err error
+
+	app.appID, err = strconv.ParseInt(string(bytes.TrimSpace(secret.Data[GitHubAppID])), 10, 64)
+	if err != nil {
+		return app, fmt.Errorf("invalid %s in the channel secret: %w", GitHubAppID, err)
+	}
+
+	app.installationID, err = strconv.ParseInt(string(bytes.TrimSpace(secret.Data[GitHubAppInstallationID])), 10, 64)
+	if err != nil {
+		return app, fmt.Errorf("invalid %s in the channel secret: %w", GitHubAppInstallationID, err)
+	}
+
+	privateKey := bytes.TrimSpace(secret.Data[GitHubAppPrivateKey])
+	if len(privateKey) == 0 {
+		return app, fmt.Errorf("%s is required in the channel secret", GitHubAppPrivateKey)
+	}
+
+	app.keyHash = sha256.Sum256(privateKey)
+
+	return app, nil
+}
+
+// createGitHubAppToken authenticates as the GitHub App with a JWT signed by its private key, and creates an
+// installation token. The API requests go through the transport.
+func createGitHubAppToken(app gitHubApp, privateKey []byte, transport http.RoundTripper) (gitHubAppToken, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(privateKey)
+	if err != nil {
+		return gitHubAppToken{}, fmt.Errorf("invalid %s in the channel secret: %w", GitHubAppPrivateKey, err)
+	}
+
+	now := time.Now()
+
+	// issued in the past to allow for clock drift, as recommended by GitHub
+	appJWT, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(gitHubAppJWTLifetime)),
+		Issuer:    strconv.FormatInt(app.appID, 10),
+	}).SignedString(key)
+	if err != nil {
+		return gitHubAppToken{}, err
+	}
+
+	httpClient := &http.Client{Transport: &bearerTransport{token: appJWT, base: transport}}
+
+	gitClt := github.NewClient(httpClient)
+
+	if app.apiURL != "" {
+		gitClt, err = github.NewEnterpriseClient(app.apiURL, app.apiURL, httpClient)
+		if err != nil {
+			return gitHubAppToken{}, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), gitHubAppRequestTimeout)
+	defer cancel()
+
+	token, _, err := gitClt.Apps.CreateInstallationToken(ctx, app.installationID, nil)
+	if err != nil {
+		return gitHubAppToken{}, fmt.Errorf("failed to create an installation token of GitHub App %v installation %v: %w",
+			app.appID, app.installationID, err)
+	}
+
+	return gitHubAppToken{token: token.GetToken(), expiresAt: token.GetExpiresAt()}, nil
+}
+
+// bearerTransport authenticates the requests of the base transport with a bearer token
+type bearerTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+
+	return t.base.RoundTrip(req)
+}
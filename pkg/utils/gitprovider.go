@@ -0,0 +1,315 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// GitProvider is the hosting service of a Git channel
+type GitProvider string
+
+const (
+	// GitProviderGitHub is GitHub.com or GitHub Enterprise Server
+	GitProviderGitHub GitProvider = "github"
+	// GitProviderGitLab is GitLab.com or a self-managed GitLab
+	GitProviderGitLab GitProvider = "gitlab"
+	// GitProviderBitbucketServer is Bitbucket Server or Bitbucket Data Center
+	GitProviderBitbucketServer GitProvider = "bitbucket-server"
+	// GitProviderGeneric is any other Git server, only the Git protocol is used
+	GitProviderGeneric GitProvider = ""
+
+	gitProviderAPITimeout = 15 * time.Second
+)
+
+// ErrUnsupportedGitProvider is returned for the provider API requests to a generic Git server
+var ErrUnsupportedGitProvider = errors.New("the Git provider API is not supported")
+
+// GetChannelGitProvider returns the Git provider of the channel, set by the git-provider annotation or detected from
+// the channel URL host
+func GetChannelGitProvider(chn *chnv1.Channel) GitProvider {
+	if chn == nil {
+		return GitProviderGeneric
+	}
+
+	return GetGitProvider(chn.Spec.Pathname, chn.GetAnnotations()[appv1.AnnotationGitProvider])
+}
+
+// GetGitProvider returns the provider if set, otherwise the provider detected from the host of the repository URL
+func GetGitProvider(repoURL, provider string) GitProvider {
+	switch p := GitProvider(strings.ToLower(strings.TrimSpace(provider))); p {
+	case GitProviderGitHub, GitProviderGitLab, GitProviderBitbucketServer:
+		return p
+	}
+
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return GitProviderGeneric
+	}
+
+	host := strings.ToLower(u.Hostname())
+
+	switch {
+	case host == "github.com" || strings.HasPrefix(host, "github."):
+		return GitProviderGitHub
+	case host == "gitlab.com" || strings.HasPrefix(host, "gitlab."):
+		return GitProviderGitLab
+	case strings.HasPrefix(host, "bitbucket.") && host != "bitbucket.org":
+		return GitProviderBitbucketServer
+	}
+
+	return GitProviderGeneric
+}
+
+// getHTTPAuth returns the clone credentials in the format of the provider. A token without user is accepted by the
+// providers with token only credentials, like the GitLab project tokens or the Bitbucket Server HTTP access tokens.
+func getHTTPAuth(provider GitProvider, user, token string) (transport.AuthMethod, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	if user != "" {
+		return &githttp.BasicAuth{Username: user, Password: token}, nil
+	}
+
+	switch provider {
+	case GitProviderGitHub:
+		return &githttp.BasicAuth{Username: gitHubAppTokenUser, Password: token}, nil
+	case GitProviderGitLab:
+		return &githttp.BasicAuth{Username: "oauth2", Password: token}, nil
+	case GitProviderBitbucketServer:
+		return &githttp.TokenAuth{Token: token}, nil
+	}
+
+	return nil, errors.New("user and accessToken need to be specified in the channel secret of a generic Git server, " +
+		"set the " + appv1.AnnotationGitProvider + " channel annotation to use the accessToken alone")
+}
+
+// GetRemoteBranchCommit returns the head commit of the branch, or of the default branch if empty, from the API of the
+// Git provider, without cloning the repository
+func GetRemoteBranchCommit(connCfg *ChannelConnectionCfg, branch string) (string, error) {
+	u, err := url.Parse(connCfg.RepoURL)
+	if err != nil || !strings.HasPrefix(u.Scheme, "http") {
+		return "", fmt.Errorf("%w for the repository URL %v", ErrUnsupportedGitProvider, connCfg.RepoURL)
+	}
+
+	repoPath := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	branch = strings.TrimPrefix(branch, "refs/heads/")
+	server := u.Scheme + "://" + u.Host
+
+	var (
+		apiURL string
+		header http.Header = http.Header{}
+	)
+
+	switch connCfg.Provider {
+	case GitProviderGitHub:
+		apiURL = server + "/api/v3"
+		if u.Hostname() == "github.com" {
+			apiURL = "https://api.github.com"
+		}
+
+		ref := branch
+		if ref == "" {
+			ref = "HEAD"
+		}
+
+		apiURL += "/repos/" + repoPath + "/commits/" + url.PathEscape(ref)
+
+		header.Set("Accept", "application/vnd.github.sha")
+
+		if connCfg.Password != "" {
+			header.Set("Authorization", "token "+connCfg.Password)
+		}
+	case GitProviderGitLab:
+		query := url.Values{"per_page": []string{"1"}}
+		if branch != "" {
+			query.Set("ref_name", branch)
+		}
+
+		apiURL = server + "/api/v4/projects/" + url.PathEscape(repoPath) + "/repository/commits?" + query.Encode()
+
+		if connCfg.Password != "" {
+			header.Set("PRIVATE-TOKEN", connCfg.Password)
+		}
+	case GitProviderBitbucketServer:
+		parsed := strings.Split(strings.TrimPrefix(repoPath, "scm/"), "/")
+		if len(parsed) != 2 {
+			return "", fmt.Errorf("invalid Bitbucket Server repository URL %v", connCfg.RepoURL)
+		}
+
+		query := url.Values{"limit": []string{"1"}}
+		if branch != "" {
+			query.Set("until", "refs/heads/"+branch)
+		}
+
+		apiURL = server + "/rest/api/1.0/projects/" + parsed[0] + "/repos/" + parsed[1] + "/commits?" + query.Encode()
+
+		if auth, _ := getHTTPAuth(connCfg.Provider, connCfg.User, connCfg.Password); auth != nil {
+			header.Set("Authorization", authHeader(auth))
+		}
+	default:
+		return "", ErrUnsupportedGitProvider
+	}
+
+	body, err := getGitProviderAPI(connCfg, apiURL, header)
+	if err != nil {
+		return "", err
+	}
+
+	return parseRemoteCommit(connCfg.Provider, body)
+}
+
+func authHeader(auth transport.AuthMethod) string {
+	req := &http.Request{Header: http.Header{}}
+
+	switch a := auth.(type) {
+	case *githttp.BasicAuth:
+		req.SetBasicAuth(a.Username, a.Password)
+	case *githttp.TokenAuth:
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+
+	return req.Header.Get("Authorization")
+}
+
+func parseRemoteCommit(provider GitProvider, body []byte) (string, error) {
+	var commitID string
+
+	switch provider {
+	case GitProviderGitHub:
+		commitID = strings.TrimSpace(string(body))
+	case GitProviderGitLab:
+		commits := []struct {
+			ID string `json:"id"`
+		}{}
+
+		if err := json.Unmarshal(body, &commits); err != nil {
+			return "", err
+		}
+
+		if len(commits) > 0 {
+			commitID = commits[0].ID
+		}
+	case GitProviderBitbucketServer:
+		commits := struct {
+			Values []struct {
+				ID string `json:"id"`
+			} `json:"values"`
+		}{}
+
+		if err := json.Unmarshal(body, &commits); err != nil {
+			return "", err
+		}
+
+		if len(commits.Values) > 0 {
+			commitID = commits.Values[0].ID
+		}
+	}
+
+	if commitID == "" {
+		return "", errors.New("no commit found in the Git provider API response")
+	}
+
+	return commitID, nil
+}
+
+// gitProviderAPITransport returns the transport of the Git provider API calls of a channel. Like the Git clones, it
+// trusts the CA certificates of the channel on top of the system ones, goes through the proxy of the channel and
+// resolves the names with the fetch dialer.
+func gitProviderAPITransport(caCerts string, insecureSkipVerify bool, proxy *httpproxy.Config) (*http.Transport, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if insecureSkipVerify {
+		/* #nosec G402 */
+		tlsConfig.InsecureSkipVerify = true
+	} else if caCerts != "" {
+		certPool, _ := x509.SystemCertPool()
+		if certPool == nil {
+			certPool = x509.NewCertPool()
+		}
+
+		for _, cert := range getCertChain(caCerts).Certificate {
+			x509Cert, err := x509.ParseCertificate(cert)
+			if err != nil {
+				return nil, err
+			}
+
+			certPool.AddCert(x509Cert)
+		}
+
+		tlsConfig.RootCAs = certPool
+	}
+
+	return &http.Transport{
+		DialContext:     NewFetchDialer().DialContext,
+		Proxy:           gitProxyFunc(proxy),
+		TLSClientConfig: tlsConfig,
+	}, nil
+}
+
+func getGitProviderAPI(connCfg *ChannelConnectionCfg, apiURL string, header http.Header) ([]byte, error) {
+	transport, err := gitProviderAPITransport(connCfg.CaCerts, connCfg.InsecureSkipVerify, connCfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{
+		Transport: transport,
+		Timeout:   gitProviderAPITimeout,
+	}
+
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = header
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v API request %v failed with status %v", connCfg.Provider, apiURL, resp.Status)
+	}
+
+	return body, nil
+}
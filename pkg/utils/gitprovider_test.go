@@ -0,0 +1,147 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/onsi/gomega"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestGetGitProvider(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(GetGitProvider("https://github.com/org/repo.git", "")).To(gomega.Equal(GitProviderGitHub))
+	g.Expect(GetGitProvider("https://gitlab.example.com/group/repo.git", "")).To(gomega.Equal(GitProviderGitLab))
+	g.Expect(GetGitProvider("https://bitbucket.example.com/scm/proj/repo.git", "")).To(gomega.Equal(GitProviderBitbucketServer))
+	g.Expect(GetGitProvider("https://bitbucket.org/team/repo.git", "")).To(gomega.Equal(GitProviderGeneric))
+	g.Expect(GetGitProvider("https://git.example.com/repo.git", "")).To(gomega.Equal(GitProviderGeneric))
+	g.Expect(GetGitProvider("https://git.example.com/repo.git", "GitLab")).To(gomega.Equal(GitProviderGitLab))
+	g.Expect(GetGitProvider("https://github.com/org/repo.git", "unknown")).To(gomega.Equal(GitProviderGitHub))
+
+	chn := &chnv1.Channel{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{appv1.AnnotationGitProvider: "bitbucket-server"},
+		},
+		Spec: chnv1.ChannelSpec{Pathname: "https://git.example.com/scm/proj/repo.git"},
+	}
+
+	g.Expect(GetChannelGitProvider(chn)).To(gomega.Equal(GitProviderBitbucketServer))
+	g.Expect(GetChannelGitProvider(nil)).To(gomega.Equal(GitProviderGeneric))
+}
+
+func TestGetHTTPAuth(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	auth, err := getHTTPAuth(GitProviderGeneric, "user", "token")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth).To(gomega.Equal(&githttp.BasicAuth{Username: "user", Password: "token"}))
+
+	auth, err = getHTTPAuth(GitProviderGitLab, "", "token")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth).To(gomega.Equal(&githttp.BasicAuth{Username: "oauth2", Password: "token"}))
+
+	auth, err = getHTTPAuth(GitProviderBitbucketServer, "", "token")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth).To(gomega.Equal(&githttp.TokenAuth{Token: "token"}))
+	g.Expect(authHeader(auth)).To(gomega.Equal("Bearer token"))
+
+	_, err = getHTTPAuth(GitProviderGeneric, "", "token")
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	auth, err = getHTTPAuth(GitProviderGeneric, "", "")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(auth).To(gomega.BeNil())
+
+	// a token alone is a valid channel secret
+	user, token, _, _, _, _, err := ParseChannelSecret(&corev1.Secret{Data: map[string][]byte{AccessToken: []byte("token")}}, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(user).To(gomega.BeEmpty())
+	g.Expect(token).To(gomega.Equal("token"))
+}
+
+func TestGetRemoteBranchCommit(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/org/repo/commits/main":
+			if r.Header.Get("Authorization") != "token secret" || r.Header.Get("Accept") != "application/vnd.github.sha" {
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			fmt.Fprint(w, "1111")
+		case "/api/v4/projects/group/sub/repo/repository/commits":
+			if r.Header.Get("PRIVATE-TOKEN") != "secret" || r.URL.Query().Get("ref_name") != "main" {
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			fmt.Fprint(w, `[{"id": "2222"}]`)
+		case "/rest/api/1.0/projects/PROJ/repos/repo/commits":
+			if r.Header.Get("Authorization") != "Bearer secret" || r.URL.Query().Get("until") != "refs/heads/main" {
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			fmt.Fprint(w, `{"values": [{"id": "3333"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	connCfg := &ChannelConnectionCfg{RepoURL: server.URL + "/org/repo.git", Password: "secret", Provider: GitProviderGitHub}
+
+	commitID, err := GetRemoteBranchCommit(connCfg, "refs/heads/main")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(commitID).To(gomega.Equal("1111"))
+
+	connCfg = &ChannelConnectionCfg{RepoURL: server.URL + "/group/sub/repo.git", Password: "secret", Provider: GitProviderGitLab}
+
+	commitID, err = GetRemoteBranchCommit(connCfg, "main")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(commitID).To(gomega.Equal("2222"))
+
+	connCfg = &ChannelConnectionCfg{RepoURL: server.URL + "/scm/PROJ/repo.git", Password: "secret", Provider: GitProviderBitbucketServer}
+
+	commitID, err = GetRemoteBranchCommit(connCfg, "main")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(commitID).To(gomega.Equal("3333"))
+
+	connCfg.Password = "wrong"
+
+	_, err = GetRemoteBranchCommit(connCfg, "main")
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	connCfg.Provider = GitProviderGeneric
+
+	_, err = GetRemoteBranchCommit(connCfg, "main")
+	g.Expect(errors.Is(err, ErrUnsupportedGitProvider)).To(gomega.BeTrue())
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// HTTPProxy is the key of the proxy of the http Git URLs, in the config map or the secret of the channel
+	HTTPProxy = "httpProxy"
+	// HTTPSProxy is the key of the proxy of the https Git URLs, in the config map or the secret of the channel
+	HTTPSProxy = "httpsProxy"
+	// NoProxy is the key of the hosts reached without the proxy, in the config map or the secret of the channel
+	NoProxy = "noProxy"
+)
+
+// gitProxy is the operator-wide proxy of the Git servers, nil to use the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables
+var gitProxy *httpproxy.Config
+
+// SetGitProxy sets the proxy the Git clones and the Git provider API calls go through, unless their channel has its own.
+// The proxy is taken from the environment if all are empty.
+func SetGitProxy(httpProxy, httpsProxy, noProxy string) {
+	gitProxy = nil
+
+	if httpProxy == "" && httpsProxy == "" && noProxy == "" {
+		return
+	}
+
+	klog.Infof("Git proxy: http: %v, https: %v, no proxy: %v", redactProxyURL(httpProxy), redactProxyURL(httpsProxy), noProxy)
+
+	gitProxy = &httpproxy.Config{HTTPProxy: httpProxy, HTTPSProxy: httpsProxy, NoProxy: noProxy}
+}
+
+// GetChannelProxyConfig returns the proxy of the channel from the httpProxy, httpsProxy and noProxy keys of its config
+// map and secret, the secret taking precedence as the proxy URLs may carry credentials. It returns nil if the channel
+// has no proxy.
+func GetChannelProxyConfig(secret *corev1.Secret, configmap *corev1.ConfigMap) *httpproxy.Config {
+	values := map[string]string{}
+
+	for _, key := range []string{HTTPProxy, HTTPSProxy, NoProxy} {
+		if configmap != nil && strings.TrimSpace(configmap.Data[key]) != "" {
+			values[key] = strings.TrimSpace(configmap.Data[key])
+		}
+
+		if secret != nil && strings.TrimSpace(string(secret.Data[key])) != "" {
+			values[key] = strings.TrimSpace(string(secret.Data[key]))
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	return &httpproxy.Config{HTTPProxy: values[HTTPProxy], HTTPSProxy: values[HTTPSProxy], NoProxy: values[NoProxy]}
+}
+
+// gitProxyFunc returns the proxy function of the Git HTTP transports, from the channel proxy, or the operator-wide
+// one, or the environment
+func gitProxyFunc(proxy *httpproxy.Config) func(*http.Request) (*url.URL, error) {
+	if proxy == nil {
+		proxy = gitProxy
+	}
+
+	if proxy == nil {
+		return http.ProxyFromEnvironment
+	}
+
+	proxyFunc := proxy.ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+// redactProxyURL hides the password of the proxy URL in the logs
+func redactProxyURL(proxyURL string) string {
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.User == nil {
+		return proxyURL
+	}
+
+	return u.Redacted()
+}
@@ -25,15 +25,17 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"time"
 
+	"github.com/Masterminds/semver/v3"
 	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/http/httpproxy"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	admissionv1 "k8s.io/api/admissionregistration/v1"
@@ -69,6 +71,9 @@ const (
 	ClientKey = "clientKey"
 	// ClientCert is a client certificate for connecting to a Git server
 	ClientCert = "clientCert"
+	// CACerts is the PEM CA certificate bundle of a Git server with a custom CA, in the secret or the config map of
+	// the channel
+	CACerts = "caCerts"
 
 	Error = " err: "
 )
@@ -86,6 +91,7 @@ type KubeResource struct {
 type GitCloneOption struct {
 	CommitHash                string
 	RevisionTag               string
+	RevisionTagConstraint     string
 	Branch                    plumbing.ReferenceName
 	DestDir                   string
 	CloneDepth                int
@@ -103,9 +109,11 @@ type ChannelConnectionCfg struct {
 	CaCerts            string
 	ClientKey          []byte
 	ClientCert         []byte
+	Provider           GitProvider
+	Proxy              *httpproxy.Config // the proxy of the channel, nil for the operator-wide proxy
 }
 
-// ParseKubeResoures parses a YAML content and returns kube resources in byte array from the file
+// ParseKubeResoures parses a YAML or JSON content and returns kube resources in byte array from the file
 func ParseKubeResoures(file []byte) [][]byte {
 	cond := func(t KubeResource) bool {
 		return t.APIVersion == "" || t.Kind == ""
@@ -114,22 +122,62 @@ func ParseKubeResoures(file []byte) [][]byte {
 	return KubeResourceParser(file, cond)
 }
 
+// ParseKubeResourcesWithErrors parses a YAML content like ParseKubeResoures, it also returns an error listing the
+// YAML documents of the content that can't be unmarshalled
+func ParseKubeResourcesWithErrors(file []byte) ([][]byte, error) {
+	cond := func(t KubeResource) bool {
+		return t.APIVersion == "" || t.Kind == ""
+	}
+
+	ret, errs := kubeResourceParser(file, cond)
+	if len(errs) == 0 {
+		return ret, nil
+	}
+
+	msgs := []string{}
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	return ret, errors.New(strings.Join(msgs, ", "))
+}
+
 type Kube func(KubeResource) bool
 
 func KubeResourceParser(file []byte, cond Kube) [][]byte {
+	ret, _ := kubeResourceParser(file, cond)
+
+	return ret
+}
+
+func kubeResourceParser(file []byte, cond Kube) ([][]byte, []error) {
 	var ret [][]byte
 
-	items := ParseYAML(file)
+	var errs []error
+
+	items := SplitManifests(file)
 
-	for _, i := range items {
+	for n, i := range items {
 		item := []byte(strings.Trim(i, "\t \n"))
 
+		// the aliases are expanded when the document is unmarshalled, check they don't blow it up first
+		if err := checkManifestLimits(item); err != nil {
+			klog.Warning(err, " Manifest document rejected")
+
+			errs = append(errs, fmt.Errorf("document %d: %w", n+1, err))
+
+			continue
+		}
+
 		t := KubeResource{}
 		err := yaml.Unmarshal(item, &t)
 
 		if err != nil {
 			// Ignore item that cannot be unmarshalled..
 			klog.Warning(err, "Failed to unmarshal YAML content")
+
+			errs = append(errs, fmt.Errorf("document %d: %w", n+1, err))
+
 			continue
 		}
 
@@ -141,7 +189,7 @@ func KubeResourceParser(file []byte, cond Kube) [][]byte {
 		}
 	}
 
-	return ret
+	return ret, errs
 }
 
 func getCertChain(certs string) tls.Certificate {
@@ -209,13 +257,17 @@ func getConnectionOptions(cloneOptions *GitCloneOption, primary bool) (connectio
 	if strings.HasPrefix(options.URL, "http") {
 		klog.Info("Connecting to Git server via HTTP")
 
+		options.Auth, err = getHTTPAuth(channelConnOptions.Provider, channelConnOptions.User, channelConnOptions.Password)
+		if err != nil {
+			return nil, err
+		}
+
 		err := getHTTPOptions(options,
-			channelConnOptions.User,
-			channelConnOptions.Password,
 			channelConnOptions.CaCerts,
 			channelConnOptions.InsecureSkipVerify,
 			channelConnOptions.ClientKey,
-			channelConnOptions.ClientCert)
+			channelConnOptions.ClientCert,
+			channelConnOptions.Proxy)
 
 		if err != nil {
 			klog.Error(err, "failed to prepare HTTP clone options")
@@ -243,7 +295,12 @@ func getConnectionOptions(cloneOptions *GitCloneOption, primary bool) (connectio
 
 	options.Depth = 1
 
-	if cloneOptions.CommitHash != "" || cloneOptions.RevisionTag != "" {
+	if cloneOptions.RevisionTagConstraint != "" {
+		// the tags matching the constraint are not necessarily in the history of the branch
+		options.Tags = git.AllTags
+	}
+
+	if cloneOptions.CommitHash != "" || cloneOptions.RevisionTag != "" || cloneOptions.RevisionTagConstraint != "" {
 		if cloneOptions.CloneDepth > 1 {
 			klog.Infof("Setting clone depth to %d", cloneOptions.CloneDepth)
 			options.Depth = cloneOptions.CloneDepth
@@ -258,6 +315,22 @@ func getConnectionOptions(cloneOptions *GitCloneOption, primary bool) (connectio
 
 // CloneGitRepo clones a GitHub repository
 func CloneGitRepo(cloneOptions *GitCloneOption) (commitID string, err error) {
+	return CloneGitRepoContext(context.TODO(), cloneOptions)
+}
+
+// CloneGitRepoContext clones a GitHub repository, the clone is aborted when ctx is done
+func CloneGitRepoContext(ctx context.Context, cloneOptions *GitCloneOption) (commitID string, err error) {
+	source, err := CloneGitRepoSource(ctx, cloneOptions)
+	if err != nil {
+		return "", err
+	}
+
+	return source.Commit, nil
+}
+
+// CloneGitRepoSource clones a GitHub repository and returns where its content comes from, the clone is aborted when
+// ctx is done
+func CloneGitRepoSource(ctx context.Context, cloneOptions *GitCloneOption) (*GitSource, error) {
 	usingPrimary := true
 
 	options, err := getConnectionOptions(cloneOptions, true)
@@ -274,7 +347,7 @@ func CloneGitRepo(cloneOptions *GitCloneOption) (commitID string, err error) {
 		if !usingPrimary {
 			// we could not get both primary and secondary Git connection options. return error
 			klog.Error("Failed to get Git clone options with the secondary channel.")
-			return "", err
+			return nil, err
 		}
 
 		klog.Warning("Failed to get Git clone options with the secondary channel.")
@@ -285,7 +358,7 @@ func CloneGitRepo(cloneOptions *GitCloneOption) (commitID string, err error) {
 		if secondaryOptions == nil {
 			// if trying the secondary connection option but nothing there, return error
 			// at this point, we have no Git connection options
-			return "", errors.New("failed to build git connection options")
+			return nil, errors.New("failed to build git connection options")
 		}
 
 		options = secondaryOptions
@@ -297,56 +370,82 @@ func CloneGitRepo(cloneOptions *GitCloneOption) (commitID string, err error) {
 	klog.Info("cloneOptions.Branch = " + cloneOptions.Branch)
 	klog.Info("cloneOptions.CommitHash = " + cloneOptions.CommitHash)
 	klog.Info("cloneOptions.RevisionTag = " + cloneOptions.RevisionTag)
+	klog.Info("cloneOptions.RevisionTagConstraint = " + cloneOptions.RevisionTagConstraint)
 	klog.Infof("cloneOptions.CloneDepth = %d", cloneOptions.CloneDepth)
 
-	repo, err := git.PlainClone(cloneOptions.DestDir, false, options)
+	repo, err := plainClone(ctx, cloneOptions.DestDir, options)
+
+	// the secondary channel serves the same repository
+	if IsRepoLimitExceeded(err) {
+		return nil, err
+	}
 
 	if err != nil {
-		if usingPrimary {
+		if usingPrimary && ctx.Err() == nil {
 			klog.Error(err, " Failed to git clone with the primary channel: ", err.Error())
 
 			if secondaryOptions == nil {
-				return "", errors.New("Failed to clone git: " + options.URL + Error + err.Error())
+				return nil, errors.New("Failed to clone git: " + options.URL + Error + err.Error())
 			}
 
 			klog.Info("Trying to clone with the secondary channel")
 			klog.Info("Cloning ", secondaryOptions.URL, " into ", cloneOptions.DestDir)
 
-			repo, err = git.PlainClone(cloneOptions.DestDir, false, secondaryOptions)
+			repo, err = plainClone(ctx, cloneOptions.DestDir, secondaryOptions)
+
+			options = secondaryOptions
+			usingPrimary = false
+
+			if IsRepoLimitExceeded(err) {
+				return nil, err
+			}
 
 			if err != nil {
 				klog.Error("Failed to clone Git with the secondary channel." + Error + err.Error())
 
-				return "", errors.New("Failed to clone git: " + secondaryOptions.URL + " branch: " + cloneOptions.Branch.String() + Error + err.Error())
+				return nil, errors.New("Failed to clone git: " + secondaryOptions.URL + " branch: " + cloneOptions.Branch.String() + Error + err.Error())
 			}
 		} else {
-			return "", errors.New("Failed to clone git: " + options.URL + " branch: " + cloneOptions.Branch.String() + Error + err.Error())
+			return nil, errors.New("Failed to clone git: " + options.URL + " branch: " + cloneOptions.Branch.String() + Error + err.Error())
 		}
 	}
 
 	ref, err := repo.Head()
 	if err != nil {
 		klog.Error(err, " Failed to get git repo head")
-		return "", errors.New("failed to get git repo head," + Error + err.Error())
+		return nil, errors.New("failed to get git repo head," + Error + err.Error())
 	}
 
 	klog.Infof("Successfully cloned the repo and the current branch is %s", ref.Name().Short())
 
-	// If both commitHash and revisionTag are provided, take commitHash.
+	source := &GitSource{URL: resolveGitURL(options.URL), Secondary: !usingPrimary}
+
+	// If both commitHash and revisionTag are provided, take commitHash. The tag constraint comes last.
 	targetCommit := cloneOptions.CommitHash
+	revisionTag := cloneOptions.RevisionTag
+
+	if revisionTag == "" && targetCommit == "" && cloneOptions.RevisionTagConstraint != "" {
+		revisionTag, err = resolveTagConstraint(repo, cloneOptions.RevisionTagConstraint)
+		if err != nil {
+			klog.Error(err, " failed to resolve the tag constraint")
+			return nil, err
+		}
+
+		klog.Infof("Tag %s is the highest tag matching %s", revisionTag, cloneOptions.RevisionTagConstraint)
+	}
 
-	if cloneOptions.RevisionTag != "" && targetCommit == "" {
-		tag := "refs/tags/" + cloneOptions.RevisionTag
+	if revisionTag != "" && targetCommit == "" {
+		tag := "refs/tags/" + revisionTag
 		releasetag := plumbing.Revision(tag)
 
 		revisionHash, err := repo.ResolveRevision(releasetag)
 
 		if err != nil {
 			klog.Error(err, " failed to resolve revision")
-			return "", errors.New("failed to resolve revision tag " + cloneOptions.RevisionTag + Error + err.Error())
+			return nil, errors.New("failed to resolve revision tag " + revisionTag + Error + err.Error())
 		}
 
-		klog.Infof("Revision tag %s is resolved to %s", cloneOptions.RevisionTag, revisionHash)
+		klog.Infof("Revision tag %s is resolved to %s", revisionTag, revisionHash)
 		targetCommit = revisionHash.String()
 	}
 
@@ -355,7 +454,7 @@ func CloneGitRepo(cloneOptions *GitCloneOption) (commitID string, err error) {
 
 		if err != nil {
 			klog.Error(err, " Failed to get work tree")
-			return "", err
+			return nil, err
 		}
 
 		klog.Infof("Checking out commit %s ", targetCommit)
@@ -367,12 +466,16 @@ func CloneGitRepo(cloneOptions *GitCloneOption) (commitID string, err error) {
 
 		if err != nil {
 			klog.Error(err, " Failed to checkout commit")
-			return "", errors.New("failed to checkout commit " + targetCommit + Error + err.Error())
+			return nil, errors.New("failed to checkout commit " + targetCommit + Error + err.Error())
 		}
 
 		klog.Infof("Successfully checked out commit %s ", targetCommit)
 
-		return targetCommit, nil
+		source.Ref = getGitSourceRef(ref, revisionTag)
+		source.Commit = targetCommit
+		source.Submodules = getSubmoduleCommits(repo)
+
+		return source, nil
 	}
 
 	// Otherwise return the latest commit ID
@@ -380,28 +483,66 @@ func CloneGitRepo(cloneOptions *GitCloneOption) (commitID string, err error) {
 
 	if err != nil {
 		klog.Error(err, " Failed to get git repo commit")
-		return "", errors.New("failed to get the repo's latest commit hash," + Error + err.Error())
+		return nil, errors.New("failed to get the repo's latest commit hash," + Error + err.Error())
 	}
 
-	return commit.ID().String(), nil
+	source.Ref = getGitSourceRef(ref, revisionTag)
+	source.Commit = commit.ID().String()
+	source.Submodules = getSubmoduleCommits(repo)
+
+	return source, nil
 }
 
-func getKnownHostFromURL(sshURL string, filepath string) error {
-	sshhostname := ""
-	sshhostport := ""
+// resolveTagConstraint returns the highest tag of the repository matching the semver constraint. The tags that are
+// not semantic versions are ignored.
+func resolveTagConstraint(repo *git.Repository, constraint string) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("invalid git tag constraint %v, err: %w", constraint, err)
+	}
 
-	if strings.HasPrefix(sshURL, "ssh:") {
-		u, err := url.Parse(sshURL)
+	tags, err := repo.Tags()
+	if err != nil {
+		return "", fmt.Errorf("failed to list the git tags, err: %w", err)
+	}
+
+	var latest *semver.Version
+
+	latestTag := ""
 
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+
+		v, err := semver.NewVersion(name)
 		if err != nil {
-			klog.Error("failed toparse SSH URL: ", err)
-			return err
+			klog.V(1).Infof("skip git tag %v, it is not a semantic version", name)
+
+			return nil
+		}
+
+		if c.Check(v) && (latest == nil || v.GreaterThan(latest)) {
+			latest = v
+			latestTag = name
 		}
 
-		sshhostname = u.Hostname()
-		sshhostport = u.Port()
-	} else if strings.HasPrefix(sshURL, "git@") {
-		sshhostname = strings.Split(strings.SplitAfter(sshURL, "@")[1], ":")[0]
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if latestTag == "" {
+		return "", fmt.Errorf("no git tag matches %v", constraint)
+	}
+
+	return latestTag, nil
+}
+
+func getKnownHostFromURL(sshURL string, filepath string) error {
+	sshhostname, sshhostport := ParseSSHHost(sshURL)
+	if sshhostname == "" {
+		return errors.New("failed to get the host from SSH URL " + sshURL)
 	}
 
 	klog.Info("sshhostname =  " + sshhostname)
@@ -477,14 +618,8 @@ func getSSHOptions(options *git.CloneOptions, sshKey, passphrase []byte, knownho
 	return nil
 }
 
-func getHTTPOptions(options *git.CloneOptions, user, password, caCerts string, insecureSkipVerify bool, clientkey, clientcert []byte) error {
-	if user != "" && password != "" {
-		options.Auth = &githttp.BasicAuth{
-			Username: user,
-			Password: password,
-		}
-	}
-
+func getHTTPOptions(options *git.CloneOptions, caCerts string, insecureSkipVerify bool, clientkey, clientcert []byte,
+	proxy *httpproxy.Config) error {
 	installProtocol := false
 
 	clientConfig := &tls.Config{MinVersion: tls.VersionTLS12}
@@ -544,47 +679,55 @@ func getHTTPOptions(options *git.CloneOptions, user, password, caCerts string, i
 		klog.Info("Client certificate key pair added successfully")
 	}
 
-	if installProtocol {
-		klog.Info("HTTP_PROXY = " + os.Getenv("HTTP_PROXY"))
-		klog.Info("HTTPS_PROXY = " + os.Getenv("HTTPS_PROXY"))
-		klog.Info("NO_PROXY = " + os.Getenv("NO_PROXY"))
-
-		transportConfig := &http.Transport{
-			/* #nosec G402 */
-			TLSClientConfig: clientConfig,
-		}
+	// the default client doesn't resolve names with the custom DNS server
+	if fetchDNSResolver != "" {
+		installProtocol = true
+	}
 
-		proxyURLEnv := ""
+	// the default client only goes through the proxy of the environment
+	if proxy != nil || gitProxy != nil {
+		installProtocol = true
+	}
 
-		if os.Getenv("HTTPS_PROXY") != "" {
-			proxyURLEnv = os.Getenv("HTTPS_PROXY")
-		} else if os.Getenv("HTTP_PROXY") != "" {
-			proxyURLEnv = os.Getenv("HTTP_PROXY")
-		} else if os.Getenv("NO_PROXY") != "" {
-			proxyURLEnv = os.Getenv("NO_PROXY")
-		}
+	if !installProtocol {
+		// the transports are global, don't keep the client installed for the previous channel
+		gitclient.InstallProtocol("https", gitRedirectClient)
+		gitclient.InstallProtocol("http", gitRedirectClient)
 
-		if proxyURLEnv != "" {
-			transportConfig.Proxy = http.ProxyFromEnvironment
+		return nil
+	}
 
-			klog.Info("HTTP transport proxy set")
-		}
+	if proxy != nil {
+		klog.Infof("channel Git proxy: http: %v, https: %v, no proxy: %v",
+			redactProxyURL(proxy.HTTPProxy), redactProxyURL(proxy.HTTPSProxy), proxy.NoProxy)
+	} else if gitProxy == nil {
+		klog.Info("HTTP_PROXY = " + redactProxyURL(os.Getenv("HTTP_PROXY")))
+		klog.Info("HTTPS_PROXY = " + redactProxyURL(os.Getenv("HTTPS_PROXY")))
+		klog.Info("NO_PROXY = " + os.Getenv("NO_PROXY"))
+	}
 
-		customClient := &http.Client{
-			/* #nosec G402 */
-			Transport: transportConfig,
+	transportConfig := &http.Transport{
+		DialContext: NewFetchDialer().DialContext,
+		/* #nosec G402 */
+		TLSClientConfig: clientConfig,
+		Proxy:           gitProxyFunc(proxy),
+	}
 
-			// 15 second timeout
-			Timeout: 15 * time.Second,
+	customClient := &http.Client{
+		/* #nosec G402 */
+		Transport: transportConfig,
 
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-		}
+		// 15 second timeout
+		Timeout: 15 * time.Second,
 
-		gitclient.InstallProtocol("https", githttp.NewClient(customClient))
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
 	}
 
+	gitclient.InstallProtocol("https", githttp.NewClient(customClient))
+	gitclient.InstallProtocol("http", githttp.NewClient(customClient))
+
 	return nil
 }
 
@@ -600,6 +743,15 @@ func GetSubscriptionBranch(sub *appv1.Subscription) plumbing.ReferenceName {
 	return GetSubscriptionBranchRef(branchStr)
 }
 
+// GetSubscriptionGitTagConstraint returns the semver constraint of the Git tags to deploy, if any
+func GetSubscriptionGitTagConstraint(sub *appv1.Subscription) string {
+	if sub.Spec.PackageFilter == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(sub.Spec.PackageFilter.GitTag)
+}
+
 func GetSubscriptionBranchRef(b string) plumbing.ReferenceName {
 	if b != "" {
 		if !strings.HasPrefix(b, "refs/heads/") {
@@ -635,7 +787,8 @@ func GetChannelSecret(client client.Client, chn *chnv1.Channel) (string, string,
 			return username, accessToken, sshKey, passphrase, clientkey, clientcert, err
 		}
 
-		username, accessToken, sshKey, passphrase, clientkey, clientcert, err = ParseChannelSecret(secret)
+		username, accessToken, sshKey, passphrase, clientkey, clientcert, err = ParseChannelSecret(secret,
+			GetChannelConfigMap(client, chn))
 
 		if err != nil {
 			return username, accessToken, sshKey, passphrase, clientkey, clientcert, err
@@ -665,7 +818,57 @@ func GetChannelConfigMap(client client.Client, chn *chnv1.Channel) *corev1.Confi
 	return nil
 }
 
-func ParseChannelSecret(secret *corev1.Secret) (string, string, []byte, []byte, []byte, []byte, error) {
+// GetChannelCACerts returns the CA certificates of the channel Git server, from its config map and its secret
+func GetChannelCACerts(client client.Client, chn *chnv1.Channel) string {
+	return ChannelCACerts(getChannelSecretObject(client, chn), GetChannelConfigMap(client, chn))
+}
+
+// GetChannelProxy returns the proxy of the channel Git server, from its config map and its secret, nil if it has none
+func GetChannelProxy(client client.Client, chn *chnv1.Channel) *httpproxy.Config {
+	return GetChannelProxyConfig(getChannelSecretObject(client, chn), GetChannelConfigMap(client, chn))
+}
+
+func getChannelSecretObject(client client.Client, chn *chnv1.Channel) *corev1.Secret {
+	if chn.Spec.SecretRef == nil {
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	secns := chn.Spec.SecretRef.Namespace
+
+	if secns == "" {
+		secns = chn.Namespace
+	}
+
+	if err := client.Get(context.TODO(), types.NamespacedName{Name: chn.Spec.SecretRef.Name, Namespace: secns}, secret); err != nil {
+		klog.Error(err, "Unable to get secret from local cluster.")
+
+		return nil
+	}
+
+	return secret
+}
+
+// ChannelCACerts returns the PEM CA certificate bundles of the caCerts key of the channel config map and secret, the
+// certificates of both are trusted
+func ChannelCACerts(secret *corev1.Secret, configmap *corev1.ConfigMap) string {
+	bundles := []string{}
+
+	if configmap != nil && strings.TrimSpace(configmap.Data[CACerts]) != "" {
+		bundles = append(bundles, strings.TrimSpace(configmap.Data[CACerts]))
+	}
+
+	if secret != nil && len(bytes.TrimSpace(secret.Data[CACerts])) > 0 {
+		bundles = append(bundles, string(bytes.TrimSpace(secret.Data[CACerts])))
+	}
+
+	return strings.Join(bundles, "\n")
+}
+
+// ParseChannelSecret returns the Git credentials of the channel secret. The installation token of a GitHub App secret
+// is minted with the CA certificates and the proxy of the secret and the config map of the channel.
+func ParseChannelSecret(secret *corev1.Secret,
+	configmap *corev1.ConfigMap) (string, string, []byte, []byte, []byte, []byte, error) {
 	username := ""
 	accessToken := ""
 	sshKey := []byte("")
@@ -686,6 +889,16 @@ func ParseChannelSecret(secret *corev1.Secret) (string, string, []byte, []byte,
 		return username, accessToken, sshKey, passphrase, clientKey, clientCert, err
 	}
 
+	if isGitHubAppSecret(secret) {
+		accessToken, err = getGitHubAppToken(secret, configmap)
+		if err != nil {
+			klog.Error(err, "Failed to get the GitHub App installation token.")
+			return username, accessToken, sshKey, passphrase, clientKey, clientCert, err
+		}
+
+		username = gitHubAppTokenUser
+	}
+
 	sshKey = bytes.TrimSpace(secret.Data[SSHKey])
 	passphrase = bytes.TrimSpace(secret.Data[Passphrase])
 	clientKey = bytes.TrimSpace(secret.Data[ClientKey])
@@ -697,11 +910,12 @@ func ParseChannelSecret(secret *corev1.Secret) (string, string, []byte, []byte,
 			errors.New("for mTLS connection to Git, both clientKey (private key) and clientCert (certificate) are required in the channel secret")
 	}
 
-	if len(sshKey) == 0 && len(clientKey) == 0 {
-		if username == "" || accessToken == "" {
-			klog.Error(err, "sshKey (and optionally passphrase) or user and accressToken need to be specified in the channel secret")
+	// a secret with only the CA certificates is for a public repository on a server with a custom CA
+	if len(sshKey) == 0 && len(clientKey) == 0 && len(bytes.TrimSpace(secret.Data[CACerts])) == 0 {
+		if accessToken == "" {
+			klog.Error(err, "sshKey (and optionally passphrase) or accessToken (and optionally user) need to be specified in the channel secret")
 			return username, accessToken, sshKey, passphrase, clientKey, clientCert,
-				errors.New("ssh_key (and optionally passphrase) or user and accressToken need to be specified in the channel secret")
+				errors.New("ssh_key (and optionally passphrase) or accessToken (and optionally user) need to be specified in the channel secret")
 		}
 	}
 
@@ -743,9 +957,14 @@ func SortResources(repoRoot, resourcePath string, skips ...SkipFunc) (map[string
 	currentChartDir := "NONE"
 	currentKustomizeDir := "NONE"
 
-	kubeIgnore := GetKubeIgnore(resourcePath)
+	// the .kubernetesignore files of the resource path and of the repo root both apply
+	kubeIgnores := []*gitignore.GitIgnore{GetKubeIgnore(resourcePath)}
 
-	err := filepath.Walk(resourcePath,
+	if filepath.Clean(resourcePath) != filepath.Clean(repoRoot) {
+		kubeIgnores = append(kubeIgnores, GetKubeIgnore(repoRoot))
+	}
+
+	err := walkRepo(repoRoot, resourcePath,
 		func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
@@ -757,7 +976,13 @@ func SortResources(repoRoot, resourcePath string, skips ...SkipFunc) (map[string
 				relativePath = strings.SplitAfter(path, repoRoot+"/")[1]
 			}
 
-			if !kubeIgnore.MatchesPath(relativePath) && !skip(resourcePath, path) {
+			if !isKubeIgnored(kubeIgnores, relativePath) && !skip(resourcePath, path) {
+				if !info.IsDir() && !strings.HasPrefix(path, repoRoot+"/.git") {
+					if err := checkRepoFileSize(path, info); err != nil {
+						return err
+					}
+				}
+
 				if info.IsDir() {
 					klog.V(4).Info("Ignoring subfolders of ", currentChartDir)
 					if _, err := os.Stat(path + "/Chart.yaml"); err == nil {
@@ -767,21 +992,12 @@ func SortResources(repoRoot, resourcePath string, skips ...SkipFunc) (map[string
 							chartDirs[path+"/"] = path + "/"
 							currentChartDir = path + "/"
 						}
-					} else if _, err := os.Stat(path + "/kustomization.yaml"); err == nil {
+					} else if kustomizationFile, ok := findKustomizationFile(path); ok {
 						// If there are nested kustomizations or any other folder structures containing kube
 						// resources under a kustomization, subscription should not process them and let kustomize
 						// build handle them based on the top-level kustomization.yaml.
 						if !strings.HasPrefix(path, currentKustomizeDir) {
-							klog.V(4).Info("Found kustomization.yaml in ", path)
-							currentKustomizeDir = path + "/"
-							kustomizeDirs[path+"/"] = path + "/"
-						}
-					} else if _, err := os.Stat(path + "/kustomization.yml"); err == nil {
-						// If there are nested kustomizations or any other folder structures containing kube
-						// resources under a kustomization, subscription should not process them and let kustomize
-						// build handle them based on the top-level kustomization.yaml
-						if !strings.HasPrefix(path, currentKustomizeDir) {
-							klog.V(4).Info("Found kustomization.yml in ", path)
+							klog.V(4).Info("Found ", filepath.Base(kustomizationFile), " in ", path)
 							currentKustomizeDir = path + "/"
 							kustomizeDirs[path+"/"] = path + "/"
 						}
@@ -798,6 +1014,10 @@ func SortResources(repoRoot, resourcePath string, skips ...SkipFunc) (map[string
 						klog.Error(err.Error())
 						return err
 					}
+
+					if err := checkManifestCount(len(crdsAndNamespaceFiles) + len(rbacFiles) + len(otherFiles)); err != nil {
+						return err
+					}
 				}
 			}
 
@@ -807,8 +1027,100 @@ func SortResources(repoRoot, resourcePath string, skips ...SkipFunc) (map[string
 	return chartDirs, kustomizeDirs, crdsAndNamespaceFiles, rbacFiles, otherFiles, err
 }
 
+// SortResourcePaths sorts the resources of several paths of the cloned repo like SortResources, and merges them in
+// the order of the paths. The resources of nested or repeated paths are returned once.
+func SortResourcePaths(repoRoot string, resourcePaths []string, skips ...SkipFunc) (map[string]string, map[string]string,
+	[]string, []string, []string, error) {
+	if len(resourcePaths) == 1 {
+		return SortResources(repoRoot, resourcePaths[0], skips...)
+	}
+
+	chartDirs := make(map[string]string)
+	kustomizeDirs := make(map[string]string)
+	crdsAndNamespaceFiles := []string{}
+	rbacFiles := []string{}
+	otherFiles := []string{}
+	sortedFiles := make(map[string]bool)
+
+	appendFiles := func(files, pathFiles []string) []string {
+		for _, file := range pathFiles {
+			if !sortedFiles[file] {
+				sortedFiles[file] = true
+				files = append(files, file)
+			}
+		}
+
+		return files
+	}
+
+	for _, resourcePath := range resourcePaths {
+		pathChartDirs, pathKustomizeDirs, pathCrdsAndNamespaceFiles, pathRbacFiles, pathOtherFiles, err :=
+			SortResources(repoRoot, resourcePath, skips...)
+		if err != nil {
+			return nil, nil, nil, nil, nil, err
+		}
+
+		for k, v := range pathChartDirs {
+			chartDirs[k] = v
+		}
+
+		for k, v := range pathKustomizeDirs {
+			kustomizeDirs[k] = v
+		}
+
+		crdsAndNamespaceFiles = appendFiles(crdsAndNamespaceFiles, pathCrdsAndNamespaceFiles)
+		rbacFiles = appendFiles(rbacFiles, pathRbacFiles)
+		otherFiles = appendFiles(otherFiles, pathOtherFiles)
+	}
+
+	if err := checkManifestCount(len(crdsAndNamespaceFiles) + len(rbacFiles) + len(otherFiles)); err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	return chartDirs, kustomizeDirs, crdsAndNamespaceFiles, rbacFiles, otherFiles, nil
+}
+
+// SortedDirs returns the directories of the chart or kustomization directory map in lexical order, so they are
+// processed in the same order on every reconcile
+func SortedDirs(dirs map[string]string) []string {
+	sorted := make([]string, 0, len(dirs))
+
+	for _, dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+const (
+	crdAndNamespacePhase = iota
+	rbacPhase
+	otherPhase
+)
+
+// GetKindApplyPhase returns the phase the resources of the kind are applied in, the CRDs and namespaces first, then
+// the RBAC resources and the others last
+func GetKindApplyPhase(kind string) int {
+	switch strings.ToLower(kind) {
+	case "customresourcedefinition", "namespace":
+		return crdAndNamespacePhase
+	case "serviceaccount", "clusterrole", "role", "clusterrolebinding", "rolebinding":
+		return rbacPhase
+	}
+
+	return otherPhase
+}
+
 func sortKubeResource(crdsAndNamespaceFiles, rbacFiles, otherFiles []string, path string) ([]string, []string, []string, error) {
-	if strings.EqualFold(filepath.Ext(path), ".yml") || strings.EqualFold(filepath.Ext(path), ".yaml") {
+	if sniff, ok := manifestFileType(path); ok {
+		if sniff {
+			if info, err := os.Stat(path); err != nil || info.Size() > maxSniffedManifestSize {
+				return crdsAndNamespaceFiles, rbacFiles, otherFiles, nil
+			}
+		}
+
 		klog.V(4).Info("Reading file: ", path)
 
 		file, err := ioutil.ReadFile(path) // #nosec G304 path is not user input
@@ -818,36 +1130,45 @@ func sortKubeResource(crdsAndNamespaceFiles, rbacFiles, otherFiles []string, pat
 			return crdsAndNamespaceFiles, rbacFiles, otherFiles, err
 		}
 
-		resources := ParseKubeResoures(file)
-
-		if len(resources) == 1 {
-			t := kubeResource{}
-			err := yaml.Unmarshal(resources[0], &t)
+		resources, parseErr := ParseKubeResourcesWithErrors(file)
 
-			if err != nil {
-				klog.Warning("Failed to unmarshal YAML file")
-				// Just ignore the YAML
-				return crdsAndNamespaceFiles, rbacFiles, otherFiles, nil
+		if sniff && (parseErr != nil || len(resources) == 0) {
+			// the files without extension are subscribed only if they are Kubernetes manifests
+			klog.V(4).Info("Not a Kubernetes manifest: ", path)
+		} else if len(resources) == 0 && parseErr != nil {
+			// keep the invalid YAML file, so it is reported when the resources are subscribed
+			otherFiles = append(otherFiles, path)
+		} else if len(resources) > 0 {
+			if len(resources) > 1 {
+				klog.Info("Multi resource file ", path)
 			}
 
-			if t.APIVersion != "" && t.Kind != "" {
-				if strings.EqualFold(t.Kind, "customresourcedefinition") {
-					crdsAndNamespaceFiles = append(crdsAndNamespaceFiles, path)
-				} else if strings.EqualFold(t.Kind, "namespace") {
-					crdsAndNamespaceFiles = append(crdsAndNamespaceFiles, path)
-				} else if strings.EqualFold(t.Kind, "serviceaccount") ||
-					strings.EqualFold(t.Kind, "clusterrole") ||
-					strings.EqualFold(t.Kind, "role") ||
-					strings.EqualFold(t.Kind, "clusterrolebinding") ||
-					strings.EqualFold(t.Kind, "rolebinding") {
-					rbacFiles = append(rbacFiles, path)
-				} else {
-					otherFiles = append(otherFiles, path)
+			// the documents of a file are applied together, in the earliest phase of their kinds, so the CRDs and
+			// namespaces of a multi resource file come before the resources of the other files
+			phase := -1
+
+			for _, resource := range resources {
+				t := kubeResource{}
+
+				if err := yaml.Unmarshal(resource, &t); err != nil || t.APIVersion == "" || t.Kind == "" {
+					klog.Warning("Failed to unmarshal YAML document of file ", path)
+
+					continue
+				}
+
+				if kindPhase := GetKindApplyPhase(t.Kind); phase == -1 || kindPhase < phase {
+					phase = kindPhase
 				}
 			}
-		} else if len(resources) > 1 {
-			klog.Info("Multi resource")
-			otherFiles = append(otherFiles, path)
+
+			switch phase {
+			case crdAndNamespacePhase:
+				crdsAndNamespaceFiles = append(crdsAndNamespaceFiles, path)
+			case rbacPhase:
+				rbacFiles = append(rbacFiles, path)
+			case otherPhase:
+				otherFiles = append(otherFiles, path)
+			}
 		}
 	}
 
@@ -880,6 +1201,17 @@ func GetKubeIgnore(resourcePath string) *gitignore.GitIgnore {
 	return kubeIgnore
 }
 
+// isKubeIgnored returns true if the path relative to the repo root matches one of the .kubernetesignore lists
+func isKubeIgnored(kubeIgnores []*gitignore.GitIgnore, relativePath string) bool {
+	for _, kubeIgnore := range kubeIgnores {
+		if kubeIgnore.MatchesPath(relativePath) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // IsGitChannel returns true if channel type is github or git
 func IsGitChannel(chType string) bool {
 	return strings.EqualFold(chType, chnv1.ChannelTypeGitHub) ||
@@ -1069,14 +1401,15 @@ func GetLatestCommitID(url, branch string, clt ...*github.Client) (string, error
 }
 
 func ParseYAML(fileContent []byte) []string {
-	fileContentString := string(fileContent)
+	fileContentString := strings.ReplaceAll(string(fileContent), "\r\n", "\n")
 	lines := strings.Split(fileContentString, "\n")
 	newFileContent := []byte("")
 
-	// Multi-document YAML delimeter --- might have trailing spaces. Trim those first.
+	// Multi-document YAML delimeter --- might have trailing spaces or a comment, and the documents might end with the
+	// ... marker. Normalize those to --- first.
 	for _, line := range lines {
-		if strings.HasPrefix(line, "---") {
-			line = strings.Trim(line, " ")
+		if isYAMLDocumentSeparator(line) {
+			line = "---"
 		}
 
 		line += "\n"
@@ -1090,6 +1423,18 @@ func ParseYAML(fileContent []byte) []string {
 	return items
 }
 
+// isYAMLDocumentSeparator returns true if the line starts or ends a YAML document, i.e. it is --- or ... followed by
+// nothing but spaces and a comment
+func isYAMLDocumentSeparator(line string) bool {
+	if !strings.HasPrefix(line, "---") && !strings.HasPrefix(line, "...") {
+		return false
+	}
+
+	rest := strings.TrimLeft(line[3:], " \t")
+
+	return rest == "" || (strings.HasPrefix(rest, "#") && len(rest) < len(line[3:]))
+}
+
 func getOwnerAndRepo(url string) ([]string, error) {
 	if len(url) == 0 {
 		return []string{}, nil
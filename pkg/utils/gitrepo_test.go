@@ -23,12 +23,15 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/onsi/gomega"
+	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	admissionv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -198,6 +201,39 @@ data:
 	}
 }
 
+func TestParseKubeResourcesWithErrors(t *testing.T) {
+	testYaml := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test-configmap-1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: [test-configmap-2
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test-configmap-3`
+
+	ret, err := ParseKubeResourcesWithErrors([]byte(testYaml))
+
+	if len(ret) != 2 {
+		t.Errorf("faild to parse yaml objects, wanted %v, got %v", 2, len(ret))
+	}
+
+	if err == nil || !strings.Contains(err.Error(), "document 2") {
+		t.Errorf("wanted the error of the second document, got %v", err)
+	}
+
+	ret, err = ParseKubeResourcesWithErrors([]byte(testYaml[:strings.Index(testYaml, "---")]))
+
+	if len(ret) != 1 || err != nil {
+		t.Errorf("faild to parse yaml objects, wanted %v, got %v, err: %v", 1, len(ret), err)
+	}
+}
+
 func TestGetCertChain(t *testing.T) {
 	validCert := `
 -----BEGIN CERTIFICATE-----
@@ -252,6 +288,26 @@ and some more`
 	}
 }
 
+func TestChannelCACerts(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	configmap := &corev1.ConfigMap{Data: map[string]string{CACerts: "configmap-ca\n"}}
+	secret := &corev1.Secret{Data: map[string][]byte{CACerts: []byte("secret-ca")}}
+
+	g.Expect(ChannelCACerts(nil, nil)).To(gomega.BeEmpty())
+	g.Expect(ChannelCACerts(nil, configmap)).To(gomega.Equal("configmap-ca"))
+	g.Expect(ChannelCACerts(secret, configmap)).To(gomega.Equal("configmap-ca\nsecret-ca"))
+
+	// a secret with only the CA certificates is valid, the repository is public
+	user, pwd, _, _, _, _, err := ParseChannelSecret(secret, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(user).To(gomega.BeEmpty())
+	g.Expect(pwd).To(gomega.BeEmpty())
+
+	_, _, _, _, _, _, err = ParseChannelSecret(&corev1.Secret{}, nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
 func TestParseMultiDocYAML(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 
@@ -273,6 +329,56 @@ func TestParseMultiDocYAML(t *testing.T) {
 	g.Expect(configMapWithCert.Data["ca.crt"]).To(gomega.HaveSuffix("CERTIFICATE-----"))
 }
 
+func TestParseMultiDocYAMLSeparators(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	content := "--- # first\r\napiVersion: v1\r\nkind: Namespace\r\nmetadata:\r\n  name: ns1\r\n" +
+		"---\t\r\napiVersion: v1\r\nkind: ConfigMap\r\nmetadata:\r\n  name: cm1\r\n  namespace: ns1\r\n" +
+		"...\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm2\ndata:\n  key: '---# not a separator'\n" +
+		"---not a separator\n"
+
+	items := ParseYAML([]byte(content))
+	g.Expect(items).To(gomega.HaveLen(3))
+	g.Expect(items[2]).To(gomega.ContainSubstring("---not a separator"))
+
+	for i, name := range []string{"ns1", "cm1"} {
+		g.Expect(items[i]).NotTo(gomega.ContainSubstring("\r"))
+		g.Expect(items[i]).To(gomega.ContainSubstring("name: " + name))
+	}
+}
+
+func TestSortMultiDocResources(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "multidoc")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"app.yaml": "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: ns1\n---\n" +
+			"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm1\n  namespace: ns1\n",
+		"rbac.yaml": "apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: sa1\n---\n" +
+			"apiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nmetadata:\n  name: role1\n",
+		"other.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm2\n---\n" +
+			"apiVersion: v1\nkind: Secret\nmetadata:\n  name: secret1\n",
+	}
+
+	crdsAndNamespaceFiles, rbacFiles, otherFiles := []string{}, []string{}, []string{}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		g.Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(gomega.Succeed())
+
+		crdsAndNamespaceFiles, rbacFiles, otherFiles, err = sortKubeResource(crdsAndNamespaceFiles, rbacFiles, otherFiles, path)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+	}
+
+	g.Expect(crdsAndNamespaceFiles).To(gomega.Equal([]string{filepath.Join(dir, "app.yaml")}))
+	g.Expect(rbacFiles).To(gomega.Equal([]string{filepath.Join(dir, "rbac.yaml")}))
+	g.Expect(otherFiles).To(gomega.Equal([]string{filepath.Join(dir, "other.yaml")}))
+}
+
 func TestGetSubscriptionBranch(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 
@@ -589,6 +695,80 @@ func TestNestedKustomize(t *testing.T) {
 	g.Expect(kustomizeDirs["../../test/github/nestedKustomize/wordpress2/"]).To(gomega.Equal("../../test/github/nestedKustomize/wordpress2/"))
 }
 
+func TestRepoRootKubeIgnore(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "kubeignore")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		".kubernetesignore":          "docs/\n*.tmpl.yaml\n",
+		"apps/.kubernetesignore":     "skipped.yaml\n",
+		"apps/cm.yaml":               "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		"apps/skipped.yaml":          "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: skipped\n",
+		"apps/deploy.tmpl.yaml":      "apiVersion: {{ .Values.apiVersion }}\nkind: {{ .Values.kind }\n",
+		"apps/docs/example.yaml":     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: example\n",
+		"apps/docs/chart/Chart.yaml": "apiVersion: v2\nname: example\nversion: 0.1.0\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		g.Expect(os.MkdirAll(filepath.Dir(path), 0750)).To(gomega.Succeed())
+		g.Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(gomega.Succeed())
+	}
+
+	// the .kubernetesignore files of the repo root and of the resource path both apply
+	chartDirs, _, _, _, otherFiles, err := SortResources(dir, filepath.Join(dir, "apps"))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(chartDirs).To(gomega.BeEmpty())
+	g.Expect(otherFiles).To(gomega.Equal([]string{filepath.Join(dir, "apps/cm.yaml")}))
+}
+
+func TestSortResourcesSymlinks(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "symlinks")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	outside, err := ioutil.TempDir("", "symlinks-outside")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(outside)
+
+	files := map[string]string{
+		"base/cm.yaml":              "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n",
+		"envs/prod/sa.yaml":         "apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: sa\n",
+		"charts/greeter/Chart.yaml": "apiVersion: v2\nname: greeter\nversion: 0.1.0\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		g.Expect(os.MkdirAll(filepath.Dir(path), 0750)).To(gomega.Succeed())
+		g.Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(gomega.Succeed())
+	}
+
+	g.Expect(ioutil.WriteFile(filepath.Join(outside, "secret.yaml"),
+		[]byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: secret\n"), 0600)).To(gomega.Succeed())
+
+	// the shared manifests and chart, a loop, a link out of the repository and a broken link
+	g.Expect(os.Symlink("../../base", filepath.Join(dir, "envs/prod/base"))).To(gomega.Succeed())
+	g.Expect(os.Symlink("../../charts/greeter", filepath.Join(dir, "envs/prod/greeter"))).To(gomega.Succeed())
+	g.Expect(os.Symlink("..", filepath.Join(dir, "envs/prod/loop"))).To(gomega.Succeed())
+	g.Expect(os.Symlink(outside, filepath.Join(dir, "envs/prod/outside"))).To(gomega.Succeed())
+	g.Expect(os.Symlink(filepath.Join(outside, "secret.yaml"), filepath.Join(dir, "envs/prod/secret.yaml"))).To(gomega.Succeed())
+	g.Expect(os.Symlink("missing", filepath.Join(dir, "envs/prod/missing"))).To(gomega.Succeed())
+
+	chartDirs, _, _, rbacFiles, otherFiles, err := SortResources(dir, filepath.Join(dir, "envs/prod"))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(chartDirs).To(gomega.HaveKey(filepath.Join(dir, "envs/prod/greeter") + "/"))
+	g.Expect(rbacFiles).To(gomega.Equal([]string{filepath.Join(dir, "envs/prod/sa.yaml")}))
+	g.Expect(otherFiles).To(gomega.Equal([]string{filepath.Join(dir, "envs/prod/base/cm.yaml")}))
+}
+
 func TestSimple(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 	g.Expect("hello").To(gomega.Equal("hello"))
@@ -1099,3 +1279,47 @@ func TestGetLatestCommitID(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveTagConstraint(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	srcDir, err := ioutil.TempDir("", "gittag-src")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(srcDir)
+
+	repo, err := git.PlainInit(srcDir, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	wt, err := repo.Worktree()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	for _, tag := range []string{"v1.1.0", "v1.3.5", "latest", "v2.0.0", "v1.2.0"} {
+		g.Expect(ioutil.WriteFile(filepath.Join(srcDir, "release"), []byte(tag), 0600)).To(gomega.Succeed())
+
+		_, err = wt.Add("release")
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		hash, err := wt.Commit("release "+tag, &git.CommitOptions{
+			Author: &object.Signature{Name: "Jane", Email: "jane@example.com", When: time.Now()},
+		})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		_, err = repo.CreateTag(tag, hash, nil)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+	}
+
+	tag, err := resolveTagConstraint(repo, ">=v1.2.0 <2.0.0")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(tag).To(gomega.Equal("v1.3.5"))
+
+	tag, err = resolveTagConstraint(repo, "1.x")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(tag).To(gomega.Equal("v1.3.5"))
+
+	_, err = resolveTagConstraint(repo, ">=3.0.0")
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	_, err = resolveTagConstraint(repo, "not a constraint")
+	g.Expect(err).To(gomega.HaveOccurred())
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// ReasonSignatureVerification prefixes the subscription status reason when the commit signature can't be verified
+const ReasonSignatureVerification = "SignatureVerificationFailed"
+
+// HasGitSigningKeys returns true if the commits of the subscription are verified with its git-signing-keys annotation
+func HasGitSigningKeys(sub *appv1.Subscription) bool {
+	return strings.TrimSpace(sub.GetAnnotations()[appv1.AnnotationGitSigningKeys]) != ""
+}
+
+// GetGitSigningKeys returns the armored PGP public keys of the keyring secret of the git-signing-keys annotation of
+// the subscription, one per key of the secret in lexical order. It returns no key if the subscription has no keyring.
+func GetGitSigningKeys(clt client.Client, sub *appv1.Subscription) ([]string, error) {
+	secretName := strings.TrimSpace(sub.GetAnnotations()[appv1.AnnotationGitSigningKeys])
+	if secretName == "" {
+		return nil, nil
+	}
+
+	return getSecretValues(clt, sub.Namespace, secretName, "signing keys")
+}
+
+// getSecretValues returns the non empty values of the secret, in the lexical order of their keys
+func getSecretValues(clt client.Client, namespace, secretName, what string) ([]string, error) {
+	secret := &corev1.Secret{}
+	if err := clt.Get(context.TODO(), types.NamespacedName{Name: secretName, Namespace: namespace}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get the %v secret %v/%v: %w", what, namespace, secretName, err)
+	}
+
+	names := make([]string, 0, len(secret.Data))
+
+	for name := range secret.Data {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	values := []string{}
+
+	for _, name := range names {
+		if value := strings.TrimSpace(string(secret.Data[name])); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	if len(values) == 0 {
+		return nil, fmt.Errorf("the %v secret %v/%v has no key", what, namespace, secretName)
+	}
+
+	return values, nil
+}
+
+// VerifyGitSignature checks the commit of the cloned repository, or the annotated tag of ref if the commit is not
+// signed, is signed by one of the armored PGP keys, and returns the identity of the signer. The tag only vouches for
+// the commit it targets, e.g. not for the commit of the git-desired-commit annotation of a subscription to a tag.
+func VerifyGitSignature(repoRoot, commitID, ref string, keys []string) (string, error) {
+	repo, err := git.PlainOpen(repoRoot)
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := repo.CommitObject(plumbing.NewHash(commitID))
+	if err != nil {
+		return "", fmt.Errorf("failed to read commit %v: %w", commitID, err)
+	}
+
+	if commit.PGPSignature != "" {
+		for _, key := range keys {
+			if signer, err := commit.Verify(key); err == nil {
+				return signerIdentity(signer), nil
+			}
+		}
+
+		return "", fmt.Errorf("commit %v is not signed by a trusted key", commitID)
+	}
+
+	if strings.HasPrefix(ref, "refs/tags/") {
+		tagRef, err := repo.Reference(plumbing.ReferenceName(ref), false)
+		if err == nil {
+			if tag, err := repo.TagObject(tagRef.Hash()); err == nil && tag.PGPSignature != "" {
+				if tag.TargetType != plumbing.CommitObject || tag.Target != commit.Hash {
+					return "", fmt.Errorf("commit %v is not signed, and tag %v targets %v instead", commitID, tag.Name,
+						tag.Target)
+				}
+
+				for _, key := range keys {
+					if signer, err := tag.Verify(key); err == nil {
+						return signerIdentity(signer), nil
+					}
+				}
+
+				return "", fmt.Errorf("tag %v is not signed by a trusted key", tag.Name)
+			}
+		}
+	}
+
+	return "", errors.New("commit " + commitID + " is not signed")
+}
+
+// signerIdentity returns the first identity of the signing key, by name
+func signerIdentity(signer *openpgp.Entity) string {
+	names := make([]string, 0, len(signer.Identities))
+
+	for name := range signer.Identities {
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		return fmt.Sprintf("key %X", signer.PrimaryKey.KeyId)
+	}
+
+	sort.Strings(names)
+
+	return names[0]
+}
+
+// UpdateSignatureVerificationStatus sets the subscription failed with the signature verification failure, or clears a
+// previous failure if failure is empty.
+func UpdateSignatureVerificationStatus(clt client.Client, instance *appv1.Subscription, failure string) {
+	UpdateFailureReasonStatus(clt, instance, ReasonSignatureVerification, failure)
+}
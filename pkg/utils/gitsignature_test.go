@@ -0,0 +1,147 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func armoredPublicKey(g *gomega.WithT, entity *openpgp.Entity) string {
+	buf := &bytes.Buffer{}
+
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(entity.Serialize(w)).To(gomega.Succeed())
+	g.Expect(w.Close()).To(gomega.Succeed())
+
+	return buf.String()
+}
+
+func TestVerifyGitSignature(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	trusted, err := openpgp.NewEntity("Release Bot", "", "release-bot@example.com", nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	untrusted, err := openpgp.NewEntity("Someone", "", "someone@example.org", nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	keys := []string{armoredPublicKey(g, untrusted), armoredPublicKey(g, trusted)}
+
+	dir, err := ioutil.TempDir("", "gitsignature")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	repo, err := git.PlainInit(dir, false)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	wt, err := repo.Worktree()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	commit := func(name string, signKey *openpgp.Entity) string {
+		g.Expect(ioutil.WriteFile(filepath.Join(dir, name), []byte("kind: ConfigMap"), 0600)).To(gomega.Succeed())
+
+		_, err := wt.Add(name)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		hash, err := wt.Commit("add "+name, &git.CommitOptions{
+			Author:  &object.Signature{Name: "Jane", Email: "jane@example.com", When: time.Now()},
+			SignKey: signKey,
+		})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		return hash.String()
+	}
+
+	signed := commit("signed.yaml", trusted)
+
+	signer, err := VerifyGitSignature(dir, signed, "refs/heads/master", keys)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(signer).To(gomega.Equal("Release Bot <release-bot@example.com>"))
+
+	otherSigned := commit("other.yaml", untrusted)
+
+	_, err = VerifyGitSignature(dir, otherSigned, "refs/heads/master", keys[1:])
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("not signed by a trusted key")))
+
+	unsigned := commit("unsigned.yaml", nil)
+
+	_, err = VerifyGitSignature(dir, unsigned, "refs/heads/master", keys)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("is not signed")))
+
+	// an unsigned commit of a signed annotated tag
+	tagger := &object.Signature{Name: "Release Bot", Email: "release-bot@example.com", When: time.Now()}
+	_, err = repo.CreateTag("v1.0.0", plumbing.NewHash(unsigned), &git.CreateTagOptions{Tagger: tagger, Message: "v1.0.0", SignKey: trusted})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	signer, err = VerifyGitSignature(dir, unsigned, "refs/tags/v1.0.0", keys)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(signer).To(gomega.Equal("Release Bot <release-bot@example.com>"))
+
+	// the signed tag doesn't vouch for another commit, e.g. the desired commit of the subscription
+	otherUnsigned := commit("other-unsigned.yaml", nil)
+
+	_, err = VerifyGitSignature(dir, otherUnsigned, "refs/tags/v1.0.0", keys)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("targets " + unsigned)))
+}
+
+func TestGetGitSigningKeys(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(s)).To(gomega.Succeed())
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "signing-keys", Namespace: "default"},
+		Data:       map[string][]byte{"b.asc": []byte("key b\n"), "a.asc": []byte("key a"), "empty": []byte(" ")},
+	}
+	clt := fake.NewClientBuilder().WithScheme(s).WithObjects(secret).Build()
+
+	sub := &appv1.Subscription{ObjectMeta: metav1.ObjectMeta{Name: "git-sub", Namespace: "default"}}
+
+	keys, err := GetGitSigningKeys(clt, sub)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(keys).To(gomega.BeEmpty())
+
+	sub.SetAnnotations(map[string]string{appv1.AnnotationGitSigningKeys: "signing-keys"})
+
+	keys, err = GetGitSigningKeys(clt, sub)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(keys).To(gomega.Equal([]string{"key a", "key b"}))
+
+	sub.SetAnnotations(map[string]string{appv1.AnnotationGitSigningKeys: "missing"})
+
+	_, err = GetGitSigningKeys(clt, sub)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
@@ -0,0 +1,257 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"k8s.io/klog/v2"
+)
+
+// GitSource is where the content of a Git repo clone comes from, to reproduce or audit the sync of its resources
+type GitSource struct {
+	// URL is the repo URL after the HTTP redirects, without credentials
+	URL string
+	// Ref is the branch or tag reference the commit was read from
+	Ref string
+	// Commit is the commit checked out
+	Commit string
+	// Submodules are the commits of the submodules checked out, by submodule path
+	Submodules map[string]string
+	// Secondary is true if the repo was cloned from the secondary channel
+	Secondary bool
+	// ChannelCache is the URL of the hub channel cache the content was fetched through, if any
+	ChannelCache string
+}
+
+const gitInfoRefsPath = "/info/refs"
+
+// gitRedirects maps the repo URLs to their location after the HTTP redirects, as last followed by the Git client
+var gitRedirects sync.Map
+
+// gitRedirectClient is the HTTP client of the Git channels without a custom connection, it follows the redirects like
+// the default client, to the hosts of the channel source allow-list only, and records where they lead to
+var gitRedirectClient = githttp.NewClient(&http.Client{CheckRedirect: recordGitRedirect})
+
+func recordGitRedirect(req *http.Request, via []*http.Request) error {
+	// the limit of the default client
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+
+	if err := CheckSourceURLAllowed(req.URL.String()); err != nil {
+		return fmt.Errorf("redirect not followed: %w", err)
+	}
+
+	if strings.HasSuffix(via[0].URL.Path, gitInfoRefsPath) && strings.HasSuffix(req.URL.Path, gitInfoRefsPath) {
+		from := *via[0].URL
+		from.Path = strings.TrimSuffix(from.Path, gitInfoRefsPath)
+
+		to := *req.URL
+		to.Path = strings.TrimSuffix(to.Path, gitInfoRefsPath)
+
+		gitRedirects.Store(RedactGitURL(from.String()), RedactGitURL(to.String()))
+	}
+
+	return nil
+}
+
+// resolveGitURL returns the location of the repo URL after the HTTP redirects followed by its last clone, without
+// credentials
+func resolveGitURL(repoURL string) string {
+	redacted := RedactGitURL(repoURL)
+
+	if resolved, ok := gitRedirects.Load(redacted); ok {
+		return resolved.(string)
+	}
+
+	return redacted
+}
+
+// RedactGitURL returns the Git repo URL without its credentials and query, the user of the SSH URLs is kept
+func RedactGitURL(repoURL string) string {
+	repoURL = strings.TrimSuffix(strings.TrimSpace(repoURL), "/")
+
+	// the scp-like SSH URLs, e.g. git@github.com:org/repo.git, have no password
+	if !strings.Contains(repoURL, "://") {
+		return repoURL
+	}
+
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return repoURL
+	}
+
+	if u.User != nil {
+		if strings.HasPrefix(u.Scheme, "http") {
+			u.User = nil
+		} else {
+			u.User = url.User(u.User.Username())
+		}
+	}
+
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	return u.String()
+}
+
+// plainCloneContext clones the repository. With a channel source allow-list, the submodules are cloned after the
+// repository, once their URLs are checked against the allow-list.
+func plainCloneContext(ctx context.Context, destDir string, options *git.CloneOptions) (*git.Repository, error) {
+	if channelSourceAllowList == nil || options.RecurseSubmodules == git.NoRecurseSubmodules {
+		return git.PlainCloneContext(ctx, destDir, false, options)
+	}
+
+	noSubmodules := *options
+	noSubmodules.RecurseSubmodules = git.NoRecurseSubmodules
+
+	repo, err := git.PlainCloneContext(ctx, destDir, false, &noSubmodules)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := updateAllowedSubmodules(ctx, repo, options.Auth, options.RecurseSubmodules); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// updateAllowedSubmodules clones the submodules of the repo, down to depth levels of nested submodules. It fails if a
+// submodule URL is not in the channel source allow-list.
+func updateAllowedSubmodules(ctx context.Context, repo *git.Repository, auth transport.AuthMethod,
+	depth git.SubmoduleRescursivity) error {
+	workTree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	submodules, err := workTree.Submodules()
+	if err != nil {
+		return err
+	}
+
+	for _, submodule := range submodules {
+		if err := CheckSourceURLAllowed(submodule.Config().URL); err != nil {
+			return fmt.Errorf("submodule %v is not cloned: %w", submodule.Config().Path, err)
+		}
+
+		if err := submodule.UpdateContext(ctx, &git.SubmoduleUpdateOptions{Init: true, Auth: auth}); err != nil {
+			return fmt.Errorf("failed to update submodule %v: %w", submodule.Config().Path, err)
+		}
+
+		if depth <= 1 {
+			continue
+		}
+
+		subRepo, err := submodule.Repository()
+		if err != nil {
+			return err
+		}
+
+		if err := updateAllowedSubmodules(ctx, subRepo, auth, depth-1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getSubmoduleCommits returns the commits checked out in the submodules of the repo, by submodule path
+func getSubmoduleCommits(repo *git.Repository) map[string]string {
+	workTree, err := repo.Worktree()
+	if err != nil {
+		return nil
+	}
+
+	submodules, err := workTree.Submodules()
+	if err != nil {
+		klog.Warning("failed to list the git submodules, err: ", err)
+
+		return nil
+	}
+
+	commits := map[string]string{}
+
+	for _, submodule := range submodules {
+		status, err := submodule.Status()
+		if err != nil || status.Current.IsZero() {
+			// not checked out
+			continue
+		}
+
+		commits[submodule.Config().Path] = status.Current.String()
+	}
+
+	if len(commits) == 0 {
+		return nil
+	}
+
+	return commits
+}
+
+// FormatSubmoduleCommits formats the submodule commits as a comma separated list of path=commit, sorted by path
+func FormatSubmoduleCommits(commits map[string]string) string {
+	pairs := make([]string, 0, len(commits))
+
+	for path, commit := range commits {
+		pairs = append(pairs, path+"="+commit)
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+// ParseSubmoduleCommits parses the submodule commits formatted by FormatSubmoduleCommits, nil if there is none
+func ParseSubmoduleCommits(value string) map[string]string {
+	var commits map[string]string
+
+	for _, pair := range strings.Split(value, ",") {
+		path, commit, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || path == "" || commit == "" {
+			continue
+		}
+
+		if commits == nil {
+			commits = map[string]string{}
+		}
+
+		commits[path] = commit
+	}
+
+	return commits
+}
+
+// getGitSourceRef returns the reference the commit of the clone was read from, the tag if any or the cloned branch
+func getGitSourceRef(head *plumbing.Reference, revisionTag string) string {
+	if revisionTag != "" {
+		return plumbing.NewTagReferenceName(revisionTag).String()
+	}
+
+	return head.Name().String()
+}
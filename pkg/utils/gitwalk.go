@@ -0,0 +1,143 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// walkRepo walks the file tree of root within the cloned repo like filepath.Walk, and follows the symlinks. The
+// files and directories under a symlinked directory are walked with the path of the symlink. The symlinks resolving
+// out of the repo, broken or looping back to a directory being walked are skipped.
+func walkRepo(repoRoot, root string, walkFn filepath.WalkFunc) error {
+	realRepoRoot, err := filepath.EvalSymlinks(repoRoot)
+	if err != nil {
+		return walkFn(root, nil, err)
+	}
+
+	info, err := os.Lstat(root)
+	if err != nil {
+		return walkFn(root, nil, err)
+	}
+
+	err = walkRepoPath(realRepoRoot, root, info, map[string]bool{}, walkFn)
+	if err == filepath.SkipDir {
+		return nil
+	}
+
+	return err
+}
+
+// walkRepoPath walks path, ancestors are the real paths of the directories being walked
+func walkRepoPath(realRepoRoot, path string, info os.FileInfo, ancestors map[string]bool, walkFn filepath.WalkFunc) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			klog.Warningf("Skipping broken symlink %v, err: %v", path, err)
+
+			return nil
+		}
+
+		if !isWithinDir(realRepoRoot, target) {
+			klog.Warningf("Skipping symlink %v to %v out of the repository", path, target)
+
+			return nil
+		}
+
+		info, err = os.Stat(path)
+		if err != nil {
+			return walkFn(path, nil, err)
+		}
+	}
+
+	if !info.IsDir() {
+		return walkFn(path, info, nil)
+	}
+
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return walkFn(path, info, err)
+	}
+
+	if ancestors[realPath] {
+		klog.Warningf("Skipping symlink loop %v to %v", path, realPath)
+
+		return nil
+	}
+
+	if err := walkFn(path, info, nil); err != nil {
+		return err
+	}
+
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return walkFn(path, info, err)
+	}
+
+	names, err := f.Readdirnames(-1)
+	f.Close()
+
+	if err != nil {
+		return walkFn(path, info, err)
+	}
+
+	sort.Strings(names)
+
+	ancestors[realPath] = true
+	defer delete(ancestors, realPath)
+
+	for _, name := range names {
+		child := filepath.Join(path, name)
+
+		childInfo, err := os.Lstat(child)
+		if err != nil {
+			if err := walkFn(child, childInfo, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+
+			continue
+		}
+
+		if err := walkRepoPath(realRepoRoot, child, childInfo, ancestors, walkFn); err != nil {
+			if err == filepath.SkipDir {
+				if !childInfo.IsDir() && childInfo.Mode()&os.ModeSymlink == 0 {
+					// the remaining files of the directory are skipped
+					return nil
+				}
+
+				continue
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isWithinDir checks path is dir or under dir
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+	"k8s.io/klog/v2"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// HealthCheck is a JSONPath readiness gate declared on a package
+type HealthCheck struct {
+	// Expression is the JSONPath template evaluated against the live resource, e.g. {.status.phase}
+	Expression string
+	// Expected is the value the expression has to yield. If empty, any value other than "" or "false" passes.
+	Expected string
+}
+
+// GetHealthCheck parses the health-check annotation of a resource.
+// The annotation value is a JSONPath template optionally followed by =<expected value>,
+// e.g. {.status.conditions[?(@.type=="Ready")].status}=True
+func GetHealthCheck(rsc *unstructured.Unstructured) (*HealthCheck, error) {
+	check := strings.TrimSpace(rsc.GetAnnotations()[appv1.AnnotationHealthCheck])
+
+	if check == "" {
+		return nil, nil
+	}
+
+	end := strings.LastIndex(check, "}")
+	if !strings.HasPrefix(check, "{") || end < 0 {
+		return nil, fmt.Errorf("invalid health check %q, a JSONPath template like {.status.phase} is expected", check)
+	}
+
+	hc := &HealthCheck{Expression: check[:end+1]}
+
+	rest := strings.TrimSpace(check[end+1:])
+	if rest != "" {
+		if !strings.HasPrefix(rest, "=") {
+			return nil, fmt.Errorf("invalid health check %q, only = comparison is supported", check)
+		}
+
+		hc.Expected = strings.TrimSpace(strings.TrimLeft(rest, "="))
+	}
+
+	return hc, nil
+}
+
+// IsHealthy evaluates the health check against the live resource. It returns false and the reason
+// if the resource does not satisfy the check.
+func (hc *HealthCheck) IsHealthy(live *unstructured.Unstructured) (bool, string, error) {
+	jp := jsonpath.New("health-check").AllowMissingKeys(true)
+
+	if err := jp.Parse(hc.Expression); err != nil {
+		return false, "", fmt.Errorf("failed to parse health check %s, err: %w", hc.Expression, err)
+	}
+
+	buf := &bytes.Buffer{}
+
+	if err := jp.Execute(buf, live.Object); err != nil {
+		return false, "", fmt.Errorf("failed to evaluate health check %s, err: %w", hc.Expression, err)
+	}
+
+	got := strings.TrimSpace(buf.String())
+
+	klog.V(1).Infof("health check %s of %v/%v evaluated to %q", hc.Expression, live.GetNamespace(), live.GetName(), got)
+
+	if hc.Expected != "" {
+		if got != hc.Expected {
+			return false, fmt.Sprintf("health check %s is %q, expecting %q", hc.Expression, got, hc.Expected), nil
+		}
+
+		return true, "", nil
+	}
+
+	if got == "" || strings.EqualFold(got, "false") {
+		return false, fmt.Sprintf("health check %s is not satisfied yet", hc.Expression), nil
+	}
+
+	return true, "", nil
+}
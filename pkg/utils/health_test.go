@@ -0,0 +1,76 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestHealthCheck(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	rsc := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Database",
+		"metadata": map[string]interface{}{
+			"name": "db",
+		},
+		"status": map[string]interface{}{
+			"phase": "Provisioning",
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True"},
+			},
+		},
+	}}
+
+	hc, err := GetHealthCheck(rsc)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(hc).To(gomega.BeNil())
+
+	rsc.SetAnnotations(map[string]string{appv1.AnnotationHealthCheck: `{.status.conditions[?(@.type=="Ready")].status}=True`})
+	hc, err = GetHealthCheck(rsc)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(hc.Expected).To(gomega.Equal("True"))
+
+	healthy, _, err := hc.IsHealthy(rsc)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(healthy).To(gomega.BeTrue())
+
+	rsc.SetAnnotations(map[string]string{appv1.AnnotationHealthCheck: "{.status.phase}=Running"})
+	hc, err = GetHealthCheck(rsc)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	healthy, msg, err := hc.IsHealthy(rsc)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(healthy).To(gomega.BeFalse())
+	g.Expect(msg).To(gomega.ContainSubstring("Provisioning"))
+
+	rsc.SetAnnotations(map[string]string{appv1.AnnotationHealthCheck: "{.status.endpoint}"})
+	hc, err = GetHealthCheck(rsc)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	healthy, _, err = hc.IsHealthy(rsc)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(healthy).To(gomega.BeFalse())
+
+	rsc.SetAnnotations(map[string]string{appv1.AnnotationHealthCheck: ".status.phase"})
+	_, err = GetHealthCheck(rsc)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
@@ -0,0 +1,159 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/provenance"
+	"helm.sh/helm/v3/pkg/repo"
+	"k8s.io/klog/v2"
+)
+
+// helmV2Charts is true if the charts of apiVersion v1, the charts written for Helm 2, are deployed with the Helm 3
+// libraries along with the charts of apiVersion v2
+var helmV2Charts = true
+
+// SetHelmV2Charts sets if the charts of apiVersion v1, written for Helm 2, are deployed. Without them, the
+// subscriptions only deploy the apiVersion v2 charts of Helm 3.
+func SetHelmV2Charts(enabled bool) {
+	if !enabled {
+		klog.Info("Helm charts of apiVersion v1 are not deployed, only the apiVersion v2 charts of Helm 3")
+	}
+
+	helmV2Charts = enabled
+}
+
+// helmProvenanceKeyring is the public keyring verifying the provenance files of the charts, the charts are not
+// verified if it is not set
+var helmProvenanceKeyring = ""
+
+// SetHelmProvenanceKeyring sets the public keyring verifying the provenance files of the charts of the Helm repos. With
+// a keyring, the charts without a valid provenance file signed by one of its keys are not deployed.
+func SetHelmProvenanceKeyring(keyring string) {
+	if keyring != "" {
+		klog.Info("Helm charts are verified with the provenance keyring ", keyring)
+	}
+
+	helmProvenanceKeyring = keyring
+}
+
+// IsHelmProvenanceVerified returns true if the provenance files of the charts are verified
+func IsHelmProvenanceVerified() bool {
+	return helmProvenanceKeyring != ""
+}
+
+// VerifyHelmChartDigest returns an error if the sha256 digest of the chart archive is not the digest of the chart in
+// the repo index. The charts without digest in the index are not verified.
+func VerifyHelmChartDigest(chartZip, digest string) error {
+	digest = strings.TrimPrefix(strings.TrimSpace(digest), "sha256:")
+	if digest == "" {
+		return nil
+	}
+
+	actual, err := provenance.DigestFile(chartZip)
+	if err != nil {
+		return fmt.Errorf("failed to compute the digest of chart archive %v, err: %w", filepath.Base(chartZip), err)
+	}
+
+	if !strings.EqualFold(actual, digest) {
+		return fmt.Errorf("digest mismatch of chart archive %v: the repo index has sha256:%v, the archive is sha256:%v",
+			filepath.Base(chartZip), digest, actual)
+	}
+
+	return nil
+}
+
+// VerifyHelmChartProvenance verifies the signature of the provenance file with the keyring, and the digest of the chart
+// archive in the provenance file. archiveName is the name of the archive in the provenance file, <chart>-<version>.tgz.
+func VerifyHelmChartProvenance(chartZip, provFile, archiveName string) error {
+	signatory, err := provenance.NewFromKeyring(helmProvenanceKeyring, "")
+	if err != nil {
+		return fmt.Errorf("failed to load the Helm provenance keyring %v, err: %w", helmProvenanceKeyring, err)
+	}
+
+	// the provenance file has the digest of the archive by its name, the downloaded archives have a digest suffix
+	dir, err := ioutil.TempDir("", "helm-prov-")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(dir)
+
+	absChartZip, err := filepath.Abs(chartZip)
+	if err != nil {
+		return err
+	}
+
+	archive := filepath.Join(dir, filepath.Base(archiveName))
+	if err := os.Symlink(absChartZip, archive); err != nil {
+		return err
+	}
+
+	verification, err := signatory.Verify(archive, provFile)
+	if err != nil {
+		return fmt.Errorf("provenance verification failed for chart archive %v, err: %w", archiveName, err)
+	}
+
+	klog.V(2).Infof("chart archive %v signed by %v", archiveName, verification.SignedBy.Identities)
+
+	return nil
+}
+
+// isHelmV2Chart returns true for the charts of apiVersion v1. The charts without apiVersion are v1 charts, like the
+// Helm chart loader.
+func isHelmV2Chart(metadata *chart.Metadata) bool {
+	return metadata != nil && (metadata.APIVersion == "" || metadata.APIVersion == chart.APIVersionV1)
+}
+
+// CheckHelmChartAPIVersion returns an error if the chart is an apiVersion v1 chart and they are not deployed
+func CheckHelmChartAPIVersion(chrt *chart.Chart) error {
+	if !helmV2Charts && isHelmV2Chart(chrt.Metadata) {
+		return fmt.Errorf("chart %v is an apiVersion v1 chart of Helm 2, only the apiVersion v2 charts are deployed",
+			chrt.Name())
+	}
+
+	return nil
+}
+
+// removeHelmV2Charts deletes the apiVersion v1 versions of the charts of the index if they are not deployed
+func removeHelmV2Charts(indexFile *repo.IndexFile) {
+	if helmV2Charts {
+		return
+	}
+
+	for k, chartVersions := range indexFile.Entries {
+		newChartVersions := make([]*repo.ChartVersion, 0)
+
+		for _, chartVersion := range chartVersions {
+			if chartVersion != nil && !isHelmV2Chart(chartVersion.Metadata) {
+				newChartVersions = append(newChartVersions, chartVersion)
+			}
+		}
+
+		if len(newChartVersions) > 0 {
+			indexFile.Entries[k] = newChartVersions
+		} else {
+			delete(indexFile.Entries, k)
+		}
+	}
+
+	klog.V(4).Info("After apiVersion matching:", indexFile)
+}
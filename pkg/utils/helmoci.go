@@ -0,0 +1,171 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/repo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// OCIScheme prefixes the pathname of the Helm repo channels of the charts of an OCI registry
+const OCIScheme = "oci://"
+
+// IsOCIHelmRepo returns true if the pathname of the Helm repo channel is an OCI registry
+func IsOCIHelmRepo(pathname string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(pathname)), OCIScheme)
+}
+
+// parseOCIRef returns the reference of an oci:// URL, without scheme, and its registry host
+func parseOCIRef(ociURL string) (ref, host string) {
+	ref = strings.TrimSuffix(strings.TrimSpace(ociURL)[len(OCIScheme):], "/")
+
+	return ref, strings.SplitN(ref, "/", 2)[0]
+}
+
+// newOCIRegistryClient returns a registry client with the credentials of the channel secret: the .dockerconfigjson of
+// a docker-config secret, or the user and password of the registry host. The returned function removes the
+// credentials file of the client.
+func newOCIRegistryClient(host string, secret *corev1.Secret) (*registry.Client, func(), error) {
+	dir, err := ioutil.TempDir("", "helm-oci-")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			klog.Warning("failed to remove the OCI registry credentials, err: ", err)
+		}
+	}
+
+	// an empty config, the client doesn't fall back to the docker config of the controller
+	config := []byte("{}")
+
+	if secret != nil && secret.Data != nil {
+		if dockerConfig, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
+			config = dockerConfig
+		} else if user, ok := secret.Data["user"]; ok {
+			auth := base64.StdEncoding.EncodeToString([]byte(string(user) + ":" + string(secret.Data["password"])))
+
+			if config, err = json.Marshal(map[string]interface{}{
+				"auths": map[string]interface{}{host: map[string]string{"auth": auth}},
+			}); err != nil {
+				cleanup()
+
+				return nil, nil, err
+			}
+		}
+	}
+
+	credentialsFile := filepath.Join(dir, "config.json")
+
+	if err := ioutil.WriteFile(credentialsFile, config, 0600); err != nil {
+		cleanup()
+
+		return nil, nil, err
+	}
+
+	client, err := registry.NewClient(registry.ClientOptCredentialsFile(credentialsFile))
+	if err != nil {
+		cleanup()
+
+		return nil, nil, err
+	}
+
+	return client, cleanup, nil
+}
+
+// GetOCIHelmIndex returns the index of the versions of the charts in the OCI registry repository, the semver tags of
+// their repositories. The registries don't list their repositories, the charts are the ones subscribed by name.
+func GetOCIHelmIndex(repoURL string, secret *corev1.Secret, chartNames []string) (*repo.IndexFile, error) {
+	if len(chartNames) == 0 {
+		return nil, fmt.Errorf("the subscriptions of the OCI registry %v need the name of their chart in spec.name, "+
+			"the registries don't list their charts", repoURL)
+	}
+
+	ref, host := parseOCIRef(repoURL)
+
+	client, cleanup, err := newOCIRegistryClient(host, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cleanup()
+
+	indexFile := repo.NewIndexFile()
+
+	for _, name := range chartNames {
+		tags, err := client.Tags(ref + "/" + name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the versions of chart %v in the OCI registry %v, err: %w", name,
+				repoURL, err)
+		}
+
+		for _, tag := range tags {
+			indexFile.Entries[name] = append(indexFile.Entries[name], &repo.ChartVersion{
+				Metadata: &chart.Metadata{Name: name, Version: tag, APIVersion: chart.APIVersionV2},
+				URLs:     []string{OCIScheme + ref + "/" + name + ":" + tag},
+			})
+		}
+	}
+
+	indexFile.SortEntries()
+
+	return indexFile, nil
+}
+
+// PullOCIChart returns the chart archive of the oci://<registry>/<repository>/<chart>:<version> URL, and its provenance
+// file if withProv is true
+func PullOCIChart(chartURL string, secret *corev1.Secret, withProv bool) (chartData, provData []byte, err error) {
+	ref, host := parseOCIRef(chartURL)
+
+	client, cleanup, err := newOCIRegistryClient(host, secret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer cleanup()
+
+	result, err := client.Pull(ref, registry.PullOptWithChart(true), registry.PullOptWithProv(withProv))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to pull chart %v, err: %w", chartURL, err)
+	}
+
+	if withProv {
+		provData = result.Prov.Data
+	}
+
+	return result.Chart.Data, provData, nil
+}
+
+// OCIChartArchiveName returns the <chart>-<version>.tgz name of the chart archive of the
+// oci://<registry>/<repository>/<chart>:<version> URL
+func OCIChartArchiveName(chartURL string) string {
+	ref, _ := parseOCIRef(chartURL)
+
+	nameTag := ref[strings.LastIndex(ref, "/")+1:]
+
+	return strings.Replace(nameTag, ":", "-", 1) + ".tgz"
+}
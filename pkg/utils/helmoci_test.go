@@ -0,0 +1,81 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetOCIHelmIndex(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "reader" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="charts"`)
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		if r.URL.Path != "/v2/charts/nginx/tags/list" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"charts/nginx","tags":["1.0.0","latest","1.2.0_build.1","1.1.0"]}`))
+	}))
+	defer registry.Close()
+
+	host := strings.TrimPrefix(registry.URL, "http://")
+	repoURL := "oci://" + host + "/charts/"
+
+	g.Expect(IsOCIHelmRepo(repoURL)).To(gomega.BeTrue())
+	g.Expect(IsOCIHelmRepo("https://charts.example.com")).To(gomega.BeFalse())
+
+	_, err := GetOCIHelmIndex(repoURL, nil, nil)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("spec.name")))
+
+	_, err = GetOCIHelmIndex(repoURL, nil, []string{"nginx"})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	secrets := []*corev1.Secret{
+		{Data: map[string][]byte{"user": []byte("reader"), "password": []byte("secret")}},
+		{Type: corev1.SecretTypeDockerConfigJson, Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(
+			`{"auths":{"` + host + `":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("reader:secret")) + `"}}}`)}},
+	}
+
+	for _, secret := range secrets {
+		indexFile, err := GetOCIHelmIndex(repoURL, secret, []string{"nginx"})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		versions := []string{}
+		for _, chartVersion := range indexFile.Entries["nginx"] {
+			versions = append(versions, chartVersion.Version)
+		}
+
+		// the tags that are not versions are not charts
+		g.Expect(versions).To(gomega.Equal([]string{"1.2.0+build.1", "1.1.0", "1.0.0"}))
+		g.Expect(indexFile.Entries["nginx"][1].URLs).To(gomega.Equal([]string{"oci://" + host + "/charts/nginx:1.1.0"}))
+	}
+}
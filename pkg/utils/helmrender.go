@@ -0,0 +1,164 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/klog/v2"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// renderHelmCharts is true if the Helm charts of the Git subscriptions are templated by default, false if they are
+// deployed with HelmRelease CRs
+var renderHelmCharts = false
+
+// SetRenderHelmCharts sets how the Helm charts of the Git subscriptions without the helm-render annotation are
+// deployed, templated in the subscription controller if render is true, with HelmRelease CRs otherwise
+func SetRenderHelmCharts(render bool) {
+	if render {
+		klog.Info("Helm charts of the git subscriptions are templated locally by default")
+	}
+
+	renderHelmCharts = render
+}
+
+// IsHelmRenderLocal checks the Helm charts of the Git subscription are templated in the subscription controller
+// instead of deployed with HelmRelease CRs
+func IsHelmRenderLocal(sub *appv1.Subscription) bool {
+	switch strings.ToLower(strings.TrimSpace(sub.GetAnnotations()[appv1.AnnotationHelmRender])) {
+	case appv1.HelmRenderLocal:
+		return true
+	case appv1.HelmRenderHelmRelease:
+		return false
+	default:
+		return renderHelmCharts
+	}
+}
+
+// RenderHelmChart templates the chart of chartDir like helm template, and returns the rendered manifests in the
+// install order of Helm, the CRDs of the chart first. The hooks and the tests of the chart are not returned. The chart
+// is rendered in a resource limited subprocess if the render sandbox is enabled. It is only used by the local render,
+// the charts of the HelmRelease CRs are rendered by the HelmRelease manager.
+func RenderHelmChart(chartDir, releaseName, namespace string, values map[string]interface{}) ([]string, error) {
+	if helmRenderSandbox != nil {
+		return renderHelmChartInSandbox(chartDir, releaseName, namespace, values)
+	}
+
+	return renderHelmChart(chartDir, releaseName, namespace, values)
+}
+
+func renderHelmChart(chartDir, releaseName, namespace string, values map[string]interface{}) ([]string, error) {
+	chrt, err := loader.Load(chartDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %v: %w", chartDir, err)
+	}
+
+	if err := CheckHelmChartAPIVersion(chrt); err != nil {
+		return nil, err
+	}
+
+	if req := chrt.Metadata.Dependencies; req != nil {
+		if err := action.CheckDependencies(chrt, req); err != nil {
+			return nil, fmt.Errorf("failed to check the dependencies of chart %v: %w", chartDir, err)
+		}
+	}
+
+	install := action.NewInstall(&action.Configuration{
+		Log: func(format string, v ...interface{}) {
+			klog.V(2).Infof(format, v...)
+		},
+	})
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.ReleaseName = releaseName
+	install.Namespace = namespace
+
+	rel, err := install.Run(chrt, values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render chart %v: %w", chartDir, err)
+	}
+
+	manifests := []string{}
+
+	for _, crd := range chrt.CRDObjects() {
+		manifests = append(manifests, SplitManifests(crd.File.Data)...)
+	}
+
+	// the manifest of the release is in the install order
+	split := releaseutil.SplitManifests(rel.Manifest)
+	keys := make([]string, 0, len(split))
+
+	for key := range split {
+		keys = append(keys, key)
+	}
+
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	for _, key := range keys {
+		manifests = append(manifests, split[key])
+	}
+
+	return manifests, nil
+}
+
+// GetChartValuesFile returns the values file of the chart in chartDir, named by the helm-values-file annotation of the
+// subscription or values-override.yaml. The file must be within the repo root.
+func GetChartValuesFile(sub *appv1.Subscription, repoRoot, chartDir string) (string, error) {
+	name := strings.TrimSpace(sub.GetAnnotations()[appv1.AnnotationHelmValuesFile])
+	if name == "" {
+		name = appv1.DefaultHelmValuesFile
+	}
+
+	valuesFile := filepath.Join(chartDir, name)
+
+	if !isWithinDir(repoRoot, valuesFile) {
+		return "", fmt.Errorf("helm values file %v is out of the repository", name)
+	}
+
+	return valuesFile, nil
+}
+
+// MergeChartValuesFile merges the values of the values file under the values, the values win. The values are
+// returned as is if the file doesn't exist.
+func MergeChartValuesFile(valuesFile string, values map[string]interface{}) (map[string]interface{}, error) {
+	fileValues, err := chartutil.ReadValuesFile(valuesFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return values, nil
+		}
+
+		return nil, fmt.Errorf("failed to read helm values file %v: %w", filepath.Base(valuesFile), err)
+	}
+
+	klog.Infof("Merging helm values file %v", valuesFile)
+
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+
+	return chartutil.CoalesceTables(values, fileValues.AsMap()), nil
+}
@@ -0,0 +1,253 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// helmRenderSandboxEnv is set in the environment of the subprocess rendering a chart
+	helmRenderSandboxEnv = "HELM_RENDER_SANDBOX"
+	// helmRenderMemoryEnv and helmRenderCPUEnv are the limits of the subprocess, in MiB and CPU seconds
+	helmRenderMemoryEnv = "HELM_RENDER_SANDBOX_MEMORY_MB"
+	helmRenderCPUEnv    = "HELM_RENDER_SANDBOX_CPU_SECONDS"
+	// helmRenderV2ChartsEnv is "false" if the charts of apiVersion v1 are not rendered, like in the operator process
+	helmRenderV2ChartsEnv = "HELM_RENDER_SANDBOX_V2_CHARTS"
+
+	// maxHelmRenderOutput is the size of the rendered manifests read from the subprocess
+	maxHelmRenderOutput = 64 << 20
+	// maxHelmRenderStderr is the size of the subprocess error output kept for the error message
+	maxHelmRenderStderr = 4 << 10
+)
+
+// HelmRenderSandbox are the limits of the subprocesses rendering the Helm charts templated locally. The charts of the
+// HelmRelease CRs are rendered by the HelmRelease manager, in the operator process.
+type HelmRenderSandbox struct {
+	// Timeout is the wall clock time the subprocess is killed after
+	Timeout time.Duration
+	// MemoryMB is the address space limit of the subprocess, in MiB
+	MemoryMB int
+	// CPUSeconds is the CPU time limit of the subprocess, in seconds
+	CPUSeconds int
+}
+
+// helmRenderSandbox are the limits of the render subprocesses, the charts are rendered in the operator process if nil
+var helmRenderSandbox *HelmRenderSandbox
+
+// helmRenderCommand returns the command running the render subprocess, the operator binary itself
+var helmRenderCommand = func(ctx context.Context) (*exec.Cmd, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	return exec.CommandContext(ctx, self), nil // #nosec G204 the operator binary
+}
+
+// SetHelmRenderSandbox renders the Helm charts templated locally in subprocesses with the limits of sandbox, or in the
+// operator process if sandbox is nil. It doesn't apply to the charts deployed with HelmRelease CRs.
+func SetHelmRenderSandbox(sandbox *HelmRenderSandbox) {
+	if sandbox != nil {
+		klog.Infof("Helm charts templated locally are rendered in subprocesses, timeout: %v, memory: %vMiB, CPU: %vs",
+			sandbox.Timeout, sandbox.MemoryMB, sandbox.CPUSeconds)
+	}
+
+	helmRenderSandbox = sandbox
+}
+
+// helmRenderRequest is the chart to render, written to the stdin of the subprocess
+type helmRenderRequest struct {
+	ChartDir    string                 `json:"chartDir"`
+	ReleaseName string                 `json:"releaseName"`
+	Namespace   string                 `json:"namespace"`
+	Values      map[string]interface{} `json:"values,omitempty"`
+}
+
+// helmRenderResponse is the rendered chart, written to the stdout of the subprocess
+type helmRenderResponse struct {
+	Manifests []string `json:"manifests,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+func renderHelmChartInSandbox(chartDir, releaseName, namespace string, values map[string]interface{}) ([]string, error) {
+	sandbox := helmRenderSandbox
+
+	request, err := json.Marshal(helmRenderRequest{ChartDir: chartDir, ReleaseName: releaseName, Namespace: namespace, Values: values})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	if sandbox.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, sandbox.Timeout)
+		defer cancel()
+	}
+
+	cmd, err := helmRenderCommand(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the helm render subprocess: %w", err)
+	}
+
+	cmd.Env = append(os.Environ(),
+		helmRenderSandboxEnv+"=true",
+		helmRenderMemoryEnv+"="+strconv.Itoa(sandbox.MemoryMB),
+		helmRenderCPUEnv+"="+strconv.Itoa(sandbox.CPUSeconds),
+		helmRenderV2ChartsEnv+"="+strconv.FormatBool(helmV2Charts))
+	cmd.Stdin = bytes.NewReader(request)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stderr := &limitedBuffer{max: maxHelmRenderStderr}
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the helm render subprocess: %w", err)
+	}
+
+	output, readErr := ioutil.ReadAll(io.LimitReader(stdout, maxHelmRenderOutput+1))
+
+	if len(output) > maxHelmRenderOutput {
+		_ = cmd.Process.Kill()
+	}
+
+	waitErr := cmd.Wait()
+
+	switch {
+	case ctx.Err() != nil:
+		return nil, fmt.Errorf("rendering chart %v timed out after %v", chartDir, sandbox.Timeout)
+	case len(output) > maxHelmRenderOutput:
+		return nil, fmt.Errorf("the rendered manifests of chart %v exceed %d bytes", chartDir, maxHelmRenderOutput)
+	case readErr != nil:
+		return nil, readErr
+	}
+
+	response := helmRenderResponse{}
+
+	if err := json.Unmarshal(output, &response); err != nil {
+		if waitErr != nil {
+			return nil, fmt.Errorf("the helm render subprocess of chart %v failed: %v: %v", chartDir, waitErr,
+				strings.TrimSpace(stderr.String()))
+		}
+
+		return nil, fmt.Errorf("invalid output of the helm render subprocess of chart %v: %w", chartDir, err)
+	}
+
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	}
+
+	return response.Manifests, nil
+}
+
+// IsHelmRenderSandbox checks the process is a subprocess rendering a Helm chart
+func IsHelmRenderSandbox() bool {
+	return os.Getenv(helmRenderSandboxEnv) == "true"
+}
+
+// RunHelmRenderSandbox renders the chart read from stdin within the limits of the sandbox, writes the manifests to
+// stdout and exits. It is called first thing by the operator binary started as a render subprocess.
+func RunHelmRenderSandbox() {
+	response := runHelmRenderSandbox(os.Stdin)
+
+	if err := json.NewEncoder(os.Stdout).Encode(response); err != nil {
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+func runHelmRenderSandbox(stdin io.Reader) helmRenderResponse {
+	if err := setHelmRenderLimits(); err != nil {
+		return helmRenderResponse{Error: "failed to set the limits of the helm render subprocess: " + err.Error()}
+	}
+
+	// the subprocess has the Helm settings of the operator flags from its environment
+	if enabled, err := strconv.ParseBool(os.Getenv(helmRenderV2ChartsEnv)); err == nil {
+		helmV2Charts = enabled
+	}
+
+	request := helmRenderRequest{}
+
+	if err := json.NewDecoder(stdin).Decode(&request); err != nil {
+		return helmRenderResponse{Error: "invalid helm render request: " + err.Error()}
+	}
+
+	manifests, err := renderHelmChart(request.ChartDir, request.ReleaseName, request.Namespace, request.Values)
+	if err != nil {
+		return helmRenderResponse{Error: err.Error()}
+	}
+
+	return helmRenderResponse{Manifests: manifests}
+}
+
+// setHelmRenderLimits limits the address space and the CPU time of the process, from the limits of its environment
+func setHelmRenderLimits() error {
+	if memoryMB, _ := strconv.Atoi(os.Getenv(helmRenderMemoryEnv)); memoryMB > 0 {
+		limit := uint64(memoryMB) << 20
+
+		if err := syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: limit, Max: limit}); err != nil {
+			return err
+		}
+	}
+
+	if cpuSeconds, _ := strconv.Atoi(os.Getenv(helmRenderCPUEnv)); cpuSeconds > 0 {
+		limit := uint64(cpuSeconds)
+
+		if err := syscall.Setrlimit(syscall.RLIMIT_CPU, &syscall.Rlimit{Cur: limit, Max: limit}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// limitedBuffer keeps the first max bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+
+	return len(p), nil
+}
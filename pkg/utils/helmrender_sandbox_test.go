@@ -0,0 +1,92 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestHelmRenderSandbox(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defaultCommand := helmRenderCommand
+
+	defer func() {
+		helmRenderCommand = defaultCommand
+
+		SetHelmRenderSandbox(nil)
+	}()
+
+	// the subprocess is a shell script standing for the operator binary
+	script := ""
+	helmRenderCommand = func(ctx context.Context) (*exec.Cmd, error) {
+		return exec.CommandContext(ctx, "sh", "-c", script), nil
+	}
+
+	SetHelmRenderSandbox(&HelmRenderSandbox{Timeout: 500 * time.Millisecond, MemoryMB: 1024, CPUSeconds: 30})
+
+	SetHelmV2Charts(false)
+	defer SetHelmV2Charts(true)
+
+	script = `read request; [ "$HELM_RENDER_SANDBOX" = true ] && [ "$HELM_RENDER_SANDBOX_MEMORY_MB" = 1024 ] &&
+[ "$HELM_RENDER_SANDBOX_V2_CHARTS" = false ] && echo '{"manifests":["kind: ConfigMap"]}'`
+	manifests, err := RenderHelmChart("chart", "release", "ns", map[string]interface{}{"replicas": 2})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(manifests).To(gomega.Equal([]string{"kind: ConfigMap"}))
+
+	script = `cat > /dev/null; echo '{"error":"failed to render chart chart"}'`
+	_, err = RenderHelmChart("chart", "release", "ns", nil)
+	g.Expect(err).To(gomega.MatchError("failed to render chart chart"))
+
+	// a chart wedging the renderer is killed on the timeout
+	script = `exec sleep 10`
+	start := time.Now()
+	_, err = RenderHelmChart("chart", "release", "ns", nil)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("timed out")))
+	g.Expect(time.Since(start)).To(gomega.BeNumerically("<", 5*time.Second))
+
+	// a subprocess killed on a limit
+	script = `echo 'fatal error: runtime: out of memory' >&2; exit 2`
+	_, err = RenderHelmChart("chart", "release", "ns", nil)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("out of memory")))
+}
+
+func TestRunHelmRenderSandbox(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	response := runHelmRenderSandbox(strings.NewReader(`{"chartDir":"../../test/github/helmcharts/chart1","releaseName":"r","namespace":"ns"}`))
+	g.Expect(response.Error).To(gomega.BeEmpty())
+	g.Expect(response.Manifests).To(gomega.HaveLen(2))
+
+	response = runHelmRenderSandbox(strings.NewReader(`{"chartDir":"../../test/github/helmcharts/missing"}`))
+	g.Expect(response.Error).To(gomega.ContainSubstring("failed to load chart"))
+
+	response = runHelmRenderSandbox(strings.NewReader(`not a request`))
+	g.Expect(response.Error).To(gomega.ContainSubstring("invalid helm render request"))
+
+	// the apiVersion v1 charts are refused if the operator doesn't deploy them
+	defer SetHelmV2Charts(true)
+
+	t.Setenv(helmRenderV2ChartsEnv, "false")
+
+	response = runHelmRenderSandbox(strings.NewReader(`{"chartDir":"../../test/github/helmcharts/chart1","releaseName":"r","namespace":"ns"}`))
+	g.Expect(response.Error).To(gomega.ContainSubstring("only the apiVersion v2 charts are deployed"))
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+var renderedChartFiles = map[string]string{
+	"Chart.yaml": `apiVersion: v2
+name: greeter
+version: 0.1.0
+`,
+	"values.yaml": `greeting: hello
+`,
+	"crds/greeting.yaml": `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: greetings.example.com
+`,
+	"templates/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-greeting
+  namespace: {{ .Release.Namespace }}
+data:
+  greeting: {{ .Values.greeting }}
+`,
+	"templates/serviceaccount.yaml": `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: {{ .Release.Name }}
+`,
+	"templates/tests/test.yaml": `apiVersion: v1
+kind: Pod
+metadata:
+  name: {{ .Release.Name }}-test
+  annotations:
+    helm.sh/hook: test
+`,
+}
+
+func TestRenderHelmChart(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	chartDir, err := ioutil.TempDir("", "helmrender")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(chartDir)
+
+	for name, content := range renderedChartFiles {
+		g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(chartDir, name)), 0750)).To(gomega.Succeed())
+		g.Expect(ioutil.WriteFile(filepath.Join(chartDir, name), []byte(content), 0600)).To(gomega.Succeed())
+	}
+
+	manifests, err := RenderHelmChart(chartDir, "greeter-release", "apps", map[string]interface{}{"greeting": "hi"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	kinds := []string{}
+
+	for _, manifest := range manifests {
+		kinds = append(kinds, getManifestKind(g, manifest))
+	}
+
+	// the CRDs first, then the install order of Helm, without the test hook
+	g.Expect(kinds).To(gomega.Equal([]string{"CustomResourceDefinition", "ServiceAccount", "ConfigMap"}))
+	g.Expect(manifests[2]).To(gomega.ContainSubstring("name: greeter-release-greeting"))
+	g.Expect(manifests[2]).To(gomega.ContainSubstring("namespace: apps"))
+	g.Expect(manifests[2]).To(gomega.ContainSubstring("greeting: hi"))
+
+	_, err = RenderHelmChart(filepath.Join(chartDir, "missing"), "greeter-release", "apps", nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func getManifestKind(g *gomega.WithT, manifest string) string {
+	t := KubeResource{}
+	g.Expect(yaml.Unmarshal([]byte(manifest), &t)).To(gomega.Succeed())
+
+	return t.Kind
+}
+
+func TestIsHelmRenderLocal(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer SetRenderHelmCharts(false)
+
+	sub := &appv1.Subscription{}
+	g.Expect(IsHelmRenderLocal(sub)).To(gomega.BeFalse())
+
+	sub.ObjectMeta = metav1.ObjectMeta{Annotations: map[string]string{appv1.AnnotationHelmRender: "Local"}}
+	g.Expect(IsHelmRenderLocal(sub)).To(gomega.BeTrue())
+
+	SetRenderHelmCharts(true)
+	g.Expect(IsHelmRenderLocal(&appv1.Subscription{})).To(gomega.BeTrue())
+
+	sub.ObjectMeta = metav1.ObjectMeta{Annotations: map[string]string{appv1.AnnotationHelmRender: appv1.HelmRenderHelmRelease}}
+	g.Expect(IsHelmRenderLocal(sub)).To(gomega.BeFalse())
+}
+
+func TestChartValuesFile(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	repoRoot, err := ioutil.TempDir("", "helmvalues")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(repoRoot)
+
+	chartDir := filepath.Join(repoRoot, "charts", "greeter")
+	g.Expect(os.MkdirAll(chartDir, 0750)).To(gomega.Succeed())
+
+	sub := &appv1.Subscription{}
+
+	valuesFile, err := GetChartValuesFile(sub, repoRoot, chartDir)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(valuesFile).To(gomega.Equal(filepath.Join(chartDir, "values-override.yaml")))
+
+	// no values file
+	values, err := MergeChartValuesFile(valuesFile, map[string]interface{}{"greeting": "hi"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(values).To(gomega.Equal(map[string]interface{}{"greeting": "hi"}))
+
+	sub.ObjectMeta = metav1.ObjectMeta{Annotations: map[string]string{appv1.AnnotationHelmValuesFile: "../../values-prod.yaml"}}
+
+	valuesFile, err = GetChartValuesFile(sub, repoRoot, chartDir)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(valuesFile).To(gomega.Equal(filepath.Join(repoRoot, "values-prod.yaml")))
+
+	sub.ObjectMeta = metav1.ObjectMeta{Annotations: map[string]string{appv1.AnnotationHelmValuesFile: "../../../etc/passwd"}}
+
+	_, err = GetChartValuesFile(sub, repoRoot, chartDir)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	g.Expect(ioutil.WriteFile(filepath.Join(chartDir, "values-override.yaml"),
+		[]byte("greeting: hello\nimage:\n  repository: greeter\n  tag: v1\n"), 0600)).To(gomega.Succeed())
+
+	// the values of the subscription win over the values file
+	values, err = MergeChartValuesFile(filepath.Join(chartDir, "values-override.yaml"),
+		map[string]interface{}{"greeting": "hi", "image": map[string]interface{}{"tag": "v2"}})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(values).To(gomega.Equal(map[string]interface{}{
+		"greeting": "hi",
+		"image":    map[string]interface{}{"repository": "greeter", "tag": "v2"},
+	}))
+
+	g.Expect(ioutil.WriteFile(filepath.Join(chartDir, "values-override.yaml"), []byte("greeting: [hello"), 0600)).To(gomega.Succeed())
+
+	_, err = MergeChartValuesFile(filepath.Join(chartDir, "values-override.yaml"), nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
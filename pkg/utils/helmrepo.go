@@ -21,6 +21,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	semver "github.com/Masterminds/semver/v3"
@@ -33,6 +34,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -61,7 +63,7 @@ func GenerateHelmIndexFile(sub *appv1.Subscription, repoRoot string, chartDirs m
 	// Build a helm repo index file
 	indexFile := repo.NewIndexFile()
 
-	for chartDir := range chartDirs {
+	for _, chartDir := range SortedDirs(chartDirs) {
 		chartDir = strings.TrimSuffix(chartDir, "/")
 		// chartFolderName is chart folder name
 		chartFolderName := filepath.Base(chartDir)
@@ -96,6 +98,20 @@ func GenerateHelmIndexFile(sub *appv1.Subscription, repoRoot string, chartDirs m
 	return indexFile, nil
 }
 
+// SortedChartNames returns the chart names of the index file in lexical order, so the charts are processed in the
+// same order on every reconcile
+func SortedChartNames(indexFile *repo.IndexFile) []string {
+	names := make([]string, 0, len(indexFile.Entries))
+
+	for name := range indexFile.Entries {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
 func createSource(channel *chnv1.Channel, chartVersions repo.ChartVersions, sub *appv1.Subscription, packageName string) (*releasev1.Source, error) {
 	var source *releasev1.Source
 
@@ -119,6 +135,12 @@ func createSource(channel *chnv1.Channel, chartVersions repo.ChartVersions, sub
 			}
 		}
 
+		// the chart URLs of the index can reference other hosts than the channel
+		validURLs, err := allowedSourceURLs(validURLs)
+		if err != nil {
+			return nil, fmt.Errorf("no allowed URLs are found for package %s: %w", packageName, err)
+		}
+
 		if len(validURLs) == 0 {
 			return nil, fmt.Errorf("no valid URLs are found for package: %s", packageName)
 		}
@@ -157,6 +179,12 @@ func createAltSource(channel *chnv1.Channel, chartVersions repo.ChartVersions, s
 			}
 		}
 
+		// the chart URLs of the index can reference other hosts than the channel
+		validURLs, err := allowedSourceURLs(validURLs)
+		if err != nil {
+			return nil, fmt.Errorf("no allowed URLs are found for package %s: %w", packageName, err)
+		}
+
 		if len(validURLs) == 0 {
 			return nil, fmt.Errorf("no valid URLs are found for package: %s", packageName)
 		}
@@ -172,6 +200,8 @@ func createAltSource(channel *chnv1.Channel, chartVersions repo.ChartVersions, s
 	return altSource, nil
 }
 
+// CreateOrUpdateHelmChart returns the HelmRelease of the chart in the namespace of the helm-release-namespace
+// annotation of the subscription, it is not authorized like by CreateHelmCRManifest
 func CreateOrUpdateHelmChart(
 	packageName string,
 	releaseCRName string,
@@ -180,6 +210,24 @@ func CreateOrUpdateHelmChart(
 	channel *chnv1.Channel,
 	secondaryChannel *chnv1.Channel,
 	sub *appv1.Subscription) (helmRelease *releasev1.HelmRelease, err error) {
+	namespace, err := GetHelmReleaseNamespace(sub, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	return createOrUpdateHelmChart(packageName, releaseCRName, chartVersions, client, channel, secondaryChannel, sub, namespace)
+}
+
+// createOrUpdateHelmChart returns the HelmRelease of the chart in namespace, the existing one updated
+func createOrUpdateHelmChart(
+	packageName string,
+	releaseCRName string,
+	chartVersions repo.ChartVersions,
+	client client.Client,
+	channel *chnv1.Channel,
+	secondaryChannel *chnv1.Channel,
+	sub *appv1.Subscription,
+	namespace string) (helmRelease *releasev1.HelmRelease, err error) {
 	helmRelease = &releasev1.HelmRelease{}
 
 	source, err := createSource(channel, chartVersions, sub, packageName)
@@ -221,7 +269,7 @@ func CreateOrUpdateHelmChart(
 	}
 
 	err = client.Get(context.TODO(),
-		types.NamespacedName{Name: releaseCRName, Namespace: sub.Namespace}, helmRelease)
+		types.NamespacedName{Name: releaseCRName, Namespace: namespace}, helmRelease)
 
 	if err != nil {
 		if errors.IsNotFound(err) {
@@ -245,13 +293,7 @@ func CreateOrUpdateHelmChart(
 				},
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      releaseCRName,
-					Namespace: sub.Namespace,
-					OwnerReferences: []metav1.OwnerReference{{
-						APIVersion: "apps.open-cluster-management.io/v1",
-						Kind:       "Subscription",
-						Name:       sub.Name,
-						UID:        sub.UID,
-					}},
+					Namespace: namespace,
 				},
 				Repo: releasev1.HelmReleaseRepo{
 					Source:                        source,
@@ -265,6 +307,17 @@ func CreateOrUpdateHelmChart(
 					WatchNamespaceScopedResources: sub.Spec.WatchHelmNamespaceScopedResources,
 				},
 			}
+
+			// the owner references across namespaces are invalid, the HelmReleases of another namespace are deleted
+			// with the subscription by the synchronizer
+			if namespace == sub.Namespace {
+				helmRelease.OwnerReferences = []metav1.OwnerReference{{
+					APIVersion: "apps.open-cluster-management.io/v1",
+					Kind:       "Subscription",
+					Name:       sub.Name,
+					UID:        sub.UID,
+				}}
+			}
 		} else {
 			klog.Error("Error in getting existing helm release", err)
 			return nil, err
@@ -302,50 +355,76 @@ func CreateOrUpdateHelmChart(
 		}
 	}
 
+	if err := MergeValuesFrom(client, helmRelease, sub); err != nil {
+		klog.Error("Failed to merge the values of helmrelease ", helmRelease.Name, " err:", err)
+
+		return nil, err
+	}
+
 	return helmRelease, nil
 }
 
+// Override applies the package overrides of the subscription to the HelmRelease of its package. The HelmRelease is
+// overridden as JSON, its numbers decoded as int64 or float64 like the unstructured objects, so the integers, the
+// floats, the booleans, the strings and the nulls of the chart values keep their type and their precision.
 func Override(helmRelease *releasev1.HelmRelease, sub *appv1.Subscription) error {
 	//Overrides with the values provided in the subscription for that package
 	overrides := getOverrides(helmRelease.Repo.ChartName, sub)
-	data, err := yaml.Marshal(helmRelease)
+	if len(overrides.ClusterOverrides) == 0 {
+		return nil
+	}
 
+	data, err := json.Marshal(helmRelease)
 	if err != nil {
 		klog.Error("Failed to mashall ", helmRelease.Name, " err:", err)
 
 		return err
 	}
 
-	template := &unstructured.Unstructured{}
-	err = yaml.Unmarshal(data, template)
+	template := map[string]interface{}{}
+	if err := utiljson.Unmarshal(data, &template); err != nil {
+		klog.Error("Failed to unmashall ", helmRelease.Name, " err:", err)
 
-	if err != nil {
-		klog.Warning("Error while processing helmrelease with template:", helmRelease.Name, err)
+		return err
 	}
 
-	template, err = OverrideTemplate(template, overrides.ClusterOverrides)
+	for _, override := range overrides.ClusterOverrides {
+		ovuobj := map[string]interface{}{}
+		if err := utiljson.Unmarshal(override.Raw, &ovuobj); err != nil {
+			return fmt.Errorf("can not parse override of package %v, err: %w", helmRelease.Repo.ChartName, err)
+		}
 
-	if err != nil {
-		klog.Error("Failed to apply override for instance: ", helmRelease.Name, err)
+		path, ok := ovuobj["path"].(string)
+		if !ok {
+			return fmt.Errorf("can not convert path of override of package %v", helmRelease.Repo.ChartName)
+		}
 
-		return err
+		if err := unstructured.SetNestedField(template, ovuobj["value"], strings.Split(path, ".")...); err != nil {
+			klog.Error("Failed to set nested field for overriding helmrelease with error:", err)
+		}
 	}
 
-	data, err = yaml.Marshal(template)
+	// the spec is set as it is, decoding it back in the HelmRelease would turn its numbers into float64
+	spec := template["spec"]
+	delete(template, "spec")
 
+	data, err = json.Marshal(template)
 	if err != nil {
 		klog.Error("Failed to mashall ", helmRelease.Name, " err:", err)
 
 		return err
 	}
 
-	err = yaml.Unmarshal(data, helmRelease)
-
-	if err != nil {
+	overridden := &releasev1.HelmRelease{}
+	if err := json.Unmarshal(data, overridden); err != nil {
 		klog.Error("Failed to unmashall ", helmRelease.Name, " err:", err)
+
 		return err
 	}
 
+	overridden.Spec = spec
+	*helmRelease = *overridden
+
 	return nil
 }
 
@@ -386,12 +465,18 @@ func CreateHelmCRManifest(
 	channel *chnv1.Channel,
 	secondaryChannel *chnv1.Channel,
 	sub *appv1.Subscription,
-	clusterAdmin bool) (*unstructured.Unstructured, error) {
+	clusterAdmin bool,
+	canDeploy func(*unstructured.Unstructured) bool) (*unstructured.Unstructured, error) {
 	releaseCRName, err := PkgToReleaseCRName(sub, packageName)
 	if err != nil {
 		return nil, err
 	}
 
+	namespace, err := ResolveHelmReleaseNamespace(client, sub, channel, clusterAdmin, canDeploy)
+	if err != nil {
+		return nil, err
+	}
+
 	if channel == nil || !IsGitChannel(string(channel.Spec.Type)) {
 		for i := range chartVersions[0].URLs {
 			parsedURL, err := url.Parse(chartVersions[0].URLs[i])
@@ -409,8 +494,8 @@ func CreateHelmCRManifest(
 		}
 	}
 
-	helmRelease, err := CreateOrUpdateHelmChart(
-		packageName, releaseCRName, chartVersions, client, channel, secondaryChannel, sub)
+	helmRelease, err := createOrUpdateHelmChart(
+		packageName, releaseCRName, chartVersions, client, channel, secondaryChannel, sub, namespace)
 
 	if err != nil {
 		klog.Error("Failed to create or update helm chart ", packageName, " err:", err)
@@ -426,6 +511,8 @@ func CreateHelmCRManifest(
 		return nil, err
 	}
 
+	SubstituteChannelVariables(helmRelease, sub, channel)
+
 	if helmRelease.Spec == nil {
 		spec := make(map[string]interface{})
 
@@ -502,39 +589,41 @@ func getOverrides(packageName string, sub *appv1.Subscription) appv1.ClusterOver
 	return dploverrides
 }
 
-//FilterCharts filters the indexFile by name, version, digest
+//FilterCharts filters the indexFile by name, version, digest and package filter plugins
 func FilterCharts(sub *appv1.Subscription, indexFile *repo.IndexFile) error {
 	//Removes all entries from the indexFile with non matching name
-	err := removeNoMatchingName(sub, indexFile)
-	if err != nil {
-		klog.Warning(err)
-	}
+	removeNoMatchingName(sub, indexFile)
+	//Removes the apiVersion v1 charts of Helm 2 if they are not deployed
+	removeHelmV2Charts(indexFile)
 	//Removes non matching version, digest
 	filterOnVersion(sub, indexFile)
-	//Keep only the lastest version if multiple remains after filtering.
-	err = takeLatestVersion(indexFile)
+	//Keep only the highest version if multiple remain after filtering.
+	err := takeLatestVersion(sub, indexFile)
 	if err != nil {
 		klog.Error("Failed to filter on version with error: ", err)
 		return err
 	}
+	//Removes the charts excluded by the package filter plugins
+	err = filterChartsOnPlugins(sub, indexFile)
+	if err != nil {
+		klog.Error("Failed to filter on package filter plugins with error: ", err)
+		return err
+	}
 
 	return nil
 }
 
 //takeLatestVersion if the indexFile contains multiple versions for a given chart, then
-//only the latest is kept.
-func takeLatestVersion(indexFile *repo.IndexFile) (err error) {
+//only the highest semver version is kept.
+func takeLatestVersion(sub *appv1.Subscription, indexFile *repo.IndexFile) (err error) {
 	indexFile.SortEntries()
 
-	for k := range indexFile.Entries {
-		//Get return the latest version when version is empty but
-		//there is a bug in the masterminds semver used by helm
-		// "*" constraint is not working properly
-		// "*" is equivalent to ">=0.0.0"
-		chartVersion, err := indexFile.Get(k, ">=0.0.0")
-		if err != nil {
-			klog.Error(err)
-			return err
+	pinned := sub.Spec.PackageFilter != nil && sub.Spec.PackageFilter.Version != ""
+
+	for k, chartVersions := range indexFile.Entries {
+		chartVersion := latestChartVersion(chartVersions, pinned)
+		if chartVersion == nil {
+			return fmt.Errorf("no valid version of chart %v", k)
 		}
 
 		indexFile.Entries[k] = []*repo.ChartVersion{chartVersion}
@@ -543,6 +632,45 @@ func takeLatestVersion(indexFile *repo.IndexFile) (err error) {
 	return nil
 }
 
+//latestChartVersion returns the highest semver version of the chart. The pre-release versions are taken only if
+//there is no release version, or if they matched the version filter of the subscription.
+func latestChartVersion(chartVersions repo.ChartVersions, pinned bool) *repo.ChartVersion {
+	var latest, latestPrerelease *repo.ChartVersion
+
+	var latestVersion, latestPrereleaseVersion *semver.Version
+
+	for _, chartVersion := range chartVersions {
+		if chartVersion == nil || chartVersion.Metadata == nil {
+			continue
+		}
+
+		version, err := semver.NewVersion(chartVersion.Version)
+		if err != nil {
+			klog.Warningf("Skipping version %q of chart %v, err: %v", chartVersion.Version, chartVersion.Name, err)
+
+			continue
+		}
+
+		if version.Prerelease() != "" && !pinned {
+			if latestPrereleaseVersion == nil || version.GreaterThan(latestPrereleaseVersion) {
+				latestPrerelease, latestPrereleaseVersion = chartVersion, version
+			}
+
+			continue
+		}
+
+		if latestVersion == nil || version.GreaterThan(latestVersion) {
+			latest, latestVersion = chartVersion, version
+		}
+	}
+
+	if latest == nil {
+		return latestPrerelease
+	}
+
+	return latest
+}
+
 //checkDigest Checks if the digest matches
 func checkDigest(sub *appv1.Subscription, chartVersion *repo.ChartVersion) bool {
 	if sub != nil {
@@ -560,26 +688,16 @@ func checkDigest(sub *appv1.Subscription, chartVersion *repo.ChartVersion) bool
 	return true
 }
 
-//removeNoMatchingName Deletes entries that the name doesn't match the name provided in the subscription
-func removeNoMatchingName(sub *appv1.Subscription, indexFile *repo.IndexFile) error {
-	if sub.Spec.Package != "" {
-		keys := make([]string, 0)
-		for k := range indexFile.Entries {
-			keys = append(keys, k)
-		}
-
-		for _, k := range keys {
-			if k != sub.Spec.Package {
-				delete(indexFile.Entries, k)
-			}
+//removeNoMatchingName Deletes entries that the name doesn't match the names provided in the subscription, all the
+//entries are kept if the subscription has no package name
+func removeNoMatchingName(sub *appv1.Subscription, indexFile *repo.IndexFile) {
+	for k := range indexFile.Entries {
+		if !IsSubscribedPackage(sub, k) {
+			delete(indexFile.Entries, k)
 		}
-	} else {
-		return fmt.Errorf("subsciption.spec.package is missing for subscription: %s/%s", sub.Namespace, sub.Name)
 	}
 
 	klog.V(4).Info("After name matching:", indexFile)
-
-	return nil
 }
 
 //filterOnVersion filters the indexFile with the version, and Digest provided in the subscription
@@ -683,3 +801,98 @@ func IsURL(str string) bool {
 	u, err := url.Parse(str)
 	return err == nil && u.Scheme != "" && u.Host != ""
 }
+
+// GetSubscriptionHelmRepoURL returns the URL of the Helm repo of the subscription, the repo URL of the channel scoped to
+// the project path of the helm-repo-project annotation of the subscription if any
+func GetSubscriptionHelmRepoURL(sub *appv1.Subscription, repoURL string) string {
+	project := strings.Trim(strings.TrimSpace(sub.GetAnnotations()[appv1.AnnotationHelmRepoProject]), "/")
+	if project == "" {
+		return repoURL
+	}
+
+	return strings.TrimSuffix(repoURL, "/") + "/" + project
+}
+
+// GetChannelHelmRepoURLs returns the URLs of the Helm repos of the channel by precedence: its pathname, then the URLs of
+// its helm-repo-urls annotation
+func GetChannelHelmRepoURLs(chn *chnv1.Channel) []string {
+	repoURLs := []string{chn.Spec.Pathname}
+	seen := map[string]bool{chn.Spec.Pathname: true}
+
+	for _, repoURL := range strings.Split(chn.GetAnnotations()[appv1.AnnotationHelmRepoURLs], ",") {
+		repoURL = strings.TrimSpace(repoURL)
+		if repoURL == "" || seen[repoURL] {
+			continue
+		}
+
+		seen[repoURL] = true
+		repoURLs = append(repoURLs, repoURL)
+	}
+
+	return repoURLs
+}
+
+// GetChannelHelmRepoPrecedence returns how the indexes of the Helm repos of the channel are merged, version by default
+func GetChannelHelmRepoPrecedence(chn *chnv1.Channel) string {
+	if strings.EqualFold(strings.TrimSpace(chn.GetAnnotations()[appv1.AnnotationHelmRepoPrecedence]), appv1.HelmRepoPrecedenceOrder) {
+		return appv1.HelmRepoPrecedenceOrder
+	}
+
+	return appv1.HelmRepoPrecedenceVersion
+}
+
+// MergeHelmRepoIndexes merges the filtered indexes of the Helm repos of a channel, in precedence order. With the
+// version precedence, a chart version in several indexes is taken from the first one and the highest version of the
+// chart across the indexes is kept. With the order precedence, a chart is taken from the first index that has it.
+func MergeHelmRepoIndexes(sub *appv1.Subscription, indexFiles []*repo.IndexFile, precedence string) (*repo.IndexFile, error) {
+	merged := repo.NewIndexFile()
+
+	for _, indexFile := range indexFiles {
+		for name, chartVersions := range indexFile.Entries {
+			if _, ok := merged.Entries[name]; ok && precedence == appv1.HelmRepoPrecedenceOrder {
+				continue
+			}
+
+			for _, chartVersion := range chartVersions {
+				if chartVersion == nil || merged.Has(name, chartVersion.Version) {
+					continue
+				}
+
+				merged.Entries[name] = append(merged.Entries[name], chartVersion)
+			}
+		}
+	}
+
+	if err := takeLatestVersion(sub, merged); err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+// ResolveHelmChartURLs makes the relative chart URLs of the index absolute: the paths are relative to the repo URL, and
+// the absolute paths to its host
+func ResolveHelmChartURLs(indexFile *repo.IndexFile, repoURL string) {
+	base, err := url.Parse(repoURL)
+	if err != nil {
+		klog.Error("Failed to parse the Helm repo URL ", repoURL, " err:", err)
+
+		return
+	}
+
+	for _, chartVersions := range indexFile.Entries {
+		for _, chartVersion := range chartVersions {
+			for i, chartURL := range chartVersion.URLs {
+				if IsURL(chartURL) {
+					continue
+				}
+
+				if strings.HasPrefix(chartURL, "/") {
+					chartVersion.URLs[i] = base.ResolveReference(&url.URL{Path: chartURL}).String()
+				} else {
+					chartVersion.URLs[i] = strings.TrimSuffix(repoURL, "/") + "/" + chartURL
+				}
+			}
+		}
+	}
+}
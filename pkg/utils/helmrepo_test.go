@@ -16,11 +16,17 @@ package utils
 
 import (
 	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/repo"
 	corev1 "k8s.io/api/core/v1"
 	clientsetx "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
@@ -279,6 +285,129 @@ func TestCheckVersion(t *testing.T) {
 	g.Expect(ret).To(gomega.BeTrue())
 }
 
+func TestFilterChartsLatestVersion(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	newIndexFile := func(versions ...string) *repo.IndexFile {
+		indexFile := repo.NewIndexFile()
+
+		for _, version := range versions {
+			indexFile.Entries["app"] = append(indexFile.Entries["app"],
+				&repo.ChartVersion{Metadata: &chart.Metadata{Name: "app", Version: version}})
+		}
+
+		return indexFile
+	}
+
+	sub := &appv1.Subscription{Spec: appv1.SubscriptionSpec{Package: "app"}}
+
+	// the highest semver version, not the first or the lexically highest one
+	indexFile := newIndexFile("1.9.0", "1.10.0", "1.2.0")
+	g.Expect(FilterCharts(sub, indexFile)).To(gomega.Succeed())
+	g.Expect(indexFile.Entries["app"]).To(gomega.HaveLen(1))
+	g.Expect(indexFile.Entries["app"][0].Version).To(gomega.Equal("1.10.0"))
+
+	// the pre-release versions are taken only without a release version
+	indexFile = newIndexFile("1.10.0", "2.0.0-rc.1")
+	g.Expect(FilterCharts(sub, indexFile)).To(gomega.Succeed())
+	g.Expect(indexFile.Entries["app"][0].Version).To(gomega.Equal("1.10.0"))
+
+	indexFile = newIndexFile("2.0.0-rc.1", "2.0.0-rc.2")
+	g.Expect(FilterCharts(sub, indexFile)).To(gomega.Succeed())
+	g.Expect(indexFile.Entries["app"][0].Version).To(gomega.Equal("2.0.0-rc.2"))
+
+	// a version pin of the package filter
+	sub.Spec.PackageFilter = &appv1.PackageFilter{Version: "2.0.0-rc.1"}
+
+	indexFile = newIndexFile("1.10.0", "2.0.0-rc.1", "2.0.0-rc.2")
+	g.Expect(FilterCharts(sub, indexFile)).To(gomega.Succeed())
+	g.Expect(indexFile.Entries["app"][0].Version).To(gomega.Equal("2.0.0-rc.1"))
+
+	sub.Spec.PackageFilter = &appv1.PackageFilter{Version: "~1.9"}
+
+	indexFile = newIndexFile("1.9.0", "1.10.0", "1.9.3")
+	g.Expect(FilterCharts(sub, indexFile)).To(gomega.Succeed())
+	g.Expect(indexFile.Entries["app"][0].Version).To(gomega.Equal("1.9.3"))
+}
+
+func TestFilterChartsPackageList(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	newIndexFile := func() *repo.IndexFile {
+		indexFile := repo.NewIndexFile()
+
+		for _, name := range []string{"app", "db", "cache"} {
+			indexFile.Entries[name] = repo.ChartVersions{
+				&repo.ChartVersion{Metadata: &chart.Metadata{Name: name, Version: "1.0.0"}}}
+		}
+
+		return indexFile
+	}
+
+	sub := &appv1.Subscription{Spec: appv1.SubscriptionSpec{Package: "app, db"}}
+
+	indexFile := newIndexFile()
+	g.Expect(FilterCharts(sub, indexFile)).To(gomega.Succeed())
+	g.Expect(indexFile.Entries).To(gomega.HaveLen(2))
+	g.Expect(indexFile.Entries).To(gomega.HaveKey("app"))
+	g.Expect(indexFile.Entries).To(gomega.HaveKey("db"))
+
+	// all the charts without a package name
+	sub.Spec.Package = ""
+
+	indexFile = newIndexFile()
+	g.Expect(FilterCharts(sub, indexFile)).To(gomega.Succeed())
+	g.Expect(indexFile.Entries).To(gomega.HaveLen(3))
+
+	g.Expect(IsSubscribedPackage(sub, "cache")).To(gomega.BeTrue())
+
+	sub.Spec.Package = "app,,db"
+	g.Expect(GetSubscriptionPackages(sub)).To(gomega.Equal([]string{"app", "db"}))
+	g.Expect(IsSubscribedPackage(sub, "cache")).To(gomega.BeFalse())
+}
+
+func TestFilterChartsHelmV2(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	newIndexFile := func() *repo.IndexFile {
+		indexFile := repo.NewIndexFile()
+		indexFile.Entries["app"] = repo.ChartVersions{
+			&repo.ChartVersion{Metadata: &chart.Metadata{Name: "app", Version: "1.0.0", APIVersion: chart.APIVersionV1}},
+			&repo.ChartVersion{Metadata: &chart.Metadata{Name: "app", Version: "2.0.0", APIVersion: chart.APIVersionV2}},
+			&repo.ChartVersion{Metadata: &chart.Metadata{Name: "app", Version: "3.0.0"}},
+		}
+		indexFile.Entries["legacy"] = repo.ChartVersions{
+			&repo.ChartVersion{Metadata: &chart.Metadata{Name: "legacy", Version: "1.0.0", APIVersion: chart.APIVersionV1}},
+		}
+
+		return indexFile
+	}
+
+	sub := &appv1.Subscription{}
+
+	// the v1 charts are deployed by default
+	indexFile := newIndexFile()
+	g.Expect(FilterCharts(sub, indexFile)).To(gomega.Succeed())
+	g.Expect(indexFile.Entries).To(gomega.HaveLen(2))
+	g.Expect(indexFile.Entries["app"][0].Version).To(gomega.Equal("3.0.0"))
+
+	legacy := &chart.Chart{Metadata: &chart.Metadata{Name: "legacy", APIVersion: chart.APIVersionV1}}
+	g.Expect(CheckHelmChartAPIVersion(legacy)).To(gomega.Succeed())
+
+	SetHelmV2Charts(false)
+	defer SetHelmV2Charts(true)
+
+	// the charts without apiVersion are v1 charts
+	indexFile = newIndexFile()
+	g.Expect(FilterCharts(sub, indexFile)).To(gomega.Succeed())
+	g.Expect(indexFile.Entries).To(gomega.HaveLen(1))
+	g.Expect(indexFile.Entries["app"][0].Version).To(gomega.Equal("2.0.0"))
+
+	g.Expect(CheckHelmChartAPIVersion(legacy)).NotTo(gomega.Succeed())
+	g.Expect(CheckHelmChartAPIVersion(&chart.Chart{Metadata: &chart.Metadata{Name: "app",
+		APIVersion: chart.APIVersionV2}})).To(gomega.Succeed())
+}
+
 func TestOverride(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 
@@ -374,14 +503,14 @@ func TestCreateHelmCRManifest(t *testing.T) {
 
 	githubsub.UID = "dummyuid"
 
-	dpl, err := CreateHelmCRManifest("../..", "chart1", indexFile.Entries["chart1"], c, githubchn, nil, githubsub, true)
+	dpl, err := CreateHelmCRManifest("../..", "chart1", indexFile.Entries["chart1"], c, githubchn, nil, githubsub, true, nil)
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(dpl).NotTo(gomega.BeNil())
 
 	dplName1 := dpl.GetName()
 
 	githubchn.Spec.Type = chnv1.ChannelTypeHelmRepo
-	dpl, err = CreateHelmCRManifest("../..", "chart1", indexFile.Entries["chart1"], c, githubchn, nil, githubsub, true)
+	dpl, err = CreateHelmCRManifest("../..", "chart1", indexFile.Entries["chart1"], c, githubchn, nil, githubsub, true, nil)
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(dpl).NotTo(gomega.BeNil())
 
@@ -397,7 +526,7 @@ func TestCreateHelmCRManifest(t *testing.T) {
 
 	time.Sleep(3 * time.Second)
 
-	dpl, err = CreateHelmCRManifest("../..", "chart1", indexFile.Entries["chart1"], c, githubchn, nil, githubsub, true)
+	dpl, err = CreateHelmCRManifest("../..", "chart1", indexFile.Entries["chart1"], c, githubchn, nil, githubsub, true, nil)
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(dpl).NotTo(gomega.BeNil())
 
@@ -450,3 +579,123 @@ func TestIsURL(t *testing.T) {
 	g.Expect(IsURL("https://charts.helm.sh/stable/packages/nginx-ingress-1.40.1.tgz")).To(gomega.BeTrue())
 	g.Expect(IsURL("nginx-ingress-1.40.1.tgz")).To(gomega.BeFalse())
 }
+
+func TestSortedDirsAndChartNames(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dirs := map[string]string{
+		"/repo/charts/nginx/": "/repo/charts/nginx/",
+		"/repo/base/":         "/repo/base/",
+		"/repo/charts/etcd/":  "/repo/charts/etcd/",
+	}
+
+	g.Expect(SortedDirs(dirs)).To(gomega.Equal([]string{"/repo/base/", "/repo/charts/etcd/", "/repo/charts/nginx/"}))
+
+	indexFile := repo.NewIndexFile()
+	indexFile.Entries["nginx"] = repo.ChartVersions{}
+	indexFile.Entries["etcd"] = repo.ChartVersions{}
+	indexFile.Entries["redis"] = repo.ChartVersions{}
+
+	g.Expect(SortedChartNames(indexFile)).To(gomega.Equal([]string{"etcd", "nginx", "redis"}))
+}
+
+func TestChartHelmIgnore(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	repoRoot, err := ioutil.TempDir("", "helmignore")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(repoRoot)
+
+	chartDir := filepath.Join(repoRoot, "charts", "nginx")
+
+	files := map[string]string{
+		"Chart.yaml":              "apiVersion: v2\nname: nginx\nversion: 0.1.0\n",
+		".helmignore":             "tests/\n*.md\n",
+		"README.md":               "# nginx\n",
+		"templates/cm.yaml":       "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: nginx\n",
+		"tests/fixtures/cm.yaml":  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: fixture\n",
+		"tests/fixtures/data.txt": "fixture\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(chartDir, name)
+		g.Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(gomega.Succeed())
+		g.Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(gomega.Succeed())
+	}
+
+	// the chart files, ignored or not, are not subscribed as Kubernetes resources
+	chartDirs, _, crdsAndNamespaceFiles, rbacFiles, otherFiles, err := SortResources(repoRoot, repoRoot)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(chartDirs).To(gomega.HaveKey(chartDir + "/"))
+	g.Expect(crdsAndNamespaceFiles).To(gomega.BeEmpty())
+	g.Expect(rbacFiles).To(gomega.BeEmpty())
+	g.Expect(otherFiles).To(gomega.BeEmpty())
+
+	// the chart rendered by the HelmRelease is loaded like helm package does, without the .helmignore files
+	chart, err := loader.LoadDir(chartDir)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(chart.Templates).To(gomega.HaveLen(1))
+
+	for _, f := range chart.Files {
+		g.Expect(f.Name).NotTo(gomega.HavePrefix("tests/"))
+		g.Expect(f.Name).NotTo(gomega.Equal("README.md"))
+	}
+}
+
+func TestResolveHelmChartURLs(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	indexFile := &repo.IndexFile{Entries: map[string]repo.ChartVersions{
+		"nginx": {{URLs: []string{"charts/nginx-1.0.0.tgz", "/chartrepo/library/charts/nginx-1.0.0.tgz",
+			"https://mirror.example.com/nginx-1.0.0.tgz"}}},
+	}}
+
+	ResolveHelmChartURLs(indexFile, "https://harbor.example.com/chartrepo/team-a/")
+	g.Expect(indexFile.Entries["nginx"][0].URLs).To(gomega.Equal([]string{
+		"https://harbor.example.com/chartrepo/team-a/charts/nginx-1.0.0.tgz",
+		"https://harbor.example.com/chartrepo/library/charts/nginx-1.0.0.tgz",
+		"https://mirror.example.com/nginx-1.0.0.tgz",
+	}))
+}
+
+func TestOverrideValueTypes(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	helmRelease := &releasev1.HelmRelease{
+		Repo: releasev1.HelmReleaseRepo{ChartName: "nginx"},
+		Spec: map[string]interface{}{"replicas": int64(3), "ratio": 1.5, "tag": "1.20"},
+	}
+
+	// the subscriptions are read as JSON from the API server
+	sub := &appv1.Subscription{}
+	g.Expect(json.Unmarshal([]byte(`{"spec": {"packageOverrides": [{"packageName": "nginx", "packageOverrides": [
+		{"path": "spec.values", "value": {"port": 8080, "id": 9007199254740993, "weight": 2.0, "enabled": true,
+			"flag": "false", "mode": "0755", "country": "no", "proxy": null}},
+		{"path": "metadata.labels", "value": {"tier": "web"}}
+	]}]}}`), sub)).To(gomega.Succeed())
+
+	g.Expect(Override(helmRelease, sub)).To(gomega.Succeed())
+	g.Expect(helmRelease.Labels).To(gomega.HaveKeyWithValue("tier", "web"))
+	g.Expect(helmRelease.Repo.ChartName).To(gomega.Equal("nginx"))
+	g.Expect(helmRelease.Spec).To(gomega.Equal(map[string]interface{}{
+		"replicas": int64(3),
+		"ratio":    1.5,
+		"tag":      "1.20",
+		"values": map[string]interface{}{
+			"port":    int64(8080),
+			"id":      int64(9007199254740993),
+			"weight":  2.0,
+			"enabled": true,
+			"flag":    "false",
+			"mode":    "0755",
+			"country": "no",
+			"proxy":   nil,
+		},
+	}))
+
+	// the HelmRelease of a package without overrides is left as it is
+	other := &releasev1.HelmRelease{Repo: releasev1.HelmReleaseRepo{ChartName: "redis"}, Spec: map[string]interface{}{"replicas": 1}}
+	g.Expect(Override(other, sub)).To(gomega.Succeed())
+	g.Expect(other.Spec).To(gomega.Equal(map[string]interface{}{"replicas": 1}))
+}
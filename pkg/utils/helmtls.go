@@ -0,0 +1,87 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// HelmRepoTLSConfig returns the TLS config of the connections to the Helm repo of a channel. The CA certificates of
+// the caCerts key of the channel config map and secret are trusted along with the system ones, and the clientCert and
+// clientKey of the channel secret are the client certificate of mutual TLS, e.g. for ChartMuseum or Harbor.
+func HelmRepoTLSConfig(secret *corev1.Secret, configMap *corev1.ConfigMap, insecureSkipVerify bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecureSkipVerify, // #nosec G402 InsecureSkipVerify optionally
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if caCerts := ChannelCACerts(secret, configMap); caCerts != "" && !insecureSkipVerify {
+		certPool, _ := x509.SystemCertPool()
+		if certPool == nil {
+			certPool = x509.NewCertPool()
+		}
+
+		certChain := getCertChain(caCerts)
+		if len(certChain.Certificate) == 0 {
+			return nil, errors.New("no PEM certificate found in the caCerts of the Helm repo channel")
+		}
+
+		for _, cert := range certChain.Certificate {
+			x509Cert, err := x509.ParseCertificate(cert)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the caCerts of the Helm repo channel, err: %w", err)
+			}
+
+			klog.V(1).Info("Adding Helm repo CA certificate ", x509Cert.Subject.String())
+			certPool.AddCert(x509Cert)
+		}
+
+		tlsConfig.RootCAs = certPool
+	}
+
+	if secret == nil {
+		return tlsConfig, nil
+	}
+
+	clientKey := bytes.TrimSpace(secret.Data[ClientKey])
+	clientCert := bytes.TrimSpace(secret.Data[ClientCert])
+
+	if len(clientKey) == 0 && len(clientCert) == 0 {
+		return tlsConfig, nil
+	}
+
+	if len(clientKey) == 0 || len(clientCert) == 0 {
+		return nil, errors.New("for mTLS connection to the Helm repo, both clientKey (private key) and clientCert " +
+			"(certificate) are required in the channel secret")
+	}
+
+	clientCertificate, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the client certificate of the Helm repo channel, err: %w", err)
+	}
+
+	klog.V(1).Info("Client certificate key pair is provided. Making mTLS connection to the Helm repo.")
+
+	tlsConfig.Certificates = []tls.Certificate{clientCertificate}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	releasev1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/helmrelease/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+const (
+	// ValuesKindConfigMap is the kind of the ConfigMaps holding Helm values
+	ValuesKindConfigMap = "ConfigMap"
+	// ValuesKindSecret is the kind of the Secrets holding Helm values
+	ValuesKindSecret = "Secret"
+	// DefaultValuesKey is the key of the Helm values in their ConfigMap or Secret
+	DefaultValuesKey = "values.yaml"
+)
+
+// MergeValuesFrom sets the spec of the HelmRelease to the values of the valuesFrom ConfigMaps and Secrets of the
+// subscription, merged in order so the later ones take precedence. The package overrides are applied on top of them
+// by Override.
+func MergeValuesFrom(clt client.Client, helmRelease *releasev1.HelmRelease, sub *appv1.Subscription) error {
+	if len(sub.Spec.ValuesFrom) == 0 {
+		return nil
+	}
+
+	var merged map[string]interface{}
+
+	for _, ref := range sub.Spec.ValuesFrom {
+		if ref.PackageName != "" && ref.PackageName != helmRelease.Repo.ChartName {
+			continue
+		}
+
+		values, err := getReferencedValues(clt, sub.Namespace, ref)
+		if err != nil {
+			return fmt.Errorf("failed to get the values of package %v from %v %v/%v: %w",
+				helmRelease.Repo.ChartName, ref.Kind, sub.Namespace, ref.Name, err)
+		}
+
+		if values == nil {
+			continue
+		}
+
+		klog.Infof("Merging the helm values of %v %v/%v in helmrelease %v", ref.Kind, sub.Namespace, ref.Name, helmRelease.Name)
+
+		if merged == nil {
+			merged = values
+		} else {
+			merged = chartutil.CoalesceTables(values, merged)
+		}
+	}
+
+	if merged != nil {
+		helmRelease.Spec = merged
+	}
+
+	return nil
+}
+
+// getReferencedValues returns the values of a ConfigMap or Secret key, nil if the optional reference is missing
+func getReferencedValues(clt client.Client, namespace string, ref appv1.ValuesReference) (map[string]interface{}, error) {
+	key := ref.ValuesKey
+	if key == "" {
+		key = DefaultValuesKey
+	}
+
+	var (
+		data  []byte
+		found bool
+		err   error
+	)
+
+	objKey := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+
+	switch ref.Kind {
+	case ValuesKindConfigMap:
+		cm := &corev1.ConfigMap{}
+		if err = clt.Get(context.TODO(), objKey, cm); err == nil {
+			var value string
+			if value, found = cm.Data[key]; found {
+				data = []byte(value)
+			} else {
+				data, found = cm.BinaryData[key]
+			}
+		}
+	case ValuesKindSecret:
+		secret := &corev1.Secret{}
+		if err = clt.Get(context.TODO(), objKey, secret); err == nil {
+			data, found = secret.Data[key]
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kind %v, it must be %v or %v", ref.Kind, ValuesKindConfigMap, ValuesKindSecret)
+	}
+
+	if err != nil {
+		if errors.IsNotFound(err) && ref.Optional {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if !found {
+		if ref.Optional {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("key %v not found", key)
+	}
+
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the values of key %v: %w", key, err)
+	}
+
+	// the numbers are decoded as int64 or float64, like in Override
+	values := map[string]interface{}{}
+	if err := utiljson.Unmarshal(jsonData, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse the values of key %v: %w", key, err)
+	}
+
+	return values, nil
+}
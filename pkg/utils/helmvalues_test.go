@@ -0,0 +1,88 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	releasev1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/helmrelease/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestMergeValuesFrom(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(s)).To(gomega.Succeed())
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "base-values", Namespace: "default"},
+		Data:       map[string]string{"values.yaml": "replicas: 1\nimage:\n  repository: nginx\n  tag: \"1.20\"\n"},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod-values", Namespace: "default"},
+		Data:       map[string][]byte{"prod.yaml": []byte("replicas: 3\nimage:\n  tag: \"1.21\"\npassword: secret\n")},
+	}
+	clt := fake.NewClientBuilder().WithScheme(s).WithObjects(cm, secret).Build()
+
+	sub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{Name: "sub", Namespace: "default"},
+		Spec: appv1.SubscriptionSpec{
+			ValuesFrom: []appv1.ValuesReference{
+				{Kind: ValuesKindConfigMap, Name: "base-values"},
+				{Kind: ValuesKindSecret, Name: "prod-values", ValuesKey: "prod.yaml"},
+				{Kind: ValuesKindConfigMap, Name: "other-chart-values", PackageName: "other"},
+				{Kind: ValuesKindConfigMap, Name: "missing", Optional: true},
+			},
+		},
+	}
+
+	hr := &releasev1.HelmRelease{Repo: releasev1.HelmReleaseRepo{ChartName: "nginx"}}
+
+	g.Expect(MergeValuesFrom(clt, hr, sub)).To(gomega.Succeed())
+	g.Expect(hr.Spec).To(gomega.Equal(map[string]interface{}{
+		"replicas": int64(3),
+		"image":    map[string]interface{}{"repository": "nginx", "tag": "1.21"},
+		"password": "secret",
+	}))
+
+	// the package overrides take precedence over the values
+	sub.Spec.PackageOverrides = []*appv1.Overrides{{
+		PackageName: "nginx",
+		PackageOverrides: []appv1.PackageOverride{
+			{RawExtension: runtime.RawExtension{Raw: []byte(`{"path": "spec.replicas", "value": 5}`)}},
+		},
+	}}
+
+	g.Expect(Override(hr, sub)).To(gomega.Succeed())
+	g.Expect(hr.Spec.(map[string]interface{})["replicas"]).To(gomega.Equal(int64(5)))
+	g.Expect(hr.Spec.(map[string]interface{})["password"]).To(gomega.Equal("secret"))
+
+	// a missing reference fails unless it is optional
+	sub.Spec.ValuesFrom = append(sub.Spec.ValuesFrom, appv1.ValuesReference{Kind: ValuesKindSecret, Name: "missing"})
+	g.Expect(MergeValuesFrom(clt, hr, sub)).NotTo(gomega.Succeed())
+
+	sub.Spec.ValuesFrom = []appv1.ValuesReference{{Kind: ValuesKindConfigMap, Name: "base-values", ValuesKey: "missing.yaml"}}
+	g.Expect(MergeValuesFrom(clt, hr, sub)).NotTo(gomega.Succeed())
+
+	sub.Spec.ValuesFrom = []appv1.ValuesReference{{Kind: "Pod", Name: "base-values"}}
+	g.Expect(MergeValuesFrom(clt, hr, sub)).NotTo(gomega.Succeed())
+}
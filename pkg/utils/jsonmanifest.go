@@ -0,0 +1,121 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// maxSniffedManifestSize is the size above which the files without extension are not read to find out if they are
+// Kubernetes manifests
+const maxSniffedManifestSize = 1024 * 1024
+
+// manifestFileType tells if the file is a YAML or JSON manifest by its extension, or if it has no extension and its
+// content must be sniffed. It returns false for the other files.
+func manifestFileType(path string) (sniff bool, ok bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return false, true
+	case "":
+		return true, true
+	}
+
+	return false, false
+}
+
+// SplitManifests splits the content of a manifest file in documents. The content is parsed as a stream of JSON
+// objects or arrays of objects if it starts like one, and as YAML documents otherwise.
+func SplitManifests(content []byte) []string {
+	if items, ok := parseJSONManifests(content); ok {
+		return items
+	}
+
+	return ParseYAML(content)
+}
+
+// parseJSONManifests splits a stream of JSON objects and arrays of objects in objects, the items of the v1 List
+// objects are split too. It returns false if the content isn't JSON.
+func parseJSONManifests(content []byte) ([]string, bool) {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 || (content[0] != '{' && content[0] != '[') {
+		return nil, false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(content))
+	items := []string{}
+
+	for {
+		raw := json.RawMessage{}
+
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return items, true
+		}
+
+		if err != nil {
+			return nil, false
+		}
+
+		expanded, err := expandJSONManifest(raw)
+		if err != nil {
+			return nil, false
+		}
+
+		items = append(items, expanded...)
+	}
+}
+
+func expandJSONManifest(raw json.RawMessage) ([]string, error) {
+	if len(raw) > 0 && raw[0] == '[' {
+		elems := []json.RawMessage{}
+		if err := json.Unmarshal(raw, &elems); err != nil {
+			return nil, err
+		}
+
+		return expandJSONManifests(elems)
+	}
+
+	list := struct {
+		APIVersion string            `json:"apiVersion"`
+		Kind       string            `json:"kind"`
+		Items      []json.RawMessage `json:"items"`
+	}{}
+
+	// the values other than objects are returned as is, to be reported when they are parsed as resources
+	if err := json.Unmarshal(raw, &list); err == nil && list.APIVersion == "v1" && list.Kind == "List" {
+		return expandJSONManifests(list.Items)
+	}
+
+	return []string{string(raw)}, nil
+}
+
+func expandJSONManifests(elems []json.RawMessage) ([]string, error) {
+	items := []string{}
+
+	for _, elem := range elems {
+		expanded, err := expandJSONManifest(elem)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, expanded...)
+	}
+
+	return items, nil
+}
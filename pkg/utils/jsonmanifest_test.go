@@ -0,0 +1,97 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const jsonConfigMap = `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "cm1"}, "data": {"key": "---"}}`
+
+func TestParseJSONManifests(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	resources := ParseKubeResoures([]byte(jsonConfigMap))
+	g.Expect(resources).To(gomega.HaveLen(1))
+
+	cm := &corev1.ConfigMap{}
+	g.Expect(yaml.Unmarshal(resources[0], cm)).To(gomega.Succeed())
+	g.Expect(cm.Name).To(gomega.Equal("cm1"))
+	g.Expect(cm.Data["key"]).To(gomega.Equal("---"))
+
+	// arrays, v1 lists and streams of objects
+	content := `[
+  {"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "ns1"}},
+  {"apiVersion": "v1", "kind": "List", "items": [` + jsonConfigMap + `]}
+]
+{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "secret1"}}`
+
+	resources = ParseKubeResoures([]byte(content))
+	g.Expect(resources).To(gomega.HaveLen(3))
+
+	kinds := []string{}
+
+	for _, resource := range resources {
+		t := KubeResource{}
+		g.Expect(yaml.Unmarshal(resource, &t)).To(gomega.Succeed())
+
+		kinds = append(kinds, t.Kind)
+	}
+
+	g.Expect(kinds).To(gomega.Equal([]string{"Namespace", "ConfigMap", "Secret"}))
+
+	// YAML flow mappings that aren't JSON are parsed as YAML
+	resources = ParseKubeResoures([]byte(`{apiVersion: v1, kind: ConfigMap, metadata: {name: cm1}}`))
+	g.Expect(resources).To(gomega.HaveLen(1))
+
+	// invalid JSON values are reported
+	_, err := ParseKubeResourcesWithErrors([]byte(`[1]`))
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestSortJSONAndSniffedResources(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "jsonmanifest")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"cm.json":  jsonConfigMap,
+		"ns":       "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: ns1\n",
+		"OWNERS":   "approvers:\n- jane\n",
+		"Makefile": "build:\n\tgo build ./...\n",
+		"notes.md": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm2\n",
+	}
+
+	for name, content := range files {
+		g.Expect(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)).To(gomega.Succeed())
+	}
+
+	_, _, crdsAndNamespaceFiles, rbacFiles, otherFiles, err := SortResources(dir, dir)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	g.Expect(crdsAndNamespaceFiles).To(gomega.Equal([]string{filepath.Join(dir, "ns")}))
+	g.Expect(rbacFiles).To(gomega.BeEmpty())
+	g.Expect(otherFiles).To(gomega.Equal([]string{filepath.Join(dir, "cm.json")}))
+}
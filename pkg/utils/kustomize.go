@@ -27,11 +27,25 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/klog/v2"
 	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+	"sigs.k8s.io/kustomize/api/konfig"
 	"sigs.k8s.io/kustomize/api/krusty"
 	kustomizetypes "sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
+// findKustomizationFile returns the kustomization file of the directory, under any of the names kustomize build
+// recognizes: kustomization.yaml, kustomization.yml or Kustomization
+func findKustomizationFile(dir string) (string, bool) {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
 // RunKustomizeBuild runs kustomize build and returns the build output
 func RunKustomizeBuild(kustomizeDir string) ([]byte, error) {
 	fSys := filesys.MakeFsOnDisk()
@@ -127,14 +141,10 @@ func OverrideKustomize(pov appv1.PackageOverride, kustomizeDir string) error {
 		override = ovuobj["value"].(map[string]interface{})
 	}
 
-	kustomizeYamlFilePath := filepath.Join(kustomizeDir, "kustomization.yaml")
-
-	if _, err := os.Stat(kustomizeYamlFilePath); os.IsNotExist(err) {
-		kustomizeYamlFilePath = filepath.Join(kustomizeDir, "kustomization.yml")
-		if _, err := os.Stat(kustomizeYamlFilePath); os.IsNotExist(err) {
-			klog.Error("Kustomization file not found in ", kustomizeDir)
-			return err
-		}
+	kustomizeYamlFilePath, ok := findKustomizationFile(kustomizeDir)
+	if !ok {
+		klog.Error("Kustomization file not found in ", kustomizeDir)
+		return os.ErrNotExist
 	}
 
 	err = mergeKustomization(kustomizeYamlFilePath, override)
@@ -148,7 +158,7 @@ func OverrideKustomize(pov appv1.PackageOverride, kustomizeDir string) error {
 func mergeKustomization(kustomizeYamlFilePath string, override map[string]interface{}) error {
 	var master map[string]interface{}
 
-	bs, err := ioutil.ReadFile(kustomizeYamlFilePath) // #nosec G304 constructed filepath.Join(kustomizeDir, "kustomization.yaml")
+	bs, err := ioutil.ReadFile(kustomizeYamlFilePath) // #nosec G304 constructed by findKustomizationFile
 
 	if err != nil {
 		klog.Error("Failed to read file ", kustomizeYamlFilePath, " err: ", err)
@@ -15,6 +15,9 @@
 package utils
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -47,3 +50,43 @@ func Test_RunKustomizeBuild(t *testing.T) {
 		}
 	}
 }
+
+func TestKustomizationFileNames(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "kustomize")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	// a kustomization named Kustomization is rendered, and the resources it lists are not subscribed as raw files
+	appDir := filepath.Join(dir, "app")
+	g.Expect(os.Mkdir(appDir, 0750)).To(gomega.Succeed())
+
+	files := map[string]string{
+		"Kustomization": "resources:\n- cm.yaml\nnamePrefix: prod-\n",
+		"cm.yaml":       "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm1\n",
+	}
+
+	for name, content := range files {
+		g.Expect(ioutil.WriteFile(filepath.Join(appDir, name), []byte(content), 0600)).To(gomega.Succeed())
+	}
+
+	_, kustomizeDirs, crdsAndNamespaceFiles, rbacFiles, otherFiles, err := SortResources(dir, dir)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(kustomizeDirs).To(gomega.HaveKey(appDir + "/"))
+	g.Expect(crdsAndNamespaceFiles).To(gomega.BeEmpty())
+	g.Expect(rbacFiles).To(gomega.BeEmpty())
+	g.Expect(otherFiles).To(gomega.BeEmpty())
+
+	out, err := RunKustomizeBuild(appDir)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(out)).To(gomega.ContainSubstring("name: prod-cm1"))
+
+	kustomizationFile, ok := findKustomizationFile(appDir)
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(kustomizationFile).To(gomega.Equal(filepath.Join(appDir, "Kustomization")))
+
+	_, ok = findKustomizationFile(dir)
+	g.Expect(ok).To(gomega.BeFalse())
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// The limits of the manifest documents read from the channels. The repository content is untrusted input, the
+// aliases of a small document can expand to gigabytes (billion laughs) once it is decoded.
+const (
+	// maxManifestDocumentSize is the size limit of a document as it is written in the manifest file
+	maxManifestDocumentSize = 4 * 1024 * 1024
+	// maxManifestNodes is the limit of the number of YAML nodes of a document once its aliases are expanded
+	maxManifestNodes = 1000000
+	// maxManifestScalarsSize is the limit of the size of the scalar values of a document once its aliases are expanded
+	maxManifestScalarsSize = 16 * 1024 * 1024
+	// maxManifestDepth is the nesting limit of the collections of a document
+	maxManifestDepth = 100
+)
+
+// manifestSize is the size of a YAML node once its aliases are expanded
+type manifestSize struct {
+	nodes   int
+	scalars int
+	depth   int
+}
+
+// checkManifestLimits checks the size and the nesting of a manifest document, before and after the expansion of its
+// aliases, without expanding them
+func checkManifestLimits(doc []byte) error {
+	if len(doc) > maxManifestDocumentSize {
+		return fmt.Errorf("the document size %d exceeds the limit of %d bytes", len(doc), maxManifestDocumentSize)
+	}
+
+	node := &yamlv3.Node{}
+
+	// a node is decoded as is, its aliases are left unresolved
+	if err := yamlv3.Unmarshal(doc, node); err != nil {
+		return err
+	}
+
+	sizer := &manifestSizer{sizes: map[*yamlv3.Node]*manifestSize{}, inProgress: map[*yamlv3.Node]bool{}}
+
+	_, err := sizer.size(node)
+
+	return err
+}
+
+// manifestSizer sizes each node once, the nodes referenced by several aliases are not walked again
+type manifestSizer struct {
+	sizes      map[*yamlv3.Node]*manifestSize
+	inProgress map[*yamlv3.Node]bool
+}
+
+func (s *manifestSizer) size(node *yamlv3.Node) (*manifestSize, error) {
+	if size, ok := s.sizes[node]; ok {
+		return size, nil
+	}
+
+	if s.inProgress[node] {
+		return nil, fmt.Errorf("anchor %q contains an alias to itself", node.Anchor)
+	}
+
+	s.inProgress[node] = true
+	defer delete(s.inProgress, node)
+
+	size := &manifestSize{}
+
+	switch node.Kind {
+	case yamlv3.AliasNode:
+		if node.Alias == nil {
+			return nil, fmt.Errorf("unknown anchor %q", node.Value)
+		}
+
+		aliased, err := s.size(node.Alias)
+		if err != nil {
+			return nil, err
+		}
+
+		*size = *aliased
+	case yamlv3.ScalarNode:
+		size.nodes = 1
+		size.scalars = len(node.Value)
+	default:
+		size.nodes = 1
+
+		for _, child := range node.Content {
+			childSize, err := s.size(child)
+			if err != nil {
+				return nil, err
+			}
+
+			size.nodes += childSize.nodes
+			size.scalars += childSize.scalars
+
+			if childSize.depth > size.depth {
+				size.depth = childSize.depth
+			}
+
+			if size.nodes > maxManifestNodes {
+				return nil, fmt.Errorf("the document expands to more than %d nodes", maxManifestNodes)
+			}
+
+			if size.scalars > maxManifestScalarsSize {
+				return nil, fmt.Errorf("the document values expand to more than %d bytes", maxManifestScalarsSize)
+			}
+		}
+
+		if node.Kind != yamlv3.DocumentNode {
+			size.depth++
+		}
+
+		if size.depth > maxManifestDepth {
+			return nil, fmt.Errorf("the document nesting exceeds the limit of %d levels", maxManifestDepth)
+		}
+	}
+
+	s.sizes[node] = size
+
+	return size, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+const anchoredDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  labels: &labels
+    app: web
+    tier: frontend
+spec:
+  selector:
+    matchLabels: *labels
+  template:
+    metadata:
+      labels:
+        <<: *labels
+        version: v2
+    spec:
+      containers:
+      - &container
+        name: web
+        image: nginx:1.21
+      - <<: *container
+        name: sidecar
+`
+
+func TestManifestAnchors(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	resources, err := ParseKubeResourcesWithErrors([]byte(anchoredDeployment + "---\n" + jsonConfigMap))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(resources).To(gomega.HaveLen(2))
+
+	deploy := &appsv1.Deployment{}
+	g.Expect(yaml.Unmarshal(resources[0], deploy)).To(gomega.Succeed())
+
+	g.Expect(deploy.Spec.Selector.MatchLabels).To(gomega.Equal(map[string]string{"app": "web", "tier": "frontend"}))
+	g.Expect(deploy.Spec.Template.Labels).To(gomega.Equal(map[string]string{"app": "web", "tier": "frontend", "version": "v2"}))
+	g.Expect(deploy.Spec.Template.Spec.Containers).To(gomega.HaveLen(2))
+	g.Expect(deploy.Spec.Template.Spec.Containers[1].Name).To(gomega.Equal("sidecar"))
+	g.Expect(deploy.Spec.Template.Spec.Containers[1].Image).To(gomega.Equal("nginx:1.21"))
+}
+
+func TestManifestLimits(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	// billion laughs: each level aliases the previous one 10 times
+	laughs := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: lol\ndata:\n  l0: &l0 \"lol\"\n"
+	prev := "l0"
+
+	for i := 1; i <= 9; i++ {
+		name := fmt.Sprintf("l%d", i)
+		laughs += fmt.Sprintf("  %s: &%s [%s]\n", name, name, strings.TrimSuffix(strings.Repeat("*"+prev+",", 10), ","))
+		prev = name
+	}
+
+	deep := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: deep\ndata: " +
+		strings.Repeat("[", maxManifestDepth+1) + strings.Repeat("]", maxManifestDepth+1) + "\n"
+
+	// few nodes, with a large scalar aliased many times
+	fat := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: fat\ndata:\n  a: &a " + strings.Repeat("x", 1024*1024) +
+		"\n  b: &b [" + strings.TrimSuffix(strings.Repeat("*a,", 20), ",") + "]\n"
+
+	for doc, msg := range map[string]string{
+		laughs: "expands to more than",
+		deep:   "nesting exceeds the limit",
+		fat:    "values expand to more than",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: big\n  annotations:\n    a: " +
+			strings.Repeat("x", maxManifestDocumentSize) + "\n": "exceeds the limit",
+	} {
+		resources, err := ParseKubeResourcesWithErrors([]byte(doc))
+		g.Expect(resources).To(gomega.BeEmpty())
+		g.Expect(err).To(gomega.HaveOccurred())
+		g.Expect(err.Error()).To(gomega.ContainSubstring(msg))
+	}
+
+	// the resources next to a rejected document are still parsed
+	resources := ParseKubeResoures([]byte(laughs + "---\n" + jsonConfigMap))
+	g.Expect(resources).To(gomega.HaveLen(1))
+}
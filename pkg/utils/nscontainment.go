@@ -0,0 +1,135 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// The namespace containment policies of the namespaced resources subscribed in another namespace than the one of
+// their subscription. Without a policy, the resources of the cluster-admin subscriptions keep their namespace and
+// the others are moved to the subscription namespace.
+const (
+	// NamespaceContainmentForce moves the resources to the subscription namespace, even for cluster-admin
+	NamespaceContainmentForce = "force"
+	// NamespaceContainmentReject rejects the resources, even for cluster-admin
+	NamespaceContainmentReject = "reject"
+)
+
+// namespaceContainment is the policy of the namespaces without the namespace-containment annotation
+var namespaceContainment = ""
+
+// SetNamespaceContainment sets the namespace containment policy of the namespaces without their own
+func SetNamespaceContainment(policy string) error {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+
+	if err := validateNamespaceContainment(policy); err != nil {
+		return err
+	}
+
+	if policy != "" {
+		klog.Info("Namespace containment policy: ", policy)
+	}
+
+	namespaceContainment = policy
+
+	return nil
+}
+
+func validateNamespaceContainment(policy string) error {
+	switch policy {
+	case "", NamespaceContainmentForce, NamespaceContainmentReject:
+		return nil
+	}
+
+	return fmt.Errorf("invalid namespace containment policy %q, expecting %v or %v", policy,
+		NamespaceContainmentForce, NamespaceContainmentReject)
+}
+
+// GetNamespaceContainment returns the namespace containment policy of the subscriptions of the namespace, the
+// namespace-containment annotation of the namespace or the policy of the operator. The namespace annotation is set by
+// the cluster administrators, unlike the subscription annotations set by the tenants. The reject policy is returned
+// if the namespace can't be read, its annotation could be stricter than the policy of the operator.
+func GetNamespaceContainment(clt client.Client, namespace string) string {
+	ns := &corev1.Namespace{}
+
+	if err := clt.Get(context.TODO(), types.NamespacedName{Name: namespace}, ns); err != nil {
+		klog.Errorf("failed to get namespace %v, rejecting the resources of other namespaces. err: %v", namespace, err)
+
+		return NamespaceContainmentReject
+	}
+
+	annotation, ok := ns.GetAnnotations()[appv1.AnnotationNamespaceContainment]
+	if !ok {
+		return namespaceContainment
+	}
+
+	policy := strings.ToLower(strings.TrimSpace(annotation))
+
+	if err := validateNamespaceContainment(policy); err != nil {
+		// an invalid policy is the strictest one
+		klog.Errorf("namespace %v: %v, rejecting the resources of other namespaces", namespace, err)
+
+		return NamespaceContainmentReject
+	}
+
+	return policy
+}
+
+// PreservesResourceNamespace returns true if the namespace declared in the resource is kept rather than replaced by
+// the subscription namespace, by the preserve-namespace annotation of the resource, or else of the subscription. The
+// namespace containment policy still applies to the preserved namespaces.
+func PreservesResourceNamespace(sub *appv1.Subscription, rsc *unstructured.Unstructured) bool {
+	if rsc.GetNamespace() == "" {
+		return false
+	}
+
+	if preserve, ok := rsc.GetAnnotations()[appv1.AnnotationPreserveNamespace]; ok {
+		return strings.EqualFold(strings.TrimSpace(preserve), "true")
+	}
+
+	return sub != nil && strings.EqualFold(strings.TrimSpace(sub.GetAnnotations()[appv1.AnnotationPreserveNamespace]), "true")
+}
+
+// ContainResourceNamespace applies the namespace containment policy to a namespaced resource of a subscription of
+// subNamespace. The resource is moved to subNamespace by the force policy, and rejected by the reject policy.
+func ContainResourceNamespace(rsc *unstructured.Unstructured, subNamespace, policy string) error {
+	if rsc.GetNamespace() == "" || rsc.GetNamespace() == subNamespace {
+		return nil
+	}
+
+	switch policy {
+	case NamespaceContainmentForce:
+		klog.Infof("Moving %v %v/%v to the subscription namespace %v", rsc.GetKind(), rsc.GetNamespace(), rsc.GetName(),
+			subNamespace)
+
+		rsc.SetNamespace(subNamespace)
+	case NamespaceContainmentReject:
+		return fmt.Errorf("%v %v/%v is outside of the subscription namespace %v", rsc.GetKind(), rsc.GetNamespace(),
+			rsc.GetName(), subNamespace)
+	}
+
+	return nil
+}
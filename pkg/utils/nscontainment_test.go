@@ -0,0 +1,107 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestNamespaceContainment(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer func() { g.Expect(SetNamespaceContainment("")).To(gomega.Succeed()) }()
+
+	g.Expect(SetNamespaceContainment("rewrite")).NotTo(gomega.Succeed())
+	g.Expect(SetNamespaceContainment(" Force ")).To(gomega.Succeed())
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(gomega.Succeed())
+
+	clt := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant1"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant2",
+			Annotations: map[string]string{appv1.AnnotationNamespaceContainment: "reject"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant3",
+			Annotations: map[string]string{appv1.AnnotationNamespaceContainment: "typo"}}},
+	).Build()
+
+	g.Expect(GetNamespaceContainment(clt, "tenant1")).To(gomega.Equal(NamespaceContainmentForce))
+	g.Expect(GetNamespaceContainment(clt, "tenant2")).To(gomega.Equal(NamespaceContainmentReject))
+	g.Expect(GetNamespaceContainment(clt, "tenant3")).To(gomega.Equal(NamespaceContainmentReject))
+	g.Expect(GetNamespaceContainment(clt, "missing")).To(gomega.Equal(NamespaceContainmentReject))
+
+	newConfigMap := func(namespace string) *unstructured.Unstructured {
+		cm := &unstructured.Unstructured{}
+		cm.SetKind("ConfigMap")
+		cm.SetNamespace(namespace)
+		cm.SetName("settings")
+
+		return cm
+	}
+
+	cm := newConfigMap("kube-system")
+	g.Expect(ContainResourceNamespace(cm, "tenant1", NamespaceContainmentForce)).To(gomega.Succeed())
+	g.Expect(cm.GetNamespace()).To(gomega.Equal("tenant1"))
+
+	cm = newConfigMap("kube-system")
+	g.Expect(ContainResourceNamespace(cm, "tenant1", NamespaceContainmentReject)).NotTo(gomega.Succeed())
+
+	// no policy, the subscribers decide
+	g.Expect(ContainResourceNamespace(cm, "tenant1", "")).To(gomega.Succeed())
+	g.Expect(cm.GetNamespace()).To(gomega.Equal("kube-system"))
+
+	for _, namespace := range []string{"", "tenant1"} {
+		cm = newConfigMap(namespace)
+		g.Expect(ContainResourceNamespace(cm, "tenant1", NamespaceContainmentReject)).To(gomega.Succeed())
+		g.Expect(cm.GetNamespace()).To(gomega.Equal(namespace))
+	}
+}
+
+func TestPreservesResourceNamespace(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	sub := &appv1.Subscription{ObjectMeta: metav1.ObjectMeta{Name: "sub", Namespace: "tenant1"}}
+
+	cm := &unstructured.Unstructured{}
+	cm.SetKind("ConfigMap")
+	cm.SetNamespace("shared")
+	cm.SetName("settings")
+
+	g.Expect(PreservesResourceNamespace(sub, cm)).To(gomega.BeFalse())
+
+	sub.SetAnnotations(map[string]string{appv1.AnnotationPreserveNamespace: "true"})
+	g.Expect(PreservesResourceNamespace(sub, cm)).To(gomega.BeTrue())
+
+	// the resource annotation overrides the subscription one
+	cm.SetAnnotations(map[string]string{appv1.AnnotationPreserveNamespace: "false"})
+	g.Expect(PreservesResourceNamespace(sub, cm)).To(gomega.BeFalse())
+
+	sub.SetAnnotations(nil)
+	cm.SetAnnotations(map[string]string{appv1.AnnotationPreserveNamespace: "True"})
+	g.Expect(PreservesResourceNamespace(sub, cm)).To(gomega.BeTrue())
+
+	// there is no namespace to preserve
+	cm.SetNamespace("")
+	g.Expect(PreservesResourceNamespace(sub, cm)).To(gomega.BeFalse())
+}
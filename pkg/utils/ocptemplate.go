@@ -0,0 +1,209 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// templateParameterPathPrefix is the packageOverrides path prefix of the OpenShift template parameters
+const templateParameterPathPrefix = "parameters."
+
+var (
+	// OpenShiftTemplateGroupKind is the OpenShift template processed into the objects it lists
+	OpenShiftTemplateGroupKind = schema.GroupKind{Group: "template.openshift.io", Kind: "Template"}
+
+	// ${{NAME}} is replaced by the value as JSON, ${NAME} by the value as a string
+	templateNonStringParameter = regexp.MustCompile(`\$\{\{([a-zA-Z0-9_]+)\}\}`)
+	templateStringParameter    = regexp.MustCompile(`\$\{([a-zA-Z0-9_]+)\}`)
+)
+
+type openShiftTemplateParameter struct {
+	Name     string `json:"name"`
+	Value    string `json:"value,omitempty"`
+	Generate string `json:"generate,omitempty"`
+	Required bool   `json:"required,omitempty"`
+}
+
+// GetTemplateParameterOverrides returns the template parameters set by the packageOverrides of the template, the
+// overrides with a parameters.<NAME> path
+func GetTemplateParameterOverrides(templateName string, instance *appv1.Subscription) (map[string]string, error) {
+	values := map[string]string{}
+
+	for _, override := range prepareOverrides(templateName, instance) {
+		ovuobj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&override) // #nosec G601 requires "k8s.io/apimachinery/pkg/runtime" object
+		if err != nil {
+			return nil, err
+		}
+
+		path, _ := ovuobj["path"].(string)
+		if !strings.HasPrefix(path, templateParameterPathPrefix) {
+			continue
+		}
+
+		switch value := ovuobj["value"].(type) {
+		case string:
+			values[strings.TrimPrefix(path, templateParameterPathPrefix)] = value
+		default:
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+
+			values[strings.TrimPrefix(path, templateParameterPathPrefix)] = string(raw)
+		}
+	}
+
+	return values, nil
+}
+
+// ProcessOpenShiftTemplate returns the objects of the template with the parameters substituted. The values override
+// the parameter values of the template. The generated parameters are not supported, they would change on every
+// reconcile, so they need a value.
+func ProcessOpenShiftTemplate(tpl *unstructured.Unstructured, values map[string]string) ([]*unstructured.Unstructured, error) {
+	params := []openShiftTemplateParameter{}
+
+	if rawParams, ok := tpl.Object["parameters"]; ok {
+		raw, err := json.Marshal(rawParams)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, fmt.Errorf("invalid parameters of template %v: %w", tpl.GetName(), err)
+		}
+	}
+
+	resolved := map[string]string{}
+
+	for _, param := range params {
+		value, ok := values[param.Name]
+		if !ok {
+			value = param.Value
+		}
+
+		if value == "" && param.Generate != "" {
+			return nil, fmt.Errorf("parameter %v of template %v is generated, set its value in the packageOverrides "+
+				"or the parameters ConfigMap", param.Name, tpl.GetName())
+		}
+
+		if value == "" && param.Required {
+			return nil, fmt.Errorf("required parameter %v of template %v has no value", param.Name, tpl.GetName())
+		}
+
+		resolved[param.Name] = value
+	}
+
+	objects, _, err := unstructured.NestedSlice(tpl.Object, "objects")
+	if err != nil {
+		return nil, fmt.Errorf("invalid objects of template %v: %w", tpl.GetName(), err)
+	}
+
+	tplLabels, _, err := unstructured.NestedStringMap(tpl.Object, "labels")
+	if err != nil {
+		return nil, fmt.Errorf("invalid labels of template %v: %w", tpl.GetName(), err)
+	}
+
+	processed := make([]*unstructured.Unstructured, 0, len(objects))
+
+	for i, object := range objects {
+		obj, ok := substituteTemplateParameters(object, resolved).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("object %d of template %v is not an object", i, tpl.GetName())
+		}
+
+		rsc := &unstructured.Unstructured{Object: obj}
+
+		if len(tplLabels) > 0 {
+			labels := rsc.GetLabels()
+			if labels == nil {
+				labels = map[string]string{}
+			}
+
+			for k, v := range tplLabels {
+				labels[k] = fmt.Sprint(substituteTemplateString(v, resolved))
+			}
+
+			rsc.SetLabels(labels)
+		}
+
+		processed = append(processed, rsc)
+	}
+
+	return processed, nil
+}
+
+func substituteTemplateParameters(in interface{}, params map[string]string) interface{} {
+	switch v := in.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = substituteTemplateParameters(item, params)
+		}
+
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = substituteTemplateParameters(item, params)
+		}
+
+		return out
+	case string:
+		return substituteTemplateString(v, params)
+	}
+
+	return in
+}
+
+// substituteTemplateString replaces the parameter references of the string. A string made of a single ${{NAME}}
+// reference is replaced by the JSON value of the parameter, e.g. a number or a boolean.
+func substituteTemplateString(s string, params map[string]string) interface{} {
+	if m := templateNonStringParameter.FindStringSubmatch(s); m != nil && m[0] == s {
+		value, ok := params[m[1]]
+		if !ok {
+			return s
+		}
+
+		var typed interface{}
+		if err := utiljson.Unmarshal([]byte(value), &typed); err == nil {
+			return typed
+		}
+
+		return value
+	}
+
+	replace := func(re *regexp.Regexp, s string) string {
+		return re.ReplaceAllStringFunc(s, func(ref string) string {
+			if value, ok := params[re.FindStringSubmatch(ref)[1]]; ok {
+				return value
+			}
+
+			return ref
+		})
+	}
+
+	return replace(templateStringParameter, replace(templateNonStringParameter, s))
+}
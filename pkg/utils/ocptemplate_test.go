@@ -0,0 +1,123 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+const openShiftTemplate = `apiVersion: template.openshift.io/v1
+kind: Template
+metadata:
+  name: frontend
+labels:
+  app: ${NAME}
+parameters:
+- name: NAME
+  required: true
+- name: REPLICAS
+  value: "1"
+- name: PASSWORD
+  generate: expression
+  from: "[a-zA-Z0-9]{16}"
+objects:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: ${NAME}
+  spec:
+    replicas: ${{REPLICAS}}
+    template:
+      spec:
+        containers:
+        - name: web
+          image: quay.io/example/${NAME}:latest
+          env:
+          - name: PASSWORD
+            value: ${PASSWORD}
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: ${NAME}-svc
+`
+
+func TestProcessOpenShiftTemplate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	tpl := &unstructured.Unstructured{}
+	g.Expect(yaml.Unmarshal([]byte(openShiftTemplate), &tpl)).To(gomega.Succeed())
+	g.Expect(tpl.GroupVersionKind().GroupKind()).To(gomega.Equal(OpenShiftTemplateGroupKind))
+
+	// the required and the generated parameters need values
+	_, err := ProcessOpenShiftTemplate(tpl, map[string]string{"PASSWORD": "secret"})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	_, err = ProcessOpenShiftTemplate(tpl, map[string]string{"NAME": "web"})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	objects, err := ProcessOpenShiftTemplate(tpl, map[string]string{"NAME": "web", "REPLICAS": "3", "PASSWORD": "secret"})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(objects).To(gomega.HaveLen(2))
+
+	deploy := objects[0]
+	g.Expect(deploy.GetName()).To(gomega.Equal("web"))
+	g.Expect(deploy.GetLabels()).To(gomega.Equal(map[string]string{"app": "web"}))
+
+	replicas, _, err := unstructured.NestedInt64(deploy.Object, "spec", "replicas")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(replicas).To(gomega.Equal(int64(3)))
+
+	containers, _, _ := unstructured.NestedSlice(deploy.Object, "spec", "template", "spec", "containers")
+	g.Expect(containers[0].(map[string]interface{})["image"]).To(gomega.Equal("quay.io/example/web:latest"))
+
+	g.Expect(objects[1].GetName()).To(gomega.Equal("web-svc"))
+	g.Expect(objects[1].DeepCopy().GetLabels()).To(gomega.HaveKeyWithValue("app", "web"))
+}
+
+func TestGetTemplateParameterOverrides(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	appsub := &appv1.Subscription{
+		Spec: appv1.SubscriptionSpec{
+			PackageOverrides: []*appv1.Overrides{
+				{
+					PackageName: "frontend",
+					PackageOverrides: []appv1.PackageOverride{
+						{RawExtension: runtime.RawExtension{Raw: []byte(`{"path": "parameters.NAME", "value": "web"}`)}},
+						{RawExtension: runtime.RawExtension{Raw: []byte(`{"path": "parameters.REPLICAS", "value": 3}`)}},
+						{RawExtension: runtime.RawExtension{Raw: []byte(`{"path": "metadata.labels.tier", "value": "web"}`)}},
+					},
+				},
+				{
+					PackageName: "other",
+					PackageOverrides: []appv1.PackageOverride{
+						{RawExtension: runtime.RawExtension{Raw: []byte(`{"path": "parameters.NAME", "value": "other"}`)}},
+					},
+				},
+			},
+		},
+	}
+
+	values, err := GetTemplateParameterOverrides("frontend", appsub)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(values).To(gomega.Equal(map[string]string{"NAME": "web", "REPLICAS": "3"}))
+}
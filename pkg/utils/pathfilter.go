@@ -0,0 +1,273 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gitignore "github.com/sabhiram/go-gitignore"
+	corev1 "k8s.io/api/core/v1"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+const (
+	// FilterRefInclude is the key of the filterRef ConfigMap listing the glob patterns of the repo paths to subscribe
+	FilterRefInclude = "include"
+	// FilterRefExclude is the key of the filterRef ConfigMap listing the glob patterns of the repo paths not to subscribe
+	FilterRefExclude = "exclude"
+	// FilterRefPath is the key of the filterRef ConfigMap giving the repo path of the resources to subscribe
+	FilterRefPath = "path"
+	// FilterRefPaths is the key of the filterRef ConfigMap listing several repo paths of the resources to subscribe
+	FilterRefPaths = "paths"
+	// FilterRefExpect is the key of the filterRef ConfigMap listing the structure expected from the subscribed repo
+	// paths: chart, kustomization or non-empty
+	FilterRefExpect = "expect"
+)
+
+const (
+	// ExpectChart expects a Chart.yaml at the top of each subscribed repo path
+	ExpectChart = "chart"
+	// ExpectKustomization expects a kustomization file at the top of each subscribed repo path
+	ExpectKustomization = "kustomization"
+	// ExpectNonEmpty expects at least one file to deploy in each subscribed repo path
+	ExpectNonEmpty = "non-empty"
+)
+
+// ParsePathPatterns splits a list of glob patterns separated by new lines or commas. The blank lines and the lines
+// starting with # are dropped.
+func ParsePathPatterns(value string) []string {
+	patterns := []string{}
+
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		for _, pattern := range strings.Split(line, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+
+	return patterns
+}
+
+// GetGitPathPatterns returns the include and exclude glob patterns of the repo paths subscribed by the appsub. The
+// annotations take precedence over the filterRef ConfigMap, which can be nil.
+func GetGitPathPatterns(sub *appv1.Subscription, filterRef *corev1.ConfigMap) (include, exclude []string) {
+	annotations := sub.GetAnnotations()
+
+	includeValue := annotations[appv1.AnnotationGitIncludePaths]
+	excludeValue := annotations[appv1.AnnotationGitExcludePaths]
+
+	if filterRef != nil {
+		if includeValue == "" {
+			includeValue = filterRef.Data[FilterRefInclude]
+		}
+
+		if excludeValue == "" {
+			excludeValue = filterRef.Data[FilterRefExclude]
+		}
+	}
+
+	return ParsePathPatterns(includeValue), ParsePathPatterns(excludeValue)
+}
+
+// GetGitResourcePaths returns the paths within repoRoot of the resources subscribed by the appsub. The git path
+// annotations, then the git paths annotation, take precedence over the paths and the path of the filterRef
+// ConfigMap, which can be nil. It returns repoRoot if the appsub has no path, and an error if a path is out of the
+// repo.
+func GetGitResourcePaths(sub *appv1.Subscription, repoRoot string, filterRef *corev1.ConfigMap) ([]string, error) {
+	annotations := sub.GetAnnotations()
+
+	var paths []string
+
+	switch {
+	case annotations[appv1.AnnotationGithubPath] != "":
+		paths = []string{annotations[appv1.AnnotationGithubPath]}
+	case annotations[appv1.AnnotationGitPath] != "":
+		paths = []string{annotations[appv1.AnnotationGitPath]}
+	case annotations[appv1.AnnotationGitPaths] != "":
+		paths = ParsePathPatterns(annotations[appv1.AnnotationGitPaths])
+	case filterRef != nil && filterRef.Data[FilterRefPaths] != "":
+		paths = ParsePathPatterns(filterRef.Data[FilterRefPaths])
+	case filterRef != nil:
+		paths = []string{filterRef.Data[FilterRefPath]}
+	}
+
+	if len(paths) == 0 {
+		return []string{repoRoot}, nil
+	}
+
+	resourcePaths := make([]string, 0, len(paths))
+
+	for _, path := range paths {
+		resourcePath := filepath.Join(repoRoot, path)
+
+		if !isWithinDir(repoRoot, resourcePath) {
+			return nil, fmt.Errorf("the path %v of appsub %v/%v is out of the repository", path, sub.Namespace, sub.Name)
+		}
+
+		resourcePaths = append(resourcePaths, resourcePath)
+	}
+
+	return resourcePaths, nil
+}
+
+// NewPathFilterSkipFunc returns a SortResources skip function skipping the paths of the repo that match none of the
+// include patterns, if any, or that match an exclude pattern. The patterns are matched against the paths relative to
+// the repo root with the .gitignore syntax: ** matches any directories, and ! negates a pattern listed before it,
+// so apps/** and !apps/legacy/** include the apps directory except its legacy subdirectory.
+func NewPathFilterSkipFunc(repoRoot string, include, exclude []string) SkipFunc {
+	if len(include) == 0 && len(exclude) == 0 {
+		return func(string, string) bool { return false }
+	}
+
+	includeMatcher := gitignore.CompileIgnoreLines(include...)
+	excludeMatcher := gitignore.CompileIgnoreLines(exclude...)
+
+	return func(resourcePath, path string) bool {
+		relativePath, err := filepath.Rel(repoRoot, path)
+		if err != nil || relativePath == "." {
+			return false
+		}
+
+		if len(include) != 0 && !includeMatcher.MatchesPath(relativePath) {
+			return true
+		}
+
+		return excludeMatcher.MatchesPath(relativePath)
+	}
+}
+
+// ChainSkipFuncs returns a SortResources skip function skipping the paths any of the functions skips
+func ChainSkipFuncs(skips ...SkipFunc) SkipFunc {
+	return func(resourcePath, path string) bool {
+		for _, skip := range skips {
+			if skip(resourcePath, path) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// GetGitPathExpectations returns the structure expected from the repo paths subscribed by the appsub. The git path
+// expect annotation takes precedence over the filterRef ConfigMap, which can be nil. An unknown expectation is an
+// error.
+func GetGitPathExpectations(sub *appv1.Subscription, filterRef *corev1.ConfigMap) ([]string, error) {
+	value := sub.GetAnnotations()[appv1.AnnotationGitPathExpect]
+
+	if value == "" && filterRef != nil {
+		value = filterRef.Data[FilterRefExpect]
+	}
+
+	expectations := ParsePathPatterns(value)
+
+	for i, expectation := range expectations {
+		switch expectations[i] = strings.ToLower(expectation); expectations[i] {
+		case ExpectChart, ExpectKustomization, ExpectNonEmpty:
+		default:
+			return nil, fmt.Errorf("unknown expected structure %v of the paths of appsub %v/%v, expecting %v, %v or %v",
+				expectation, sub.Namespace, sub.Name, ExpectChart, ExpectKustomization, ExpectNonEmpty)
+		}
+	}
+
+	return expectations, nil
+}
+
+// VerifyGitPathStructure checks each subscribed path of the repo exists and has the expected structure: a Chart.yaml
+// for chart, a kustomization file for kustomization, and at least one file not skipped for non-empty. The error
+// describes all the paths that don't.
+func VerifyGitPathStructure(repoRoot string, resourcePaths, expectations []string, skip SkipFunc) error {
+	if len(expectations) == 0 {
+		return nil
+	}
+
+	problems := []string{}
+
+	for _, resourcePath := range resourcePaths {
+		relativePath, err := filepath.Rel(repoRoot, resourcePath)
+		if err != nil {
+			return err
+		}
+
+		if info, err := os.Stat(resourcePath); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("path %v is not a directory of the repository", relativePath))
+
+			continue
+		}
+
+		for _, expectation := range expectations {
+			switch expectation {
+			case ExpectChart:
+				if info, err := os.Stat(filepath.Join(resourcePath, "Chart.yaml")); err != nil || info.IsDir() {
+					problems = append(problems, fmt.Sprintf("path %v has no Chart.yaml", relativePath))
+				}
+			case ExpectKustomization:
+				if _, ok := findKustomizationFile(resourcePath); !ok {
+					problems = append(problems, fmt.Sprintf("path %v has no kustomization.yaml", relativePath))
+				}
+			case ExpectNonEmpty:
+				if !hasDeployableFile(resourcePath, skip) {
+					problems = append(problems, fmt.Sprintf("path %v has no file to deploy", relativePath))
+				}
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("the repository doesn't have the expected structure: %v", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// hasDeployableFile returns true if the resource path has a file out of the hidden directories and not skipped
+func hasDeployableFile(resourcePath string, skip SkipFunc) bool {
+	found := false
+
+	_ = filepath.WalkDir(resourcePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || found {
+			return filepath.SkipDir
+		}
+
+		if path != resourcePath && (strings.HasPrefix(d.Name(), ".") || skip != nil && skip(resourcePath, path)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !d.IsDir() {
+			found = true
+
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	return found
+}
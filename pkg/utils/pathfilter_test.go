@@ -0,0 +1,217 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestGetGitPathPatterns(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(ParsePathPatterns("apps/**\n# legacy apps\n!apps/legacy/** , \n\n*.md")).
+		To(gomega.Equal([]string{"apps/**", "!apps/legacy/**", "*.md"}))
+
+	filterRef := &corev1.ConfigMap{Data: map[string]string{FilterRefInclude: "apps/**", FilterRefExclude: "*.md"}}
+	sub := &appv1.Subscription{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{appv1.AnnotationGitIncludePaths: "infra/**,apps/**"},
+	}}
+
+	include, exclude := GetGitPathPatterns(sub, filterRef)
+	g.Expect(include).To(gomega.Equal([]string{"infra/**", "apps/**"}))
+	g.Expect(exclude).To(gomega.Equal([]string{"*.md"}))
+
+	include, exclude = GetGitPathPatterns(&appv1.Subscription{}, nil)
+	g.Expect(include).To(gomega.BeEmpty())
+	g.Expect(exclude).To(gomega.BeEmpty())
+}
+
+func TestPathFilterSkipFunc(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "pathfilter")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"apps/web/cm.yaml":         "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n",
+		"apps/web/ns.yaml":         "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: web\n",
+		"apps/legacy/cm.yaml":      "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: legacy\n",
+		"apps/db/chart/Chart.yaml": "apiVersion: v2\nname: db\nversion: 0.1.0\n",
+		"apps/db/values/cm.yaml":   "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: db-values\n",
+		"infra/chart/Chart.yaml":   "apiVersion: v2\nname: infra\nversion: 0.1.0\n",
+		"infra/cm.yaml":            "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: infra\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		g.Expect(os.MkdirAll(filepath.Dir(path), 0750)).To(gomega.Succeed())
+		g.Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(gomega.Succeed())
+	}
+
+	skip := NewPathFilterSkipFunc(dir, []string{"apps/**", "!apps/legacy/**"}, []string{"apps/db/values/**"})
+
+	chartDirs, _, crdsAndNamespaceFiles, _, otherFiles, err := SortResources(dir, dir, skip)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	g.Expect(chartDirs).To(gomega.Equal(map[string]string{
+		filepath.Join(dir, "apps/db/chart") + "/": filepath.Join(dir, "apps/db/chart") + "/",
+	}))
+	g.Expect(crdsAndNamespaceFiles).To(gomega.Equal([]string{filepath.Join(dir, "apps/web/ns.yaml")}))
+	g.Expect(otherFiles).To(gomega.Equal([]string{filepath.Join(dir, "apps/web/cm.yaml")}))
+
+	// no pattern subscribes everything
+	_, _, _, _, otherFiles, err = SortResources(dir, dir, NewPathFilterSkipFunc(dir, nil, nil))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(otherFiles).To(gomega.HaveLen(4))
+}
+
+func TestGitResourcePaths(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "resourcepaths")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"apps/web/cm.yaml":         "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n",
+		"apps/db/chart/Chart.yaml": "apiVersion: v2\nname: db\nversion: 0.1.0\n",
+		"infra/ns.yaml":            "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: infra\n",
+		"docs/cm.yaml":             "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: docs\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		g.Expect(os.MkdirAll(filepath.Dir(path), 0750)).To(gomega.Succeed())
+		g.Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(gomega.Succeed())
+	}
+
+	sub := &appv1.Subscription{}
+
+	resourcePaths, err := GetGitResourcePaths(sub, dir, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(resourcePaths).To(gomega.Equal([]string{dir}))
+
+	filterRef := &corev1.ConfigMap{Data: map[string]string{FilterRefPath: "docs", FilterRefPaths: "apps\ninfra, apps/web"}}
+
+	resourcePaths, err = GetGitResourcePaths(sub, dir, filterRef)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(resourcePaths).To(gomega.Equal([]string{
+		filepath.Join(dir, "apps"), filepath.Join(dir, "infra"), filepath.Join(dir, "apps/web"),
+	}))
+
+	// the nested apps/web path doesn't sort its resources twice
+	chartDirs, _, crdsAndNamespaceFiles, _, otherFiles, err := SortResourcePaths(dir, resourcePaths)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(chartDirs).To(gomega.HaveKey(filepath.Join(dir, "apps/db/chart") + "/"))
+	g.Expect(crdsAndNamespaceFiles).To(gomega.Equal([]string{filepath.Join(dir, "infra/ns.yaml")}))
+	g.Expect(otherFiles).To(gomega.Equal([]string{filepath.Join(dir, "apps/web/cm.yaml")}))
+
+	// the annotation takes precedence over the filterRef ConfigMap
+	sub.SetAnnotations(map[string]string{appv1.AnnotationGitPath: "docs"})
+
+	resourcePaths, err = GetGitResourcePaths(sub, dir, filterRef)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(resourcePaths).To(gomega.Equal([]string{filepath.Join(dir, "docs")}))
+
+	sub.SetAnnotations(map[string]string{appv1.AnnotationGitPaths: "infra,docs"})
+
+	resourcePaths, err = GetGitResourcePaths(sub, dir, filterRef)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(resourcePaths).To(gomega.Equal([]string{filepath.Join(dir, "infra"), filepath.Join(dir, "docs")}))
+
+	sub.SetAnnotations(nil)
+
+	_, err = GetGitResourcePaths(sub, dir, &corev1.ConfigMap{Data: map[string]string{FilterRefPaths: "apps,../other"}})
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("out of the repository")))
+}
+
+func TestVerifyGitPathStructure(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "pathstructure")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"charts/nginx/Chart.yaml":          "apiVersion: v2\nname: nginx\nversion: 1.0.0\n",
+		"overlays/prod/kustomization.yaml": "resources: []\n",
+		"apps/docs/README.md":              "docs\n",
+		"apps/empty/.keep":                 "",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		g.Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(gomega.Succeed())
+		g.Expect(ioutil.WriteFile(path, []byte(content), 0o600)).To(gomega.Succeed())
+	}
+
+	filterRef := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "filter"},
+		Data:       map[string]string{FilterRefExpect: "Chart, non-empty"},
+	}
+
+	sub := &appv1.Subscription{}
+
+	expectations, err := GetGitPathExpectations(sub, filterRef)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(expectations).To(gomega.Equal([]string{ExpectChart, ExpectNonEmpty}))
+
+	// the annotation passed by the hub takes precedence
+	sub.SetAnnotations(map[string]string{appv1.AnnotationGitPathExpect: "kustomization"})
+
+	expectations, err = GetGitPathExpectations(sub, filterRef)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(expectations).To(gomega.Equal([]string{ExpectKustomization}))
+
+	expectations = []string{ExpectChart, ExpectNonEmpty}
+
+	g.Expect(VerifyGitPathStructure(dir, []string{filepath.Join(dir, "charts/nginx")}, expectations, nil)).To(gomega.Succeed())
+
+	// all the paths not matching are reported
+	err = VerifyGitPathStructure(dir, []string{filepath.Join(dir, "overlays/prod"), filepath.Join(dir, "apps/empty"),
+		filepath.Join(dir, "apps/missing")}, expectations, nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(err.Error()).To(gomega.ContainSubstring("path overlays/prod has no Chart.yaml"))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("path apps/empty has no Chart.yaml; path apps/empty has no file to deploy"))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("path apps/missing is not a directory of the repository"))
+
+	g.Expect(VerifyGitPathStructure(dir, []string{filepath.Join(dir, "overlays/prod")}, []string{ExpectKustomization}, nil)).
+		To(gomega.Succeed())
+
+	// the files skipped by the path filters are not deployed
+	skip := NewPathFilterSkipFunc(dir, nil, []string{"*.md"})
+	g.Expect(VerifyGitPathStructure(dir, []string{filepath.Join(dir, "apps/docs")}, []string{ExpectNonEmpty}, nil)).To(gomega.Succeed())
+	g.Expect(VerifyGitPathStructure(dir, []string{filepath.Join(dir, "apps/docs")}, []string{ExpectNonEmpty}, skip)).
+		NotTo(gomega.Succeed())
+
+	// without expectations, nothing is verified
+	g.Expect(VerifyGitPathStructure(dir, []string{filepath.Join(dir, "apps/missing")}, nil, nil)).To(gomega.Succeed())
+
+	_, err = GetGitPathExpectations(&appv1.Subscription{}, &corev1.ConfigMap{Data: map[string]string{FilterRefExpect: "helm"}})
+	g.Expect(err).To(gomega.HaveOccurred())
+}
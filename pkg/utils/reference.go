@@ -18,6 +18,7 @@ import (
 	"context"
 	"reflect"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/klog/v2"
@@ -29,6 +30,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
 	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
 )
 
@@ -299,3 +301,44 @@ func isEqualObjectsDataOwnersLabels(existingObject *unstructured.Unstructured, r
 
 	return true
 }
+
+// IsSubscriberItemReferenceChanged returns true if the data of the channel secrets and config maps, or of the package
+// filter config map, of the subscriber item changed since the previous item
+func IsSubscriberItemReferenceChanged(previous, item *appv1.SubscriberItem) bool {
+	return !reflect.DeepEqual(secretData(previous.ChannelSecret), secretData(item.ChannelSecret)) ||
+		!reflect.DeepEqual(secretData(previous.SecondaryChannelSecret), secretData(item.SecondaryChannelSecret)) ||
+		!reflect.DeepEqual(configMapData(previous.ChannelConfigMap), configMapData(item.ChannelConfigMap)) ||
+		!reflect.DeepEqual(configMapData(previous.SecondaryChannelConfigMap), configMapData(item.SecondaryChannelConfigMap)) ||
+		!reflect.DeepEqual(configMapData(previous.SubscriptionConfigMap), configMapData(item.SubscriptionConfigMap))
+}
+
+// IsSubscriberItemChannelChanged returns true if the spec of the primary or the secondary channel of the subscriber
+// item, e.g. its pathname or secretRef, changed since the previous item
+func IsSubscriberItemChannelChanged(previous, item *appv1.SubscriberItem) bool {
+	return !reflect.DeepEqual(channelSpec(previous.Channel), channelSpec(item.Channel)) ||
+		!reflect.DeepEqual(channelSpec(previous.SecondaryChannel), channelSpec(item.SecondaryChannel))
+}
+
+func channelSpec(chn *chnv1.Channel) *chnv1.ChannelSpec {
+	if chn == nil {
+		return nil
+	}
+
+	return &chn.Spec
+}
+
+func secretData(secret *corev1.Secret) map[string][]byte {
+	if secret == nil || len(secret.Data) == 0 {
+		return nil
+	}
+
+	return secret.Data
+}
+
+func configMapData(cm *corev1.ConfigMap) map[string]string {
+	if cm == nil || len(cm.Data) == 0 {
+		return nil
+	}
+
+	return cm.Data
+}
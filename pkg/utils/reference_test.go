@@ -24,6 +24,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
 	appv1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
 )
 
@@ -297,3 +298,58 @@ func TestIsEqualObjectsDataOwnersLabels(t *testing.T) {
 		})
 	}
 }
+
+func TestIsSubscriberItemChannelChanged(t *testing.T) {
+	newChannel := func(pathname, secret string) *chnv1.Channel {
+		chn := &chnv1.Channel{
+			ObjectMeta: metav1.ObjectMeta{Name: chKey.Name, Namespace: chKey.Namespace},
+			Spec:       chnv1.ChannelSpec{Type: chnv1.ChannelTypeGit, Pathname: pathname},
+		}
+
+		if secret != "" {
+			chn.Spec.SecretRef = &corev1.ObjectReference{Name: secret}
+		}
+
+		return chn
+	}
+
+	previous := &appv1alpha1.SubscriberItem{Channel: newChannel("https://github.com/org/repo.git", "")}
+
+	testCases := []struct {
+		desc   string
+		item   *appv1alpha1.SubscriberItem
+		wanted bool
+	}{
+		{
+			desc:   "same channel spec",
+			item:   &appv1alpha1.SubscriberItem{Channel: newChannel("https://github.com/org/repo.git", "")},
+			wanted: false,
+		},
+		{
+			desc:   "pathname changed",
+			item:   &appv1alpha1.SubscriberItem{Channel: newChannel("https://github.com/org/fork.git", "")},
+			wanted: true,
+		},
+		{
+			desc:   "secretRef changed",
+			item:   &appv1alpha1.SubscriberItem{Channel: newChannel("https://github.com/org/repo.git", "git-creds")},
+			wanted: true,
+		},
+		{
+			desc: "secondary channel added",
+			item: &appv1alpha1.SubscriberItem{
+				Channel:          newChannel("https://github.com/org/repo.git", ""),
+				SecondaryChannel: newChannel("https://gitlab.com/org/repo.git", ""),
+			},
+			wanted: true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			got := IsSubscriberItemChannelChanged(previous, tC.item)
+			if got != tC.wanted {
+				t.Errorf("wanted %v, got %v", tC.wanted, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// ReasonRepoLimitExceeded prefixes the subscription status reason when its Git repository exceeds one of the
+// repository limits
+const ReasonRepoLimitExceeded = "RepoLimitExceeded"
+
+// cloneSizeCheckInterval is how often the size of a clone in progress is checked against the repository size limit
+var cloneSizeCheckInterval = time.Second
+
+// RepoLimits are the limits of the Git repositories cloned by the subscriptions, a limit of 0 is no limit
+type RepoLimits struct {
+	// MaxRepoSize is the size limit of a clone, its history and working tree, in bytes
+	MaxRepoSize int64
+	// MaxFileSize is the size limit of a file of the subscribed paths, in bytes
+	MaxFileSize int64
+	// MaxManifests is the limit of the number of resource files of the subscribed paths
+	MaxManifests int
+}
+
+var repoLimits = RepoLimits{}
+
+// SetRepoLimits sets the limits of the Git repositories cloned by the subscriptions
+func SetRepoLimits(limits RepoLimits) {
+	if limits != (RepoLimits{}) {
+		klog.Infof("Git repository limits, size: %d bytes, file size: %d bytes, resource files: %d",
+			limits.MaxRepoSize, limits.MaxFileSize, limits.MaxManifests)
+	}
+
+	repoLimits = limits
+}
+
+// RepoLimitError is the error of a Git repository exceeding one of the repository limits
+type RepoLimitError struct {
+	msg string
+}
+
+func (e *RepoLimitError) Error() string {
+	return e.msg
+}
+
+// IsRepoLimitExceeded returns true if the error, or the error it wraps, is a RepoLimitError
+func IsRepoLimitExceeded(err error) bool {
+	limitErr := &RepoLimitError{}
+
+	return errors.As(err, &limitErr)
+}
+
+// UpdateRepoLimitStatus sets the subscription failed with the repository limit its Git repository exceeds, or clears
+// a previous failure if failure is empty.
+func UpdateRepoLimitStatus(clt client.Client, instance *appv1.Subscription, failure string) {
+	UpdateFailureReasonStatus(clt, instance, ReasonRepoLimitExceeded, failure)
+}
+
+// cloneWithinSizeLimit clones the repository into destDir, the clone is aborted as soon as destDir exceeds the
+// repository size limit
+func cloneWithinSizeLimit(ctx context.Context, destDir string, options *git.CloneOptions) (*git.Repository, error) {
+	limit := repoLimits.MaxRepoSize
+	if limit <= 0 {
+		return plainCloneContext(ctx, destDir, options)
+	}
+
+	cloneCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	exceeded := make(chan int64, 1)
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(cloneSizeCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if size, err := dirSize(destDir); err == nil && size > limit {
+					exceeded <- size
+
+					cancel()
+
+					return
+				}
+			}
+		}
+	}()
+
+	repo, err := plainCloneContext(cloneCtx, destDir, options)
+
+	close(done)
+
+	select {
+	case size := <-exceeded:
+		return nil, repoSizeError(options.URL, size, limit)
+	default:
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if size, err := dirSize(destDir); err == nil && size > limit {
+		return nil, repoSizeError(options.URL, size, limit)
+	}
+
+	return repo, nil
+}
+
+func repoSizeError(url string, size, limit int64) error {
+	return &RepoLimitError{msg: fmt.Sprintf("the clone of %v exceeds the repository size limit of %d bytes, %d bytes "+
+		"were written", url, limit, size)}
+}
+
+// dirSize returns the size of the files of the directory
+func dirSize(dir string) (int64, error) {
+	var size int64
+
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			// the files of a clone in progress come and go
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if !info.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size, err
+}
+
+// checkRepoFileSize checks the size of a file of the subscribed paths against the file size limit
+func checkRepoFileSize(path string, info os.FileInfo) error {
+	if repoLimits.MaxFileSize > 0 && info.Size() > repoLimits.MaxFileSize {
+		return &RepoLimitError{msg: fmt.Sprintf("file %v of %d bytes exceeds the file size limit of %d bytes",
+			filepath.Base(path), info.Size(), repoLimits.MaxFileSize)}
+	}
+
+	return nil
+}
+
+// checkManifestCount checks the number of resource files of the subscribed paths against the resource file limit
+func checkManifestCount(count int) error {
+	if repoLimits.MaxManifests > 0 && count > repoLimits.MaxManifests {
+		return &RepoLimitError{msg: fmt.Sprintf("the subscribed paths have more than %d resource files",
+			repoLimits.MaxManifests)}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// GetResyncPackageName returns the package name of the resync-package annotation value <package name>[@<request id>].
+// The request id only makes the same package resync again.
+func GetResyncPackageName(request string) string {
+	return strings.TrimSpace(strings.SplitN(request, "@", 2)[0])
+}
+
+// IsPackageResource checks the subscribed resource is the named package, as in the resync-package annotation or the
+// packageOverrides. The package is either the resource name, <kind>/<name>, or the chart name of a HelmRelease.
+func IsPackageResource(resource *unstructured.Unstructured, pkgName string) bool {
+	if resource == nil || pkgName == "" {
+		return false
+	}
+
+	if resource.GetName() == pkgName || strings.EqualFold(resource.GetKind()+"/"+resource.GetName(), pkgName) {
+		return true
+	}
+
+	if resource.GetKind() == "HelmRelease" && resource.GroupVersionKind().Group == appv1.SchemeGroupVersion.Group {
+		chartName, _, _ := unstructured.NestedString(resource.Object, "repo", "chartName")
+
+		return chartName == pkgName
+	}
+
+	return false
+}
@@ -0,0 +1,52 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestResyncPackage(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(GetResyncPackageName("nginx-ingress")).To(gomega.Equal("nginx-ingress"))
+	g.Expect(GetResyncPackageName("nginx-ingress@2022-03-01T10:00:00Z")).To(gomega.Equal("nginx-ingress"))
+	g.Expect(GetResyncPackageName("")).To(gomega.Equal(""))
+
+	hr := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps.open-cluster-management.io/v1",
+		"kind":       "HelmRelease",
+		"metadata":   map[string]interface{}{"name": "nginx-ingress-5d3e1"},
+		"repo":       map[string]interface{}{"chartName": "nginx-ingress"},
+	}}
+
+	g.Expect(IsPackageResource(hr, "nginx-ingress")).To(gomega.BeTrue())
+	g.Expect(IsPackageResource(hr, "nginx-ingress-5d3e1")).To(gomega.BeTrue())
+	g.Expect(IsPackageResource(hr, "helmrelease/nginx-ingress-5d3e1")).To(gomega.BeTrue())
+	g.Expect(IsPackageResource(hr, "mongodb")).To(gomega.BeFalse())
+	g.Expect(IsPackageResource(hr, "")).To(gomega.BeFalse())
+
+	cm := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "settings"},
+	}}
+
+	g.Expect(IsPackageResource(cm, "ConfigMap/settings")).To(gomega.BeTrue())
+	g.Expect(IsPackageResource(cm, "Secret/settings")).To(gomega.BeFalse())
+}
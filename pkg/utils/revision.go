@@ -0,0 +1,70 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+const revisionDigestPrefix = "sha256:"
+
+// GetHelmIndexRevision returns the source revision of the chart versions of the filtered index file, a digest of
+// their names, versions, digests and URLs. It changes only if a subscribed chart version changes, not on the release
+// of other charts of the repository.
+func GetHelmIndexRevision(indexFile *repo.IndexFile) string {
+	h := sha256.New()
+
+	if indexFile != nil {
+		for _, name := range SortedChartNames(indexFile) {
+			for _, chartVersion := range indexFile.Entries[name] {
+				if chartVersion == nil || chartVersion.Metadata == nil {
+					continue
+				}
+
+				h.Write([]byte(strings.Join([]string{name, chartVersion.Version, chartVersion.Digest,
+					strings.Join(chartVersion.URLs, ",")}, "\x00") + "\n"))
+			}
+		}
+	}
+
+	return revisionDigestPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// GetObjectsRevision returns the source revision of the objects of a bucket listing, a digest of their keys and
+// contents like an etag of the listing
+func GetObjectsRevision(objects map[string][]byte) string {
+	keys := make([]string, 0, len(objects))
+
+	for key := range objects {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	h := sha256.New()
+
+	for _, key := range keys {
+		content := sha256.Sum256(objects[key])
+
+		h.Write([]byte(key + "\x00" + hex.EncodeToString(content[:]) + "\n"))
+	}
+
+	return revisionDigestPrefix + hex.EncodeToString(h.Sum(nil))
+}
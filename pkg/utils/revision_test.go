@@ -0,0 +1,59 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func revisionIndex(versions map[string]string) *repo.IndexFile {
+	indexFile := repo.NewIndexFile()
+
+	for name, version := range versions {
+		indexFile.Entries[name] = repo.ChartVersions{{
+			Metadata: &chart.Metadata{Name: name, Version: version},
+			URLs:     []string{"https://charts.example.com/" + name + "-" + version + ".tgz"},
+			Digest:   "digest-" + name + "-" + version,
+		}}
+	}
+
+	return indexFile
+}
+
+func TestSourceRevisions(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	revision := GetHelmIndexRevision(revisionIndex(map[string]string{"nginx": "1.0.0", "redis": "2.0.0"}))
+	g.Expect(revision).To(gomega.HavePrefix("sha256:"))
+	g.Expect(GetHelmIndexRevision(revisionIndex(map[string]string{"redis": "2.0.0", "nginx": "1.0.0"}))).To(gomega.Equal(revision))
+	g.Expect(GetHelmIndexRevision(revisionIndex(map[string]string{"nginx": "1.0.1", "redis": "2.0.0"}))).NotTo(gomega.Equal(revision))
+	g.Expect(GetHelmIndexRevision(nil)).To(gomega.HavePrefix("sha256:"))
+
+	objects := map[string][]byte{"app/cm.yaml": []byte("kind: ConfigMap"), "app/deploy.yaml": []byte("kind: Deployment")}
+
+	revision = GetObjectsRevision(objects)
+	g.Expect(revision).To(gomega.HavePrefix("sha256:"))
+
+	objects["app/cm.yaml"] = []byte("kind: ConfigMap\ndata: {}")
+	g.Expect(GetObjectsRevision(objects)).NotTo(gomega.Equal(revision))
+
+	// a renamed object changes the revision
+	g.Expect(GetObjectsRevision(map[string][]byte{"cm.yaml": []byte("a")})).
+		NotTo(gomega.Equal(GetObjectsRevision(map[string][]byte{"configmap.yaml": []byte("a")})))
+}
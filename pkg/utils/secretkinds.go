@@ -0,0 +1,42 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type secretOperatorKind struct {
+	operator   string
+	namespaced bool
+}
+
+// secretOperatorKinds are the kinds of the secret operators the Git repositories commonly keep their secrets with.
+// They are supported before their CRD is installed, since the operators are often installed by the same appsubs.
+var secretOperatorKinds = map[schema.GroupKind]secretOperatorKind{
+	{Group: "bitnami.com", Kind: "SealedSecret"}:                  {operator: "Sealed Secrets", namespaced: true},
+	{Group: "external-secrets.io", Kind: "ExternalSecret"}:        {operator: "External Secrets", namespaced: true},
+	{Group: "external-secrets.io", Kind: "SecretStore"}:           {operator: "External Secrets", namespaced: true},
+	{Group: "external-secrets.io", Kind: "ClusterSecretStore"}:    {operator: "External Secrets", namespaced: false},
+	{Group: "external-secrets.io", Kind: "ClusterExternalSecret"}: {operator: "External Secrets", namespaced: false},
+}
+
+// GetSecretOperatorKind returns the name of the secret operator serving the kind and the scope of the kind, false if
+// the kind is not one of the SealedSecret or ExternalSecret kinds
+func GetSecretOperatorKind(gk schema.GroupKind) (operator string, namespaced bool, ok bool) {
+	kind, ok := secretOperatorKinds[gk]
+
+	return kind.operator, kind.namespaced, ok
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+)
+
+// sopsEncrypt encrypts the value the way SOPS does, authenticated with the path of its keys
+func sopsEncrypt(g *gomega.WithT, dataKey []byte, value, valueType string, path ...string) string {
+	return sopsEncryptData(g, dataKey, value, valueType, strings.Join(path, ":")+":")
+}
+
+// sopsMAC returns the message authentication code of the values, encrypted the way SOPS does
+func sopsMAC(g *gomega.WithT, dataKey []byte, lastModified string, values ...string) string {
+	sum := sha512.Sum512([]byte(strings.Join(values, "")))
+
+	return sopsEncryptData(g, dataKey, fmt.Sprintf("%X", sum), "str", lastModified)
+}
+
+func sopsEncryptData(g *gomega.WithT, dataKey []byte, value, valueType, additionalData string) string {
+	block, err := aes.NewCipher(dataKey)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, 32)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	iv := make([]byte, 32)
+	_, err = rand.Read(iv)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	sealed := gcm.Seal(nil, iv, []byte(value), []byte(additionalData))
+	data, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return fmt.Sprintf("ENC[AES256_GCM,data:%v,iv:%v,tag:%v,type:%v]", base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv), base64.StdEncoding.EncodeToString(tag), valueType)
+}
+
+func TestDecryptSopsDocument(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	identity, err := age.GenerateX25519Identity()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	other, err := age.GenerateX25519Identity()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	dataKey := make([]byte, 32)
+	_, err = rand.Read(dataKey)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	encryptedKey := &bytes.Buffer{}
+	aw := armor.NewWriter(encryptedKey)
+	w, err := age.Encrypt(aw, identity.Recipient())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	_, err = w.Write(dataKey)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(w.Close()).To(gomega.Succeed())
+	g.Expect(aw.Close()).To(gomega.Succeed())
+
+	lastModified := "2021-06-01T12:00:00Z"
+
+	// the values in the order of the keys of the marshalled document
+	mac := sopsMAC(g, dataKey, lastModified, "v1", "Secret", "db", "db1", "True", "s3cr3t", "5432")
+
+	doc, err := yaml.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "db"},
+		"stringData": map[string]interface{}{
+			"password": sopsEncrypt(g, dataKey, "s3cr3t", "str", "stringData", "password"),
+			"port":     sopsEncrypt(g, dataKey, "5432", "int", "stringData", "port"),
+			"hosts": []interface{}{
+				sopsEncrypt(g, dataKey, "db1", "str", "stringData", "hosts"),
+				sopsEncrypt(g, dataKey, "true", "bool", "stringData", "hosts"),
+			},
+		},
+		"sops": map[string]interface{}{
+			"mac":          mac,
+			"lastmodified": lastModified,
+			"age": []interface{}{map[string]interface{}{"recipient": identity.Recipient().String(),
+				"enc": encryptedKey.String()}},
+		},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	g.Expect(IsSopsEncrypted(doc)).To(gomega.BeTrue())
+	g.Expect(IsSopsEncrypted([]byte("kind: ConfigMap\nsops: true"))).To(gomega.BeFalse())
+
+	_, err = DecryptSopsDocument(doc, nil)
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	_, err = DecryptSopsDocument(doc, []string{other.String()})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	decrypted, err := DecryptSopsDocument(doc, []string{other.String(), identity.String()})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(IsSopsEncrypted(decrypted)).To(gomega.BeFalse())
+
+	content := map[string]interface{}{}
+	g.Expect(yaml.Unmarshal(decrypted, &content)).To(gomega.Succeed())
+	g.Expect(content).NotTo(gomega.HaveKey("sops"))
+	g.Expect(content["stringData"]).To(gomega.Equal(map[string]interface{}{
+		"password": "s3cr3t",
+		"port":     float64(5432),
+		"hosts":    []interface{}{"db1", true},
+	}))
+
+	// a value moved to another key fails its authentication
+	tampered := strings.Replace(string(doc), "password:", "user:", 1)
+	_, err = DecryptSopsDocument([]byte(tampered), []string{identity.String()})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	// a plain value changed after the encryption fails the message authentication code
+	tampered = strings.Replace(string(doc), "name: db", "name: other", 1)
+	_, err = DecryptSopsDocument([]byte(tampered), []string{identity.String()})
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("message authentication code doesn't match")))
+
+	// the message authentication code of a file covers all its documents
+	docs := [][]byte{}
+
+	for _, name := range []string{"db", "cache"} {
+		docs = append(docs, []byte(fmt.Sprintf(`apiVersion: v1
+kind: Secret
+metadata:
+  name: %v
+stringData:
+  password: %v
+sops:
+  mac: %v
+  lastmodified: "%v"
+  age:
+  - enc: |
+%v
+`, name, sopsEncrypt(g, dataKey, name+"-s3cr3t", "str", "stringData", "password"),
+			sopsMAC(g, dataKey, lastModified, "v1", "Secret", "db", "db-s3cr3t", "v1", "Secret", "cache", "cache-s3cr3t"),
+			lastModified, "      "+strings.ReplaceAll(strings.TrimSpace(encryptedKey.String()), "\n", "\n      "))))
+	}
+
+	decryptedDocs, err := DecryptSopsDocuments(docs, []string{identity.String()})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(decryptedDocs).To(gomega.HaveLen(2))
+	g.Expect(string(decryptedDocs[1])).To(gomega.ContainSubstring("password: cache-s3cr3t"))
+
+	_, err = DecryptSopsDocuments(docs[:1], []string{identity.String()})
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("message authentication code doesn't match")))
+}
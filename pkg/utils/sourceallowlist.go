@@ -0,0 +1,243 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	chnv1 "open-cluster-management.io/multicloud-operators-channel/pkg/apis/apps/v1"
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// ReasonSourceNotAllowed prefixes the subscription status reason when its channel references a host that is not in
+// the channel source allow-list
+const ReasonSourceNotAllowed = "SourceNotAllowed"
+
+// errSourceNotAllowed is wrapped by the errors of the URLs that are not in the channel source allow-list
+var errSourceNotAllowed = errors.New("not in the channel source allow-list")
+
+// sourceAllowList is the list of the hosts the channels may reference, by name or by address
+type sourceAllowList struct {
+	// hosts are lower case host names, the ones starting with *. match the subdomains of the rest of the name
+	hosts []string
+	cidrs []*net.IPNet
+}
+
+// channelSourceAllowList is the operator-wide channel source allow-list, nil if all the hosts are allowed
+var channelSourceAllowList *sourceAllowList
+
+// lookupIP resolves the channel hosts checked against the CIDRs of the allow-list
+var lookupIP = net.LookupIP
+
+// SetChannelSourceAllowList sets the hosts the channels may reference from a comma separated list of host names,
+// wildcard domains like *.example.com, IP addresses and CIDRs. All the hosts are allowed if the list is empty.
+func SetChannelSourceAllowList(list string) error {
+	channelSourceAllowList = nil
+
+	allowList := &sourceAllowList{}
+
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+
+		switch {
+		case entry == "":
+			continue
+		case strings.Contains(entry, "/"):
+			_, cidr, err := net.ParseCIDR(entry)
+			if err != nil {
+				return fmt.Errorf("invalid channel source allow-list CIDR %v, err: %w", entry, err)
+			}
+
+			allowList.cidrs = append(allowList.cidrs, cidr)
+		case net.ParseIP(entry) != nil:
+			ip := net.ParseIP(entry)
+			bits := 8 * len(ip.To16())
+
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+
+			allowList.cidrs = append(allowList.cidrs, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		default:
+			allowList.hosts = append(allowList.hosts, entry)
+		}
+	}
+
+	if len(allowList.hosts) == 0 && len(allowList.cidrs) == 0 {
+		return nil
+	}
+
+	klog.Infof("Channel source allow-list: hosts: %v, CIDRs: %v", allowList.hosts, allowList.cidrs)
+
+	channelSourceAllowList = allowList
+
+	return nil
+}
+
+// CheckChannelSourceAllowed returns an error if one of the channels references a host that is not in the channel
+// source allow-list, or a host that can't be found, in its pathname or its object store endpoint annotation. The nil
+// and namespace channels are not checked.
+func CheckChannelSourceAllowed(chns ...*chnv1.Channel) error {
+	if channelSourceAllowList == nil {
+		return nil
+	}
+
+	for _, chn := range chns {
+		if chn == nil || strings.EqualFold(string(chn.Spec.Type), chnv1.ChannelTypeNamespace) {
+			continue
+		}
+
+		host := GetChannelSourceHost(chn)
+		if host == "" {
+			return fmt.Errorf("the host of channel %v/%v can't be checked against the channel source allow-list", chn.Namespace, chn.Name)
+		}
+
+		if !channelSourceAllowList.allows(host) {
+			return fmt.Errorf("host %v of channel %v/%v is not in the channel source allow-list", host, chn.Namespace, chn.Name)
+		}
+
+		// the object store endpoint of the channel replaces the host of its pathname
+		if endpoint := strings.TrimSpace(chn.GetAnnotations()[appv1.AnnotationObjectStoreEndpoint]); endpoint != "" {
+			if err := CheckSourceURLAllowed(endpoint); err != nil {
+				return fmt.Errorf("object store endpoint of channel %v/%v: %w", chn.Namespace, chn.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// CheckSourceURLAllowed returns an error if the URL references a host that is not in the channel source allow-list,
+// e.g. the target of a redirect, a chart URL of a Helm repo index or a Git submodule URL. The relative URLs are
+// allowed, they reference the host of the channel.
+func CheckSourceURLAllowed(rawURL string) error {
+	if channelSourceAllowList == nil {
+		return nil
+	}
+
+	rawURL = strings.TrimSpace(rawURL)
+
+	if strings.HasPrefix(rawURL, "./") || strings.HasPrefix(rawURL, "../") {
+		return nil
+	}
+
+	host := getSourceURLHost(rawURL, true)
+	if host == "" {
+		return fmt.Errorf("the host of %v can't be checked against the channel source allow-list", RedactGitURL(rawURL))
+	}
+
+	if !channelSourceAllowList.allows(host) {
+		return fmt.Errorf("host %v of %v is %w", host, RedactGitURL(rawURL), errSourceNotAllowed)
+	}
+
+	return nil
+}
+
+// allowedSourceURLs returns the URLs in the channel source allow-list. If none is, the error of the last one is returned.
+func allowedSourceURLs(urls []string) ([]string, error) {
+	allowed := []string{}
+
+	var err error
+
+	for _, u := range urls {
+		if err = CheckSourceURLAllowed(u); err != nil {
+			klog.Info(err)
+
+			continue
+		}
+
+		allowed = append(allowed, u)
+	}
+
+	if len(allowed) == 0 && err != nil {
+		return nil, err
+	}
+
+	return allowed, nil
+}
+
+// GetChannelSourceHost returns the host of the channel pathname, empty if it has none
+func GetChannelSourceHost(chn *chnv1.Channel) string {
+	return getSourceURLHost(strings.TrimSpace(chn.Spec.Pathname), IsGitChannel(string(chn.Spec.Type)))
+}
+
+// getSourceURLHost returns the lower case host of the URL, empty if it has none. The scp-like SSH URLs are parsed
+// for the Git sources.
+func getSourceURLHost(rawURL string, git bool) string {
+	// the scp-like ssh git URLs, e.g. git@github.com:org/repo.git
+	if git && !strings.Contains(rawURL, "://") {
+		host, _ := ParseSSHHost(rawURL)
+
+		return strings.ToLower(host)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+func (l *sourceAllowList) allows(host string) bool {
+	for _, allowed := range l.hosts {
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return true
+		}
+	}
+
+	if len(l.cidrs) == 0 {
+		return false
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+
+	// the host names are allowed by CIDR if all their addresses are in the allow-list
+	if ips[0] == nil {
+		var err error
+
+		ips, err = lookupIP(host)
+		if err != nil || len(ips) == 0 {
+			klog.Warningf("failed to resolve channel source host %v, err: %v", host, err)
+
+			return false
+		}
+	}
+
+	for _, ip := range ips {
+		if !l.containsIP(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (l *sourceAllowList) containsIP(ip net.IP) bool {
+	for _, cidr := range l.cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,48 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+// strictSync is true if any package failure fails the sync of the Git subscriptions
+var strictSync = false
+
+// SetStrictSync sets if any package failure fails the sync of the Git subscriptions. In strict mode, the commit with
+// a failed package is not applied at all and is retried, the deployed commit only advances once all the packages of a
+// commit are applied.
+func SetStrictSync(enabled bool) {
+	if enabled {
+		klog.Info("Strict sync: the Git subscriptions are not synced while one of their packages fails")
+	}
+
+	strictSync = enabled
+}
+
+// IsStrictSync returns true if any package failure fails the sync of the Git subscriptions
+func IsStrictSync() bool {
+	return strictSync
+}
+
+// IsAtLeastOnce returns true if the at-least-once annotation of the subscription is true. The commits of the
+// subscription are retried until all their packages are applied, the packages that don't fail are applied meanwhile.
+func IsAtLeastOnce(sub *appv1.Subscription) bool {
+	return strings.EqualFold(sub.GetAnnotations()[appv1.AnnotationAtLeastOnce], "true")
+}
@@ -0,0 +1,44 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestStrictSync(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer SetStrictSync(false)
+
+	g.Expect(IsStrictSync()).To(gomega.BeFalse())
+
+	SetStrictSync(true)
+	g.Expect(IsStrictSync()).To(gomega.BeTrue())
+
+	sub := &appv1.Subscription{}
+	g.Expect(IsAtLeastOnce(sub)).To(gomega.BeFalse())
+
+	sub.ObjectMeta = metav1.ObjectMeta{Annotations: map[string]string{appv1.AnnotationAtLeastOnce: "True"}}
+	g.Expect(IsAtLeastOnce(sub)).To(gomega.BeTrue())
+
+	sub.Annotations[appv1.AnnotationAtLeastOnce] = "false"
+	g.Expect(IsAtLeastOnce(sub)).To(gomega.BeFalse())
+}
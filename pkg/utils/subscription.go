@@ -25,6 +25,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -36,11 +37,13 @@ import (
 	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
 	appsubReportV1alpha1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1alpha1"
 	managedClusterView "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/view/v1beta1"
+	"open-cluster-management.io/multicloud-operators-subscription/pkg/metrics"
 
 	corev1 "k8s.io/api/core/v1"
 	clientsetx "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/apimachinery/pkg/api/equality"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
@@ -321,6 +324,10 @@ func IsHubRelatedStatusChanged(old, nnew *appv1.SubscriptionStatus) bool {
 		return true
 	}
 
+	if !reflect.DeepEqual(old.Retarget, nnew.Retarget) {
+		return true
+	}
+
 	//care about the managed subscription status
 	if !isEqualSubClusterStatus(old.Statuses, nnew.Statuses) {
 		return true
@@ -365,6 +372,40 @@ var ChannelPredicateFunctions = predicate.Funcs{
 	},
 }
 
+// ChannelConfigMapPredicateFunctions filters config map data update, e.g. the rotation of the channel CA certificates
+var ChannelConfigMapPredicateFunctions = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		newCm := e.ObjectNew.(*corev1.ConfigMap)
+		oldCm := e.ObjectOld.(*corev1.ConfigMap)
+
+		return !reflect.DeepEqual(newCm.Data, oldCm.Data)
+	},
+	CreateFunc: func(e event.CreateEvent) bool {
+		return true
+	},
+
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return true
+	},
+}
+
+// ChannelSecretPredicateFunctions filters secret data update, e.g. the rotation of the channel credentials
+var ChannelSecretPredicateFunctions = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		newSecret := e.ObjectNew.(*corev1.Secret)
+		oldSecret := e.ObjectOld.(*corev1.Secret)
+
+		return !reflect.DeepEqual(newSecret.Data, oldSecret.Data)
+	},
+	CreateFunc: func(e event.CreateEvent) bool {
+		return true
+	},
+
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return true
+	},
+}
+
 // ServiceAccountPredicateFunctions watches for changes in klusterlet-addon-appmgr service account in open-cluster-management-agent-addon namespace
 var ServiceAccountPredicateFunctions = predicate.Funcs{
 	UpdateFunc: func(e event.UpdateEvent) bool {
@@ -640,6 +681,254 @@ func UpdateLastUpdateTime(clt client.Client, instance *appv1.Subscription) {
 	}
 }
 
+// ReasonResourceErrors prefixes the subscription status reason when some of the subscribed resources failed
+const ReasonResourceErrors = "ResourceErrors"
+
+// UpdateFailureReasonStatus sets the subscription failed with the message, its reason prefixed by reasonType, or
+// clears a previous failure of the same reasonType if msg is empty.
+func UpdateFailureReasonStatus(clt client.Client, instance *appv1.Subscription, reasonType, msg string) {
+	curSub := &appv1.Subscription{}
+	if err := clt.Get(context.TODO(), types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}, curSub); err != nil {
+		klog.Warningf("Failed to get appsub to update the %v status, err: %v", reasonType, err)
+		return
+	}
+
+	reason := ""
+	if msg != "" {
+		reason = reasonType + ": " + msg
+	}
+
+	if curSub.Status.Reason == reason {
+		return
+	}
+
+	if msg == "" {
+		if !strings.HasPrefix(curSub.Status.Reason, reasonType) {
+			return
+		}
+
+		curSub.Status.Phase = appv1.SubscriptionSubscribed
+	} else {
+		curSub.Status.Phase = appv1.SubscriptionFailed
+	}
+
+	curSub.Status.Reason = reason
+
+	if err := clt.Status().Update(context.TODO(), curSub); err != nil {
+		klog.Warningf("Failed to update the %v status, err: %v", reasonType, err)
+	}
+}
+
+// UpdateOutOfSyncStatus sets the out of sync since time of the appsub on the first failed sync and clears it on the
+// next successful one
+func UpdateOutOfSyncStatus(clt client.Client, instance *appv1.Subscription, inSync bool) {
+	hostSub := types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}
+
+	curSub := &appv1.Subscription{}
+	if err := clt.Get(context.TODO(), hostSub, curSub); err != nil {
+		klog.Warning("Failed to get appsub to update OutOfSyncSince", err)
+		return
+	}
+
+	if inSync {
+		metrics.RecordInSync(hostSub)
+
+		if curSub.Status.OutOfSyncSince == nil {
+			return
+		}
+
+		klog.Infof("appsub %v is in sync again, it was out of sync since %v", hostSub, curSub.Status.OutOfSyncSince)
+
+		curSub.Status.OutOfSyncSince = nil
+	} else {
+		if curSub.Status.OutOfSyncSince != nil {
+			metrics.RecordOutOfSync(hostSub, curSub.Status.OutOfSyncSince.Time)
+			return
+		}
+
+		now := metav1.Now()
+		curSub.Status.OutOfSyncSince = &now
+
+		metrics.RecordOutOfSync(hostSub, now.Time)
+	}
+
+	if err := clt.Status().Update(context.TODO(), curSub); err != nil {
+		klog.Warning("Failed to update OutOfSyncSince", err)
+	}
+}
+
+// UpdateDeployedCommitStatus records the Git commit deployed to the cluster, if any yet, and whether it is the desired
+// commit of the subscription
+func UpdateDeployedCommitStatus(clt client.Client, instance *appv1.Subscription, deployedCommit, desiredCommit string) {
+	curSub := &appv1.Subscription{}
+	if err := clt.Get(context.TODO(), types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}, curSub); err != nil {
+		klog.Warning("Failed to get appsub to update the deployed commit", err)
+		return
+	}
+
+	newStatus := curSub.Status.DeepCopy()
+
+	if deployedCommit != "" {
+		newStatus.DeployedCommit = deployedCommit
+	}
+
+	newStatus.DesiredCommitSynced = nil
+
+	if desiredCommit = strings.TrimSpace(desiredCommit); desiredCommit != "" {
+		synced := strings.EqualFold(desiredCommit, newStatus.DeployedCommit)
+		newStatus.DesiredCommitSynced = &synced
+	}
+
+	if reflect.DeepEqual(newStatus, &curSub.Status) {
+		return
+	}
+
+	if newStatus.DesiredCommitSynced != nil && !*newStatus.DesiredCommitSynced {
+		klog.Infof("appsub %v/%v is not synced to the desired commit %v, deployed commit: %v",
+			curSub.Namespace, curSub.Name, desiredCommit, newStatus.DeployedCommit)
+	}
+
+	curSub.Status = *newStatus
+
+	if err := clt.Status().Update(context.TODO(), curSub); err != nil {
+		klog.Warning("Failed to update the deployed commit", err)
+	}
+}
+
+// UpdateSourceStatus records in the appsub status where the resources applied to the cluster were read from, and
+// their source revision
+func UpdateSourceStatus(clt client.Client, instance *appv1.Subscription, source *appv1.SubscriptionSource, revision string) {
+	curSub := &appv1.Subscription{}
+	if err := clt.Get(context.TODO(), types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}, curSub); err != nil {
+		klog.Warning("Failed to get appsub to update the source", err)
+		return
+	}
+
+	if reflect.DeepEqual(source, curSub.Status.Source) && revision == curSub.Status.SourceRevision {
+		return
+	}
+
+	curSub.Status.Source = source
+	curSub.Status.SourceRevision = revision
+
+	if err := clt.Status().Update(context.TODO(), curSub); err != nil {
+		klog.Warning("Failed to update the source", err)
+	}
+}
+
+// GetApplyBatchSize returns the number of resources applied between two apply progress updates of the appsub, from
+// its apply-batch-size annotation
+func GetApplyBatchSize(instance *appv1.Subscription) int {
+	batchSize := appv1.DefaultApplyBatchSize
+
+	if size := instance.GetAnnotations()[appv1.AnnotationApplyBatchSize]; size != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil || n <= 0 {
+			klog.Warningf("invalid %v annotation %q of appsub %v/%v, using %d", appv1.AnnotationApplyBatchSize, size,
+				instance.GetNamespace(), instance.GetName(), batchSize)
+
+			return batchSize
+		}
+
+		batchSize = n
+	}
+
+	return batchSize
+}
+
+// UpdateApplyProgressStatus records in the appsub status that applied of the total resources are applied
+func UpdateApplyProgressStatus(clt client.Client, instance *appv1.Subscription, applied, total int) {
+	curSub := &appv1.Subscription{}
+	if err := clt.Get(context.TODO(), types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}, curSub); err != nil {
+		klog.Warning("Failed to get appsub to update the apply progress", err)
+		return
+	}
+
+	progress := &appv1.ApplyProgress{
+		Applied: applied,
+		Total:   total,
+		Message: fmt.Sprintf("applied %d of %d", applied, total),
+	}
+
+	if reflect.DeepEqual(progress, curSub.Status.ApplyProgress) {
+		return
+	}
+
+	curSub.Status.ApplyProgress = progress
+
+	if err := clt.Status().Update(context.TODO(), curSub); err != nil {
+		klog.Warning("Failed to update the apply progress", err)
+	}
+}
+
+// UpdateDesiredStateHashStatus records in the appsub status the desired state hash of the resources applied to the
+// cluster
+func UpdateDesiredStateHashStatus(clt client.Client, instance *appv1.Subscription, stateHash string) {
+	curSub := &appv1.Subscription{}
+	if err := clt.Get(context.TODO(), types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}, curSub); err != nil {
+		klog.Warning("Failed to get appsub to update the desired state hash", err)
+		return
+	}
+
+	if curSub.Status.DesiredStateHash == stateHash {
+		return
+	}
+
+	curSub.Status.DesiredStateHash = stateHash
+
+	if err := clt.Status().Update(context.TODO(), curSub); err != nil {
+		klog.Warning("Failed to update the desired state hash", err)
+	}
+}
+
+const (
+	// ReasonUnexpectedRepoStructure is the reason of the Degraded condition of the subscriptions whose repo paths
+	// don't have the structure expected by their filterRef
+	ReasonUnexpectedRepoStructure = "UnexpectedRepoStructure"
+	// ReasonExpectedRepoStructure is the reason of the Degraded condition cleared once the repo paths have the
+	// expected structure
+	ReasonExpectedRepoStructure = "ExpectedRepoStructure"
+)
+
+// UpdateDegradedCondition sets the Degraded condition of the appsub, with the reason and message of the failure. A
+// subscription never degraded gets no condition when it is not degraded.
+func UpdateDegradedCondition(clt client.Client, instance *appv1.Subscription, degraded bool, reason, msg string) {
+	curSub := &appv1.Subscription{}
+	if err := clt.Get(context.TODO(), types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}, curSub); err != nil {
+		klog.Warning("Failed to get appsub to update the Degraded condition", err)
+		return
+	}
+
+	condition := metav1.Condition{
+		Type:               appv1.SubscriptionDegraded,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: curSub.Generation,
+		Reason:             reason,
+		Message:            msg,
+	}
+
+	if degraded {
+		condition.Status = metav1.ConditionTrue
+	}
+
+	cur := meta.FindStatusCondition(curSub.Status.Conditions, appv1.SubscriptionDegraded)
+
+	if cur == nil && !degraded {
+		return
+	}
+
+	if cur != nil && cur.Status == condition.Status && cur.Reason == condition.Reason && cur.Message == condition.Message &&
+		cur.ObservedGeneration == condition.ObservedGeneration {
+		return
+	}
+
+	meta.SetStatusCondition(&curSub.Status.Conditions, condition)
+
+	if err := clt.Status().Update(context.TODO(), curSub); err != nil {
+		klog.Warning("Failed to update the Degraded condition", err)
+	}
+}
+
 // OverrideResourceBySubscription alter the given template with overrides
 func OverrideResourceBySubscription(template *unstructured.Unstructured,
 	pkgName string, instance *appv1.Subscription) (*unstructured.Unstructured, error) {
@@ -725,6 +1014,38 @@ func GetPauseLabel(instance *appv1.Subscription) bool {
 	return false
 }
 
+// GetSubscriptionPackages returns the package names of the spec.package comma separated list of the subscription,
+// none if all the packages of the channel are subscribed
+func GetSubscriptionPackages(instance *appv1.Subscription) []string {
+	packages := []string{}
+
+	for _, name := range strings.Split(instance.Spec.Package, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			packages = append(packages, name)
+		}
+	}
+
+	return packages
+}
+
+// IsSubscribedPackage returns true if the package name is in the spec.package list of the subscription, or if the
+// list is empty
+func IsSubscribedPackage(instance *appv1.Subscription, name string) bool {
+	packages := GetSubscriptionPackages(instance)
+
+	if len(packages) == 0 {
+		return true
+	}
+
+	for _, pkg := range packages {
+		if pkg == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 // AllowApplyTemplate check if the template is allowed to apply based on its hosting subscription pause label
 // return false if the hosting subscription is paused.
 func AllowApplyTemplate(localClient client.Client, template *unstructured.Unstructured) bool {
@@ -793,6 +1114,27 @@ func IsResourceDenied(resource unstructured.Unstructured, denyList map[string]ma
 	return false
 }
 
+// CheckResourceAllowDeny returns an error if the resource is on the deny list or is not on the allow list of the
+// subscription.
+func CheckResourceAllowDeny(resource unstructured.Unstructured, allowlist, denyList map[string]map[string]string, isAdmin bool) error {
+	if IsResourceDenied(resource, denyList, isAdmin) {
+		return fmt.Errorf("the resource apiVersion: %s kind: %s is on the deny list. Not deployed",
+			resource.GetAPIVersion(), resource.GetKind())
+	}
+
+	if !IsResourceAllowed(resource, allowlist, isAdmin) {
+		if !isAdmin {
+			return fmt.Errorf("not deployed by a subscription admin. the resource apiVersion: %s kind: %s is not deployed",
+				resource.GetAPIVersion(), resource.GetKind())
+		}
+
+		return fmt.Errorf("the resource apiVersion: %s kind: %s is not on the allow list. Not deployed",
+			resource.GetAPIVersion(), resource.GetKind())
+	}
+
+	return nil
+}
+
 // GetAllowDenyLists returns subscription's allow and deny lists as maps. It returns empty map if there is no list.
 func GetAllowDenyLists(subscription appv1.Subscription) (map[string]map[string]string, map[string]map[string]string) {
 	allowedGroupResources := make(map[string]map[string]string)
@@ -889,6 +1231,7 @@ func RemoveSubAnnotations(obj *unstructured.Unstructured) *unstructured.Unstruct
 		delete(objanno, appv1.AnnotationSyncSource)
 		delete(objanno, appv1.AnnotationHostingDeployable)
 		delete(objanno, appv1.AnnotationChannelType)
+		delete(objanno, appv1.AnnotationGeneratorVersion)
 	}
 
 	if len(objanno) > 0 {
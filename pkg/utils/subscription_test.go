@@ -33,6 +33,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	manifestWorkV1 "open-cluster-management.io/api/work/v1"
@@ -1109,6 +1110,36 @@ func TestIsResourceDenied(t *testing.T) {
 	g.Expect(IsResourceDenied(resource, allowlist, true)).To(BeTrue())
 }
 
+func TestCheckResourceAllowDeny(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	sub := appv1.Subscription{}
+	sub.Spec.Allow = []*appv1.AllowDenyItem{{APIVersion: "v1", Kinds: []string{"*"}}}
+	sub.Spec.Deny = []*appv1.AllowDenyItem{{APIVersion: "v1", Kinds: []string{"Secret"}}}
+
+	allowlist, denyList := GetAllowDenyLists(sub)
+
+	resource := unstructured.Unstructured{}
+	resource.SetAPIVersion("v1")
+	resource.SetKind("ConfigMap")
+
+	g.Expect(CheckResourceAllowDeny(resource, allowlist, denyList, true)).To(Succeed())
+
+	resource.SetKind("Secret")
+	g.Expect(CheckResourceAllowDeny(resource, allowlist, denyList, true)).To(MatchError(ContainSubstring("is on the deny list")))
+
+	resource.SetAPIVersion("rbac.authorization.k8s.io/v1")
+	resource.SetKind("ClusterRole")
+	g.Expect(CheckResourceAllowDeny(resource, allowlist, denyList, true)).To(MatchError(ContainSubstring("is not on the allow list")))
+
+	// the lists of a subscription not created by a subscription admin are ignored
+	g.Expect(CheckResourceAllowDeny(resource, allowlist, denyList, false)).To(Succeed())
+
+	resource.SetAPIVersion("policy.open-cluster-management.io/v1")
+	resource.SetKind("Policy")
+	g.Expect(CheckResourceAllowDeny(resource, allowlist, denyList, false)).To(MatchError(ContainSubstring("not deployed by a subscription admin")))
+}
+
 func TestGetAllowDenyLists(t *testing.T) {
 	g := NewGomegaWithT(t)
 
@@ -1320,3 +1351,152 @@ func TestSetPartOfLabel(t *testing.T) {
 	g.Expect(labels).NotTo(BeNil())
 	g.Expect(labels["app.kubernetes.io/part-of"]).To(Equal("testApp"))
 }
+
+func TestUpdateOutOfSyncStatus(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(appv1.SchemeBuilder.AddToScheme(s)).To(Succeed())
+
+	sub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "git-sub",
+			Namespace: "default",
+		},
+	}
+
+	clt := fake.NewClientBuilder().WithScheme(s).WithObjects(sub).Build()
+	key := types.NamespacedName{Name: sub.Name, Namespace: sub.Namespace}
+
+	UpdateOutOfSyncStatus(clt, sub, false)
+
+	curSub := &appv1.Subscription{}
+	g.Expect(clt.Get(context.TODO(), key, curSub)).To(Succeed())
+	g.Expect(curSub.Status.OutOfSyncSince).NotTo(BeNil())
+
+	since := curSub.Status.OutOfSyncSince.DeepCopy()
+
+	// the first failure is kept on the following failures
+	UpdateOutOfSyncStatus(clt, sub, false)
+
+	g.Expect(clt.Get(context.TODO(), key, curSub)).To(Succeed())
+	g.Expect(curSub.Status.OutOfSyncSince.Equal(since)).To(BeTrue())
+
+	UpdateOutOfSyncStatus(clt, sub, true)
+
+	g.Expect(clt.Get(context.TODO(), key, curSub)).To(Succeed())
+	g.Expect(curSub.Status.OutOfSyncSince).To(BeNil())
+}
+
+func TestUpdateFailureReasonStatus(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(appv1.SchemeBuilder.AddToScheme(s)).To(Succeed())
+
+	sub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "git-sub",
+			Namespace: "default",
+		},
+	}
+
+	clt := fake.NewClientBuilder().WithScheme(s).WithObjects(sub).Build()
+	key := types.NamespacedName{Name: sub.Name, Namespace: sub.Namespace}
+
+	UpdateFailureReasonStatus(clt, sub, ReasonResourceErrors, "bad.yaml: invalid")
+
+	curSub := &appv1.Subscription{}
+	g.Expect(clt.Get(context.TODO(), key, curSub)).To(Succeed())
+	g.Expect(curSub.Status.Phase).To(Equal(appv1.SubscriptionFailed))
+	g.Expect(curSub.Status.Reason).To(Equal(ReasonResourceErrors + ": bad.yaml: invalid"))
+
+	// a failure of another type is not cleared
+	UpdateFailureReasonStatus(clt, sub, ReasonPolicyViolation, "")
+
+	g.Expect(clt.Get(context.TODO(), key, curSub)).To(Succeed())
+	g.Expect(curSub.Status.Phase).To(Equal(appv1.SubscriptionFailed))
+
+	UpdateFailureReasonStatus(clt, sub, ReasonResourceErrors, "")
+
+	g.Expect(clt.Get(context.TODO(), key, curSub)).To(Succeed())
+	g.Expect(curSub.Status.Phase).To(Equal(appv1.SubscriptionSubscribed))
+	g.Expect(curSub.Status.Reason).To(BeEmpty())
+}
+
+func TestUpdateDeployedCommitStatus(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(appv1.SchemeBuilder.AddToScheme(s)).To(Succeed())
+
+	sub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "git-sub",
+			Namespace: "default",
+		},
+	}
+
+	clt := fake.NewClientBuilder().WithScheme(s).WithObjects(sub).Build()
+	key := types.NamespacedName{Name: sub.Name, Namespace: sub.Namespace}
+	curSub := &appv1.Subscription{}
+
+	// nothing is deployed yet
+	UpdateDeployedCommitStatus(clt, sub, "", "4f7a2b1c")
+
+	g.Expect(clt.Get(context.TODO(), key, curSub)).To(Succeed())
+	g.Expect(curSub.Status.DeployedCommit).To(BeEmpty())
+	g.Expect(curSub.Status.DesiredCommitSynced).NotTo(BeNil())
+	g.Expect(*curSub.Status.DesiredCommitSynced).To(BeFalse())
+
+	UpdateDeployedCommitStatus(clt, sub, "4f7a2b1c", " 4F7A2B1C")
+
+	g.Expect(clt.Get(context.TODO(), key, curSub)).To(Succeed())
+	g.Expect(curSub.Status.DeployedCommit).To(Equal("4f7a2b1c"))
+	g.Expect(*curSub.Status.DesiredCommitSynced).To(BeTrue())
+
+	// the deployed commit is kept until a new one is deployed
+	UpdateDeployedCommitStatus(clt, sub, "", "")
+
+	g.Expect(clt.Get(context.TODO(), key, curSub)).To(Succeed())
+	g.Expect(curSub.Status.DeployedCommit).To(Equal("4f7a2b1c"))
+	g.Expect(curSub.Status.DesiredCommitSynced).To(BeNil())
+}
+
+func TestUpdateDegradedCondition(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s := runtime.NewScheme()
+	g.Expect(appv1.SchemeBuilder.AddToScheme(s)).To(Succeed())
+
+	sub := &appv1.Subscription{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "helm-sub",
+			Namespace: "default",
+		},
+	}
+
+	clt := fake.NewClientBuilder().WithScheme(s).WithObjects(sub).Build()
+	key := types.NamespacedName{Name: sub.Name, Namespace: sub.Namespace}
+
+	// nothing to clear on a subscription never degraded
+	UpdateDegradedCondition(clt, sub, false, "ChartRepoAvailable", "")
+
+	curSub := &appv1.Subscription{}
+	g.Expect(clt.Get(context.TODO(), key, curSub)).To(Succeed())
+	g.Expect(curSub.Status.Conditions).To(BeEmpty())
+
+	UpdateDegradedCondition(clt, sub, true, "ChartRepoUnavailable", "status 503 Service Unavailable")
+
+	g.Expect(clt.Get(context.TODO(), key, curSub)).To(Succeed())
+	g.Expect(curSub.Status.Conditions).To(HaveLen(1))
+	g.Expect(curSub.Status.Conditions[0].Type).To(Equal(appv1.SubscriptionDegraded))
+	g.Expect(curSub.Status.Conditions[0].Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(curSub.Status.Conditions[0].Reason).To(Equal("ChartRepoUnavailable"))
+
+	UpdateDegradedCondition(clt, sub, false, "ChartRepoAvailable", "")
+
+	g.Expect(clt.Get(context.TODO(), key, curSub)).To(Succeed())
+	g.Expect(curSub.Status.Conditions).To(HaveLen(1))
+	g.Expect(curSub.Status.Conditions[0].Status).To(Equal(metav1.ConditionFalse))
+}
@@ -0,0 +1,412 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+const (
+	// SubscriberStateStoreMemory keeps the subscriber states in memory, they are lost when the controller restarts
+	SubscriberStateStoreMemory = "memory"
+	// SubscriberStateStoreStatus saves the subscriber states in the status of the subscriptions, without their
+	// inventory, which could make the subscriptions exceed the size limit of the objects
+	SubscriberStateStoreStatus = "status"
+	// SubscriberStateStoreConfigMap saves the subscriber states in a config map next to each subscription
+	SubscriberStateStoreConfigMap = "configmap"
+	// SubscriberStateStoreKV saves the subscriber states in an external key value store, under
+	// <url>/<cluster>/<namespace>/<name> keys
+	SubscriberStateStoreKV = "kv"
+
+	// SubscriberStateConfigMapSuffix is the suffix of the names of the subscriber state config maps
+	SubscriberStateConfigMapSuffix = "-subscriber-state"
+	// SubscriberStateConfigMapKey is the key of the subscriber state in its config map
+	SubscriberStateConfigMapKey = "state.json"
+
+	subscriberStateKVTimeout = 10 * time.Second
+)
+
+// SubscriberStateStore persists the runtime state of the subscribers of the subscriptions, so a restarted
+// controller, or the controller a subscription moved to, resumes the subscription from its last sync.
+type SubscriberStateStore interface {
+	// Get returns the subscriber state of the subscription, nil if it has none
+	Get(clt client.Client, sub *appv1.Subscription) (*appv1.SubscriberState, error)
+	Set(clt client.Client, sub *appv1.Subscription, state *appv1.SubscriberState) error
+	Delete(clt client.Client, sub *appv1.Subscription) error
+}
+
+var subscriberStateStore SubscriberStateStore = NewMemoryStateStore()
+
+// SetSubscriberStateStore sets the store of the subscriber states
+func SetSubscriberStateStore(store SubscriberStateStore) {
+	subscriberStateStore = store
+}
+
+// NewSubscriberStateStore returns the subscriber state store of the kind. The key value store needs the URL of its
+// HTTP API, the file of its bearer token if it requires one, and the name of the cluster, since the clusters may
+// share the store and have subscriptions of the same namespace and name.
+func NewSubscriberStateStore(kind, kvURL, kvTokenFile, clusterName string) (SubscriberStateStore, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "", SubscriberStateStoreMemory:
+		return NewMemoryStateStore(), nil
+	case SubscriberStateStoreStatus:
+		return &statusStateStore{}, nil
+	case SubscriberStateStoreConfigMap:
+		return &configMapStateStore{}, nil
+	case SubscriberStateStoreKV:
+		u, err := url.Parse(kvURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid subscriber state key value store URL %q", kvURL)
+		}
+
+		if clusterName == "" {
+			return nil, fmt.Errorf("the subscriber state key value store needs the name of the cluster, its keys are " +
+				"per cluster")
+		}
+
+		return &kvStateStore{
+			url:       strings.TrimSuffix(kvURL, "/") + "/" + url.PathEscape(clusterName),
+			tokenFile: kvTokenFile,
+			client:    &http.Client{Timeout: subscriberStateKVTimeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown subscriber state store %q, it must be %v, %v, %v or %v", kind,
+			SubscriberStateStoreMemory, SubscriberStateStoreStatus, SubscriberStateStoreConfigMap, SubscriberStateStoreKV)
+	}
+}
+
+// SetupSubscriberStateStore sets the store of the subscriber states to a new store of the kind
+func SetupSubscriberStateStore(kind, kvURL, kvTokenFile, clusterName string) error {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	if kind == "" {
+		kind = SubscriberStateStoreMemory
+	}
+
+	store, err := NewSubscriberStateStore(kind, kvURL, kvTokenFile, clusterName)
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("The subscriber states are saved in the %v store", kind)
+
+	subscriberStateStore = store
+
+	return nil
+}
+
+// LoadSubscriberState returns the subscriber state of the subscription, nil if it has none or it can't be read
+func LoadSubscriberState(clt client.Client, sub *appv1.Subscription) *appv1.SubscriberState {
+	state, err := subscriberStateStore.Get(clt, sub)
+	if err != nil {
+		klog.Warningf("Failed to load the subscriber state of %v/%v, err: %v", sub.Namespace, sub.Name, err)
+
+		return nil
+	}
+
+	return state
+}
+
+// SaveSubscriberState saves the subscriber state of the subscription if it changed since it was last saved, which
+// is returned
+func SaveSubscriberState(clt client.Client, sub *appv1.Subscription,
+	state, saved *appv1.SubscriberState) *appv1.SubscriberState {
+	if reflect.DeepEqual(state, saved) {
+		return saved
+	}
+
+	if err := subscriberStateStore.Set(clt, sub, state); err != nil {
+		klog.Warningf("Failed to save the subscriber state of %v/%v, err: %v", sub.Namespace, sub.Name, err)
+
+		return saved
+	}
+
+	return state.DeepCopy()
+}
+
+// DeleteSubscriberState deletes the subscriber state of the subscription
+func DeleteSubscriberState(clt client.Client, sub *appv1.Subscription) {
+	if err := subscriberStateStore.Delete(clt, sub); err != nil {
+		klog.Warningf("Failed to delete the subscriber state of %v/%v, err: %v", sub.Namespace, sub.Name, err)
+	}
+}
+
+// GetInventoryKey returns the key of a resource in the inventory of a subscriber state
+func GetInventoryKey(apiVersion, kind, namespace, name string) string {
+	return strings.Join([]string{apiVersion, kind, namespace, name}, "/")
+}
+
+// MemoryStateStore keeps the subscriber states in memory
+type MemoryStateStore struct {
+	mu     sync.Mutex
+	states map[types.NamespacedName]*appv1.SubscriberState
+}
+
+var _ SubscriberStateStore = &MemoryStateStore{}
+
+// NewMemoryStateStore returns an empty memory subscriber state store
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{states: map[types.NamespacedName]*appv1.SubscriberState{}}
+}
+
+// Get returns the subscriber state of the subscription
+func (m *MemoryStateStore) Get(clt client.Client, sub *appv1.Subscription) (*appv1.SubscriberState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.states[types.NamespacedName{Namespace: sub.Namespace, Name: sub.Name}].DeepCopy(), nil
+}
+
+// Set sets the subscriber state of the subscription
+func (m *MemoryStateStore) Set(clt client.Client, sub *appv1.Subscription, state *appv1.SubscriberState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.states[types.NamespacedName{Namespace: sub.Namespace, Name: sub.Name}] = state.DeepCopy()
+
+	return nil
+}
+
+// Delete deletes the subscriber state of the subscription
+func (m *MemoryStateStore) Delete(clt client.Client, sub *appv1.Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.states, types.NamespacedName{Namespace: sub.Namespace, Name: sub.Name})
+
+	return nil
+}
+
+// statusStateStore saves the subscriber states in the subscriberState of the subscription status. The inventory is
+// left out, the resources of a large subscription would make it exceed the size limit of the objects.
+type statusStateStore struct{}
+
+func (s *statusStateStore) Get(clt client.Client, sub *appv1.Subscription) (*appv1.SubscriberState, error) {
+	curSub := &appv1.Subscription{}
+	if err := clt.Get(context.TODO(), types.NamespacedName{Namespace: sub.Namespace, Name: sub.Name}, curSub); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	return curSub.Status.SubscriberState, nil
+}
+
+func (s *statusStateStore) Set(clt client.Client, sub *appv1.Subscription, state *appv1.SubscriberState) error {
+	curSub := &appv1.Subscription{}
+	if err := clt.Get(context.TODO(), types.NamespacedName{Namespace: sub.Namespace, Name: sub.Name}, curSub); err != nil {
+		return err
+	}
+
+	curSub.Status.SubscriberState = state.DeepCopy()
+	curSub.Status.SubscriberState.Inventory = nil
+
+	return clt.Status().Update(context.TODO(), curSub)
+}
+
+func (s *statusStateStore) Delete(clt client.Client, sub *appv1.Subscription) error {
+	// the state is deleted with the subscription
+	return nil
+}
+
+// configMapStateStore saves the subscriber states in <subscription>-subscriber-state config maps owned by the
+// subscriptions
+type configMapStateStore struct{}
+
+func getSubscriberStateConfigMapKey(sub *appv1.Subscription) types.NamespacedName {
+	return types.NamespacedName{Namespace: sub.Namespace, Name: sub.Name + SubscriberStateConfigMapSuffix}
+}
+
+func (s *configMapStateStore) Get(clt client.Client, sub *appv1.Subscription) (*appv1.SubscriberState, error) {
+	cm := &corev1.ConfigMap{}
+	if err := clt.Get(context.TODO(), getSubscriberStateConfigMapKey(sub), cm); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	data, ok := cm.Data[SubscriberStateConfigMapKey]
+	if !ok {
+		return nil, nil
+	}
+
+	state := &appv1.SubscriberState{}
+	if err := json.Unmarshal([]byte(data), state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func (s *configMapStateStore) Set(clt client.Client, sub *appv1.Subscription, state *appv1.SubscriberState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	key := getSubscriberStateConfigMapKey(sub)
+
+	cm := &corev1.ConfigMap{}
+	if err := clt.Get(context.TODO(), key, cm); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels:    map[string]string{appv1.LabelSubscriptionName: sub.Name},
+			},
+			Data: map[string]string{SubscriberStateConfigMapKey: string(data)},
+		}
+
+		if sub.UID != "" {
+			cm.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: appv1.SchemeGroupVersion.String(),
+				Kind:       "Subscription",
+				Name:       sub.Name,
+				UID:        sub.UID,
+			}}
+		}
+
+		return clt.Create(context.TODO(), cm)
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+
+	cm.Data[SubscriberStateConfigMapKey] = string(data)
+
+	return clt.Update(context.TODO(), cm)
+}
+
+func (s *configMapStateStore) Delete(clt client.Client, sub *appv1.Subscription) error {
+	key := getSubscriberStateConfigMapKey(sub)
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+
+	return client.IgnoreNotFound(clt.Delete(context.TODO(), cm))
+}
+
+// kvStateStore saves the subscriber states in an external key value store through its HTTP API: GET, PUT and DELETE
+// of the <url>/<cluster>/<namespace>/<name> keys, a missing key is a 404
+type kvStateStore struct {
+	// url is the URL of the keys of the cluster
+	url       string
+	tokenFile string
+	client    *http.Client
+}
+
+func (s *kvStateStore) do(method string, sub *appv1.Subscription, body []byte) (*http.Response, error) {
+	key := s.url + "/" + url.PathEscape(sub.Namespace) + "/" + url.PathEscape(sub.Name)
+
+	req, err := http.NewRequestWithContext(context.TODO(), method, key, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if s.tokenFile != "" {
+		// the token is read on each call, it may be rotated
+		token, err := ioutil.ReadFile(s.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the token file, err: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		resp.Body.Close()
+
+		return nil, fmt.Errorf("%v %v failed with status %v", method, key, resp.Status)
+	}
+
+	return resp, nil
+}
+
+func (s *kvStateStore) Get(clt client.Client, sub *appv1.Subscription) (*appv1.SubscriberState, error) {
+	resp, err := s.do(http.MethodGet, sub, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	state := &appv1.SubscriberState{}
+	if err := json.NewDecoder(resp.Body).Decode(state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func (s *kvStateStore) Set(clt client.Client, sub *appv1.Subscription, state *appv1.SubscriberState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodPut, sub, data)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("the key value store has no path for subscription %v/%v", sub.Namespace, sub.Name)
+	}
+
+	return nil
+}
+
+func (s *kvStateStore) Delete(clt client.Client, sub *appv1.Subscription) error {
+	resp, err := s.do(http.MethodDelete, sub, nil)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	return nil
+}
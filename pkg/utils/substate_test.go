@@ -0,0 +1,136 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "open-cluster-management.io/multicloud-operators-subscription/pkg/apis/apps/v1"
+)
+
+func TestSubscriberStateStores(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	defer SetSubscriberStateStore(NewMemoryStateStore())
+
+	s := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(s)).To(gomega.Succeed())
+	g.Expect(appv1.SchemeBuilder.AddToScheme(s)).To(gomega.Succeed())
+
+	sub := &appv1.Subscription{ObjectMeta: metav1.ObjectMeta{Name: "sub", Namespace: "default", UID: "sub-uid"}}
+	clt := fake.NewClientBuilder().WithScheme(s).WithObjects(sub).Build()
+
+	// the key value store server keeps the values by path
+	var mu sync.Mutex
+
+	kv := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		g.Expect(r.Header.Get("Authorization")).To(gomega.Equal("Bearer kv-token"))
+
+		switch r.Method {
+		case http.MethodGet:
+			value, ok := kv[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+
+				return
+			}
+
+			_, _ = w.Write(value)
+		case http.MethodPut:
+			value, err := ioutil.ReadAll(r.Body)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+
+			kv[r.URL.Path] = value
+		case http.MethodDelete:
+			delete(kv, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	tokenFile := t.TempDir() + "/token"
+	g.Expect(ioutil.WriteFile(tokenFile, []byte("kv-token\n"), 0600)).To(gomega.Succeed())
+
+	for _, kind := range []string{"", SubscriberStateStoreStatus, SubscriberStateStoreConfigMap, SubscriberStateStoreKV} {
+		g.Expect(SetupSubscriberStateStore(kind, server.URL+"/states/", tokenFile, "cluster1")).To(gomega.Succeed())
+
+		g.Expect(LoadSubscriberState(clt, sub)).To(gomega.BeNil())
+
+		state := &appv1.SubscriberState{
+			Revision:   "2c0e7c3",
+			Generation: 2,
+			Successful: true,
+			Retries:    3,
+			Inventory:  []string{GetInventoryKey("v1", "ConfigMap", "default", "settings")},
+		}
+
+		saved := SaveSubscriberState(clt, sub, state, nil)
+		g.Expect(saved).To(gomega.Equal(state))
+
+		if kind == SubscriberStateStoreStatus {
+			// the inventory is not saved in the status
+			g.Expect(LoadSubscriberState(clt, sub).Inventory).To(gomega.BeEmpty())
+			g.Expect(LoadSubscriberState(clt, sub).Revision).To(gomega.Equal(state.Revision))
+		} else {
+			g.Expect(LoadSubscriberState(clt, sub)).To(gomega.Equal(state))
+		}
+
+		if kind == SubscriberStateStoreKV {
+			// the keys are per cluster
+			mu.Lock()
+			g.Expect(kv).To(gomega.HaveKey("/states/cluster1/default/sub"))
+			mu.Unlock()
+		}
+
+		state.Successful = false
+		saved = SaveSubscriberState(clt, sub, state, saved)
+		g.Expect(saved.Successful).To(gomega.BeFalse())
+		g.Expect(LoadSubscriberState(clt, sub).Successful).To(gomega.BeFalse())
+
+		DeleteSubscriberState(clt, sub)
+
+		if kind != SubscriberStateStoreStatus {
+			// the status state is deleted with the subscription
+			g.Expect(LoadSubscriberState(clt, sub)).To(gomega.BeNil())
+		}
+	}
+
+	// the config map state is owned by the subscription
+	g.Expect(SetupSubscriberStateStore(SubscriberStateStoreConfigMap, "", "", "")).To(gomega.Succeed())
+	SaveSubscriberState(clt, sub, &appv1.SubscriberState{Revision: "2c0e7c3"}, nil)
+
+	cm := &corev1.ConfigMap{}
+	g.Expect(clt.Get(context.TODO(), getSubscriberStateConfigMapKey(sub), cm)).To(gomega.Succeed())
+	g.Expect(cm.OwnerReferences).To(gomega.HaveLen(1))
+	g.Expect(cm.OwnerReferences[0].UID).To(gomega.BeEquivalentTo("sub-uid"))
+
+	g.Expect(SetupSubscriberStateStore("etcd", "", "", "")).NotTo(gomega.Succeed())
+	g.Expect(SetupSubscriberStateStore(SubscriberStateStoreKV, "", "", "cluster1")).NotTo(gomega.Succeed())
+	g.Expect(SetupSubscriberStateStore(SubscriberStateStoreKV, server.URL, "", "")).NotTo(gomega.Succeed())
+}
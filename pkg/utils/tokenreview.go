@@ -0,0 +1,70 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AuthorizeBearerToken reviews the bearer token of the request and checks its user is allowed the resource
+// attributes. It returns the HTTP status code to fail the request with.
+func AuthorizeBearerToken(r *http.Request, authClient kubernetes.Interface, attributes authzv1.ResourceAttributes) (int, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		return http.StatusUnauthorized, fmt.Errorf("missing bearer token")
+	}
+
+	review, err := authClient.AuthenticationV1().TokenReviews().Create(r.Context(),
+		&authnv1.TokenReview{Spec: authnv1.TokenReviewSpec{Token: token}}, metav1.CreateOptions{})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if !review.Status.Authenticated {
+		return http.StatusUnauthorized, fmt.Errorf("invalid bearer token")
+	}
+
+	extra := map[string]authzv1.ExtraValue{}
+	for k, v := range review.Status.User.Extra {
+		extra[k] = authzv1.ExtraValue(v)
+	}
+
+	sar, err := authClient.AuthorizationV1().SubjectAccessReviews().Create(r.Context(), &authzv1.SubjectAccessReview{
+		Spec: authzv1.SubjectAccessReviewSpec{
+			User:               review.Status.User.Username,
+			UID:                review.Status.User.UID,
+			Groups:             review.Status.User.Groups,
+			Extra:              extra,
+			ResourceAttributes: &attributes,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if !sar.Status.Allowed {
+		return http.StatusForbidden, fmt.Errorf("user %v can't %v %v %v in namespace %q", review.Status.User.Username,
+			attributes.Verb, attributes.Resource, attributes.Name, attributes.Namespace)
+	}
+
+	return http.StatusOK, nil
+}
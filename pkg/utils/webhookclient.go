@@ -0,0 +1,161 @@
+// Copyright 2021 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// WebhookFailurePolicyFail fails the operation calling the webhook when the webhook can't be called
+	WebhookFailurePolicyFail = "Fail"
+	// WebhookFailurePolicyIgnore carries on without the webhook when the webhook can't be called
+	WebhookFailurePolicyIgnore = "Ignore"
+
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+// WebhookConfig is an external endpoint called by the controllers, e.g. a mutation webhook or a package filter plugin
+type WebhookConfig struct {
+	Name string `json:"name"`
+	// URL receives the POST requests of the webhook
+	URL string `json:"url,omitempty"`
+	// TimeoutSeconds is the timeout of a call, 10 seconds if not set
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// FailurePolicy is Fail or Ignore, Fail if not set
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+	// CAFile is the file of the CA certificates verifying the webhook server, the system ones are used if not set
+	CAFile string `json:"caFile,omitempty"`
+	// TokenFile is the file of the bearer token presented to the webhook server
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+// SetDefaults validates the failure policy of the config, and sets it to Fail if not set
+func (c *WebhookConfig) SetDefaults() error {
+	switch c.FailurePolicy {
+	case "":
+		c.FailurePolicy = WebhookFailurePolicyFail
+	case WebhookFailurePolicyFail, WebhookFailurePolicyIgnore:
+	default:
+		return fmt.Errorf("unknown failure policy %v, use %v or %v", c.FailurePolicy, WebhookFailurePolicyFail,
+			WebhookFailurePolicyIgnore)
+	}
+
+	return nil
+}
+
+// Timeout returns the timeout of a call, 10 seconds if not set
+func (c *WebhookConfig) Timeout() time.Duration {
+	if c.TimeoutSeconds > 0 {
+		return time.Duration(c.TimeoutSeconds) * time.Second
+	}
+
+	return defaultWebhookTimeout
+}
+
+// IgnoresFailures returns true if the failures of the webhook are ignored
+func (c *WebhookConfig) IgnoresFailures() bool {
+	return c.FailurePolicy == WebhookFailurePolicyIgnore
+}
+
+// WebhookClient posts JSON requests to a webhook
+type WebhookClient struct {
+	config          WebhookConfig
+	client          *http.Client
+	maxResponseSize int64
+}
+
+// NewWebhookClient creates the client of the webhook of config, verifying its server with the CA file of the config.
+// The responses are read up to maxResponseSize bytes.
+func NewWebhookClient(config WebhookConfig, maxResponseSize int64) (*WebhookClient, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if config.CAFile != "" {
+		caCerts, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file, err: %w", err)
+		}
+
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caCerts) {
+			return nil, fmt.Errorf("no certificate found in CA file %v", config.CAFile)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: certPool, MinVersion: tls.VersionTLS12}
+	}
+
+	return &WebhookClient{
+		config:          config,
+		client:          &http.Client{Transport: transport, Timeout: config.Timeout()},
+		maxResponseSize: maxResponseSize,
+	}, nil
+}
+
+// Post sends the request to the webhook, with the bearer token of the token file, and decodes its response. The
+// webhook must answer 200.
+func (c *WebhookClient) Post(request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, c.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	if c.config.TokenFile != "" {
+		// the token is read on each call, it may be rotated
+		token, err := ioutil.ReadFile(c.config.TokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read the token file, err: %w", err)
+		}
+
+		httpReq.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, c.maxResponseSize))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %v: %.256s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	if err := json.Unmarshal(respBody, response); err != nil {
+		return fmt.Errorf("invalid response, err: %w", err)
+	}
+
+	return nil
+}
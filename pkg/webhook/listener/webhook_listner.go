@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -110,7 +111,8 @@ func (listener *WebhookListener) Start(ctx context.Context) error {
 
 	if listener.TLSKeyFile != "" && listener.TLSCrtFile != "" {
 		klog.Info("Starting the WebHook listener on port 8443 with TLS key and cert files: " + listener.TLSKeyFile + " " + listener.TLSCrtFile)
-		klog.Fatal(http.ListenAndServeTLS(":8443", listener.TLSCrtFile, listener.TLSKeyFile, nil))
+		klog.Fatal(utils.ListenAndServeTLS(&http.Server{Addr: ":8443", ReadHeaderTimeout: 30 * time.Second},
+			listener.TLSCrtFile, listener.TLSKeyFile))
 	} else {
 		klog.Info("Starting the WebHook listener on port 8443 with no TLS.")
 		klog.Fatal(http.ListenAndServe(":8443", nil))